@@ -15,6 +15,7 @@
 package git
 
 import (
+	"bytes"
 	"context"
 	"path/filepath"
 	"testing"
@@ -263,6 +264,44 @@ func TestAmend(t *testing.T) {
 			t.Errorf("HEAD ref = %s; want refs/heads/main", head.Ref)
 		}
 	})
+
+	t.Run("Sign", func(t *testing.T) {
+		env, err := newTestEnv(ctx, gitPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer env.cleanup()
+		keyPath := newTestSSHKey(t, env)
+		if err := env.g.Init(ctx, "."); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.root.Apply(filesystem.Write("foo.txt", dummyContent)); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.g.Add(ctx, []Pathspec{"foo.txt"}, AddOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.g.Commit(ctx, "initial import\n", CommitOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		err = env.g.Amend(ctx, AmendOptions{
+			SigningKey:    keyPath,
+			SigningFormat: "ssh",
+		})
+		if err != nil {
+			t.Fatal("Amend error:", err)
+		}
+		info, err := env.g.CommitInfo(ctx, "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(info.GPGSignature, []byte("-----BEGIN SSH SIGNATURE-----\n")) {
+			t.Errorf("signature = %q; want SSH signature", info.GPGSignature)
+		}
+		if info.Message != "initial import\n" {
+			t.Errorf("message = %q; want %q", info.Message, "initial import\n")
+		}
+	})
 }
 
 func TestAmendFiles(t *testing.T) {
@@ -527,6 +527,14 @@ type CommitOptions struct {
 
 	// If SkipHooks is true, pre-commit and commit-msg hooks will be skipped.
 	SkipHooks bool
+
+	// If Sign is true, the commit will be signed (like `git commit -S`).
+	// SigningKey and SigningFormat override the user.signingKey
+	// and gpg.format configuration settings, respectively.
+	// SigningKey implies Sign.
+	Sign          bool
+	SigningKey    string
+	SigningFormat string
 }
 
 func (opts CommitOptions) addToEnv(env []string) []string {
@@ -551,6 +559,22 @@ func (opts CommitOptions) addToArgs(args []string) []string {
 	if opts.SkipHooks {
 		args = append(args, "--no-verify")
 	}
+	return addSigningArgs(args, opts.Sign, opts.SigningKey, opts.SigningFormat)
+}
+
+// addSigningArgs adds the arguments for signing a commit to args,
+// which must start with the subcommand name.
+func addSigningArgs(args []string, sign bool, key, format string) []string {
+	if format != "" {
+		// Configuration must come before the subcommand.
+		args = append([]string{"-c", "gpg.format=" + format}, args...)
+	}
+	switch {
+	case key != "":
+		args = append(args, "--gpg-sign="+key)
+	case sign:
+		args = append(args, "--gpg-sign")
+	}
 	return args
 }
 
@@ -666,6 +690,14 @@ type AmendOptions struct {
 
 	// If SkipHooks is true, pre-commit and commit-msg hooks will be skipped.
 	SkipHooks bool
+
+	// If Sign is true, the new commit will be signed (like `git commit -S`).
+	// SigningKey and SigningFormat override the user.signingKey
+	// and gpg.format configuration settings, respectively.
+	// SigningKey implies Sign.
+	Sign          bool
+	SigningKey    string
+	SigningFormat string
 }
 
 func (opts AmendOptions) addToArgs(args []string) []string {
@@ -678,7 +710,7 @@ func (opts AmendOptions) addToArgs(args []string) []string {
 	if opts.SkipHooks {
 		args = append(args, "--no-verify")
 	}
-	return args
+	return addSigningArgs(args, opts.Sign, opts.SigningKey, opts.SigningFormat)
 }
 
 func (opts AmendOptions) addToEnv(env []string) []string {
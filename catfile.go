@@ -0,0 +1,447 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gg-scm.io/pkg/git/object"
+)
+
+const catFileBatchErrPrefix = "git cat-file --batch-command"
+
+// Limits on the number of requests that an ObjectReader sends to Git
+// before reading responses. Git does not read more commands
+// while it is blocked on writing output, so the commands for a batch
+// must fit in the operating system's pipe buffer.
+const (
+	maxCatFileBatchRequests = 128
+	maxCatFileBatchBytes    = 16 << 10 // 16 KiB
+)
+
+var errObjectReaderClosed = errors.New("object reader closed")
+
+// ObjectInfo describes an object in a repository.
+type ObjectInfo struct {
+	ID   Hash
+	Type object.Type
+	Size int64
+}
+
+// ObjectReader is an open handle to a `git cat-file --batch-command`
+// subprocess, which reads objects from a repository without starting a new
+// process for each object. It requires Git 2.36 or later.
+//
+// ObjectReader is safe to call from multiple goroutines simultaneously.
+// Requests are queued and sent to Git in batches.
+// *ObjectReader implements [object.ObjectReader].
+type ObjectReader struct {
+	requests   chan *catFileRequest
+	closing    chan struct{} // closed when Close is called
+	done       chan struct{} // closed when the worker goroutine exits
+	cancelFunc context.CancelFunc
+	closeOnce  sync.Once
+
+	// Owned by the worker goroutine until done is closed.
+	stdin  *io.PipeWriter
+	pipe   io.ReadCloser
+	r      *bufio.Reader
+	stderr *bytes.Buffer // can't be read until the pipe is closed
+	err    error
+}
+
+type catFileRequest struct {
+	command string // "info" or "contents"
+	rev     string
+	resp    chan catFileResponse
+}
+
+type catFileResponse struct {
+	info *ObjectInfo
+	body *catFileBody // only for "contents"
+	err  error
+}
+
+// NewObjectReader starts a `git cat-file --batch-command` subprocess.
+// The context's deadline and cancelation will apply to the lifetime
+// of the ObjectReader. The caller is responsible for calling Close
+// on the returned ObjectReader.
+func (g *Git) NewObjectReader(ctx context.Context) (*ObjectReader, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stdinReader, stdinWriter := io.Pipe()
+	stderr := new(bytes.Buffer)
+	pipe, err := StartPipe(ctx, g.runner, &Invocation{
+		Args:   []string{"cat-file", "--batch-command", "--buffer"},
+		Dir:    g.dir,
+		Stdin:  stdinReader,
+		Stderr: &limitWriter{w: stderr, n: errorOutputLimit},
+	})
+	if err != nil {
+		cancel()
+		stdinWriter.Close()
+		return nil, fmt.Errorf("%s: %w", catFileBatchErrPrefix, err)
+	}
+	r := &ObjectReader{
+		requests:   make(chan *catFileRequest),
+		closing:    make(chan struct{}),
+		done:       make(chan struct{}),
+		cancelFunc: cancel,
+		stdin:      stdinWriter,
+		pipe:       pipe,
+		r:          bufio.NewReaderSize(pipe, 64<<10 /* 64 KiB */),
+		stderr:     stderr,
+	}
+	go r.run()
+	return r, nil
+}
+
+// Info returns the type and size of the object named by rev.
+// rev may be any object name that Git accepts,
+// such as a hash or "HEAD:path/to/file".
+// If the object does not exist, Info returns an error for which
+// errors.Is(err, fs.ErrNotExist) reports true.
+func (r *ObjectReader) Info(rev string) (*ObjectInfo, error) {
+	resp := r.do("info", rev)
+	if resp.err != nil {
+		return nil, resp.err
+	}
+	return resp.info, nil
+}
+
+// Open returns the type and size of the object named by rev
+// along with a reader for the object's contents.
+// The caller must close the returned reader before the ObjectReader
+// can process other requests.
+// If the object does not exist, Open returns an error for which
+// errors.Is(err, fs.ErrNotExist) reports true.
+func (r *ObjectReader) Open(rev string) (*ObjectInfo, io.ReadCloser, error) {
+	resp := r.do("contents", rev)
+	if resp.err != nil {
+		return nil, nil, resp.err
+	}
+	return resp.info, resp.body, nil
+}
+
+// ReadCommit reads and parses the commit with the given hash.
+func (r *ObjectReader) ReadCommit(id Hash) (*object.Commit, error) {
+	data, err := r.readTyped(id, object.TypeCommit)
+	if err != nil {
+		return nil, err
+	}
+	c, err := object.ParseCommit(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: commit %v: %w", catFileBatchErrPrefix, id, err)
+	}
+	return c, nil
+}
+
+// ReadTree reads and parses the tree with the given hash.
+func (r *ObjectReader) ReadTree(id Hash) (object.Tree, error) {
+	data, err := r.readTyped(id, object.TypeTree)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := object.ParseTreeFormat(data, id.ObjectFormat())
+	if err != nil {
+		return nil, fmt.Errorf("%s: tree %v: %w", catFileBatchErrPrefix, id, err)
+	}
+	return tree, nil
+}
+
+// ReadTag reads and parses the annotated tag with the given hash.
+func (r *ObjectReader) ReadTag(id Hash) (*object.Tag, error) {
+	data, err := r.readTyped(id, object.TypeTag)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := object.ParseTag(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: tag %v: %w", catFileBatchErrPrefix, id, err)
+	}
+	return tag, nil
+}
+
+// ReadBlob reads the content of the blob with the given hash.
+// Use [*ObjectReader.Open] to stream large blobs.
+func (r *ObjectReader) ReadBlob(id Hash) ([]byte, error) {
+	return r.readTyped(id, object.TypeBlob)
+}
+
+func (r *ObjectReader) readTyped(id Hash, want object.Type) ([]byte, error) {
+	info, body, err := r.Open(id.String())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	if info.Type != want {
+		return nil, fmt.Errorf("%s: %v %v: object is a %v", catFileBatchErrPrefix, want, id, info.Type)
+	}
+	data := make([]byte, int(info.Size))
+	if _, err := io.ReadFull(body, data); err != nil {
+		return nil, fmt.Errorf("%s: %v %v: %w", catFileBatchErrPrefix, want, id, err)
+	}
+	return data, nil
+}
+
+// do sends a request to the worker goroutine and waits for its response.
+func (r *ObjectReader) do(command, rev string) catFileResponse {
+	if rev == "" {
+		return catFileResponse{err: fmt.Errorf("%s: %s: empty object name", catFileBatchErrPrefix, command)}
+	}
+	if strings.ContainsAny(rev, "\n\x00") {
+		return catFileResponse{err: fmt.Errorf("%s: %s %q: object name contains newline or NUL", catFileBatchErrPrefix, command, rev)}
+	}
+	req := &catFileRequest{
+		command: command,
+		rev:     rev,
+		resp:    make(chan catFileResponse, 1),
+	}
+	select {
+	case r.requests <- req:
+	case <-r.done:
+		return catFileResponse{err: r.closedError()}
+	}
+	select {
+	case resp := <-req.resp:
+		return resp
+	case <-r.done:
+		// The worker may have sent a response before exiting.
+		select {
+		case resp := <-req.resp:
+			return resp
+		default:
+			return catFileResponse{err: r.closedError()}
+		}
+	}
+}
+
+// closedError returns the error to report for requests
+// made after the worker goroutine exits.
+func (r *ObjectReader) closedError() error {
+	<-r.done
+	return r.err
+}
+
+// run is the ObjectReader's worker goroutine. It sends batches of requests
+// to Git followed by a flush command and then reads the responses in order.
+func (r *ObjectReader) run() {
+	defer close(r.done)
+	var batch []*catFileRequest
+	cmds := new(bytes.Buffer)
+	for {
+		select {
+		case req := <-r.requests:
+			batch = append(batch[:0], req)
+		case <-r.closing:
+			r.stop(errObjectReaderClosed)
+			return
+		}
+		cmds.Reset()
+		fmt.Fprintf(cmds, "%s %s\n", batch[0].command, batch[0].rev)
+	fill:
+		for len(batch) < maxCatFileBatchRequests && cmds.Len() < maxCatFileBatchBytes {
+			select {
+			case req := <-r.requests:
+				batch = append(batch, req)
+				fmt.Fprintf(cmds, "%s %s\n", req.command, req.rev)
+			default:
+				break fill
+			}
+		}
+		cmds.WriteString("flush\n")
+
+		if _, err := r.stdin.Write(cmds.Bytes()); err != nil {
+			r.fail(batch, err)
+			return
+		}
+		for i, req := range batch {
+			resp, err := r.readResponse(req)
+			if err != nil {
+				r.fail(batch[i:], err)
+				return
+			}
+			req.resp <- resp
+			if resp.body == nil {
+				continue
+			}
+			if err := resp.body.wait(r.closing); err != nil {
+				r.fail(batch[i+1:], err)
+				return
+			}
+		}
+	}
+}
+
+// readResponse reads the output of a single command.
+// Reference: https://git-scm.com/docs/git-cat-file#_batch_output
+func (r *ObjectReader) readResponse(req *catFileRequest) (catFileResponse, error) {
+	line, err := r.r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return catFileResponse{}, err
+	}
+	line = line[:len(line)-1]
+	if rest, ok := cutSuffix(line, " missing"); ok && string(rest) == req.rev {
+		return catFileResponse{err: fmt.Errorf("%s: %s %s: object not found: %w", catFileBatchErrPrefix, req.command, req.rev, fs.ErrNotExist)}, nil
+	}
+	if rest, ok := cutSuffix(line, " ambiguous"); ok && string(rest) == req.rev {
+		return catFileResponse{err: fmt.Errorf("%s: %s %s: ambiguous object name", catFileBatchErrPrefix, req.command, req.rev)}, nil
+	}
+	fields := bytes.Fields(line)
+	if len(fields) != 3 {
+		return catFileResponse{}, fmt.Errorf("invalid object information line %q", line)
+	}
+	info := new(ObjectInfo)
+	if err := info.ID.UnmarshalText(fields[0]); err != nil {
+		return catFileResponse{}, fmt.Errorf("invalid object information line: %w", err)
+	}
+	info.Type = object.Type(fields[1])
+	info.Size, err = strconv.ParseInt(string(fields[2]), 10, 64)
+	if err != nil || info.Size < 0 {
+		return catFileResponse{}, fmt.Errorf("invalid object information line %q", line)
+	}
+	resp := catFileResponse{info: info}
+	if req.command == "contents" {
+		resp.body = &catFileBody{
+			r:         r.r,
+			remaining: info.Size,
+			closed:    make(chan struct{}),
+		}
+	}
+	return resp, nil
+}
+
+func cutSuffix(b []byte, suffix string) ([]byte, bool) {
+	if !bytes.HasSuffix(b, []byte(suffix)) {
+		return b, false
+	}
+	return b[:len(b)-len(suffix)], true
+}
+
+// fail stops the subprocess and reports err to the given requests.
+func (r *ObjectReader) fail(reqs []*catFileRequest, err error) {
+	r.stop(err)
+	for _, req := range reqs {
+		req.resp <- catFileResponse{err: r.err}
+	}
+}
+
+// stop stops the subprocess and waits for it to exit.
+// If cause is errObjectReaderClosed, then the ObjectReader is being closed
+// and errors from interrupting the subprocess are ignored.
+func (r *ObjectReader) stop(cause error) {
+	r.stdin.Close()
+	waitErr := r.pipe.Close()
+	r.cancelFunc()
+	switch {
+	case errors.Is(cause, errObjectReaderClosed):
+		r.err = fmt.Errorf("%s: %w", catFileBatchErrPrefix, cause)
+	case waitErr != nil:
+		r.err = commandError(catFileBatchErrPrefix, waitErr, r.stderr.Bytes())
+	default:
+		r.err = fmt.Errorf("%s: %w", catFileBatchErrPrefix, cause)
+	}
+}
+
+// Close stops the subprocess and waits for it to exit.
+// Requests made after Close is called return an error,
+// as do reads from any object contents that are still open.
+// Close returns an error if the subprocess failed before Close was called.
+func (r *ObjectReader) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closing)
+	})
+	<-r.done
+	if errors.Is(r.err, errObjectReaderClosed) {
+		return nil
+	}
+	return r.err
+}
+
+// catFileBody reads the contents of a single object from cat-file's output.
+type catFileBody struct {
+	r         *bufio.Reader
+	remaining int64
+	closeOnce sync.Once
+	closed    chan struct{}
+	err       error // read error to report to the worker
+}
+
+func (body *catFileBody) Read(p []byte) (int, error) {
+	select {
+	case <-body.closed:
+		return 0, errors.New("read from closed object")
+	default:
+	}
+	if body.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > body.remaining {
+		p = p[:body.remaining]
+	}
+	n, err := body.r.Read(p)
+	body.remaining -= int64(n)
+	if err == io.EOF && body.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && err != io.EOF {
+		body.err = err
+	}
+	return n, err
+}
+
+// Close skips any unread object contents
+// and allows the ObjectReader to process the next request.
+func (body *catFileBody) Close() error {
+	body.closeOnce.Do(func() {
+		close(body.closed)
+	})
+	return nil
+}
+
+// wait waits for the body to be closed and then consumes the rest of the object
+// along with the newline that follows it.
+func (body *catFileBody) wait(closing <-chan struct{}) error {
+	select {
+	case <-body.closed:
+	case <-closing:
+		return errObjectReaderClosed
+	}
+	if body.err != nil {
+		return body.err
+	}
+	if _, err := body.r.Discard(int(body.remaining)); err != nil {
+		return err
+	}
+	if b, err := body.r.ReadByte(); err != nil {
+		return err
+	} else if b != '\n' {
+		return errors.New("object contents not followed by newline")
+	}
+	return nil
+}
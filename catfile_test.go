@@ -0,0 +1,280 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+
+	"gg-scm.io/pkg/git/internal/filesystem"
+	"gg-scm.io/pkg/git/object"
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ object.ObjectReader = (*ObjectReader)(nil)
+
+func TestObjectReader(t *testing.T) {
+	gitPath, err := findGit()
+	if err != nil {
+		t.Skip("git not found:", err)
+	}
+	ctx := context.Background()
+	env, err := newTestEnv(ctx, gitPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.cleanup()
+	if version, err := env.g.getVersion(ctx); err != nil {
+		t.Fatal(err)
+	} else if major, minor, ok := parseVersion(version); !ok || major < 2 || major == 2 && minor < 36 {
+		t.Skipf("%s does not support cat-file --batch-command", strings.TrimSpace(version))
+	}
+	if err := env.g.Init(ctx, "."); err != nil {
+		t.Fatal(err)
+	}
+	const bigContent = "Lorem ipsum dolor sit amet, consectetur adipiscing elit.\n"
+	err = env.root.Apply(
+		filesystem.Write("foo.txt", dummyContent),
+		filesystem.Write("dir/big.txt", strings.Repeat(bigContent, 10000)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Add(ctx, []Pathspec{"."}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "first", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.root.Apply(filesystem.Write("foo.txt", "changed\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.CommitAll(ctx, "second", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Run(ctx, "tag", "-a", "-m", "Release", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	head, err := env.g.Head(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCommit, err := env.g.CommitInfo(ctx, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagRev, err := env.g.ParseRev(ctx, "refs/tags/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagOut, err := env.g.Output(ctx, "rev-parse", "refs/tags/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagID, err := ParseHash(strings.TrimSpace(tagOut))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := env.g.NewObjectReader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			t.Error("Close:", err)
+		}
+	}()
+
+	t.Run("ReadCommit", func(t *testing.T) {
+		got, err := r.ReadCommit(head.Commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(wantCommit, got); diff != "" {
+			t.Errorf("ReadCommit(%v) (-want +got):\n%s", head.Commit, diff)
+		}
+	})
+	t.Run("ReadTree", func(t *testing.T) {
+		got, err := r.ReadTree(wantCommit.Tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.SHA1() != wantCommit.Tree {
+			t.Errorf("ReadTree(%v) = %v", wantCommit.Tree, got)
+		}
+		if got.Search("dir") == nil || got.Search("foo.txt") == nil {
+			t.Errorf("ReadTree(%v) = %v; missing entries", wantCommit.Tree, got)
+		}
+	})
+	t.Run("ReadBlob", func(t *testing.T) {
+		got, err := r.ReadBlob(blobSum("changed\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "changed\n" {
+			t.Errorf("ReadBlob(...) = %q; want %q", got, "changed\n")
+		}
+	})
+	t.Run("ReadTag", func(t *testing.T) {
+		got, err := r.ReadTag(tagID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "v1" || got.ObjectID != tagRev.Commit || got.Message != "Release\n" {
+			t.Errorf("ReadTag(%v) = %+v", tagID, got)
+		}
+	})
+	t.Run("WrongType", func(t *testing.T) {
+		if _, err := r.ReadTree(head.Commit); err == nil {
+			t.Error("ReadTree(commit) did not return an error")
+		}
+	})
+	t.Run("Info", func(t *testing.T) {
+		got, err := r.Info("HEAD:foo.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &ObjectInfo{
+			ID:   blobSum("changed\n"),
+			Type: object.TypeBlob,
+			Size: int64(len("changed\n")),
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Info(\"HEAD:foo.txt\") (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		if _, err := r.Info("HEAD:nope.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Info(\"HEAD:nope.txt\") error = %v; want fs.ErrNotExist", err)
+		}
+		if _, _, err := r.Open(blobSum("nope\n").String()); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Open(missing) error = %v; want fs.ErrNotExist", err)
+		}
+	})
+	t.Run("PartialRead", func(t *testing.T) {
+		info, body, err := r.Open("HEAD:dir/big.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := int64(len(bigContent) * 10000); info.Size != want {
+			t.Errorf("size = %d; want %d", info.Size, want)
+		}
+		buf := make([]byte, len(bigContent))
+		if _, err := io.ReadFull(body, buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != bigContent {
+			t.Errorf("first line = %q; want %q", buf, bigContent)
+		}
+		if err := body.Close(); err != nil {
+			t.Error("body.Close:", err)
+		}
+		// The reader should continue to work after skipping the rest of the blob.
+		got, err := r.ReadBlob(blobSum(dummyContent))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != dummyContent {
+			t.Errorf("ReadBlob(...) = %q; want %q", got, dummyContent)
+		}
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					var err error
+					switch (i + j) % 3 {
+					case 0:
+						_, err = r.ReadCommit(head.Commit)
+					case 1:
+						_, err = r.Info("HEAD:dir/big.txt")
+					case 2:
+						var data []byte
+						data, err = r.ReadBlob(blobSum(dummyContent))
+						if err == nil && string(data) != dummyContent {
+							err = errors.New("wrong blob content")
+						}
+					}
+					if err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+func TestObjectReaderClose(t *testing.T) {
+	gitPath, err := findGit()
+	if err != nil {
+		t.Skip("git not found:", err)
+	}
+	ctx := context.Background()
+	env, err := newTestEnv(ctx, gitPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.cleanup()
+	if version, err := env.g.getVersion(ctx); err != nil {
+		t.Fatal(err)
+	} else if major, minor, ok := parseVersion(version); !ok || major < 2 || major == 2 && minor < 36 {
+		t.Skipf("%s does not support cat-file --batch-command", strings.TrimSpace(version))
+	}
+	if err := env.g.Init(ctx, "."); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.root.Apply(filesystem.Write("foo.txt", dummyContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Add(ctx, []Pathspec{"foo.txt"}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "first", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := env.g.NewObjectReader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leave an object open while closing.
+	_, body, err := r.Open("HEAD:foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Error("Close:", err)
+	}
+	body.Close()
+	if _, err := r.Info("HEAD"); err == nil {
+		t.Error("Info after Close did not return an error")
+	}
+	if err := r.Close(); err != nil {
+		t.Error("second Close:", err)
+	}
+}
@@ -15,6 +15,7 @@
 package git
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"path/filepath"
@@ -86,6 +87,38 @@ func TestCommit(t *testing.T) {
 		}
 	})
 
+	t.Run("Sign", func(t *testing.T) {
+		env, err := newTestEnv(ctx, gitPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer env.cleanup()
+		keyPath := newTestSSHKey(t, env)
+		if err := env.g.Init(ctx, "."); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.root.Apply(filesystem.Write("foo.txt", dummyContent)); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.g.Add(ctx, []Pathspec{"foo.txt"}, AddOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		err = env.g.Commit(ctx, "initial import\n", CommitOptions{
+			SigningKey:    keyPath,
+			SigningFormat: "ssh",
+		})
+		if err != nil {
+			t.Fatal("Commit error:", err)
+		}
+		info, err := env.g.CommitInfo(ctx, "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(info.GPGSignature, []byte("-----BEGIN SSH SIGNATURE-----\n")) {
+			t.Errorf("signature = %q; want SSH signature", info.GPGSignature)
+		}
+	})
+
 	t.Run("LocalChanges", func(t *testing.T) {
 		env, err := newTestEnv(ctx, gitPath)
 		if err != nil {
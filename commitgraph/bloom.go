@@ -0,0 +1,175 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package commitgraph
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"strings"
+
+	"gg-scm.io/pkg/git/object"
+)
+
+// Default changed-path Bloom filter settings, matching Git.
+const (
+	bloomHashVersion     = 1
+	bloomNumHashes       = 7
+	bloomBitsPerEntry    = 10
+	bloomMaxChangedPaths = 512
+)
+
+// bloomSettings are the parameters stored in the header of a BDAT chunk.
+type bloomSettings struct {
+	hashVersion  uint32
+	numHashes    uint32
+	bitsPerEntry uint32
+}
+
+const bloomSettingsSize = 12
+
+func (s *bloomSettings) appendBinary(dst []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, s.hashVersion)
+	dst = binary.BigEndian.AppendUint32(dst, s.numHashes)
+	dst = binary.BigEndian.AppendUint32(dst, s.bitsPerEntry)
+	return dst
+}
+
+// bloomKeyHashes returns the bit positions (before reduction modulo
+// the filter size) that represent path in a Bloom filter.
+func (s *bloomSettings) bloomKeyHashes(path string) []uint32 {
+	const (
+		seed0 = 0x293ae76f
+		seed1 = 0x7e646e2c
+	)
+	signed := s.hashVersion == 1
+	hash0 := murmur3(seed0, path, signed)
+	hash1 := murmur3(seed1, path, signed)
+	hashes := make([]uint32, s.numHashes)
+	for i := range hashes {
+		hashes[i] = hash0 + uint32(i)*hash1
+	}
+	return hashes
+}
+
+// filterContains reports whether the Bloom filter may contain path.
+func (s *bloomSettings) filterContains(filter []byte, path string) bool {
+	if len(filter) == 0 {
+		// Git treats empty filters as missing.
+		return true
+	}
+	nbits := uint64(len(filter)) * 8
+	for _, h := range s.bloomKeyHashes(path) {
+		pos := uint64(h) % nbits
+		if filter[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// buildFilter builds a Bloom filter for the given changed paths.
+func (s *bloomSettings) buildFilter(changes []*object.TreeChange) []byte {
+	if len(changes) > bloomMaxChangedPaths {
+		return []byte{0xff}
+	}
+	// Each changed file's leading directories are also added to the filter.
+	paths := make(map[string]struct{})
+	for _, c := range changes {
+		p := c.Name
+		for {
+			paths[p] = struct{}{}
+			i := strings.LastIndexByte(p, '/')
+			if i == -1 {
+				break
+			}
+			p = p[:i]
+		}
+	}
+	if len(paths) > bloomMaxChangedPaths {
+		return []byte{0xff}
+	}
+	n := (len(paths)*int(s.bitsPerEntry) + 7) / 8
+	if n == 0 {
+		n = 1
+	}
+	filter := make([]byte, n)
+	nbits := uint64(n) * 8
+	for p := range paths {
+		for _, h := range s.bloomKeyHashes(p) {
+			pos := uint64(h) % nbits
+			filter[pos/8] |= 1 << (pos % 8)
+		}
+	}
+	return filter
+}
+
+// murmur3 computes the 32-bit MurmurHash3 of data with the given seed.
+// If signed is true, bytes are sign-extended before they are mixed in,
+// reproducing the hash used by version 1 changed-path Bloom filters.
+func murmur3(seed uint32, data string, signed bool) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+		r1 = 15
+		r2 = 13
+		m  = 5
+		n  = 0xe6546b64
+	)
+	b := func(i int) uint32 {
+		if signed {
+			return uint32(int32(int8(data[i])))
+		}
+		return uint32(data[i])
+	}
+
+	h := seed
+	len4 := len(data) / 4
+	for i := 0; i < len4; i++ {
+		k := b(4*i) | b(4*i+1)<<8 | b(4*i+2)<<16 | b(4*i+3)<<24
+		k *= c1
+		k = bits.RotateLeft32(k, r1)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, r2)
+		h = h*m + n
+	}
+
+	tail := 4 * len4
+	var k uint32
+	switch len(data) & 3 {
+	case 3:
+		k ^= b(tail+2) << 16
+		fallthrough
+	case 2:
+		k ^= b(tail+1) << 8
+		fallthrough
+	case 1:
+		k ^= b(tail)
+		k *= c1
+		k = bits.RotateLeft32(k, r1)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
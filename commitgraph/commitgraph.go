@@ -0,0 +1,566 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package commitgraph reads and writes Git commit-graph files,
+which store the parents, root trees, commit times, and generation numbers
+of commits, along with optional changed-path Bloom filters.
+The format is described in https://git-scm.com/docs/gitformat-commit-graph.
+
+A Graph implements [revwalk.GenerationReader], so it can be combined with
+a commit reader to speed up history walks:
+
+	graph, err := commitgraph.Open(filepath.Join(gitDir, "objects"))
+	if err != nil {
+		return err
+	}
+	walker := revwalk.New(struct {
+		*gitrepo.Repository
+		*commitgraph.Graph
+	}{repo, graph})
+
+A Graph is safe to use from multiple goroutines simultaneously.
+
+[revwalk.GenerationReader]: https://pkg.go.dev/gg-scm.io/pkg/git/revwalk#GenerationReader
+*/
+package commitgraph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/internal/chunkfile"
+)
+
+// File format constants.
+const (
+	signature  = "CGPH"
+	version    = 1
+	headerSize = 8
+
+	fanOutSize = 256 * 4
+
+	parentNone       = 0x70000000
+	parentExtraEdges = 0x80000000
+	lastEdge         = 0x80000000
+
+	generationV1Max          = 0x3fffffff
+	generationV2OffsetMax    = 1<<31 - 1
+	generationOffsetOverflow = 0x80000000
+	commitTimeMax            = 1<<34 - 1
+)
+
+// Chunk IDs.
+const (
+	chunkOIDFanOut        = "OIDF"
+	chunkOIDLookup        = "OIDL"
+	chunkCommitData       = "CDAT"
+	chunkGenerationData   = "GDA2"
+	chunkGenerationOffset = "GDO2"
+	chunkExtraEdges       = "EDGE"
+	chunkBloomIndexes     = "BIDX"
+	chunkBloomData        = "BDAT"
+	chunkBase             = "BASE"
+)
+
+// A Graph is a parsed commit-graph file, along with the files for its
+// base layers if it is part of a split commit-graph chain.
+type Graph struct {
+	base    *Graph
+	format  githash.ObjectFormat
+	hash    githash.ObjectID
+	numBase uint32
+	n       uint32
+	// genData is true if every layer of the chain
+	// stores corrected commit dates.
+	genData bool
+
+	fanOut      []byte
+	oids        []byte
+	commitData  []byte
+	genDates    []byte
+	genOffsets  []byte
+	extraEdges  []byte
+	bloomIndex  []byte
+	bloomData   []byte
+	bloomConfig *bloomSettings
+}
+
+// A Commit is the information stored about a single commit in a commit-graph.
+type Commit struct {
+	ID      githash.ObjectID
+	Tree    githash.ObjectID
+	Parents []githash.ObjectID
+	// CommitTime is the commit's time in seconds since the Unix epoch.
+	CommitTime int64
+	// Generation is the commit's topological level: 1 for root commits
+	// and otherwise one more than the maximum level of its parents.
+	// It is capped at 0x3fffffff.
+	Generation uint32
+	// CorrectedCommitDate is the maximum of the commit's time
+	// and one more than the corrected commit dates of its parents.
+	// It is zero if the graph does not store corrected commit dates.
+	CorrectedCommitDate int64
+}
+
+// Open reads the commit-graph for the given objects directory.
+// It reads info/commit-graph if present,
+// then falls back to the chain described by
+// info/commit-graphs/commit-graph-chain.
+// If neither exist, Open returns an error that wraps [fs.ErrNotExist].
+func Open(objectsDir string) (*Graph, error) {
+	data, err := os.ReadFile(filepath.Join(objectsDir, "info", "commit-graph"))
+	if err == nil {
+		g, err := Parse(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("open commit-graph: %w", err)
+		}
+		return g, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("open commit-graph: %w", err)
+	}
+	g, err := openChain(filepath.Join(objectsDir, "info", "commit-graphs"))
+	if err != nil {
+		return nil, fmt.Errorf("open commit-graph: %w", err)
+	}
+	return g, nil
+}
+
+func openChain(dir string) (*Graph, error) {
+	chain, err := os.ReadFile(filepath.Join(dir, "commit-graph-chain"))
+	if err != nil {
+		return nil, err
+	}
+	var g *Graph
+	s := bufio.NewScanner(bytes.NewReader(chain))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		hash, err := githash.ParseObjectID(line)
+		if err != nil {
+			return nil, fmt.Errorf("commit-graph-chain: %w", err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "graph-"+line+".graph"))
+		if err != nil {
+			return nil, err
+		}
+		layer, err := Parse(data, g)
+		if err != nil {
+			return nil, fmt.Errorf("graph-%s.graph: %w", line, err)
+		}
+		if layer.hash != hash {
+			return nil, fmt.Errorf("graph-%s.graph: checksum is %v", line, layer.hash)
+		}
+		g = layer
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, fmt.Errorf("commit-graph-chain: empty")
+	}
+	return g, nil
+}
+
+// Parse parses a single commit-graph file. If the file is a layer in a
+// split commit-graph chain, then base must be the graph for the layers below it.
+// The returned Graph refers to data, so data must not be modified afterward.
+func Parse(data []byte, base *Graph) (*Graph, error) {
+	g, err := parse(data, base)
+	if err != nil {
+		return nil, fmt.Errorf("parse commit-graph: %w", err)
+	}
+	return g, nil
+}
+
+func parse(data []byte, base *Graph) (*Graph, error) {
+	if len(data) < headerSize || string(data[:4]) != signature {
+		return nil, errors.New("not a commit-graph file")
+	}
+	if data[4] != version {
+		return nil, fmt.Errorf("unsupported version %d", data[4])
+	}
+	g := &Graph{base: base, genData: true}
+	switch data[5] {
+	case 1:
+		g.format = githash.SHA1Format
+	case 2:
+		g.format = githash.SHA256Format
+	default:
+		return nil, fmt.Errorf("unknown hash version %d", data[5])
+	}
+	hashSize := g.format.Size()
+	if len(data) < headerSize+hashSize {
+		return nil, errors.New("file too short")
+	}
+	h := g.format.New()
+	h.Write(data[:len(data)-hashSize])
+	if got := g.format.Sum(h); !bytes.Equal(got.Bytes(), data[len(data)-hashSize:]) {
+		return nil, errors.New("checksum does not match")
+	} else {
+		g.hash = got
+	}
+
+	numChunks := int(data[6])
+	numBaseFiles := int(data[7])
+	chunks, err := chunkfile.Read(data[:len(data)-hashSize], headerSize, numChunks)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the base layers.
+	baseHashes := chunks[chunkBase]
+	if len(baseHashes) != numBaseFiles*hashSize {
+		return nil, fmt.Errorf("%s chunk has wrong size for %d base layers", chunkBase, numBaseFiles)
+	}
+	var layers []*Graph
+	for b := base; b != nil; b = b.base {
+		layers = append(layers, b)
+	}
+	if len(layers) != numBaseFiles {
+		return nil, fmt.Errorf("file has %d base layers, but %d were provided", numBaseFiles, len(layers))
+	}
+	for i := 0; i < numBaseFiles; i++ {
+		// layers is ordered from the top down, but the BASE chunk is bottom up.
+		b := layers[len(layers)-1-i]
+		if b.format != g.format {
+			return nil, fmt.Errorf("base layer %d uses %v", i, b.format)
+		}
+		if !bytes.Equal(baseHashes[i*hashSize:(i+1)*hashSize], b.hash.Bytes()) {
+			return nil, fmt.Errorf("base layer %d is %v, but file expects %x", i, b.hash, baseHashes[i*hashSize:(i+1)*hashSize])
+		}
+	}
+	if base != nil {
+		g.numBase = base.numBase + base.n
+		g.genData = base.genData
+	}
+
+	g.fanOut = chunks[chunkOIDFanOut]
+	if len(g.fanOut) != fanOutSize {
+		return nil, fmt.Errorf("missing or invalid %s chunk", chunkOIDFanOut)
+	}
+	g.n = binary.BigEndian.Uint32(g.fanOut[fanOutSize-4:])
+	for i := 1; i < 256; i++ {
+		if binary.BigEndian.Uint32(g.fanOut[(i-1)*4:]) > binary.BigEndian.Uint32(g.fanOut[i*4:]) {
+			return nil, fmt.Errorf("%s chunk is not sorted", chunkOIDFanOut)
+		}
+	}
+	g.oids = chunks[chunkOIDLookup]
+	if uint64(len(g.oids)) != uint64(g.n)*uint64(hashSize) {
+		return nil, fmt.Errorf("missing or invalid %s chunk", chunkOIDLookup)
+	}
+	g.commitData = chunks[chunkCommitData]
+	if uint64(len(g.commitData)) != uint64(g.n)*uint64(hashSize+16) {
+		return nil, fmt.Errorf("missing or invalid %s chunk", chunkCommitData)
+	}
+	g.extraEdges = chunks[chunkExtraEdges]
+	if len(g.extraEdges)%4 != 0 {
+		return nil, fmt.Errorf("invalid %s chunk", chunkExtraEdges)
+	}
+	if gda, ok := chunks[chunkGenerationData]; ok {
+		if uint64(len(gda)) != uint64(g.n)*4 {
+			return nil, fmt.Errorf("invalid %s chunk", chunkGenerationData)
+		}
+		g.genDates = gda
+		g.genOffsets = chunks[chunkGenerationOffset]
+		if len(g.genOffsets)%8 != 0 {
+			return nil, fmt.Errorf("invalid %s chunk", chunkGenerationOffset)
+		}
+	} else {
+		g.genData = false
+	}
+
+	bidx, hasIndex := chunks[chunkBloomIndexes]
+	bdat, hasData := chunks[chunkBloomData]
+	if hasIndex && hasData {
+		// Git ignores invalid Bloom filter chunks, so do the same.
+		if uint64(len(bidx)) == uint64(g.n)*4 && len(bdat) >= bloomSettingsSize {
+			settings := &bloomSettings{
+				hashVersion:  binary.BigEndian.Uint32(bdat[0:]),
+				numHashes:    binary.BigEndian.Uint32(bdat[4:]),
+				bitsPerEntry: binary.BigEndian.Uint32(bdat[8:]),
+			}
+			if (settings.hashVersion == 1 || settings.hashVersion == 2) && settings.numHashes > 0 {
+				g.bloomIndex = bidx
+				g.bloomData = bdat[bloomSettingsSize:]
+				g.bloomConfig = settings
+			}
+		}
+	}
+	return g, nil
+}
+
+// Base returns the graph for the layers below g in a split commit-graph chain
+// or nil if g is the bottom layer.
+func (g *Graph) Base() *Graph {
+	return g.base
+}
+
+// Hash returns the checksum at the end of the graph's top layer file.
+// Files in a split commit-graph chain are named after their checksum.
+func (g *Graph) Hash() githash.ObjectID {
+	return g.hash
+}
+
+// ObjectFormat returns the object format of the graph.
+func (g *Graph) ObjectFormat() githash.ObjectFormat {
+	return g.format
+}
+
+// Len returns the number of commits in the graph, including its base layers.
+func (g *Graph) Len() int {
+	return int(g.numBase + g.n)
+}
+
+// Has reports whether the graph contains the commit with the given ID.
+func (g *Graph) Has(id githash.ObjectID) bool {
+	_, ok := g.position(id)
+	return ok
+}
+
+// Lookup returns the information stored about the commit with the given ID.
+// If the graph does not contain the commit, Lookup returns an error
+// that wraps [fs.ErrNotExist].
+func (g *Graph) Lookup(id githash.ObjectID) (*Commit, error) {
+	pos, ok := g.position(id)
+	if !ok {
+		return nil, fmt.Errorf("commit-graph: lookup %v: %w", id, fs.ErrNotExist)
+	}
+	c, err := g.commitAt(pos)
+	if err != nil {
+		return nil, fmt.Errorf("commit-graph: lookup %v: %w", id, err)
+	}
+	return c, nil
+}
+
+// CommitGeneration returns the generation number of the commit
+// with the given ID. It returns the commit's corrected commit date
+// if every layer of the graph stores them, and the commit's topological
+// level otherwise. CommitGeneration implements [revwalk.GenerationReader].
+//
+// [revwalk.GenerationReader]: https://pkg.go.dev/gg-scm.io/pkg/git/revwalk#GenerationReader
+func (g *Graph) CommitGeneration(id githash.ObjectID) (gen uint64, ok bool) {
+	pos, ok := g.position(id)
+	if !ok {
+		return 0, false
+	}
+	layer, i := g.layer(pos)
+	if g.genData {
+		date, err := layer.correctedCommitDate(i)
+		if err != nil {
+			return 0, false
+		}
+		return uint64(date), true
+	}
+	_, level := layer.timeAndLevel(i)
+	return uint64(level), true
+}
+
+// MaybeChangedPath reports whether the commit with the given ID may have
+// changed the file or directory at path relative to its first parent,
+// according to the commit's changed-path Bloom filter.
+// A false result means that the commit definitely did not change the path.
+// MaybeChangedPath returns true if the commit does not have a Bloom filter.
+func (g *Graph) MaybeChangedPath(id githash.ObjectID, path string) bool {
+	pos, ok := g.position(id)
+	if !ok {
+		return true
+	}
+	layer, i := g.layer(pos)
+	filter, ok := layer.bloomFilter(i)
+	if !ok {
+		return true
+	}
+	return layer.bloomConfig.filterContains(filter, strings.Trim(path, "/"))
+}
+
+// HasChangedPaths reports whether the graph's top layer
+// stores changed-path Bloom filters.
+func (g *Graph) HasChangedPaths() bool {
+	return g.bloomConfig != nil
+}
+
+// position returns the position of the commit in the chain.
+func (g *Graph) position(id githash.ObjectID) (uint32, bool) {
+	if id.ObjectFormat() != g.format {
+		return 0, false
+	}
+	idBytes := id.Bytes()
+	for layer := g; layer != nil; layer = layer.base {
+		if i, ok := layer.find(idBytes); ok {
+			return layer.numBase + i, true
+		}
+	}
+	return 0, false
+}
+
+// find searches for the object ID in the layer's OIDL chunk.
+func (g *Graph) find(id []byte) (uint32, bool) {
+	hashSize := g.format.Size()
+	lo := uint32(0)
+	if id[0] > 0 {
+		lo = binary.BigEndian.Uint32(g.fanOut[(int(id[0])-1)*4:])
+	}
+	hi := binary.BigEndian.Uint32(g.fanOut[int(id[0])*4:])
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		switch bytes.Compare(g.oids[int(mid)*hashSize:int(mid+1)*hashSize], id) {
+		case 0:
+			return mid, true
+		case -1:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, false
+}
+
+// layer returns the layer that contains the commit at the given position
+// in the chain and the commit's index within the layer.
+func (g *Graph) layer(pos uint32) (*Graph, uint32) {
+	layer := g
+	for layer.base != nil && pos < layer.numBase {
+		layer = layer.base
+	}
+	return layer, pos - layer.numBase
+}
+
+func (g *Graph) idAt(pos uint32) (githash.ObjectID, error) {
+	if pos >= g.numBase+g.n {
+		return githash.ObjectID{}, fmt.Errorf("commit position %d out of range", pos)
+	}
+	layer, i := g.layer(pos)
+	return layer.oidAt(i), nil
+}
+
+func (g *Graph) oidAt(i uint32) githash.ObjectID {
+	hashSize := g.format.Size()
+	var id githash.ObjectID
+	id.UnmarshalBinary(g.oids[int(i)*hashSize : int(i+1)*hashSize])
+	return id
+}
+
+// commitDataAt returns the CDAT entry for the i'th commit in the layer.
+func (g *Graph) commitDataAt(i uint32) []byte {
+	size := g.format.Size() + 16
+	return g.commitData[int(i)*size : int(i+1)*size]
+}
+
+func (g *Graph) timeAndLevel(i uint32) (commitTime int64, level uint32) {
+	cd := g.commitDataAt(i)
+	tail := cd[len(cd)-8:]
+	hi := binary.BigEndian.Uint32(tail)
+	lo := binary.BigEndian.Uint32(tail[4:])
+	return int64(hi&3)<<32 | int64(lo), hi >> 2
+}
+
+func (g *Graph) correctedCommitDate(i uint32) (int64, error) {
+	if g.genDates == nil {
+		return 0, nil
+	}
+	commitTime, _ := g.timeAndLevel(i)
+	offset := uint64(binary.BigEndian.Uint32(g.genDates[i*4:]))
+	if offset&generationOffsetOverflow != 0 {
+		j := int(offset &^ generationOffsetOverflow)
+		if (j+1)*8 > len(g.genOffsets) {
+			return 0, fmt.Errorf("%s index %d out of range", chunkGenerationOffset, j)
+		}
+		offset = binary.BigEndian.Uint64(g.genOffsets[j*8:])
+	}
+	return commitTime + int64(offset), nil
+}
+
+// commitAt returns the commit at the given position in the chain.
+func (g *Graph) commitAt(pos uint32) (*Commit, error) {
+	layer, i := g.layer(pos)
+	hashSize := g.format.Size()
+	cd := layer.commitDataAt(i)
+	c := &Commit{ID: layer.oidAt(i)}
+	c.Tree.UnmarshalBinary(cd[:hashSize])
+	c.CommitTime, c.Generation = layer.timeAndLevel(i)
+	if g.genData {
+		var err error
+		c.CorrectedCommitDate, err = layer.correctedCommitDate(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	parent1 := binary.BigEndian.Uint32(cd[hashSize:])
+	parent2 := binary.BigEndian.Uint32(cd[hashSize+4:])
+	if parent1 == parentNone {
+		return c, nil
+	}
+	id, err := g.idAt(parent1)
+	if err != nil {
+		return nil, err
+	}
+	c.Parents = append(c.Parents, id)
+	switch {
+	case parent2 == parentNone:
+	case parent2&parentExtraEdges == 0:
+		id, err := g.idAt(parent2)
+		if err != nil {
+			return nil, err
+		}
+		c.Parents = append(c.Parents, id)
+	default:
+		for j := int(parent2 &^ parentExtraEdges); ; j++ {
+			if (j+1)*4 > len(layer.extraEdges) {
+				return nil, fmt.Errorf("%s index %d out of range", chunkExtraEdges, j)
+			}
+			edge := binary.BigEndian.Uint32(layer.extraEdges[j*4:])
+			id, err := g.idAt(edge &^ lastEdge)
+			if err != nil {
+				return nil, err
+			}
+			c.Parents = append(c.Parents, id)
+			if edge&lastEdge != 0 {
+				break
+			}
+		}
+	}
+	return c, nil
+}
+
+// bloomFilter returns the changed-path Bloom filter
+// for the i'th commit in the layer.
+func (g *Graph) bloomFilter(i uint32) ([]byte, bool) {
+	if g.bloomConfig == nil {
+		return nil, false
+	}
+	end := binary.BigEndian.Uint32(g.bloomIndex[i*4:])
+	start := uint32(0)
+	if i > 0 {
+		start = binary.BigEndian.Uint32(g.bloomIndex[(i-1)*4:])
+	}
+	if start > end || uint64(end) > uint64(len(g.bloomData)) {
+		return nil, false
+	}
+	return g.bloomData[start:end], true
+}
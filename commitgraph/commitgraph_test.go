@@ -0,0 +1,517 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package commitgraph
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/object"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestMurmur3(t *testing.T) {
+	// Test vectors from Git's t/t0095-bloom.sh.
+	tests := []struct {
+		seed uint32
+		data string
+		want uint32
+	}{
+		{0, "", 0x00000000},
+		{0, "Hello world!", 0x627b0c2c},
+		{0, "The quick brown fox jumps over the lazy dog", 0x2e4ff723},
+	}
+	for _, test := range tests {
+		for _, signed := range []bool{false, true} {
+			if got := murmur3(test.seed, test.data, signed); got != test.want {
+				t.Errorf("murmur3(%#x, %q, %t) = %#08x; want %#08x", test.seed, test.data, signed, got, test.want)
+			}
+		}
+	}
+
+	// Bytes with the high bit set hash differently
+	// in version 1 filters.
+	const highBits = "\x99\xaa\xbb\xcc\xdd\xee\xff"
+	if murmur3(0, highBits, false) == murmur3(0, highBits, true) {
+		t.Errorf("murmur3(0, %q) is the same for signed and unsigned bytes", highBits)
+	}
+}
+
+// testRepo is a bare Git repository that tests add commits to.
+type testRepo struct {
+	tb      testing.TB
+	git     *git.Git
+	repo    *gitrepo.Repository
+	commits map[githash.ObjectID]*object.Commit
+	names   map[string]githash.ObjectID
+	time    time.Time
+}
+
+func newTestRepo(tb testing.TB) *testRepo {
+	tb.Helper()
+	localGit, err := git.NewLocal(git.Options{
+		Env: []string{"GIT_CONFIG_NOSYSTEM=1"},
+	})
+	if err != nil {
+		tb.Skip("Can't find Git, skipping:", err)
+	}
+	dir := tb.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	if err := g.Run(context.Background(), "init", "--quiet", "--bare", "."); err != nil {
+		tb.Fatal(err)
+	}
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { repo.Close() })
+	return &testRepo{
+		tb:      tb,
+		git:     g,
+		repo:    repo,
+		commits: make(map[githash.ObjectID]*object.Commit),
+		names:   make(map[string]githash.ObjectID),
+		time:    time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (r *testRepo) objectsDir() string {
+	return filepath.Join(r.repo.Dir(), "objects")
+}
+
+// commit writes a commit named name whose tree is the first parent's tree
+// with the given files written. A file with empty content is deleted.
+// The commit is one minute newer than the previous one,
+// unless commitTime is not zero.
+func (r *testRepo) commit(name string, commitTime time.Time, files map[string]string, parents ...string) githash.ObjectID {
+	r.tb.Helper()
+	var baseTree githash.ObjectID
+	if len(parents) > 0 {
+		baseTree = r.commits[r.names[parents[0]]].Tree
+	}
+	var edits []object.TreeEdit
+	for path, content := range files {
+		if content == "" {
+			edits = append(edits, object.TreeEdit{Path: path, Delete: true})
+			continue
+		}
+		blob, err := r.repo.WriteObject(object.TypeBlob, []byte(content))
+		if err != nil {
+			r.tb.Fatal(err)
+		}
+		edits = append(edits, object.TreeEdit{Path: path, Mode: object.ModePlain, ObjectID: blob})
+	}
+	tree := baseTree
+	if len(edits) > 0 || baseTree.IsZero() {
+		result, err := object.EditTree(r.repo, baseTree, edits)
+		if err != nil {
+			r.tb.Fatal(err)
+		}
+		if result.Root.IsZero() {
+			result.Root, err = r.repo.WriteTree(object.Tree{})
+			if err != nil {
+				r.tb.Fatal(err)
+			}
+		}
+		for _, t := range result.Trees {
+			if _, err := r.repo.WriteTree(t); err != nil {
+				r.tb.Fatal(err)
+			}
+		}
+		tree = result.Root
+	}
+	if commitTime.IsZero() {
+		commitTime = r.time
+		r.time = r.time.Add(time.Minute)
+	}
+	c := &object.Commit{
+		Tree:       tree,
+		Author:     "Octocat <octocat@example.com>",
+		AuthorTime: commitTime,
+		Committer:  "Octocat <octocat@example.com>",
+		CommitTime: commitTime,
+		Message:    name + "\n",
+	}
+	for _, p := range parents {
+		c.Parents = append(c.Parents, r.names[p])
+	}
+	id, err := r.repo.WriteCommit(c)
+	if err != nil {
+		r.tb.Fatal(err)
+	}
+	r.commits[id] = c
+	r.names[name] = id
+	if err := r.git.Run(context.Background(), "update-ref", "refs/heads/"+name, id.String()); err != nil {
+		r.tb.Fatal(err)
+	}
+	return id
+}
+
+func (r *testRepo) allCommits() []*object.Commit {
+	commits := make([]*object.Commit, 0, len(r.commits))
+	for _, c := range r.commits {
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+// levels returns the topological levels of the repository's commits.
+func (r *testRepo) levels() map[githash.ObjectID]uint32 {
+	levels := make(map[githash.ObjectID]uint32)
+	var level func(id githash.ObjectID) uint32
+	level = func(id githash.ObjectID) uint32 {
+		if l, ok := levels[id]; ok {
+			return l
+		}
+		l := uint32(1)
+		for _, p := range r.commits[id].Parents {
+			if pl := level(p) + 1; pl > l {
+				l = pl
+			}
+		}
+		levels[id] = l
+		return l
+	}
+	for id := range r.commits {
+		level(id)
+	}
+	return levels
+}
+
+// verify runs `git commit-graph verify`.
+func (r *testRepo) verify() {
+	r.tb.Helper()
+	out, err := r.git.Output(context.Background(), "commit-graph", "verify")
+	if err != nil {
+		r.tb.Fatalf("git commit-graph verify: %v\n%s", err, out)
+	}
+}
+
+// newTestHistory adds commits that exercise octopus merges,
+// clock skew, and changed-path Bloom filters of various sizes.
+func newTestHistory(r *testRepo) {
+	r.commit("root", time.Time{}, map[string]string{
+		"a.txt":             "a\n",
+		"dir/b.txt":         "b\n",
+		"dir/sub/c.txt":     "c\n",
+		"héllo/wörld.txt":   "unicode\n",
+		"\x99\xaa/\xbb\xcc": "high bits\n",
+	})
+	r.commit("modify", time.Time{}, map[string]string{"a.txt": "a2\n"}, "root")
+	r.commit("add", time.Time{}, map[string]string{"dir/new.txt": "new\n"}, "root")
+	r.commit("delete", time.Time{}, map[string]string{"dir/sub/c.txt": ""}, "modify")
+	r.commit("octopus", time.Time{}, map[string]string{"héllo/wörld.txt": "merged\n"}, "modify", "add", "delete")
+	r.commit("empty", time.Time{}, nil, "octopus")
+	r.commit("merge", time.Time{}, nil, "empty", "add")
+
+	many := make(map[string]string)
+	for i := 0; i < 300; i++ {
+		many[fmt.Sprintf("many/%d/file.txt", i)] = fmt.Sprintf("%d\n", i)
+	}
+	r.commit("many", time.Time{}, many, "merge")
+
+	// Commits with times far in the future or past
+	// stress the corrected commit date offsets.
+	r.commit("future", time.Unix(5_000_000_000, 0), map[string]string{"future.txt": "future\n"}, "many")
+	r.commit("past", time.Unix(1_000_000, 0), map[string]string{"past.txt": "past\n"}, "future")
+	r.commit("otherroot", time.Time{}, map[string]string{"other.txt": "other\n"})
+}
+
+func TestReadGitGraph(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	newTestHistory(r)
+	if err := r.git.Run(ctx, "commit-graph", "write", "--reachable", "--changed-paths"); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := Open(r.objectsDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Base() != nil {
+		t.Error("Base() != nil for single file")
+	}
+	if got, want := g.Len(), len(r.commits); got != want {
+		t.Errorf("Len() = %d; want %d", got, want)
+	}
+	if !g.HasChangedPaths() {
+		t.Error("HasChangedPaths() = false")
+	}
+	checkCommits(t, r, g)
+
+	// Bloom filters should be exactly what Git computes.
+	settings := &bloomSettings{
+		hashVersion:  bloomHashVersion,
+		numHashes:    bloomNumHashes,
+		bitsPerEntry: bloomBitsPerEntry,
+	}
+	if diff := cmp.Diff(settings, g.bloomConfig, cmp.AllowUnexported(bloomSettings{})); diff != "" {
+		t.Errorf("Bloom filter settings (-want +got):\n%s", diff)
+	}
+	for name, id := range r.names {
+		pos, ok := g.position(id)
+		if !ok {
+			continue
+		}
+		got, ok := g.bloomFilter(pos)
+		if !ok {
+			t.Errorf("%s: no Bloom filter", name)
+			continue
+		}
+		c := r.commits[id]
+		var parentTree object.Tree
+		if len(c.Parents) > 0 {
+			parentTree, err = r.repo.ReadTree(r.commits[c.Parents[0]].Tree)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		tree, err := r.repo.ReadTree(c.Tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes, err := object.DiffTrees(r.repo, parentTree, tree, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := settings.buildFilter(changes); !bytes.Equal(got, want) {
+			t.Errorf("%s: Bloom filter = %x; want %x", name, got, want)
+		}
+	}
+
+	tests := []struct {
+		commit string
+		path   string
+		want   bool
+	}{
+		{"modify", "a.txt", true},
+		{"modify", "dir", false},
+		{"delete", "dir/sub/c.txt", true},
+		{"delete", "dir/sub", true},
+		{"delete", "dir", true},
+		{"delete", "dir/", true},
+		{"delete", "a.txt", false},
+		{"octopus", "héllo/wörld.txt", true},
+		{"octopus", "héllo", true},
+		{"octopus", "dir/b.txt", false},
+		{"root", "\x99\xaa/\xbb\xcc", true},
+		{"empty", "a.txt", false},
+		{"many", "a.txt", true},
+	}
+	for _, test := range tests {
+		if got := g.MaybeChangedPath(r.names[test.commit], test.path); got != test.want {
+			t.Errorf("MaybeChangedPath(%s, %q) = %t; want %t", test.commit, test.path, got, test.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	newTestHistory(r)
+	if err := r.git.Run(ctx, "commit-graph", "write", "--reachable", "--changed-paths"); err != nil {
+		t.Fatal(err)
+	}
+	graphPath := filepath.Join(r.objectsDir(), "info", "commit-graph")
+	want, err := os.ReadFile(graphPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	sum, err := Write(buf, r.allCommits(), &WriteOptions{ChangedPaths: r.repo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Error("Written commit-graph does not match the one written by Git")
+	}
+	if !bytes.Equal(sum.Bytes(), buf.Bytes()[buf.Len()-sum.ObjectFormat().Size():]) {
+		t.Errorf("Write returned %v, which is not the file's checksum", sum)
+	}
+
+	if err := os.WriteFile(graphPath, buf.Bytes(), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	r.verify()
+	g, err := Open(r.objectsDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Hash() != sum {
+		t.Errorf("Hash() = %v; want %v", g.Hash(), sum)
+	}
+	checkCommits(t, r, g)
+}
+
+func TestWriteMissingParent(t *testing.T) {
+	r := newTestRepo(t)
+	newTestHistory(r)
+	commits := []*object.Commit{r.commits[r.names["modify"]]}
+	if _, err := Write(new(bytes.Buffer), commits, nil); err == nil {
+		t.Error("Write did not return an error")
+	}
+}
+
+func TestSplitChain(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	r.commit("A", time.Time{}, map[string]string{"a.txt": "a\n"})
+	r.commit("B", time.Time{}, map[string]string{"b.txt": "b\n"}, "A")
+	if err := r.git.Run(ctx, "commit-graph", "write", "--reachable", "--split=no-merge"); err != nil {
+		t.Fatal(err)
+	}
+	r.commit("C", time.Time{}, map[string]string{"c.txt": "c\n"}, "B")
+	r.commit("D", time.Time{}, map[string]string{"d.txt": "d\n"}, "A")
+	r.commit("E", time.Time{}, map[string]string{"e.txt": "e\n"}, "C", "D", "A")
+	if err := r.git.Run(ctx, "commit-graph", "write", "--reachable", "--split=no-merge"); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := Open(r.objectsDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Base() == nil || g.Base().Base() != nil {
+		t.Fatal("Open did not return a graph with two layers")
+	}
+	if got, want := g.Len(), len(r.commits); got != want {
+		t.Errorf("Len() = %d; want %d", got, want)
+	}
+	checkCommits(t, r, g)
+
+	// Add a layer written in Go.
+	r.commit("F", time.Time{}, map[string]string{"f.txt": "f\n"}, "E", "B")
+	r.commit("G", time.Time{}, map[string]string{"dir/g.txt": "g\n"}, "F")
+	buf := new(bytes.Buffer)
+	sum, err := Write(buf, r.allCommits(), &WriteOptions{
+		Base:         g,
+		ChangedPaths: r.repo,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainDir := filepath.Join(r.objectsDir(), "info", "commit-graphs")
+	if err := os.WriteFile(filepath.Join(chainDir, "graph-"+sum.String()+".graph"), buf.Bytes(), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := os.ReadFile(filepath.Join(chainDir, "commit-graph-chain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain = append(chain, sum.String()+"\n"...)
+	if err := os.WriteFile(filepath.Join(chainDir, "commit-graph-chain"), chain, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	r.verify()
+
+	g, err = Open(r.objectsDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.Len(), len(r.commits); got != want {
+		t.Errorf("Len() = %d; want %d", got, want)
+	}
+	if g.Hash() != sum {
+		t.Errorf("Hash() = %v; want %v", g.Hash(), sum)
+	}
+	checkCommits(t, r, g)
+	if g.MaybeChangedPath(r.names["G"], "f.txt") {
+		t.Error("MaybeChangedPath(G, \"f.txt\") = true; want false")
+	}
+	if !g.MaybeChangedPath(r.names["G"], "dir") {
+		t.Error("MaybeChangedPath(G, \"dir\") = false; want true")
+	}
+}
+
+func TestOpenMissing(t *testing.T) {
+	_, err := Open(t.TempDir())
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open(empty) = _, %v; want %v", err, fs.ErrNotExist)
+	}
+}
+
+// checkCommits verifies that every commit in r is stored correctly in g.
+func checkCommits(t *testing.T, r *testRepo, g *Graph) {
+	t.Helper()
+	levels := r.levels()
+	corrected := make(map[githash.ObjectID]int64)
+	var correctedDate func(id githash.ObjectID) int64
+	correctedDate = func(id githash.ObjectID) int64 {
+		if d, ok := corrected[id]; ok {
+			return d
+		}
+		c := r.commits[id]
+		d := c.CommitTime.Unix()
+		for _, p := range c.Parents {
+			if pd := correctedDate(p) + 1; pd > d {
+				d = pd
+			}
+		}
+		corrected[id] = d
+		return d
+	}
+
+	for name, id := range r.names {
+		c := r.commits[id]
+		want := &Commit{
+			ID:                  id,
+			Tree:                c.Tree,
+			Parents:             c.Parents,
+			CommitTime:          c.CommitTime.Unix(),
+			Generation:          levels[id],
+			CorrectedCommitDate: correctedDate(id),
+		}
+		got, err := g.Lookup(id)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("Lookup(%s) (-want +got):\n%s", name, diff)
+		}
+		if gen, ok := g.CommitGeneration(id); !ok || gen != uint64(want.CorrectedCommitDate) {
+			t.Errorf("CommitGeneration(%s) = %d, %t; want %d, true", name, gen, ok, want.CorrectedCommitDate)
+		}
+		if !g.Has(id) {
+			t.Errorf("Has(%s) = false", name)
+		}
+	}
+
+	missing := githash.SHA1{0xde, 0xad}.ObjectID()
+	if _, err := g.Lookup(missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Lookup(missing) = _, %v; want %v", err, fs.ErrNotExist)
+	}
+	if _, ok := g.CommitGeneration(missing); ok {
+		t.Error("CommitGeneration(missing) returned ok")
+	}
+	if !g.MaybeChangedPath(missing, "a.txt") {
+		t.Error("MaybeChangedPath(missing, ...) = false")
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package commitgraph
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/internal/chunkfile"
+	"gg-scm.io/pkg/git/object"
+)
+
+// WriteOptions specifies optional parameters to Write.
+type WriteOptions struct {
+	// Base is the graph that the written file is layered on top of
+	// in a split commit-graph chain. Commits that are already in Base
+	// are not written. If Base is nil, a standalone file is written.
+	//
+	// To add a layer to a chain, write the file to
+	// info/commit-graphs/graph-{hash}.graph (where hash is the checksum
+	// returned by Write) and append the hash to
+	// info/commit-graphs/commit-graph-chain.
+	Base *Graph
+
+	// ObjectFormat is the hash algorithm used by the commits.
+	// It must match Base's object format if Base is not nil.
+	ObjectFormat githash.ObjectFormat
+
+	// ChangedPaths is used to read trees to compute
+	// changed-path Bloom filters. If nil, no Bloom filters are written.
+	ChangedPaths object.TreeReader
+}
+
+// commitInfo is a commit being written.
+type commitInfo struct {
+	id        githash.ObjectID
+	c         *object.Commit
+	time      int64
+	level     uint32
+	corrected int64
+	parents   []uint32
+	done      bool
+}
+
+// Write writes a commit-graph file containing the given commits to w
+// and returns the file's checksum. Every parent of the commits must either
+// be in commits or in opts.Base.
+func Write(w io.Writer, commits []*object.Commit, opts *WriteOptions) (githash.ObjectID, error) {
+	if opts == nil {
+		opts = new(WriteOptions)
+	}
+	sum, err := write(w, commits, opts.ObjectFormat, opts)
+	if err != nil {
+		return githash.ObjectID{}, fmt.Errorf("write commit-graph: %w", err)
+	}
+	return sum, nil
+}
+
+func write(w io.Writer, commits []*object.Commit, format githash.ObjectFormat, opts *WriteOptions) (githash.ObjectID, error) {
+	var hashVersion byte
+	switch format {
+	case githash.SHA1Format:
+		hashVersion = 1
+	case githash.SHA256Format:
+		hashVersion = 2
+	default:
+		return githash.ObjectID{}, fmt.Errorf("unsupported object format %v", format)
+	}
+	base := opts.Base
+	if base != nil && base.format != format {
+		return githash.ObjectID{}, fmt.Errorf("base uses %v instead of %v", base.format, format)
+	}
+
+	// Sort the commits by ID.
+	infos := make([]*commitInfo, 0, len(commits))
+	byID := make(map[githash.ObjectID]*commitInfo, len(commits))
+	for _, c := range commits {
+		id := c.Sum(format)
+		if byID[id] != nil || (base != nil && base.Has(id)) {
+			continue
+		}
+		info := &commitInfo{id: id, c: c, time: c.CommitTime.Unix()}
+		if info.time < 0 {
+			info.time = 0
+		} else if info.time > commitTimeMax {
+			info.time = commitTimeMax
+		}
+		infos = append(infos, info)
+		byID[id] = info
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].id.Compare(infos[j].id) < 0
+	})
+	if uint64(len(infos)) >= parentNone {
+		return githash.ObjectID{}, fmt.Errorf("too many commits")
+	}
+	var numBase uint32
+	if base != nil {
+		numBase = base.numBase + base.n
+	}
+	positions := make(map[githash.ObjectID]uint32, len(infos))
+	for i, info := range infos {
+		positions[info.id] = numBase + uint32(i)
+	}
+	for _, info := range infos {
+		for _, p := range info.c.Parents {
+			pos, ok := positions[p]
+			if !ok && base != nil {
+				pos, ok = base.position(p)
+			}
+			if !ok {
+				return githash.ObjectID{}, fmt.Errorf("commit %v: parent %v not in graph", info.id, p)
+			}
+			info.parents = append(info.parents, pos)
+		}
+	}
+
+	// Corrected commit dates are only meaningful
+	// if every layer of the chain stores them.
+	writeGenData := base == nil || base.genData
+	if err := computeGenerations(infos, base, numBase, writeGenData); err != nil {
+		return githash.ObjectID{}, err
+	}
+
+	// Build chunks.
+	hashSize := format.Size()
+	fanOut := make([]byte, 0, fanOutSize)
+	oids := make([]byte, 0, len(infos)*hashSize)
+	commitData := make([]byte, 0, len(infos)*(hashSize+16))
+	var genDates, genOffsets, extraEdges []byte
+	for i, info := range infos {
+		oids = append(oids, info.id.Bytes()...)
+		commitData = append(commitData, info.c.Tree.Bytes()...)
+		parent1, parent2 := uint32(parentNone), uint32(parentNone)
+		switch len(info.parents) {
+		case 0:
+		case 1:
+			parent1 = info.parents[0]
+		case 2:
+			parent1, parent2 = info.parents[0], info.parents[1]
+		default:
+			parent1 = info.parents[0]
+			parent2 = parentExtraEdges | uint32(len(extraEdges)/4)
+			for j, p := range info.parents[1:] {
+				if j == len(info.parents)-2 {
+					p |= lastEdge
+				}
+				extraEdges = binary.BigEndian.AppendUint32(extraEdges, p)
+			}
+		}
+		commitData = binary.BigEndian.AppendUint32(commitData, parent1)
+		commitData = binary.BigEndian.AppendUint32(commitData, parent2)
+		commitData = binary.BigEndian.AppendUint32(commitData, info.level<<2|uint32(info.time>>32))
+		commitData = binary.BigEndian.AppendUint32(commitData, uint32(info.time))
+
+		if writeGenData {
+			offset := uint64(info.corrected - info.time)
+			if offset > generationV2OffsetMax {
+				genDates = binary.BigEndian.AppendUint32(genDates, generationOffsetOverflow|uint32(len(genOffsets)/8))
+				genOffsets = binary.BigEndian.AppendUint64(genOffsets, offset)
+			} else {
+				genDates = binary.BigEndian.AppendUint32(genDates, uint32(offset))
+			}
+		}
+
+		// Fill in fan-out entries up to and including this commit's first byte.
+		for first := int(info.id.Bytes()[0]); len(fanOut)/4 <= first; {
+			fanOut = binary.BigEndian.AppendUint32(fanOut, uint32(i))
+		}
+		binary.BigEndian.PutUint32(fanOut[len(fanOut)-4:], uint32(i+1))
+	}
+	for len(fanOut) < fanOutSize {
+		fanOut = binary.BigEndian.AppendUint32(fanOut, uint32(len(infos)))
+	}
+
+	chunks := []chunkfile.Chunk{
+		{ID: chunkOIDFanOut, Data: fanOut},
+		{ID: chunkOIDLookup, Data: oids},
+		{ID: chunkCommitData, Data: commitData},
+	}
+	if writeGenData {
+		chunks = append(chunks, chunkfile.Chunk{ID: chunkGenerationData, Data: genDates})
+		if len(genOffsets) > 0 {
+			chunks = append(chunks, chunkfile.Chunk{ID: chunkGenerationOffset, Data: genOffsets})
+		}
+	}
+	if len(extraEdges) > 0 {
+		chunks = append(chunks, chunkfile.Chunk{ID: chunkExtraEdges, Data: extraEdges})
+	}
+	if opts.ChangedPaths != nil {
+		bidx, bdat, err := buildBloomChunks(opts.ChangedPaths, infos, byID, base)
+		if err != nil {
+			return githash.ObjectID{}, err
+		}
+		chunks = append(chunks,
+			chunkfile.Chunk{ID: chunkBloomIndexes, Data: bidx},
+			chunkfile.Chunk{ID: chunkBloomData, Data: bdat},
+		)
+	}
+	var numBaseFiles int
+	if base != nil {
+		var baseHashes [][]byte
+		for b := base; b != nil; b = b.base {
+			baseHashes = append(baseHashes, b.hash.Bytes())
+		}
+		numBaseFiles = len(baseHashes)
+		var data []byte
+		for i := len(baseHashes) - 1; i >= 0; i-- {
+			data = append(data, baseHashes[i]...)
+		}
+		chunks = append(chunks, chunkfile.Chunk{ID: chunkBase, Data: data})
+	}
+	if len(chunks) > 0xff || numBaseFiles > 0xff {
+		return githash.ObjectID{}, fmt.Errorf("too many chunks or base layers")
+	}
+
+	h := format.New()
+	hw := io.MultiWriter(w, h)
+	header := []byte{signature[0], signature[1], signature[2], signature[3], version, hashVersion, byte(len(chunks)), byte(numBaseFiles)}
+	if _, err := hw.Write(header); err != nil {
+		return githash.ObjectID{}, err
+	}
+	if err := chunkfile.Write(hw, headerSize, chunks); err != nil {
+		return githash.ObjectID{}, err
+	}
+	sum := format.Sum(h)
+	if _, err := w.Write(sum.Bytes()); err != nil {
+		return githash.ObjectID{}, err
+	}
+	return sum, nil
+}
+
+// computeGenerations fills in the topological levels and
+// corrected commit dates of the commits being written.
+func computeGenerations(infos []*commitInfo, base *Graph, numBase uint32, genData bool) error {
+	// Walk depth-first with an explicit stack,
+	// since histories can be deeper than the goroutine stack allows.
+	var stack []*commitInfo
+	for _, root := range infos {
+		if root.done {
+			continue
+		}
+		stack = append(stack[:0], root)
+		for len(stack) > 0 {
+			info := stack[len(stack)-1]
+			level := uint32(0)
+			corrected := int64(0)
+			pending := false
+			for _, pos := range info.parents {
+				var plevel uint32
+				var pcorrected int64
+				if pos >= numBase {
+					p := infos[pos-numBase]
+					if !p.done {
+						stack = append(stack, p)
+						pending = true
+						continue
+					}
+					plevel, pcorrected = p.level, p.corrected
+				} else {
+					layer, i := base.layer(pos)
+					_, plevel = layer.timeAndLevel(i)
+					if genData {
+						var err error
+						pcorrected, err = layer.correctedCommitDate(i)
+						if err != nil {
+							return err
+						}
+					}
+				}
+				if plevel > level {
+					level = plevel
+				}
+				if pcorrected > corrected {
+					corrected = pcorrected
+				}
+			}
+			if pending {
+				continue
+			}
+			stack = stack[:len(stack)-1]
+			info.level = level + 1
+			if info.level > generationV1Max {
+				info.level = generationV1Max
+			}
+			info.corrected = info.time
+			if len(info.parents) > 0 && corrected+1 > info.corrected {
+				info.corrected = corrected + 1
+			}
+			info.done = true
+		}
+	}
+	return nil
+}
+
+// buildBloomChunks computes the BIDX and BDAT chunks for the commits.
+func buildBloomChunks(r object.TreeReader, infos []*commitInfo, byID map[githash.ObjectID]*commitInfo, base *Graph) (bidx, bdat []byte, err error) {
+	settings := &bloomSettings{
+		hashVersion:  bloomHashVersion,
+		numHashes:    bloomNumHashes,
+		bitsPerEntry: bloomBitsPerEntry,
+	}
+	dr := treeOnlyReader{r}
+	bdat = settings.appendBinary(nil)
+	for _, info := range infos {
+		tree, err := r.ReadTree(info.c.Tree)
+		if err != nil {
+			return nil, nil, fmt.Errorf("commit %v: %w", info.id, err)
+		}
+		var parentTree object.Tree
+		if len(info.c.Parents) > 0 {
+			p := info.c.Parents[0]
+			var parentTreeID githash.ObjectID
+			if pinfo := byID[p]; pinfo != nil {
+				parentTreeID = pinfo.c.Tree
+			} else {
+				pc, err := base.Lookup(p)
+				if err != nil {
+					return nil, nil, err
+				}
+				parentTreeID = pc.Tree
+			}
+			parentTree, err = r.ReadTree(parentTreeID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("commit %v: %w", info.id, err)
+			}
+		}
+		changes, err := object.DiffTrees(dr, parentTree, tree, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("commit %v: %w", info.id, err)
+		}
+		bdat = append(bdat, settings.buildFilter(changes)...)
+		bidx = binary.BigEndian.AppendUint32(bidx, uint32(len(bdat)-bloomSettingsSize))
+	}
+	return bidx, bdat, nil
+}
+
+// treeOnlyReader adapts an object.TreeReader to an object.ObjectReader
+// for diffs that do not need to read blobs.
+type treeOnlyReader struct {
+	object.TreeReader
+}
+
+func (treeOnlyReader) ReadBlob(id githash.ObjectID) ([]byte, error) {
+	return nil, fmt.Errorf("read blob %v: not supported", id)
+}
@@ -20,6 +20,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+
+	"gg-scm.io/pkg/git/githash"
 )
 
 // Config is a collection of configuration settings.
@@ -106,6 +108,32 @@ func (cfg *Config) CommentChar() (string, error) {
 	return v, nil
 }
 
+// ObjectFormat returns the hash algorithm that the repository uses to name
+// its objects, as given by the `extensions.objectFormat` setting.
+// Repositories without the setting use SHA-1.
+func (cfg *Config) ObjectFormat() (githash.ObjectFormat, error) {
+	v := cfg.Value("extensions.objectFormat")
+	if v == "" {
+		return githash.SHA1Format, nil
+	}
+	format, err := githash.ParseObjectFormat(v)
+	if err != nil {
+		return 0, fmt.Errorf("git config: extensions.objectFormat: %w", err)
+	}
+	return format, nil
+}
+
+// ObjectFormat returns the hash algorithm that the repository uses to name
+// its objects. It is a shorthand for calling [Config.ObjectFormat]
+// on the result of [*Git.ReadConfig].
+func (g *Git) ObjectFormat(ctx context.Context) (githash.ObjectFormat, error) {
+	cfg, err := g.ReadConfig(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return cfg.ObjectFormat()
+}
+
 // Value returns the string value of the configuration setting with the
 // given name.
 func (cfg *Config) Value(name string) string {
@@ -19,6 +19,7 @@ import (
 	"strings"
 	"testing"
 
+	"gg-scm.io/pkg/git/githash"
 	"gg-scm.io/pkg/git/internal/filesystem"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -171,6 +172,57 @@ func TestConfigBool(t *testing.T) {
 	}
 }
 
+func TestObjectFormat(t *testing.T) {
+	gitPath, err := findGit()
+	if err != nil {
+		t.Skip("git not found:", err)
+	}
+	ctx := context.Background()
+	env, err := newTestEnv(ctx, gitPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.cleanup()
+
+	t.Run("SHA1", func(t *testing.T) {
+		if err := env.g.Init(ctx, "sha1repo"); err != nil {
+			t.Fatal(err)
+		}
+		g := env.g.WithDir("sha1repo")
+		got, err := g.ObjectFormat(ctx)
+		if got != githash.SHA1Format || err != nil {
+			t.Errorf("ObjectFormat(ctx) = %v, %v; want %v, <nil>", got, err, githash.SHA1Format)
+		}
+	})
+	t.Run("SHA256", func(t *testing.T) {
+		if err := env.g.Run(ctx, "init", "--quiet", "--object-format=sha256", "sha256repo"); err != nil {
+			t.Skip("Git does not support SHA-256 repositories:", err)
+		}
+		g := env.g.WithDir("sha256repo")
+		got, err := g.ObjectFormat(ctx)
+		if got != githash.SHA256Format || err != nil {
+			t.Errorf("ObjectFormat(ctx) = %v, %v; want %v, <nil>", got, err, githash.SHA256Format)
+		}
+
+		if err := env.root.Apply(filesystem.Write("sha256repo/foo.txt", "Hello, World!\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Add(ctx, []Pathspec{"foo.txt"}, AddOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Commit(ctx, "Initial import", CommitOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		rev, err := g.Head(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rev.Commit.ObjectFormat(); got != githash.SHA256Format {
+			t.Errorf("HEAD commit %v has format %v; want %v", rev.Commit, got, githash.SHA256Format)
+		}
+	})
+}
+
 func TestListRemotes(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -0,0 +1,573 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gg-scm.io/pkg/git/object"
+)
+
+// DiffOptions specifies the command-line arguments for `git diff`.
+type DiffOptions struct {
+	// Commit1 specifies the earlier commit to compare with. If empty,
+	// then Diff compares against the index.
+	Commit1 string
+	// Commit2 specifies the later commit to compare with. If empty, then
+	// Diff compares against the working tree. Callers must not set
+	// Commit2 if Commit1 is empty.
+	Commit2 string
+	// Pathspecs filters the output to the given pathspecs.
+	Pathspecs []Pathspec
+	// DisableRenames will force Git to disable rename/copy detection.
+	DisableRenames bool
+	// ContextLines is the number of unchanged lines to show around each change.
+	// If zero, Git's default (usually 3) is used.
+	// If negative, no context lines are shown.
+	ContextLines int
+}
+
+// Diff compares the working copy with a commit using `git diff --patch`
+// and returns the parsed patch.
+//
+// See https://git-scm.com/docs/git-diff for more details.
+func (g *Git) Diff(ctx context.Context, opts DiffOptions) ([]*DiffFile, error) {
+	if opts.Commit1 != "" {
+		if err := validateRev(opts.Commit1); err != nil {
+			return nil, fmt.Errorf("diff: %w", err)
+		}
+	}
+	if opts.Commit2 != "" {
+		if opts.Commit1 == "" {
+			return nil, errors.New("diff: Commit2 set without Commit1 being set")
+		}
+		if err := validateRev(opts.Commit2); err != nil {
+			return nil, fmt.Errorf("diff: %w", err)
+		}
+	}
+	args := []string{
+		"diff",
+		"--raw",
+		"--numstat",
+		"--patch",
+		"-z",
+		"--no-abbrev",
+		"--full-index",
+		"--no-color",
+		"--no-ext-diff",
+		"--no-textconv",
+	}
+	if opts.DisableRenames {
+		args = append(args, "--no-renames")
+	}
+	switch {
+	case opts.ContextLines > 0:
+		args = append(args, "--unified="+strconv.Itoa(opts.ContextLines))
+	case opts.ContextLines < 0:
+		args = append(args, "--unified=0")
+	}
+	if opts.Commit1 != "" {
+		args = append(args, opts.Commit1)
+	}
+	if opts.Commit2 != "" {
+		args = append(args, opts.Commit2)
+	}
+	args = append(args, "--")
+	for _, p := range opts.Pathspecs {
+		args = append(args, string(p))
+	}
+	stdout, err := g.output(ctx, "diff", args)
+	if err != nil {
+		return nil, err
+	}
+	files, err := parseDiff(stdout)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+	return files, nil
+}
+
+// A DiffFile describes the changes to a single file in a diff.
+type DiffFile struct {
+	Status DiffStatusCode
+	// Similarity is the percentage of the file that was unchanged
+	// for renames and copies. It is zero for other statuses.
+	Similarity int
+
+	// Name is the path of the file after the change.
+	Name TopPath
+	// OldName is the path of the source file for renames and copies.
+	// It is empty for other statuses.
+	OldName TopPath
+
+	// OldMode and NewMode are the file's modes before and after the change.
+	// OldMode is zero for added files and NewMode is zero for deleted files.
+	OldMode object.Mode
+	NewMode object.Mode
+	// OldObjectID and NewObjectID are the blob hashes before and after the change.
+	// They are zero for files that do not exist on that side of the change.
+	OldObjectID Hash
+	NewObjectID Hash
+
+	// Binary is true if Git considers either side of the change to be binary.
+	// Binary files do not have hunks or line counts.
+	Binary bool
+	// Added and Deleted are the number of lines added and deleted.
+	Added   int
+	Deleted int
+
+	// Hunks is the list of changed regions of the file.
+	// It is empty for binary files, unmerged files,
+	// and changes that only affect the file's mode or name.
+	Hunks []*DiffHunk
+}
+
+// A DiffHunk is a contiguous region of changed lines in a file.
+type DiffHunk struct {
+	// OldStart and NewStart are the 1-based line numbers at which the hunk
+	// starts in the old and new files. If the hunk does not contain any
+	// lines from a side, then the start is the line number preceding the hunk.
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	// Section is the text after the hunk's line ranges,
+	// usually the nearest function declaration.
+	Section string
+
+	Lines []DiffLine
+}
+
+// A DiffLine is a single line in a DiffHunk.
+type DiffLine struct {
+	Op DiffLineOp
+	// Content is the text of the line without the prefix or trailing newline.
+	Content string
+	// OldLine and NewLine are the 1-based line numbers of the line in the
+	// old and new files. They are zero if the line is not present on that side.
+	OldLine int
+	NewLine int
+	// NoNewline is true if the line is the last line in its file
+	// and does not end with a newline.
+	NoNewline bool
+}
+
+// DiffLineOp is the prefix character of a line in a unified diff hunk.
+type DiffLineOp byte
+
+// Diff line operations.
+const (
+	DiffLineContext DiffLineOp = ' '
+	DiffLineAdded   DiffLineOp = '+'
+	DiffLineDeleted DiffLineOp = '-'
+)
+
+// String returns the prefix character as a string.
+func (op DiffLineOp) String() string {
+	return string(op)
+}
+
+// parseDiff parses the output of
+// `git diff --raw --numstat --patch -z --full-index`.
+// Git writes the raw entries, then one numstat entry for each non-combined
+// raw entry, then a NUL byte, then the patch for each non-combined raw entry.
+func parseDiff(out string) ([]*DiffFile, error) {
+	var files []*DiffFile
+	// patched is the list of files that have numstat entries and patches.
+	// Unmerged paths in a working copy diff are shown as combined entries,
+	// which do not appear in either section.
+	var patched []*DiffFile
+	for strings.HasPrefix(out, ":") {
+		var f *DiffFile
+		var combined bool
+		var err error
+		f, combined, out, err = readDiffRawEntry(out)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		if !combined {
+			patched = append(patched, f)
+		}
+	}
+	for _, f := range patched {
+		var err error
+		out, err = readDiffNumstat(f, out)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(out) > 0 {
+		if out[0] != 0 {
+			return nil, fmt.Errorf("expected '\\x00' before patch, got %q", out[0])
+		}
+		out = out[1:]
+	}
+	if err := parseDiffPatch(patched, out); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// readDiffRawEntry reads a single entry in the `git diff --raw -z` format.
+// It reports whether the entry is a combined diff entry.
+func readDiffRawEntry(out string) (_ *DiffFile, combined bool, rest string, _ error) {
+	nparents := 0
+	for nparents < len(out) && out[nparents] == ':' {
+		nparents++
+	}
+	end := strings.IndexByte(out, 0)
+	if end == -1 {
+		return nil, false, out, errors.New("raw entry: unexpected EOF")
+	}
+	fields := strings.Fields(out[nparents:end])
+	out = out[end+1:]
+	if len(fields) != 2*(nparents+1)+1 {
+		return nil, false, out, fmt.Errorf("raw entry: %d fields in line (want %d)", len(fields), 2*(nparents+1)+1)
+	}
+	readName := func() (TopPath, error) {
+		i := strings.IndexByte(out, 0)
+		if i == -1 {
+			return "", errors.New("raw entry: unexpected EOF")
+		}
+		name := TopPath(out[:i])
+		out = out[i+1:]
+		return name, nil
+	}
+
+	if nparents > 1 {
+		// Combined diffs only show unmerged paths.
+		// Report the working copy side of the merge.
+		f := &DiffFile{Status: DiffStatusUnmerged}
+		var err error
+		f.NewMode, err = parseDiffMode(fields[nparents])
+		if err != nil {
+			return nil, true, out, fmt.Errorf("raw entry: %w", err)
+		}
+		f.NewObjectID, err = parseDiffObjectID(fields[2*nparents+1])
+		if err != nil {
+			return nil, true, out, fmt.Errorf("raw entry: %w", err)
+		}
+		f.Name, err = readName()
+		if err != nil {
+			return nil, true, out, err
+		}
+		return f, true, out, nil
+	}
+
+	f := new(DiffFile)
+	var err error
+	f.OldMode, err = parseDiffMode(fields[0])
+	if err != nil {
+		return nil, false, out, fmt.Errorf("raw entry: %w", err)
+	}
+	f.NewMode, err = parseDiffMode(fields[1])
+	if err != nil {
+		return nil, false, out, fmt.Errorf("raw entry: %w", err)
+	}
+	f.OldObjectID, err = parseDiffObjectID(fields[2])
+	if err != nil {
+		return nil, false, out, fmt.Errorf("raw entry: %w", err)
+	}
+	f.NewObjectID, err = parseDiffObjectID(fields[3])
+	if err != nil {
+		return nil, false, out, fmt.Errorf("raw entry: %w", err)
+	}
+	status := fields[4]
+	f.Status = DiffStatusCode(status[0])
+	if !f.Status.isValid() {
+		return nil, false, out, fmt.Errorf("raw entry: invalid code %v", f.Status)
+	}
+	hasFrom := f.Status == DiffStatusRenamed || f.Status == DiffStatusCopied
+	if hasFrom && len(status) > 1 {
+		f.Similarity, err = strconv.Atoi(status[1:])
+		if err != nil {
+			return nil, false, out, fmt.Errorf("raw entry: invalid score in %q", status)
+		}
+	}
+	if hasFrom {
+		f.OldName, err = readName()
+		if err != nil {
+			return nil, false, out, err
+		}
+	}
+	f.Name, err = readName()
+	if err != nil {
+		return nil, false, out, err
+	}
+	return f, false, out, nil
+}
+
+func parseDiffMode(s string) (object.Mode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parse mode: %w", err)
+	}
+	return object.Mode(mode), nil
+}
+
+// parseDiffObjectID parses a hex-encoded object ID,
+// returning the zero Hash for an all-zero ID.
+func parseDiffObjectID(s string) (Hash, error) {
+	if strings.Trim(s, "0") == "" {
+		return Hash{}, nil
+	}
+	return ParseHash(s)
+}
+
+// readDiffNumstat reads a single entry in the `git diff --numstat -z` format
+// into f.
+func readDiffNumstat(f *DiffFile, out string) (string, error) {
+	end := strings.IndexByte(out, '\t')
+	if end == -1 {
+		return out, errors.New("numstat: unexpected EOF")
+	}
+	added := out[:end]
+	out = out[end+1:]
+	end = strings.IndexByte(out, '\t')
+	if end == -1 {
+		return out, errors.New("numstat: unexpected EOF")
+	}
+	deleted := out[:end]
+	out = out[end+1:]
+	if added == "-" && deleted == "-" {
+		f.Binary = true
+	} else {
+		var err error
+		f.Added, err = strconv.Atoi(added)
+		if err != nil {
+			return out, fmt.Errorf("numstat: invalid count %q", added)
+		}
+		f.Deleted, err = strconv.Atoi(deleted)
+		if err != nil {
+			return out, fmt.Errorf("numstat: invalid count %q", deleted)
+		}
+	}
+
+	// Renames and copies have an empty name, followed by the source and
+	// destination names. These are the same as in the raw entry.
+	nnames := 1
+	if strings.HasPrefix(out, "\x00") {
+		out = out[1:]
+		nnames = 2
+	}
+	for i := 0; i < nnames; i++ {
+		end = strings.IndexByte(out, 0)
+		if end == -1 {
+			return out, errors.New("numstat: unexpected EOF")
+		}
+		out = out[end+1:]
+	}
+	return out, nil
+}
+
+// parseDiffPatch parses the patch section of a diff,
+// adding hunks to the files in the order they appear.
+func parseDiffPatch(files []*DiffFile, patch string) error {
+	var lines []string
+	if patch != "" {
+		lines = strings.Split(strings.TrimSuffix(patch, "\n"), "\n")
+	}
+	isFileStart := func(line string) bool {
+		return strings.HasPrefix(line, "diff --git ") ||
+			strings.HasPrefix(line, "* Unmerged path ")
+	}
+	i := 0
+	for _, f := range files {
+		if i >= len(lines) {
+			return fmt.Errorf("patch: missing patch for %s", f.Name)
+		}
+		if strings.HasPrefix(lines[i], "* Unmerged path ") {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(lines[i], "diff --git ") {
+			return fmt.Errorf("patch: expected header for %s, got %q", f.Name, lines[i])
+		}
+		i++
+
+		// Extended headers.
+		for ; i < len(lines) && !isFileStart(lines[i]) && !strings.HasPrefix(lines[i], "@@ "); i++ {
+			line := lines[i]
+			switch {
+			case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+				f.Binary = true
+			case strings.HasPrefix(line, "index "):
+				if err := parseDiffIndexLine(f, line); err != nil {
+					return fmt.Errorf("patch: %s: %w", f.Name, err)
+				}
+			}
+		}
+
+		// Hunks.
+		for i < len(lines) && strings.HasPrefix(lines[i], "@@ ") {
+			h, err := parseDiffHunkHeader(lines[i])
+			if err != nil {
+				return fmt.Errorf("patch: %s: %w", f.Name, err)
+			}
+			i++
+			i, err = readDiffHunkLines(h, lines, i)
+			if err != nil {
+				return fmt.Errorf("patch: %s: %w", f.Name, err)
+			}
+			f.Hunks = append(f.Hunks, h)
+		}
+	}
+	if i < len(lines) {
+		return fmt.Errorf("patch: unexpected line %q", lines[i])
+	}
+	return nil
+}
+
+// parseDiffIndexLine parses an "index" extended header line,
+// filling in object IDs that were missing from the raw entry.
+// Git does not hash working copy files for raw entries,
+// but does include their hashes in the patch.
+func parseDiffIndexLine(f *DiffFile, line string) error {
+	ids := strings.TrimPrefix(line, "index ")
+	if i := strings.IndexByte(ids, ' '); i != -1 {
+		ids = ids[:i]
+	}
+	oldID, newID, ok := strings.Cut(ids, "..")
+	if !ok {
+		return fmt.Errorf("parse %q: missing '..'", line)
+	}
+	if f.OldObjectID.IsZero() {
+		var err error
+		f.OldObjectID, err = parseDiffObjectID(oldID)
+		if err != nil {
+			return fmt.Errorf("parse %q: %w", line, err)
+		}
+	}
+	if f.NewObjectID.IsZero() {
+		var err error
+		f.NewObjectID, err = parseDiffObjectID(newID)
+		if err != nil {
+			return fmt.Errorf("parse %q: %w", line, err)
+		}
+	}
+	return nil
+}
+
+// parseDiffHunkHeader parses a line of the form
+// "@@ -oldStart[,oldLines] +newStart[,newLines] @@[ section]".
+func parseDiffHunkHeader(line string) (*DiffHunk, error) {
+	rest := strings.TrimPrefix(line, "@@ ")
+	ranges, section, ok := strings.Cut(rest, " @@")
+	if !ok {
+		return nil, fmt.Errorf("parse hunk header %q: missing closing '@@'", line)
+	}
+	oldRange, newRange, ok := strings.Cut(ranges, " ")
+	if !ok || !strings.HasPrefix(oldRange, "-") || !strings.HasPrefix(newRange, "+") {
+		return nil, fmt.Errorf("parse hunk header %q: invalid ranges", line)
+	}
+	h := &DiffHunk{Section: strings.TrimPrefix(section, " ")}
+	var err error
+	h.OldStart, h.OldLines, err = parseDiffHunkRange(oldRange[1:])
+	if err != nil {
+		return nil, fmt.Errorf("parse hunk header %q: %w", line, err)
+	}
+	h.NewStart, h.NewLines, err = parseDiffHunkRange(newRange[1:])
+	if err != nil {
+		return nil, fmt.Errorf("parse hunk header %q: %w", line, err)
+	}
+	return h, nil
+}
+
+// parseDiffHunkRange parses "start[,lines]". If lines is omitted, it is 1.
+func parseDiffHunkRange(s string) (start, lines int, err error) {
+	startString, linesString, hasLines := strings.Cut(s, ",")
+	start, err = strconv.Atoi(startString)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid line number %q", startString)
+	}
+	if !hasLines {
+		return start, 1, nil
+	}
+	lines, err = strconv.Atoi(linesString)
+	if err != nil || lines < 0 {
+		return 0, 0, fmt.Errorf("invalid line count %q", linesString)
+	}
+	return start, lines, nil
+}
+
+// readDiffHunkLines reads the lines of h from lines[i:],
+// returning the index of the first line after the hunk.
+func readDiffHunkLines(h *DiffHunk, lines []string, i int) (int, error) {
+	oldLine, newLine := h.OldStart, h.NewStart
+	oldLeft, newLeft := h.OldLines, h.NewLines
+	for oldLeft > 0 || newLeft > 0 {
+		if i >= len(lines) {
+			return i, errors.New("unexpected EOF in hunk")
+		}
+		line := lines[i]
+		i++
+		if strings.HasPrefix(line, `\`) {
+			if len(h.Lines) > 0 {
+				h.Lines[len(h.Lines)-1].NoNewline = true
+			}
+			continue
+		}
+		// diff.suppressBlankEmpty omits the space for empty context lines.
+		op := DiffLineContext
+		if line != "" {
+			op = DiffLineOp(line[0])
+			line = line[1:]
+		}
+		l := DiffLine{Op: op, Content: line}
+		switch op {
+		case DiffLineContext:
+			if oldLeft == 0 || newLeft == 0 {
+				return i, errors.New("hunk longer than header")
+			}
+			l.OldLine = oldLine
+			l.NewLine = newLine
+			oldLine++
+			newLine++
+			oldLeft--
+			newLeft--
+		case DiffLineDeleted:
+			if oldLeft == 0 {
+				return i, errors.New("hunk longer than header")
+			}
+			l.OldLine = oldLine
+			oldLine++
+			oldLeft--
+		case DiffLineAdded:
+			if newLeft == 0 {
+				return i, errors.New("hunk longer than header")
+			}
+			l.NewLine = newLine
+			newLine++
+			newLeft--
+		default:
+			return i, fmt.Errorf("invalid hunk line %q", lines[i-1])
+		}
+		h.Lines = append(h.Lines, l)
+	}
+	if i < len(lines) && strings.HasPrefix(lines[i], `\`) {
+		if len(h.Lines) > 0 {
+			h.Lines[len(h.Lines)-1].NoNewline = true
+		}
+		i++
+	}
+	return i, nil
+}
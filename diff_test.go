@@ -0,0 +1,393 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"gg-scm.io/pkg/git/internal/filesystem"
+	"gg-scm.io/pkg/git/object"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	gitPath, err := findGit()
+	if err != nil {
+		t.Skip("git not found:", err)
+	}
+	ctx := context.Background()
+	env, err := newTestEnv(ctx, gitPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(env.cleanup)
+	if err := env.g.Init(ctx, "."); err != nil {
+		t.Fatal(err)
+	}
+	const (
+		fooContent1    = "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+		fooContent2    = "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm"
+		binContent1    = "\x00\x01\x02"
+		binContent2    = "\x00\x01\x03"
+		scriptContent  = "#!/bin/sh\necho hi\n"
+		renameContent1 = "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+		renameContent2 = "1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"
+		addedContent   = "new\n"
+	)
+	err = env.root.Apply(
+		filesystem.Write("foo.txt", fooContent1),
+		filesystem.Write("bin.dat", binContent1),
+		filesystem.Write("script.sh", scriptContent),
+		filesystem.Write("old.txt", renameContent1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Add(ctx, []Pathspec{"."}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "first", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	err = env.root.Apply(
+		filesystem.Write("foo.txt", fooContent2),
+		filesystem.Write("bin.dat", binContent2),
+		filesystem.Remove("old.txt"),
+		filesystem.Write("new.txt", renameContent2),
+		filesystem.Write("added.txt", addedContent),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(env.root.FromSlash("script.sh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Add(ctx, []Pathspec{"."}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := env.g.Diff(ctx, DiffOptions{Commit1: "HEAD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := func(content string) Hash {
+		t.Helper()
+		id, err := object.BlobSum(strings.NewReader(content), int64(len(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	want := []*DiffFile{
+		{
+			Status:      DiffStatusAdded,
+			Name:        "added.txt",
+			NewMode:     object.ModePlain,
+			NewObjectID: blob(addedContent),
+			Added:       1,
+			Hunks: []*DiffHunk{{
+				OldStart: 0,
+				OldLines: 0,
+				NewStart: 1,
+				NewLines: 1,
+				Lines: []DiffLine{
+					{Op: DiffLineAdded, Content: "new", NewLine: 1},
+				},
+			}},
+		},
+		{
+			Status:      DiffStatusModified,
+			Name:        "bin.dat",
+			OldMode:     object.ModePlain,
+			NewMode:     object.ModePlain,
+			OldObjectID: blob(binContent1),
+			NewObjectID: blob(binContent2),
+			Binary:      true,
+		},
+		{
+			Status:      DiffStatusModified,
+			Name:        "foo.txt",
+			OldMode:     object.ModePlain,
+			NewMode:     object.ModePlain,
+			OldObjectID: blob(fooContent1),
+			NewObjectID: blob(fooContent2),
+			Added:       2,
+			Deleted:     1,
+			Hunks: []*DiffHunk{
+				{
+					OldStart: 1,
+					OldLines: 5,
+					NewStart: 1,
+					NewLines: 5,
+					Lines: []DiffLine{
+						{Op: DiffLineContext, Content: "a", OldLine: 1, NewLine: 1},
+						{Op: DiffLineDeleted, Content: "b", OldLine: 2},
+						{Op: DiffLineAdded, Content: "B", NewLine: 2},
+						{Op: DiffLineContext, Content: "c", OldLine: 3, NewLine: 3},
+						{Op: DiffLineContext, Content: "d", OldLine: 4, NewLine: 4},
+						{Op: DiffLineContext, Content: "e", OldLine: 5, NewLine: 5},
+					},
+				},
+				{
+					OldStart: 10,
+					OldLines: 3,
+					NewStart: 10,
+					NewLines: 4,
+					Section:  "i",
+					Lines: []DiffLine{
+						{Op: DiffLineContext, Content: "j", OldLine: 10, NewLine: 10},
+						{Op: DiffLineContext, Content: "k", OldLine: 11, NewLine: 11},
+						{Op: DiffLineContext, Content: "l", OldLine: 12, NewLine: 12},
+						{Op: DiffLineAdded, Content: "m", NewLine: 13, NoNewline: true},
+					},
+				},
+			},
+		},
+		{
+			Status:      DiffStatusRenamed,
+			Similarity:  81,
+			Name:        "new.txt",
+			OldName:     "old.txt",
+			OldMode:     object.ModePlain,
+			NewMode:     object.ModePlain,
+			OldObjectID: blob(renameContent1),
+			NewObjectID: blob(renameContent2),
+			Added:       1,
+			Deleted:     1,
+			Hunks: []*DiffHunk{{
+				OldStart: 7,
+				OldLines: 4,
+				NewStart: 7,
+				NewLines: 4,
+				Lines: []DiffLine{
+					{Op: DiffLineContext, Content: "7", OldLine: 7, NewLine: 7},
+					{Op: DiffLineContext, Content: "8", OldLine: 8, NewLine: 8},
+					{Op: DiffLineContext, Content: "9", OldLine: 9, NewLine: 9},
+					{Op: DiffLineDeleted, Content: "10", OldLine: 10},
+					{Op: DiffLineAdded, Content: "ten", NewLine: 10},
+				},
+			}},
+		},
+		{
+			Status:      DiffStatusModified,
+			Name:        "script.sh",
+			OldMode:     object.ModePlain,
+			NewMode:     object.ModePlain | 0o111,
+			OldObjectID: blob(scriptContent),
+			NewObjectID: blob(scriptContent),
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Diff(ctx, {Commit1: \"HEAD\"}) (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	const (
+		hash1 = "8a3ca1e12ab3a9e1e6e0a7d4f1d4a6e2d0c3e6b1"
+		hash2 = "c4d39e3d1f2a0bb7d2f1b9a3bdc6a6c37b3d5e12"
+		null  = "0000000000000000000000000000000000000000"
+	)
+	id1, err := ParseHash(hash1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, err := ParseHash(hash2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		out     string
+		want    []*DiffFile
+		wantErr bool
+	}{
+		{
+			name: "Empty",
+			out:  "",
+		},
+		{
+			name: "RenameOnly",
+			out: ":100644 100644 " + hash1 + " " + hash1 + " R100\x00a.txt\x00b.txt\x00" +
+				"0\t0\t\x00a.txt\x00b.txt\x00" +
+				"\x00" +
+				"diff --git a/a.txt b/b.txt\n" +
+				"similarity index 100%\n" +
+				"rename from a.txt\n" +
+				"rename to b.txt\n",
+			want: []*DiffFile{{
+				Status:      DiffStatusRenamed,
+				Similarity:  100,
+				Name:        "b.txt",
+				OldName:     "a.txt",
+				OldMode:     object.ModePlain,
+				NewMode:     object.ModePlain,
+				OldObjectID: id1,
+				NewObjectID: id1,
+			}},
+		},
+		{
+			name: "WorkingCopyHash",
+			out: ":100644 100644 " + hash1 + " " + null + " M\x00foo.txt\x00" +
+				"1\t0\tfoo.txt\x00" +
+				"\x00" +
+				"diff --git a/foo.txt b/foo.txt\n" +
+				"index " + hash1 + ".." + hash2 + " 100644\n" +
+				"--- a/foo.txt\n" +
+				"+++ b/foo.txt\n" +
+				"@@ -0,0 +1 @@\n" +
+				"+x\n",
+			want: []*DiffFile{{
+				Status:      DiffStatusModified,
+				Name:        "foo.txt",
+				OldMode:     object.ModePlain,
+				NewMode:     object.ModePlain,
+				OldObjectID: id1,
+				NewObjectID: id2,
+				Added:       1,
+				Hunks: []*DiffHunk{{
+					NewStart: 1,
+					NewLines: 1,
+					Lines: []DiffLine{
+						{Op: DiffLineAdded, Content: "x", NewLine: 1},
+					},
+				}},
+			}},
+		},
+		{
+			name: "SuppressBlankEmpty",
+			out: ":100644 100644 " + hash1 + " " + hash2 + " M\x00foo.txt\x00" +
+				"1\t1\tfoo.txt\x00" +
+				"\x00" +
+				"diff --git a/foo.txt b/foo.txt\n" +
+				"index " + hash1 + ".." + hash2 + " 100644\n" +
+				"--- a/foo.txt\n" +
+				"+++ b/foo.txt\n" +
+				"@@ -1,2 +1,2 @@ func main() {\n" +
+				"\n" +
+				"-a\n" +
+				"\\ No newline at end of file\n" +
+				"+b\n" +
+				"\\ No newline at end of file\n",
+			want: []*DiffFile{{
+				Status:      DiffStatusModified,
+				Name:        "foo.txt",
+				OldMode:     object.ModePlain,
+				NewMode:     object.ModePlain,
+				OldObjectID: id1,
+				NewObjectID: id2,
+				Added:       1,
+				Deleted:     1,
+				Hunks: []*DiffHunk{{
+					OldStart: 1,
+					OldLines: 2,
+					NewStart: 1,
+					NewLines: 2,
+					Section:  "func main() {",
+					Lines: []DiffLine{
+						{Op: DiffLineContext, Content: "", OldLine: 1, NewLine: 1},
+						{Op: DiffLineDeleted, Content: "a", OldLine: 2, NoNewline: true},
+						{Op: DiffLineAdded, Content: "b", NewLine: 2, NoNewline: true},
+					},
+				}},
+			}},
+		},
+		{
+			name: "Unmerged",
+			out: "::100644 100644 100644 " + hash1 + " " + hash2 + " " + null + " MM\x00conflict.txt\x00" +
+				":100644 000000 " + hash1 + " " + null + " D\x00gone.txt\x00" +
+				"0\t1\tgone.txt\x00" +
+				"\x00" +
+				"diff --git a/gone.txt b/gone.txt\n" +
+				"deleted file mode 100644\n" +
+				"index " + hash1 + ".." + null + "\n" +
+				"--- a/gone.txt\n" +
+				"+++ /dev/null\n" +
+				"@@ -1 +0,0 @@\n" +
+				"-diff --git a/x b/x\n",
+			want: []*DiffFile{
+				{
+					Status:  DiffStatusUnmerged,
+					Name:    "conflict.txt",
+					NewMode: object.ModePlain,
+				},
+				{
+					Status:      DiffStatusDeleted,
+					Name:        "gone.txt",
+					OldMode:     object.ModePlain,
+					OldObjectID: id1,
+					Deleted:     1,
+					Hunks: []*DiffHunk{{
+						OldStart: 1,
+						OldLines: 1,
+						Lines: []DiffLine{
+							{Op: DiffLineDeleted, Content: "diff --git a/x b/x", OldLine: 1},
+						},
+					}},
+				},
+			},
+		},
+		{
+			name: "UnmergedIndex",
+			out: ":000000 000000 " + null + " " + null + " U\x00f.txt\x00" +
+				"0\t0\tf.txt\x00" +
+				"\x00" +
+				"* Unmerged path f.txt\n",
+			want: []*DiffFile{{
+				Status: DiffStatusUnmerged,
+				Name:   "f.txt",
+			}},
+		},
+		{
+			name: "HunkTooShort",
+			out: ":100644 100644 " + hash1 + " " + hash2 + " M\x00foo.txt\x00" +
+				"1\t1\tfoo.txt\x00" +
+				"\x00" +
+				"diff --git a/foo.txt b/foo.txt\n" +
+				"@@ -1,2 +1,2 @@\n" +
+				"-a\n" +
+				"+b\n",
+			wantErr: true,
+		},
+		{
+			name: "MissingPatch",
+			out: ":100644 100644 " + hash1 + " " + hash2 + " M\x00foo.txt\x00" +
+				"1\t1\tfoo.txt\x00",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseDiff(test.out)
+			if err != nil {
+				if !test.wantErr {
+					t.Fatal("parseDiff:", err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Fatalf("parseDiff(...) = %+v, <nil>; want error", got)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("parseDiff(...) (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
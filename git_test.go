@@ -253,6 +253,23 @@ func (env *testEnv) cleanup() {
 	os.RemoveAll(env.top.String())
 }
 
+// newTestSSHKey generates an unencrypted SSH key in the test environment
+// and returns the path to the private key.
+// It skips the test if ssh-keygen is not available.
+func newTestSSHKey(tb testing.TB, env *testEnv) string {
+	tb.Helper()
+	sshKeygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		tb.Skip("ssh-keygen not found:", err)
+	}
+	keyPath := env.top.FromSlash("id_ed25519")
+	out, err := exec.Command(sshKeygen, "-q", "-t", "ed25519", "-N", "", "-C", "", "-f", keyPath).CombinedOutput()
+	if err != nil {
+		tb.Fatalf("ssh-keygen: %v\n%s", err, out)
+	}
+	return keyPath
+}
+
 // prettyCommit annotates the hex-encoded hash with a name if present
 // in the given map.
 func prettyCommit(h Hash, names map[Hash]string) string {
@@ -23,8 +23,11 @@ import (
 	"io"
 )
 
-// SHA1Size is the number of bytes in a SHA-1 hash.
-const SHA1Size = 20
+// Hash sizes in bytes.
+const (
+	SHA1Size   = 20
+	SHA256Size = 32
+)
 
 // A SHA1 is the SHA-1 hash of a Git object.
 type SHA1 [SHA1Size]byte
@@ -83,7 +86,82 @@ func (h *SHA1) UnmarshalBinary(b []byte) error {
 // Format implements the fmt.Formatter interface.
 // Specifically, it ensures that %x does not double-hex-encode the data.
 func (h SHA1) Format(f fmt.State, c rune) {
-	bits := h[:]
+	formatHash(f, c, "githash.SHA1", h[:])
+}
+
+// ObjectID returns h as an object ID.
+func (h SHA1) ObjectID() ObjectID {
+	id := ObjectID{format: SHA1Format}
+	copy(id.hash[:], h[:])
+	return id
+}
+
+// A SHA256 is the SHA-256 hash of a Git object.
+type SHA256 [SHA256Size]byte
+
+// ParseSHA256 parses a hex-encoded SHA-256 hash. It is the same as calling
+// UnmarshalText on a new SHA256.
+func ParseSHA256(s string) (SHA256, error) {
+	var h SHA256
+	err := h.UnmarshalText([]byte(s))
+	return h, err
+}
+
+// String returns the hex-encoded hash.
+func (h SHA256) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Short returns the first 4 hex-encoded bytes of the hash.
+func (h SHA256) Short() string {
+	return hex.EncodeToString(h[:4])
+}
+
+// MarshalText returns the hex-encoded hash.
+func (h SHA256) MarshalText() ([]byte, error) {
+	buf := make([]byte, hex.EncodedLen(len(h)))
+	hex.Encode(buf, h[:])
+	return buf, nil
+}
+
+// UnmarshalText decodes a hex-encoded hash into h.
+func (h *SHA256) UnmarshalText(s []byte) error {
+	if len(s) != hex.EncodedLen(SHA256Size) {
+		return fmt.Errorf("parse git hash %q: wrong size", s)
+	}
+	if _, err := hex.Decode(h[:], s); err != nil {
+		return fmt.Errorf("parse git hash %q: %w", s, err)
+	}
+	return nil
+}
+
+// MarshalBinary returns the hash as a byte slice.
+func (h SHA256) MarshalBinary() ([]byte, error) {
+	return h[:], nil
+}
+
+// UnmarshalBinary copies the bytes from b into h. It returns an error if
+// len(b) != len(*h).
+func (h *SHA256) UnmarshalBinary(b []byte) error {
+	if len(b) != len(*h) {
+		return fmt.Errorf("parse git binary hash %x: wrong size", b)
+	}
+	copy(h[:], b)
+	return nil
+}
+
+// Format implements the fmt.Formatter interface.
+// Specifically, it ensures that %x does not double-hex-encode the data.
+func (h SHA256) Format(f fmt.State, c rune) {
+	formatHash(f, c, "githash.SHA256", h[:])
+}
+
+// ObjectID returns h as an object ID.
+func (h SHA256) ObjectID() ObjectID {
+	return ObjectID{format: SHA256Format, hash: h}
+}
+
+func formatHash(f fmt.State, c rune, typeName string, bits []byte) {
 	if prec, ok := f.Precision(); ok && c != 'v' && prec < len(bits) {
 		bits = bits[:prec]
 	}
@@ -98,7 +176,7 @@ func (h SHA1) Format(f fmt.State, c rune) {
 			f.Write(text)
 			return
 		}
-		f.Write([]byte("githash.SHA1{"))
+		io.WriteString(f, typeName+"{")
 		sep := []byte(", 0x")
 		f.Write(sep[2:])
 		f.Write(text[:2])
@@ -126,7 +204,7 @@ func (h SHA1) Format(f fmt.State, c rune) {
 		// Print a wrong type/unknown verb error.
 		f.Write([]byte("%!"))
 		io.WriteString(f, string(c))
-		f.Write([]byte("(githash.SHA1="))
+		io.WriteString(f, "("+typeName+"=")
 		f.Write(text)
 		f.Write([]byte(")"))
 	}
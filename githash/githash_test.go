@@ -34,6 +34,16 @@ var (
 	_ encoding.BinaryUnmarshaler = &SHA1{}
 )
 
+// Verify that SHA256 implements the various encoding interfaces.
+var (
+	_ fmt.Stringer               = SHA256{}
+	_ fmt.Formatter              = SHA256{}
+	_ encoding.TextMarshaler     = SHA256{}
+	_ encoding.TextUnmarshaler   = &SHA256{}
+	_ encoding.BinaryMarshaler   = SHA256{}
+	_ encoding.BinaryUnmarshaler = &SHA256{}
+)
+
 func TestSHA1(t *testing.T) {
 	tests := []struct {
 		h     SHA1
@@ -140,3 +150,28 @@ func TestParseSHA1(t *testing.T) {
 		}
 	}
 }
+
+func TestSHA256(t *testing.T) {
+	h := SHA256{
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+	}
+	const s = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	if got := h.String(); got != s {
+		t.Errorf("h.String() = %q; want %q", got, s)
+	}
+	if got := h.Short(); got != s[:8] {
+		t.Errorf("h.Short() = %q; want %q", got, s[:8])
+	}
+	if got := fmt.Sprintf("%.4x", h); got != s[:8] {
+		t.Errorf("fmt.Sprintf(\"%%.4x\", h) = %q; want %q", got, s[:8])
+	}
+	if got, err := ParseSHA256(s); err != nil || got != h {
+		t.Errorf("ParseSHA256(%q) = %v, %v; want %v, <nil>", s, got, err, h)
+	}
+	if got, err := ParseSHA256(s[:40]); err == nil {
+		t.Errorf("ParseSHA256(%q) = %v, <nil>; want error", s[:40], got)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package githash
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+// ObjectFormat is an enumeration of the hash algorithms
+// that a Git repository can use to name its objects.
+// The zero value is SHA1Format.
+// See https://git-scm.com/docs/hash-function-transition
+type ObjectFormat int8
+
+// Object formats.
+const (
+	SHA1Format   ObjectFormat = 0
+	SHA256Format ObjectFormat = 1
+)
+
+// ParseObjectFormat parses an object format name
+// as it appears in the extensions.objectFormat configuration setting
+// (e.g. "sha1" or "sha256").
+func ParseObjectFormat(s string) (ObjectFormat, error) {
+	switch s {
+	case "sha1":
+		return SHA1Format, nil
+	case "sha256":
+		return SHA256Format, nil
+	default:
+		return 0, fmt.Errorf("parse git object format: unknown format %q", s)
+	}
+}
+
+// IsValid reports whether f is one of the known constants.
+func (f ObjectFormat) IsValid() bool {
+	return f == SHA1Format || f == SHA256Format
+}
+
+// String returns the Git name of the format (e.g. "sha1" or "sha256").
+func (f ObjectFormat) String() string {
+	switch f {
+	case SHA1Format:
+		return "sha1"
+	case SHA256Format:
+		return "sha256"
+	default:
+		return fmt.Sprintf("ObjectFormat(%d)", int8(f))
+	}
+}
+
+// Size returns the number of bytes in a hash of the format.
+// Size panics if f is not a known format.
+func (f ObjectFormat) Size() int {
+	switch f {
+	case SHA1Format:
+		return SHA1Size
+	case SHA256Format:
+		return SHA256Size
+	default:
+		panic("unknown git object format " + f.String())
+	}
+}
+
+// New returns a new hash.Hash that computes the format's hash.
+// New panics if f is not a known format.
+func (f ObjectFormat) New() hash.Hash {
+	switch f {
+	case SHA1Format:
+		return sha1.New()
+	case SHA256Format:
+		return sha256.New()
+	default:
+		panic("unknown git object format " + f.String())
+	}
+}
+
+// Sum returns the object ID for the data written to h.
+// h must have been created by f.New.
+func (f ObjectFormat) Sum(h hash.Hash) ObjectID {
+	id := ObjectID{format: f}
+	h.Sum(id.hash[:0])
+	return id
+}
+
+// Null returns the all-zeroes object ID of the format.
+func (f ObjectFormat) Null() ObjectID {
+	return ObjectID{format: f}
+}
+
+// An ObjectID is the hash of a Git object in one of the supported object
+// formats. ObjectIDs are comparable, so they can be used as map keys.
+// The zero value is the null SHA-1 hash.
+type ObjectID struct {
+	hash   [SHA256Size]byte
+	format ObjectFormat
+}
+
+// ParseObjectID parses a hex-encoded hash. The object format is determined
+// from the length of the string. It is the same as calling UnmarshalText on a
+// new ObjectID.
+func ParseObjectID(s string) (ObjectID, error) {
+	var id ObjectID
+	err := id.UnmarshalText([]byte(s))
+	return id, err
+}
+
+// ObjectFormat returns the hash algorithm used to produce the object ID.
+func (id ObjectID) ObjectFormat() ObjectFormat {
+	return id.format
+}
+
+// Bytes returns the hash as a newly allocated byte slice.
+func (id ObjectID) Bytes() []byte {
+	b := make([]byte, id.format.Size())
+	copy(b, id.hash[:])
+	return b
+}
+
+// AppendBinary appends the raw bytes of the hash to dst.
+func (id ObjectID) AppendBinary(dst []byte) []byte {
+	return append(dst, id.hash[:id.format.Size()]...)
+}
+
+// IsZero reports whether every byte of the hash is zero.
+func (id ObjectID) IsZero() bool {
+	return id.hash == [SHA256Size]byte{}
+}
+
+// SHA1 returns the SHA-1 hash if the object ID is in SHA1Format.
+func (id ObjectID) SHA1() (_ SHA1, ok bool) {
+	if id.format != SHA1Format {
+		return SHA1{}, false
+	}
+	var h SHA1
+	copy(h[:], id.hash[:])
+	return h, true
+}
+
+// SHA256 returns the SHA-256 hash if the object ID is in SHA256Format.
+func (id ObjectID) SHA256() (_ SHA256, ok bool) {
+	if id.format != SHA256Format {
+		return SHA256{}, false
+	}
+	return SHA256(id.hash), true
+}
+
+// Compare returns an integer comparing the bytes of two object IDs.
+// The result will be 0 if id == id2, -1 if id < id2, and +1 if id > id2.
+// Object IDs of different formats are ordered by format.
+func (id ObjectID) Compare(id2 ObjectID) int {
+	if id.format != id2.format {
+		if id.format < id2.format {
+			return -1
+		}
+		return 1
+	}
+	return bytes.Compare(id.hash[:], id2.hash[:])
+}
+
+// Equal reports whether id == id2.
+func (id ObjectID) Equal(id2 ObjectID) bool {
+	return id == id2
+}
+
+// String returns the hex-encoded hash.
+func (id ObjectID) String() string {
+	return hex.EncodeToString(id.hash[:id.format.Size()])
+}
+
+// Short returns the first 4 hex-encoded bytes of the hash.
+func (id ObjectID) Short() string {
+	return hex.EncodeToString(id.hash[:4])
+}
+
+// MarshalText returns the hex-encoded hash.
+func (id ObjectID) MarshalText() ([]byte, error) {
+	buf := make([]byte, hex.EncodedLen(id.format.Size()))
+	hex.Encode(buf, id.hash[:id.format.Size()])
+	return buf, nil
+}
+
+// UnmarshalText decodes a hex-encoded hash into id.
+// The object format is determined from the length of s.
+func (id *ObjectID) UnmarshalText(s []byte) error {
+	var format ObjectFormat
+	switch len(s) {
+	case hex.EncodedLen(SHA1Size):
+		format = SHA1Format
+	case hex.EncodedLen(SHA256Size):
+		format = SHA256Format
+	default:
+		return fmt.Errorf("parse git hash %q: wrong size", s)
+	}
+	var newID ObjectID
+	newID.format = format
+	if _, err := hex.Decode(newID.hash[:], s); err != nil {
+		return fmt.Errorf("parse git hash %q: %w", s, err)
+	}
+	*id = newID
+	return nil
+}
+
+// MarshalBinary returns the hash as a byte slice.
+func (id ObjectID) MarshalBinary() ([]byte, error) {
+	return id.Bytes(), nil
+}
+
+// UnmarshalBinary copies the bytes from b into id.
+// The object format is determined from the length of b.
+func (id *ObjectID) UnmarshalBinary(b []byte) error {
+	var newID ObjectID
+	switch len(b) {
+	case SHA1Size:
+		newID.format = SHA1Format
+	case SHA256Size:
+		newID.format = SHA256Format
+	default:
+		return fmt.Errorf("parse git binary hash %x: wrong size", b)
+	}
+	copy(newID.hash[:], b)
+	*id = newID
+	return nil
+}
+
+// Format implements the fmt.Formatter interface.
+// Specifically, it ensures that %x does not double-hex-encode the data.
+func (id ObjectID) Format(f fmt.State, c rune) {
+	formatHash(f, c, "githash.ObjectID", id.hash[:id.format.Size()])
+}
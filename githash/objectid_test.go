@@ -0,0 +1,141 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package githash
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"testing"
+)
+
+// Verify that ObjectID implements the various encoding interfaces.
+var (
+	_ fmt.Stringer               = ObjectID{}
+	_ fmt.Formatter              = ObjectID{}
+	_ encoding.TextMarshaler     = ObjectID{}
+	_ encoding.TextUnmarshaler   = &ObjectID{}
+	_ encoding.BinaryMarshaler   = ObjectID{}
+	_ encoding.BinaryUnmarshaler = &ObjectID{}
+)
+
+func TestObjectID(t *testing.T) {
+	tests := []struct {
+		s      string
+		format ObjectFormat
+	}{
+		{
+			s:      "0123456789abcdef0123456789abcdef01234567",
+			format: SHA1Format,
+		},
+		{
+			s:      "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			format: SHA256Format,
+		},
+	}
+	for _, test := range tests {
+		id, err := ParseObjectID(test.s)
+		if err != nil {
+			t.Errorf("ParseObjectID(%q): %v", test.s, err)
+			continue
+		}
+		if got := id.ObjectFormat(); got != test.format {
+			t.Errorf("ParseObjectID(%q).ObjectFormat() = %v; want %v", test.s, got, test.format)
+		}
+		if got := id.String(); got != test.s {
+			t.Errorf("ParseObjectID(%q).String() = %q", test.s, got)
+		}
+		if got := fmt.Sprintf("%x", id); got != test.s {
+			t.Errorf("fmt.Sprintf(\"%%x\", ParseObjectID(%q)) = %q", test.s, got)
+		}
+		if got := id.Short(); got != test.s[:8] {
+			t.Errorf("ParseObjectID(%q).Short() = %q; want %q", test.s, got, test.s[:8])
+		}
+		b := id.Bytes()
+		if len(b) != test.format.Size() {
+			t.Errorf("len(ParseObjectID(%q).Bytes()) = %d; want %d", test.s, len(b), test.format.Size())
+		}
+		if got := id.AppendBinary(nil); !bytes.Equal(got, b) {
+			t.Errorf("ParseObjectID(%q).AppendBinary(nil) = %x; want %x", test.s, got, b)
+		}
+		var id2 ObjectID
+		if err := id2.UnmarshalBinary(b); err != nil {
+			t.Errorf("UnmarshalBinary(%x): %v", b, err)
+		} else if id2 != id {
+			t.Errorf("UnmarshalBinary(%x) = %v; want %v", b, id2, id)
+		}
+		if id.IsZero() {
+			t.Errorf("ParseObjectID(%q).IsZero() = true", test.s)
+		}
+		if null := test.format.Null(); !null.IsZero() || null.ObjectFormat() != test.format {
+			t.Errorf("%v.Null() = %v (format %v)", test.format, null, null.ObjectFormat())
+		}
+	}
+
+	for _, s := range []string{"", "01234567", "0123456789abcdef0123456789abcdef012345678", "fooooooooooooooooooooooooooooooooooooooo"} {
+		if id, err := ParseObjectID(s); err == nil {
+			t.Errorf("ParseObjectID(%q) = %v, <nil>; want error", s, id)
+		}
+	}
+}
+
+func TestObjectIDConversions(t *testing.T) {
+	if (ObjectID{}) != (SHA1{}).ObjectID() {
+		t.Error("ObjectID{} != SHA1{}.ObjectID()")
+	}
+	if (ObjectID{}) == (SHA256{}).ObjectID() {
+		t.Error("ObjectID{} == SHA256{}.ObjectID()")
+	}
+	h1 := SHA1{0xab, 0xcd}
+	if got, ok := h1.ObjectID().SHA1(); !ok || got != h1 {
+		t.Errorf("h1.ObjectID().SHA1() = %v, %t; want %v, true", got, ok, h1)
+	}
+	if _, ok := h1.ObjectID().SHA256(); ok {
+		t.Error("h1.ObjectID().SHA256() returned ok")
+	}
+	h256 := SHA256{0xab, 0xcd}
+	if got, ok := h256.ObjectID().SHA256(); !ok || got != h256 {
+		t.Errorf("h256.ObjectID().SHA256() = %v, %t; want %v, true", got, ok, h256)
+	}
+}
+
+func TestObjectFormat(t *testing.T) {
+	tests := []struct {
+		format ObjectFormat
+		name   string
+		sum    string
+	}{
+		{SHA1Format, "sha1", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{SHA256Format, "sha256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+	for _, test := range tests {
+		if got := test.format.String(); got != test.name {
+			t.Errorf("%d.String() = %q; want %q", int8(test.format), got, test.name)
+		}
+		if got, err := ParseObjectFormat(test.name); err != nil || got != test.format {
+			t.Errorf("ParseObjectFormat(%q) = %v, %v; want %v, <nil>", test.name, got, err, test.format)
+		}
+		h := test.format.New()
+		h.Write([]byte("abc"))
+		if got := test.format.Sum(h).String(); got != test.sum {
+			t.Errorf("%v.Sum(\"abc\") = %s; want %s", test.format, got, test.sum)
+		}
+	}
+	if got, err := ParseObjectFormat("md5"); err == nil {
+		t.Errorf("ParseObjectFormat(\"md5\") = %v, <nil>; want error", got)
+	}
+}
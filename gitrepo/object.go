@@ -0,0 +1,293 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitrepo
+
+import (
+	"bufio"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+)
+
+// OpenObject returns the type and size of the object with the given ID
+// and a reader for the object's contents. The caller is responsible for
+// closing the returned reader. If the object does not exist, OpenObject
+// returns an error for which errors.Is(err, fs.ErrNotExist) reports true.
+func (r *Repository) OpenObject(id githash.ObjectID) (object.Prefix, io.ReadCloser, error) {
+	if id.ObjectFormat() != r.format {
+		return object.Prefix{}, nil, fmt.Errorf("read git object %v: repository uses %v", id, r.format)
+	}
+	p, offset, err := r.findPacked(id)
+	if err != nil {
+		return object.Prefix{}, nil, fmt.Errorf("read git object %v: %w", id, err)
+	}
+	if p != nil {
+		prefix, rc, err := r.openPacked(p, offset)
+		if err != nil {
+			return object.Prefix{}, nil, fmt.Errorf("read git object %v: %w", id, err)
+		}
+		return prefix, rc, nil
+	}
+	prefix, rc, err := r.openLoose(id)
+	if err == nil {
+		return prefix, rc, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return object.Prefix{}, nil, fmt.Errorf("read git object %v: %w", id, err)
+	}
+	// The object may have been packed since the packs were last scanned.
+	p, offset, err = r.findPackedAfterRescan(id)
+	if err != nil {
+		return object.Prefix{}, nil, fmt.Errorf("read git object %v: %w", id, err)
+	}
+	if p == nil {
+		return object.Prefix{}, nil, notExistError(fmt.Sprintf("read git object %v: not found", id))
+	}
+	prefix, rc, err = r.openPacked(p, offset)
+	if err != nil {
+		return object.Prefix{}, nil, fmt.Errorf("read git object %v: %w", id, err)
+	}
+	return prefix, rc, nil
+}
+
+// Stat returns the type and size of the object with the given ID.
+// If the object does not exist, Stat returns an error for which
+// errors.Is(err, fs.ErrNotExist) reports true.
+func (r *Repository) Stat(id githash.ObjectID) (object.Prefix, error) {
+	if id.ObjectFormat() != r.format {
+		return object.Prefix{}, fmt.Errorf("stat git object %v: repository uses %v", id, r.format)
+	}
+	p, offset, err := r.findPacked(id)
+	if err != nil {
+		return object.Prefix{}, fmt.Errorf("stat git object %v: %w", id, err)
+	}
+	if p != nil {
+		prefix, err := p.stat(offset)
+		if err != nil {
+			return object.Prefix{}, fmt.Errorf("stat git object %v: %w", id, err)
+		}
+		return prefix, nil
+	}
+	prefix, rc, err := r.openLoose(id)
+	if err == nil {
+		rc.Close()
+		return prefix, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return object.Prefix{}, fmt.Errorf("stat git object %v: %w", id, err)
+	}
+	p, offset, err = r.findPackedAfterRescan(id)
+	if err != nil {
+		return object.Prefix{}, fmt.Errorf("stat git object %v: %w", id, err)
+	}
+	if p == nil {
+		return object.Prefix{}, notExistError(fmt.Sprintf("stat git object %v: not found", id))
+	}
+	prefix, err = p.stat(offset)
+	if err != nil {
+		return object.Prefix{}, fmt.Errorf("stat git object %v: %w", id, err)
+	}
+	return prefix, nil
+}
+
+// HasObject reports whether the repository contains an object with the
+// given ID.
+func (r *Repository) HasObject(id githash.ObjectID) (bool, error) {
+	if id.ObjectFormat() != r.format {
+		return false, nil
+	}
+	p, _, err := r.findPacked(id)
+	if err != nil {
+		return false, fmt.Errorf("check for git object %v: %w", id, err)
+	}
+	if p != nil {
+		return true, nil
+	}
+	for _, dir := range r.objectDirs {
+		if _, err := os.Stat(looseObjectPath(dir, id)); err == nil {
+			return true, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("check for git object %v: %w", id, err)
+		}
+	}
+	p, _, err = r.findPackedAfterRescan(id)
+	if err != nil {
+		return false, fmt.Errorf("check for git object %v: %w", id, err)
+	}
+	return p != nil, nil
+}
+
+// ReadObject reads the entire contents of the object with the given ID.
+func (r *Repository) ReadObject(id githash.ObjectID) (object.Prefix, []byte, error) {
+	prefix, rc, err := r.OpenObject(id)
+	if err != nil {
+		return object.Prefix{}, nil, err
+	}
+	defer rc.Close()
+	data := make([]byte, int(prefix.Size))
+	if _, err := io.ReadFull(rc, data); err != nil {
+		return object.Prefix{}, nil, fmt.Errorf("read git object %v: %w", id, err)
+	}
+	return prefix, data, nil
+}
+
+// ReadBlob reads the content of the blob with the given ID.
+func (r *Repository) ReadBlob(id githash.ObjectID) ([]byte, error) {
+	return r.readTyped(id, object.TypeBlob)
+}
+
+// ReadCommit reads and parses the commit with the given ID.
+func (r *Repository) ReadCommit(id githash.ObjectID) (*object.Commit, error) {
+	data, err := r.readTyped(id, object.TypeCommit)
+	if err != nil {
+		return nil, err
+	}
+	c, err := object.ParseCommit(data)
+	if err != nil {
+		return nil, fmt.Errorf("read git commit %v: %w", id, err)
+	}
+	return c, nil
+}
+
+// ReadTree reads and parses the tree with the given ID.
+func (r *Repository) ReadTree(id githash.ObjectID) (object.Tree, error) {
+	data, err := r.readTyped(id, object.TypeTree)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := object.ParseTreeFormat(data, r.format)
+	if err != nil {
+		return nil, fmt.Errorf("read git tree %v: %w", id, err)
+	}
+	return tree, nil
+}
+
+// ReadTag reads and parses the annotated tag with the given ID.
+func (r *Repository) ReadTag(id githash.ObjectID) (*object.Tag, error) {
+	data, err := r.readTyped(id, object.TypeTag)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := object.ParseTag(data)
+	if err != nil {
+		return nil, fmt.Errorf("read git tag %v: %w", id, err)
+	}
+	return tag, nil
+}
+
+func (r *Repository) readTyped(id githash.ObjectID, want object.Type) ([]byte, error) {
+	prefix, data, err := r.ReadObject(id)
+	if err != nil {
+		return nil, err
+	}
+	if prefix.Type != want {
+		return nil, fmt.Errorf("read git %v %v: object is a %v", want, id, prefix.Type)
+	}
+	return data, nil
+}
+
+// looseObjectPath returns the path of the loose object with the given ID
+// inside objectsDir.
+func looseObjectPath(objectsDir string, id githash.ObjectID) string {
+	hex := id.String()
+	return filepath.Join(objectsDir, hex[:2], hex[2:])
+}
+
+// openLoose opens a zlib-compressed loose object.
+// See https://git-scm.com/book/en/v2/Git-Internals-Git-Objects#_object_storage
+func (r *Repository) openLoose(id githash.ObjectID) (object.Prefix, io.ReadCloser, error) {
+	for _, dir := range r.objectDirs {
+		f, err := os.Open(looseObjectPath(dir, id))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return object.Prefix{}, nil, err
+		}
+		zr, err := zlib.NewReader(bufio.NewReader(f))
+		if err != nil {
+			f.Close()
+			return object.Prefix{}, nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		br := bufio.NewReader(zr)
+		header, err := br.ReadSlice(0)
+		if err != nil {
+			zr.Close()
+			f.Close()
+			return object.Prefix{}, nil, fmt.Errorf("%s: read header: %w", f.Name(), err)
+		}
+		var prefix object.Prefix
+		if err := prefix.UnmarshalBinary(header); err != nil {
+			zr.Close()
+			f.Close()
+			return object.Prefix{}, nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		return prefix, &objectReader{
+			r:         br,
+			remaining: prefix.Size,
+			close: func() error {
+				zr.Close()
+				return f.Close()
+			},
+		}, nil
+	}
+	return object.Prefix{}, nil, fs.ErrNotExist
+}
+
+// objectReader reads exactly the number of bytes in an object.
+type objectReader struct {
+	r         io.Reader
+	remaining int64
+	close     func() error
+}
+
+func (or *objectReader) Read(p []byte) (int, error) {
+	if or.r == nil {
+		return 0, errors.New("read from closed object")
+	}
+	if or.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > or.remaining {
+		p = p[:or.remaining]
+	}
+	n, err := or.r.Read(p)
+	or.remaining -= int64(n)
+	if err == io.EOF {
+		if or.remaining > 0 {
+			err = io.ErrUnexpectedEOF
+		} else if n > 0 {
+			err = nil
+		}
+	}
+	return n, err
+}
+
+func (or *objectReader) Close() error {
+	if or.r == nil {
+		return nil
+	}
+	or.r = nil
+	return or.close()
+}
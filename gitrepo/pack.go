@@ -0,0 +1,311 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitrepo
+
+import (
+	"bufio"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+	"gg-scm.io/pkg/git/packfile"
+)
+
+// pack is an open packfile and its index.
+type pack struct {
+	indexPath string
+	index     *packfile.Index
+	f         *os.File
+	size      int64
+}
+
+// reader returns a new buffered reader for the packfile.
+// It is safe to call concurrently.
+func (p *pack) reader() *packfile.BufferedReadSeeker {
+	return packfile.NewBufferedReadSeeker(io.NewSectionReader(p.f, 0, p.size))
+}
+
+// stat returns the type and size of the object at the given offset
+// without decompressing the whole object.
+func (p *pack) stat(offset int64) (object.Prefix, error) {
+	f := p.reader()
+	typ, err := packfile.ResolveType(f, offset, &packfile.UndeltifyOptions{Index: p.index})
+	if err != nil {
+		return object.Prefix{}, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return object.Prefix{}, err
+	}
+	hdr, err := packfile.ReadHeaderFormat(offset, f, p.index.ObjectFormat())
+	if err != nil {
+		return object.Prefix{}, err
+	}
+	if hdr.Type.NonDelta() != "" {
+		return object.Prefix{Type: typ, Size: hdr.Size}, nil
+	}
+	// Deltified objects record their expanded size at the start of the
+	// delta instructions.
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return object.Prefix{}, fmt.Errorf("packfile %s: object at %d: %w", p.f.Name(), offset, err)
+	}
+	defer zr.Close()
+	size, err := readDeltaTargetSize(bufio.NewReaderSize(zr, 32))
+	if err != nil {
+		return object.Prefix{}, fmt.Errorf("packfile %s: object at %d: %w", p.f.Name(), offset, err)
+	}
+	return object.Prefix{Type: typ, Size: size}, nil
+}
+
+// readDeltaTargetSize reads the header of a delta and returns the size of the
+// object produced by the delta.
+// See https://git-scm.com/docs/pack-format#_deltified_representation
+func readDeltaTargetSize(r io.ByteReader) (int64, error) {
+	if _, err := readDeltaSize(r); err != nil {
+		return 0, fmt.Errorf("read delta header: %w", err)
+	}
+	n, err := readDeltaSize(r)
+	if err != nil {
+		return 0, fmt.Errorf("read delta header: %w", err)
+	}
+	return n, nil
+}
+
+func readDeltaSize(r io.ByteReader) (int64, error) {
+	var n uint64
+	for shift := 0; ; shift += 7 {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		if shift > 56 {
+			return 0, errors.New("size too large")
+		}
+		n |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return int64(n), nil
+		}
+	}
+}
+
+// openPacked returns a reader for the object at the given offset in p.
+func (r *Repository) openPacked(p *pack, offset int64) (object.Prefix, io.ReadCloser, error) {
+	u := r.undeltifiers.Get().(*packfile.Undeltifier)
+	prefix, rd, err := u.Undeltify(p.reader(), offset, &packfile.UndeltifyOptions{Index: p.index})
+	if err != nil {
+		r.undeltifiers.Put(u)
+		return object.Prefix{}, nil, err
+	}
+	return prefix, &objectReader{
+		r:         rd,
+		remaining: prefix.Size,
+		close: func() error {
+			// The reader returned by Undeltify uses the Undeltifier's buffers,
+			// so the Undeltifier can only be reused once the reader is closed.
+			r.undeltifiers.Put(u)
+			return nil
+		},
+	}, nil
+}
+
+// findPacked searches the repository's packfiles for the given object,
+// scanning the pack directories if they haven't been scanned yet.
+// It returns a nil pack if the object is not found.
+func (r *Repository) findPacked(id githash.ObjectID) (*pack, int64, error) {
+	r.packMu.RLock()
+	loaded := r.packsLoaded
+	if loaded {
+		p, offset := searchPacks(r.packs, id)
+		r.packMu.RUnlock()
+		return p, offset, nil
+	}
+	r.packMu.RUnlock()
+	return r.findPackedAfterRescan(id)
+}
+
+// findPackedAfterRescan scans the pack directories for new packfiles before
+// searching for the given object. The pack directories are only scanned
+// if they have changed since the last scan.
+func (r *Repository) findPackedAfterRescan(id githash.ObjectID) (*pack, int64, error) {
+	stats, err := r.statPackDirs()
+	if err != nil {
+		return nil, 0, err
+	}
+	r.packMu.RLock()
+	if r.packsLoaded && packDirsMatch(r.packDirStats, stats) {
+		p, offset := searchPacks(r.packs, id)
+		r.packMu.RUnlock()
+		return p, offset, nil
+	}
+	r.packMu.RUnlock()
+
+	r.packMu.Lock()
+	defer r.packMu.Unlock()
+	if !r.packsLoaded || !packDirsMatch(r.packDirStats, stats) {
+		if err := r.rescanPacks(); err != nil {
+			return nil, 0, err
+		}
+	}
+	p, offset := searchPacks(r.packs, id)
+	return p, offset, nil
+}
+
+// statPackDirs returns the statValidity of each object directory's
+// pack directory.
+func (r *Repository) statPackDirs() ([]statValidity, error) {
+	stats := make([]statValidity, 0, len(r.objectDirs))
+	for _, dir := range r.objectDirs {
+		sv, err := statPath(filepath.Join(dir, "pack"))
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, sv)
+	}
+	return stats, nil
+}
+
+// packDirsMatch reports whether the pack directories are unchanged
+// since old was recorded. A nil old never matches.
+func packDirsMatch(old, stats []statValidity) bool {
+	if old == nil || len(old) != len(stats) {
+		return false
+	}
+	for i := range old {
+		if !old[i].matches(stats[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func searchPacks(packs []*pack, id githash.ObjectID) (*pack, int64) {
+	for _, p := range packs {
+		if i := p.index.FindID(id); i != -1 {
+			return p, p.index.Offsets[i]
+		}
+	}
+	return nil, 0
+}
+
+// rescanPacks updates r.packs to reflect the packfiles currently on disk.
+// The caller must be holding onto r.packMu for writing.
+func (r *Repository) rescanPacks() error {
+	// Stat the directories before listing them
+	// so that any changes made during the scan cause another scan.
+	stats, err := r.statPackDirs()
+	if err != nil {
+		return err
+	}
+	for _, sv := range stats {
+		if sv.isRacy() {
+			stats = nil
+			break
+		}
+	}
+	var indexPaths []string
+	for _, dir := range r.objectDirs {
+		listing, err := os.ReadDir(filepath.Join(dir, "pack"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, ent := range listing {
+			if name := ent.Name(); strings.HasPrefix(name, "pack-") && strings.HasSuffix(name, ".idx") {
+				indexPaths = append(indexPaths, filepath.Join(dir, "pack", name))
+			}
+		}
+	}
+
+	existing := make(map[string]*pack, len(r.packs))
+	for _, p := range r.packs {
+		existing[p.indexPath] = p
+	}
+	newPacks := make([]*pack, 0, len(indexPaths))
+	for _, indexPath := range indexPaths {
+		if p := existing[indexPath]; p != nil {
+			newPacks = append(newPacks, p)
+			delete(existing, indexPath)
+			continue
+		}
+		p, err := openPack(indexPath, r.format)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Packfile is being written or removed.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		newPacks = append(newPacks, p)
+	}
+	// Search larger packs first, since they are more likely to contain
+	// a requested object.
+	sort.SliceStable(newPacks, func(i, j int) bool {
+		return newPacks[i].index.Len() > newPacks[j].index.Len()
+	})
+
+	// Readers may still be using packfiles that were removed,
+	// so don't close them until the repository is closed.
+	for _, p := range existing {
+		r.retired = append(r.retired, p)
+	}
+	r.packs = newPacks
+	r.packsLoaded = true
+	r.packDirStats = stats
+	return nil
+}
+
+// openPack reads a packfile index and opens its corresponding packfile.
+func openPack(indexPath string, format githash.ObjectFormat) (*pack, error) {
+	indexFile, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := packfile.ReadIndexFormat(bufio.NewReader(indexFile), format)
+	indexFile.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", indexPath, err)
+	}
+	packPath := strings.TrimSuffix(indexPath, ".idx") + ".pack"
+	f, err := os.Open(packPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &pack{
+		indexPath: indexPath,
+		index:     idx,
+		f:         f,
+		size:      info.Size(),
+	}, nil
+}
@@ -0,0 +1,341 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gg-scm.io/pkg/git/githash"
+)
+
+// A Ref is a reference read from the repository.
+type Ref struct {
+	Name githash.Ref
+	// ObjectID is the object that the ref points to after following any
+	// symbolic refs. It is the zero ObjectID if Name is a symbolic ref to
+	// a ref that does not exist, like HEAD in a newly initialized repository.
+	ObjectID githash.ObjectID
+	// SymrefTarget is the ref that Name points to if Name is a symbolic ref.
+	SymrefTarget githash.Ref
+}
+
+// maxSymrefDepth is the maximum number of symbolic refs that will be followed.
+// This matches Git's SYMREF_MAXDEPTH.
+const maxSymrefDepth = 5
+
+// Head reads the repository's HEAD ref.
+func (r *Repository) Head() (*Ref, error) {
+	return r.ReadRef(githash.Head)
+}
+
+// ReadRef reads the ref with the given name, following symbolic refs.
+// If the ref does not exist, ReadRef returns an error for which
+// errors.Is(err, fs.ErrNotExist) reports true.
+func (r *Repository) ReadRef(name githash.Ref) (*Ref, error) {
+	if !name.IsValid() {
+		return nil, fmt.Errorf("read ref %q: invalid name", name)
+	}
+	packed, err := r.readPackedRefs()
+	if err != nil {
+		return nil, fmt.Errorf("read ref %v: %w", name, err)
+	}
+	val, err := r.readRefValue(name, packed)
+	if err != nil {
+		return nil, fmt.Errorf("read ref %v: %w", name, err)
+	}
+	if val == nil {
+		return nil, notExistError(fmt.Sprintf("read ref %v: not found", name))
+	}
+	ref := &Ref{
+		Name:         name,
+		ObjectID:     val.id,
+		SymrefTarget: val.symref,
+	}
+	if ref.SymrefTarget != "" {
+		ref.ObjectID, err = r.resolveSymref(ref.SymrefTarget, packed)
+		if err != nil {
+			return nil, fmt.Errorf("read ref %v: %w", name, err)
+		}
+	}
+	return ref, nil
+}
+
+// ListRefs returns the repository's refs, including HEAD.
+// If refPrefixes is given, then only refs that start with one of the given
+// strings are returned.
+func (r *Repository) ListRefs(refPrefixes ...string) (map[githash.Ref]*Ref, error) {
+	packed, err := r.readPackedRefs()
+	if err != nil {
+		return nil, fmt.Errorf("list refs: %w", err)
+	}
+	names := make(map[githash.Ref]struct{})
+	if matchesRefPrefixes(githash.Head, refPrefixes) {
+		names[githash.Head] = struct{}{}
+	}
+	for name := range packed.refs {
+		if matchesRefPrefixes(name, refPrefixes) {
+			names[name] = struct{}{}
+		}
+	}
+	refsDirs := []string{filepath.Join(r.commonDir, "refs")}
+	if r.gitDir != r.commonDir {
+		refsDirs = append(refsDirs, filepath.Join(r.gitDir, "refs"))
+	}
+	for _, dir := range refsDirs {
+		err := filepath.WalkDir(dir, func(path string, ent fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if ent.IsDir() || strings.HasSuffix(path, ".lock") {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			name := githash.Ref("refs/" + filepath.ToSlash(rel))
+			if name.IsValid() && matchesRefPrefixes(name, refPrefixes) {
+				names[name] = struct{}{}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list refs: %w", err)
+		}
+	}
+
+	refs := make(map[githash.Ref]*Ref, len(names))
+	for name := range names {
+		val, err := r.readRefValue(name, packed)
+		if err != nil {
+			return nil, fmt.Errorf("list refs: %v: %w", name, err)
+		}
+		if val == nil {
+			// Deleted since the directory was read.
+			continue
+		}
+		ref := &Ref{
+			Name:         name,
+			ObjectID:     val.id,
+			SymrefTarget: val.symref,
+		}
+		if ref.SymrefTarget != "" {
+			ref.ObjectID, err = r.resolveSymref(ref.SymrefTarget, packed)
+			if err != nil {
+				return nil, fmt.Errorf("list refs: %v: %w", name, err)
+			}
+		}
+		refs[name] = ref
+	}
+	return refs, nil
+}
+
+func matchesRefPrefixes(name githash.Ref, refPrefixes []string) bool {
+	if len(refPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range refPrefixes {
+		if strings.HasPrefix(string(name), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// refValue is the unresolved value of a ref.
+// Exactly one of id or symref is set.
+type refValue struct {
+	id     githash.ObjectID
+	symref githash.Ref
+}
+
+// resolveSymref follows a chain of symbolic refs starting at target.
+// It returns the zero ObjectID if the chain ends at a ref that does not exist.
+func (r *Repository) resolveSymref(target githash.Ref, packed *packedRefs) (githash.ObjectID, error) {
+	for i := 0; i < maxSymrefDepth; i++ {
+		val, err := r.readRefValue(target, packed)
+		if err != nil {
+			return githash.ObjectID{}, err
+		}
+		if val == nil {
+			return githash.ObjectID{}, nil
+		}
+		if val.symref == "" {
+			return val.id, nil
+		}
+		target = val.symref
+	}
+	return githash.ObjectID{}, fmt.Errorf("symbolic refs nested too deeply")
+}
+
+// readRefValue reads a ref from its loose file or, failing that,
+// from the packed refs. It returns nil if the ref does not exist.
+func (r *Repository) readRefValue(name githash.Ref, packed *packedRefs) (*refValue, error) {
+	data, err := os.ReadFile(filepath.Join(r.refDir(name), filepath.FromSlash(string(name))))
+	if errors.Is(err, fs.ErrNotExist) || isDirError(err) {
+		id, ok := packed.refs[name]
+		if !ok {
+			return nil, nil
+		}
+		return &refValue{id: id}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	line := string(bytes.TrimRight(data, "\r\n"))
+	const symrefPrefix = "ref: "
+	if strings.HasPrefix(line, symrefPrefix) {
+		target := githash.Ref(strings.TrimSpace(line[len(symrefPrefix):]))
+		if !target.IsValid() {
+			return nil, fmt.Errorf("invalid symbolic ref target %q", target)
+		}
+		return &refValue{symref: target}, nil
+	}
+	id, err := githash.ParseObjectID(line)
+	if err != nil {
+		return nil, err
+	}
+	if id.ObjectFormat() != r.format {
+		return nil, fmt.Errorf("object ID %v is not %v", id, r.format)
+	}
+	return &refValue{id: id}, nil
+}
+
+// isDirError reports whether err is the result of reading a directory
+// as a file. This happens when looking up a ref like "refs/heads"
+// or "refs/heads/foo" when "refs/heads/foo/bar" exists.
+func isDirError(err error) bool {
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		return false
+	}
+	info, statErr := os.Stat(pathErr.Path)
+	return statErr == nil && info.IsDir()
+}
+
+// refDir returns the directory that stores the given ref.
+// Refs like HEAD are specific to a working tree
+// while most refs are shared among all working trees.
+// See https://git-scm.com/docs/git-worktree#_refs
+func (r *Repository) refDir(name githash.Ref) string {
+	if !strings.HasPrefix(string(name), "refs/") ||
+		strings.HasPrefix(string(name), "refs/worktree/") ||
+		strings.HasPrefix(string(name), "refs/bisect/") ||
+		strings.HasPrefix(string(name), "refs/rewritten/") {
+		return r.gitDir
+	}
+	return r.commonDir
+}
+
+// packedRefs is the parsed content of a packed-refs file.
+type packedRefs struct {
+	refs map[githash.Ref]githash.ObjectID
+}
+
+// readPackedRefs reads the repository's packed-refs file.
+// The parsed file is cached until the file's stat information changes.
+// The returned packedRefs must not be modified.
+// See https://git-scm.com/docs/git-pack-refs
+func (r *Repository) readPackedRefs() (*packedRefs, error) {
+	path := filepath.Join(r.commonDir, "packed-refs")
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return new(packedRefs), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	stats := statValidity{info}
+
+	r.packedRefsMu.Lock()
+	defer r.packedRefsMu.Unlock()
+	if r.packedRefs != nil && r.packedRefsStats.matches(stats) {
+		return r.packedRefs, nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	packed, err := parsePackedRefs(data, r.format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if stats.isRacy() {
+		r.packedRefs = nil
+	} else {
+		r.packedRefs = packed
+		r.packedRefsStats = stats
+	}
+	return packed, nil
+}
+
+func parsePackedRefs(data []byte, format githash.ObjectFormat) (*packedRefs, error) {
+	packed := &packedRefs{
+		refs: make(map[githash.Ref]githash.ObjectID),
+	}
+	var lastRef githash.Ref
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for lineno := 1; s.Scan(); lineno++ {
+		line := s.Text()
+		switch {
+		case line == "" || line[0] == '#':
+			// Header or blank line.
+		case line[0] == '^':
+			// Peeled tag. Ignored, since readers can peel the tag themselves.
+			if lastRef == "" {
+				return nil, fmt.Errorf("line %d: peeled object without ref", lineno)
+			}
+		default:
+			hexID, name, ok := strings.Cut(line, " ")
+			if !ok {
+				return nil, fmt.Errorf("line %d: missing space", lineno)
+			}
+			id, err := githash.ParseObjectID(hexID)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineno, err)
+			}
+			if id.ObjectFormat() != format {
+				return nil, fmt.Errorf("line %d: object ID is not %v", lineno, format)
+			}
+			lastRef = githash.Ref(name)
+			if !lastRef.IsValid() {
+				return nil, fmt.Errorf("line %d: invalid ref %q", lineno, name)
+			}
+			packed.refs[lastRef] = id
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return packed, nil
+}
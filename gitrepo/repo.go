@@ -0,0 +1,335 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package gitrepo provides access to a Git repository on the local
+filesystem without running Git. It reads loose objects, packfiles, and refs
+directly from the repository's directory using the formats described in
+https://git-scm.com/docs/gitrepository-layout,
+and it can write new loose objects.
+
+A Repository is safe to use from multiple goroutines simultaneously.
+*/
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/packfile"
+)
+
+// Repository is a handle to a Git repository on the local filesystem.
+type Repository struct {
+	gitDir     string
+	commonDir  string
+	objectDirs []string
+	format     githash.ObjectFormat
+
+	undeltifiers sync.Pool
+
+	packMu       sync.RWMutex
+	packs        []*pack
+	packsLoaded  bool
+	packDirStats []statValidity // one per object directory
+	retired      []*pack
+
+	packedRefsMu    sync.Mutex
+	packedRefs      *packedRefs
+	packedRefsStats statValidity
+}
+
+// statValidity records the stat information of a file or directory
+// so that later changes can be detected, like Git's struct stat_validity.
+// The zero value matches a file that does not exist.
+type statValidity struct {
+	info fs.FileInfo
+}
+
+// statPath returns the statValidity for the given path.
+func statPath(path string) (statValidity, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return statValidity{}, nil
+	}
+	if err != nil {
+		return statValidity{}, err
+	}
+	return statValidity{info}, nil
+}
+
+// matches reports whether sv and sv2 describe the same, unchanged file.
+func (sv statValidity) matches(sv2 statValidity) bool {
+	if sv.info == nil || sv2.info == nil {
+		return sv.info == nil && sv2.info == nil
+	}
+	return os.SameFile(sv.info, sv2.info) &&
+		sv.info.ModTime().Equal(sv2.info.ModTime()) &&
+		sv.info.Size() == sv2.info.Size()
+}
+
+// isRacy reports whether the file was modified so recently
+// that a later modification could leave its modification time unchanged
+// on filesystems with coarse timestamps.
+func (sv statValidity) isRacy() bool {
+	return sv.info != nil && time.Since(sv.info.ModTime()) < racyThreshold
+}
+
+// racyThreshold is the age below which a modification time
+// is not trusted to detect later changes.
+const racyThreshold = 2 * time.Second
+
+// Open opens the Git repository at the given path. dir may be either the top
+// of a working copy, a working copy's .git directory, or a bare repository.
+func Open(dir string) (*Repository, error) {
+	gitDir, err := findGitDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open git repository %s: %w", dir, err)
+	}
+	commonDir, err := readCommonDir(gitDir)
+	if err != nil {
+		return nil, fmt.Errorf("open git repository %s: %w", dir, err)
+	}
+	format, err := readObjectFormat(filepath.Join(commonDir, "config"))
+	if err != nil {
+		return nil, fmt.Errorf("open git repository %s: %w", dir, err)
+	}
+	objectsDir := filepath.Join(commonDir, "objects")
+	objectDirs, err := readAlternates(objectsDir)
+	if err != nil {
+		return nil, fmt.Errorf("open git repository %s: %w", dir, err)
+	}
+	r := &Repository{
+		gitDir:     gitDir,
+		commonDir:  commonDir,
+		objectDirs: objectDirs,
+		format:     format,
+	}
+	r.undeltifiers.New = func() any { return new(packfile.Undeltifier) }
+	return r, nil
+}
+
+// Close releases any open files associated with the repository.
+func (r *Repository) Close() error {
+	r.packMu.Lock()
+	defer r.packMu.Unlock()
+	var firstErr error
+	for _, p := range r.packs {
+		if err := p.f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, p := range r.retired {
+		if err := p.f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.packs = nil
+	r.retired = nil
+	r.packsLoaded = false
+	r.packDirStats = nil
+	return firstErr
+}
+
+// Dir returns the path to the repository's Git directory
+// (e.g. the .git directory of a working copy).
+func (r *Repository) Dir() string {
+	return r.gitDir
+}
+
+// ObjectFormat returns the hash algorithm that the repository uses to name
+// its objects.
+func (r *Repository) ObjectFormat() githash.ObjectFormat {
+	return r.format
+}
+
+// findGitDir returns the Git directory for the given path.
+func findGitDir(dir string) (string, error) {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	switch {
+	case err == nil && info.IsDir():
+		return dotGit, nil
+	case err == nil:
+		// Linked working trees and submodules use a file that points to the
+		// actual Git directory.
+		data, err := os.ReadFile(dotGit)
+		if err != nil {
+			return "", err
+		}
+		const prefix = "gitdir: "
+		line := strings.TrimRight(string(data), "\r\n")
+		if !strings.HasPrefix(line, prefix) {
+			return "", fmt.Errorf("%s: missing %q", dotGit, prefix)
+		}
+		gitDir := line[len(prefix):]
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(dir, gitDir)
+		}
+		return gitDir, nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	}
+	if !isGitDir(dir) {
+		return "", errors.New("not a git repository")
+	}
+	return dir, nil
+}
+
+// isGitDir reports whether dir looks like a Git directory.
+func isGitDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(dir, "commondir")); err == nil {
+		return true
+	}
+	info, err := os.Stat(filepath.Join(dir, "objects"))
+	return err == nil && info.IsDir()
+}
+
+// readCommonDir returns the directory that holds the objects and refs shared
+// among all the working trees of a repository.
+func readCommonDir(gitDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return gitDir, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	commonDir := strings.TrimRight(string(data), "\r\n")
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return commonDir, nil
+}
+
+// readObjectFormat reads the extensions.objectFormat setting
+// from the repository's configuration file.
+func readObjectFormat(path string) (githash.ObjectFormat, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return githash.SHA1Format, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	value, found := findConfigValue(data, "extensions", "objectformat")
+	if !found {
+		return githash.SHA1Format, nil
+	}
+	format, err := githash.ParseObjectFormat(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: extensions.objectFormat: %w", path, err)
+	}
+	return format, nil
+}
+
+// findConfigValue returns the last value of the given setting
+// in a Git configuration file. section and key must be lowercase.
+// It only understands the subset of the configuration syntax
+// that Git uses when writing a repository's configuration file:
+// subsection names and line continuations are not supported.
+func findConfigValue(data []byte, section, key string) (value string, found bool) {
+	currSection := ""
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end == -1 {
+				continue
+			}
+			currSection = strings.ToLower(strings.TrimSpace(line[1:end]))
+			continue
+		}
+		if currSection != section {
+			continue
+		}
+		k, v, hasValue := strings.Cut(line, "=")
+		if !strings.EqualFold(strings.TrimSpace(k), key) {
+			continue
+		}
+		if !hasValue {
+			value, found = "true", true
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if i := strings.IndexAny(v, "#;"); i != -1 {
+			v = strings.TrimSpace(v[:i])
+		}
+		value, found = strings.Trim(v, `"`), true
+	}
+	return value, found
+}
+
+// readAlternates returns the list of object directories to search,
+// starting with objectsDir itself.
+// See https://git-scm.com/docs/gitrepository-layout#Documentation/gitrepository-layout.txt-objectsinfoalternates
+func readAlternates(objectsDir string) ([]string, error) {
+	dirs := []string{objectsDir}
+	seen := map[string]struct{}{objectsDir: {}}
+	for i := 0; i < len(dirs); i++ {
+		data, err := os.ReadFile(filepath.Join(dirs[i], "info", "alternates"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || line[0] == '#' {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(dirs[i], line)
+			}
+			line = filepath.Clean(line)
+			if _, dup := seen[line]; dup {
+				continue
+			}
+			seen[line] = struct{}{}
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs, nil
+}
+
+// notExistError is an error for a missing object or ref.
+// It matches fs.ErrNotExist with errors.Is.
+type notExistError string
+
+func (e notExistError) Error() string {
+	return string(e)
+}
+
+func (e notExistError) Is(target error) bool {
+	return target == fs.ErrNotExist
+}
@@ -0,0 +1,459 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRepository(t *testing.T) {
+	for _, format := range []githash.ObjectFormat{githash.SHA1Format, githash.SHA256Format} {
+		t.Run(format.String(), func(t *testing.T) {
+			ctx := context.Background()
+			g, dir := newTestRepository(ctx, t, format)
+
+			r, err := Open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if err := r.Close(); err != nil {
+					t.Error("Close:", err)
+				}
+			}()
+			if got := r.ObjectFormat(); got != format {
+				t.Errorf("ObjectFormat() = %v; want %v", got, format)
+			}
+			if want := filepath.Join(dir, ".git"); r.Dir() != want {
+				t.Errorf("Dir() = %q; want %q", r.Dir(), want)
+			}
+
+			t.Run("Loose", func(t *testing.T) {
+				checkObjects(ctx, t, g, r)
+				checkRefs(ctx, t, g, r)
+			})
+			if err := g.Run(ctx, "gc", "--quiet", "--aggressive", "--prune=now"); err != nil {
+				t.Fatal(err)
+			}
+			t.Run("Packed", func(t *testing.T) {
+				checkObjects(ctx, t, g, r)
+				checkRefs(ctx, t, g, r)
+			})
+			t.Run("Concurrent", func(t *testing.T) {
+				head, err := r.Head()
+				if err != nil {
+					t.Fatal(err)
+				}
+				var wg sync.WaitGroup
+				for i := 0; i < 8; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for id := head.ObjectID; !id.IsZero(); {
+							c, err := r.ReadCommit(id)
+							if err != nil {
+								t.Error(err)
+								return
+							}
+							if _, err := r.ReadTree(c.Tree); err != nil {
+								t.Error(err)
+								return
+							}
+							id = githash.ObjectID{}
+							if len(c.Parents) > 0 {
+								id = c.Parents[0]
+							}
+						}
+					}()
+				}
+				wg.Wait()
+			})
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	g, dir := newTestRepository(ctx, t, githash.SHA1Format)
+	bareDir := t.TempDir()
+	if err := g.Run(ctx, "clone", "--quiet", "--bare", dir, bareDir); err != nil {
+		t.Fatal(err)
+	}
+	head, err := g.Head(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+	}{
+		{"WorkingCopy", dir},
+		{"GitDir", filepath.Join(dir, ".git")},
+		{"Bare", bareDir},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := Open(test.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			got, err := r.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ObjectID != head.Commit {
+				t.Errorf("Head().ObjectID = %v; want %v", got.ObjectID, head.Commit)
+			}
+		})
+	}
+
+	t.Run("NotARepository", func(t *testing.T) {
+		r, err := Open(t.TempDir())
+		if err == nil {
+			r.Close()
+			t.Fatal("Open did not return an error")
+		}
+		t.Log("Error:", err)
+	})
+}
+
+func TestReadRefUnborn(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	if err := g.Init(ctx, "."); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !head.ObjectID.IsZero() || head.SymrefTarget == "" {
+		t.Errorf("Head() = %+v; want symref to unborn branch", head)
+	}
+	if _, err := r.ReadRef(head.SymrefTarget); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadRef(%q) error = %v; want fs.ErrNotExist", head.SymrefTarget, err)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	g, dir := newTestRepository(ctx, t, githash.SHA1Format)
+	if err := g.Run(ctx, "gc", "--quiet", "--prune=now"); err != nil {
+		t.Fatal(err)
+	}
+	// Move the modification times into the past
+	// so that the repository caches the packed refs and packfile list.
+	gitDir := filepath.Join(dir, ".git")
+	past := time.Now().Add(-time.Hour)
+	for _, path := range []string{filepath.Join(gitDir, "packed-refs"), filepath.Join(gitDir, "objects", "pack")} {
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.Head(); err != nil {
+		t.Fatal(err)
+	}
+	missing := githash.SHA1{0xff}.ObjectID()
+	if _, err := r.Stat(missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat(%v) error = %v; want fs.ErrNotExist", missing, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("Hello, World!\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Add(ctx, []git.Pathspec{"new.txt"}, git.AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Commit(ctx, "Add new.txt", git.CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Run(ctx, "pack-refs", "--all"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Run(ctx, "repack", "-d", "--quiet"); err != nil {
+		t.Fatal(err)
+	}
+	want, err := g.Head(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ObjectID != want.Commit {
+		t.Errorf("Head().ObjectID = %v; want %v", got.ObjectID, want.Commit)
+	}
+	if _, err := r.ReadCommit(want.Commit); err != nil {
+		t.Error(err)
+	}
+}
+
+// newTestRepository creates a repository with a few commits, branches, and
+// tags in a new temporary directory.
+func newTestRepository(ctx context.Context, tb testing.TB, format githash.ObjectFormat) (*git.Git, string) {
+	tb.Helper()
+	localGit, err := git.NewLocal(git.Options{
+		Env: []string{
+			"GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Octocat",
+			"GIT_AUTHOR_EMAIL=octocat@example.com",
+			"GIT_COMMITTER_NAME=Octocat",
+			"GIT_COMMITTER_EMAIL=octocat@example.com",
+		},
+	})
+	if err != nil {
+		tb.Skip("Can't find Git, skipping:", err)
+	}
+	dir := tb.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	if err := g.Run(ctx, "init", "--quiet", "--object-format="+format.String(), "."); err != nil {
+		tb.Skip("Git does not support", format, "repositories:", err)
+	}
+	// Build a file that changes slightly in each commit
+	// so that packing produces deltified objects.
+	var content strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&content, "Line %d of a file that is long enough to be worth deltifying.\n", i)
+	}
+	commitTime := time.Date(2020, time.January, 9, 14, 50, 0, 0, time.FixedZone("-0800", -8*60*60))
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(&content, "Change %d\n", i)
+		if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte(content.String()), 0o666); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o777); err != nil {
+			tb.Fatal(err)
+		}
+		name := filepath.Join(dir, "sub", "n"+strconv.Itoa(i)+".txt")
+		if err := os.WriteFile(name, []byte(strconv.Itoa(i)+"\n"), 0o666); err != nil {
+			tb.Fatal(err)
+		}
+		if err := g.Add(ctx, []git.Pathspec{"."}, git.AddOptions{}); err != nil {
+			tb.Fatal(err)
+		}
+		commitTime = commitTime.Add(time.Hour)
+		err := g.Commit(ctx, fmt.Sprintf("Commit %d", i), git.CommitOptions{
+			AuthorTime: commitTime,
+			CommitTime: commitTime,
+		})
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if i == 2 {
+			if err := g.NewBranch(ctx, "feature", git.BranchOptions{}); err != nil {
+				tb.Fatal(err)
+			}
+			if err := g.Run(ctx, "tag", "lightweight"); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+	if err := g.Run(ctx, "tag", "-a", "-m", "Annotated", "annotated"); err != nil {
+		tb.Fatal(err)
+	}
+	if err := g.Run(ctx, "symbolic-ref", "refs/heads/alias", "refs/heads/feature"); err != nil {
+		tb.Fatal(err)
+	}
+	return g, dir
+}
+
+// checkObjects verifies that r reads every object in the repository
+// the same way that git cat-file does.
+func checkObjects(ctx context.Context, t *testing.T, g *git.Git, r *Repository) {
+	t.Helper()
+	out, err := g.Output(ctx, "cat-file", "--batch-all-objects", "--batch-check=%(objectname) %(objecttype) %(objectsize)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) < 10 {
+		t.Fatalf("only %d objects in repository", len(lines))
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		id, err := githash.ParseObjectID(fields[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := object.Prefix{Type: object.Type(fields[1]), Size: size}
+
+		if got, err := r.Stat(id); err != nil {
+			t.Errorf("Stat(%v): %v", id, err)
+		} else if got != want {
+			t.Errorf("Stat(%v) = %v; want %v", id, got, want)
+		}
+		if ok, err := r.HasObject(id); !ok || err != nil {
+			t.Errorf("HasObject(%v) = %t, %v; want true, <nil>", id, ok, err)
+		}
+		wantData, err := g.Output(ctx, "cat-file", fields[1], id.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefix, rc, err := r.OpenObject(id)
+		if err != nil {
+			t.Errorf("OpenObject(%v): %v", id, err)
+			continue
+		}
+		gotData, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Errorf("read %v: %v", id, err)
+		}
+		if prefix != want {
+			t.Errorf("OpenObject(%v) prefix = %v; want %v", id, prefix, want)
+		}
+		if string(gotData) != wantData {
+			t.Errorf("OpenObject(%v) content = %q; want %q", id, gotData, wantData)
+		}
+		h := r.ObjectFormat().New()
+		h.Write(object.AppendPrefix(nil, prefix.Type, prefix.Size))
+		h.Write(gotData)
+		if got := r.ObjectFormat().Sum(h); got != id {
+			t.Errorf("hash of OpenObject(%v) = %v", id, got)
+		}
+
+		switch want.Type {
+		case object.TypeCommit:
+			if _, err := r.ReadCommit(id); err != nil {
+				t.Error(err)
+			}
+		case object.TypeTree:
+			if _, err := r.ReadTree(id); err != nil {
+				t.Error(err)
+			}
+		case object.TypeTag:
+			if _, err := r.ReadTag(id); err != nil {
+				t.Error(err)
+			}
+			if _, err := r.ReadCommit(id); err == nil {
+				t.Errorf("ReadCommit(%v) on tag did not return an error", id)
+			}
+		}
+	}
+
+	missing := r.ObjectFormat().Null()
+	if _, err := r.Stat(missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(%v) error = %v; want fs.ErrNotExist", missing, err)
+	}
+	if _, _, err := r.OpenObject(missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("OpenObject(%v) error = %v; want fs.ErrNotExist", missing, err)
+	}
+	if ok, err := r.HasObject(missing); ok || err != nil {
+		t.Errorf("HasObject(%v) = %t, %v; want false, <nil>", missing, ok, err)
+	}
+}
+
+// checkRefs verifies that r reads the same refs as git show-ref.
+func checkRefs(ctx context.Context, t *testing.T, g *git.Git, r *Repository) {
+	t.Helper()
+	out, err := g.Output(ctx, "show-ref", "--head")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[githash.Ref]*Ref)
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		hexID, name, _ := strings.Cut(line, " ")
+		id, err := githash.ParseObjectID(hexID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[githash.Ref(name)] = &Ref{Name: githash.Ref(name), ObjectID: id}
+	}
+	headRef, err := g.HeadRef(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want[githash.Head].SymrefTarget = headRef
+	want["refs/heads/alias"].SymrefTarget = "refs/heads/feature"
+
+	got, err := r.ListRefs()
+	if err != nil {
+		t.Fatal("ListRefs:", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ListRefs() (-want +got):\n%s", diff)
+	}
+
+	gotTags, err := r.ListRefs("refs/tags/")
+	if err != nil {
+		t.Fatal("ListRefs:", err)
+	}
+	wantTags := map[githash.Ref]*Ref{
+		"refs/tags/annotated":   want["refs/tags/annotated"],
+		"refs/tags/lightweight": want["refs/tags/lightweight"],
+	}
+	if diff := cmp.Diff(wantTags, gotTags); diff != "" {
+		t.Errorf("ListRefs(\"refs/tags/\") (-want +got):\n%s", diff)
+	}
+
+	for name, wantRef := range want {
+		gotRef, err := r.ReadRef(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if diff := cmp.Diff(wantRef, gotRef); diff != "" {
+			t.Errorf("ReadRef(%q) (-want +got):\n%s", name, diff)
+		}
+	}
+	if _, err := r.ReadRef("refs/heads/nonexistent"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadRef(\"refs/heads/nonexistent\") error = %v; want fs.ErrNotExist", err)
+	}
+	if _, err := r.ReadRef("refs/heads"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadRef(\"refs/heads\") error = %v; want fs.ErrNotExist", err)
+	}
+}
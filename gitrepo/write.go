@@ -0,0 +1,133 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitrepo
+
+import (
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+)
+
+// WriteObject stores an object with the given type and content
+// in the repository as a zlib-compressed loose object and returns its ID.
+// If the repository already contains the object, WriteObject does nothing.
+// The object is written to a temporary file and then renamed into place,
+// so other readers (including Git) never observe a partially written object.
+//
+// WriteObject does not check that the content is well-formed.
+func (r *Repository) WriteObject(tp object.Type, data []byte) (githash.ObjectID, error) {
+	if !tp.IsValid() {
+		return githash.ObjectID{}, fmt.Errorf("write git object: invalid type %q", tp)
+	}
+	prefix := object.AppendPrefix(nil, tp, int64(len(data)))
+	h := r.format.New()
+	h.Write(prefix)
+	h.Write(data)
+	id := r.format.Sum(h)
+	if exists, err := r.HasObject(id); err != nil {
+		return githash.ObjectID{}, fmt.Errorf("write git %v %v: %w", tp, id, err)
+	} else if exists {
+		return id, nil
+	}
+	if err := writeLoose(looseObjectPath(r.objectDirs[0], id), prefix, data); err != nil {
+		return githash.ObjectID{}, fmt.Errorf("write git %v %v: %w", tp, id, err)
+	}
+	return id, nil
+}
+
+// WriteTree serializes a tree and stores it in the repository.
+// The tree must be sorted.
+func (r *Repository) WriteTree(tree object.Tree) (githash.ObjectID, error) {
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		return githash.ObjectID{}, err
+	}
+	return r.WriteObject(object.TypeTree, data)
+}
+
+// WriteCommit serializes a commit and stores it in the repository.
+func (r *Repository) WriteCommit(c *object.Commit) (githash.ObjectID, error) {
+	data, err := c.MarshalBinary()
+	if err != nil {
+		return githash.ObjectID{}, err
+	}
+	return r.WriteObject(object.TypeCommit, data)
+}
+
+// WriteTag serializes an annotated tag and stores it in the repository.
+func (r *Repository) WriteTag(tag *object.Tag) (githash.ObjectID, error) {
+	data, err := tag.MarshalBinary()
+	if err != nil {
+		return githash.ObjectID{}, err
+	}
+	return r.WriteObject(object.TypeTag, data)
+}
+
+// writeLoose atomically writes a loose object to path.
+func writeLoose(path string, prefix, data []byte) (err error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "tmp_obj_")
+	if err != nil {
+		return err
+	}
+	temp := f.Name()
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+		if err != nil {
+			os.Remove(temp)
+		}
+	}()
+	zw := zlib.NewWriter(f)
+	if _, err := zw.Write(prefix); err != nil {
+		return err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		f = nil
+		return err
+	}
+	f = nil
+	if err := os.Chmod(temp, 0o444); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		// Another writer may have stored the same object concurrently.
+		if _, statErr := os.Stat(path); statErr == nil {
+			os.Remove(temp)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitrepo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+)
+
+func TestWriteObject(t *testing.T) {
+	for _, format := range []githash.ObjectFormat{githash.SHA1Format, githash.SHA256Format} {
+		t.Run(format.String(), func(t *testing.T) {
+			ctx := context.Background()
+			g, dir := newTestRepository(ctx, t, format)
+			r, err := Open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			head, err := r.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parent, err := r.ReadCommit(head.ObjectID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const content = "Hello, World!\n"
+			blobID, err := r.WriteObject(object.TypeBlob, []byte(content))
+			if err != nil {
+				t.Fatal("WriteObject:", err)
+			}
+			if want, _ := object.BlobSumFormat(format, strings.NewReader(content), int64(len(content))); blobID != want {
+				t.Errorf("WriteObject(blob) = %v; want %v", blobID, want)
+			}
+			// Writing the same object again should succeed without changes.
+			if again, err := r.WriteObject(object.TypeBlob, []byte(content)); err != nil {
+				t.Error("WriteObject (again):", err)
+			} else if again != blobID {
+				t.Errorf("WriteObject (again) = %v; want %v", again, blobID)
+			}
+
+			edit, err := object.EditTree(r, parent.Tree, []object.TreeEdit{
+				{Path: "new/hello.txt", Mode: object.ModePlain, ObjectID: blobID},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, tree := range edit.Trees {
+				got, err := r.WriteTree(tree)
+				if err != nil {
+					t.Fatal("WriteTree:", err)
+				}
+				if got != id {
+					t.Errorf("WriteTree(...) = %v; want %v", got, id)
+				}
+			}
+
+			commitTime := time.Date(2021, time.March, 4, 12, 0, 0, 0, time.FixedZone("+0100", 60*60))
+			commitID, err := r.WriteCommit(&object.Commit{
+				Tree:       edit.Root,
+				Parents:    []githash.ObjectID{head.ObjectID},
+				Author:     "Octocat <octocat@example.com>",
+				AuthorTime: commitTime,
+				Committer:  "Octocat <octocat@example.com>",
+				CommitTime: commitTime,
+				Message:    "Add hello.txt\n",
+			})
+			if err != nil {
+				t.Fatal("WriteCommit:", err)
+			}
+			tagID, err := r.WriteTag(&object.Tag{
+				ObjectID:   commitID,
+				ObjectType: object.TypeCommit,
+				Name:       "v1.0.0",
+				Tagger:     "Octocat <octocat@example.com>",
+				Time:       commitTime,
+				Message:    "Release\n",
+			})
+			if err != nil {
+				t.Fatal("WriteTag:", err)
+			}
+
+			// Git should be able to read everything that was written.
+			if err := g.Run(ctx, "fsck", "--strict", "--no-dangling"); err != nil {
+				t.Error(err)
+			}
+			out, err := g.Output(ctx, "show", "--format=%s", tagID.String()+":new/hello.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != content {
+				t.Errorf("git show %v:new/hello.txt = %q; want %q", tagID, out, content)
+			}
+			err = g.MutateRefs(ctx, map[git.Ref]git.RefMutation{
+				head.Name: git.SetRefIfMatches(head.ObjectID.String(), commitID.String()),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := g.CommitInfo(ctx, "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Sum(format); got != commitID {
+				t.Errorf("HEAD = %v; want %v", got, commitID)
+			}
+
+			// Objects should not be left writable or have temporary files.
+			hex := blobID.String()
+			info2, err := os.Stat(filepath.Join(dir, ".git", "objects", hex[:2], hex[2:]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info2.Mode().Perm(); perm&0o222 != 0 {
+				t.Errorf("blob permissions = %v; want read-only", perm)
+			}
+			temps, err := filepath.Glob(filepath.Join(dir, ".git", "objects", "*", "tmp_obj_*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(temps) > 0 {
+				t.Errorf("temporary files left behind: %q", temps)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package gitsign signs and verifies Git commits and tags
+without running Git or external signing programs.
+It supports OpenPGP signatures (as made by `gpg`)
+and SSH signatures (as made by `ssh-keygen -Y sign`
+with the "git" namespace).
+*/
+package gitsign
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+)
+
+// ErrUnsigned is returned when verifying an object that does not have a signature.
+var ErrUnsigned = errors.New("object is not signed")
+
+// Format is a signature format.
+type Format int
+
+// Signature formats.
+const (
+	// OpenPGP is the format used when Git's gpg.format is "openpgp".
+	OpenPGP Format = 1 + iota
+	// SSH is the format used when Git's gpg.format is "ssh".
+	SSH
+)
+
+// String returns the gpg.format name of the format.
+func (f Format) String() string {
+	switch f {
+	case OpenPGP:
+		return "openpgp"
+	case SSH:
+		return "ssh"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// Armor header lines that begin signatures.
+const (
+	openPGPSignatureHeader = "-----BEGIN PGP SIGNATURE-----"
+	sshSignatureHeader     = "-----BEGIN SSH SIGNATURE-----"
+)
+
+// A Verification describes a valid signature.
+type Verification struct {
+	Format Format
+	// Fingerprint identifies the key that made the signature.
+	// For OpenPGP signatures, it is the uppercase hex fingerprint
+	// of the primary key. For SSH signatures, it is the SHA-256 fingerprint
+	// in the format printed by `ssh-keygen -l`.
+	Fingerprint string
+	// Identities is the list of names associated with the key.
+	// For OpenPGP signatures, it is the key's user IDs.
+	// For SSH signatures, it is the principals from the allowed signers
+	// entries that match the key.
+	Identities []string
+}
+
+// A Verifier checks signatures against a set of trusted keys.
+type Verifier struct {
+	// OpenPGP is the set of trusted OpenPGP keys.
+	// If nil, all OpenPGP signatures are rejected.
+	OpenPGP *OpenPGPKeyring
+	// SSH is the set of trusted SSH keys.
+	// If nil, all SSH signatures are rejected.
+	SSH *AllowedSigners
+}
+
+// VerifyCommit checks the signature of a commit.
+// It returns an error wrapping ErrUnsigned if the commit has no signature.
+// The commit time is used to check the validity period of SSH keys.
+func (v *Verifier) VerifyCommit(c *object.Commit) (*Verification, error) {
+	sig := c.GPGSignature
+	if len(sig) == 0 {
+		if s := c.Extra.Get("gpgsig-sha256"); s != "" {
+			sig = []byte(s + "\n")
+		}
+	}
+	if len(sig) == 0 {
+		return nil, fmt.Errorf("verify commit: %w", ErrUnsigned)
+	}
+	payload, err := c.SignedPayload()
+	if err != nil {
+		return nil, fmt.Errorf("verify commit: %w", err)
+	}
+	result, err := v.Verify(payload, sig, c.CommitTime)
+	if err != nil {
+		return nil, fmt.Errorf("verify commit: %w", err)
+	}
+	return result, nil
+}
+
+// VerifyTag checks the signature of an annotated tag.
+// It returns an error wrapping ErrUnsigned if the tag has no signature.
+// The tag time is used to check the validity period of SSH keys.
+func (v *Verifier) VerifyTag(t *object.Tag) (*Verification, error) {
+	if len(t.Signature) == 0 {
+		return nil, fmt.Errorf("verify tag %s: %w", t.Name, ErrUnsigned)
+	}
+	payload, err := t.SignedPayload()
+	if err != nil {
+		return nil, fmt.Errorf("verify tag %s: %w", t.Name, err)
+	}
+	result, err := v.Verify(payload, t.Signature, t.Time)
+	if err != nil {
+		return nil, fmt.Errorf("verify tag %s: %w", t.Name, err)
+	}
+	return result, nil
+}
+
+// Verify checks an ASCII-armored signature of payload.
+// signTime is the time the signature claims to have been made,
+// which is used to check the validity period of SSH keys.
+// OpenPGP keys are checked at the creation time stored in the signature.
+func (v *Verifier) Verify(payload, sig []byte, signTime time.Time) (*Verification, error) {
+	switch {
+	case bytes.HasPrefix(sig, []byte(openPGPSignatureHeader)):
+		if v.OpenPGP == nil {
+			return nil, errors.New("no OpenPGP keys trusted")
+		}
+		return v.OpenPGP.verify(payload, sig)
+	case bytes.HasPrefix(sig, []byte(sshSignatureHeader)):
+		if v.SSH == nil {
+			return nil, errors.New("no SSH keys trusted")
+		}
+		return v.SSH.verify(payload, sig, signTime)
+	case len(sig) == 0:
+		return nil, ErrUnsigned
+	default:
+		return nil, errors.New("unsupported signature format")
+	}
+}
+
+// A Signer creates signatures for commits and tags.
+// The Signer implementations in this package are [*OpenPGPSigner] and [*SSHSigner].
+type Signer interface {
+	// Sign returns an ASCII-armored detached signature of payload.
+	// The signature must end with a newline.
+	Sign(payload []byte) ([]byte, error)
+}
+
+// SignCommit signs a commit, replacing any existing signature.
+// For commits that use SHA-1 object IDs, the signature is stored in GPGSignature.
+// For commits that use SHA-256 object IDs, the signature is stored
+// in a "gpgsig-sha256" field at the end of Extra, like Git does.
+func SignCommit(c *object.Commit, s Signer) error {
+	payload, err := c.SignedPayload()
+	if err != nil {
+		return fmt.Errorf("sign commit: %w", err)
+	}
+	sig, err := signPayload(s, payload)
+	if err != nil {
+		return fmt.Errorf("sign commit: %w", err)
+	}
+	if c.Tree.ObjectFormat() != githash.SHA256Format {
+		c.GPGSignature = sig
+		return nil
+	}
+	field := "gpgsig-sha256 " + strings.ReplaceAll(strings.TrimSuffix(string(sig), "\n"), "\n", "\n ")
+	extra := removeField(c.Extra, "gpgsig-sha256")
+	if extra == "" {
+		c.Extra = object.CommitFields(field)
+	} else {
+		c.Extra = extra + "\n" + object.CommitFields(field)
+	}
+	return nil
+}
+
+// removeField returns fields without any fields with the given key.
+func removeField(fields object.CommitFields, key string) object.CommitFields {
+	var kept []string
+	for fields != "" {
+		var head object.CommitFields
+		head, fields = fields.Cut()
+		if k, _ := head.First(); k != key {
+			kept = append(kept, string(head))
+		}
+	}
+	return object.CommitFields(strings.Join(kept, "\n"))
+}
+
+// SignTag signs an annotated tag, replacing any existing signature.
+func SignTag(t *object.Tag, s Signer) error {
+	t.Signature = nil
+	payload, err := t.SignedPayload()
+	if err != nil {
+		return fmt.Errorf("sign tag %s: %w", t.Name, err)
+	}
+	sig, err := signPayload(s, payload)
+	if err != nil {
+		return fmt.Errorf("sign tag %s: %w", t.Name, err)
+	}
+	t.Signature = sig
+	return nil
+}
+
+func signPayload(s Signer, payload []byte) ([]byte, error) {
+	sig, err := s.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(sig, []byte(openPGPSignatureHeader)) && !bytes.HasPrefix(sig, []byte(sshSignatureHeader)) {
+		return nil, errors.New("signer returned unsupported signature format")
+	}
+	if !bytes.HasSuffix(sig, []byte("\n")) {
+		return nil, errors.New("signer returned signature without trailing newline")
+	}
+	return sig, nil
+}
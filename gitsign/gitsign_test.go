@@ -0,0 +1,539 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitsign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// The objects in testdata were created by Git 2.39
+// with `git commit -S` and `git tag -s`.
+// The "-ed25519" files were signed by GnuPG 2.2 with an Ed25519 key.
+const (
+	testOpenPGPFingerprint        = "F88151197444E6B6FF9B49795A681516B658F3D7"
+	testOpenPGPEd25519Fingerprint = "E236C89EADC7D9996CA53B6124F7BEC683D14A2B"
+	testSSHFingerprint            = "SHA256:VesGHr4V0s1wVcvKfyilXMoUz4EGclUqGWsrSIT/Bpg"
+	testSSHPublicKey              = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGFIynkwhqF1fl5TmJNNLobQuPe25hyp81e8idnVHOuU octocat"
+)
+
+func TestVerifier(t *testing.T) {
+	v := &Verifier{
+		OpenPGP: readTestKeyring(t, "openpgp-pubkey.asc"),
+		SSH:     readTestAllowedSigners(t),
+	}
+	openPGPResult := &Verification{
+		Format:      OpenPGP,
+		Fingerprint: testOpenPGPFingerprint,
+		Identities:  []string{"Octocat <octocat@example.com>"},
+	}
+	sshResult := &Verification{
+		Format:      SSH,
+		Fingerprint: testSSHFingerprint,
+		Identities:  []string{"octocat@example.com"},
+	}
+
+	t.Run("Commit", func(t *testing.T) {
+		tests := []struct {
+			file string
+			want *Verification
+		}{
+			{"commit-openpgp", openPGPResult},
+			{"commit-ssh", sshResult},
+		}
+		for _, test := range tests {
+			t.Run(test.file, func(t *testing.T) {
+				c := readTestCommit(t, test.file)
+				got, err := v.VerifyCommit(c)
+				if err != nil {
+					t.Fatal("VerifyCommit:", err)
+				}
+				if diff := cmp.Diff(test.want, got); diff != "" {
+					t.Errorf("VerifyCommit(...) (-want +got):\n%s", diff)
+				}
+
+				c.Message += "Tampered\n"
+				if _, err := v.VerifyCommit(c); err == nil {
+					t.Error("VerifyCommit(tampered) did not return an error")
+				} else if errors.Is(err, ErrUnsigned) {
+					t.Errorf("VerifyCommit(tampered) = _, %v; want non-ErrUnsigned error", err)
+				}
+			})
+		}
+	})
+
+	t.Run("Tag", func(t *testing.T) {
+		tests := []struct {
+			file string
+			want *Verification
+		}{
+			{"tag-openpgp", openPGPResult},
+			{"tag-ssh", sshResult},
+		}
+		for _, test := range tests {
+			t.Run(test.file, func(t *testing.T) {
+				tag := readTestTag(t, test.file)
+				got, err := v.VerifyTag(tag)
+				if err != nil {
+					t.Fatal("VerifyTag:", err)
+				}
+				if diff := cmp.Diff(test.want, got); diff != "" {
+					t.Errorf("VerifyTag(...) (-want +got):\n%s", diff)
+				}
+
+				tag.Name = "v3"
+				if _, err := v.VerifyTag(tag); err == nil {
+					t.Error("VerifyTag(tampered) did not return an error")
+				}
+			})
+		}
+	})
+
+	t.Run("Unsigned", func(t *testing.T) {
+		c := readTestCommit(t, "commit-ssh")
+		c.GPGSignature = nil
+		if _, err := v.VerifyCommit(c); !errors.Is(err, ErrUnsigned) {
+			t.Errorf("VerifyCommit(...) = _, %v; want %v", err, ErrUnsigned)
+		}
+		tag := readTestTag(t, "tag-ssh")
+		tag.Signature = nil
+		if _, err := v.VerifyTag(tag); !errors.Is(err, ErrUnsigned) {
+			t.Errorf("VerifyTag(...) = _, %v; want %v", err, ErrUnsigned)
+		}
+	})
+
+	t.Run("UntrustedFormat", func(t *testing.T) {
+		sshOnly := &Verifier{SSH: v.SSH}
+		if _, err := sshOnly.VerifyTag(readTestTag(t, "tag-openpgp")); err == nil {
+			t.Error("VerifyTag with no OpenPGP keys did not return an error")
+		}
+		openPGPOnly := &Verifier{OpenPGP: v.OpenPGP}
+		if _, err := openPGPOnly.VerifyTag(readTestTag(t, "tag-ssh")); err == nil {
+			t.Error("VerifyTag with no SSH keys did not return an error")
+		}
+	})
+}
+
+func TestVerifyOpenPGPEd25519(t *testing.T) {
+	v := &Verifier{OpenPGP: readTestKeyring(t, "openpgp-ed25519-pubkey.asc")}
+	want := &Verification{
+		Format:      OpenPGP,
+		Fingerprint: testOpenPGPEd25519Fingerprint,
+		Identities:  []string{"Octocat <octocat@example.com>"},
+	}
+
+	c := readTestCommit(t, "commit-openpgp-ed25519")
+	got, err := v.VerifyCommit(c)
+	if err != nil {
+		t.Fatal("VerifyCommit:", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("VerifyCommit(...) (-want +got):\n%s", diff)
+	}
+	c.Message += "Tampered\n"
+	if _, err := v.VerifyCommit(c); err == nil {
+		t.Error("VerifyCommit(tampered) did not return an error")
+	}
+
+	tag := readTestTag(t, "tag-openpgp-ed25519")
+	got, err = v.VerifyTag(tag)
+	if err != nil {
+		t.Fatal("VerifyTag:", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("VerifyTag(...) (-want +got):\n%s", diff)
+	}
+	tag.Name = "v3"
+	if _, err := v.VerifyTag(tag); err == nil {
+		t.Error("VerifyTag(tampered) did not return an error")
+	}
+
+	// The RSA key in the other fixtures must not verify the Ed25519 signature.
+	rsaOnly := &Verifier{OpenPGP: readTestKeyring(t, "openpgp-pubkey.asc")}
+	if _, err := rsaOnly.VerifyCommit(readTestCommit(t, "commit-openpgp-ed25519")); err == nil {
+		t.Error("VerifyCommit with the wrong key did not return an error")
+	}
+}
+
+func TestVerifyOpenPGPExpiredKey(t *testing.T) {
+	// Sign with a key that expired after the signature was made.
+	signTime := time.Now().Add(-48 * time.Hour)
+	config := &packet.Config{
+		Time:            func() time.Time { return signTime },
+		KeyLifetimeSecs: 24 * 60 * 60,
+	}
+	entity, err := openpgp.NewEntity("Octocat", "", "octocat@example.com", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte("Hello, World!\n")
+	sig := new(bytes.Buffer)
+	if err := openpgp.ArmoredDetachSign(sig, entity, bytes.NewReader(payload), config); err != nil {
+		t.Fatal(err)
+	}
+	publicKey := new(bytes.Buffer)
+	if err := entity.Serialize(publicKey); err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := ReadOpenPGPKeyring(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := &Verifier{OpenPGP: keyring}
+	if _, err := v.Verify(payload, sig.Bytes(), signTime); err != nil {
+		t.Error("Verify:", err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	openPGPEntity, err := openpgp.NewEntity("Octocat", "", "octocat@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := new(bytes.Buffer)
+	if err := openPGPEntity.SerializePrivate(privateKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	openPGPSigner, err := ReadOpenPGPSigner(privateKey, nil)
+	if err != nil {
+		t.Fatal("ReadOpenPGPSigner:", err)
+	}
+	publicKey := new(bytes.Buffer)
+	if err := openPGPEntity.Serialize(publicKey); err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := ReadOpenPGPKeyring(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Signer, err := ssh.NewSignerFromKey(ed25519Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSigner, err := ssh.NewSignerFromKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyAgent := agent.NewKeyring()
+	if err := keyAgent.Add(agent.AddedKey{PrivateKey: ed25519Key}); err != nil {
+		t.Fatal(err)
+	}
+	agentSigner, err := NewSSHAgentSigner(keyAgent, ed25519Signer.PublicKey())
+	if err != nil {
+		t.Fatal("NewSSHAgentSigner:", err)
+	}
+	allowedSigners, err := ParseAllowedSigners([]byte(
+		"ed25519@example.com " + string(ssh.MarshalAuthorizedKey(ed25519Signer.PublicKey())) +
+			"rsa@example.com " + string(ssh.MarshalAuthorizedKey(rsaSigner.PublicKey())),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Verifier{
+		OpenPGP: keyring,
+		SSH:     allowedSigners,
+	}
+
+	tests := []struct {
+		name   string
+		signer Signer
+		want   *Verification
+	}{
+		{
+			name:   "OpenPGP",
+			signer: openPGPSigner,
+			want: &Verification{
+				Format:      OpenPGP,
+				Fingerprint: openPGPSigner.Fingerprint(),
+				Identities:  []string{"Octocat <octocat@example.com>"},
+			},
+		},
+		{
+			name:   "SSH",
+			signer: NewSSHSigner(ed25519Signer),
+			want: &Verification{
+				Format:      SSH,
+				Fingerprint: ssh.FingerprintSHA256(ed25519Signer.PublicKey()),
+				Identities:  []string{"ed25519@example.com"},
+			},
+		},
+		{
+			name:   "SSHRSA",
+			signer: NewSSHSigner(rsaSigner),
+			want: &Verification{
+				Format:      SSH,
+				Fingerprint: ssh.FingerprintSHA256(rsaSigner.PublicKey()),
+				Identities:  []string{"rsa@example.com"},
+			},
+		},
+		{
+			name:   "SSHAgent",
+			signer: agentSigner,
+			want: &Verification{
+				Format:      SSH,
+				Fingerprint: ssh.FingerprintSHA256(ed25519Signer.PublicKey()),
+				Identities:  []string{"ed25519@example.com"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Run("Commit", func(t *testing.T) {
+				c := readTestCommit(t, "commit-ssh")
+				if err := SignCommit(c, test.signer); err != nil {
+					t.Fatal("SignCommit:", err)
+				}
+				data, err := c.MarshalBinary()
+				if err != nil {
+					t.Fatal(err)
+				}
+				c, err = object.ParseCommit(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := v.VerifyCommit(c)
+				if err != nil {
+					t.Fatal("VerifyCommit:", err)
+				}
+				if diff := cmp.Diff(test.want, got); diff != "" {
+					t.Errorf("VerifyCommit(...) (-want +got):\n%s", diff)
+				}
+			})
+
+			t.Run("SHA256Commit", func(t *testing.T) {
+				c := &object.Commit{
+					Tree:       githash.SHA256{0x01, 0x02, 0x03}.ObjectID(),
+					Author:     "Octocat <octocat@example.com>",
+					AuthorTime: time.Unix(1578581360, 0).In(time.UTC),
+					Committer:  "Octocat <octocat@example.com>",
+					CommitTime: time.Unix(1578581360, 0).In(time.UTC),
+					Extra:      "foo bar\ngpgsig-sha256 stale",
+					Message:    "Hello, World!\n",
+				}
+				if err := SignCommit(c, test.signer); err != nil {
+					t.Fatal("SignCommit:", err)
+				}
+				if len(c.GPGSignature) > 0 {
+					t.Errorf("GPGSignature = %q; want empty", c.GPGSignature)
+				}
+				data, err := c.MarshalBinary()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n := strings.Count(string(data), "\ngpgsig-sha256 "); n != 1 {
+					t.Errorf("serialized commit has %d gpgsig-sha256 fields; want 1", n)
+				}
+				c, err = object.ParseCommit(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := v.VerifyCommit(c)
+				if err != nil {
+					t.Fatal("VerifyCommit:", err)
+				}
+				if diff := cmp.Diff(test.want, got); diff != "" {
+					t.Errorf("VerifyCommit(...) (-want +got):\n%s", diff)
+				}
+			})
+
+			t.Run("Tag", func(t *testing.T) {
+				tag := readTestTag(t, "tag-ssh")
+				if err := SignTag(tag, test.signer); err != nil {
+					t.Fatal("SignTag:", err)
+				}
+				data, err := tag.MarshalBinary()
+				if err != nil {
+					t.Fatal(err)
+				}
+				tag, err = object.ParseTag(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := v.VerifyTag(tag)
+				if err != nil {
+					t.Fatal("VerifyTag:", err)
+				}
+				if diff := cmp.Diff(test.want, got); diff != "" {
+					t.Errorf("VerifyTag(...) (-want +got):\n%s", diff)
+				}
+			})
+		})
+	}
+}
+
+func TestAllowedSigners(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowedSigners string
+		want           []string
+		wantParseError bool
+	}{
+		{
+			name:           "NoOptions",
+			allowedSigners: "octocat@example.com " + testSSHPublicKey + "\n",
+			want:           []string{"octocat@example.com"},
+		},
+		{
+			name: "MultiplePrincipals",
+			allowedSigners: "# Comment\n" +
+				"\n" +
+				"\"a@example.com,b@example.com\" " + testSSHPublicKey + "\n" +
+				"c@example.com " + testSSHPublicKey + "\n",
+			want: []string{"a@example.com", "b@example.com", "c@example.com"},
+		},
+		{
+			name:           "NamespacePattern",
+			allowedSigners: `octocat@example.com namespaces="file,g*" ` + testSSHPublicKey,
+			want:           []string{"octocat@example.com"},
+		},
+		{
+			name:           "WrongNamespace",
+			allowedSigners: `octocat@example.com namespaces="file" ` + testSSHPublicKey,
+		},
+		{
+			name:           "ValidAfter",
+			allowedSigners: `octocat@example.com valid-after="20200101Z" ` + testSSHPublicKey,
+			want:           []string{"octocat@example.com"},
+		},
+		{
+			name:           "ValidBefore",
+			allowedSigners: `octocat@example.com valid-before="20200109145000Z" ` + testSSHPublicKey,
+		},
+		{
+			name:           "NotYetValid",
+			allowedSigners: `octocat@example.com valid-after="202001091451Z" ` + testSSHPublicKey,
+		},
+		{
+			name:           "CertAuthority",
+			allowedSigners: `*@example.com cert-authority ` + testSSHPublicKey,
+			wantParseError: true,
+		},
+		{
+			name:           "BadTime",
+			allowedSigners: `octocat@example.com valid-after="2020" ` + testSSHPublicKey,
+			wantParseError: true,
+		},
+	}
+	tag := readTestTag(t, "tag-ssh")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			allowed, err := ParseAllowedSigners([]byte(test.allowedSigners))
+			if err != nil {
+				if !test.wantParseError {
+					t.Fatal("ParseAllowedSigners:", err)
+				}
+				return
+			}
+			if test.wantParseError {
+				t.Fatal("ParseAllowedSigners did not return an error")
+			}
+			v := &Verifier{SSH: allowed}
+			got, err := v.VerifyTag(tag)
+			if test.want == nil {
+				if err == nil {
+					t.Errorf("VerifyTag(...) = %+v, <nil>; want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("VerifyTag:", err)
+			}
+			if diff := cmp.Diff(test.want, got.Identities); diff != "" {
+				t.Errorf("identities (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func readTestKeyring(tb testing.TB, name string) *OpenPGPKeyring {
+	tb.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	k, err := ReadOpenPGPKeyring(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return k
+}
+
+func readTestAllowedSigners(tb testing.TB) *AllowedSigners {
+	tb.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "allowed_signers"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s, err := ParseAllowedSigners(data)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
+
+func readTestCommit(tb testing.TB, name string) *object.Commit {
+	tb.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	c, err := object.ParseCommit(data)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return c
+}
+
+func readTestTag(tb testing.TB, name string) *object.Tag {
+	tb.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tag, err := object.ParseTag(data)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return tag
+}
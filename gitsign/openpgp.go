@@ -0,0 +1,144 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitsign
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// An OpenPGPKeyring is a set of OpenPGP public keys.
+type OpenPGPKeyring struct {
+	entities openpgp.EntityList
+}
+
+// ReadOpenPGPKeyring reads a keyring in either the binary or ASCII-armored
+// format, like the output of `gpg --export` or `gpg --export --armor`.
+// RSA, DSA, ECDSA, and EdDSA (Ed25519) keys are supported.
+func ReadOpenPGPKeyring(r io.Reader) (*OpenPGPKeyring, error) {
+	entities, err := readOpenPGPEntities(r)
+	if err != nil {
+		return nil, fmt.Errorf("read openpgp keyring: %w", err)
+	}
+	return &OpenPGPKeyring{entities: entities}, nil
+}
+
+func readOpenPGPEntities(r io.Reader) (openpgp.EntityList, error) {
+	br := bufio.NewReader(r)
+	start, _ := br.Peek(len("-----BEGIN"))
+	if bytes.Equal(start, []byte("-----BEGIN")) {
+		return openpgp.ReadArmoredKeyRing(br)
+	}
+	return openpgp.ReadKeyRing(br)
+}
+
+// verify checks an ASCII-armored detached signature of payload.
+// Like Git, verify checks key expiration and revocation
+// at the time the signature was made, not the current time.
+func (k *OpenPGPKeyring) verify(payload, sig []byte) (*Verification, error) {
+	sigTime, err := openPGPSignatureTime(sig)
+	if err != nil {
+		return nil, fmt.Errorf("openpgp: %w", err)
+	}
+	config := &packet.Config{
+		Time: func() time.Time { return sigTime },
+	}
+	entity, err := openpgp.CheckArmoredDetachedSignature(k.entities, bytes.NewReader(payload), bytes.NewReader(sig), config)
+	if err != nil {
+		return nil, fmt.Errorf("openpgp: %w", err)
+	}
+	result := &Verification{
+		Format:      OpenPGP,
+		Fingerprint: fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint),
+	}
+	for name := range entity.Identities {
+		result.Identities = append(result.Identities, name)
+	}
+	sort.Strings(result.Identities)
+	return result, nil
+}
+
+// openPGPSignatureTime returns the creation time
+// of an ASCII-armored signature.
+func openPGPSignatureTime(sig []byte) (time.Time, error) {
+	block, err := armor.Decode(bytes.NewReader(sig))
+	if err != nil {
+		return time.Time{}, err
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return time.Time{}, err
+	}
+	s, ok := p.(*packet.Signature)
+	if !ok {
+		return time.Time{}, errors.New("not a signature")
+	}
+	return s.CreationTime, nil
+}
+
+// An OpenPGPSigner signs with an OpenPGP private key.
+type OpenPGPSigner struct {
+	entity *openpgp.Entity
+}
+
+// ReadOpenPGPSigner reads a private key in either the binary or ASCII-armored
+// format, like the output of `gpg --export-secret-keys`.
+// If the input contains more than one key, the first one is used.
+// If the key is encrypted, it is decrypted with passphrase.
+// RSA, DSA, ECDSA, and EdDSA (Ed25519) keys are supported.
+func ReadOpenPGPSigner(r io.Reader, passphrase []byte) (*OpenPGPSigner, error) {
+	entities, err := readOpenPGPEntities(r)
+	if err != nil {
+		return nil, fmt.Errorf("read openpgp signer: %w", err)
+	}
+	for _, e := range entities {
+		if e.PrivateKey == nil {
+			continue
+		}
+		if err := e.DecryptPrivateKeys(passphrase); err != nil {
+			return nil, fmt.Errorf("read openpgp signer: %w", err)
+		}
+		return &OpenPGPSigner{entity: e}, nil
+	}
+	return nil, errors.New("read openpgp signer: no private key found")
+}
+
+// Fingerprint returns the uppercase hex fingerprint of the signer's primary key.
+func (s *OpenPGPSigner) Fingerprint() string {
+	return fmt.Sprintf("%X", s.entity.PrimaryKey.Fingerprint)
+}
+
+// Sign returns an ASCII-armored OpenPGP signature of payload.
+func (s *OpenPGPSigner) Sign(payload []byte) ([]byte, error) {
+	sig := new(bytes.Buffer)
+	if err := openpgp.ArmoredDetachSign(sig, s.entity, bytes.NewReader(payload), nil); err != nil {
+		return nil, fmt.Errorf("openpgp: %w", err)
+	}
+	if !bytes.HasSuffix(sig.Bytes(), []byte("\n")) {
+		sig.WriteString("\n")
+	}
+	return sig.Bytes(), nil
+}
@@ -0,0 +1,410 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitsign
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// sshNamespace is the namespace Git uses for SSH signatures.
+const sshNamespace = "git"
+
+// sshSigMagic is the preamble of SSH signature blobs and signed data.
+// See https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+const sshSigMagic = "SSHSIG"
+
+const sshSignatureFooter = "-----END SSH SIGNATURE-----"
+
+// AllowedSigners is a set of trusted SSH public keys
+// in the format of Git's gpg.ssh.allowedSignersFile.
+// See the "ALLOWED SIGNERS" section of ssh-keygen(1).
+type AllowedSigners struct {
+	entries []*allowedSigner
+}
+
+type allowedSigner struct {
+	principals  []string
+	key         ssh.PublicKey
+	namespaces  []string // nil means any namespace
+	validAfter  time.Time
+	validBefore time.Time
+}
+
+// ParseAllowedSigners parses an allowed signers file.
+// Certificate authorities (the cert-authority option) are not supported.
+func ParseAllowedSigners(data []byte) (*AllowedSigners, error) {
+	s := new(AllowedSigners)
+	for lineno := 1; len(data) > 0; lineno++ {
+		var line []byte
+		line, data, _ = bytes.Cut(data, []byte("\n"))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		ent, err := parseAllowedSigner(line)
+		if err != nil {
+			return nil, fmt.Errorf("parse allowed signers: line %d: %w", lineno, err)
+		}
+		s.entries = append(s.entries, ent)
+	}
+	return s, nil
+}
+
+func parseAllowedSigner(line []byte) (*allowedSigner, error) {
+	var principals string
+	if line[0] == '"' {
+		end := bytes.IndexByte(line[1:], '"')
+		if end == -1 {
+			return nil, errors.New("unterminated quote in principals")
+		}
+		principals = string(line[1 : end+1])
+		line = line[end+2:]
+	} else {
+		end := bytes.IndexAny(line, " \t")
+		if end == -1 {
+			return nil, errors.New("missing public key")
+		}
+		principals = string(line[:end])
+		line = line[end:]
+	}
+	ent := &allowedSigner{
+		principals: strings.Split(principals, ","),
+	}
+	var options []string
+	var err error
+	ent.key, _, options, _, err = ssh.ParseAuthorizedKey(bytes.TrimSpace(line))
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range options {
+		name, value, _ := strings.Cut(opt, "=")
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(name) {
+		case "namespaces":
+			ent.namespaces = strings.Split(value, ",")
+		case "valid-after":
+			ent.validAfter, err = parseSSHTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("valid-after: %w", err)
+			}
+		case "valid-before":
+			ent.validBefore, err = parseSSHTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("valid-before: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported option %q", name)
+		}
+	}
+	return ent, nil
+}
+
+// parseSSHTime parses a time in the YYYYMMDD[HHMM[SS]][Z] format
+// used by ssh-keygen. Times without a trailing "Z" are in the local time zone.
+func parseSSHTime(s string) (time.Time, error) {
+	loc := time.Local
+	if strings.HasSuffix(s, "Z") || strings.HasSuffix(s, "z") {
+		s = s[:len(s)-1]
+		loc = time.UTC
+	}
+	var layout string
+	switch len(s) {
+	case len("20060102"):
+		layout = "20060102"
+	case len("200601021504"):
+		layout = "200601021504"
+	case len("20060102150405"):
+		layout = "20060102150405"
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	return time.ParseInLocation(layout, s, loc)
+}
+
+func (ent *allowedSigner) allows(key ssh.PublicKey, namespace string, t time.Time) bool {
+	if !bytes.Equal(ent.key.Marshal(), key.Marshal()) {
+		return false
+	}
+	if ent.namespaces != nil {
+		found := false
+		for _, pattern := range ent.namespaces {
+			if matchSSHPattern(pattern, namespace) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !ent.validAfter.IsZero() && t.Before(ent.validAfter) {
+		return false
+	}
+	if !ent.validBefore.IsZero() && !t.Before(ent.validBefore) {
+		return false
+	}
+	return true
+}
+
+// matchSSHPattern reports whether s matches an OpenSSH pattern,
+// where '*' matches zero or more characters and '?' matches one character.
+func matchSSHPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchSSHPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		s = s[1:]
+	}
+	return len(s) == 0
+}
+
+// sshSignature is the binary form of an SSH signature, after the magic preamble.
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the data that an SSH signature's key signs,
+// after the magic preamble.
+type sshSignedData struct {
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Hash          []byte
+}
+
+func (s *AllowedSigners) verify(payload, armored []byte, t time.Time) (*Verification, error) {
+	blob, err := decodeSSHSignature(armored)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+	sig := new(sshSignature)
+	if err := ssh.Unmarshal(blob[len(sshSigMagic):], sig); err != nil {
+		return nil, fmt.Errorf("ssh: parse signature: %w", err)
+	}
+	if sig.Version != 1 {
+		return nil, fmt.Errorf("ssh: unsupported signature version %d", sig.Version)
+	}
+	if sig.Namespace != sshNamespace {
+		return nil, fmt.Errorf("ssh: signature namespace is %q instead of %q", sig.Namespace, sshNamespace)
+	}
+	key, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: parse signature: %w", err)
+	}
+	if _, isCert := key.(*ssh.Certificate); isCert {
+		return nil, errors.New("ssh: certificate signatures not supported")
+	}
+	keySig := new(ssh.Signature)
+	if err := ssh.Unmarshal(sig.Signature, keySig); err != nil {
+		return nil, fmt.Errorf("ssh: parse signature: %w", err)
+	}
+	if keySig.Format == ssh.KeyAlgoRSA {
+		// PROTOCOL.sshsig forbids SHA-1 RSA signatures.
+		return nil, fmt.Errorf("ssh: unsupported signature algorithm %s", keySig.Format)
+	}
+	h, err := sshSigHash(sig.HashAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+	h.Write(payload)
+	signed := append([]byte(sshSigMagic), ssh.Marshal(&sshSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+	if err := key.Verify(signed, keySig); err != nil {
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+
+	result := &Verification{
+		Format:      SSH,
+		Fingerprint: ssh.FingerprintSHA256(key),
+	}
+	for _, ent := range s.entries {
+		if ent.allows(key, sig.Namespace, t) {
+			result.Identities = append(result.Identities, ent.principals...)
+		}
+	}
+	if len(result.Identities) == 0 {
+		return nil, fmt.Errorf("ssh: key %s is not an allowed signer", result.Fingerprint)
+	}
+	return result, nil
+}
+
+// decodeSSHSignature decodes an ASCII-armored SSH signature
+// and checks its magic preamble.
+func decodeSSHSignature(armored []byte) ([]byte, error) {
+	if !bytes.HasPrefix(armored, []byte(sshSignatureHeader+"\n")) {
+		return nil, errors.New("missing signature header")
+	}
+	rest := armored[len(sshSignatureHeader)+1:]
+	end := bytes.Index(rest, []byte(sshSignatureFooter))
+	if end == -1 {
+		return nil, errors.New("missing signature footer")
+	}
+	b64 := bytes.Join(bytes.Fields(rest[:end]), nil)
+	blob := make([]byte, base64.StdEncoding.DecodedLen(len(b64)))
+	n, err := base64.StdEncoding.Decode(blob, b64)
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	blob = blob[:n]
+	if !bytes.HasPrefix(blob, []byte(sshSigMagic)) {
+		return nil, errors.New("decode signature: missing preamble")
+	}
+	return blob, nil
+}
+
+func sshSigHash(name string) (hash.Hash, error) {
+	switch name {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %q", name)
+	}
+}
+
+// An SSHSigner signs with an SSH private key.
+type SSHSigner struct {
+	signer ssh.Signer
+}
+
+// NewSSHSigner returns a signer that uses the given SSH key.
+// Certificates are not supported.
+func NewSSHSigner(s ssh.Signer) *SSHSigner {
+	return &SSHSigner{signer: s}
+}
+
+// NewSSHAgentSigner returns a signer that uses the key in the agent
+// that matches the given public key. To use the user's running ssh-agent,
+// dial the socket named by the SSH_AUTH_SOCK environment variable:
+//
+//	conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+//	if err != nil {
+//		return err
+//	}
+//	defer conn.Close()
+//	signer, err := gitsign.NewSSHAgentSigner(agent.NewClient(conn), pub)
+//
+// The agent must remain available for as long as the signer is used.
+func NewSSHAgentSigner(ag agent.Agent, pub ssh.PublicKey) (*SSHSigner, error) {
+	signers, err := ag.Signers()
+	if err != nil {
+		return nil, fmt.Errorf("ssh agent signer: %w", err)
+	}
+	want := pub.Marshal()
+	for _, s := range signers {
+		if bytes.Equal(s.PublicKey().Marshal(), want) {
+			return NewSSHSigner(s), nil
+		}
+	}
+	return nil, fmt.Errorf("ssh agent signer: agent does not have key %s", ssh.FingerprintSHA256(pub))
+}
+
+// PublicKey returns the signer's public key.
+func (s *SSHSigner) PublicKey() ssh.PublicKey {
+	return s.signer.PublicKey()
+}
+
+// Sign returns an ASCII-armored SSH signature of payload
+// in the "git" namespace, as made by `ssh-keygen -Y sign`.
+func (s *SSHSigner) Sign(payload []byte) ([]byte, error) {
+	const hashAlgorithm = "sha512"
+	h := sha512.New()
+	h.Write(payload)
+	signed := append([]byte(sshSigMagic), ssh.Marshal(&sshSignedData{
+		Namespace:     sshNamespace,
+		HashAlgorithm: hashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+	pub := s.signer.PublicKey()
+	var keySig *ssh.Signature
+	var err error
+	if pub.Type() == ssh.KeyAlgoRSA {
+		// PROTOCOL.sshsig forbids SHA-1 RSA signatures.
+		algSigner, ok := s.signer.(ssh.AlgorithmSigner)
+		if !ok {
+			return nil, errors.New("ssh: rsa signer does not support SHA-2 signatures")
+		}
+		keySig, err = algSigner.SignWithAlgorithm(rand.Reader, signed, ssh.KeyAlgoRSASHA512)
+	} else {
+		keySig, err = s.signer.Sign(rand.Reader, signed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+	blob := append([]byte(sshSigMagic), ssh.Marshal(&sshSignature{
+		Version:       1,
+		PublicKey:     pub.Marshal(),
+		Namespace:     sshNamespace,
+		HashAlgorithm: hashAlgorithm,
+		Signature:     ssh.Marshal(keySig),
+	})...)
+	return encodeSSHSignature(blob), nil
+}
+
+// encodeSSHSignature ASCII-armors an SSH signature blob
+// with the same line length as ssh-keygen.
+func encodeSSHSignature(blob []byte) []byte {
+	const lineLength = 70
+	b64 := base64.StdEncoding.EncodeToString(blob)
+	buf := new(bytes.Buffer)
+	buf.WriteString(sshSignatureHeader + "\n")
+	for len(b64) > lineLength {
+		buf.WriteString(b64[:lineLength])
+		buf.WriteString("\n")
+		b64 = b64[lineLength:]
+	}
+	buf.WriteString(b64)
+	buf.WriteString("\n" + sshSignatureFooter + "\n")
+	return buf.Bytes()
+}
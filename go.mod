@@ -17,8 +17,13 @@ module gg-scm.io/pkg/git
 go 1.19
 
 require (
+	github.com/ProtonMail/go-crypto v1.1.6
 	github.com/google/go-cmp v0.5.4
-	golang.org/x/sys v0.1.0
+	golang.org/x/crypto v0.21.0
+	golang.org/x/sys v0.18.0
 )
 
-require golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
+require (
+	github.com/cloudflare/circl v1.3.7 // indirect
+	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
+)
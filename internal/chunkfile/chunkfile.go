@@ -0,0 +1,93 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package chunkfile reads and writes the table of contents
+// of Git's chunk-based file formats, like commit-graph and multi-pack-index.
+// See https://git-scm.com/docs/gitformat-chunk for details.
+package chunkfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TOCEntrySize is the size in bytes of a single table of contents entry.
+const TOCEntrySize = 12
+
+// A Chunk is a single section of a chunk-based file.
+type Chunk struct {
+	// ID is the four-byte identifier of the chunk, like "OIDF".
+	ID   string
+	Data []byte
+}
+
+// Read parses a table of contents with n chunks that starts at offset
+// in data. It returns the data of each chunk, keyed by ID.
+// The data slices refer to the same memory as data.
+func Read(data []byte, offset int, n int) (map[string][]byte, error) {
+	tocEnd := offset + (n+1)*TOCEntrySize
+	if offset < 0 || tocEnd > len(data) {
+		return nil, fmt.Errorf("chunk table of contents: %w", io.ErrUnexpectedEOF)
+	}
+	chunks := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		entry := data[offset+i*TOCEntrySize:]
+		id := string(entry[:4])
+		if id == "\x00\x00\x00\x00" {
+			return nil, fmt.Errorf("chunk table of contents: entry %d has zero ID", i)
+		}
+		start := binary.BigEndian.Uint64(entry[4:12])
+		end := binary.BigEndian.Uint64(entry[TOCEntrySize+4 : TOCEntrySize+12])
+		if start < uint64(tocEnd) || end < start || end > uint64(len(data)) {
+			return nil, fmt.Errorf("chunk %q: invalid offsets [%d, %d)", id, start, end)
+		}
+		if _, dup := chunks[id]; dup {
+			return nil, fmt.Errorf("chunk %q: duplicated", id)
+		}
+		chunks[id] = data[start:end:end]
+	}
+	if id := data[tocEnd-TOCEntrySize : tocEnd-TOCEntrySize+4]; string(id) != "\x00\x00\x00\x00" {
+		return nil, fmt.Errorf("chunk table of contents: missing terminating entry")
+	}
+	return chunks, nil
+}
+
+// Write writes a table of contents for chunks followed by the chunks' data.
+// offset is the position in the file that the table of contents starts at.
+func Write(w io.Writer, offset int64, chunks []Chunk) error {
+	toc := make([]byte, 0, (len(chunks)+1)*TOCEntrySize)
+	pos := uint64(offset) + uint64(len(chunks)+1)*TOCEntrySize
+	for _, c := range chunks {
+		if len(c.ID) != 4 {
+			return fmt.Errorf("write chunk %q: ID must be 4 bytes", c.ID)
+		}
+		toc = append(toc, c.ID...)
+		toc = binary.BigEndian.AppendUint64(toc, pos)
+		pos += uint64(len(c.Data))
+	}
+	toc = append(toc, 0, 0, 0, 0)
+	toc = binary.BigEndian.AppendUint64(toc, pos)
+	if _, err := w.Write(toc); err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if _, err := w.Write(c.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
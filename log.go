@@ -18,7 +18,6 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"crypto/sha1"
 	"errors"
 	"fmt"
 	"hash"
@@ -26,7 +25,9 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"gg-scm.io/pkg/git/githash"
 	"gg-scm.io/pkg/git/object"
 )
 
@@ -64,19 +65,68 @@ func (g *Git) CommitInfo(ctx context.Context, rev string) (*object.Commit, error
 // LogOptions specifies filters and ordering on a log listing.
 type LogOptions struct {
 	// Revs specifies the set of commits to list. When empty, it defaults
-	// to all commits reachable from HEAD.
+	// to all commits reachable from HEAD, unless one of All, Branches, Tags,
+	// or Remotes is set.
 	Revs []string
 
+	// If All is true, then all refs and HEAD are added to Revs.
+	All bool
+	// If Branches is true, then all refs under refs/heads are added to Revs.
+	Branches bool
+	// If Tags is true, then all refs under refs/tags are added to Revs.
+	Tags bool
+	// If Remotes is true, then all refs under refs/remotes are added to Revs.
+	Remotes bool
+
+	// Paths limits the listing to commits that modify the given paths.
+	Paths []Pathspec
+	// If Follow is true, then the history of the single file in Paths
+	// is followed across renames. Follow requires exactly one path.
+	Follow bool
+
+	// Author, Committer, and Grep limit the listing to commits whose author,
+	// committer, or message (respectively) match the given basic regular
+	// expression. An empty string matches any commit.
+	Author    string
+	Committer string
+	Grep      string
+
+	// If Since is not zero, then commits made before Since are not listed.
+	// If Until is not zero, then commits made after Until are not listed.
+	// Both compare against the commit time.
+	Since time.Time
+	Until time.Time
+
 	// MaxParents sets an inclusive upper limit on the number of parents
 	// on revisions to return from Log. If MaxParents is zero, then it is
 	// treated as no limit unless AllowZeroMaxParents is true.
 	MaxParents          int
 	AllowZeroMaxParents bool
 
+	// MinParents sets an inclusive lower limit on the number of parents
+	// on revisions to return from Log. Zero means no limit.
+	MinParents int
+
 	// If FirstParent is true, then follow only the first parent commit
 	// upon seeing a merge commit.
 	FirstParent bool
 
+	// If AncestryPath is true, then when given a range A..B, only commits
+	// that are both descendants of A and ancestors of B are listed.
+	AncestryPath bool
+
+	// If SimplifyByDecoration is true, then only commits that are referred
+	// to by a ref (along with any commits needed to preserve the graph
+	// structure) are listed.
+	SimplifyByDecoration bool
+
+	// Order specifies the order in which commits are listed.
+	Order LogOrder
+
+	// Skip is the number of commits to skip before starting to return
+	// revisions from Log.
+	Skip int
+
 	// Limit specifies the upper bound on the number of revisions to return from Log.
 	// Zero means no limit.
 	Limit int
@@ -87,6 +137,66 @@ type LogOptions struct {
 	// If NoWalk is true, then ancestor commits are not traversed. Does not have
 	// an effect if Revs contains a range.
 	NoWalk bool
+
+	// If ChangedFiles is true, then [Log.ChangedFiles] reports the files
+	// each commit changed relative to its first parent. Merge commits are
+	// compared against their first parent and root commits against the
+	// empty tree. If Paths is not empty, only matching files are reported.
+	// Requires Git 2.31 or later.
+	ChangedFiles bool
+	// If LineCounts is true, then the files reported by [Log.ChangedFiles]
+	// include the number of lines added and deleted, like `git log --numstat`.
+	// LineCounts implies ChangedFiles.
+	LineCounts bool
+	// DisableRenames will force Git to disable rename/copy detection
+	// for ChangedFiles.
+	DisableRenames bool
+
+	// If Decorations is true, then [Log.Refs] reports the refs
+	// that point to each commit.
+	Decorations bool
+}
+
+// usesLog reports whether the options require running `git log`
+// instead of `git rev-list`.
+func (opts *LogOptions) usesLog() bool {
+	return opts.Follow || opts.ChangedFiles || opts.LineCounts || opts.Decorations
+}
+
+// LogOrder specifies the order in which commits are listed in a log.
+type LogOrder int
+
+// Log orders. See https://git-scm.com/docs/git-rev-list#_commit_ordering
+// for details.
+const (
+	// DefaultOrder lists commits in reverse chronological order
+	// by commit time.
+	DefaultOrder LogOrder = iota
+	// TopoOrder shows no parents before all of their children are shown
+	// and avoids interleaving multiple lines of history.
+	TopoOrder
+	// DateOrder shows no parents before all of their children are shown,
+	// but otherwise lists commits in commit time order.
+	DateOrder
+	// AuthorDateOrder shows no parents before all of their children
+	// are shown, but otherwise lists commits in author time order.
+	AuthorDateOrder
+)
+
+// String returns the Go constant name of the order.
+func (order LogOrder) String() string {
+	switch order {
+	case DefaultOrder:
+		return "DefaultOrder"
+	case TopoOrder:
+		return "TopoOrder"
+	case DateOrder:
+		return "DateOrder"
+	case AuthorDateOrder:
+		return "AuthorDateOrder"
+	default:
+		return fmt.Sprintf("LogOrder(%d)", int(order))
+	}
 }
 
 const logErrPrefix = "git rev-list | git cat-file --batch"
@@ -101,13 +211,92 @@ func (g *Git) Log(ctx context.Context, opts LogOptions) (_ *Log, err error) {
 			return nil, fmt.Errorf("%s: %w", logErrPrefix, err)
 		}
 	}
-	args := []string{"rev-list"}
+	if opts.Follow && len(opts.Paths) != 1 {
+		return nil, fmt.Errorf("%s: follow requires exactly one path", logErrPrefix)
+	}
+	var args []string
+	if opts.usesLog() {
+		// rev-list does not support --follow or printing diffs,
+		// so use log and read its output in Log.readEntry.
+		args = []string{
+			"log",
+			"-z",
+			"--no-color",
+			"--no-show-signature",
+		}
+		if opts.Decorations {
+			args = append(args, "--decorate=full", "--format=commit %H%x00%D")
+		} else {
+			args = append(args, "--no-decorate", "--format=commit %H")
+		}
+		if opts.ChangedFiles || opts.LineCounts {
+			args = append(args,
+				"--raw",
+				"--no-abbrev",
+				"--root",
+				"--diff-merges=first-parent",
+				"--no-ext-diff",
+				"--no-textconv",
+			)
+			if opts.LineCounts {
+				args = append(args, "--numstat")
+			}
+			if opts.DisableRenames {
+				args = append(args, "--no-renames")
+			} else {
+				args = append(args, "--find-renames")
+			}
+		}
+		if opts.Follow {
+			args = append(args, "--follow")
+		}
+	} else {
+		args = []string{"rev-list"}
+	}
 	if opts.MaxParents > 0 || opts.AllowZeroMaxParents {
 		args = append(args, fmt.Sprintf("--max-parents=%d", opts.MaxParents))
 	}
+	if opts.MinParents > 0 {
+		args = append(args, fmt.Sprintf("--min-parents=%d", opts.MinParents))
+	}
 	if opts.FirstParent {
 		args = append(args, "--first-parent")
 	}
+	if opts.AncestryPath {
+		args = append(args, "--ancestry-path")
+	}
+	if opts.SimplifyByDecoration {
+		args = append(args, "--simplify-by-decoration")
+	}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author)
+	}
+	if opts.Committer != "" {
+		args = append(args, "--committer="+opts.Committer)
+	}
+	if opts.Grep != "" {
+		args = append(args, "--grep="+opts.Grep)
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, fmt.Sprintf("--since=@%d", opts.Since.Unix()))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, fmt.Sprintf("--until=@%d", opts.Until.Unix()))
+	}
+	switch opts.Order {
+	case DefaultOrder:
+	case TopoOrder:
+		args = append(args, "--topo-order")
+	case DateOrder:
+		args = append(args, "--date-order")
+	case AuthorDateOrder:
+		args = append(args, "--author-date-order")
+	default:
+		return nil, fmt.Errorf("%s: unknown order %v", logErrPrefix, opts.Order)
+	}
+	if opts.Skip > 0 {
+		args = append(args, fmt.Sprintf("--skip=%d", opts.Skip))
+	}
 	if opts.Limit > 0 {
 		args = append(args, fmt.Sprintf("--max-count=%d", opts.Limit))
 	}
@@ -117,12 +306,27 @@ func (g *Git) Log(ctx context.Context, opts LogOptions) (_ *Log, err error) {
 	if opts.NoWalk {
 		args = append(args, "--no-walk=sorted")
 	}
-	if len(opts.Revs) == 0 {
-		args = append(args, Head.String())
-	} else {
+	if opts.All {
+		args = append(args, "--all")
+	}
+	if opts.Branches {
+		args = append(args, "--branches")
+	}
+	if opts.Tags {
+		args = append(args, "--tags")
+	}
+	if opts.Remotes {
+		args = append(args, "--remotes")
+	}
+	if len(opts.Revs) > 0 {
 		args = append(args, opts.Revs...)
+	} else if !opts.All && !opts.Branches && !opts.Tags && !opts.Remotes {
+		args = append(args, Head.String())
 	}
 	args = append(args, "--")
+	for _, p := range opts.Paths {
+		args = append(args, p.String())
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	stderr := new(bytes.Buffer)
@@ -139,29 +343,45 @@ func (g *Git) Log(ctx context.Context, opts LogOptions) (_ *Log, err error) {
 		return nil, fmt.Errorf("%s: %w", logErrPrefix, err)
 	}
 
+	l := &Log{
+		stderr:      stderr,
+		cancelFunc:  cancel,
+		decorations: opts.Decorations,
+	}
+	catFileInvoke := &Invocation{
+		Args:  []string{"cat-file", "--batch"},
+		Dir:   g.dir,
+		Stdin: revListPipe,
+	}
+	if opts.usesLog() {
+		// Send object IDs to cat-file one at a time from Log.next.
+		var catFileIn *io.PipeReader
+		catFileIn, l.catFileIn = io.Pipe()
+		catFileInvoke.Stdin = catFileIn
+		l.entries = bufio.NewReader(revListPipe)
+		go func() {
+			// Unblock any pending writes if the subprocess is stopped.
+			<-ctx.Done()
+			l.catFileIn.CloseWithError(ctx.Err())
+		}()
+	}
 	catFileStderr := stderrMux.newHandle()
-	catFilePipe, err := StartPipe(ctx, g.runner, &Invocation{
-		Args:   []string{"cat-file", "--batch"},
-		Dir:    g.dir,
-		Stdin:  revListPipe,
-		Stderr: catFileStderr,
-	})
+	catFileInvoke.Stderr = catFileStderr
+	catFilePipe, err := StartPipe(ctx, g.runner, catFileInvoke)
 	if err != nil {
 		cancel()
+		if l.catFileIn != nil {
+			l.catFileIn.Close()
+		}
 		revListPipe.Close()
 		return nil, fmt.Errorf("%s: %w", logErrPrefix, err)
 	}
-
-	return &Log{
-		r:          bufio.NewReaderSize(catFilePipe, 1<<20 /* 1 MiB */),
-		stderr:     stderr,
-		cancelFunc: cancel,
-		hash:       sha1.New(),
-		closers: [...]io.Closer{
-			pipeStreamCloser{catFilePipe, catFileStderr},
-			pipeStreamCloser{revListPipe, revListStderr},
-		},
-	}, nil
+	l.r = bufio.NewReaderSize(catFilePipe, 1<<20 /* 1 MiB */)
+	l.closers = [...]io.Closer{
+		pipeStreamCloser{catFilePipe, catFileStderr},
+		pipeStreamCloser{revListPipe, revListStderr},
+	}
+	return l, nil
 }
 
 // Log is an open handle to a `git cat-file --batch` subprocess. Closing the Log
@@ -170,12 +390,21 @@ type Log struct {
 	r          *bufio.Reader
 	stderr     *bytes.Buffer
 	hash       hash.Hash
+	hashFormat githash.ObjectFormat
 	cancelFunc context.CancelFunc
 	closers    [2]io.Closer
 
+	// entries reads the output of `git log` when it is used instead of
+	// `git rev-list`. Each commit's ID is written to catFileIn as it is read.
+	entries     *bufio.Reader
+	catFileIn   *io.PipeWriter
+	decorations bool
+
 	scanErr  error
 	scanDone bool
 	info     *object.Commit
+	files    []*DiffFile
+	refs     []Ref
 }
 
 // Next attempts to scan the next log entry and returns whether there is a new entry.
@@ -196,6 +425,20 @@ func (l *Log) Next() bool {
 }
 
 func (l *Log) next() error {
+	var wantSum Hash
+	if l.entries != nil {
+		var err error
+		wantSum, err = l.readEntry()
+		if errors.Is(err, io.EOF) {
+			// Let cat-file finish.
+			l.catFileIn.Close()
+		} else if err != nil {
+			return err
+		} else if _, err := io.WriteString(l.catFileIn, wantSum.String()+"\n"); err != nil {
+			return err
+		}
+	}
+
 	// Read object information.
 	// Reference: https://git-scm.com/docs/git-cat-file#_batch_output
 	line, err := l.r.ReadSlice('\n')
@@ -220,6 +463,9 @@ func (l *Log) next() error {
 	if err := expectSum.UnmarshalText(fields[0]); err != nil {
 		return err
 	}
+	if l.entries != nil && expectSum != wantSum {
+		return fmt.Errorf("commit %v: expected %v from log", expectSum, wantSum)
+	}
 	if !bytes.Equal(fields[1], []byte(object.TypeCommit)) {
 		return fmt.Errorf("commit %v: object is a %s", expectSum, fields[1])
 	}
@@ -241,12 +487,15 @@ func (l *Log) next() error {
 	}
 	// Validate commit object matches hash.
 	data = data[:len(data)-1]
-	l.hash.Reset()
+	if format := expectSum.ObjectFormat(); l.hash == nil || l.hashFormat != format {
+		l.hash = format.New()
+		l.hashFormat = format
+	} else {
+		l.hash.Reset()
+	}
 	l.hash.Write(object.AppendPrefix(nil, object.TypeCommit, int64(size)))
 	l.hash.Write(data)
-	var gotSum Hash
-	l.hash.Sum(gotSum[:0])
-	if gotSum != expectSum {
+	if gotSum := l.hashFormat.Sum(l.hash); gotSum != expectSum {
 		return fmt.Errorf("commit %v: data does not match object ID", expectSum)
 	}
 	// Parse commit.
@@ -264,6 +513,164 @@ func (l *Log) CommitInfo() *object.Commit {
 	return l.info
 }
 
+// ChangedFiles returns the files changed by the most recently scanned log entry
+// relative to its first parent. It returns nil unless
+// [LogOptions.ChangedFiles] or [LogOptions.LineCounts] was set.
+// The returned files do not have hunks.
+func (l *Log) ChangedFiles() []*DiffFile {
+	return l.files
+}
+
+// Refs returns the refs that point to the most recently scanned log entry,
+// like HEAD, refs/heads/main, or refs/tags/v1.0.0.
+// It returns nil unless [LogOptions.Decorations] was set.
+func (l *Log) Refs() []Ref {
+	return l.refs
+}
+
+// readEntry reads a single commit from the output of
+// `git log -z --format='commit %H' --raw --numstat`,
+// storing its changed files and refs in l.
+func (l *Log) readEntry() (Hash, error) {
+	l.files = nil
+	l.refs = nil
+	header, err := l.entries.ReadString(0)
+	if err != nil {
+		if header == "" && errors.Is(err, io.EOF) {
+			return Hash{}, io.EOF
+		}
+		if errors.Is(err, io.EOF) {
+			return Hash{}, io.ErrUnexpectedEOF
+		}
+		return Hash{}, err
+	}
+	const prefix = "commit "
+	if !strings.HasPrefix(header, prefix) {
+		return Hash{}, fmt.Errorf("log: expected %q, got %q", prefix, header)
+	}
+	id, err := ParseHash(header[len(prefix) : len(header)-1])
+	if err != nil {
+		return Hash{}, fmt.Errorf("log: %w", err)
+	}
+	if l.decorations {
+		decorations, err := l.entries.ReadString(0)
+		if err != nil {
+			return Hash{}, fmt.Errorf("log: commit %v: decorations: %w", id, noEOF(err))
+		}
+		l.refs = parseDecorations(decorations[:len(decorations)-1])
+	}
+
+	numstats := 0
+	for {
+		c, err := l.entries.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Hash{}, err
+		}
+		if c == '\n' || c == 0 {
+			// Separators between the header and the diff.
+			continue
+		}
+		l.entries.UnreadByte()
+		if c == prefix[0] {
+			break
+		}
+		if c == ':' {
+			f, err := l.readRawEntry()
+			if err != nil {
+				return Hash{}, fmt.Errorf("log: commit %v: %w", id, err)
+			}
+			l.files = append(l.files, f)
+			continue
+		}
+		if numstats >= len(l.files) {
+			return Hash{}, fmt.Errorf("log: commit %v: numstat: more entries than files", id)
+		}
+		if err := l.readNumstat(l.files[numstats]); err != nil {
+			return Hash{}, fmt.Errorf("log: commit %v: %w", id, err)
+		}
+		numstats++
+	}
+	return id, nil
+}
+
+// readRawEntry reads a single entry in the `git diff --raw -z` format.
+func (l *Log) readRawEntry() (*DiffFile, error) {
+	info, err := l.entries.ReadString(0)
+	if err != nil {
+		return nil, fmt.Errorf("raw entry: %w", noEOF(err))
+	}
+	nnames := 1
+	if i := strings.LastIndexByte(info, ' '); i != -1 && i+1 < len(info) {
+		if code := DiffStatusCode(info[i+1]); code == DiffStatusRenamed || code == DiffStatusCopied {
+			nnames = 2
+		}
+	}
+	entry, err := l.readNames(info, nnames)
+	if err != nil {
+		return nil, fmt.Errorf("raw entry: %w", err)
+	}
+	f, _, _, err := readDiffRawEntry(entry)
+	return f, err
+}
+
+// readNumstat reads a single entry in the `git diff --numstat -z` format into f.
+func (l *Log) readNumstat(f *DiffFile) error {
+	entry, err := l.entries.ReadString(0)
+	if err != nil {
+		return fmt.Errorf("numstat: %w", noEOF(err))
+	}
+	if strings.HasSuffix(entry, "\t\x00") {
+		// Renames and copies are followed by the source and destination names.
+		entry, err = l.readNames(entry, 2)
+		if err != nil {
+			return fmt.Errorf("numstat: %w", err)
+		}
+	}
+	_, err = readDiffNumstat(f, entry)
+	return err
+}
+
+// readNames appends n NUL-terminated strings to entry.
+func (l *Log) readNames(entry string, n int) (string, error) {
+	for i := 0; i < n; i++ {
+		name, err := l.entries.ReadString(0)
+		if err != nil {
+			return "", noEOF(err)
+		}
+		entry += name
+	}
+	return entry, nil
+}
+
+// parseDecorations parses the output of the `%D` log format
+// with `--decorate=full`.
+func parseDecorations(s string) []Ref {
+	var refs []Ref
+	for _, d := range strings.Split(s, ", ") {
+		const headPrefix = "HEAD -> "
+		if strings.HasPrefix(d, headPrefix) {
+			refs = append(refs, Head)
+			d = d[len(headPrefix):]
+		}
+		d = strings.TrimPrefix(d, "tag: ")
+		// Skip other markers like "grafted".
+		if d == Head.String() || strings.HasPrefix(d, "refs/") {
+			refs = append(refs, Ref(d))
+		}
+	}
+	return refs
+}
+
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
 // Close ends the log subprocess and waits for it to finish.
 // Close returns an error if [Log.Next] returned false due to a parse failure.
 // Subsequent calls to Close will no-op and return the same error.
@@ -276,11 +683,17 @@ func (l *Log) Close() error {
 func (l *Log) cancel() {
 	l.cancelFunc()
 	l.r = nil
+	l.entries = nil
 	l.scanDone = true
 	l.info = nil
+	l.files = nil
+	l.refs = nil
 }
 
 func (l *Log) close() error {
+	if l.catFileIn != nil {
+		l.catFileIn.Close()
+	}
 	var first error
 	for i, c := range l.closers {
 		if c == nil {
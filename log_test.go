@@ -677,6 +677,470 @@ func TestLog_NoWalk(t *testing.T) {
 	}
 }
 
+func TestLog_Filters(t *testing.T) {
+	gitPath, err := findGit()
+	if err != nil {
+		t.Skip("git not found:", err)
+	}
+	ctx := context.Background()
+	env, err := newTestEnv(ctx, gitPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.cleanup()
+
+	const (
+		alice   object.User = "Alice <alice@example.com>"
+		bob     object.User = "Bob <bob@example.com>"
+		charlie object.User = "Charlie <charlie@example.com>"
+	)
+	zone := time.FixedZone("UTC-8", -8*60*60)
+	times := []time.Time{
+		time.Date(2018, time.February, 20, 15, 47, 42, 0, zone),
+		time.Date(2018, time.February, 21, 15, 49, 58, 0, zone),
+		time.Date(2018, time.February, 22, 9, 2, 11, 0, zone),
+		time.Date(2018, time.February, 23, 17, 7, 53, 0, zone),
+		time.Date(2018, time.February, 24, 19, 37, 26, 0, zone),
+	}
+	commitOpts := func(u object.User, t time.Time) CommitOptions {
+		return CommitOptions{
+			Author:     u,
+			AuthorTime: t,
+			Committer:  u,
+			CommitTime: t,
+		}
+	}
+	if err := env.g.Init(ctx, "."); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.root.Apply(filesystem.Write("foo.txt", dummyContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Add(ctx, []Pathspec{"foo.txt"}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "initial import", commitOpts(alice, times[0])); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Run(ctx, "update-ref", "refs/remotes/origin/main", "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.root.Apply(filesystem.Write("bar.txt", dummyContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Add(ctx, []Pathspec{"bar.txt"}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "add bar", commitOpts(bob, times[1])); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Run(ctx, "mv", "foo.txt", "qux.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "rename foo to qux", commitOpts(alice, times[2])); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Run(ctx, "tag", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.NewBranch(ctx, "diverge", BranchOptions{Checkout: true, StartPoint: "HEAD~"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.root.Apply(filesystem.Write("baz.txt", dummyContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Add(ctx, []Pathspec{"baz.txt"}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "add baz", commitOpts(bob, times[3])); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.CheckoutBranch(ctx, "main", CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Merge(ctx, []string{"diverge"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "merge diverge", commitOpts(charlie, times[4])); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      LogOptions
+		want      []string
+		wantError bool
+	}{
+		{
+			name: "Default",
+			opts: LogOptions{},
+			want: []string{"merge diverge", "add baz", "rename foo to qux", "add bar", "initial import"},
+		},
+		{
+			name: "Paths",
+			opts: LogOptions{Paths: []Pathspec{"qux.txt"}},
+			want: []string{"rename foo to qux"},
+		},
+		{
+			name: "Follow",
+			opts: LogOptions{Paths: []Pathspec{"qux.txt"}, Follow: true},
+			want: []string{"rename foo to qux", "initial import"},
+		},
+		{
+			name:      "FollowMultiplePaths",
+			opts:      LogOptions{Paths: []Pathspec{"qux.txt", "bar.txt"}, Follow: true},
+			wantError: true,
+		},
+		{
+			name: "Author",
+			opts: LogOptions{Author: "^Alice"},
+			want: []string{"rename foo to qux", "initial import"},
+		},
+		{
+			name: "Committer",
+			opts: LogOptions{Committer: "bob@example\\.com"},
+			want: []string{"add baz", "add bar"},
+		},
+		{
+			name: "Grep",
+			opts: LogOptions{Grep: "^add "},
+			want: []string{"add baz", "add bar"},
+		},
+		{
+			name: "SinceUntil",
+			opts: LogOptions{Since: times[2], Until: times[3]},
+			want: []string{"add baz", "rename foo to qux"},
+		},
+		{
+			name: "TopoOrder",
+			opts: LogOptions{Order: TopoOrder},
+			want: []string{"merge diverge", "add baz", "rename foo to qux", "add bar", "initial import"},
+		},
+		{
+			name: "DateOrder",
+			opts: LogOptions{Order: DateOrder, Reverse: true},
+			want: []string{"initial import", "add bar", "rename foo to qux", "add baz", "merge diverge"},
+		},
+		{
+			name: "AuthorDateOrder",
+			opts: LogOptions{Order: AuthorDateOrder, Revs: []string{"diverge"}},
+			want: []string{"add baz", "add bar", "initial import"},
+		},
+		{
+			name:      "UnknownOrder",
+			opts:      LogOptions{Order: LogOrder(42)},
+			wantError: true,
+		},
+		{
+			name: "Tags",
+			opts: LogOptions{Tags: true},
+			want: []string{"rename foo to qux", "add bar", "initial import"},
+		},
+		{
+			name: "Remotes",
+			opts: LogOptions{Remotes: true},
+			want: []string{"initial import"},
+		},
+		{
+			name: "Branches",
+			opts: LogOptions{Branches: true, MaxParents: 1},
+			want: []string{"add baz", "rename foo to qux", "add bar", "initial import"},
+		},
+		{
+			name: "All",
+			opts: LogOptions{All: true, Limit: 1},
+			want: []string{"merge diverge"},
+		},
+		{
+			name: "Skip",
+			opts: LogOptions{Skip: 1, Limit: 2},
+			want: []string{"add baz", "rename foo to qux"},
+		},
+		{
+			name: "MinParents",
+			opts: LogOptions{MinParents: 2},
+			want: []string{"merge diverge"},
+		},
+		{
+			name: "AncestryPath",
+			opts: LogOptions{Revs: []string{"v1..main"}, AncestryPath: true},
+			want: []string{"merge diverge"},
+		},
+		{
+			name: "SimplifyByDecoration",
+			opts: LogOptions{SimplifyByDecoration: true},
+			want: []string{"merge diverge", "add baz", "rename foo to qux", "initial import"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log, err := env.g.Log(ctx, test.opts)
+			if err != nil {
+				if !test.wantError {
+					t.Fatal("Log:", err)
+				}
+				return
+			}
+			var got []string
+			for log.Next() {
+				got = append(got, strings.TrimSuffix(log.CommitInfo().Message, "\n"))
+			}
+			err = log.Close()
+			if test.wantError {
+				if err == nil {
+					t.Error("Log did not return an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Error("Close:", err)
+			}
+			if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("log messages (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLog_ChangedFiles(t *testing.T) {
+	gitPath, err := findGit()
+	if err != nil {
+		t.Skip("git not found:", err)
+	}
+	ctx := context.Background()
+	env, err := newTestEnv(ctx, gitPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.cleanup()
+	if version, err := env.g.getVersion(ctx); err != nil {
+		t.Fatal(err)
+	} else if major, minor, ok := parseVersion(version); !ok || major < 2 || major == 2 && minor < 31 {
+		t.Skipf("%s does not support --diff-merges=first-parent", strings.TrimSpace(version))
+	}
+
+	const longContent = "Lorem ipsum dolor sit amet, consectetur adipiscing elit.\n" +
+		"Sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.\n" +
+		"Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris.\n"
+	if err := env.g.Init(ctx, "."); err != nil {
+		t.Fatal(err)
+	}
+	err = env.root.Apply(
+		filesystem.Write("foo.txt", longContent),
+		filesystem.Write("bin", "\x00\x01\x02"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Add(ctx, []Pathspec{"."}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "initial import", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Run(ctx, "tag", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Run(ctx, "mv", "foo.txt", "bar.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.root.Apply(filesystem.Write("bar.txt", longContent+"more\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Remove(ctx, []Pathspec{"bin"}, RemoveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.CommitAll(ctx, "rename foo", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.NewBranch(ctx, "side", BranchOptions{Checkout: true, StartPoint: "HEAD~"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.root.Apply(filesystem.Write("baz.txt", dummyContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Add(ctx, []Pathspec{"baz.txt"}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "add baz", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.CheckoutBranch(ctx, "main", CheckoutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Merge(ctx, []string{"side"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.g.Commit(ctx, "merge side", CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	type entry struct {
+		Message string
+		Files   []*DiffFile
+		Refs    []Ref
+	}
+	log, err := env.g.Log(ctx, LogOptions{
+		LineCounts:  true,
+		Decorations: true,
+		Order:       TopoOrder,
+	})
+	if err != nil {
+		t.Fatal("Log:", err)
+	}
+	var got []entry
+	for log.Next() {
+		got = append(got, entry{
+			Message: strings.TrimSuffix(log.CommitInfo().Message, "\n"),
+			Files:   log.ChangedFiles(),
+			Refs:    log.Refs(),
+		})
+	}
+	if err := log.Close(); err != nil {
+		t.Error("Close:", err)
+	}
+	fooSum := blobSum(longContent)
+	barSum := blobSum(longContent + "more\n")
+	binSum := blobSum("\x00\x01\x02")
+	bazSum := blobSum(dummyContent)
+	want := []entry{
+		{
+			Message: "merge side",
+			Files: []*DiffFile{{
+				Status:      DiffStatusAdded,
+				Name:        "baz.txt",
+				NewMode:     object.ModePlain,
+				NewObjectID: bazSum,
+				Added:       1,
+			}},
+			Refs: []Ref{Head, "refs/heads/main"},
+		},
+		{
+			Message: "add baz",
+			Files: []*DiffFile{{
+				Status:      DiffStatusAdded,
+				Name:        "baz.txt",
+				NewMode:     object.ModePlain,
+				NewObjectID: bazSum,
+				Added:       1,
+			}},
+			Refs: []Ref{"refs/heads/side"},
+		},
+		{
+			Message: "rename foo",
+			Files: []*DiffFile{
+				{
+					Status:      DiffStatusRenamed,
+					Similarity:  97,
+					Name:        "bar.txt",
+					OldName:     "foo.txt",
+					OldMode:     object.ModePlain,
+					NewMode:     object.ModePlain,
+					OldObjectID: fooSum,
+					NewObjectID: barSum,
+					Added:       1,
+				},
+				{
+					Status:      DiffStatusDeleted,
+					Name:        "bin",
+					OldMode:     object.ModePlain,
+					OldObjectID: binSum,
+					Binary:      true,
+				},
+			},
+		},
+		{
+			Message: "initial import",
+			Files: []*DiffFile{
+				{
+					Status:      DiffStatusAdded,
+					Name:        "bin",
+					NewMode:     object.ModePlain,
+					NewObjectID: binSum,
+					Binary:      true,
+				},
+				{
+					Status:      DiffStatusAdded,
+					Name:        "foo.txt",
+					NewMode:     object.ModePlain,
+					NewObjectID: fooSum,
+					Added:       3,
+				},
+			},
+			Refs: []Ref{"refs/tags/v1"},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("log (-want +got):\n%s", diff)
+	}
+
+	t.Run("Paths", func(t *testing.T) {
+		log, err := env.g.Log(ctx, LogOptions{
+			Paths:        []Pathspec{"bar.txt"},
+			Follow:       true,
+			ChangedFiles: true,
+		})
+		if err != nil {
+			t.Fatal("Log:", err)
+		}
+		var got [][]TopPath
+		for log.Next() {
+			var names []TopPath
+			for _, f := range log.ChangedFiles() {
+				names = append(names, f.Name)
+			}
+			got = append(got, names)
+			if log.Refs() != nil {
+				t.Errorf("Refs() = %q; want nil", log.Refs())
+			}
+		}
+		if err := log.Close(); err != nil {
+			t.Error("Close:", err)
+		}
+		want := [][]TopPath{{"bar.txt"}, {"foo.txt"}}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("changed files (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("CloseEarly", func(t *testing.T) {
+		log, err := env.g.Log(ctx, LogOptions{ChangedFiles: true})
+		if err != nil {
+			t.Fatal("Log:", err)
+		}
+		if !log.Next() {
+			t.Fatal("Next() = false")
+		}
+		if err := log.Close(); err != nil {
+			t.Error("Close:", err)
+		}
+		if log.Next() {
+			t.Error("Next() = true after Close")
+		}
+	})
+}
+
+func TestParseDecorations(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []Ref
+	}{
+		{"", nil},
+		{"HEAD", []Ref{Head}},
+		{"HEAD -> refs/heads/main", []Ref{Head, "refs/heads/main"}},
+		{
+			"HEAD -> refs/heads/main, tag: refs/tags/v1, refs/remotes/origin/main",
+			[]Ref{Head, "refs/heads/main", "refs/tags/v1", "refs/remotes/origin/main"},
+		},
+		{"refs/heads/main, grafted", []Ref{"refs/heads/main"}},
+	}
+	for _, test := range tests {
+		got := parseDecorations(test.s)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("parseDecorations(%q) (-want +got):\n%s", test.s, diff)
+		}
+	}
+}
+
 func equateTruncatedTime(d time.Duration) cmp.Option {
 	return cmp.Comparer(func(t1, t2 time.Time) bool {
 		return t1.Truncate(d).Equal(t2.Truncate(d))
@@ -27,6 +27,7 @@ import (
 	"sort"
 	"strings"
 
+	"gg-scm.io/pkg/git/githash"
 	"gg-scm.io/pkg/git/object"
 	"gg-scm.io/pkg/git/packfile"
 )
@@ -225,7 +226,7 @@ func deltaObject() (err error) {
 	deltaObjectOffset, err := w.WriteHeader(&packfile.Header{
 		Type:       packfile.RefDelta,
 		Size:       int64(len(deltaContent)),
-		BaseObject: baseBlobHash,
+		BaseObject: githash.SHA1(baseBlobHash).ObjectID(),
 	})
 	if err != nil {
 		return err
@@ -223,6 +223,68 @@ func (spec FetchRefspec) Map(remote Ref) Ref {
 	return Ref(dstPattern)
 }
 
+// A PushRefspec specifies a mapping from local refs to remote refs.
+// A refspec with an empty source (like ":refs/heads/old")
+// deletes the destination ref from the remote.
+type PushRefspec string
+
+// String returns the refspec as a string.
+func (spec PushRefspec) String() string {
+	return string(spec)
+}
+
+// Parse parses the refspec into its parts.
+// If the refspec does not have a colon, then dst is empty.
+func (spec PushRefspec) Parse() (src, dst RefPattern, plus bool) {
+	plus = strings.HasPrefix(string(spec), "+")
+	s := string(spec)
+	if plus {
+		s = s[1:]
+	}
+	if i := strings.IndexByte(s, ':'); i != -1 {
+		return RefPattern(s[:i]), RefPattern(s[i+1:]), plus
+	}
+	return RefPattern(s), "", plus
+}
+
+// IsDelete reports whether the refspec deletes its destination ref.
+func (spec PushRefspec) IsDelete() bool {
+	src, dst, _ := spec.Parse()
+	return src == "" && dst != ""
+}
+
+// Map maps a local ref into a remote ref. If the refspec has no destination,
+// then the local ref is pushed to the ref of the same name.
+// A destination that does not start with "refs/" is qualified
+// with the same "refs/heads/" or "refs/tags/" prefix as the local ref.
+// If there is no mapping, then Map returns an empty Ref.
+func (spec PushRefspec) Map(local Ref) Ref {
+	srcPattern, dstPattern, _ := spec.Parse()
+	if srcPattern == "" {
+		return ""
+	}
+	suffix, ok := srcPattern.Match(local)
+	if !ok {
+		return ""
+	}
+	if dstPattern == "" {
+		return local
+	}
+	if prefix, ok := dstPattern.Prefix(); ok {
+		return Ref(prefix + suffix)
+	}
+	switch {
+	case strings.HasPrefix(string(dstPattern), "refs/"):
+		return Ref(dstPattern)
+	case local.IsBranch():
+		return BranchRef(string(dstPattern))
+	case local.IsTag():
+		return TagRef(string(dstPattern))
+	default:
+		return ""
+	}
+}
+
 // A RefPattern is a part of a refspec. It may be either a literal
 // suffix match (e.g. "main" matches "refs/head/main"), or the last
 // component may be a wildcard ('*'), which indicates a prefix match.
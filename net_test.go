@@ -83,6 +83,86 @@ func TestFetchRefspecMap(t *testing.T) {
 	}
 }
 
+func TestPushRefspecParse(t *testing.T) {
+	tests := []struct {
+		spec   PushRefspec
+		src    RefPattern
+		dst    RefPattern
+		plus   bool
+		delete bool
+	}{
+		{spec: "", src: "", dst: "", plus: false},
+		{spec: "main", src: "main", dst: "", plus: false},
+		{spec: "main:other", src: "main", dst: "other", plus: false},
+		{spec: "+refs/heads/*:refs/heads/*", src: "refs/heads/*", dst: "refs/heads/*", plus: true},
+		{spec: ":refs/heads/old", src: "", dst: "refs/heads/old", plus: false, delete: true},
+		{spec: "+:old", src: "", dst: "old", plus: true, delete: true},
+	}
+	for _, test := range tests {
+		src, dst, plus := test.spec.Parse()
+		if src != test.src || dst != test.dst || plus != test.plus {
+			t.Errorf("PushRefspec(%q).Parse() = %q, %q, %t; want %q, %q, %t", test.spec, src, dst, plus, test.src, test.dst, test.plus)
+		}
+		if got := test.spec.IsDelete(); got != test.delete {
+			t.Errorf("PushRefspec(%q).IsDelete() = %t; want %t", test.spec, got, test.delete)
+		}
+	}
+}
+
+func TestPushRefspecMap(t *testing.T) {
+	tests := []struct {
+		spec   PushRefspec
+		local  Ref
+		remote Ref
+	}{
+		{
+			spec:   "main",
+			local:  "refs/heads/main",
+			remote: "refs/heads/main",
+		},
+		{
+			spec:   "+refs/heads/*:refs/heads/mirror/*",
+			local:  "refs/heads/main",
+			remote: "refs/heads/mirror/main",
+		},
+		{
+			spec:   "+refs/heads/*:refs/heads/mirror/*",
+			local:  "refs/tags/v1.0.0",
+			remote: "",
+		},
+		{
+			spec:   "main:refs/for/main",
+			local:  "refs/heads/main",
+			remote: "refs/for/main",
+		},
+		{
+			spec:   "main:feature",
+			local:  "refs/heads/main",
+			remote: "refs/heads/feature",
+		},
+		{
+			spec:   "v1:v1.0",
+			local:  "refs/tags/v1",
+			remote: "refs/tags/v1.0",
+		},
+		{
+			spec:   "HEAD:feature",
+			local:  "HEAD",
+			remote: "",
+		},
+		{
+			spec:   ":refs/heads/main",
+			local:  "refs/heads/main",
+			remote: "",
+		},
+	}
+	for _, test := range tests {
+		if remote := test.spec.Map(test.local); remote != test.remote {
+			t.Errorf("PushRefspec(%q).Map(%q) = %q; want %q", test.spec, test.local, remote, test.remote)
+		}
+	}
+}
+
 func TestRefPatternPrefix(t *testing.T) {
 	tests := []struct {
 		pat    RefPattern
@@ -18,8 +18,6 @@ package object
 
 import (
 	"bytes"
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"io"
 	"strconv"
@@ -32,9 +30,9 @@ import (
 // A Commit is a parsed Git commit object.
 type Commit struct {
 	// Tree is the hash of the commit's tree object.
-	Tree githash.SHA1
+	Tree githash.ObjectID
 	// Parents are the hashes of the commit's parents.
-	Parents []githash.SHA1
+	Parents []githash.ObjectID
 
 	// Author identifies the person who wrote the code.
 	Author User
@@ -97,7 +95,7 @@ func (c *Commit) UnmarshalBinary(data []byte) error {
 	}
 	*c = Commit{}
 	var err error
-	data, err = consumeHex(c.Tree[:], data)
+	c.Tree, data, err = consumeObjectID(data)
 	if err != nil {
 		return fmt.Errorf("parse git commit: tree: %w", err)
 	}
@@ -110,11 +108,14 @@ func (c *Commit) UnmarshalBinary(data []byte) error {
 		if !ok {
 			break
 		}
-		var p githash.SHA1
-		data, err = consumeHex(p[:], data)
+		var p githash.ObjectID
+		p, data, err = consumeObjectID(data)
 		if err != nil {
 			return fmt.Errorf("parse git commit: parent %d: %w", i, err)
 		}
+		if p.ObjectFormat() != c.Tree.ObjectFormat() {
+			return fmt.Errorf("parse git commit: parent %d: object format %v does not match tree (%v)", i, p.ObjectFormat(), c.Tree.ObjectFormat())
+		}
 		c.Parents = append(c.Parents, p)
 		data, ok = consumeString(data, "\n")
 		if !ok {
@@ -166,6 +167,15 @@ func (c *Commit) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// SignedPayload returns the data covered by the commit's signature:
+// the serialized commit without its gpgsig and gpgsig-sha256 headers.
+func (c *Commit) SignedPayload() ([]byte, error) {
+	c2 := *c
+	c2.GPGSignature = nil
+	c2.Extra = c.Extra.without("gpgsig-sha256")
+	return c2.MarshalBinary()
+}
+
 // MarshalText serializes a commit into the Git object format.
 // It is the same as calling [*Commit.MarshalBinary].
 func (c *Commit) MarshalText() ([]byte, error) {
@@ -225,17 +235,21 @@ func tzOffset(t time.Time) string {
 
 // SHA1 computes the SHA-1 hash of the commit object. This is commonly known as
 // the "commit hash" and uniquely identifies the commit.
-func (c *Commit) SHA1() githash.SHA1 {
-	h := sha1.New()
+// It is the same as calling Sum with [githash.SHA1Format].
+func (c *Commit) SHA1() githash.ObjectID {
+	return c.Sum(githash.SHA1Format)
+}
+
+// Sum computes the hash of the commit object using the given object format.
+func (c *Commit) Sum(format githash.ObjectFormat) githash.ObjectID {
+	h := format.New()
 	s, err := c.MarshalText()
 	if err != nil {
 		panic(err)
 	}
 	h.Write(AppendPrefix(nil, TypeCommit, int64(len(s))))
 	h.Write(s)
-	var arr githash.SHA1
-	h.Sum(arr[:0])
-	return arr
+	return format.Sum(h)
 }
 
 // Summary returns the first line of the message.
@@ -259,15 +273,25 @@ func consumeString(src []byte, s string) (_ []byte, ok bool) {
 	return src[len(s):], true
 }
 
-func consumeHex(dst []byte, src []byte) (tail []byte, _ error) {
-	n := hex.EncodedLen(len(dst))
-	if len(src) < n {
-		return src, io.ErrUnexpectedEOF
+// consumeObjectID parses a hex-encoded object ID at the beginning of src.
+// The object format is determined by the number of hex digits.
+func consumeObjectID(src []byte) (_ githash.ObjectID, tail []byte, _ error) {
+	n := 0
+	for n < len(src) && isHexDigit(src[n]) {
+		n++
+	}
+	if len(src) == 0 {
+		return githash.ObjectID{}, src, io.ErrUnexpectedEOF
 	}
-	if _, err := hex.Decode(dst, src[:n]); err != nil {
-		return src, err
+	var id githash.ObjectID
+	if err := id.UnmarshalText(src[:n]); err != nil {
+		return githash.ObjectID{}, src, err
 	}
-	return src[n:], nil
+	return id, src[n:], nil
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
 }
 
 func consumeUser(src []byte) (_ User, _ time.Time, tail []byte, _ error) {
@@ -506,6 +530,19 @@ func (fields CommitFields) Get(key string) string {
 	return ""
 }
 
+// without returns fields without any fields with the given key.
+func (fields CommitFields) without(key string) CommitFields {
+	var kept []string
+	for fields != "" {
+		head, tail := fields.Cut()
+		if k, _ := head.cutKV(); k != key {
+			kept = append(kept, string(head))
+		}
+		fields = tail
+	}
+	return CommitFields(strings.Join(kept, "\n"))
+}
+
 func normalizeContinuations(s string) string {
 	return strings.ReplaceAll(s, "\n ", "\n")
 }
@@ -17,8 +17,8 @@
 package object
 
 import (
-	"bytes"
 	"encoding"
+	"strings"
 	"testing"
 	"time"
 
@@ -36,7 +36,7 @@ var (
 
 var gitCommitTests = []struct {
 	name   string
-	id     githash.SHA1
+	id     githash.ObjectID
 	data   string
 	parsed *Commit
 }{
@@ -68,7 +68,7 @@ var gitCommitTests = []struct {
 			"Add zv root command\n",
 		parsed: &Commit{
 			Tree: hashLiteral("e69c497a490ecaf78f377810e715f0340aa5a10e"),
-			Parents: []githash.SHA1{
+			Parents: []githash.ObjectID{
 				hashLiteral("aff248747f6a94066967a75e30a5b025816a6aef"),
 			},
 			Author:     "Ross Light <ross@zombiezen.com>",
@@ -100,7 +100,7 @@ var gitCommitTests = []struct {
 			"Create NOTES.md",
 		parsed: &Commit{
 			Tree: hashLiteral("045bad13340b59b9e50c94051200d9f1a729861e"),
-			Parents: []githash.SHA1{
+			Parents: []githash.ObjectID{
 				hashLiteral("b64df08d9368c7a11a4093cc04cf6a307241cf0c"),
 			},
 			Author:     "Ross Light <ross@zombiezen.com>",
@@ -158,7 +158,7 @@ var gitCommitTests = []struct {
 			"Version 0.3.1: fixes + QuickFix shortcut\n",
 		parsed: &Commit{
 			Tree:       hashLiteral("2e0f03b8f4bbcdcb7c7925c54829af0426b1f47d"),
-			Parents:    []githash.SHA1{hashLiteral("a8fb1d583d1341f1a5449f7857a817a139f7ee44")},
+			Parents:    []githash.ObjectID{hashLiteral("a8fb1d583d1341f1a5449f7857a817a139f7ee44")},
 			Author:     "Marc Weber <marco-oweber@gmx.de>",
 			AuthorTime: time.Unix(1263859200, 0).In(time.FixedZone("+0000", 0)),
 			Committer:  "Able Scraper <scraper@vim-scripts.org>",
@@ -197,17 +197,108 @@ func TestCommitMarshalText(t *testing.T) {
 	}
 }
 
+func TestCommitSignedPayload(t *testing.T) {
+	for _, test := range gitCommitTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.parsed.SignedPayload()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Remove the gpgsig header and its continuation lines.
+			want := new(strings.Builder)
+			inSig := false
+			for _, line := range strings.SplitAfter(test.data, "\n") {
+				if strings.HasPrefix(line, "gpgsig ") || inSig && strings.HasPrefix(line, " ") {
+					inSig = true
+					continue
+				}
+				inSig = false
+				want.WriteString(line)
+			}
+			if diff := cmp.Diff(want.String(), string(got)); diff != "" {
+				t.Errorf("payload (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("SHA256Signature", func(t *testing.T) {
+		c := &Commit{
+			Tree:       hashLiteral("045bad13340b59b9e50c94051200d9f1a729861e"),
+			Author:     "Octocat <octocat@example.com>",
+			AuthorTime: time.Unix(1578581400, 0).In(time.FixedZone("+0000", 0)),
+			Committer:  "Octocat <octocat@example.com>",
+			CommitTime: time.Unix(1578581400, 0).In(time.FixedZone("+0000", 0)),
+			Extra: "encoding ISO-8859-1\n" +
+				"gpgsig-sha256 -----BEGIN SSH SIGNATURE-----\n" +
+				" AAAA\n" +
+				" -----END SSH SIGNATURE-----",
+			Message: "Hello\n",
+		}
+		got, err := c.SignedPayload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		const want = "tree 045bad13340b59b9e50c94051200d9f1a729861e\n" +
+			"author Octocat <octocat@example.com> 1578581400 +0000\n" +
+			"committer Octocat <octocat@example.com> 1578581400 +0000\n" +
+			"encoding ISO-8859-1\n" +
+			"\n" +
+			"Hello\n"
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Errorf("payload (-want +got):\n%s", diff)
+		}
+	})
+}
+
 func TestCommitSHA1(t *testing.T) {
 	for _, test := range gitCommitTests {
 		t.Run(test.name, func(t *testing.T) {
 			got := test.parsed.SHA1()
-			if !bytes.Equal(got[:], test.id[:]) {
+			if got != test.id {
 				t.Errorf("sha1() = %x; want %x", got, test.id)
 			}
 		})
 	}
 }
 
+func TestCommitSHA256(t *testing.T) {
+	const data = "tree 11a3209a9d7c016713f68898d71d1360a5d580911ba0d46bb50cbafe4a2968f7\n" +
+		"author Octocat <octocat@example.com> 1578610200 -0800\n" +
+		"committer Octocat <octocat@example.com> 1578610200 -0800\n" +
+		"\n" +
+		"Initial import\n"
+	want := &Commit{
+		Tree:       hashLiteral("11a3209a9d7c016713f68898d71d1360a5d580911ba0d46bb50cbafe4a2968f7"),
+		Author:     "Octocat <octocat@example.com>",
+		AuthorTime: time.Unix(1578610200, 0).In(time.FixedZone("-0800", -8*60*60)),
+		Committer:  "Octocat <octocat@example.com>",
+		CommitTime: time.Unix(1578610200, 0).In(time.FixedZone("-0800", -8*60*60)),
+		Message:    "Initial import\n",
+	}
+	got, err := ParseCommit([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("commit (-want +got):\n%s", diff)
+	}
+	wantID := hashLiteral("963218adeeac3502277b2150c0bf556e518898cec606ca5fb2fc78c9abe24d01")
+	if gotID := got.Sum(githash.SHA256Format); gotID != wantID {
+		t.Errorf("Sum(githash.SHA256Format) = %v; want %v", gotID, wantID)
+	}
+
+	t.Run("MixedParents", func(t *testing.T) {
+		mixed := "tree 11a3209a9d7c016713f68898d71d1360a5d580911ba0d46bb50cbafe4a2968f7\n" +
+			"parent a8fb1d583d1341f1a5449f7857a817a139f7ee44\n" +
+			data[len("tree 11a3209a9d7c016713f68898d71d1360a5d580911ba0d46bb50cbafe4a2968f7\n"):]
+		if _, err := ParseCommit([]byte(mixed)); err == nil {
+			t.Error("ParseCommit did not return an error")
+		} else {
+			t.Log("Error:", err)
+		}
+	})
+}
+
 func TestUser(t *testing.T) {
 	tests := []struct {
 		u     User
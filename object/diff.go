@@ -0,0 +1,521 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"sort"
+
+	"gg-scm.io/pkg/git/githash"
+)
+
+// A TreeReader loads the subtrees referenced by a tree.
+// *gitrepo.Repository implements TreeReader.
+type TreeReader interface {
+	ReadTree(id githash.ObjectID) (Tree, error)
+}
+
+// An ObjectReader loads the subtrees and blobs referenced by a tree.
+// *gitrepo.Repository implements ObjectReader.
+type ObjectReader interface {
+	TreeReader
+	ReadBlob(id githash.ObjectID) ([]byte, error)
+}
+
+// DiffTreesOptions specifies optional parameters to DiffTrees.
+type DiffTreesOptions struct {
+	// DetectRenames enables rename detection.
+	// Deleted files are paired with added files that have the same content
+	// or are at least RenameThreshold percent similar.
+	DetectRenames bool
+	// DetectCopies enables copy detection in addition to rename detection.
+	// Added files may be paired with modified files as well as deleted files.
+	DetectCopies bool
+	// FindCopiesHarder considers unmodified files as copy sources
+	// in addition to modified files. It has no effect unless
+	// DetectCopies is set. It is expensive for large trees.
+	FindCopiesHarder bool
+
+	// RenameThreshold is the minimum similarity percentage of a rename or copy
+	// that does not have identical content. If zero, 50 is used.
+	RenameThreshold int
+	// RenameLimit is the maximum number of sources or destinations
+	// considered for inexact rename and copy detection.
+	// If zero, 1000 is used. If negative, there is no limit.
+	RenameLimit int
+}
+
+// A TreeChange describes a single file that differs between two trees.
+type TreeChange struct {
+	// Code is the kind of change. It uses the same letters
+	// as `git diff --name-status`.
+	Code ChangeCode
+	// Name is the slash-separated path of the file
+	// relative to the root of the trees.
+	Name string
+	// OldName is the path of the source file for renames and copies.
+	// It is empty for other changes.
+	OldName string
+	// Similarity is the percentage of the file that was unchanged
+	// for renames and copies. It is zero for other changes.
+	Similarity int
+
+	// OldMode and NewMode are the file's modes before and after the change.
+	// OldMode is zero for added files and NewMode is zero for deleted files.
+	OldMode Mode
+	NewMode Mode
+	// OldObjectID and NewObjectID are the file's object IDs
+	// before and after the change. OldObjectID is zero for added files
+	// and NewObjectID is zero for deleted files.
+	OldObjectID githash.ObjectID
+	NewObjectID githash.ObjectID
+}
+
+// ChangeCode is a single-letter code describing a TreeChange.
+type ChangeCode byte
+
+// Change codes.
+const (
+	ChangeAdded       ChangeCode = 'A'
+	ChangeCopied      ChangeCode = 'C'
+	ChangeDeleted     ChangeCode = 'D'
+	ChangeModified    ChangeCode = 'M'
+	ChangeRenamed     ChangeCode = 'R'
+	ChangeTypeChanged ChangeCode = 'T'
+)
+
+// String returns the code letter as a string.
+func (code ChangeCode) String() string {
+	return string(code)
+}
+
+// DiffTrees compares two trees recursively, like `git diff-tree -r`.
+// Subtrees and (if needed for rename detection) blobs are loaded from r.
+// Subtrees with identical object IDs are skipped without being loaded.
+// Either tree may be nil, in which case it is treated as empty.
+// The returned changes are sorted by Name.
+func DiffTrees(r ObjectReader, tree1, tree2 Tree, opts *DiffTreesOptions) ([]*TreeChange, error) {
+	if opts == nil {
+		opts = new(DiffTreesOptions)
+	}
+	d := &treeDiffer{
+		r:      r,
+		opts:   opts,
+		harder: opts.DetectCopies && opts.FindCopiesHarder,
+	}
+	if err := d.diff("", tree1, tree2); err != nil {
+		return nil, fmt.Errorf("diff trees: %w", err)
+	}
+	if opts.DetectRenames || opts.DetectCopies {
+		if err := d.detectRenames(); err != nil {
+			return nil, fmt.Errorf("diff trees: %w", err)
+		}
+	}
+	sort.Slice(d.changes, func(i, j int) bool {
+		return d.changes[i].Name < d.changes[j].Name
+	})
+	return d.changes, nil
+}
+
+type treeDiffer struct {
+	r      ObjectReader
+	opts   *DiffTreesOptions
+	harder bool
+
+	changes []*TreeChange
+	// unchanged is the list of files that are the same in both trees.
+	// It is only populated when finding copies harder.
+	unchanged []*TreeChange
+}
+
+func (d *treeDiffer) diff(prefix string, tree1, tree2 Tree) error {
+	names2 := make(map[string]*TreeEntry, len(tree2))
+	for _, ent := range tree2 {
+		names2[ent.Name] = ent
+	}
+	for _, ent1 := range tree1 {
+		name := prefix + ent1.Name
+		ent2 := names2[ent1.Name]
+		delete(names2, ent1.Name)
+		switch {
+		case ent2 == nil:
+			if err := d.add(name, ent1, ChangeDeleted); err != nil {
+				return err
+			}
+		case ent1.Mode.IsDir() && ent2.Mode.IsDir():
+			if ent1.ObjectID == ent2.ObjectID {
+				if d.harder {
+					if err := d.add(name, ent1, 0); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			sub1, err := d.r.ReadTree(ent1.ObjectID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			sub2, err := d.r.ReadTree(ent2.ObjectID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err := d.diff(name+"/", sub1, sub2); err != nil {
+				return err
+			}
+		case ent1.Mode.IsDir() || ent2.Mode.IsDir():
+			// A directory replaced by a file or vice versa.
+			if err := d.add(name, ent1, ChangeDeleted); err != nil {
+				return err
+			}
+			if err := d.add(name, ent2, ChangeAdded); err != nil {
+				return err
+			}
+		case ent1.ObjectID == ent2.ObjectID && ent1.Mode == ent2.Mode:
+			if d.harder {
+				if err := d.add(name, ent1, 0); err != nil {
+					return err
+				}
+			}
+		default:
+			code := ChangeModified
+			if ent1.Mode&typeMask != ent2.Mode&typeMask {
+				code = ChangeTypeChanged
+			}
+			d.changes = append(d.changes, &TreeChange{
+				Code:        code,
+				Name:        name,
+				OldMode:     ent1.Mode,
+				NewMode:     ent2.Mode,
+				OldObjectID: ent1.ObjectID,
+				NewObjectID: ent2.ObjectID,
+			})
+		}
+	}
+	for _, ent2 := range tree2 {
+		if names2[ent2.Name] == nil {
+			continue
+		}
+		if err := d.add(prefix+ent2.Name, ent2, ChangeAdded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add records every file in ent with the given code.
+// If code is zero, the files are recorded as unchanged copy sources.
+func (d *treeDiffer) add(name string, ent *TreeEntry, code ChangeCode) error {
+	if ent.Mode.IsDir() {
+		tree, err := d.r.ReadTree(ent.ObjectID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, sub := range tree {
+			if err := d.add(name+"/"+sub.Name, sub, code); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	c := &TreeChange{Code: code, Name: name}
+	switch code {
+	case ChangeAdded:
+		c.NewMode = ent.Mode
+		c.NewObjectID = ent.ObjectID
+	case ChangeDeleted:
+		c.OldMode = ent.Mode
+		c.OldObjectID = ent.ObjectID
+	default:
+		c.OldMode = ent.Mode
+		c.NewMode = ent.Mode
+		c.OldObjectID = ent.ObjectID
+		c.NewObjectID = ent.ObjectID
+		d.unchanged = append(d.unchanged, c)
+		return nil
+	}
+	d.changes = append(d.changes, c)
+	return nil
+}
+
+// renameSource is a file that an added file may have been renamed or copied from.
+type renameSource struct {
+	name    string
+	mode    Mode
+	id      githash.ObjectID
+	deleted *TreeChange // non-nil if the file was deleted
+	renamed bool        // whether the deletion has been paired with a rename
+}
+
+// detectRenames pairs added files with their sources,
+// modifying d.changes in place.
+func (d *treeDiffer) detectRenames() error {
+	var srcs []*renameSource
+	var dsts []*TreeChange
+	for _, c := range d.changes {
+		switch {
+		case c.Code == ChangeDeleted && c.OldMode&typeMask != ModeGitlink:
+			srcs = append(srcs, &renameSource{name: c.Name, mode: c.OldMode, id: c.OldObjectID, deleted: c})
+		case c.Code == ChangeAdded && c.NewMode&typeMask != ModeGitlink:
+			dsts = append(dsts, c)
+		case d.opts.DetectCopies && (c.Code == ChangeModified || c.Code == ChangeTypeChanged) && c.OldMode&typeMask != ModeGitlink:
+			srcs = append(srcs, &renameSource{name: c.Name, mode: c.OldMode, id: c.OldObjectID})
+		}
+	}
+	for _, c := range d.unchanged {
+		if c.OldMode&typeMask != ModeGitlink {
+			srcs = append(srcs, &renameSource{name: c.Name, mode: c.OldMode, id: c.OldObjectID})
+		}
+	}
+	if len(srcs) == 0 || len(dsts) == 0 {
+		return nil
+	}
+	// Like Git, don't pair up empty files, since they are indistinguishable.
+	emptyBlob := emptyBlobID(dsts[0].NewObjectID.ObjectFormat())
+
+	// First, pair files with identical content.
+	srcsByID := make(map[githash.ObjectID][]*renameSource)
+	for _, src := range srcs {
+		if src.id != emptyBlob {
+			srcsByID[src.id] = append(srcsByID[src.id], src)
+		}
+	}
+	remaining := dsts[:0:0]
+	for _, dst := range dsts {
+		var best *renameSource
+		for _, src := range srcsByID[dst.NewObjectID] {
+			if !d.canPair(src, dst) {
+				continue
+			}
+			if best == nil || betterExactSource(src, best, dst) {
+				best = src
+			}
+		}
+		if best == nil {
+			remaining = append(remaining, dst)
+			continue
+		}
+		d.pair(best, dst, 100)
+	}
+	if err := d.pairSimilar(srcs, remaining, emptyBlob); err != nil {
+		return err
+	}
+
+	// Remove the deletions that were paired with renames.
+	renamed := make(map[*TreeChange]bool)
+	for _, src := range srcs {
+		if src.deleted != nil && src.renamed {
+			renamed[src.deleted] = true
+		}
+	}
+	changes := d.changes[:0]
+	for _, c := range d.changes {
+		if !renamed[c] {
+			changes = append(changes, c)
+		}
+	}
+	d.changes = changes
+	return nil
+}
+
+// pairSimilar pairs added files with sources that have similar content.
+func (d *treeDiffer) pairSimilar(srcs []*renameSource, dsts []*TreeChange, emptyBlob githash.ObjectID) error {
+	threshold := d.opts.RenameThreshold
+	if threshold <= 0 {
+		threshold = 50
+	}
+	limit := d.opts.RenameLimit
+	if limit == 0 {
+		limit = 1000
+	}
+	var candidates []*renameSource
+	for _, src := range srcs {
+		if src.mode&typeMask == ModeSymlink || src.id == emptyBlob {
+			continue
+		}
+		if src.deleted != nil && src.renamed && !d.opts.DetectCopies {
+			continue
+		}
+		candidates = append(candidates, src)
+	}
+	remaining := dsts[:0:0]
+	for _, dst := range dsts {
+		if dst.NewMode&typeMask != ModeSymlink && dst.NewObjectID != emptyBlob {
+			remaining = append(remaining, dst)
+		}
+	}
+	dsts = remaining
+	if len(candidates) == 0 || len(dsts) == 0 ||
+		limit > 0 && (len(candidates) > limit || len(dsts) > limit) {
+		return nil
+	}
+	type match struct {
+		src   *renameSource
+		dst   *TreeChange
+		score int
+	}
+	var matches []match
+	spans := make(map[githash.ObjectID]*spanCounts)
+	loadSpans := func(id githash.ObjectID) (*spanCounts, error) {
+		if s := spans[id]; s != nil {
+			return s, nil
+		}
+		data, err := d.r.ReadBlob(id)
+		if err != nil {
+			return nil, err
+		}
+		s := countSpans(data)
+		spans[id] = s
+		return s, nil
+	}
+	for _, dst := range dsts {
+		dstSpans, err := loadSpans(dst.NewObjectID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", dst.Name, err)
+		}
+		for _, src := range candidates {
+			if !d.canPair(src, dst) {
+				continue
+			}
+			srcSpans, err := loadSpans(src.id)
+			if err != nil {
+				return fmt.Errorf("%s: %w", src.name, err)
+			}
+			if score := similarity(srcSpans, dstSpans, threshold); score >= threshold {
+				matches = append(matches, match{src, dst, score})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	paired := make(map[*TreeChange]bool)
+	for _, m := range matches {
+		if paired[m.dst] || !d.canPair(m.src, m.dst) {
+			continue
+		}
+		d.pair(m.src, m.dst, m.score)
+		paired[m.dst] = true
+	}
+	return nil
+}
+
+// canPair reports whether dst can be recorded as a rename or copy of src.
+func (d *treeDiffer) canPair(src *renameSource, dst *TreeChange) bool {
+	if src.mode&typeMask != dst.NewMode&typeMask {
+		return false
+	}
+	if src.deleted != nil && !src.renamed {
+		return true
+	}
+	return d.opts.DetectCopies
+}
+
+// pair records dst as a rename or copy of src.
+func (d *treeDiffer) pair(src *renameSource, dst *TreeChange, score int) {
+	dst.Code = ChangeCopied
+	if src.deleted != nil && !src.renamed {
+		dst.Code = ChangeRenamed
+		src.renamed = true
+	}
+	dst.OldName = src.name
+	dst.OldMode = src.mode
+	dst.OldObjectID = src.id
+	dst.Similarity = score
+}
+
+// betterExactSource reports whether src is a better source for dst than best
+// when both have identical content. Unused deletions are preferred,
+// then sources with the same base name.
+func betterExactSource(src, best *renameSource, dst *TreeChange) bool {
+	srcRename := src.deleted != nil && !src.renamed
+	bestRename := best.deleted != nil && !best.renamed
+	if srcRename != bestRename {
+		return srcRename
+	}
+	base := path.Base(dst.Name)
+	return path.Base(src.name) == base && path.Base(best.name) != base
+}
+
+func emptyBlobID(format githash.ObjectFormat) githash.ObjectID {
+	if !format.IsValid() {
+		return githash.ObjectID{}
+	}
+	id, _ := BlobSumFormat(format, bytes.NewReader(nil), 0)
+	return id
+}
+
+// spanCounts is a summary of a file's content used to estimate similarity.
+type spanCounts struct {
+	size   int
+	counts map[uint32]int
+}
+
+// maxSpanLen is the maximum length of a span.
+const maxSpanLen = 64
+
+// countSpans splits data into lines of at most maxSpanLen bytes
+// and counts the number of bytes in each distinct span.
+// Like Git, carriage returns before newlines are ignored.
+func countSpans(data []byte) *spanCounts {
+	s := &spanCounts{
+		size:   len(data),
+		counts: make(map[uint32]int),
+	}
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 || n > maxSpanLen {
+			n = len(data)
+			if n > maxSpanLen {
+				n = maxSpanLen
+			}
+		}
+		span := data[:n]
+		data = data[n:]
+		if bytes.HasSuffix(span, []byte("\r\n")) {
+			span = append(span[:len(span)-2:len(span)-2], '\n')
+		}
+		h := fnv.New32a()
+		h.Write(span)
+		s.counts[h.Sum32()] += n
+	}
+	return s
+}
+
+// similarity returns the percentage of content shared by two files.
+// It returns 0 without comparing the files if their sizes are too different
+// for the similarity to reach the threshold.
+func similarity(src, dst *spanCounts, threshold int) int {
+	maxSize, minSize := src.size, dst.size
+	if minSize > maxSize {
+		maxSize, minSize = minSize, maxSize
+	}
+	if maxSize == 0 || minSize*100 < maxSize*threshold {
+		return 0
+	}
+	shared := 0
+	for h, n := range dst.counts {
+		if m := src.counts[h]; m < n {
+			shared += m
+		} else {
+			shared += n
+		}
+	}
+	return shared * 100 / maxSize
+}
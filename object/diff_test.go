@@ -0,0 +1,337 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package object
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gg-scm.io/pkg/git/githash"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffTrees(t *testing.T) {
+	objs := newMemObjects()
+	const (
+		longContent1 = "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+		longContent2 = "1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"
+	)
+	foo1 := objs.blob("foo\n")
+	foo2 := objs.blob("foo2\n")
+	bar := objs.blob("bar\n")
+	long1 := objs.blob(longContent1)
+	long2 := objs.blob(longContent2)
+	unrelated := objs.blob("something else entirely\n")
+	empty := objs.blob("")
+	sub := objs.tree(
+		&TreeEntry{Name: "a.txt", Mode: ModePlain, ObjectID: foo1},
+		&TreeEntry{Name: "b.txt", Mode: ModePlain, ObjectID: bar},
+	)
+
+	tests := []struct {
+		name  string
+		tree1 Tree
+		tree2 Tree
+		opts  *DiffTreesOptions
+		want  []*TreeChange
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name: "Identical",
+			tree1: Tree{
+				{Name: "dir", Mode: ModeDir, ObjectID: sub},
+				{Name: "foo.txt", Mode: ModePlain, ObjectID: foo1},
+			},
+			tree2: Tree{
+				{Name: "dir", Mode: ModeDir, ObjectID: sub},
+				{Name: "foo.txt", Mode: ModePlain, ObjectID: foo1},
+			},
+		},
+		{
+			name: "Basic",
+			tree1: Tree{
+				{Name: "dir", Mode: ModeDir, ObjectID: sub},
+				{Name: "foo.txt", Mode: ModePlain, ObjectID: foo1},
+				{Name: "link", Mode: ModePlain, ObjectID: bar},
+				{Name: "script.sh", Mode: ModePlain, ObjectID: bar},
+			},
+			tree2: Tree{
+				{Name: "bar.txt", Mode: ModePlain, ObjectID: bar},
+				{Name: "foo.txt", Mode: ModePlain, ObjectID: foo2},
+				{Name: "link", Mode: ModeSymlink, ObjectID: bar},
+				{Name: "script.sh", Mode: ModeExecutable, ObjectID: bar},
+			},
+			want: []*TreeChange{
+				{Code: ChangeAdded, Name: "bar.txt", NewMode: ModePlain, NewObjectID: bar},
+				{Code: ChangeDeleted, Name: "dir/a.txt", OldMode: ModePlain, OldObjectID: foo1},
+				{Code: ChangeDeleted, Name: "dir/b.txt", OldMode: ModePlain, OldObjectID: bar},
+				{Code: ChangeModified, Name: "foo.txt", OldMode: ModePlain, NewMode: ModePlain, OldObjectID: foo1, NewObjectID: foo2},
+				{Code: ChangeTypeChanged, Name: "link", OldMode: ModePlain, NewMode: ModeSymlink, OldObjectID: bar, NewObjectID: bar},
+				{Code: ChangeModified, Name: "script.sh", OldMode: ModePlain, NewMode: ModeExecutable, OldObjectID: bar, NewObjectID: bar},
+			},
+		},
+		{
+			name: "Subdirectory",
+			tree1: Tree{
+				{Name: "dir", Mode: ModeDir, ObjectID: sub},
+			},
+			tree2: Tree{
+				{Name: "dir", Mode: ModeDir, ObjectID: objs.tree(
+					&TreeEntry{Name: "a.txt", Mode: ModePlain, ObjectID: foo2},
+					&TreeEntry{Name: "b.txt", Mode: ModePlain, ObjectID: bar},
+				)},
+			},
+			want: []*TreeChange{
+				{Code: ChangeModified, Name: "dir/a.txt", OldMode: ModePlain, NewMode: ModePlain, OldObjectID: foo1, NewObjectID: foo2},
+			},
+		},
+		{
+			name: "FileToDirectory",
+			tree1: Tree{
+				{Name: "dir", Mode: ModePlain, ObjectID: foo1},
+			},
+			tree2: Tree{
+				{Name: "dir", Mode: ModeDir, ObjectID: sub},
+			},
+			want: []*TreeChange{
+				{Code: ChangeDeleted, Name: "dir", OldMode: ModePlain, OldObjectID: foo1},
+				{Code: ChangeAdded, Name: "dir/a.txt", NewMode: ModePlain, NewObjectID: foo1},
+				{Code: ChangeAdded, Name: "dir/b.txt", NewMode: ModePlain, NewObjectID: bar},
+			},
+		},
+		{
+			name: "RenamesDisabled",
+			tree1: Tree{
+				{Name: "old.txt", Mode: ModePlain, ObjectID: foo1},
+			},
+			tree2: Tree{
+				{Name: "new.txt", Mode: ModePlain, ObjectID: foo1},
+			},
+			want: []*TreeChange{
+				{Code: ChangeAdded, Name: "new.txt", NewMode: ModePlain, NewObjectID: foo1},
+				{Code: ChangeDeleted, Name: "old.txt", OldMode: ModePlain, OldObjectID: foo1},
+			},
+		},
+		{
+			name: "ExactRename",
+			tree1: Tree{
+				{Name: "dir", Mode: ModeDir, ObjectID: sub},
+			},
+			tree2: Tree{
+				{Name: "dir", Mode: ModeDir, ObjectID: objs.tree(
+					&TreeEntry{Name: "b.txt", Mode: ModePlain, ObjectID: bar},
+				)},
+				{Name: "moved.txt", Mode: ModePlain, ObjectID: foo1},
+			},
+			opts: &DiffTreesOptions{DetectRenames: true},
+			want: []*TreeChange{
+				{Code: ChangeRenamed, Name: "moved.txt", OldName: "dir/a.txt", Similarity: 100, OldMode: ModePlain, NewMode: ModePlain, OldObjectID: foo1, NewObjectID: foo1},
+			},
+		},
+		{
+			name: "ExactRenamePrefersBaseName",
+			tree1: Tree{
+				{Name: "a", Mode: ModeDir, ObjectID: objs.tree(
+					&TreeEntry{Name: "x.txt", Mode: ModePlain, ObjectID: foo1},
+				)},
+				{Name: "b", Mode: ModeDir, ObjectID: objs.tree(
+					&TreeEntry{Name: "y.txt", Mode: ModePlain, ObjectID: foo1},
+				)},
+			},
+			tree2: Tree{
+				{Name: "c", Mode: ModeDir, ObjectID: objs.tree(
+					&TreeEntry{Name: "y.txt", Mode: ModePlain, ObjectID: foo1},
+				)},
+			},
+			opts: &DiffTreesOptions{DetectRenames: true},
+			want: []*TreeChange{
+				{Code: ChangeDeleted, Name: "a/x.txt", OldMode: ModePlain, OldObjectID: foo1},
+				{Code: ChangeRenamed, Name: "c/y.txt", OldName: "b/y.txt", Similarity: 100, OldMode: ModePlain, NewMode: ModePlain, OldObjectID: foo1, NewObjectID: foo1},
+			},
+		},
+		{
+			name: "SimilarRename",
+			tree1: Tree{
+				{Name: "old.txt", Mode: ModePlain, ObjectID: long1},
+				{Name: "other.txt", Mode: ModePlain, ObjectID: foo1},
+			},
+			tree2: Tree{
+				{Name: "new.txt", Mode: ModePlain, ObjectID: long2},
+				{Name: "unrelated.txt", Mode: ModePlain, ObjectID: unrelated},
+			},
+			opts: &DiffTreesOptions{DetectRenames: true},
+			want: []*TreeChange{
+				{Code: ChangeRenamed, Name: "new.txt", OldName: "old.txt", Similarity: 81, OldMode: ModePlain, NewMode: ModePlain, OldObjectID: long1, NewObjectID: long2},
+				{Code: ChangeDeleted, Name: "other.txt", OldMode: ModePlain, OldObjectID: foo1},
+				{Code: ChangeAdded, Name: "unrelated.txt", NewMode: ModePlain, NewObjectID: unrelated},
+			},
+		},
+		{
+			name: "BelowThreshold",
+			tree1: Tree{
+				{Name: "old.txt", Mode: ModePlain, ObjectID: long1},
+			},
+			tree2: Tree{
+				{Name: "new.txt", Mode: ModePlain, ObjectID: long2},
+			},
+			opts: &DiffTreesOptions{DetectRenames: true, RenameThreshold: 90},
+			want: []*TreeChange{
+				{Code: ChangeAdded, Name: "new.txt", NewMode: ModePlain, NewObjectID: long2},
+				{Code: ChangeDeleted, Name: "old.txt", OldMode: ModePlain, OldObjectID: long1},
+			},
+		},
+		{
+			name: "EmptyFilesNotRenamed",
+			tree1: Tree{
+				{Name: "old.txt", Mode: ModePlain, ObjectID: empty},
+			},
+			tree2: Tree{
+				{Name: "new.txt", Mode: ModePlain, ObjectID: empty},
+			},
+			opts: &DiffTreesOptions{DetectRenames: true},
+			want: []*TreeChange{
+				{Code: ChangeAdded, Name: "new.txt", NewMode: ModePlain, NewObjectID: empty},
+				{Code: ChangeDeleted, Name: "old.txt", OldMode: ModePlain, OldObjectID: empty},
+			},
+		},
+		{
+			name: "RenameAndCopy",
+			tree1: Tree{
+				{Name: "old.txt", Mode: ModePlain, ObjectID: foo1},
+			},
+			tree2: Tree{
+				{Name: "new1.txt", Mode: ModePlain, ObjectID: foo1},
+				{Name: "new2.txt", Mode: ModePlain, ObjectID: foo1},
+			},
+			opts: &DiffTreesOptions{DetectCopies: true},
+			want: []*TreeChange{
+				{Code: ChangeRenamed, Name: "new1.txt", OldName: "old.txt", Similarity: 100, OldMode: ModePlain, NewMode: ModePlain, OldObjectID: foo1, NewObjectID: foo1},
+				{Code: ChangeCopied, Name: "new2.txt", OldName: "old.txt", Similarity: 100, OldMode: ModePlain, NewMode: ModePlain, OldObjectID: foo1, NewObjectID: foo1},
+			},
+		},
+		{
+			name: "CopyFromModified",
+			tree1: Tree{
+				{Name: "file.txt", Mode: ModePlain, ObjectID: long1},
+			},
+			tree2: Tree{
+				{Name: "copy.txt", Mode: ModePlain, ObjectID: long2},
+				{Name: "file.txt", Mode: ModePlain, ObjectID: foo1},
+			},
+			opts: &DiffTreesOptions{DetectCopies: true},
+			want: []*TreeChange{
+				{Code: ChangeCopied, Name: "copy.txt", OldName: "file.txt", Similarity: 81, OldMode: ModePlain, NewMode: ModePlain, OldObjectID: long1, NewObjectID: long2},
+				{Code: ChangeModified, Name: "file.txt", OldMode: ModePlain, NewMode: ModePlain, OldObjectID: long1, NewObjectID: foo1},
+			},
+		},
+		{
+			name: "CopyFromUnmodified",
+			tree1: Tree{
+				{Name: "dir", Mode: ModeDir, ObjectID: sub},
+			},
+			tree2: Tree{
+				{Name: "copy.txt", Mode: ModePlain, ObjectID: bar},
+				{Name: "dir", Mode: ModeDir, ObjectID: sub},
+			},
+			opts: &DiffTreesOptions{DetectCopies: true, FindCopiesHarder: true},
+			want: []*TreeChange{
+				{Code: ChangeCopied, Name: "copy.txt", OldName: "dir/b.txt", Similarity: 100, OldMode: ModePlain, NewMode: ModePlain, OldObjectID: bar, NewObjectID: bar},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := DiffTrees(objs, test.tree1, test.tree2, test.opts)
+			if err != nil {
+				t.Fatal("DiffTrees:", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("DiffTrees(...) (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"a\nb\nc\nd\n", "a\nb\nc\nd\n", 100},
+		{"a\nb\nc\nd\n", "a\nb\nc\nx\n", 75},
+		{"a\nb\nc\nd\n", "a\r\nb\r\nc\r\nd\r\n", 66},
+		{"a\nb\nc\nd\n", "w\nx\ny\nz\n", 0},
+		{strings.Repeat("x", 200), strings.Repeat("x", 200) + "y", 95},
+	}
+	for _, test := range tests {
+		got := similarity(countSpans([]byte(test.a)), countSpans([]byte(test.b)), 0)
+		if got != test.want {
+			t.Errorf("similarity(%q, %q) = %d; want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+// memObjects is an in-memory ObjectReader.
+type memObjects struct {
+	trees map[githash.ObjectID]Tree
+	blobs map[githash.ObjectID][]byte
+}
+
+func newMemObjects() *memObjects {
+	return &memObjects{
+		trees: make(map[githash.ObjectID]Tree),
+		blobs: make(map[githash.ObjectID][]byte),
+	}
+}
+
+func (m *memObjects) blob(content string) githash.ObjectID {
+	id, err := BlobSum(strings.NewReader(content), int64(len(content)))
+	if err != nil {
+		panic(err)
+	}
+	m.blobs[id] = []byte(content)
+	return id
+}
+
+func (m *memObjects) tree(entries ...*TreeEntry) githash.ObjectID {
+	tree := Tree(entries)
+	if err := tree.Sort(); err != nil {
+		panic(err)
+	}
+	id := tree.SHA1()
+	m.trees[id] = tree
+	return id
+}
+
+func (m *memObjects) ReadTree(id githash.ObjectID) (Tree, error) {
+	tree, ok := m.trees[id]
+	if !ok {
+		return nil, fmt.Errorf("tree %v not found", id)
+	}
+	return tree, nil
+}
+
+func (m *memObjects) ReadBlob(id githash.ObjectID) ([]byte, error) {
+	data, ok := m.blobs[id]
+	if !ok {
+		return nil, fmt.Errorf("blob %v not found", id)
+	}
+	return append([]byte(nil), data...), nil
+}
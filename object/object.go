@@ -23,7 +23,6 @@ package object
 
 import (
 	"bytes"
-	"crypto/sha1"
 	"fmt"
 	"io"
 	"strconv"
@@ -50,19 +49,25 @@ func (typ Type) IsValid() bool {
 // BlobSum computes the Git SHA-1 object ID of the blob with the given content.
 // This includes the Git object prefix as part of the hash input. It returns an
 // error if the blob does not match the provided size in bytes.
-func BlobSum(r io.Reader, size int64) (githash.SHA1, error) {
-	h := sha1.New()
+func BlobSum(r io.Reader, size int64) (githash.ObjectID, error) {
+	return BlobSumFormat(githash.SHA1Format, r, size)
+}
+
+// BlobSumFormat computes the Git object ID of the blob with the given content
+// using the given object format.
+// This includes the Git object prefix as part of the hash input. It returns an
+// error if the blob does not match the provided size in bytes.
+func BlobSumFormat(format githash.ObjectFormat, r io.Reader, size int64) (githash.ObjectID, error) {
+	h := format.New()
 	h.Write(AppendPrefix(nil, TypeBlob, size))
 	n, err := io.Copy(h, r)
 	if err != nil {
-		return githash.SHA1{}, fmt.Errorf("hash git blob: %w", err)
+		return githash.ObjectID{}, fmt.Errorf("hash git blob: %w", err)
 	}
 	if n != size {
-		return githash.SHA1{}, fmt.Errorf("hash git blob: wrong size %d (expected %d)", n, size)
+		return githash.ObjectID{}, fmt.Errorf("hash git blob: wrong size %d (expected %d)", n, size)
 	}
-	var sum githash.SHA1
-	h.Sum(sum[:0])
-	return sum, nil
+	return format.Sum(h), nil
 }
 
 // Prefix is a parsed Git object prefix like "blob 42\x00".
@@ -125,11 +130,3 @@ func AppendPrefix(dst []byte, typ Type, n int64) []byte {
 	dst = append(dst, 0)
 	return dst
 }
-
-func appendHex(dst, src []byte) []byte {
-	const digits = "0123456789abcdef"
-	for _, b := range src {
-		dst = append(dst, digits[b>>4], digits[b&0xf])
-	}
-	return dst
-}
@@ -32,7 +32,7 @@ var (
 func TestBlobSum(t *testing.T) {
 	tests := []struct {
 		data string
-		want githash.SHA1
+		want githash.ObjectID
 	}{
 		{"", hashLiteral("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")},
 		{"Hello, World!\n", hashLiteral("8ab686eafeb1f44702738c8b0f24f2567c36da6d")},
@@ -62,6 +62,25 @@ func TestBlobSum(t *testing.T) {
 	})
 }
 
+func TestBlobSumFormat(t *testing.T) {
+	tests := []struct {
+		format githash.ObjectFormat
+		data   string
+		want   githash.ObjectID
+	}{
+		{githash.SHA1Format, "Hello, World!\n", hashLiteral("8ab686eafeb1f44702738c8b0f24f2567c36da6d")},
+		{githash.SHA256Format, "", hashLiteral("473a0f4c3be8a93681a267e3b1e9a7dcda1185436fe141f7749120a303721813")},
+		{githash.SHA256Format, "Hello, World!\n", hashLiteral("dabc789f60c22621c92df8736ff8cb60e35185584772b93b9315a3e2aab55653")},
+	}
+	for _, test := range tests {
+		size := int64(len(test.data))
+		got, err := BlobSumFormat(test.format, strings.NewReader(test.data), size)
+		if got != test.want || err != nil {
+			t.Errorf("BlobSumFormat(%v, strings.NewReader(%q), %d) = %v, %v; want %v, <nil>", test.format, test.data, size, got, err, test.want)
+		}
+	}
+}
+
 func TestPrefixUnmarshalBinary(t *testing.T) {
 	tests := []struct {
 		data      string
@@ -112,8 +131,8 @@ func TestPrefixUnmarshalBinary(t *testing.T) {
 	}
 }
 
-func hashLiteral(s string) githash.SHA1 {
-	var h githash.SHA1
+func hashLiteral(s string) githash.ObjectID {
+	var h githash.ObjectID
 	if err := h.UnmarshalText([]byte(s)); err != nil {
 		panic(err)
 	}
@@ -18,7 +18,6 @@ package object
 
 import (
 	"bytes"
-	"crypto/sha1"
 	"fmt"
 	"io"
 	"strings"
@@ -40,7 +39,7 @@ signatures appended to the message: https://github.com/git/git/blob/21bf933928c0
 // in the Git documentation.
 type Tag struct {
 	// ObjectID is the hash of the object that the tag refers to.
-	ObjectID githash.SHA1
+	ObjectID githash.ObjectID
 	// ObjectType is the type of the object that the tag refers to.
 	ObjectType Type
 
@@ -55,6 +54,9 @@ type Tag struct {
 
 	// Message is the tag message.
 	Message string
+	// If Signature is not empty, then it is the ASCII-armored signature
+	// of the tag. Git stores the signature at the end of the message.
+	Signature []byte
 }
 
 // ParseTag deserializes a tag in the Git object format. It is the same as
@@ -80,7 +82,7 @@ func (t *Tag) UnmarshalBinary(data []byte) error {
 	}
 	*t = Tag{}
 	var err error
-	data, err = consumeHex(t.ObjectID[:], data)
+	t.ObjectID, data, err = consumeObjectID(data)
 	if err != nil {
 		return fmt.Errorf("parse git tag: object: %w", err)
 	}
@@ -124,10 +126,49 @@ func (t *Tag) UnmarshalBinary(data []byte) error {
 	if !ok {
 		return fmt.Errorf("parse git tag: message: expect blank line after header")
 	}
-	t.Message = string(data)
+	sigStart := findSignature(data)
+	t.Message = string(data[:sigStart])
+	if sigStart < len(data) {
+		t.Signature = append([]byte(nil), data[sigStart:]...)
+	}
 	return nil
 }
 
+// signaturePrefixes is the list of lines that start an ASCII-armored signature.
+var signaturePrefixes = []string{
+	"-----BEGIN PGP SIGNATURE-----",
+	"-----BEGIN PGP MESSAGE-----",
+	"-----BEGIN SSH SIGNATURE-----",
+	"-----BEGIN SIGNED MESSAGE-----",
+}
+
+// findSignature returns the offset of the last line in data that begins
+// a signature or len(data) if no such line exists.
+// See parse_signed_buffer in Git's gpg-interface.c.
+func findSignature(data []byte) int {
+	match := len(data)
+	for i := 0; i < len(data); {
+		if hasSignaturePrefix(data[i:]) {
+			match = i
+		}
+		eol := bytes.IndexByte(data[i:], '\n')
+		if eol == -1 {
+			break
+		}
+		i += eol + 1
+	}
+	return match
+}
+
+func hasSignaturePrefix(line []byte) bool {
+	for _, prefix := range signaturePrefixes {
+		if bytes.HasPrefix(line, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
 func consumeLine(src []byte) (_ string, tail []byte, _ error) {
 	eol := bytes.IndexByte(src, '\n')
 	if eol == -1 {
@@ -159,21 +200,42 @@ func (t *Tag) MarshalBinary() ([]byte, error) {
 	}
 	buf.WriteString("\n")
 	buf.WriteString(t.Message)
+	if len(t.Signature) > 0 {
+		if t.Message != "" && !strings.HasSuffix(t.Message, "\n") {
+			return nil, fmt.Errorf("marshal git tag: signed message does not end with a newline")
+		}
+		if !hasSignaturePrefix(t.Signature) {
+			return nil, fmt.Errorf("marshal git tag: signature is not ASCII-armored")
+		}
+		buf.Write(t.Signature)
+	}
 	return buf.Bytes(), nil
 }
 
+// SignedPayload returns the data covered by the tag's signature:
+// the serialized tag without its signature.
+func (t *Tag) SignedPayload() ([]byte, error) {
+	t2 := *t
+	t2.Signature = nil
+	return t2.MarshalBinary()
+}
+
 // SHA1 computes the SHA-1 hash of the tag object.
-func (t *Tag) SHA1() githash.SHA1 {
-	h := sha1.New()
+// It is the same as calling Sum with [githash.SHA1Format].
+func (t *Tag) SHA1() githash.ObjectID {
+	return t.Sum(githash.SHA1Format)
+}
+
+// Sum computes the hash of the tag object using the given object format.
+func (t *Tag) Sum(format githash.ObjectFormat) githash.ObjectID {
+	h := format.New()
 	s, err := t.MarshalText()
 	if err != nil {
 		panic(err)
 	}
 	h.Write(AppendPrefix(nil, TypeTag, int64(len(s))))
 	h.Write(s)
-	var arr githash.SHA1
-	h.Sum(arr[:0])
-	return arr
+	return format.Sum(h)
 }
 
 // Summary returns the first line of the message.
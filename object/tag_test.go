@@ -17,8 +17,8 @@
 package object
 
 import (
-	"bytes"
 	"encoding"
+	"strings"
 	"testing"
 	"time"
 
@@ -36,7 +36,7 @@ var (
 
 var gitTagTests = []struct {
 	name   string
-	id     githash.SHA1
+	id     githash.ObjectID
 	data   string
 	parsed *Tag
 }{
@@ -58,6 +58,36 @@ var gitTagTests = []struct {
 			Message:    "Release version 0.7.2\n",
 		},
 	},
+	{
+		name: "Signed",
+		id:   hashLiteral("a0df8b904a77e71c7acf5ebbdd057f3ebf8acdaf"),
+		data: "object 46f783e427e3290894ca55232de1b42b61cfe3b7\n" +
+			"type commit\n" +
+			"tag v2\n" +
+			"tagger Octocat <octocat@example.com> 1578581400 +0000\n" +
+			"\n" +
+			"Release v2\n" +
+			"-----BEGIN SSH SIGNATURE-----\n" +
+			"U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgYUjKeTCGoXV+XlOYk00uhtC497\n" +
+			"bmHKnzV7yJ2dUc65QAAAADZ2l0AAAAAAAAAAZzaGE1MTIAAABTAAAAC3NzaC1lZDI1NTE5\n" +
+			"AAAAQDpP6emugjNQONrPIPxIVUkY5/UYaG3NPRdSN74kkm+Wd5HBvi3kxl47cWOcbXTwcM\n" +
+			"m6PgVtAQPyKQxlmgjULw4=\n" +
+			"-----END SSH SIGNATURE-----\n",
+		parsed: &Tag{
+			ObjectID:   hashLiteral("46f783e427e3290894ca55232de1b42b61cfe3b7"),
+			ObjectType: TypeCommit,
+			Name:       "v2",
+			Tagger:     "Octocat <octocat@example.com>",
+			Time:       time.Unix(1578581400, 0).In(time.FixedZone("+0000", 0)),
+			Message:    "Release v2\n",
+			Signature: []byte("-----BEGIN SSH SIGNATURE-----\n" +
+				"U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgYUjKeTCGoXV+XlOYk00uhtC497\n" +
+				"bmHKnzV7yJ2dUc65QAAAADZ2l0AAAAAAAAAAZzaGE1MTIAAABTAAAAC3NzaC1lZDI1NTE5\n" +
+				"AAAAQDpP6emugjNQONrPIPxIVUkY5/UYaG3NPRdSN74kkm+Wd5HBvi3kxl47cWOcbXTwcM\n" +
+				"m6PgVtAQPyKQxlmgjULw4=\n" +
+				"-----END SSH SIGNATURE-----\n"),
+		},
+	},
 }
 
 func TestParseTag(t *testing.T) {
@@ -93,9 +123,24 @@ func TestTagSHA1(t *testing.T) {
 	for _, test := range gitTagTests {
 		t.Run(test.name, func(t *testing.T) {
 			got := test.parsed.SHA1()
-			if !bytes.Equal(got[:], test.id[:]) {
+			if got != test.id {
 				t.Errorf("sha1() = %x; want %x", got, test.id)
 			}
 		})
 	}
 }
+
+func TestTagSignedPayload(t *testing.T) {
+	for _, test := range gitTagTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.parsed.SignedPayload()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.TrimSuffix(test.data, string(test.parsed.Signature))
+			if diff := cmp.Diff(want, string(got)); diff != "" {
+				t.Errorf("payload (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -18,7 +18,6 @@ package object
 
 import (
 	"bytes"
-	"crypto/sha1"
 	"fmt"
 	"io"
 	"io/fs"
@@ -45,6 +44,16 @@ func ParseTree(src []byte) (Tree, error) {
 	return tree, err
 }
 
+// ParseTreeFormat deserializes a tree in the Git object format
+// whose entries use the given object format.
+// Unlike commits and tags, trees store object IDs in binary,
+// so the object format cannot be inferred from the tree data.
+func ParseTreeFormat(src []byte, format githash.ObjectFormat) (Tree, error) {
+	var tree Tree
+	err := tree.unmarshal(src, format)
+	return tree, err
+}
+
 // MarshalBinary serializes the tree into the Git tree object format. It returns
 // an error if the tree is not sorted or contains duplicates.
 func (tree Tree) MarshalBinary() ([]byte, error) {
@@ -62,14 +71,19 @@ func (tree Tree) MarshalBinary() ([]byte, error) {
 	return dst, nil
 }
 
-// UnmarshalBinary deserializes a tree from the Git object format.
+// UnmarshalBinary deserializes a tree with SHA-1 object IDs
+// from the Git object format.
 // If UnmarshalBinary does not return an error, the tree will always be sorted.
 func (tree *Tree) UnmarshalBinary(src []byte) error {
+	return tree.unmarshal(src, githash.SHA1Format)
+}
+
+func (tree *Tree) unmarshal(src []byte, format githash.ObjectFormat) error {
 	*tree = nil
 	for len(src) > 0 {
 		var ent *TreeEntry
 		var err error
-		ent, src, err = parseTreeEntry(src)
+		ent, src, err = parseTreeEntry(src, format)
 		if err != nil {
 			return fmt.Errorf("parse git tree: %w", err)
 		}
@@ -98,17 +112,22 @@ func (tree Tree) String() string {
 
 // SHA1 computes the SHA-1 hash of the tree object. It panics if the tree is
 // not sorted or contains duplicates.
-func (tree Tree) SHA1() githash.SHA1 {
+// It is the same as calling Sum with [githash.SHA1Format].
+func (tree Tree) SHA1() githash.ObjectID {
+	return tree.Sum(githash.SHA1Format)
+}
+
+// Sum computes the hash of the tree object using the given object format.
+// It panics if the tree is not sorted or contains duplicates.
+func (tree Tree) Sum(format githash.ObjectFormat) githash.ObjectID {
 	buf, err := tree.MarshalBinary()
 	if err != nil {
 		panic(err)
 	}
-	h := sha1.New()
+	h := format.New()
 	h.Write(AppendPrefix(nil, TypeTree, int64(len(buf))))
 	h.Write(buf)
-	var arr githash.SHA1
-	h.Sum(arr[:0])
-	return arr
+	return format.Sum(h)
 }
 
 // Search returns the entry with the given name in the tree or nil if not found.
@@ -195,10 +214,10 @@ func (tree Tree) isLastDuplicated() bool {
 type TreeEntry struct {
 	Name     string
 	Mode     Mode
-	ObjectID githash.SHA1
+	ObjectID githash.ObjectID
 }
 
-func parseTreeEntry(src []byte) (_ *TreeEntry, tail []byte, _ error) {
+func parseTreeEntry(src []byte, format githash.ObjectFormat) (_ *TreeEntry, tail []byte, _ error) {
 	modeEnd := bytes.IndexByte(src, ' ')
 	if modeEnd == -1 {
 		return nil, src, fmt.Errorf("entry: mode: %w", io.ErrUnexpectedEOF)
@@ -218,11 +237,13 @@ func parseTreeEntry(src []byte) (_ *TreeEntry, tail []byte, _ error) {
 	ent.Name = string(src[nameStart:nameEnd])
 
 	hashStart := nameEnd + 1
-	hashEnd := hashStart + len(ent.ObjectID)
+	hashEnd := hashStart + format.Size()
 	if hashEnd > len(src) {
 		return nil, src, fmt.Errorf("entry: object ID: %w", io.ErrUnexpectedEOF)
 	}
-	copy(ent.ObjectID[:], src[hashStart:hashEnd])
+	if err := ent.ObjectID.UnmarshalBinary(src[hashStart:hashEnd]); err != nil {
+		return nil, src, fmt.Errorf("entry: object ID: %w", err)
+	}
 	return ent, src[hashEnd:], nil
 }
 
@@ -235,7 +256,7 @@ func (ent *TreeEntry) appendTo(dst []byte) ([]byte, error) {
 	dst = append(dst, ' ')
 	dst = append(dst, ent.Name...)
 	dst = append(dst, 0)
-	dst = append(dst, ent.ObjectID[:]...)
+	dst = ent.ObjectID.AppendBinary(dst)
 	return dst, nil
 }
 
@@ -290,7 +311,7 @@ func (ent *TreeEntry) String() string {
 	sb.WriteByte(' ')
 	sb.WriteString(ent.Name)
 	sb.WriteByte(' ')
-	sb.Write(appendHex(nil, ent.ObjectID[:]))
+	sb.WriteString(ent.ObjectID.String())
 	return sb.String()
 }
 
@@ -36,7 +36,7 @@ var (
 
 var treeTests = []struct {
 	name   string
-	id     githash.SHA1
+	id     githash.ObjectID
 	parsed Tree
 }{
 	{
@@ -212,6 +212,34 @@ func TestTreeSHA1(t *testing.T) {
 	}
 }
 
+func TestTreeSHA256(t *testing.T) {
+	tree := Tree{
+		{
+			Name:     "foo.txt",
+			Mode:     ModePlain,
+			ObjectID: hashLiteral("dabc789f60c22621c92df8736ff8cb60e35185584772b93b9315a3e2aab55653"),
+		},
+	}
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseTreeFormat(data, githash.SHA256Format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(tree, got); diff != "" {
+		t.Errorf("ParseTreeFormat(tree.MarshalBinary()) (-want +got):\n%s", diff)
+	}
+	wantID := hashLiteral("11a3209a9d7c016713f68898d71d1360a5d580911ba0d46bb50cbafe4a2968f7")
+	if gotID := tree.Sum(githash.SHA256Format); gotID != wantID {
+		t.Errorf("Sum(githash.SHA256Format) = %v; want %v", gotID, wantID)
+	}
+	if gotID := (Tree{}).Sum(githash.SHA256Format); gotID != hashLiteral("6ef19b41225c5369f1c104d45d8d85efa9b057b53b14b4b9b939dd74decc5321") {
+		t.Errorf("empty tree Sum(githash.SHA256Format) = %v", gotID)
+	}
+}
+
 func TestTreeSearch(t *testing.T) {
 	for _, test := range treeTests {
 		t.Run(test.name, func(t *testing.T) {
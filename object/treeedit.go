@@ -0,0 +1,274 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package object
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gg-scm.io/pkg/git/githash"
+)
+
+// A TreeEdit is a single path-level change to a tree.
+type TreeEdit struct {
+	// Path is the slash-separated path of the entry to change,
+	// relative to the root of the tree.
+	// A trailing slash is permitted and ignored.
+	Path string
+
+	// If Delete is true, the entry at Path (and anything below it) is removed.
+	// Deleting a path that does not exist is not an error.
+	Delete bool
+
+	// Mode and ObjectID are the new entry for Path
+	// if Delete is false. Any intermediate directories are created as needed.
+	// An existing entry at Path is replaced,
+	// even if it is a directory and Mode is not ModeDir.
+	Mode     Mode
+	ObjectID githash.ObjectID
+}
+
+// String formats the edit in a form similar to `git update-index --index-info`.
+func (edit TreeEdit) String() string {
+	if edit.Delete {
+		return "delete " + edit.Path
+	}
+	return fmt.Sprintf("%v %v %s", edit.Mode, edit.ObjectID, edit.Path)
+}
+
+// TreeEditResult is the outcome of EditTree.
+type TreeEditResult struct {
+	// Root is the object ID of the new root tree.
+	Root githash.ObjectID
+	// Trees is the set of tree objects rewritten by the edits,
+	// keyed by object ID. It includes the root tree if it changed.
+	// Subtrees that were not affected by the edits are not included.
+	Trees map[githash.ObjectID]Tree
+}
+
+// EditTree applies a batch of edits to the tree identified by base,
+// like `git update-index` followed by `git write-tree`.
+// Edits are applied in order, so a later edit may override an earlier one.
+// base may be the zero ID to start from an empty tree.
+// Only the subtrees along the edited paths are loaded from r and rewritten.
+// Directories that become empty are removed, as in Git.
+//
+// The object format is the format of base or, if base is zero,
+// the format of the first edit's object ID.
+// All object IDs in the edits must use the same format.
+func EditTree(r TreeReader, base githash.ObjectID, edits []TreeEdit) (*TreeEditResult, error) {
+	format, err := editFormat(base, edits)
+	if err != nil {
+		return nil, fmt.Errorf("edit tree: %w", err)
+	}
+	root := &editDir{entries: make(map[string]*TreeEntry)}
+	if !base.IsZero() {
+		root, err = loadEditDir(r, base)
+		if err != nil {
+			return nil, fmt.Errorf("edit tree: %w", err)
+		}
+	}
+	for _, edit := range edits {
+		if err := root.apply(r, edit); err != nil {
+			return nil, fmt.Errorf("edit tree: %s: %w", edit.Path, err)
+		}
+	}
+
+	result := &TreeEditResult{
+		Root:  base,
+		Trees: make(map[githash.ObjectID]Tree),
+	}
+	if root.changed || base.IsZero() {
+		result.Root = root.write(format, result.Trees, true)
+	}
+	return result, nil
+}
+
+func editFormat(base githash.ObjectID, edits []TreeEdit) (githash.ObjectFormat, error) {
+	format := githash.SHA1Format
+	known := false
+	if !base.IsZero() {
+		format = base.ObjectFormat()
+		known = true
+	}
+	for _, edit := range edits {
+		if edit.Delete {
+			continue
+		}
+		if edit.ObjectID.IsZero() {
+			return 0, fmt.Errorf("%s: missing object ID", edit.Path)
+		}
+		if !known {
+			format = edit.ObjectID.ObjectFormat()
+			known = true
+		} else if edit.ObjectID.ObjectFormat() != format {
+			return 0, fmt.Errorf("%s: object ID %v is not in %v format", edit.Path, edit.ObjectID, format)
+		}
+	}
+	return format, nil
+}
+
+// editDir is a directory in the tree being edited.
+type editDir struct {
+	entries map[string]*TreeEntry
+	// subdirs holds the directories that have been loaded for editing.
+	// Their entries in the entries map are stale until the editDir is written.
+	subdirs map[string]*editDir
+	changed bool
+}
+
+func loadEditDir(r TreeReader, id githash.ObjectID) (*editDir, error) {
+	tree, err := r.ReadTree(id)
+	if err != nil {
+		return nil, err
+	}
+	dir := &editDir{entries: make(map[string]*TreeEntry, len(tree))}
+	for _, ent := range tree {
+		dir.entries[ent.Name] = ent
+	}
+	return dir, nil
+}
+
+func (dir *editDir) apply(r TreeReader, edit TreeEdit) error {
+	path, err := splitEditPath(edit.Path)
+	if err != nil {
+		return err
+	}
+	if !edit.Delete && !isValidEditMode(edit.Mode) {
+		return fmt.Errorf("invalid mode %v", edit.Mode)
+	}
+	_, err = dir.edit(r, path, 0, edit)
+	return err
+}
+
+// edit applies edit to the entry at path[depth:] relative to dir.
+// It reports whether dir changed.
+func (dir *editDir) edit(r TreeReader, path []string, depth int, edit TreeEdit) (changed bool, err error) {
+	name := path[depth]
+	if depth == len(path)-1 {
+		if edit.Delete {
+			if dir.entries[name] == nil {
+				return false, nil
+			}
+			delete(dir.entries, name)
+		} else {
+			dir.entries[name] = &TreeEntry{
+				Name:     name,
+				Mode:     edit.Mode,
+				ObjectID: edit.ObjectID,
+			}
+		}
+		delete(dir.subdirs, name)
+		dir.changed = true
+		return true, nil
+	}
+
+	sub := dir.subdirs[name]
+	if sub == nil {
+		switch ent := dir.entries[name]; {
+		case ent == nil:
+			if edit.Delete {
+				return false, nil
+			}
+			sub = &editDir{entries: make(map[string]*TreeEntry)}
+		case ent.Mode.IsDir():
+			sub, err = loadEditDir(r, ent.ObjectID)
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", strings.Join(path[:depth+1], "/"), err)
+			}
+		case edit.Delete:
+			return false, nil
+		default:
+			return false, fmt.Errorf("%s is not a directory", strings.Join(path[:depth+1], "/"))
+		}
+		if dir.subdirs == nil {
+			dir.subdirs = make(map[string]*editDir)
+		}
+		dir.subdirs[name] = sub
+	}
+	changed, err = sub.edit(r, path, depth+1, edit)
+	if changed {
+		dir.changed = true
+	}
+	return changed, err
+}
+
+// write serializes dir and the changed directories under it,
+// adds them to trees, and returns dir's object ID.
+// If dir is empty and isRoot is false, then write returns the zero ID
+// and does not add dir to trees.
+func (dir *editDir) write(format githash.ObjectFormat, trees map[githash.ObjectID]Tree, isRoot bool) githash.ObjectID {
+	for name, sub := range dir.subdirs {
+		if !sub.changed {
+			continue
+		}
+		id := sub.write(format, trees, false)
+		if id.IsZero() {
+			delete(dir.entries, name)
+			continue
+		}
+		dir.entries[name] = &TreeEntry{
+			Name:     name,
+			Mode:     ModeDir,
+			ObjectID: id,
+		}
+	}
+	if len(dir.entries) == 0 && !isRoot {
+		return githash.ObjectID{}
+	}
+	tree := make(Tree, 0, len(dir.entries))
+	for _, ent := range dir.entries {
+		tree = append(tree, ent)
+	}
+	if err := tree.Sort(); err != nil {
+		// Entries are keyed by name, so there can't be duplicates.
+		panic(err)
+	}
+	id := tree.Sum(format)
+	trees[id] = tree
+	return id
+}
+
+// splitEditPath splits a slash-separated path into its components.
+func splitEditPath(path string) ([]string, error) {
+	path = strings.TrimSuffix(path, "/")
+	if path == "" {
+		return nil, errors.New("empty path")
+	}
+	parts := strings.Split(path, "/")
+	for _, part := range parts {
+		switch {
+		case part == "":
+			return nil, errors.New("empty path component")
+		case part == "." || part == "..":
+			return nil, fmt.Errorf("invalid path component %q", part)
+		case strings.Contains(part, "\x00"):
+			return nil, errors.New("path contains NUL")
+		}
+	}
+	return parts, nil
+}
+
+func isValidEditMode(m Mode) bool {
+	switch m {
+	case ModePlain, ModeExecutable, ModeDir, ModeSymlink, ModeGitlink:
+		return true
+	default:
+		return false
+	}
+}
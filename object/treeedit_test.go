@@ -0,0 +1,340 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package object
+
+import (
+	"testing"
+
+	"gg-scm.io/pkg/git/githash"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestEditTree(t *testing.T) {
+	objs := newMemObjects()
+	foo := objs.blob("foo\n")
+	bar := objs.blob("bar\n")
+	baz := objs.blob("baz\n")
+	libTree := objs.tree(
+		&TreeEntry{Name: "bar.txt", Mode: ModePlain, ObjectID: bar},
+	)
+	docsTree := objs.tree(
+		&TreeEntry{Name: "README", Mode: ModePlain, ObjectID: foo},
+	)
+	srcTree := objs.tree(
+		&TreeEntry{Name: "lib", Mode: ModeDir, ObjectID: libTree},
+		&TreeEntry{Name: "main.txt", Mode: ModePlain, ObjectID: foo},
+	)
+	base := objs.tree(
+		&TreeEntry{Name: "docs", Mode: ModeDir, ObjectID: docsTree},
+		&TreeEntry{Name: "foo.txt", Mode: ModePlain, ObjectID: foo},
+		&TreeEntry{Name: "src", Mode: ModeDir, ObjectID: srcTree},
+	)
+
+	tests := []struct {
+		name  string
+		base  githash.ObjectID
+		edits []TreeEdit
+		// want builds the expected root tree in a separate set of objects.
+		want func(m *memObjects) githash.ObjectID
+		// wantTrees is the number of trees that should be rewritten.
+		wantTrees int
+		wantErr   bool
+	}{
+		{
+			name: "NewNestedFile",
+			edits: []TreeEdit{
+				{Path: "a/b/c.txt", Mode: ModeExecutable, ObjectID: foo},
+			},
+			want: func(m *memObjects) githash.ObjectID {
+				return m.tree(&TreeEntry{Name: "a", Mode: ModeDir, ObjectID: m.tree(
+					&TreeEntry{Name: "b", Mode: ModeDir, ObjectID: m.tree(
+						&TreeEntry{Name: "c.txt", Mode: ModeExecutable, ObjectID: foo},
+					)},
+				)})
+			},
+			wantTrees: 3,
+		},
+		{
+			name: "NoEditsOnEmpty",
+			want: func(m *memObjects) githash.ObjectID {
+				return m.tree()
+			},
+			wantTrees: 1,
+		},
+		{
+			name: "ModifyNested",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "src/lib/bar.txt", Mode: ModePlain, ObjectID: baz},
+			},
+			want: func(m *memObjects) githash.ObjectID {
+				return m.tree(
+					&TreeEntry{Name: "docs", Mode: ModeDir, ObjectID: docsTree},
+					&TreeEntry{Name: "foo.txt", Mode: ModePlain, ObjectID: foo},
+					&TreeEntry{Name: "src", Mode: ModeDir, ObjectID: m.tree(
+						&TreeEntry{Name: "lib", Mode: ModeDir, ObjectID: m.tree(
+							&TreeEntry{Name: "bar.txt", Mode: ModePlain, ObjectID: baz},
+						)},
+						&TreeEntry{Name: "main.txt", Mode: ModePlain, ObjectID: foo},
+					)},
+				)
+			},
+			wantTrees: 3,
+		},
+		{
+			name: "Batch",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "foo.txt", Mode: ModeExecutable, ObjectID: foo},
+				{Path: "src/main.txt", Delete: true},
+				{Path: "src/new.txt", Mode: ModePlain, ObjectID: bar},
+				{Path: "src/new.txt", Mode: ModePlain, ObjectID: baz},
+				{Path: "link", Mode: ModeSymlink, ObjectID: bar},
+			},
+			want: func(m *memObjects) githash.ObjectID {
+				return m.tree(
+					&TreeEntry{Name: "docs", Mode: ModeDir, ObjectID: docsTree},
+					&TreeEntry{Name: "foo.txt", Mode: ModeExecutable, ObjectID: foo},
+					&TreeEntry{Name: "link", Mode: ModeSymlink, ObjectID: bar},
+					&TreeEntry{Name: "src", Mode: ModeDir, ObjectID: m.tree(
+						&TreeEntry{Name: "lib", Mode: ModeDir, ObjectID: libTree},
+						&TreeEntry{Name: "new.txt", Mode: ModePlain, ObjectID: baz},
+					)},
+				)
+			},
+			wantTrees: 2,
+		},
+		{
+			name: "DeleteDir",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "src/", Delete: true},
+			},
+			want: func(m *memObjects) githash.ObjectID {
+				return m.tree(
+					&TreeEntry{Name: "docs", Mode: ModeDir, ObjectID: docsTree},
+					&TreeEntry{Name: "foo.txt", Mode: ModePlain, ObjectID: foo},
+				)
+			},
+			wantTrees: 1,
+		},
+		{
+			name: "DeleteLastFileRemovesParents",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "src/lib/bar.txt", Delete: true},
+				{Path: "docs/README", Delete: true},
+			},
+			want: func(m *memObjects) githash.ObjectID {
+				return m.tree(
+					&TreeEntry{Name: "foo.txt", Mode: ModePlain, ObjectID: foo},
+					&TreeEntry{Name: "src", Mode: ModeDir, ObjectID: m.tree(
+						&TreeEntry{Name: "main.txt", Mode: ModePlain, ObjectID: foo},
+					)},
+				)
+			},
+			wantTrees: 2,
+		},
+		{
+			name: "DeleteEverything",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "docs", Delete: true},
+				{Path: "foo.txt", Delete: true},
+				{Path: "src", Delete: true},
+			},
+			want: func(m *memObjects) githash.ObjectID {
+				return m.tree()
+			},
+			wantTrees: 1,
+		},
+		{
+			name: "DeleteMissing",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "nope.txt", Delete: true},
+				{Path: "nope/file.txt", Delete: true},
+				{Path: "foo.txt/file.txt", Delete: true},
+			},
+			want: func(m *memObjects) githash.ObjectID {
+				return base
+			},
+			wantTrees: 0,
+		},
+		{
+			name: "ReplaceDirWithFile",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "src/lib/bar.txt", Mode: ModePlain, ObjectID: baz},
+				{Path: "src", Mode: ModePlain, ObjectID: foo},
+			},
+			want: func(m *memObjects) githash.ObjectID {
+				return m.tree(
+					&TreeEntry{Name: "docs", Mode: ModeDir, ObjectID: docsTree},
+					&TreeEntry{Name: "foo.txt", Mode: ModePlain, ObjectID: foo},
+					&TreeEntry{Name: "src", Mode: ModePlain, ObjectID: foo},
+				)
+			},
+			wantTrees: 1,
+		},
+		{
+			name: "ReplaceFileAfterDelete",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "foo.txt", Delete: true},
+				{Path: "foo.txt/a.txt", Mode: ModePlain, ObjectID: bar},
+			},
+			want: func(m *memObjects) githash.ObjectID {
+				return m.tree(
+					&TreeEntry{Name: "docs", Mode: ModeDir, ObjectID: docsTree},
+					&TreeEntry{Name: "foo.txt", Mode: ModeDir, ObjectID: m.tree(
+						&TreeEntry{Name: "a.txt", Mode: ModePlain, ObjectID: bar},
+					)},
+					&TreeEntry{Name: "src", Mode: ModeDir, ObjectID: srcTree},
+				)
+			},
+			wantTrees: 2,
+		},
+		{
+			name: "SetSubtree",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "vendor/lib", Mode: ModeDir, ObjectID: libTree},
+				{Path: "vendor/lib/extra.txt", Mode: ModePlain, ObjectID: foo},
+			},
+			want: func(m *memObjects) githash.ObjectID {
+				return m.tree(
+					&TreeEntry{Name: "docs", Mode: ModeDir, ObjectID: docsTree},
+					&TreeEntry{Name: "foo.txt", Mode: ModePlain, ObjectID: foo},
+					&TreeEntry{Name: "src", Mode: ModeDir, ObjectID: srcTree},
+					&TreeEntry{Name: "vendor", Mode: ModeDir, ObjectID: m.tree(
+						&TreeEntry{Name: "lib", Mode: ModeDir, ObjectID: m.tree(
+							&TreeEntry{Name: "bar.txt", Mode: ModePlain, ObjectID: bar},
+							&TreeEntry{Name: "extra.txt", Mode: ModePlain, ObjectID: foo},
+						)},
+					)},
+				)
+			},
+			wantTrees: 3,
+		},
+		{
+			name: "FileIsNotDirectory",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "foo.txt/a.txt", Mode: ModePlain, ObjectID: bar},
+			},
+			wantErr: true,
+		},
+		{
+			name: "EmptyPath",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "", Mode: ModePlain, ObjectID: bar},
+			},
+			wantErr: true,
+		},
+		{
+			name: "DotDot",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "src/../foo.txt", Delete: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "DoubleSlash",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "src//foo.txt", Mode: ModePlain, ObjectID: bar},
+			},
+			wantErr: true,
+		},
+		{
+			name: "MissingObjectID",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "new.txt", Mode: ModePlain},
+			},
+			wantErr: true,
+		},
+		{
+			name: "InvalidMode",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "new.txt", Mode: 0o100600, ObjectID: bar},
+			},
+			wantErr: true,
+		},
+		{
+			name: "MixedFormats",
+			base: base,
+			edits: []TreeEdit{
+				{Path: "new.txt", Mode: ModePlain, ObjectID: githash.SHA256{1}.ObjectID()},
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := EditTree(objs, test.base, test.edits)
+			if err != nil {
+				if !test.wantErr {
+					t.Fatal("EditTree:", err)
+				}
+				t.Log("EditTree:", err)
+				return
+			}
+			if test.wantErr {
+				t.Fatal("EditTree did not return an error")
+			}
+			want := newMemObjects()
+			wantRoot := test.want(want)
+			if got.Root != wantRoot {
+				t.Errorf("Root = %v; want %v", got.Root, wantRoot)
+			}
+			if len(got.Trees) != test.wantTrees {
+				t.Errorf("len(Trees) = %d; want %d", len(got.Trees), test.wantTrees)
+			}
+			for id, tree := range got.Trees {
+				if sum := tree.SHA1(); sum != id {
+					t.Errorf("Trees[%v].SHA1() = %v", id, sum)
+				}
+				if wantTree, ok := want.trees[id]; !ok {
+					t.Errorf("Trees contains unexpected tree %v:\n%v", id, tree)
+				} else if diff := cmp.Diff(wantTree, tree, cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("Trees[%v] (-want +got):\n%s", id, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestEditTreeSHA256(t *testing.T) {
+	blob := githash.SHA256{1, 2, 3}.ObjectID()
+	got, err := EditTree(nil, githash.ObjectID{}, []TreeEdit{
+		{Path: "dir/file.txt", Mode: ModePlain, ObjectID: blob},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := Tree{{Name: "file.txt", Mode: ModePlain, ObjectID: blob}}
+	root := Tree{{Name: "dir", Mode: ModeDir, ObjectID: dir.Sum(githash.SHA256Format)}}
+	if want := root.Sum(githash.SHA256Format); got.Root != want {
+		t.Errorf("Root = %v; want %v", got.Root, want)
+	}
+}
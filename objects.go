@@ -0,0 +1,155 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gg-scm.io/pkg/git/object"
+)
+
+// HashObject writes an object with the given type and content
+// to the repository's object database and returns its hash.
+// Git checks that tree, commit, and tag objects are well-formed.
+func (g *Git) HashObject(ctx context.Context, tp object.Type, content io.Reader) (Hash, error) {
+	errPrefix := fmt.Sprintf("git hash-object -t %s", tp)
+	if !tp.IsValid() {
+		return Hash{}, fmt.Errorf("%s: invalid object type", errPrefix)
+	}
+	return g.writeObject(ctx, errPrefix, &Invocation{
+		Args:  []string{"hash-object", "-t", string(tp), "-w", "--stdin"},
+		Stdin: content,
+	})
+}
+
+// MakeTree writes a tree object to the repository's object database
+// and returns its hash. The tree does not need to be sorted,
+// but every object it references must exist in the repository,
+// except for submodule commits.
+func (g *Git) MakeTree(ctx context.Context, tree object.Tree) (Hash, error) {
+	const errPrefix = "git mktree"
+	input := new(bytes.Buffer)
+	for _, ent := range tree {
+		if strings.ContainsAny(ent.Name, "/\x00") {
+			return Hash{}, fmt.Errorf("%s: invalid name %q", errPrefix, ent.Name)
+		}
+		var tp object.Type
+		switch {
+		case ent.Mode.IsDir():
+			tp = object.TypeTree
+		case ent.Mode == object.ModeGitlink:
+			tp = object.TypeCommit
+		default:
+			tp = object.TypeBlob
+		}
+		fmt.Fprintf(input, "%v %s %v\t%s\x00", ent.Mode, tp, ent.ObjectID, ent.Name)
+	}
+	return g.writeObject(ctx, errPrefix, &Invocation{
+		Args:  []string{"mktree", "-z"},
+		Stdin: input,
+	})
+}
+
+// CommitTree creates a commit object from c with `git commit-tree`
+// and returns its hash. If c.Author or c.Committer is empty
+// or c.AuthorTime or c.CommitTime is zero,
+// then Git fills in the field like it does for `git commit`.
+// The message is used verbatim.
+//
+// `git commit-tree` cannot write extra headers or signatures,
+// so CommitTree returns an error if c.Extra or c.GPGSignature is not empty.
+// To store such a commit exactly as given,
+// pass its serialized form to [*Git.HashObject] instead.
+func (g *Git) CommitTree(ctx context.Context, c *object.Commit) (Hash, error) {
+	const errPrefix = "git commit-tree"
+	if c.Extra != "" || len(c.GPGSignature) > 0 {
+		return Hash{}, fmt.Errorf("%s: extra headers and signatures not supported", errPrefix)
+	}
+	args := []string{"commit-tree"}
+	for _, par := range c.Parents {
+		args = append(args, "-p", par.String())
+	}
+	args = append(args, c.Tree.String())
+	var env []string
+	if c.Author != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+c.Author.Name())
+		env = append(env, "GIT_AUTHOR_EMAIL="+c.Author.Email())
+	}
+	if !c.AuthorTime.IsZero() {
+		env = append(env, "GIT_AUTHOR_DATE="+rawGitTime(c.AuthorTime))
+	}
+	if c.Committer != "" {
+		env = append(env, "GIT_COMMITTER_NAME="+c.Committer.Name())
+		env = append(env, "GIT_COMMITTER_EMAIL="+c.Committer.Email())
+	}
+	if !c.CommitTime.IsZero() {
+		env = append(env, "GIT_COMMITTER_DATE="+rawGitTime(c.CommitTime))
+	}
+	return g.writeObject(ctx, errPrefix, &Invocation{
+		Args:  args,
+		Env:   env,
+		Stdin: strings.NewReader(c.Message),
+	})
+}
+
+// rawGitTime formats t in Git's internal date format,
+// which preserves the time zone offset.
+func rawGitTime(t time.Time) string {
+	return fmt.Sprintf("@%d %s", t.Unix(), t.Format("-0700"))
+}
+
+// MakeTag writes an annotated tag object to the repository's object database
+// with `git mktag` and returns its hash.
+// Git checks that the tag is well-formed and that its target object exists.
+func (g *Git) MakeTag(ctx context.Context, tag *object.Tag) (Hash, error) {
+	const errPrefix = "git mktag"
+	data, err := tag.MarshalBinary()
+	if err != nil {
+		return Hash{}, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	return g.writeObject(ctx, errPrefix, &Invocation{
+		Args:  []string{"mktag"},
+		Stdin: bytes.NewReader(data),
+	})
+}
+
+// writeObject runs a Git subcommand that prints the hash of a new object.
+// The Dir, Stdout, and Stderr fields of invoke are filled in by writeObject.
+func (g *Git) writeObject(ctx context.Context, errPrefix string, invoke *Invocation) (Hash, error) {
+	stdout := new(strings.Builder)
+	stderr := new(bytes.Buffer)
+	invoke.Dir = g.dir
+	invoke.Stdout = &limitWriter{w: stdout, n: dataOutputLimit}
+	invoke.Stderr = &limitWriter{w: stderr, n: errorOutputLimit}
+	if err := g.runner.RunGit(ctx, invoke); err != nil {
+		return Hash{}, commandError(errPrefix, err, stderr.Bytes())
+	}
+	line, err := oneLine(stdout.String())
+	if err != nil {
+		return Hash{}, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	h, err := ParseHash(line)
+	if err != nil {
+		return Hash{}, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	return h, nil
+}
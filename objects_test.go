@@ -0,0 +1,136 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git/object"
+)
+
+func TestWriteObjects(t *testing.T) {
+	gitPath, err := findGit()
+	if err != nil {
+		t.Skip("git not found:", err)
+	}
+	ctx := context.Background()
+	env, err := newTestEnv(ctx, gitPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.cleanup()
+	if err := env.g.Init(ctx, "."); err != nil {
+		t.Fatal(err)
+	}
+
+	blobID, err := env.g.HashObject(ctx, object.TypeBlob, strings.NewReader(dummyContent))
+	if err != nil {
+		t.Fatal("HashObject:", err)
+	}
+	if want := blobSum(dummyContent); blobID != want {
+		t.Errorf("HashObject(...) = %v; want %v", blobID, want)
+	}
+	if _, err := env.g.HashObject(ctx, object.TypeTree, strings.NewReader("garbage")); err == nil {
+		t.Error("HashObject(malformed tree) did not return an error")
+	}
+
+	subtree := object.Tree{
+		{Name: "foo.txt", Mode: object.ModePlain, ObjectID: blobID},
+	}
+	subtreeID, err := env.g.MakeTree(ctx, subtree)
+	if err != nil {
+		t.Fatal("MakeTree:", err)
+	}
+	// Intentionally unsorted: "dir" sorts after "dir.txt".
+	tree := object.Tree{
+		{Name: "dir", Mode: object.ModeDir, ObjectID: subtreeID},
+		{Name: "dir.txt", Mode: object.ModeExecutable, ObjectID: blobID},
+	}
+	treeID, err := env.g.MakeTree(ctx, tree)
+	if err != nil {
+		t.Fatal("MakeTree:", err)
+	}
+	if err := tree.Sort(); err != nil {
+		t.Fatal(err)
+	}
+	if want := tree.SHA1(); treeID != want {
+		t.Errorf("MakeTree(...) = %v; want %v", treeID, want)
+	}
+
+	commitTime := time.Date(2018, time.February, 20, 15, 47, 42, 0, time.FixedZone("-0800", -8*60*60))
+	c := &object.Commit{
+		Tree:       treeID,
+		Author:     "Lisbeth Salander <lisbeth@example.com>",
+		AuthorTime: commitTime,
+		Committer:  "Octo Cat <noreply@github.com>",
+		CommitTime: commitTime.Add(time.Hour),
+		Message:    "initial import\n\n  verbatim  \n",
+	}
+	commitID, err := env.g.CommitTree(ctx, c)
+	if err != nil {
+		t.Fatal("CommitTree:", err)
+	}
+	if want := c.SHA1(); commitID != want {
+		t.Errorf("CommitTree(...) = %v; want %v", commitID, want)
+	}
+	c2 := &object.Commit{
+		Tree:    subtreeID,
+		Parents: []Hash{commitID},
+		Message: "second\n",
+	}
+	commit2ID, err := env.g.CommitTree(ctx, c2)
+	if err != nil {
+		t.Fatal("CommitTree:", err)
+	}
+	info, err := env.g.CommitInfo(ctx, commit2ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Author != "User <foo@example.com>" || info.Committer != "User <foo@example.com>" {
+		t.Errorf("second commit author, committer = %q, %q; want defaults from config", info.Author, info.Committer)
+	}
+	if len(info.Parents) != 1 || info.Parents[0] != commitID {
+		t.Errorf("second commit parents = %v; want [%v]", info.Parents, commitID)
+	}
+	if _, err := env.g.CommitTree(ctx, &object.Commit{Tree: treeID, Extra: "foo bar", Message: "x\n"}); err == nil {
+		t.Error("CommitTree with Extra did not return an error")
+	}
+
+	tag := &object.Tag{
+		ObjectID:   commitID,
+		ObjectType: object.TypeCommit,
+		Name:       "v1.0.0",
+		Tagger:     "Octo Cat <noreply@github.com>",
+		Time:       commitTime,
+		Message:    "Release 1.0\n",
+	}
+	tagID, err := env.g.MakeTag(ctx, tag)
+	if err != nil {
+		t.Fatal("MakeTag:", err)
+	}
+	if want := tag.SHA1(); tagID != want {
+		t.Errorf("MakeTag(...) = %v; want %v", tagID, want)
+	}
+	badTag := *tag
+	badTag.ObjectType = object.TypeTree
+	if _, err := env.g.MakeTag(ctx, &badTag); err == nil {
+		t.Error("MakeTag with wrong object type did not return an error")
+	}
+}
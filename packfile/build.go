@@ -0,0 +1,323 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package packfile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"unicode"
+
+	"gg-scm.io/pkg/git/githash"
+)
+
+// Default parameters for Build.
+// These are the same defaults that git-pack-objects(1) uses.
+const (
+	DefaultDeltaWindow = 10
+	DefaultDeltaDepth  = 50
+)
+
+// minDeltaTargetSize is the size of the smallest object that Build will
+// attempt to deltify. Deltas for smaller objects are unlikely to be
+// smaller than the objects themselves.
+const minDeltaTargetSize = 50
+
+// A BuildObject is an object to be written to a packfile by Build.
+type BuildObject struct {
+	// Type is the type of the object.
+	// It must be one of Commit, Tree, Blob, or Tag.
+	Type ObjectType
+	// Data is the object's uncompressed content.
+	// If Data is nil and BuildOptions.Load is set,
+	// then the content is read with Load when it is needed.
+	Data []byte
+	// Size is the length of the object's uncompressed content.
+	// It is only used if the content is read with BuildOptions.Load.
+	Size int64
+	// Name is an optional hint of the path at which the object was found
+	// (e.g. "cmd/foo/main.go" for a blob). Objects with similar names are
+	// grouped together when searching for delta bases.
+	Name string
+}
+
+// BuildOptions contains optional parameters for Build.
+type BuildOptions struct {
+	// ObjectFormat is the object format of the packfile.
+	// The zero value is SHA-1.
+	ObjectFormat githash.ObjectFormat
+
+	// Window is the number of objects considered as delta bases for each object.
+	// If Window is zero, then DefaultDeltaWindow is used.
+	// If Window is negative, then no objects are deltified.
+	Window int
+
+	// Depth is the maximum length of a delta chain.
+	// If Depth is zero, then DefaultDeltaDepth is used.
+	Depth int
+
+	// Load returns the uncompressed content of the object at the given index
+	// in the list passed to Build. If Load is not nil, then Build calls it
+	// for each object whose Data field is nil instead of holding the content
+	// of every object in memory: only the objects in the delta window
+	// are retained. The delta chosen for each deltified object is still kept
+	// in memory until the packfile is written, so memory use grows with
+	// the total size of the deltas. Load may be called more than once
+	// for the same object.
+	Load func(i int) ([]byte, error)
+}
+
+// Build writes a packfile containing the given objects to w.
+// Objects that can be stored more compactly as a delta of another object
+// are written as OffsetDelta entries. Delta bases are chosen with the same
+// sliding window heuristic that git-pack-objects(1) uses: objects are sorted
+// by type, name, and size, then each object is compared against the objects
+// preceding it in the window. Deltified objects are written after their bases,
+// but otherwise objects are written in the order given.
+//
+// See https://git-scm.com/docs/pack-heuristics for background.
+func Build(w io.Writer, objects []BuildObject, opts *BuildOptions) error {
+	if opts == nil {
+		opts = new(BuildOptions)
+	}
+	if !opts.ObjectFormat.IsValid() {
+		return fmt.Errorf("packfile: build: invalid object format %v", opts.ObjectFormat)
+	}
+	if len(objects) > 1<<32-1 {
+		return fmt.Errorf("packfile: build: too many objects")
+	}
+	for i := range objects {
+		if objects[i].Type.NonDelta() == "" {
+			return fmt.Errorf("packfile: build: object %d: invalid type %v", i, objects[i].Type)
+		}
+	}
+	window := opts.Window
+	if window == 0 {
+		window = DefaultDeltaWindow
+	}
+	depth := opts.Depth
+	if depth <= 0 {
+		depth = DefaultDeltaDepth
+	}
+
+	src := &buildSource{objects: objects, load: opts.Load}
+	entries := make([]buildEntry, len(objects))
+	for i := range entries {
+		entries[i].base = -1
+		entries[i].offset = -1
+	}
+	if window > 0 {
+		if err := findDeltas(src, entries, window, depth, opts.ObjectFormat.Size()); err != nil {
+			return fmt.Errorf("packfile: build: %w", err)
+		}
+	}
+
+	pw := NewWriterFormat(w, uint32(len(objects)), opts.ObjectFormat)
+	var chain []int
+	for i := range objects {
+		// Write any bases that haven't been written yet, starting from the root.
+		chain = chain[:0]
+		for j := i; j != -1 && entries[j].offset == -1; j = entries[j].base {
+			chain = append(chain, j)
+		}
+		for k := len(chain) - 1; k >= 0; k-- {
+			j := chain[k]
+			if err := writeBuildEntry(pw, src, entries, j); err != nil {
+				return fmt.Errorf("packfile: build: %w", err)
+			}
+		}
+	}
+	if err := pw.Close(); err != nil {
+		return fmt.Errorf("packfile: build: %w", err)
+	}
+	return nil
+}
+
+// buildEntry is the bookkeeping information for a BuildObject.
+type buildEntry struct {
+	base   int    // index of delta base object or -1
+	delta  []byte // delta instructions if base != -1
+	depth  int    // length of the delta chain ending at this object
+	offset int64  // offset in the packfile or -1 if not written yet
+}
+
+// buildSource provides the content of the objects passed to Build.
+type buildSource struct {
+	objects []BuildObject
+	load    func(i int) ([]byte, error)
+}
+
+// size returns the size of the object at index i without loading it.
+func (src *buildSource) size(i int) int64 {
+	obj := &src.objects[i]
+	if obj.Data == nil && src.load != nil {
+		return obj.Size
+	}
+	return int64(len(obj.Data))
+}
+
+// data returns the content of the object at index i.
+func (src *buildSource) data(i int) ([]byte, error) {
+	obj := &src.objects[i]
+	if obj.Data != nil || src.load == nil {
+		return obj.Data, nil
+	}
+	data, err := src.load(i)
+	if err != nil {
+		return nil, fmt.Errorf("object %d: %w", i, err)
+	}
+	return data, nil
+}
+
+func writeBuildEntry(pw *Writer, src *buildSource, entries []buildEntry, i int) error {
+	ent := &entries[i]
+	var err error
+	if ent.base == -1 {
+		data, err := src.data(i)
+		if err != nil {
+			return err
+		}
+		ent.offset, err = pw.WriteHeader(&Header{
+			Type: src.objects[i].Type,
+			Size: int64(len(data)),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = pw.Write(data)
+		return err
+	}
+	ent.offset, err = pw.WriteHeader(&Header{
+		Type:       OffsetDelta,
+		Size:       int64(len(ent.delta)),
+		BaseOffset: entries[ent.base].offset,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = pw.Write(ent.delta)
+	ent.delta = nil
+	return err
+}
+
+// findDeltas chooses delta bases for objects and stores the results in entries.
+// Only the content of the objects in the window is held in memory,
+// but the deltas stored in entries are kept until they are written.
+func findDeltas(src *buildSource, entries []buildEntry, window, maxDepth, hashSize int) error {
+	objects := src.objects
+	order := make([]int, len(objects))
+	nameHashes := make([]uint32, len(objects))
+	for i := range order {
+		order[i] = i
+		nameHashes[i] = packNameHash(objects[i].Name)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if objects[a].Type != objects[b].Type {
+			return objects[a].Type < objects[b].Type
+		}
+		if nameHashes[a] != nameHashes[b] {
+			return nameHashes[a] < nameHashes[b]
+		}
+		// Larger objects first: deltas that remove data are smaller than
+		// deltas that add data.
+		return src.size(a) > src.size(b)
+	})
+
+	type windowEntry struct {
+		i     int
+		data  []byte
+		index *DeltaIndex
+	}
+	// win is a ring buffer of the most recently considered objects.
+	win := make([]windowEntry, 0, window)
+	next := 0
+	var scratch []byte
+	for _, i := range order {
+		target, err := src.data(i)
+		if err != nil {
+			return err
+		}
+		if len(target) >= minDeltaTargetSize {
+			best := -1
+			var bestDelta []byte
+			// Consider the most recent objects first.
+			for k := 0; k < len(win); k++ {
+				w := &win[(next-1-k+len(win))%len(win)]
+				if objects[w.i].Type != objects[i].Type {
+					break
+				}
+				baseDepth := entries[w.i].depth
+				if baseDepth >= maxDepth {
+					continue
+				}
+				if len(w.data) < len(target)>>5 {
+					// Base is too small to be useful.
+					continue
+				}
+				maxSize := len(target)/2 - hashSize
+				if best != -1 {
+					maxSize = len(bestDelta) - 1
+				}
+				// Prefer shallower chains by requiring deeper bases to save more.
+				maxSize = maxSize * (maxDepth - baseDepth) / maxDepth
+				if maxSize <= 0 {
+					continue
+				}
+				if w.index == nil {
+					w.index = NewDeltaIndex(w.data)
+				}
+				var ok bool
+				scratch, ok = w.index.AppendDelta(scratch[:0], target, maxSize)
+				if ok {
+					best = w.i
+					bestDelta = append(bestDelta[:0], scratch...)
+				}
+			}
+			if best != -1 {
+				entries[i].base = best
+				entries[i].delta = bestDelta
+				entries[i].depth = entries[best].depth + 1
+			}
+		}
+
+		// Add object to window.
+		if len(win) < window {
+			win = append(win, windowEntry{i: i, data: target})
+			next = len(win) % window
+		} else {
+			win[next] = windowEntry{i: i, data: target}
+			next = (next + 1) % window
+		}
+	}
+	return nil
+}
+
+// packNameHash returns a hash of a path such that paths with the same
+// trailing characters sort near each other. This is the same hash that
+// git-pack-objects(1) uses.
+func packNameHash(name string) uint32 {
+	var hash uint32
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < unicode.MaxASCII && unicode.IsSpace(rune(c)) {
+			continue
+		}
+		hash = hash>>2 + uint32(c)<<24
+	}
+	return hash
+}
@@ -0,0 +1,256 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package packfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+)
+
+func TestBuild(t *testing.T) {
+	var objects []BuildObject
+	var content strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&content, "This is line %d of a file that changes a little in every version.\n", i)
+	}
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&content, "Version %d\n", i)
+		objects = append(objects, BuildObject{
+			Type: Blob,
+			Data: []byte(content.String()),
+			Name: "file.txt",
+		})
+		objects = append(objects, BuildObject{
+			Type: Blob,
+			Data: []byte(fmt.Sprintf("Small file %d\n", i)),
+			Name: fmt.Sprintf("small%d.txt", i),
+		})
+	}
+	objects = append(objects, BuildObject{
+		Type: Commit,
+		Data: []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+			"author Octocat <octocat@example.com> 1578610200 -0800\n" +
+			"committer Octocat <octocat@example.com> 1578610200 -0800\n" +
+			"\n" +
+			"Initial import\n"),
+	})
+
+	for _, format := range []githash.ObjectFormat{githash.SHA1Format, githash.SHA256Format} {
+		t.Run(format.String(), func(t *testing.T) {
+			undeltified := new(bytes.Buffer)
+			err := Build(undeltified, objects, &BuildOptions{
+				ObjectFormat: format,
+				Window:       -1,
+			})
+			if err != nil {
+				t.Fatal("Build(Window: -1):", err)
+			}
+			if n := countDeltas(t, undeltified.Bytes(), format); n != 0 {
+				t.Errorf("Build(Window: -1) wrote %d deltas; want 0", n)
+			}
+			checkBuiltPackfile(t, undeltified.Bytes(), objects, format)
+
+			deltified := new(bytes.Buffer)
+			if err := Build(deltified, objects, &BuildOptions{ObjectFormat: format}); err != nil {
+				t.Fatal("Build:", err)
+			}
+			if n := countDeltas(t, deltified.Bytes(), format); n < 19 {
+				t.Errorf("Build wrote %d deltas; want >=19", n)
+			}
+			if deltified.Len() >= undeltified.Len()/2 {
+				t.Errorf("deltified packfile is %d bytes; want less than half of undeltified packfile (%d bytes)", deltified.Len(), undeltified.Len())
+			}
+			checkBuiltPackfile(t, deltified.Bytes(), objects, format)
+		})
+	}
+
+	t.Run("Depth", func(t *testing.T) {
+		const maxDepth = 3
+		buf := new(bytes.Buffer)
+		if err := Build(buf, objects, &BuildOptions{Depth: maxDepth}); err != nil {
+			t.Fatal("Build:", err)
+		}
+		idx, err := BuildIndex(bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := bytes.NewReader(buf.Bytes())
+		for _, start := range idx.Offsets {
+			depth := 0
+			offset := start
+			for {
+				if _, err := f.Seek(offset, io.SeekStart); err != nil {
+					t.Fatal(err)
+				}
+				hdr, err := ReadHeader(offset, f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if hdr.Type != OffsetDelta {
+					break
+				}
+				depth++
+				offset = hdr.BaseOffset
+			}
+			if depth > maxDepth {
+				t.Errorf("object at %d has delta chain of length %d; want <=%d", start, depth, maxDepth)
+			}
+		}
+		checkBuiltPackfile(t, buf.Bytes(), objects, githash.SHA1Format)
+	})
+
+	t.Run("Load", func(t *testing.T) {
+		want := new(bytes.Buffer)
+		if err := Build(want, objects, nil); err != nil {
+			t.Fatal("Build:", err)
+		}
+
+		lazyObjects := make([]BuildObject, len(objects))
+		for i, obj := range objects {
+			lazyObjects[i] = BuildObject{
+				Type: obj.Type,
+				Size: int64(len(obj.Data)),
+				Name: obj.Name,
+			}
+		}
+		loads := make([]int, len(objects))
+		got := new(bytes.Buffer)
+		err := Build(got, lazyObjects, &BuildOptions{
+			Load: func(i int) ([]byte, error) {
+				loads[i]++
+				return objects[i].Data, nil
+			},
+		})
+		if err != nil {
+			t.Fatal("Build:", err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Error("Build with Load wrote a different packfile than Build with Data")
+		}
+		for i, n := range loads {
+			if n == 0 || n > 2 {
+				t.Errorf("object %d loaded %d times; want 1 or 2", i, n)
+			}
+		}
+
+		errLoad := errors.New("bork")
+		err = Build(io.Discard, lazyObjects, &BuildOptions{
+			Load: func(i int) ([]byte, error) {
+				return nil, errLoad
+			},
+		})
+		if !errors.Is(err, errLoad) {
+			t.Errorf("Build with failing Load = %v; want %v", err, errLoad)
+		}
+	})
+
+	t.Run("InvalidType", func(t *testing.T) {
+		err := Build(io.Discard, []BuildObject{{Type: OffsetDelta}}, nil)
+		if err == nil {
+			t.Error("Build did not return an error")
+		}
+	})
+}
+
+// checkBuiltPackfile verifies that the packfile contains exactly the given objects.
+func checkBuiltPackfile(t *testing.T, pack []byte, objects []BuildObject, format githash.ObjectFormat) {
+	t.Helper()
+	idx, err := BuildIndex(bytes.NewReader(pack), int64(len(pack)), &IndexOptions{ObjectFormat: format})
+	if err != nil {
+		t.Fatal("BuildIndex:", err)
+	}
+	if idx.Len() != len(objects) {
+		t.Errorf("packfile has %d objects; want %d", idx.Len(), len(objects))
+	}
+	f := NewBufferedReadSeeker(bytes.NewReader(pack))
+	u := new(Undeltifier)
+	for i, obj := range objects {
+		h := format.New()
+		h.Write(object.AppendPrefix(nil, obj.Type.NonDelta(), int64(len(obj.Data))))
+		h.Write(obj.Data)
+		id := format.Sum(h)
+		j := idx.FindID(id)
+		if j == -1 {
+			t.Errorf("object %d (%v) not found in packfile", i, id)
+			continue
+		}
+		prefix, r, err := u.Undeltify(f, idx.Offsets[j], &UndeltifyOptions{Index: idx})
+		if err != nil {
+			t.Errorf("object %d (%v): %v", i, id, err)
+			continue
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Errorf("object %d (%v): %v", i, id, err)
+			continue
+		}
+		if prefix.Type != obj.Type.NonDelta() || !bytes.Equal(got, obj.Data) {
+			t.Errorf("object %d (%v) = %v %q; want %v %q", i, id, prefix.Type, got, obj.Type.NonDelta(), obj.Data)
+		}
+	}
+}
+
+func countDeltas(t *testing.T, pack []byte, format githash.ObjectFormat) int {
+	t.Helper()
+	r := NewReaderFormat(bytes.NewReader(pack), format)
+	n := 0
+	for {
+		hdr, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return n
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Type == OffsetDelta || hdr.Type == RefDelta {
+			n++
+		}
+	}
+}
+
+func BenchmarkBuild(b *testing.B) {
+	var objects []BuildObject
+	var content strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&content, "This is line %d of a file that changes a little in every version.\n", i)
+	}
+	total := 0
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&content, "Version %d\n", i)
+		objects = append(objects, BuildObject{
+			Type: Blob,
+			Data: []byte(content.String()),
+			Name: "file.txt",
+		})
+		total += content.Len()
+	}
+	b.SetBytes(int64(total))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Build(io.Discard, objects, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
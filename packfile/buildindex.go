@@ -19,7 +19,6 @@ package packfile
 import (
 	"bufio"
 	"bytes"
-	"crypto/sha1"
 	"fmt"
 	"hash"
 	"hash/crc32"
@@ -33,12 +32,22 @@ import (
 
 // IndexOptions holds optional arguments to BuildIndex.
 type IndexOptions struct {
+	// ObjectFormat is the object format of the packfile.
+	// The zero value is githash.SHA1Format.
+	ObjectFormat githash.ObjectFormat
 }
 
 // BuildIndex indexes a packfile. This is equivalent to running git-index-pack(1)
 // on the packfile.
 func BuildIndex(f io.ReaderAt, fileSize int64, opts *IndexOptions) (*Index, error) {
-	fileHash := sha1.New()
+	format := githash.SHA1Format
+	if opts != nil {
+		format = opts.ObjectFormat
+	}
+	if !format.IsValid() {
+		return nil, fmt.Errorf("packfile: build index: unknown object format %v", format)
+	}
+	fileHash := format.New()
 	hashTee := &teeByteReader{
 		r: bufio.NewReader(io.NewSectionReader(f, 0, fileSize)),
 		w: fileHash,
@@ -50,22 +59,25 @@ func BuildIndex(f io.ReaderAt, fileSize int64, opts *IndexOptions) (*Index, erro
 
 	// Read file serially to get initial index.
 	brc := &byteReaderCounter{r: hashTee, n: fileHeaderSize}
-	base, err := baseIndexPass(brc, nobjs)
+	base, err := baseIndexPass(brc, nobjs, format)
 	if err != nil {
 		return nil, fmt.Errorf("packfile: build index: %w", err)
 	}
 
-	// Verify end-of-packfile SHA-1 hash.
-	var gotSum githash.SHA1
-	fileHash.Sum(gotSum[:0])
+	// Verify end-of-packfile hash.
+	gotSum := format.Sum(fileHash)
 	endOfObjects := brc.n
-	if _, err := f.ReadAt(base.PackfileSHA1[:], endOfObjects); err != nil {
+	wantSum := make([]byte, format.Size())
+	if _, err := f.ReadAt(wantSum, endOfObjects); err != nil {
+		return nil, fmt.Errorf("packfile: build index: %w", err)
+	}
+	if err := base.PackfileSHA1.UnmarshalBinary(wantSum); err != nil {
 		return nil, fmt.Errorf("packfile: build index: %w", err)
 	}
-	if !bytes.Equal(gotSum[:], base.PackfileSHA1[:]) {
+	if gotSum != base.PackfileSHA1 {
 		return nil, fmt.Errorf("packfile: build index: packfile checksum does not match content")
 	}
-	if endOfObjects+githash.SHA1Size != fileSize {
+	if endOfObjects+int64(format.Size()) != fileSize {
 		return nil, fmt.Errorf("packfile: build index: trailing data in packfile")
 	}
 
@@ -73,7 +85,7 @@ func BuildIndex(f io.ReaderAt, fileSize int64, opts *IndexOptions) (*Index, erro
 	if !base.hasDeltas() {
 		return base.Index, nil
 	}
-	c := newDeltaCrawler(f, base)
+	c := newDeltaCrawler(f, base, format)
 	defer c.wait()
 	var rootReader *bufio.Reader
 	var z zlibReader
@@ -84,7 +96,7 @@ func BuildIndex(f io.ReaderAt, fileSize int64, opts *IndexOptions) (*Index, erro
 		} else {
 			rootReader.Reset(section)
 		}
-		hdr, err := readObjectHeader(offset, rootReader)
+		hdr, err := readObjectHeader(offset, rootReader, format)
 		if err != nil {
 			return "", nil, err
 		}
@@ -154,7 +166,7 @@ type baseIndex struct {
 	*Index
 	rootOffsets      []int64
 	childrenByOffset map[int64][]*deltaObject
-	childrenByID     map[githash.SHA1][]*deltaObject
+	childrenByID     map[githash.ObjectID][]*deltaObject
 }
 
 func (base *baseIndex) hasDeltas() bool {
@@ -175,24 +187,24 @@ const maxDeltaObjectSize = 16 << 20 // 16 MiB
 
 // basePass indexes any non-deltified objects and builds a tree of deltified
 // objects to undeltify.
-func baseIndexPass(r *byteReaderCounter, nobjs uint32) (*baseIndex, error) {
+func baseIndexPass(r *byteReaderCounter, nobjs uint32, format githash.ObjectFormat) (*baseIndex, error) {
 	result := &baseIndex{
 		Index: &Index{
-			ObjectIDs:       make([]githash.SHA1, 0, int(nobjs)),
+			ObjectIDs:       make([]githash.ObjectID, 0, int(nobjs)),
 			Offsets:         make([]int64, 0, int(nobjs)),
 			PackedChecksums: make([]uint32, 0, int(nobjs)),
 		},
 		childrenByOffset: make(map[int64][]*deltaObject),
-		childrenByID:     make(map[githash.SHA1][]*deltaObject),
+		childrenByID:     make(map[githash.ObjectID][]*deltaObject),
 	}
 	sizes := make([]int64, 0, int(nobjs))
-	sha1Hash := sha1.New()
+	objectHash := format.New()
 	c := crc32.NewIEEE()
 	t := &teeByteReader{r: r, w: c}
 	var z zlibReader
 	for ; nobjs > 0; nobjs-- {
 		c.Reset()
-		hdr, err := readObjectHeader(r.n, t)
+		hdr, err := readObjectHeader(r.n, t, format)
 		if err != nil {
 			return nil, err
 		}
@@ -240,9 +252,9 @@ func baseIndexPass(r *byteReaderCounter, nobjs uint32) (*baseIndex, error) {
 			}
 			continue
 		}
-		sha1Hash.Reset()
-		sha1Hash.Write(object.AppendPrefix(nil, objType, hdr.Size))
-		size, err := io.Copy(sha1Hash, z)
+		objectHash.Reset()
+		objectHash.Write(object.AppendPrefix(nil, objType, hdr.Size))
+		size, err := io.Copy(objectHash, z)
 		if err != nil {
 			return nil, err
 		}
@@ -252,10 +264,8 @@ func baseIndexPass(r *byteReaderCounter, nobjs uint32) (*baseIndex, error) {
 		if size > hdr.Size {
 			return nil, errTooLong
 		}
-		var sum githash.SHA1
-		sha1Hash.Sum(sum[:0])
 		result.Offsets = append(result.Offsets, hdr.Offset)
-		result.ObjectIDs = append(result.ObjectIDs, sum)
+		result.ObjectIDs = append(result.ObjectIDs, format.Sum(objectHash))
 		result.PackedChecksums = append(result.PackedChecksums, c.Sum32())
 		sizes = append(sizes, hdr.Size)
 	}
@@ -287,10 +297,10 @@ type deltaCrawler struct {
 	mu               sync.Mutex
 	newIndex         *Index // unsorted
 	childrenByOffset map[int64][]*deltaObject
-	childrenByID     map[githash.SHA1][]*deltaObject
+	childrenByID     map[githash.ObjectID][]*deltaObject
 }
 
-func newDeltaCrawler(f io.ReaderAt, base *baseIndex) *deltaCrawler {
+func newDeltaCrawler(f io.ReaderAt, base *baseIndex, format githash.ObjectFormat) *deltaCrawler {
 	c := &deltaCrawler{
 		f:                f,
 		sem:              make(chan *indexer, 2),
@@ -300,7 +310,7 @@ func newDeltaCrawler(f io.ReaderAt, base *baseIndex) *deltaCrawler {
 	}
 	*c.newIndex = *base.Index
 	for i := 0; i < cap(c.sem); i++ {
-		c.sem <- new(indexer)
+		c.sem <- &indexer{format: format}
 	}
 	return c
 }
@@ -371,7 +381,7 @@ func (c *deltaCrawler) wait() error {
 }
 
 // An indexer decompresses deltified objects and computes their IDs.
-// The zero value is a valid indexer.
+// The zero value is a valid indexer for SHA-1 packfiles.
 //
 // indexer is distinct from Undeltifier because it creates new byte buffers for
 // each undeltification. This is crucial for index-building because it permits
@@ -380,37 +390,36 @@ func (c *deltaCrawler) wait() error {
 // The fields of indexer are expensive to create in a tight loop. Reusing an
 // indexer reduces memory allocations.
 type indexer struct {
-	z    zlibReader
-	sha1 hash.Hash
+	z      zlibReader
+	format githash.ObjectFormat
+	hash   hash.Hash
 }
 
-func (idxr *indexer) undeltify(typ object.Type, baseObject io.ReadSeeker, deltaPackObject ByteReader) (githash.SHA1, []byte, error) {
-	if _, err := ReadHeader(0, deltaPackObject); err != nil {
-		return githash.SHA1{}, nil, err
+func (idxr *indexer) undeltify(typ object.Type, baseObject io.ReadSeeker, deltaPackObject ByteReader) (githash.ObjectID, []byte, error) {
+	if _, err := ReadHeaderFormat(0, deltaPackObject, idxr.format); err != nil {
+		return githash.ObjectID{}, nil, err
 	}
 	if err := setZlibReader(&idxr.z, deltaPackObject); err != nil {
-		return githash.SHA1{}, nil, err
+		return githash.ObjectID{}, nil, err
 	}
 	defer setZlibReader(&idxr.z, emptyReader{}) // don't retain deltaPackObject past function return
 	newObjectReader := NewDeltaReader(baseObject, bufio.NewReader(idxr.z))
 	newSize, err := newObjectReader.Size()
 	if err != nil {
-		return githash.SHA1{}, nil, err
+		return githash.ObjectID{}, nil, err
 	}
 	newObject := bytes.NewBuffer(make([]byte, 0, newSize))
 	if _, err := io.Copy(newObject, newObjectReader); err != nil {
-		return githash.SHA1{}, nil, err
+		return githash.ObjectID{}, nil, err
 	}
-	if idxr.sha1 == nil {
-		idxr.sha1 = sha1.New()
+	if idxr.hash == nil {
+		idxr.hash = idxr.format.New()
 	} else {
-		idxr.sha1.Reset()
+		idxr.hash.Reset()
 	}
-	idxr.sha1.Write(object.AppendPrefix(nil, typ, int64(newObject.Len())))
-	idxr.sha1.Write(newObject.Bytes())
-	var sumSHA1 githash.SHA1
-	idxr.sha1.Sum(sumSHA1[:0])
-	return sumSHA1, newObject.Bytes(), nil
+	idxr.hash.Write(object.AppendPrefix(nil, typ, int64(newObject.Len())))
+	idxr.hash.Write(newObject.Bytes())
+	return idxr.format.Sum(idxr.hash), newObject.Bytes(), nil
 }
 
 type teeByteReader struct {
@@ -56,6 +56,78 @@ func TestBuildIndex(t *testing.T) {
 	}
 }
 
+func TestBuildIndexSHA256(t *testing.T) {
+	const baseData = "Hello, World!\n"
+	baseID, err := githash.ParseObjectID("dabc789f60c22621c92df8736ff8cb60e35185584772b93b9315a3e2aab55653")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltaID, err := githash.ParseObjectID("db65ba1a9c70d385661fcf67b9b87e5e3659699d2f7d5155534a3b5dcd08e1c5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Produces "Hello!\n" from baseData.
+	delta := []byte{
+		byte(len(baseData)), 7,
+		0x80 | 0x10, 5,
+		2, '!', '\n',
+	}
+
+	buf := new(bytes.Buffer)
+	w := NewWriterFormat(buf, 2, githash.SHA256Format)
+	if _, err := w.WriteHeader(&Header{Type: Blob, Size: int64(len(baseData))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(baseData)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.WriteHeader(&Header{
+		Type:       RefDelta,
+		Size:       int64(len(delta)),
+		BaseObject: baseID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(delta); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := BuildIndex(bytes.NewReader(buf.Bytes()), int64(buf.Len()), &IndexOptions{
+		ObjectFormat: githash.SHA256Format,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ObjectFormat() != githash.SHA256Format {
+		t.Errorf("index object format = %v; want %v", got.ObjectFormat(), githash.SHA256Format)
+	}
+	wantIDs := []githash.ObjectID{baseID, deltaID}
+	if diff := cmp.Diff(wantIDs, got.ObjectIDs); diff != "" {
+		t.Errorf("object IDs (-want +got):\n%s", diff)
+	}
+
+	// Round-trip the index.
+	idxBuf := new(bytes.Buffer)
+	if err := got.EncodeV2(idxBuf); err != nil {
+		t.Fatal("EncodeV2:", err)
+	}
+	decoded, err := ReadIndexFormat(idxBuf, githash.SHA256Format)
+	if err != nil {
+		t.Fatal("ReadIndexFormat:", err)
+	}
+	if diff := cmp.Diff(got, decoded, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("decoded index (-want +got):\n%s", diff)
+	}
+
+	if _, err := BuildIndex(bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil); err == nil {
+		t.Error("BuildIndex with SHA-1 did not return an error")
+	}
+}
+
 func BenchmarkBuildIndex(b *testing.B) {
 	buf := new(bytes.Buffer)
 	w := NewWriter(buf, uint32(b.N))
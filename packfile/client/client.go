@@ -35,6 +35,7 @@ import (
 
 	"gg-scm.io/pkg/git/githash"
 	"gg-scm.io/pkg/git/internal/giturl"
+	"golang.org/x/crypto/ssh"
 )
 
 // Remote represents a Git repository that can be pulled from or pushed to.
@@ -49,6 +50,18 @@ type Options struct {
 	HTTPClient        *http.Client // defaults to http.DefaultClient
 	HTTPAuthorization string
 	UserAgent         string
+
+	// SSHConfig is the configuration for connecting to ssh:// remotes with
+	// an in-process SSH client. A user in the URL takes precedence over
+	// SSHConfig.User.
+	//
+	// If SSHConfig is nil, then ssh:// remotes are reached by running
+	// an ssh(1)-compatible command.
+	SSHConfig *ssh.ClientConfig
+	// SSHCommand is a shell command that is used instead of ssh(1) when
+	// SSHConfig is nil. If SSHCommand is empty, then the GIT_SSH_COMMAND and
+	// GIT_SSH environment variables are consulted in the same way as Git.
+	SSHCommand string
 }
 
 func (opts *Options) httpClient() *http.Client {
@@ -79,6 +92,8 @@ func (opts *Options) httpUserAgent() string {
 //
 //   file: Local git-upload-pack/git-receive-pack subprocesses
 //   http/https: Smart Git HTTP protocol
+//   ssh: git-upload-pack/git-receive-pack over SSH
+//   git: Git daemon protocol
 func NewRemote(u *url.URL, opts *Options) (*Remote, error) {
 	urlstr := u.Redacted()
 	remote := &Remote{
@@ -121,16 +136,28 @@ func NewRemote(u *url.URL, opts *Options) (*Remote, error) {
 			authorization: auth,
 			userAgent:     opts.httpUserAgent(),
 		}
+	case "ssh", "git+ssh", "ssh+git":
+		sshRemote, err := newSSHRemote(u, opts)
+		if err != nil {
+			return nil, fmt.Errorf("open remote %s: %w", urlstr, err)
+		}
+		remote.impl = &connRemote{dial: sshRemote.dial}
+	case "git":
+		daemonRemote, err := newDaemonRemote(u)
+		if err != nil {
+			return nil, fmt.Errorf("open remote %s: %w", urlstr, err)
+		}
+		remote.impl = &connRemote{dial: daemonRemote.dial}
 	default:
 		return nil, fmt.Errorf("open remote %s: unknown scheme %q", urlstr, u.Scheme)
 	}
 	return remote, nil
 }
 
-func parseObjectID(src []byte) (githash.SHA1, error) {
-	var id githash.SHA1
+func parseObjectID(src []byte) (githash.ObjectID, error) {
+	var id githash.ObjectID
 	if err := id.UnmarshalText(src); err != nil {
-		return githash.SHA1{}, fmt.Errorf("parse object id: %w", err)
+		return githash.ObjectID{}, fmt.Errorf("parse object id: %w", err)
 	}
 	return id, nil
 }
@@ -138,10 +165,12 @@ func parseObjectID(src []byte) (githash.SHA1, error) {
 type impl interface {
 	advertiseRefs(ctx context.Context, extraParams string) (io.ReadCloser, error)
 	uploadPack(ctx context.Context, extraParams string, request io.Reader) (io.ReadCloser, error)
-	receivePack(ctx context.Context) (receivePackConn, error)
+	receivePack(ctx context.Context) (serviceConn, error)
 }
 
-type receivePackConn interface {
+// A serviceConn is a bidirectional connection to a git-upload-pack or
+// git-receive-pack process.
+type serviceConn interface {
 	io.Reader
 	io.Writer
 	CloseWrite() error
@@ -0,0 +1,123 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"gg-scm.io/pkg/git/internal/pktline"
+)
+
+// Git service names.
+const (
+	uploadPackService  = "git-upload-pack"
+	receivePackService = "git-receive-pack"
+)
+
+// connRemote is an impl for transports that start a Git service on a
+// persistent bidirectional connection, like SSH.
+//
+// The rest of the package uses stateless request/response semantics
+// (like the smart HTTP protocol), so connRemote opens a new connection for
+// every request and discards the advertisement that the server sends at the
+// start of each connection before sending the request.
+type connRemote struct {
+	// dial starts the given service on the remote. extraParams is the value of
+	// GIT_PROTOCOL to send to the service, if the transport supports it.
+	dial func(ctx context.Context, service string, extraParams string) (serviceConn, error)
+}
+
+func (r *connRemote) advertiseRefs(ctx context.Context, extraParams string) (io.ReadCloser, error) {
+	conn, err := r.dial(ctx, uploadPackService, extraParams)
+	if err != nil {
+		return nil, err
+	}
+	return uploadPackConnReader{conn}, nil
+}
+
+func (r *connRemote) uploadPack(ctx context.Context, extraParams string, request io.Reader) (_ io.ReadCloser, err error) {
+	conn, err := r.dial(ctx, uploadPackService, extraParams)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+	if err := skipAdvertisement(conn); err != nil {
+		return nil, fmt.Errorf("%s: %w", uploadPackService, err)
+	}
+	if _, err := io.Copy(conn, request); err != nil {
+		return nil, err
+	}
+	return uploadPackConnReader{conn}, nil
+}
+
+func (r *connRemote) receivePack(ctx context.Context) (serviceConn, error) {
+	return r.dial(ctx, receivePackService, "")
+}
+
+// skipAdvertisement reads pkt-lines from r up to and including the first
+// flush packet.
+func skipAdvertisement(r io.Reader) error {
+	pr := pktline.NewReader(r)
+	for pr.Next() {
+		if pr.Type() == pktline.Flush {
+			return nil
+		}
+		line, err := pr.Text()
+		if err != nil {
+			return fmt.Errorf("read advertisement: %w", err)
+		}
+		if bytes.HasPrefix(line, []byte("ERR ")) {
+			return fmt.Errorf("read advertisement: server error: %s", line[len("ERR "):])
+		}
+	}
+	return fmt.Errorf("read advertisement: %w", pr.Err())
+}
+
+// uploadPackConnReader is the read half of a connection to git-upload-pack.
+type uploadPackConnReader struct {
+	conn serviceConn
+}
+
+func (r uploadPackConnReader) Read(p []byte) (int, error) {
+	return r.conn.Read(p)
+}
+
+// Close closes the connection. If the caller stops reading early,
+// git-upload-pack may be blocked writing, so if possible, the connection is
+// aborted instead of waiting for the service to exit. As with uploadPackReader,
+// errors from the service exiting are not interesting.
+func (r uploadPackConnReader) Close() error {
+	if a, ok := r.conn.(aborter); ok {
+		a.abort()
+		return nil
+	}
+	r.conn.Close()
+	return nil
+}
+
+// An aborter is a serviceConn that can be closed without waiting for the
+// service to exit cleanly.
+type aborter interface {
+	abort()
+}
@@ -0,0 +1,171 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"gg-scm.io/pkg/git/internal/pktline"
+)
+
+// defaultGitDaemonPort is the TCP port that git-daemon(1) listens on by default.
+const defaultGitDaemonPort = "9418"
+
+// daemonRemote starts Git services with the git:// protocol
+// served by git-daemon(1).
+type daemonRemote struct {
+	addr string // host:port to dial
+	host string // host parameter sent to the daemon
+	path string
+}
+
+func newDaemonRemote(u *url.URL) (*daemonRemote, error) {
+	if u.Hostname() == "" {
+		return nil, errors.New("missing host")
+	}
+	if u.Path == "" {
+		return nil, errors.New("missing path")
+	}
+	if strings.ContainsRune(u.Host, 0) || strings.ContainsRune(u.Path, 0) {
+		return nil, errors.New("URL contains NUL byte")
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultGitDaemonPort
+	}
+	path := u.Path
+	// Like Git, treat git://example.com/~user/foo as relative to user's home.
+	if strings.HasPrefix(path, "/~") {
+		path = path[1:]
+	}
+	return &daemonRemote{
+		addr: net.JoinHostPort(u.Hostname(), port),
+		host: u.Host,
+		path: path,
+	}, nil
+}
+
+// request returns the initial request line for the given service.
+// See https://git-scm.com/docs/pack-protocol#_git_transport
+func (r *daemonRemote) request(service string, extraParams string) []byte {
+	line := service + " " + r.path + "\x00host=" + r.host + "\x00"
+	if extraParams != "" {
+		line += "\x00"
+		for _, param := range strings.Split(extraParams, ":") {
+			line += param + "\x00"
+		}
+	}
+	return pktline.AppendString(nil, line)
+}
+
+func (r *daemonRemote) dial(ctx context.Context, service string, extraParams string) (serviceConn, error) {
+	errPrefix := "git://" + r.host + " " + service
+	netConn, err := new(net.Dialer).DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	conn := &daemonConn{
+		errPrefix: errPrefix,
+		conn:      netConn,
+		done:      make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			netConn.Close()
+		case <-conn.done:
+		}
+	}()
+	if _, err := netConn.Write(r.request(service, extraParams)); err != nil {
+		conn.abort()
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	return conn, nil
+}
+
+// daemonConn is a serviceConn to a git-daemon(1) service.
+type daemonConn struct {
+	errPrefix string
+	conn      net.Conn
+	wrote     bool
+
+	done      chan struct{} // closed when the connection is closed
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func (conn *daemonConn) Read(p []byte) (int, error) {
+	n, err := conn.conn.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("%s: %w", conn.errPrefix, err)
+	}
+	return n, err
+}
+
+func (conn *daemonConn) Write(p []byte) (int, error) {
+	n, err := conn.conn.Write(p)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", conn.errPrefix, err)
+	}
+	if n > 0 {
+		conn.wrote = true
+	}
+	return n, err
+}
+
+func (conn *daemonConn) CloseWrite() error {
+	cw, ok := conn.conn.(interface{ CloseWrite() error })
+	if !ok {
+		return nil
+	}
+	if err := cw.CloseWrite(); err != nil {
+		return fmt.Errorf("%s: %w", conn.errPrefix, err)
+	}
+	return nil
+}
+
+func (conn *daemonConn) Close() error {
+	conn.closeOnce.Do(func() {
+		if !conn.wrote {
+			conn.conn.Write(pktline.AppendFlush(nil))
+		}
+		conn.CloseWrite()
+		// Unlike a process, the daemon has no exit status to report,
+		// so wait for the daemon to close its end of the connection.
+		_, err := io.Copy(io.Discard, conn.conn)
+		if err != nil {
+			conn.closeErr = fmt.Errorf("%s: %w", conn.errPrefix, err)
+		}
+		conn.conn.Close()
+		close(conn.done)
+	})
+	return conn.closeErr
+}
+
+func (conn *daemonConn) abort() {
+	conn.closeOnce.Do(func() {
+		conn.conn.Close()
+		close(conn.done)
+	})
+}
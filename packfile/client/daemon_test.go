@@ -0,0 +1,138 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestDaemonRequest(t *testing.T) {
+	tests := []struct {
+		url         string
+		service     string
+		extraParams string
+		want        string
+	}{
+		{
+			url:     "git://example.com/foo.git",
+			service: uploadPackService,
+			want:    "002egit-upload-pack /foo.git\x00host=example.com\x00",
+		},
+		{
+			url:         "git://example.com:1234/foo.git",
+			service:     uploadPackService,
+			extraParams: v2ExtraParams,
+			want:        "003egit-upload-pack /foo.git\x00host=example.com:1234\x00\x00version=2\x00",
+		},
+		{
+			url:         "git://example.com/~octocat/foo.git",
+			service:     receivePackService,
+			extraParams: "version=1:object-format=sha1",
+			want:        "0055git-receive-pack ~octocat/foo.git\x00host=example.com\x00\x00version=1\x00object-format=sha1\x00",
+		},
+	}
+	for _, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		r, err := newDaemonRemote(u)
+		if err != nil {
+			t.Errorf("newDaemonRemote(%q): %v", test.url, err)
+			continue
+		}
+		if got := string(r.request(test.service, test.extraParams)); got != test.want {
+			t.Errorf("newDaemonRemote(%q).request(%q, %q) = %q; want %q", test.url, test.service, test.extraParams, got, test.want)
+		}
+	}
+}
+
+// serveGitDaemon starts a TCP server that runs git-daemon(1) in inetd mode
+// for each connection, serving the repository in dir.
+// It returns the git:// URL for the repository.
+func serveGitDaemon(tb testing.TB, gitExe string, dir string) *url.URL {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	procs := make(map[*os.Process]struct{})
+	tb.Cleanup(func() {
+		l.Close()
+		// Stop any daemons that are still waiting on a client.
+		mu.Lock()
+		for p := range procs {
+			p.Kill()
+		}
+		mu.Unlock()
+		wg.Wait()
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// Pass the socket to git-daemon directly, as inetd would.
+			f, err := c.(*net.TCPConn).File()
+			c.Close()
+			if err != nil {
+				continue
+			}
+			cmd := exec.Command(gitExe, "daemon",
+				"--inetd",
+				"--export-all",
+				"--enable=receive-pack",
+				"--base-path="+filepath.Dir(dir),
+				"--log-destination=none",
+			)
+			cmd.Stdin = f
+			cmd.Stdout = f
+			err = cmd.Start()
+			f.Close()
+			if err != nil {
+				continue
+			}
+			mu.Lock()
+			procs[cmd.Process] = struct{}{}
+			mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cmd.Wait()
+				mu.Lock()
+				delete(procs, cmd.Process)
+				mu.Unlock()
+			}()
+		}
+	}()
+	return &url.URL{
+		Scheme: "git",
+		Host:   l.Addr().String(),
+		Path:   "/" + filepath.Base(dir),
+	}
+}
@@ -19,7 +19,6 @@ package client_test
 import (
 	"bufio"
 	"context"
-	"crypto/sha1"
 	"errors"
 	"fmt"
 	"io"
@@ -70,7 +69,7 @@ func ExamplePullStream_clone() {
 	if err != nil {
 		// handle error
 	}
-	var want []githash.SHA1
+	var want []githash.ObjectID
 	for _, r := range refs {
 		want = append(want, r.ObjectID)
 	}
@@ -86,7 +85,7 @@ func ExamplePullStream_clone() {
 	defer response.Packfile.Close()
 
 	// Read the packfile and print commit IDs.
-	packReader := packfile.NewReader(bufio.NewReader(response.Packfile))
+	packReader := packfile.NewReaderFormat(bufio.NewReader(response.Packfile), stream.ObjectFormat())
 	for {
 		hdr, err := packReader.Next()
 		if errors.Is(err, io.EOF) {
@@ -97,13 +96,12 @@ func ExamplePullStream_clone() {
 		}
 		if hdr.Type == packfile.Commit {
 			// Hash the object to get the ID.
-			h := sha1.New()
+			h := stream.ObjectFormat().New()
 			h.Write(object.AppendPrefix(nil, object.TypeCommit, hdr.Size))
 			if _, err := io.Copy(h, packReader); err != nil {
 				// handle error
 			}
-			var commitID githash.SHA1
-			h.Sum(commitID[:0])
+			commitID := stream.ObjectFormat().Sum(h)
 			fmt.Println(commitID)
 		}
 	}
@@ -143,7 +141,7 @@ func ExamplePullStream_singleRef() {
 
 	// Start pulling from remote.
 	response, err := stream.Negotiate(&client.PullRequest{
-		Want:     []githash.SHA1{headRef.ObjectID},
+		Want:     []githash.ObjectID{headRef.ObjectID},
 		Progress: os.Stdout,
 	})
 	if err != nil {
@@ -152,7 +150,7 @@ func ExamplePullStream_singleRef() {
 	defer response.Packfile.Close()
 
 	// Read the packfile and print commit IDs.
-	packReader := packfile.NewReader(bufio.NewReader(response.Packfile))
+	packReader := packfile.NewReaderFormat(bufio.NewReader(response.Packfile), stream.ObjectFormat())
 	for {
 		hdr, err := packReader.Next()
 		if errors.Is(err, io.EOF) {
@@ -163,13 +161,12 @@ func ExamplePullStream_singleRef() {
 		}
 		if hdr.Type == packfile.Commit {
 			// Hash the object to get the ID.
-			h := sha1.New()
+			h := stream.ObjectFormat().New()
 			h.Write(object.AppendPrefix(nil, object.TypeCommit, hdr.Size))
 			if _, err := io.Copy(h, packReader); err != nil {
 				// handle error
 			}
-			var commitID githash.SHA1
-			h.Sum(commitID[:0])
+			commitID := stream.ObjectFormat().Sum(h)
 			fmt.Println(commitID)
 		}
 	}
@@ -201,12 +198,12 @@ func ExamplePullStream_singleCommit() {
 	}
 
 	// Start pulling from remote.
-	want, err := githash.ParseSHA1("c8ede9119a7188f2564d3b7257fa526c9285c23f")
+	want, err := githash.ParseObjectID("c8ede9119a7188f2564d3b7257fa526c9285c23f")
 	if err != nil {
 		// handle error
 	}
 	response, err := stream.Negotiate(&client.PullRequest{
-		Want:     []githash.SHA1{want},
+		Want:     []githash.ObjectID{want},
 		Depth:    1,
 		Progress: os.Stderr,
 	})
@@ -216,7 +213,7 @@ func ExamplePullStream_singleCommit() {
 	defer response.Packfile.Close()
 
 	// Read the packfile and print commit IDs.
-	packReader := packfile.NewReader(bufio.NewReader(response.Packfile))
+	packReader := packfile.NewReaderFormat(bufio.NewReader(response.Packfile), stream.ObjectFormat())
 	for {
 		hdr, err := packReader.Next()
 		if errors.Is(err, io.EOF) {
@@ -227,13 +224,12 @@ func ExamplePullStream_singleCommit() {
 		}
 		if hdr.Type == packfile.Commit {
 			// Hash the object to get the ID.
-			h := sha1.New()
+			h := stream.ObjectFormat().New()
 			h.Write(object.AppendPrefix(nil, object.TypeCommit, hdr.Size))
 			if _, err := io.Copy(h, packReader); err != nil {
 				// handle error
 			}
-			var commitID githash.SHA1
-			h.Sum(commitID[:0])
+			commitID := stream.ObjectFormat().Sum(h)
 			fmt.Println(commitID)
 		}
 	}
@@ -281,7 +277,7 @@ func ExamplePushStream() {
 	newTree := object.Tree(nil)
 	newCommit := &object.Commit{
 		Tree:       newTree.SHA1(),
-		Parents:    []githash.SHA1{curr.ObjectID},
+		Parents:    []githash.ObjectID{curr.ObjectID},
 		Author:     author,
 		AuthorTime: now,
 		Committer:  author,
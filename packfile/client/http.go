@@ -156,7 +156,7 @@ func (r *httpRemote) uploadPack(ctx context.Context, extraParams string, request
 	return resp.Body, nil
 }
 
-func (r *httpRemote) receivePack(ctx context.Context) (_ receivePackConn, err error) {
+func (r *httpRemote) receivePack(ctx context.Context) (_ serviceConn, err error) {
 	resp, err := r.do(ctx, &http.Request{
 		Method: http.MethodGet,
 		URL:    r.url("/info/refs", url.Values{"service": {"git-receive-pack"}}),
@@ -91,9 +91,15 @@ func (r *uploadPackReader) Close() error {
 	return nil
 }
 
-func (r *fileRemote) receivePack(ctx context.Context) (receivePackConn, error) {
+func (r *fileRemote) receivePack(ctx context.Context) (serviceConn, error) {
 	errPrefix := "git-receive-pack " + r.dir
 	c := exec.Command(r.receivePackPath, "--", r.dir)
+	return startProcessConn(ctx, c, errPrefix)
+}
+
+// startProcessConn starts the given command and returns a connection
+// to its stdin and stdout.
+func startProcessConn(ctx context.Context, c *exec.Cmd, errPrefix string) (*processConn, error) {
 	stdin, err := c.StdinPipe()
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", errPrefix, err)
@@ -107,7 +113,7 @@ func (r *fileRemote) receivePack(ctx context.Context) (receivePackConn, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", errPrefix, err)
 	}
-	return &localReceivePackConn{
+	return &processConn{
 		errPrefix: errPrefix,
 		stdin:     stdin,
 		stdout:    stdout,
@@ -115,15 +121,16 @@ func (r *fileRemote) receivePack(ctx context.Context) (receivePackConn, error) {
 	}, nil
 }
 
-type localReceivePackConn struct {
+// processConn is a serviceConn to a subprocess's stdin and stdout.
+type processConn struct {
 	errPrefix string
 	stdin     io.WriteCloser
-	stdout    io.Reader
+	stdout    io.ReadCloser
 	wait      func() error
 	wrote     bool
 }
 
-func (conn *localReceivePackConn) Read(p []byte) (int, error) {
+func (conn *processConn) Read(p []byte) (int, error) {
 	n, err := conn.stdout.Read(p)
 	if err != nil && !errors.Is(err, io.EOF) {
 		err = fmt.Errorf("%s: %w", conn.errPrefix, err)
@@ -131,7 +138,7 @@ func (conn *localReceivePackConn) Read(p []byte) (int, error) {
 	return n, err
 }
 
-func (conn *localReceivePackConn) Write(p []byte) (int, error) {
+func (conn *processConn) Write(p []byte) (int, error) {
 	n, err := conn.stdin.Write(p)
 	if err != nil {
 		err = fmt.Errorf("%s: %w", conn.errPrefix, err)
@@ -142,11 +149,11 @@ func (conn *localReceivePackConn) Write(p []byte) (int, error) {
 	return n, err
 }
 
-func (conn *localReceivePackConn) CloseWrite() error {
+func (conn *processConn) CloseWrite() error {
 	return conn.stdin.Close()
 }
 
-func (conn *localReceivePackConn) Close() error {
+func (conn *processConn) Close() error {
 	if !conn.wrote {
 		conn.stdin.Write(pktline.AppendFlush(nil))
 	}
@@ -156,3 +163,12 @@ func (conn *localReceivePackConn) Close() error {
 	}
 	return nil
 }
+
+func (conn *processConn) abort() {
+	if !conn.wrote {
+		conn.stdin.Write(pktline.AppendFlush(nil))
+	}
+	conn.stdin.Close()
+	conn.stdout.Close()
+	conn.wait()
+}
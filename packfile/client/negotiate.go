@@ -0,0 +1,330 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"container/heap"
+	"fmt"
+	"time"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+)
+
+// Number of haves sent in each round of negotiation.
+const (
+	initialHaveBatch = 32
+	haveBatch        = 64
+)
+
+// maxInVain is the number of haves that a Negotiator sends
+// after the remote's last new acknowledgement before giving up.
+const maxInVain = 256
+
+// A CommitSource provides the local commits that a Negotiator walks.
+// *gitrepo.Repository implements CommitSource.
+type CommitSource interface {
+	ReadCommit(id githash.ObjectID) (*object.Commit, error)
+}
+
+// NegotiationAlgorithm selects the order in which a Negotiator
+// sends local commits to the remote.
+type NegotiationAlgorithm int
+
+// Negotiation algorithms.
+// See the fetch.negotiationAlgorithm option in git-config(1).
+const (
+	// NegotiateConsecutive sends every local commit, newest first.
+	NegotiateConsecutive NegotiationAlgorithm = iota
+	// NegotiateSkipping sends local commits newest first, but skips
+	// an increasing number of ancestors between each commit sent.
+	// It usually takes fewer rounds to find a common base in long histories,
+	// at the cost of potentially receiving more objects than necessary.
+	NegotiateSkipping
+)
+
+// NegotiatorOptions specifies optional parameters to NewNegotiator.
+type NegotiatorOptions struct {
+	Algorithm NegotiationAlgorithm
+}
+
+// A Negotiator finds the commits that the local repository shares with the
+// remote over multiple rounds of have/ack exchanges, like Git's fetch
+// negotiation. A Negotiator can only be used for a single negotiation.
+type Negotiator struct {
+	src      CommitSource
+	skipping bool
+	tips     []githash.ObjectID
+
+	commits map[githash.ObjectID]*negotiatorCommit
+	queue   negotiatorQueue
+	// nonCommon is the number of queued commits that are not known to be common.
+	nonCommon int
+	// common is the list of acknowledged commits, in the order acknowledged.
+	common []githash.ObjectID
+	ctr    int
+}
+
+// negotiatorCommit is a local commit that has been seen by a Negotiator.
+type negotiatorCommit struct {
+	id      githash.ObjectID
+	time    time.Time
+	parents []githash.ObjectID
+	common  bool
+	popped  bool
+
+	// Skipping state. The commit is sent when ttl reaches zero.
+	ttl         uint16
+	originalTTL uint16
+
+	ctr int // insertion order, to break ties
+}
+
+// NewNegotiator returns a new Negotiator that walks the history of
+// the given local commits.
+func NewNegotiator(src CommitSource, tips []githash.ObjectID, opts *NegotiatorOptions) *Negotiator {
+	if opts == nil {
+		opts = new(NegotiatorOptions)
+	}
+	return &Negotiator{
+		src:      src,
+		skipping: opts.Algorithm == NegotiateSkipping,
+		tips:     append([]githash.ObjectID(nil), tips...),
+		commits:  make(map[githash.ObjectID]*negotiatorCommit),
+	}
+}
+
+// Negotiate requests a packfile from the remote, sending the Negotiator's
+// local commits as haves until the remote is ready to send a packfile,
+// the Negotiator runs out of commits, or the remote has not acknowledged
+// any new commits in a while. The Have and HaveMore fields of req are ignored.
+// The returned response's Acks contains every commit the remote acknowledged
+// during the negotiation.
+func (neg *Negotiator) Negotiate(p *PullStream, req *PullRequest) (*PullResponse, error) {
+	for _, id := range neg.tips {
+		if _, err := neg.push(id); err != nil {
+			return nil, fmt.Errorf("pull %s: negotiate: %w", p.urlstr, err)
+		}
+	}
+	neg.tips = nil
+
+	round := *req
+	batch := initialHaveBatch
+	inVain := 0
+	for {
+		haves, err := neg.next(batch)
+		if err != nil {
+			return nil, fmt.Errorf("pull %s: negotiate: %w", p.urlstr, err)
+		}
+		if len(haves) == 0 {
+			break
+		}
+		inVain += len(haves)
+		// Every request is stateless, so the remote needs to be reminded
+		// of the commits it has already acknowledged.
+		round.Have = append(append([]githash.ObjectID(nil), neg.common...), haves...)
+		round.HaveMore = true
+		resp, err := p.Negotiate(&round)
+		if err != nil {
+			return nil, err
+		}
+		if neg.ack(round.Have, resp.Acks) {
+			inVain = 0
+		}
+		if resp.Packfile != nil {
+			resp.Acks = neg.acks()
+			return resp, nil
+		}
+		if resp.Ready || len(neg.common) > 0 && inVain >= maxInVain {
+			break
+		}
+		batch = haveBatch
+	}
+
+	round.Have = neg.common
+	round.HaveMore = false
+	resp, err := p.Negotiate(&round)
+	if err != nil {
+		return nil, err
+	}
+	neg.ack(round.Have, resp.Acks)
+	resp.Acks = neg.acks()
+	return resp, nil
+}
+
+// acks returns the set of commits acknowledged by the remote.
+func (neg *Negotiator) acks() map[githash.ObjectID]struct{} {
+	m := make(map[githash.ObjectID]struct{}, len(neg.common))
+	for _, id := range neg.common {
+		m[id] = struct{}{}
+	}
+	return m
+}
+
+// ack marks the commits in haves that are in acks as common.
+// It reports whether any of them were not previously known to be common.
+func (neg *Negotiator) ack(haves []githash.ObjectID, acks map[githash.ObjectID]struct{}) bool {
+	newCommon := false
+	for _, id := range haves {
+		if _, ok := acks[id]; !ok {
+			continue
+		}
+		c := neg.commits[id]
+		if c == nil || c.common {
+			continue
+		}
+		neg.common = append(neg.common, id)
+		neg.markCommon(c)
+		newCommon = true
+	}
+	return newCommon
+}
+
+// next returns up to n commits to send as haves.
+func (neg *Negotiator) next(n int) ([]githash.ObjectID, error) {
+	var haves []githash.ObjectID
+	for len(haves) < n && neg.nonCommon > 0 && len(neg.queue) > 0 {
+		c := heap.Pop(&neg.queue).(*negotiatorCommit)
+		c.popped = true
+		if !c.common {
+			neg.nonCommon--
+		}
+		send := !c.common && (!neg.skipping || c.ttl == 0)
+		parentPushed := false
+		for _, parentID := range c.parents {
+			pushed, err := neg.pushParent(c, parentID)
+			if err != nil {
+				return nil, err
+			}
+			parentPushed = parentPushed || pushed
+		}
+		if !c.common && !parentPushed {
+			// Always send commits at the edge of the walk.
+			send = true
+		}
+		if send {
+			haves = append(haves, c.id)
+		}
+	}
+	return haves, nil
+}
+
+// push adds a commit to the queue if it has not been seen before.
+func (neg *Negotiator) push(id githash.ObjectID) (*negotiatorCommit, error) {
+	if c := neg.commits[id]; c != nil {
+		return c, nil
+	}
+	commit, err := neg.src.ReadCommit(id)
+	if err != nil {
+		return nil, err
+	}
+	neg.ctr++
+	c := &negotiatorCommit{
+		id:      id,
+		time:    commit.CommitTime,
+		parents: commit.Parents,
+		ctr:     neg.ctr,
+	}
+	neg.commits[id] = c
+	heap.Push(&neg.queue, c)
+	neg.nonCommon++
+	return c, nil
+}
+
+// pushParent queues a parent of a commit that was just popped.
+// It reports whether the parent is still in the queue.
+func (neg *Negotiator) pushParent(child *negotiatorCommit, id githash.ObjectID) (bool, error) {
+	parent := neg.commits[id]
+	if parent == nil {
+		var err error
+		parent, err = neg.push(id)
+		if err != nil {
+			return false, err
+		}
+	} else if parent.popped {
+		// Clock skew: the parent was visited before its child.
+		return false, nil
+	}
+	if child.common {
+		neg.markCommon(parent)
+		return true, nil
+	}
+	if neg.skipping {
+		newOriginalTTL := child.originalTTL
+		newTTL := child.ttl
+		if child.ttl > 0 {
+			newTTL--
+		} else {
+			newOriginalTTL = child.originalTTL*3/2 + 1
+			newTTL = newOriginalTTL
+		}
+		if parent.originalTTL < newOriginalTTL {
+			parent.originalTTL = newOriginalTTL
+			parent.ttl = newTTL
+		}
+	}
+	return true, nil
+}
+
+// markCommon marks c and all of its seen ancestors as common.
+func (neg *Negotiator) markCommon(c *negotiatorCommit) {
+	stack := []*negotiatorCommit{c}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if c.common {
+			continue
+		}
+		c.common = true
+		if !c.popped {
+			neg.nonCommon--
+		}
+		for _, parentID := range c.parents {
+			if parent := neg.commits[parentID]; parent != nil {
+				stack = append(stack, parent)
+			}
+		}
+	}
+}
+
+// negotiatorQueue is a priority queue of commits, newest first.
+type negotiatorQueue []*negotiatorCommit
+
+func (q negotiatorQueue) Len() int { return len(q) }
+
+func (q negotiatorQueue) Less(i, j int) bool {
+	if !q[i].time.Equal(q[j].time) {
+		return q[i].time.After(q[j].time)
+	}
+	return q[i].ctr < q[j].ctr
+}
+
+func (q negotiatorQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *negotiatorQueue) Push(x interface{}) {
+	*q = append(*q, x.(*negotiatorCommit))
+}
+
+func (q *negotiatorQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return c
+}
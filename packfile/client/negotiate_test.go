@@ -0,0 +1,297 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+	"gg-scm.io/pkg/git/packfile"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNegotiatorNext(t *testing.T) {
+	h := newTestHistory(t)
+	commits := h.chain(nil, "c", 100, time.Minute)
+
+	t.Run("Consecutive", func(t *testing.T) {
+		neg := NewNegotiator(h.source, []githash.ObjectID{commits[99]}, nil)
+		if _, err := neg.push(commits[99]); err != nil {
+			t.Fatal(err)
+		}
+		got, err := neg.next(4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []githash.ObjectID{commits[99], commits[98], commits[97], commits[96]}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("first batch (-want +got):\n%s", diff)
+		}
+
+		// Acknowledging a commit should stop the walk of its ancestors.
+		if !neg.ack(got, map[githash.ObjectID]struct{}{commits[97]: {}}) {
+			t.Error("ack(...) = false; want true")
+		}
+		got, err = neg.next(4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) > 0 {
+			t.Errorf("second batch = %v; want empty", got)
+		}
+	})
+
+	t.Run("Skipping", func(t *testing.T) {
+		neg := NewNegotiator(h.source, []githash.ObjectID{commits[99]}, &NegotiatorOptions{
+			Algorithm: NegotiateSkipping,
+		})
+		if _, err := neg.push(commits[99]); err != nil {
+			t.Fatal(err)
+		}
+		got, err := neg.next(4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []githash.ObjectID{commits[99], commits[97], commits[94], commits[89]}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("first batch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestNegotiator(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+
+	// Build a remote with a long history and a local repository
+	// that diverged from it before the remote's most recent commits.
+	h := newTestHistory(t)
+	shared := h.chain(nil, "shared", 200, time.Minute)
+	remoteOnly := h.chain(shared[len(shared)-1:], "remote", 20, time.Minute)
+	localOnly := h.chain(shared[len(shared)-1:], "local", 300, time.Minute)
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	if err := g.Run(ctx, "init", "--quiet", "--bare", "."); err != nil {
+		t.Fatal(err)
+	}
+	remoteTip := remoteOnly[len(remoteOnly)-1]
+	if err := h.writeRepository(dir, remoteTip); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Run(ctx, "update-ref", "refs/heads/main", remoteTip.String()); err != nil {
+		t.Fatal(err)
+	}
+	localTip := localOnly[len(localOnly)-1]
+
+	algorithms := []struct {
+		name      string
+		algorithm NegotiationAlgorithm
+	}{
+		{"Consecutive", NegotiateConsecutive},
+		{"Skipping", NegotiateSkipping},
+	}
+	forEachTransportVersion(t, localGit.Exe(), dir, func(t *testing.T, u *url.URL, opts *Options, version int) {
+		remote, err := NewRemote(u, opts)
+		if err != nil {
+			t.Fatal("NewRemote:", err)
+		}
+		if version == 1 {
+			remote.pullExtraParams = v1ExtraParams
+		}
+		for _, test := range algorithms {
+			t.Run(test.name, func(t *testing.T) {
+				stream, err := remote.StartPull(ctx)
+				if err != nil {
+					t.Fatal("remote.StartPull:", err)
+				}
+				defer func() {
+					if err := stream.Close(); err != nil {
+						t.Error("stream.Close():", err)
+					}
+				}()
+				neg := NewNegotiator(h.source, []githash.ObjectID{localTip}, &NegotiatorOptions{
+					Algorithm: test.algorithm,
+				})
+				resp, err := neg.Negotiate(stream, &PullRequest{
+					Want: []githash.ObjectID{remoteTip},
+				})
+				if err != nil {
+					t.Fatal("Negotiate:", err)
+				}
+				if resp.Packfile == nil {
+					t.Fatal("Negotiate returned nil Packfile")
+				}
+				defer func() {
+					if err := resp.Packfile.Close(); err != nil {
+						t.Error("resp.Packfile.Close():", err)
+					}
+				}()
+				got, err := readPackfile(bufio.NewReader(resp.Packfile))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(resp.Acks) == 0 {
+					t.Error("remote did not acknowledge any commits")
+				}
+
+				nCommits := 0
+				for id := range got {
+					if _, ok := h.source[id]; ok {
+						nCommits++
+					}
+				}
+				for _, id := range remoteOnly {
+					if _, ok := got[id]; !ok {
+						t.Errorf("packfile missing commit %v", id)
+					}
+				}
+				switch test.algorithm {
+				case NegotiateConsecutive:
+					if nCommits != len(remoteOnly) {
+						t.Errorf("packfile has %d commits; want %d", nCommits, len(remoteOnly))
+					}
+				case NegotiateSkipping:
+					if nCommits >= len(shared) {
+						t.Errorf("packfile has %d commits; want < %d", nCommits, len(shared))
+					}
+				}
+			})
+		}
+	})
+}
+
+// testHistory builds commit graphs in memory.
+type testHistory struct {
+	tb      testing.TB
+	source  mapCommitSource
+	objects map[githash.ObjectID]packfile.BuildObject
+	time    time.Time
+}
+
+func newTestHistory(tb testing.TB) *testHistory {
+	return &testHistory{
+		tb:      tb,
+		source:  make(mapCommitSource),
+		objects: make(map[githash.ObjectID]packfile.BuildObject),
+		time:    time.Date(2020, time.January, 9, 14, 50, 0, 0, time.UTC),
+	}
+}
+
+// chain adds a linear sequence of n commits whose first commit has the given
+// parents. Each subsequent call produces newer commits.
+func (h *testHistory) chain(parents []githash.ObjectID, name string, n int, step time.Duration) []githash.ObjectID {
+	const author object.User = "Octocat <octocat@example.com>"
+	ids := make([]githash.ObjectID, 0, n)
+	for i := 0; i < n; i++ {
+		content := []byte(fmt.Sprintf("%s %d\n", name, i))
+		blob := h.add(packfile.Blob, content)
+		tree := h.add(packfile.Tree, mustMarshalBinary(h.tb, object.Tree{
+			{Name: "file.txt", Mode: object.ModePlain, ObjectID: blob},
+		}))
+		h.time = h.time.Add(step)
+		c := &object.Commit{
+			Tree:       tree,
+			Parents:    parents,
+			Author:     author,
+			AuthorTime: h.time,
+			Committer:  author,
+			CommitTime: h.time,
+			Message:    fmt.Sprintf("%s %d", name, i),
+		}
+		id := h.add(packfile.Commit, mustMarshalBinary(h.tb, c))
+		h.source[id] = c
+		ids = append(ids, id)
+		parents = []githash.ObjectID{id}
+	}
+	return ids
+}
+
+func (h *testHistory) add(typ packfile.ObjectType, data []byte) githash.ObjectID {
+	var objType object.Type
+	switch typ {
+	case packfile.Blob:
+		objType = object.TypeBlob
+	case packfile.Tree:
+		objType = object.TypeTree
+	case packfile.Commit:
+		objType = object.TypeCommit
+	}
+	hash := githash.SHA1Format.New()
+	hash.Write(object.AppendPrefix(nil, objType, int64(len(data))))
+	hash.Write(data)
+	id := githash.SHA1Format.Sum(hash)
+	h.objects[id] = packfile.BuildObject{Type: typ, Data: data}
+	return id
+}
+
+// writeRepository writes a packfile containing the history of tip
+// into the bare repository at dir.
+func (h *testHistory) writeRepository(dir string, tip githash.ObjectID) error {
+	var objects []packfile.BuildObject
+	for stack := []githash.ObjectID{tip}; len(stack) > 0; {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		c := h.source[id]
+		tree, err := object.ParseTree(h.objects[c.Tree].Data)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, h.objects[id], h.objects[c.Tree], h.objects[tree[0].ObjectID])
+		stack = append(stack, c.Parents...)
+	}
+	buf := new(bytes.Buffer)
+	if err := packfile.Build(buf, objects, nil); err != nil {
+		return err
+	}
+	idx, err := packfile.BuildIndex(bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil)
+	if err != nil {
+		return err
+	}
+	idxData, err := idx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	base := filepath.Join(dir, "objects", "pack", "pack-"+idx.PackfileSHA1.String())
+	if err := os.WriteFile(base+".pack", buf.Bytes(), 0o444); err != nil {
+		return err
+	}
+	return os.WriteFile(base+".idx", idxData, 0o444)
+}
+
+// mapCommitSource is a CommitSource backed by a map.
+type mapCommitSource map[githash.ObjectID]*object.Commit
+
+func (src mapCommitSource) ReadCommit(id githash.ObjectID) (*object.Commit, error) {
+	c := src[id]
+	if c == nil {
+		return nil, fmt.Errorf("commit %v not found", id)
+	}
+	return c, nil
+}
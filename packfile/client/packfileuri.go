@@ -0,0 +1,105 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+
+	"gg-scm.io/pkg/git/githash"
+)
+
+// A PackfileURI is a pre-generated packfile that the remote
+// asked the client to download separately from the negotiated packfile.
+type PackfileURI struct {
+	// Hash is the checksum at the end of the packfile.
+	Hash githash.ObjectID
+	// URI is the location of the packfile.
+	URI string
+}
+
+// Download fetches the packfile over HTTP or HTTPS using the given client
+// and copies it to dst. If client is nil, http.DefaultClient is used.
+// Download returns an error if the packfile's checksum does not match
+// u.Hash. Since the packfile is written to dst as it is received,
+// the caller should discard anything written to dst if Download
+// returns an error.
+func (u *PackfileURI) Download(ctx context.Context, client *http.Client, dst io.Writer) error {
+	parsed, err := url.Parse(u.URI)
+	if err != nil {
+		return fmt.Errorf("download packfile %v: %w", u.Hash, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("download packfile %v from %s: unsupported scheme %q", u.Hash, u.URI, parsed.Scheme)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return fmt.Errorf("download packfile %v from %s: %w", u.Hash, u.URI, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("download packfile %v from %s: %w", u.Hash, u.URI, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download packfile %v from %s: http %s", u.Hash, u.URI, resp.Status)
+	}
+	format := u.Hash.ObjectFormat()
+	verifier := &trailerHashWriter{
+		h:    format.New(),
+		size: format.Size(),
+	}
+	if _, err := io.Copy(io.MultiWriter(dst, verifier), resp.Body); err != nil {
+		return fmt.Errorf("download packfile %v from %s: %w", u.Hash, u.URI, err)
+	}
+	if len(verifier.tail) < verifier.size {
+		return fmt.Errorf("download packfile %v from %s: %w", u.Hash, u.URI, io.ErrUnexpectedEOF)
+	}
+	got := format.Sum(verifier.h)
+	if !bytes.Equal(got.Bytes(), verifier.tail) {
+		return fmt.Errorf("download packfile %v from %s: corrupt packfile (checksum does not match contents)", u.Hash, u.URI)
+	}
+	if got != u.Hash {
+		return fmt.Errorf("download packfile %v from %s: received packfile %v", u.Hash, u.URI, got)
+	}
+	return nil
+}
+
+// trailerHashWriter hashes everything written to it
+// except for the last size bytes, which it retains in tail.
+type trailerHashWriter struct {
+	h    hash.Hash
+	size int
+	tail []byte
+}
+
+func (w *trailerHashWriter) Write(p []byte) (int, error) {
+	w.tail = append(w.tail, p...)
+	if n := len(w.tail) - w.size; n > 0 {
+		w.h.Write(w.tail[:n])
+		w.tail = append(w.tail[:0], w.tail[n:]...)
+	}
+	return len(p), nil
+}
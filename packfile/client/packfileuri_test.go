@@ -0,0 +1,236 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPackfileURIs(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	objects, err := initPullTestRepository(ctx, g, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Put the blob in its own packfile and configure the remote
+	// to send its URI instead of including the blob.
+	packDir := t.TempDir()
+	packObjects := exec.Command(localGit.Exe(), "pack-objects", filepath.Join(packDir, "pack"))
+	packObjects.Dir = dir
+	packObjects.Stdin = strings.NewReader(objects.blobObjectID().String() + "\n")
+	out, err := packObjects.Output()
+	if err != nil {
+		t.Fatal("git pack-objects:", err)
+	}
+	packHash, err := githash.ParseObjectID(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packData, err := os.ReadFile(filepath.Join(packDir, "pack-"+packHash.String()+".pack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(packData)
+	}))
+	t.Cleanup(packServer.Close)
+	packURI := packServer.URL + "/pack-" + packHash.String() + ".pack"
+	// git-upload-pack only reads uploadpack.blobPackfileUri from protected
+	// configuration, so set it in the environment instead of the repository.
+	// Only the local transport passes the environment through to Git.
+	// Git only sends packfile URIs when the response uses sideband-all.
+	t.Setenv("GIT_CONFIG_COUNT", "2")
+	t.Setenv("GIT_CONFIG_KEY_0", "uploadpack.blobPackfileUri")
+	t.Setenv("GIT_CONFIG_VALUE_0", objects.blobObjectID().String()+" "+packHash.String()+" "+packURI)
+	t.Setenv("GIT_CONFIG_KEY_1", "uploadpack.allowSidebandAll")
+	t.Setenv("GIT_CONFIG_VALUE_1", "true")
+
+	for version := 1; version <= 2; version++ {
+		t.Run(fmt.Sprintf("Version%d", version), func(t *testing.T) {
+			remote, err := NewRemote(URLFromPath(dir), nil)
+			if err != nil {
+				t.Fatal("NewRemote:", err)
+			}
+			if version == 1 {
+				remote.pullExtraParams = v1ExtraParams
+			}
+			stream, err := remote.StartPull(ctx)
+			if err != nil {
+				t.Fatal("remote.StartPull:", err)
+			}
+			defer func() {
+				if err := stream.Close(); err != nil {
+					t.Error("stream.Close():", err)
+				}
+			}()
+
+			req := &PullRequest{
+				Want:                 []githash.ObjectID{objects.commit1.SHA1()},
+				PackfileURIProtocols: []string{"http", "https"},
+			}
+			if version == 1 {
+				if stream.Capabilities().Has(PullCapPackfileURIs) {
+					t.Error("Capabilities() includes PullCapPackfileURIs on version 1")
+				}
+				if resp, err := stream.Negotiate(req); err == nil {
+					resp.Packfile.Close()
+					t.Error("Negotiate with PackfileURIProtocols did not return an error on version 1")
+				}
+				return
+			}
+			if !stream.Capabilities().Has(PullCapPackfileURIs) {
+				t.Fatalf("Capabilities() = %v; want to include PullCapPackfileURIs", stream.Capabilities())
+			}
+			resp, err := stream.Negotiate(req)
+			if err != nil {
+				t.Fatal("stream.Negotiate:", err)
+			}
+			defer func() {
+				if err := resp.Packfile.Close(); err != nil {
+					t.Error("resp.Packfile.Close():", err)
+				}
+			}()
+			wantURIs := []*PackfileURI{{Hash: packHash, URI: packURI}}
+			if diff := cmp.Diff(wantURIs, resp.PackfileURIs); diff != "" {
+				t.Errorf("resp.PackfileURIs (-want +got):\n%s", diff)
+			}
+			got, err := readPackfile(bufio.NewReader(resp.Packfile))
+			if err != nil {
+				t.Error(err)
+			}
+			want := map[githash.ObjectID][]byte{
+				objects.tree1.SHA1():   mustMarshalBinary(t, objects.tree1),
+				objects.commit1.SHA1(): mustMarshalBinary(t, objects.commit1),
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("inline packfile objects (-want +got):\n%s", diff)
+			}
+
+			if len(resp.PackfileURIs) == 0 {
+				return
+			}
+			buf := new(bytes.Buffer)
+			if err := resp.PackfileURIs[0].Download(ctx, packServer.Client(), buf); err != nil {
+				t.Fatal("Download:", err)
+			}
+			got, err = readPackfile(bufio.NewReader(buf))
+			if err != nil {
+				t.Error(err)
+			}
+			want = map[githash.ObjectID][]byte{
+				objects.blobObjectID(): objects.blobContent,
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("downloaded packfile objects (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPackfileURIDownload(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	objects, err := initPullTestRepository(ctx, g, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packObjects := exec.Command(localGit.Exe(), "pack-objects", "--stdout")
+	packObjects.Dir = dir
+	packObjects.Stdin = strings.NewReader(objects.blobObjectID().String() + "\n")
+	packData, err := packObjects.Output()
+	if err != nil {
+		t.Fatal("git pack-objects:", err)
+	}
+	h := githash.SHA1Format.New()
+	h.Write(packData[:len(packData)-githash.SHA1Size])
+	packHash := githash.SHA1Format.Sum(h)
+	corruptData := append([]byte(nil), packData...)
+	corruptData[len(corruptData)/2] ^= 0xff
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/good.pack", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(packData)
+	})
+	mux.HandleFunc("/corrupt.pack", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(corruptData)
+	})
+	mux.HandleFunc("/short.pack", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(packData[:4])
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name    string
+		hash    githash.ObjectID
+		uri     string
+		wantErr bool
+	}{
+		{name: "Good", hash: packHash, uri: srv.URL + "/good.pack"},
+		{name: "Corrupt", hash: packHash, uri: srv.URL + "/corrupt.pack", wantErr: true},
+		{name: "Short", hash: packHash, uri: srv.URL + "/short.pack", wantErr: true},
+		{name: "WrongHash", hash: objects.blobObjectID(), uri: srv.URL + "/good.pack", wantErr: true},
+		{name: "NotFound", hash: packHash, uri: srv.URL + "/missing.pack", wantErr: true},
+		{name: "UnsupportedScheme", hash: packHash, uri: "ftp://example.com/good.pack", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u := &PackfileURI{Hash: test.hash, URI: test.uri}
+			buf := new(bytes.Buffer)
+			err := u.Download(ctx, srv.Client(), buf)
+			if err != nil {
+				t.Log("Download:", err)
+				if !test.wantErr {
+					t.Fail()
+				}
+				return
+			}
+			if test.wantErr {
+				t.Fatal("Download did not return an error")
+			}
+			if !bytes.Equal(buf.Bytes(), packData) {
+				t.Error("Download wrote different data than served")
+			}
+		})
+	}
+}
@@ -39,7 +39,10 @@ type PullStream struct {
 type puller interface {
 	negotiate(ctx context.Context, errPrefix string, req *PullRequest) (*PullResponse, error)
 	listRefs(ctx context.Context, refPrefixes []string) (map[githash.Ref]*Ref, error)
+	objectSizes(ctx context.Context, ids []githash.ObjectID) (map[githash.ObjectID]int64, error)
+	bundleURIs(ctx context.Context) (*BundleList, error)
 	capabilities() PullCapabilities
+	objectFormat() githash.ObjectFormat
 	Close() error
 }
 
@@ -76,9 +79,14 @@ func (r *Remote) StartPull(ctx context.Context) (_ *PullStream, err error) {
 		if err != nil {
 			return nil, fmt.Errorf("pull %s: %w", r.urlstr, err)
 		}
+		format, err := caps.objectFormat()
+		if err != nil {
+			return nil, fmt.Errorf("pull %s: %w", r.urlstr, err)
+		}
 		p.impl = &pullV2{
-			caps: caps,
-			impl: r.impl,
+			caps:   caps,
+			format: format,
+			impl:   r.impl,
 		}
 	} else {
 		p.impl = newPullV1(r.impl, respReader, resp)
@@ -88,9 +96,16 @@ func (r *Remote) StartPull(ctx context.Context) (_ *PullStream, err error) {
 
 // Ref describes a single reference to a Git object.
 type Ref struct {
-	ObjectID     githash.SHA1
+	// ObjectID is the object the ref points to. It is the zero value
+	// if the ref is a symbolic ref to a branch that does not exist yet,
+	// like HEAD in an empty repository.
+	ObjectID     githash.ObjectID
 	Name         githash.Ref
 	SymrefTarget githash.Ref
+	// PeeledObjectID is the object that an annotated tag points to
+	// after following any chain of tags. It is the zero value if ObjectID
+	// is not an annotated tag or the remote did not send peeled values.
+	PeeledObjectID githash.ObjectID
 }
 
 // ListRefs lists the remote's references. If refPrefixes is given, then only
@@ -106,21 +121,77 @@ func (p *PullStream) ListRefs(refPrefixes ...string) (map[githash.Ref]*Ref, erro
 	return refs, nil
 }
 
+// ObjectSizes returns the sizes of the given objects in bytes
+// without downloading their contents, using the protocol version 2
+// object-info command. Objects that the remote does not have
+// are not included in the returned map. This is only supported
+// by the remote if it has PullCapObjectInfo.
+func (p *PullStream) ObjectSizes(ids ...githash.ObjectID) (map[githash.ObjectID]int64, error) {
+	sizes, err := p.impl.objectSizes(p.ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("object info for %s: %w", p.urlstr, err)
+	}
+	return sizes, nil
+}
+
+// A BundleList is a set of bundles advertised by a remote that a client
+// can download to bootstrap a clone before pulling the remaining objects.
+type BundleList struct {
+	Version int
+	// Mode is "all" if the client should download every bundle
+	// or "any" if any one of the bundles is sufficient.
+	Mode string
+	// Heuristic is the strategy the remote suggests for ordering downloads,
+	// like "creationToken". It is empty if the remote did not send one.
+	Heuristic string
+	// Bundles is the list of bundles in the order the remote listed them.
+	Bundles []*Bundle
+}
+
+// A Bundle describes a single bundle file in a BundleList.
+type Bundle struct {
+	ID string
+	// URI is the location of the bundle as sent by the remote.
+	// It may be relative to the remote's URL.
+	URI string
+	// CreationToken orders bundles when the list's Heuristic is
+	// "creationToken". It is zero if the remote did not send one.
+	CreationToken uint64
+}
+
+// BundleURIs returns the bundles that the remote advertises
+// using the protocol version 2 bundle-uri command. This is only supported
+// by the remote if it has PullCapBundleURI.
+func (p *PullStream) BundleURIs() (*BundleList, error) {
+	list, err := p.impl.bundleURIs(p.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("bundle uris for %s: %w", p.urlstr, err)
+	}
+	return list, nil
+}
+
 // Capabilities returns the set of pull request fields the remote supports.
 func (p *PullStream) Capabilities() PullCapabilities {
 	return p.impl.capabilities()
 }
 
+// ObjectFormat returns the object format of the remote repository.
+// Object IDs in requests must use this format,
+// and packfiles returned by the remote use this format.
+func (p *PullStream) ObjectFormat() githash.ObjectFormat {
+	return p.impl.objectFormat()
+}
+
 // A PullRequest informs the remote which objects to include in the packfile.
 type PullRequest struct {
 	// Want is the set of commits to send. At least one must be specified,
 	// or SendRequest will return an error.
-	Want []githash.SHA1
+	Want []githash.ObjectID
 	// Have is a set of commits that the remote can exclude from the packfile.
 	// It may be empty for a full clone. The remote will also avoid sending any
 	// trees and blobs used in the Have commits and any of their ancestors, even
 	// if they're used in returned commits.
-	Have []githash.SHA1
+	Have []githash.ObjectID
 	// If HaveMore is true, then the response will not return a packfile if the
 	// remote hasn't found a suitable base.
 	HaveMore bool
@@ -131,7 +202,7 @@ type PullRequest struct {
 
 	// Shallow is the set of object IDs that the client does not have the parent
 	// commits of. This is only supported by the remote if it has PullCapShallow.
-	Shallow []githash.SHA1
+	Shallow []githash.ObjectID
 
 	// If Depth is greater than zero, it limits the depth of the commits pulled.
 	// It is mutually exclusive with Since. This is only supported by the remote
@@ -168,6 +239,13 @@ type PullRequest struct {
 	// these packs by adding the missing bases to the pack. This is only supported
 	// by the remote if it has PullCapThinPack.
 	ThinPack bool
+
+	// PackfileURIProtocols is the set of URI schemes (like "https") that
+	// the client is willing to download packfiles from. If it is not empty,
+	// the remote may omit some objects from the Packfile stream and instead
+	// list pre-generated packfiles in PullResponse.PackfileURIs.
+	// This is only supported by the remote if it has PullCapPackfileURIs.
+	PackfileURIProtocols []string
 }
 
 func (req *PullRequest) needsShallow() bool {
@@ -188,12 +266,20 @@ type PullResponse struct {
 	Packfile io.ReadCloser
 	// Acks indicates which of the Have objects from the request that the remote
 	// shares. It may not be populated if Packfile is not nil.
-	Acks map[githash.SHA1]struct{}
+	Acks map[githash.ObjectID]struct{}
+	// Ready is true if the remote has found enough common commits to send
+	// a packfile. If the request set HaveMore and Packfile is nil, then
+	// the client should make a final request without HaveMore.
+	Ready bool
 	// Shallow indicates each commit sent whose parents will not be in the
 	// packfile. If a commit hash is in the Shallow map but its value is false,
 	// it means that the request indicated the commit was shallow, but its parents
 	// are present in the packfile.
-	Shallow map[githash.SHA1]bool
+	Shallow map[githash.ObjectID]bool
+	// PackfileURIs is the list of packfiles that the client must download
+	// in addition to reading Packfile to receive all of the requested objects.
+	// It is only populated if the request set PackfileURIProtocols.
+	PackfileURIs []*PackfileURI
 }
 
 // Negotiate requests a packfile from the remote. It must be called before
@@ -210,6 +296,14 @@ func (p *PullStream) Negotiate(req *PullRequest) (*PullResponse, error) {
 	if req.Depth > 0 && len(req.ShallowExclude) > 0 {
 		return nil, fmt.Errorf("%s: Depth used with ShallowExclude", errPrefix)
 	}
+	format := p.impl.objectFormat()
+	for _, ids := range [][]githash.ObjectID{req.Want, req.Have, req.Shallow} {
+		for _, id := range ids {
+			if id.ObjectFormat() != format {
+				return nil, fmt.Errorf("%s: %v is not a %v object ID", errPrefix, id, format)
+			}
+		}
+	}
 
 	// Validate that request uses capabilities that the remote supports.
 	caps := p.impl.capabilities()
@@ -219,7 +313,7 @@ func (p *PullStream) Negotiate(req *PullRequest) (*PullResponse, error) {
 	if req.needsDepthRelative() && !caps.Has(PullCapDepthRelative) {
 		return nil, fmt.Errorf("%s: remote does not support relative depths", errPrefix)
 	}
-	if req.Since.IsZero() && !caps.Has(PullCapSince) {
+	if !req.Since.IsZero() && !caps.Has(PullCapSince) {
 		return nil, fmt.Errorf("%s: remote does not support shallow-since", errPrefix)
 	}
 	if len(req.ShallowExclude) > 0 && !caps.Has(PullCapShallowExclude) {
@@ -234,6 +328,14 @@ func (p *PullStream) Negotiate(req *PullRequest) (*PullResponse, error) {
 	if req.ThinPack && !caps.Has(PullCapThinPack) {
 		return nil, fmt.Errorf("%s: remote does not support thin packs", errPrefix)
 	}
+	if len(req.PackfileURIProtocols) > 0 && !caps.Has(PullCapPackfileURIs) {
+		return nil, fmt.Errorf("%s: remote does not support packfile URIs", errPrefix)
+	}
+	for _, proto := range req.PackfileURIProtocols {
+		if proto == "" || strings.ContainsAny(proto, ", \n") {
+			return nil, fmt.Errorf("%s: invalid packfile URI protocol %q", errPrefix, proto)
+		}
+	}
 
 	// Call negotiate.
 	resp, err := p.impl.negotiate(p.ctx, errPrefix, req)
@@ -336,6 +438,9 @@ const (
 	PullCapFilter                                      // filter
 	PullCapIncludeTag                                  // include-tag
 	PullCapThinPack                                    // thin-pack
+	PullCapObjectInfo                                  // object-info
+	PullCapPackfileURIs                                // packfile-uris
+	PullCapBundleURI                                   // bundle-uri
 
 	maxPullCapBit
 )
@@ -371,6 +476,12 @@ func (caps PullCapabilities) String() string {
 			sb.WriteString("PullCapIncludeTag")
 		case PullCapThinPack:
 			sb.WriteString("PullCapThinPack")
+		case PullCapObjectInfo:
+			sb.WriteString("PullCapObjectInfo")
+		case PullCapPackfileURIs:
+			sb.WriteString("PullCapPackfileURIs")
+		case PullCapBundleURI:
+			sb.WriteString("PullCapBundleURI")
 		}
 		caps &^= bit
 	}
@@ -392,6 +503,19 @@ func (caps capabilityList) supports(key string) bool {
 	return ok
 }
 
+// hasFeature reports whether the value of the protocol version 2
+// capability key includes the given feature.
+// Version 2 capabilities list their features separated by spaces,
+// like "fetch=shallow filter".
+func (caps capabilityList) hasFeature(key, feature string) bool {
+	for _, f := range strings.Fields(caps[key]) {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
 // symrefs parses the symrefCap value. It's the only repeated capability
 // permitted, so it's stored as a space-separated string.
 func (caps capabilityList) symrefs() map[githash.Ref]githash.Ref {
@@ -424,6 +548,20 @@ func (caps capabilityList) addSymref(elem string) {
 	caps[symrefCap] = v
 }
 
+// objectFormat returns the object format from the objectFormatCap value.
+// Remotes that do not advertise an object format use SHA-1.
+func (caps capabilityList) objectFormat() (githash.ObjectFormat, error) {
+	v, ok := caps[objectFormatCap]
+	if !ok {
+		return githash.SHA1Format, nil
+	}
+	format, err := githash.ParseObjectFormat(v)
+	if err != nil {
+		return 0, fmt.Errorf("capabilities: %w", err)
+	}
+	return format, nil
+}
+
 func (caps capabilityList) intersect(toIntersect capabilityList) {
 	for c := range caps {
 		if !toIntersect.supports(c) {
@@ -492,9 +630,22 @@ func (emptyPuller) listRefs(ctx context.Context, refPrefixes []string) (map[gith
 	return nil, nil
 }
 
+func (emptyPuller) objectSizes(ctx context.Context, ids []githash.ObjectID) (map[githash.ObjectID]int64, error) {
+	return nil, fmt.Errorf("unsupported by server")
+}
+
+func (emptyPuller) bundleURIs(ctx context.Context) (*BundleList, error) {
+	return nil, fmt.Errorf("unsupported by server")
+}
+
 func (emptyPuller) capabilities() PullCapabilities {
 	return 0
 }
+
+func (emptyPuller) objectFormat() githash.ObjectFormat {
+	return githash.SHA1Format
+}
+
 func (emptyPuller) Close() error {
 	return nil
 }
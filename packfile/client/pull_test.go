@@ -20,7 +20,6 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"crypto/sha1"
 	"encoding"
 	"errors"
 	"fmt"
@@ -31,12 +30,15 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"gg-scm.io/pkg/git"
 	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/internal/pktline"
 	"gg-scm.io/pkg/git/object"
 	"gg-scm.io/pkg/git/packfile"
 	"github.com/google/go-cmp/cmp"
@@ -55,8 +57,8 @@ func TestPull(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	forEachTransportVersion(t, localGit.Exe(), dir, func(t *testing.T, u *url.URL, version int) {
-		remote, err := NewRemote(u, nil)
+	forEachTransportVersion(t, localGit.Exe(), dir, func(t *testing.T, u *url.URL, opts *Options, version int) {
+		remote, err := NewRemote(u, opts)
 		if err != nil {
 			t.Fatal("NewRemote:", err)
 		}
@@ -104,7 +106,7 @@ func TestPull(t *testing.T) {
 
 		t.Run("Negotiate/All", func(t *testing.T) {
 			resp, err := stream.Negotiate(&PullRequest{
-				Want: []githash.SHA1{objects.commit2.SHA1()},
+				Want: []githash.ObjectID{objects.commit2.SHA1()},
 			})
 			if err != nil {
 				t.Fatal("stream.Negotiate:", err)
@@ -121,7 +123,7 @@ func TestPull(t *testing.T) {
 			if err != nil {
 				t.Error(err)
 			}
-			want := map[githash.SHA1][]byte{
+			want := map[githash.ObjectID][]byte{
 				objects.blobObjectID(): objects.blobContent,
 				objects.tree1.SHA1():   mustMarshalBinary(t, objects.tree1),
 				objects.commit1.SHA1(): mustMarshalBinary(t, objects.commit1),
@@ -135,7 +137,7 @@ func TestPull(t *testing.T) {
 
 		t.Run("Negotiate/First", func(t *testing.T) {
 			resp, err := stream.Negotiate(&PullRequest{
-				Want: []githash.SHA1{objects.commit1.SHA1()},
+				Want: []githash.ObjectID{objects.commit1.SHA1()},
 			})
 			if err != nil {
 				t.Fatal("stream.Negotiate:", err)
@@ -152,7 +154,7 @@ func TestPull(t *testing.T) {
 			if err != nil {
 				t.Error(err)
 			}
-			want := map[githash.SHA1][]byte{
+			want := map[githash.ObjectID][]byte{
 				objects.blobObjectID(): objects.blobContent,
 				objects.tree1.SHA1():   mustMarshalBinary(t, objects.tree1),
 				objects.commit1.SHA1(): mustMarshalBinary(t, objects.commit1),
@@ -164,8 +166,8 @@ func TestPull(t *testing.T) {
 
 		t.Run("Negotiate/Incremental", func(t *testing.T) {
 			resp, err := stream.Negotiate(&PullRequest{
-				Want: []githash.SHA1{objects.commit2.SHA1()},
-				Have: []githash.SHA1{objects.commit1.SHA1()},
+				Want: []githash.ObjectID{objects.commit2.SHA1()},
+				Have: []githash.ObjectID{objects.commit1.SHA1()},
 			})
 			if err != nil {
 				t.Fatal("stream.Negotiate:", err)
@@ -182,7 +184,7 @@ func TestPull(t *testing.T) {
 			if err != nil {
 				t.Error(err)
 			}
-			want := map[githash.SHA1][]byte{
+			want := map[githash.ObjectID][]byte{
 				objects.tree2.SHA1():   mustMarshalBinary(t, objects.tree2),
 				objects.commit2.SHA1(): mustMarshalBinary(t, objects.commit2),
 			}
@@ -193,7 +195,7 @@ func TestPull(t *testing.T) {
 
 		t.Run("Negotiate/ShallowSecond", func(t *testing.T) {
 			resp, err := stream.Negotiate(&PullRequest{
-				Want:  []githash.SHA1{objects.commit2.SHA1()},
+				Want:  []githash.ObjectID{objects.commit2.SHA1()},
 				Depth: 1,
 			})
 			if err != nil {
@@ -211,7 +213,7 @@ func TestPull(t *testing.T) {
 			if err != nil {
 				t.Error(err)
 			}
-			want := map[githash.SHA1][]byte{
+			want := map[githash.ObjectID][]byte{
 				objects.blobObjectID(): objects.blobContent,
 				objects.tree2.SHA1():   mustMarshalBinary(t, objects.tree2),
 				objects.commit2.SHA1(): mustMarshalBinary(t, objects.commit2),
@@ -222,13 +224,13 @@ func TestPull(t *testing.T) {
 		})
 
 		t.Run("Negotiate/HaveMore", func(t *testing.T) {
-			randomHash, err := githash.ParseSHA1("ccfe3cfa687f0ea735937a81454d21fef86cdce8")
+			randomHash, err := githash.ParseObjectID("ccfe3cfa687f0ea735937a81454d21fef86cdce8")
 			if err != nil {
 				t.Fatal(err)
 			}
 			resp, err := stream.Negotiate(&PullRequest{
-				Want:     []githash.SHA1{objects.commit2.SHA1()},
-				Have:     []githash.SHA1{randomHash},
+				Want:     []githash.ObjectID{objects.commit2.SHA1()},
+				Have:     []githash.ObjectID{randomHash},
 				HaveMore: true,
 			})
 			if err != nil {
@@ -256,9 +258,13 @@ func TestPullEmpty(t *testing.T) {
 	if err := g.InitBare(ctx, "."); err != nil {
 		t.Fatal(err)
 	}
+	mainRef := githash.BranchRef("main")
+	if err := g.Run(ctx, "symbolic-ref", "HEAD", mainRef.String()); err != nil {
+		t.Fatal(err)
+	}
 
-	forEachTransportVersion(t, localGit.Exe(), dir, func(t *testing.T, u *url.URL, version int) {
-		remote, err := NewRemote(u, nil)
+	forEachTransportVersion(t, localGit.Exe(), dir, func(t *testing.T, u *url.URL, opts *Options, version int) {
+		remote, err := NewRemote(u, opts)
 		if err != nil {
 			t.Fatal("NewRemote:", err)
 		}
@@ -277,13 +283,347 @@ func TestPullEmpty(t *testing.T) {
 
 		t.Run("ListRefs", func(t *testing.T) {
 			got, err := stream.ListRefs()
-			if len(got) > 0 || err != nil {
-				t.Errorf("ListRefs() = %v, %v; want {}, <nil>", got, err)
+			if err != nil {
+				t.Fatal("ListRefs:", err)
+			}
+			want := map[githash.Ref]*Ref{}
+			if version == 2 {
+				// Protocol version 2 reports the target of an unborn HEAD.
+				want[githash.Head] = &Ref{
+					Name:         githash.Head,
+					SymrefTarget: mainRef,
+				}
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("ListRefs() (-want +got):\n%s", diff)
+			}
+		})
+	})
+}
+
+func TestListRefsPeeled(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	objects, err := initPullTestRepository(ctx, g, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagTime := time.Date(2020, time.January, 9, 15, 0, 0, 0, time.UTC)
+	tagID, err := g.MakeTag(ctx, &object.Tag{
+		ObjectID:   objects.commit1.SHA1(),
+		ObjectType: object.TypeCommit,
+		Name:       "v1.0.0",
+		Tagger:     "Octocat <octocat@example.com>",
+		Time:       tagTime,
+		Message:    "Release 1.0.0\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagRef := githash.TagRef("v1.0.0")
+	if err := g.Run(ctx, "update-ref", tagRef.String(), tagID.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	forEachTransportVersion(t, localGit.Exe(), dir, func(t *testing.T, u *url.URL, opts *Options, version int) {
+		remote, err := NewRemote(u, opts)
+		if err != nil {
+			t.Fatal("NewRemote:", err)
+		}
+		if version == 1 {
+			remote.pullExtraParams = v1ExtraParams
+		}
+		stream, err := remote.StartPull(ctx)
+		if err != nil {
+			t.Fatal("remote.StartPull:", err)
+		}
+		defer func() {
+			if err := stream.Close(); err != nil {
+				t.Error("stream.Close():", err)
+			}
+		}()
+
+		got, err := stream.ListRefs("refs/tags/")
+		if err != nil {
+			t.Fatal("ListRefs:", err)
+		}
+		want := map[githash.Ref]*Ref{
+			objects.ref1: {
+				Name:     objects.ref1,
+				ObjectID: objects.commit1.SHA1(),
+			},
+			objects.ref2: {
+				Name:     objects.ref2,
+				ObjectID: objects.commit2.SHA1(),
+			},
+			tagRef: {
+				Name:           tagRef,
+				ObjectID:       tagID,
+				PeeledObjectID: objects.commit1.SHA1(),
+			},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("ListRefs(\"refs/tags/\") (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestPullSHA256(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	if err := g.Run(ctx, "init", "--quiet", "--object-format=sha256", "."); err != nil {
+		t.Skip("Git does not support SHA-256 repositories:", err)
+	}
+	const fname = "foo.txt"
+	const fileContent = "Hello, World!\n"
+	if err := os.WriteFile(filepath.Join(dir, fname), []byte(fileContent), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Add(ctx, []git.Pathspec{git.LiteralPath(fname)}, git.AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	const author object.User = "Octocat <octocat@example.com>"
+	commitTime := time.Date(2020, time.January, 9, 14, 50, 0, 0, time.FixedZone("-0800", -8*60*60))
+	err = g.Commit(ctx, "Initial import", git.CommitOptions{
+		Author:     author,
+		AuthorTime: commitTime,
+		Committer:  author,
+		CommitTime: commitTime,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainRef, err := g.HeadRef(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobID, err := object.BlobSumFormat(githash.SHA256Format, strings.NewReader(fileContent), int64(len(fileContent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := object.Tree{{Name: fname, Mode: object.ModePlain, ObjectID: blobID}}
+	commit := &object.Commit{
+		Tree:       tree.Sum(githash.SHA256Format),
+		Author:     author,
+		AuthorTime: commitTime,
+		Committer:  author,
+		CommitTime: commitTime,
+		Message:    "Initial import",
+	}
+	commitID := commit.Sum(githash.SHA256Format)
+
+	forEachTransportVersion(t, localGit.Exe(), dir, func(t *testing.T, u *url.URL, opts *Options, version int) {
+		remote, err := NewRemote(u, opts)
+		if err != nil {
+			t.Fatal("NewRemote:", err)
+		}
+		if version == 1 {
+			remote.pullExtraParams = v1ExtraParams
+		}
+		stream, err := remote.StartPull(ctx)
+		if err != nil {
+			t.Fatal("remote.StartPull:", err)
+		}
+		defer func() {
+			if err := stream.Close(); err != nil {
+				t.Error("stream.Close():", err)
 			}
+		}()
+		if got := stream.ObjectFormat(); got != githash.SHA256Format {
+			t.Errorf("stream.ObjectFormat() = %v; want %v", got, githash.SHA256Format)
+		}
+
+		refs, err := stream.ListRefs()
+		if err != nil {
+			t.Fatal("ListRefs:", err)
+		}
+		if got := refs[mainRef]; got == nil || got.ObjectID != commitID {
+			t.Errorf("ListRefs()[%q] = %+v; want object %v", mainRef, got, commitID)
+		}
+
+		resp, err := stream.Negotiate(&PullRequest{
+			Want: []githash.ObjectID{commitID},
 		})
+		if err != nil {
+			t.Fatal("stream.Negotiate:", err)
+		}
+		if resp.Packfile == nil {
+			t.Fatal("stream.Negotiate returned nil Packfile")
+		}
+		defer func() {
+			if err := resp.Packfile.Close(); err != nil {
+				t.Error("resp.Packfile.Close():", err)
+			}
+		}()
+		got, err := readPackfileFormat(bufio.NewReader(resp.Packfile), githash.SHA256Format)
+		if err != nil {
+			t.Error(err)
+		}
+		want := map[githash.ObjectID][]byte{
+			blobID:                         []byte(fileContent),
+			tree.Sum(githash.SHA256Format): mustMarshalBinary(t, tree),
+			commitID:                       mustMarshalBinary(t, commit),
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("objects (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestObjectSizes(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	objects, err := initPullTestRepository(ctx, g, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Run(ctx, "config", "transfer.advertiseObjectInfo", "true"); err != nil {
+		t.Fatal(err)
+	}
+	blobID, err := object.BlobSum(bytes.NewReader(objects.blobContent), int64(len(objects.blobContent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitData, err := objects.commit1.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	missingID := githash.SHA1{0xde, 0xad, 0xbe, 0xef}
+
+	forEachTransportVersion(t, localGit.Exe(), dir, func(t *testing.T, u *url.URL, opts *Options, version int) {
+		remote, err := NewRemote(u, opts)
+		if err != nil {
+			t.Fatal("NewRemote:", err)
+		}
+		if version == 1 {
+			remote.pullExtraParams = v1ExtraParams
+		}
+		stream, err := remote.StartPull(ctx)
+		if err != nil {
+			t.Fatal("remote.StartPull:", err)
+		}
+		defer func() {
+			if err := stream.Close(); err != nil {
+				t.Error("stream.Close():", err)
+			}
+		}()
+
+		if version == 1 {
+			if stream.Capabilities().Has(PullCapObjectInfo) {
+				t.Error("Capabilities() includes PullCapObjectInfo on version 1")
+			}
+			if _, err := stream.ObjectSizes(blobID); err == nil {
+				t.Error("ObjectSizes did not return an error on version 1")
+			}
+			return
+		}
+		if !stream.Capabilities().Has(PullCapObjectInfo) {
+			t.Fatalf("Capabilities() = %v; want to include PullCapObjectInfo", stream.Capabilities())
+		}
+		got, err := stream.ObjectSizes(blobID, objects.commit1.SHA1(), missingID.ObjectID())
+		if err != nil {
+			t.Fatal("ObjectSizes:", err)
+		}
+		want := map[githash.ObjectID]int64{
+			blobID:                 int64(len(objects.blobContent)),
+			objects.commit1.SHA1(): int64(len(commitData)),
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("ObjectSizes(...) (-want +got):\n%s", diff)
+		}
 	})
 }
 
+func TestReadBundleListV2(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    *BundleList
+		wantErr bool
+	}{
+		{
+			name:  "Empty",
+			lines: nil,
+			want:  &BundleList{},
+		},
+		{
+			name: "Bundles",
+			lines: []string{
+				"bundle.version=1",
+				"bundle.mode=all",
+				"bundle.heuristic=creationToken",
+				"bundle.base.uri=https://cdn.example.com/base.bundle",
+				"bundle.base.creationToken=1",
+				"bundle.recent.uri=recent.bundle",
+				"bundle.recent.creationtoken=2",
+				"bundle.recent.filter=blob:none",
+				"unknown.key=value",
+			},
+			want: &BundleList{
+				Version:   1,
+				Mode:      "all",
+				Heuristic: "creationToken",
+				Bundles: []*Bundle{
+					{ID: "base", URI: "https://cdn.example.com/base.bundle", CreationToken: 1},
+					{ID: "recent", URI: "recent.bundle", CreationToken: 2},
+				},
+			},
+		},
+		{
+			name:    "MissingURI",
+			lines:   []string{"bundle.version=1", "bundle.mode=any", "bundle.base.creationToken=1"},
+			wantErr: true,
+		},
+		{
+			name:    "NoEquals",
+			lines:   []string{"bundle.version"},
+			wantErr: true,
+		},
+		{
+			name:    "BadVersion",
+			lines:   []string{"bundle.version=x"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf []byte
+			for _, line := range test.lines {
+				buf = pktline.AppendString(buf, line+"\n")
+			}
+			buf = pktline.AppendFlush(buf)
+			got, err := readBundleListV2(pktline.NewReader(bytes.NewReader(buf)))
+			if err != nil {
+				if !test.wantErr {
+					t.Fatal("readBundleListV2:", err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Fatalf("readBundleListV2 = %+v, <nil>; want error", got)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("readBundleListV2 (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 type pullTestObjects struct {
 	mainRef     githash.Ref
 	blobContent []byte
@@ -391,7 +731,7 @@ func initPullTestRepository(ctx context.Context, g *git.Git, dir string) (*pullT
 	}
 	objects.commit2 = &object.Commit{
 		Tree:       objects.tree2.SHA1(),
-		Parents:    []githash.SHA1{objects.commit1.SHA1()},
+		Parents:    []githash.ObjectID{objects.commit1.SHA1()},
 		Author:     author,
 		AuthorTime: commitTime2,
 		Committer:  author,
@@ -401,7 +741,7 @@ func initPullTestRepository(ctx context.Context, g *git.Git, dir string) (*pullT
 	return objects, nil
 }
 
-func (objects *pullTestObjects) blobObjectID() githash.SHA1 {
+func (objects *pullTestObjects) blobObjectID() githash.ObjectID {
 	id, err := object.BlobSum(bytes.NewReader(objects.blobContent), int64(len(objects.blobContent)))
 	if err != nil {
 		panic(err)
@@ -434,9 +774,13 @@ func TestPullGitHubRepository(t *testing.T) {
 	}
 }
 
-func readPackfile(r packfile.ByteReader) (map[githash.SHA1][]byte, error) {
-	pr := packfile.NewReader(r)
-	objects := make(map[githash.SHA1][]byte)
+func readPackfile(r packfile.ByteReader) (map[githash.ObjectID][]byte, error) {
+	return readPackfileFormat(r, githash.SHA1Format)
+}
+
+func readPackfileFormat(r packfile.ByteReader, format githash.ObjectFormat) (map[githash.ObjectID][]byte, error) {
+	pr := packfile.NewReaderFormat(r, format)
+	objects := make(map[githash.ObjectID][]byte)
 	for {
 		hdr, err := pr.Next()
 		if errors.Is(err, io.EOF) {
@@ -458,15 +802,13 @@ func readPackfile(r packfile.ByteReader) (map[githash.SHA1][]byte, error) {
 		default:
 			return objects, fmt.Errorf("unsupported object type %v", hdr.Type)
 		}
-		h := sha1.New()
+		h := format.New()
 		h.Write(object.AppendPrefix(nil, objType, hdr.Size))
 		buf := new(bytes.Buffer)
 		if _, err := io.Copy(io.MultiWriter(buf, h), pr); err != nil {
 			return objects, err
 		}
-		var sum githash.SHA1
-		h.Sum(sum[:0])
-		objects[sum] = buf.Bytes()
+		objects[format.Sum(h)] = buf.Bytes()
 	}
 }
 
@@ -480,34 +822,59 @@ func mustMarshalBinary(tb testing.TB, m encoding.BinaryMarshaler) []byte {
 
 type transportVariant struct {
 	name   string
-	getURL func(tb testing.TB, dir string) *url.URL
+	getURL func(tb testing.TB, dir string) (*url.URL, *Options)
 }
 
 func allTransportVariants(gitExe string) []transportVariant {
-	return []transportVariant{
-		{"Local", func(_ testing.TB, dir string) *url.URL {
-			return URLFromPath(dir)
+	variants := []transportVariant{
+		{"Local", func(_ testing.TB, dir string) (*url.URL, *Options) {
+			return URLFromPath(dir), nil
 		}},
-		{"HTTP", func(tb testing.TB, dir string) *url.URL {
+		{"HTTP", func(tb testing.TB, dir string) (*url.URL, *Options) {
 			httpServer := serveHTTPRepository(gitExe, dir)
 			tb.Cleanup(httpServer.Close)
 			u, err := ParseURL(httpServer.URL)
 			if err != nil {
 				tb.Fatal(err)
 			}
-			return u
+			return u, nil
+		}},
+		{"SSH", func(tb testing.TB, dir string) (*url.URL, *Options) {
+			addr, config := serveSSH(tb, gitExe)
+			u := &url.URL{
+				Scheme: "ssh",
+				Host:   addr,
+				Path:   filepath.ToSlash(dir),
+			}
+			return u, &Options{SSHConfig: config}
 		}},
 	}
+	if runtime.GOOS != "windows" {
+		variants = append(variants, transportVariant{"SSHCommand", func(tb testing.TB, dir string) (*url.URL, *Options) {
+			u := &url.URL{
+				Scheme: "ssh",
+				Host:   "example.com",
+				Path:   filepath.ToSlash(dir),
+			}
+			return u, &Options{SSHCommand: fakeSSHCommand}
+		}})
+		// git-daemon(1) does not support --inetd on Windows.
+		variants = append(variants, transportVariant{"GitDaemon", func(tb testing.TB, dir string) (*url.URL, *Options) {
+			return serveGitDaemon(tb, gitExe, dir), nil
+		}})
+	}
+	return variants
 }
 
-func forEachTransportVersion(t *testing.T, gitExe, dir string, f func(t *testing.T, u *url.URL, version int)) {
+func forEachTransportVersion(t *testing.T, gitExe, dir string, f func(t *testing.T, u *url.URL, opts *Options, version int)) {
 	t.Helper()
 
 	for _, transport := range allTransportVariants(gitExe) {
 		t.Run(transport.name, func(t *testing.T) {
 			for version := 1; version <= 2; version++ {
 				t.Run(fmt.Sprintf("Version%d", version), func(t *testing.T) {
-					f(t, transport.getURL(t, dir), version)
+					u, opts := transport.getURL(t, dir)
+					f(t, u, opts, version)
 				})
 			}
 		})
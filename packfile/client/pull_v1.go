@@ -35,23 +35,26 @@ const v1ExtraParams = "version=1"
 
 // Capability names. See https://git-scm.com/docs/protocol-capabilities
 const (
-	deepenRelativeCap = "deepen-relative"
-	deepenSinceCap    = "deepen-since"
-	deepenNotCap      = "deepen-not"
-	filterCap         = "filter"
-	includeTagCap     = "include-tag"
-	multiAckCap       = "multi_ack"
-	noProgressCap     = "no-progress"
-	ofsDeltaCap       = "ofs-delta"
-	shallowCap        = "shallow"
-	sideBand64KCap    = "side-band-64k"
-	sideBandCap       = "side-band"
-	symrefCap         = "symref"
-	thinPackCap       = "thin-pack"
+	deepenRelativeCap   = "deepen-relative"
+	deepenSinceCap      = "deepen-since"
+	deepenNotCap        = "deepen-not"
+	filterCap           = "filter"
+	includeTagCap       = "include-tag"
+	multiAckCap         = "multi_ack"
+	multiAckDetailedCap = "multi_ack_detailed"
+	noProgressCap       = "no-progress"
+	objectFormatCap     = "object-format"
+	ofsDeltaCap         = "ofs-delta"
+	shallowCap          = "shallow"
+	sideBand64KCap      = "side-band-64k"
+	sideBandCap         = "side-band"
+	symrefCap           = "symref"
+	thinPackCap         = "thin-pack"
 )
 
 type pullV1 struct {
 	caps       capabilityList
+	format     githash.ObjectFormat
 	impl       impl
 	refsReader *pktline.Reader
 	refsCloser io.Closer
@@ -64,7 +67,13 @@ func newPullV1(impl impl, refsReader *pktline.Reader, refsCloser io.Closer) *pul
 	p := &pullV1{impl: impl}
 	var ref0 *Ref
 	ref0, p.caps, p.refsError = readFirstRefV1(refsReader)
-	if ref0 == nil {
+	if p.refsError == nil {
+		p.format, p.refsError = p.caps.objectFormat()
+	}
+	if ref0 != nil && p.refsError == nil && ref0.ObjectID.ObjectFormat() != p.format {
+		p.refsError = fmt.Errorf("read refs: ref %s: object ID is not %v", ref0.Name, p.format)
+	}
+	if ref0 == nil || p.refsError != nil {
 		// Either an error or only capabilities were received.
 		// No need to hang onto refsReader.
 		refsCloser.Close()
@@ -85,7 +94,7 @@ func (p *pullV1) Close() error {
 
 func (p *pullV1) listRefs(ctx context.Context, refPrefixes []string) (map[githash.Ref]*Ref, error) {
 	if p.refsReader != nil {
-		p.refsError = readOtherRefsV1(p.refs, p.caps.symrefs(), p.refsReader)
+		p.refsError = readOtherRefsV1(p.refs, p.caps.symrefs(), p.format, p.refsReader)
 		p.refsCloser.Close()
 		p.refsReader = nil
 		p.refsCloser = nil
@@ -159,7 +168,7 @@ func parseFirstRefV1(line []byte) (*Ref, capabilityList, error) {
 	if idEnd == -1 {
 		return nil, nil, fmt.Errorf("first ref: missing space")
 	}
-	id, err := githash.ParseSHA1(string(line[:idEnd]))
+	id, err := githash.ParseObjectID(string(line[:idEnd]))
 	if err != nil {
 		return nil, nil, fmt.Errorf("first ref: %w", err)
 	}
@@ -177,7 +186,7 @@ func parseFirstRefV1(line []byte) (*Ref, capabilityList, error) {
 		}
 	}
 	if refName == "capabilities^{}" {
-		if id != (githash.SHA1{}) {
+		if !id.IsZero() {
 			return nil, nil, fmt.Errorf("first ref: non-zero ID passed with no-refs response")
 		}
 		return nil, caps, nil
@@ -195,16 +204,28 @@ func parseFirstRefV1(line []byte) (*Ref, capabilityList, error) {
 // readOtherRefsV1 parses the second and subsequent refs in the version 1 refs
 // advertisement response. The caller is expected to have advanced r past the
 // first ref before calling readOtherRefsV1.
-func readOtherRefsV1(refs map[githash.Ref]*Ref, symrefs map[githash.Ref]githash.Ref, r *pktline.Reader) error {
+func readOtherRefsV1(refs map[githash.Ref]*Ref, symrefs map[githash.Ref]githash.Ref, format githash.ObjectFormat, r *pktline.Reader) error {
 	for r.Next() && r.Type() != pktline.Flush {
 		line, err := r.Text()
 		if err != nil {
 			return fmt.Errorf("read refs: %w", err)
 		}
-		ref, err := parseOtherRefV1(line)
+		ref, peeled, err := parseOtherRefV1(line)
 		if err != nil {
 			return fmt.Errorf("read refs: %w", err)
 		}
+		if ref.ObjectID.ObjectFormat() != format {
+			return fmt.Errorf("read refs: ref %s: object ID is not %v", ref.Name, format)
+		}
+		if peeled {
+			// A peeled tag follows the tag it belongs to.
+			tag := refs[ref.Name]
+			if tag == nil {
+				return fmt.Errorf("read refs: peeled value for %s sent before ref", ref.Name)
+			}
+			tag.PeeledObjectID = ref.ObjectID
+			continue
+		}
 		ref.SymrefTarget = symrefs[ref.Name]
 		refs[ref.Name] = ref
 	}
@@ -214,23 +235,45 @@ func readOtherRefsV1(refs map[githash.Ref]*Ref, symrefs map[githash.Ref]githash.
 	return nil
 }
 
-func parseOtherRefV1(line []byte) (*Ref, error) {
+// parseOtherRefV1 parses a ref line after the first in a version 1 refs
+// advertisement. If the line gives the peeled value of a tag
+// (like "<id> refs/tags/v1^{}"), then peeled is true and
+// the returned Ref has the name of the tag.
+func parseOtherRefV1(line []byte) (_ *Ref, peeled bool, _ error) {
 	idEnd := bytes.IndexByte(line, ' ')
 	if idEnd == -1 {
-		return nil, fmt.Errorf("ref: missing space")
+		return nil, false, fmt.Errorf("ref: missing space")
 	}
-	refName := githash.Ref(line[idEnd+1:])
+	nameBytes := line[idEnd+1:]
+	const peeledSuffix = "^{}"
+	if bytes.HasSuffix(nameBytes, []byte(peeledSuffix)) {
+		peeled = true
+		nameBytes = nameBytes[:len(nameBytes)-len(peeledSuffix)]
+	}
+	refName := githash.Ref(nameBytes)
 	if !refName.IsValid() {
-		return nil, fmt.Errorf("ref %q: invalid name", refName)
+		return nil, false, fmt.Errorf("ref %q: invalid name", line[idEnd+1:])
 	}
-	id, err := githash.ParseSHA1(string(line[:idEnd]))
+	id, err := githash.ParseObjectID(string(line[:idEnd]))
 	if err != nil {
-		return nil, fmt.Errorf("ref %s: %w", refName, err)
+		return nil, false, fmt.Errorf("ref %s: %w", line[idEnd+1:], err)
 	}
 	return &Ref{
 		ObjectID: id,
 		Name:     refName,
-	}, nil
+	}, peeled, nil
+}
+
+func (p *pullV1) objectSizes(ctx context.Context, ids []githash.ObjectID) (map[githash.ObjectID]int64, error) {
+	return nil, fmt.Errorf("object-info requires protocol version 2")
+}
+
+func (p *pullV1) bundleURIs(ctx context.Context) (*BundleList, error) {
+	return nil, fmt.Errorf("bundle-uri requires protocol version 2")
+}
+
+func (p *pullV1) objectFormat() githash.ObjectFormat {
+	return p.format
 }
 
 func (p *pullV1) capabilities() PullCapabilities {
@@ -260,7 +303,7 @@ func (p *pullV1) capabilities() PullCapabilities {
 }
 
 func (p *pullV1) negotiate(ctx context.Context, errPrefix string, req *PullRequest) (*PullResponse, error) {
-	useCaps, err := capabilitiesToSendV1(req, p.caps)
+	useCaps, err := capabilitiesToSendV1(req, p.caps, p.format)
 	if err != nil {
 		return nil, err
 	}
@@ -277,20 +320,19 @@ func (p *pullV1) negotiate(ctx context.Context, errPrefix string, req *PullReque
 
 	respReader := pktline.NewReader(resp)
 	result := &PullResponse{
-		Acks: make(map[githash.SHA1]struct{}),
+		Acks: make(map[githash.ObjectID]struct{}),
 	}
 	if req.Depth > 0 || !req.Since.IsZero() || len(req.ShallowExclude) > 0 {
 		// "If the client sent a positive depth request, the server will determine
 		// which commits will and will not be shallow and send this information
 		// to the client."
-		var err error
 		result.Shallow, err = readShallowUpdateV1(respReader)
 		if err != nil {
 			return nil, err
 		}
 	}
 	var foundCommonBase bool
-	result.Acks, foundCommonBase, err = readServerResponseV1(respReader)
+	result.Acks, result.Ready, foundCommonBase, err = readServerResponseV1(respReader)
 	if err != nil {
 		return nil, err
 	}
@@ -301,14 +343,18 @@ func (p *pullV1) negotiate(ctx context.Context, errPrefix string, req *PullReque
 			packCloser: resp,
 			progress:   req.Progress,
 		}
+	} else {
+		resp.Close()
 	}
 	return result, nil
 }
 
-func capabilitiesToSendV1(req *PullRequest, remoteCaps capabilityList) (capabilityList, error) {
+func capabilitiesToSendV1(req *PullRequest, remoteCaps capabilityList, format githash.ObjectFormat) (capabilityList, error) {
 	useCaps := capabilityList{
-		multiAckCap: "",
-		ofsDeltaCap: "",
+		multiAckCap:         "",
+		multiAckDetailedCap: "",
+		ofsDeltaCap:         "",
+		objectFormatCap:     format.String(),
 	}
 	if req.Progress == nil {
 		useCaps[noProgressCap] = ""
@@ -335,6 +381,10 @@ func capabilitiesToSendV1(req *PullRequest, remoteCaps capabilityList) (capabili
 		useCaps[thinPackCap] = ""
 	}
 	useCaps.intersect(remoteCaps)
+	if useCaps.supports(multiAckDetailedCap) {
+		// multi_ack_detailed supersedes multi_ack.
+		delete(useCaps, multiAckCap)
+	}
 	// From https://git-scm.com/docs/protocol-capabilities, "[t]he client MUST
 	// send only maximum [sic] of one of 'side-band' and [sic] 'side-band-64k'."
 	switch {
@@ -383,14 +433,14 @@ func formatUploadRequestV1(req *PullRequest, useCaps capabilityList) []byte {
 	return buf
 }
 
-func readShallowUpdateV1(r *pktline.Reader) (map[githash.SHA1]bool, error) {
-	result := make(map[githash.SHA1]bool)
+func readShallowUpdateV1(r *pktline.Reader) (map[githash.ObjectID]bool, error) {
+	result := make(map[githash.ObjectID]bool)
 	for r.Next() && r.Type() != pktline.Flush {
 		line, err := r.Text()
 		if err != nil {
 			return nil, fmt.Errorf("parse shallow update: %w", err)
 		}
-		var commitID githash.SHA1
+		var commitID githash.ObjectID
 		var isShallow bool
 		switch {
 		case bytes.HasPrefix(line, []byte(shallowPrefix)):
@@ -414,12 +464,17 @@ func readShallowUpdateV1(r *pktline.Reader) (map[githash.SHA1]bool, error) {
 	return result, nil
 }
 
-func readServerResponseV1(r *pktline.Reader) (acks map[githash.SHA1]struct{}, foundCommonBase bool, err error) {
-	acks = make(map[githash.SHA1]struct{})
+// readServerResponseV1 reads the acknowledgements sent in response to
+// a round of haves. ready is true if the remote sent "ACK <id> ready",
+// indicating that it has found enough common commits to send a packfile.
+// foundCommonBase is true if the remote sent a final ACK,
+// which is followed by the packfile.
+func readServerResponseV1(r *pktline.Reader) (acks map[githash.ObjectID]struct{}, ready, foundCommonBase bool, err error) {
+	acks = make(map[githash.ObjectID]struct{})
 	for r.Next() {
 		line, err := r.Text()
 		if err != nil {
-			return nil, false, fmt.Errorf("parse response: %w", err)
+			return nil, false, false, fmt.Errorf("parse response: %w", err)
 		}
 		switch {
 		case bytes.HasPrefix(line, []byte(ackPrefix)):
@@ -430,24 +485,29 @@ func readServerResponseV1(r *pktline.Reader) (acks map[githash.SHA1]struct{}, fo
 				idEnd = len(line)
 				statusStart = idEnd
 			}
-			var id githash.SHA1
+			var id githash.ObjectID
 			if err := id.UnmarshalText(line[:idEnd]); err != nil {
-				return nil, false, fmt.Errorf("parse response: acknowledgements: %w", err)
+				return nil, false, false, fmt.Errorf("parse response: acknowledgements: %w", err)
 			}
 			acks[id] = struct{}{}
 			switch status := line[statusStart:]; {
 			case len(status) == 0:
-				return acks, true, nil
+				return acks, ready, true, nil
 			case bytes.Equal(status, []byte("continue")):
 				// Only valid status for multi_ack
+			case bytes.Equal(status, []byte("common")):
+				// Only valid status for multi_ack_detailed
+			case bytes.Equal(status, []byte("ready")):
+				// Only valid status for multi_ack_detailed
+				ready = true
 			default:
-				return nil, false, fmt.Errorf("parse response: acknowledgements: unknown status %q", status)
+				return nil, false, false, fmt.Errorf("parse response: acknowledgements: unknown status %q", status)
 			}
 		case bytes.Equal(line, []byte(nak)):
-			return acks, false, nil
+			return acks, ready, false, nil
 		default:
-			return nil, false, fmt.Errorf("parse response: acknowledgements: unrecognized directive %q", line)
+			return nil, false, false, fmt.Errorf("parse response: acknowledgements: unrecognized directive %q", line)
 		}
 	}
-	return nil, false, fmt.Errorf("parse response: %w", err)
+	return nil, false, false, fmt.Errorf("parse response: %w", r.Err())
 }
@@ -21,6 +21,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	"gg-scm.io/pkg/git/githash"
@@ -32,13 +33,29 @@ import (
 const v2ExtraParams = "version=2"
 
 const (
-	listRefsV2Command = "ls-refs"
-	fetchV2Command    = "fetch"
+	listRefsV2Command   = "ls-refs"
+	fetchV2Command      = "fetch"
+	objectInfoV2Command = "object-info"
+	bundleURIV2Command  = "bundle-uri"
 )
 
+// unbornFeature is the ls-refs feature that reports
+// the target of a symbolic ref to a branch that does not exist,
+// as well as the keyword used in place of an object ID in the response.
+const unbornFeature = "unborn"
+
+// packfileURIsFeature is the fetch feature that permits the server
+// to send some objects as packfiles to download from other URIs.
+const packfileURIsFeature = "packfile-uris"
+
+// sidebandAllFeature is the fetch feature that multiplexes
+// the entire response instead of only the packfile section.
+const sidebandAllFeature = "sideband-all"
+
 type pullV2 struct {
-	caps capabilityList
-	impl impl
+	caps   capabilityList
+	format githash.ObjectFormat
+	impl   impl
 }
 
 func (p *pullV2) Close() error {
@@ -52,8 +69,13 @@ func (p *pullV2) listRefs(ctx context.Context, refPrefixes []string) (map[githas
 
 	var commandBuf []byte
 	commandBuf = pktline.AppendString(commandBuf, "command="+listRefsV2Command+"\n")
+	commandBuf = p.appendCommandCapabilities(commandBuf)
 	commandBuf = pktline.AppendDelim(commandBuf)
 	commandBuf = pktline.AppendString(commandBuf, "symrefs\n")
+	commandBuf = pktline.AppendString(commandBuf, "peel\n")
+	if p.caps.hasFeature(listRefsV2Command, unbornFeature) {
+		commandBuf = pktline.AppendString(commandBuf, unbornFeature+"\n")
+	}
 	for _, prefix := range refPrefixes {
 		commandBuf = pktline.AppendString(commandBuf, "ref-prefix "+prefix+"\n")
 	}
@@ -78,9 +100,14 @@ func (p *pullV2) listRefs(ctx context.Context, refPrefixes []string) (map[githas
 		if !ref.Name.IsValid() {
 			return nil, fmt.Errorf("parse response: ref %q: invalid name", ref.Name)
 		}
-		ref.ObjectID, err = parseObjectID(words[0])
-		if err != nil {
-			return nil, fmt.Errorf("parse response: ref %s: %w", ref.Name, err)
+		if string(words[0]) != unbornFeature {
+			ref.ObjectID, err = parseObjectID(words[0])
+			if err != nil {
+				return nil, fmt.Errorf("parse response: ref %s: %w", ref.Name, err)
+			}
+			if ref.ObjectID.ObjectFormat() != p.format {
+				return nil, fmt.Errorf("parse response: ref %s: object ID is not %v", ref.Name, p.format)
+			}
 		}
 		for _, attr := range words[2:] {
 			if val, ok := isRefAttribute(attr, "symref-target"); ok {
@@ -88,8 +115,19 @@ func (p *pullV2) listRefs(ctx context.Context, refPrefixes []string) (map[githas
 				if !ref.SymrefTarget.IsValid() {
 					return nil, fmt.Errorf("parse response: ref %s: invalid symref target %q", ref.Name, ref.SymrefTarget)
 				}
+			} else if val, ok := isRefAttribute(attr, "peeled"); ok {
+				ref.PeeledObjectID, err = parseObjectID(val)
+				if err != nil {
+					return nil, fmt.Errorf("parse response: ref %s: peeled: %w", ref.Name, err)
+				}
+				if ref.PeeledObjectID.ObjectFormat() != p.format {
+					return nil, fmt.Errorf("parse response: ref %s: peeled object ID is not %v", ref.Name, p.format)
+				}
 			}
 		}
+		if ref.ObjectID.IsZero() && ref.SymrefTarget == "" {
+			return nil, fmt.Errorf("parse response: unborn ref %s without symref target", ref.Name)
+		}
 		refs[ref.Name] = ref
 	}
 	if err := respReader.Err(); err != nil {
@@ -98,6 +136,164 @@ func (p *pullV2) listRefs(ctx context.Context, refPrefixes []string) (map[githas
 	return refs, nil
 }
 
+func (p *pullV2) objectSizes(ctx context.Context, ids []githash.ObjectID) (map[githash.ObjectID]int64, error) {
+	if !p.caps.supports(objectInfoV2Command) {
+		return nil, fmt.Errorf("unsupported by server")
+	}
+	var commandBuf []byte
+	commandBuf = pktline.AppendString(commandBuf, "command="+objectInfoV2Command+"\n")
+	commandBuf = p.appendCommandCapabilities(commandBuf)
+	commandBuf = pktline.AppendDelim(commandBuf)
+	commandBuf = pktline.AppendString(commandBuf, "size\n")
+	for _, id := range ids {
+		if id.ObjectFormat() != p.format {
+			return nil, fmt.Errorf("object %v is not %v", id, p.format)
+		}
+		commandBuf = pktline.AppendString(commandBuf, "oid "+id.String()+"\n")
+	}
+	commandBuf = pktline.AppendFlush(commandBuf)
+	resp, err := p.impl.uploadPack(ctx, v2ExtraParams, bytes.NewReader(commandBuf))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	// The first line lists the requested attributes.
+	respReader := pktline.NewReader(resp)
+	if !respReader.Next() {
+		return nil, fmt.Errorf("parse response: %w", respReader.Err())
+	}
+	line, err := respReader.Text()
+	if err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	if attrs := strings.Fields(string(line)); len(attrs) != 1 || attrs[0] != "size" {
+		return nil, fmt.Errorf("parse response: unexpected attributes %q", line)
+	}
+	sizes := make(map[githash.ObjectID]int64, len(ids))
+	for respReader.Next() && respReader.Type() != pktline.Flush {
+		line, err := respReader.Text()
+		if err != nil {
+			return nil, fmt.Errorf("parse response: %w", err)
+		}
+		idEnd := bytes.IndexByte(line, ' ')
+		if idEnd == -1 {
+			return nil, fmt.Errorf("parse response: invalid packet from server")
+		}
+		id, err := parseObjectID(line[:idEnd])
+		if err != nil {
+			return nil, fmt.Errorf("parse response: %w", err)
+		}
+		sizeString := string(line[idEnd+1:])
+		if sizeString == "" {
+			// The remote does not have the object.
+			continue
+		}
+		size, err := strconv.ParseInt(sizeString, 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("parse response: object %v: invalid size %q", id, sizeString)
+		}
+		sizes[id] = size
+	}
+	if err := respReader.Err(); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return sizes, nil
+}
+
+func (p *pullV2) bundleURIs(ctx context.Context) (*BundleList, error) {
+	if !p.caps.supports(bundleURIV2Command) {
+		return nil, fmt.Errorf("unsupported by server")
+	}
+	var commandBuf []byte
+	commandBuf = pktline.AppendString(commandBuf, "command="+bundleURIV2Command+"\n")
+	commandBuf = p.appendCommandCapabilities(commandBuf)
+	commandBuf = pktline.AppendFlush(commandBuf)
+	resp, err := p.impl.uploadPack(ctx, v2ExtraParams, bytes.NewReader(commandBuf))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	list, err := readBundleListV2(pktline.NewReader(resp))
+	if err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return list, nil
+}
+
+// readBundleListV2 parses the key=value lines of a bundle-uri response.
+// Keys that are not understood are ignored, as required by the protocol.
+func readBundleListV2(r *pktline.Reader) (*BundleList, error) {
+	list := new(BundleList)
+	bundles := make(map[string]*Bundle)
+	for r.Next() && r.Type() != pktline.Flush {
+		line, err := r.Text()
+		if err != nil {
+			return nil, err
+		}
+		key, value, ok := strings.Cut(string(line), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid bundle list line %q", line)
+		}
+		key = strings.ToLower(key)
+		switch key {
+		case "bundle.version":
+			list.Version, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("bundle.version: %w", err)
+			}
+			continue
+		case "bundle.mode":
+			list.Mode = value
+			continue
+		case "bundle.heuristic":
+			list.Heuristic = value
+			continue
+		}
+		id, field, ok := strings.Cut(strings.TrimPrefix(key, "bundle."), ".")
+		if !ok || !strings.HasPrefix(key, "bundle.") || id == "" {
+			continue
+		}
+		b := bundles[id]
+		if b == nil {
+			b = &Bundle{ID: id}
+			bundles[id] = b
+			list.Bundles = append(list.Bundles, b)
+		}
+		switch field {
+		case "uri":
+			b.URI = value
+		case "creationtoken":
+			b.CreationToken, err = strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bundle.%s.creationToken: %w", id, err)
+			}
+		}
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	for _, b := range list.Bundles {
+		if b.URI == "" {
+			return nil, fmt.Errorf("bundle %q missing uri", b.ID)
+		}
+	}
+	return list, nil
+}
+
+// appendCommandCapabilities appends the capability lines
+// that the client sends in a command request.
+func (p *pullV2) appendCommandCapabilities(buf []byte) []byte {
+	if p.caps.supports(objectFormatCap) {
+		buf = pktline.AppendString(buf, objectFormatCap+"="+p.format.String()+"\n")
+	}
+	return buf
+}
+
+func (p *pullV2) objectFormat() githash.ObjectFormat {
+	return p.format
+}
+
 func isRefAttribute(b []byte, name string) (val []byte, ok bool) {
 	if len(b) < len(name)+1 {
 		return nil, false
@@ -115,12 +311,20 @@ func isRefAttribute(b []byte, name string) (val []byte, ok bool) {
 
 func (p *pullV2) capabilities() PullCapabilities {
 	caps := PullCapIncludeTag | PullCapThinPack
+	if p.caps.supports(objectInfoV2Command) {
+		caps |= PullCapObjectInfo
+	}
+	if p.caps.supports(bundleURIV2Command) {
+		caps |= PullCapBundleURI
+	}
 	for _, feature := range strings.Fields(p.caps[fetchV2Command]) {
 		switch feature {
 		case shallowCap:
 			caps |= PullCapShallow | PullCapDepthRelative | PullCapSince | PullCapShallowExclude
 		case filterCap:
 			caps |= PullCapFilter
+		case packfileURIsFeature:
+			caps |= PullCapPackfileURIs
 		}
 	}
 	return caps
@@ -130,12 +334,22 @@ func (p *pullV2) negotiate(ctx context.Context, errPrefix string, req *PullReque
 	if !p.caps.supports(fetchV2Command) {
 		return nil, fmt.Errorf("unsupported by server")
 	}
-	commandBuf := formatFetchRequestV2(req)
+	// Git only sends packfile URIs in a response that is entirely multiplexed.
+	sidebandAll := len(req.PackfileURIProtocols) > 0 && p.caps.hasFeature(fetchV2Command, sidebandAllFeature)
+	commandBuf := formatFetchRequestV2(req, sidebandAll, p.appendCommandCapabilities(nil))
 	resp, err := p.impl.uploadPack(ctx, v2ExtraParams, bytes.NewReader(commandBuf))
 	if err != nil {
 		return nil, err
 	}
-	respReader := pktline.NewReader(resp)
+	var respReader *pktline.Reader
+	if sidebandAll {
+		respReader = pktline.NewReader(&sidebandAllReader{
+			r:        pktline.NewReader(resp),
+			progress: req.Progress,
+		})
+	} else {
+		respReader = pktline.NewReader(resp)
+	}
 	result, err := readFetchOutputV2(respReader, func(_ *pktline.Reader) io.ReadCloser {
 		return &packfileReader{
 			errPrefix:  errPrefix,
@@ -150,9 +364,10 @@ func (p *pullV2) negotiate(ctx context.Context, errPrefix string, req *PullReque
 	return result, err
 }
 
-func formatFetchRequestV2(req *PullRequest) []byte {
+func formatFetchRequestV2(req *PullRequest, sidebandAll bool, capsBuf []byte) []byte {
 	var buf []byte
 	buf = pktline.AppendString(buf, "command="+fetchV2Command+"\n")
+	buf = append(buf, capsBuf...)
 	buf = pktline.AppendDelim(buf)
 	for _, want := range req.Want {
 		buf = pktline.AppendString(buf, "want "+want.String()+"\n")
@@ -191,6 +406,12 @@ func formatFetchRequestV2(req *PullRequest) []byte {
 	if req.Filter != "" {
 		buf = pktline.AppendString(buf, "filter "+req.Filter+"\n")
 	}
+	if sidebandAll {
+		buf = pktline.AppendString(buf, sidebandAllFeature+"\n")
+	}
+	if len(req.PackfileURIProtocols) > 0 {
+		buf = pktline.AppendString(buf, packfileURIsFeature+" "+strings.Join(req.PackfileURIProtocols, ",")+"\n")
+	}
 	buf = pktline.AppendFlush(buf)
 	return buf
 }
@@ -205,7 +426,7 @@ func readFetchOutputV2(r *pktline.Reader, newPackfileReader func(*pktline.Reader
 
 	if bytes.Equal(section, []byte("acknowledgments")) {
 		var err error
-		result.Acks, err = readAcksSectionV2(r)
+		result.Acks, result.Ready, err = readAcksSectionV2(r)
 		if err != nil {
 			return nil, fmt.Errorf("parse response: %w", err)
 		}
@@ -239,6 +460,22 @@ func readFetchOutputV2(r *pktline.Reader, newPackfileReader func(*pktline.Reader
 		}
 	}
 
+	if bytes.Equal(section, []byte(packfileURIsFeature)) {
+		var err error
+		result.PackfileURIs, err = readPackfileURIsSectionV2(r)
+		if err != nil {
+			return nil, fmt.Errorf("parse response: %w", err)
+		}
+		if r.Type() != pktline.Delim {
+			return nil, fmt.Errorf("parse response: parse packfile uris: expected delim at end")
+		}
+		r.Next()
+		section, err = r.Text()
+		if err != nil {
+			return nil, fmt.Errorf("parse response: %w", err)
+		}
+	}
+
 	if !bytes.Equal(section, []byte("packfile")) {
 		return nil, fmt.Errorf("parse response: unexpected section %q", section)
 	}
@@ -246,35 +483,107 @@ func readFetchOutputV2(r *pktline.Reader, newPackfileReader func(*pktline.Reader
 	return result, nil
 }
 
+// sidebandAllReader removes the multiplexing from the sections of
+// a fetch response sent with the sideband-all feature so that they can be
+// parsed like any other response. Once the packfile section header has been
+// read, packets are passed through unchanged, since the packfile section is
+// always multiplexed.
+type sidebandAllReader struct {
+	r        *pktline.Reader
+	progress io.Writer
+
+	buf         []byte // unread portion of storage
+	storage     []byte
+	passthrough bool
+	err         error
+}
+
+func (sr *sidebandAllReader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		sr.err = sr.fill()
+	}
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+func (sr *sidebandAllReader) fill() error {
+	sr.storage = sr.storage[:0]
+	if !sr.r.Next() {
+		return sr.r.Err()
+	}
+	switch sr.r.Type() {
+	case pktline.Flush:
+		sr.storage = pktline.AppendFlush(sr.storage)
+	case pktline.Delim:
+		sr.storage = pktline.AppendDelim(sr.storage)
+	default:
+		pkt, err := sr.r.Bytes()
+		if err != nil {
+			return err
+		}
+		if len(pkt) == 0 {
+			return fmt.Errorf("empty packet")
+		}
+		if sr.passthrough {
+			sr.storage = pktline.Append(sr.storage, pkt)
+			break
+		}
+		pktType, data := pkt[0], pkt[1:]
+		switch pktType {
+		case 1:
+			if len(data) == 0 {
+				return fmt.Errorf("empty packet")
+			}
+			sr.storage = pktline.Append(sr.storage, data)
+			sr.passthrough = bytes.Equal(trimLF(data), []byte("packfile"))
+		case 2:
+			if sr.progress != nil {
+				sr.progress.Write(data)
+			}
+		case 3:
+			return fmt.Errorf("server error: %s", trimLF(data))
+		default:
+			return fmt.Errorf("encountered bad stream code (%02x)", pktType)
+		}
+	}
+	sr.buf = sr.storage
+	return nil
+}
+
 const (
 	ackPrefix = "ACK "
 	nak       = "NAK"
 )
 
-func readAcksSectionV2(r *pktline.Reader) (map[githash.SHA1]struct{}, error) {
-	acks := make(map[githash.SHA1]struct{})
+func readAcksSectionV2(r *pktline.Reader) (acks map[githash.ObjectID]struct{}, ready bool, err error) {
+	acks = make(map[githash.ObjectID]struct{})
 	for r.Next() && r.Type() == pktline.Data {
 		line, err := r.Text()
 		if err != nil {
-			return nil, fmt.Errorf("parse acknowledgements: %w", err)
+			return nil, false, fmt.Errorf("parse acknowledgements: %w", err)
 		}
 		switch {
 		case bytes.HasPrefix(line, []byte(ackPrefix)):
-			var id githash.SHA1
+			var id githash.ObjectID
 			if err := id.UnmarshalText(line[len(ackPrefix):]); err != nil {
-				return nil, fmt.Errorf("parse acknowledgements: %w", err)
+				return nil, false, fmt.Errorf("parse acknowledgements: %w", err)
 			}
 			acks[id] = struct{}{}
 		case bytes.Equal(line, []byte(nak)):
 		case bytes.Equal(line, []byte("ready")):
+			ready = true
 		default:
-			return nil, fmt.Errorf("parse acknowledgements: unrecognized directive %q", line)
+			return nil, false, fmt.Errorf("parse acknowledgements: unrecognized directive %q", line)
 		}
 	}
 	if err := r.Err(); err != nil {
-		return nil, fmt.Errorf("parse acknowledgements: %w", err)
+		return nil, false, fmt.Errorf("parse acknowledgements: %w", err)
 	}
-	return acks, nil
+	return acks, ready, nil
 }
 
 const (
@@ -282,14 +591,14 @@ const (
 	unshallowPrefix = "unshallow "
 )
 
-func readShallowInfoSectionV2(r *pktline.Reader) (map[githash.SHA1]bool, error) {
-	result := make(map[githash.SHA1]bool)
+func readShallowInfoSectionV2(r *pktline.Reader) (map[githash.ObjectID]bool, error) {
+	result := make(map[githash.ObjectID]bool)
 	for r.Next() && r.Type() == pktline.Data {
 		line, err := r.Text()
 		if err != nil {
 			return nil, fmt.Errorf("parse shallow info: %w", err)
 		}
-		var commitID githash.SHA1
+		var commitID githash.ObjectID
 		var isShallow bool
 		switch {
 		case bytes.HasPrefix(line, []byte(shallowPrefix)):
@@ -313,6 +622,36 @@ func readShallowInfoSectionV2(r *pktline.Reader) (map[githash.SHA1]bool, error)
 	return result, nil
 }
 
+func readPackfileURIsSectionV2(r *pktline.Reader) ([]*PackfileURI, error) {
+	var uris []*PackfileURI
+	for r.Next() && r.Type() == pktline.Data {
+		line, err := r.Text()
+		if err != nil {
+			return nil, fmt.Errorf("parse packfile uris: %w", err)
+		}
+		hashEnd := bytes.IndexByte(line, ' ')
+		if hashEnd == -1 {
+			return nil, fmt.Errorf("parse packfile uris: invalid line %q", line)
+		}
+		hash, err := parseObjectID(line[:hashEnd])
+		if err != nil {
+			return nil, fmt.Errorf("parse packfile uris: %w", err)
+		}
+		uri := string(line[hashEnd+1:])
+		if uri == "" {
+			return nil, fmt.Errorf("parse packfile uris: empty uri for pack %v", hash)
+		}
+		uris = append(uris, &PackfileURI{
+			Hash: hash,
+			URI:  uri,
+		})
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("parse packfile uris: %w", err)
+	}
+	return uris, nil
+}
+
 const version2Line = "version 2"
 
 // parseCapabilityAdvertisementV2 parses the version 2 "refs" advertisement
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"strings"
 
 	"gg-scm.io/pkg/git/githash"
@@ -29,8 +30,13 @@ import (
 // receive-pack capability names.
 // See https://git-scm.com/docs/protocol-capabilities
 const (
-	deleteRefsCap   = "delete-refs"
-	reportStatusCap = "report-status"
+	atomicCap         = "atomic"
+	deleteRefsCap     = "delete-refs"
+	noThinCap         = "no-thin"
+	pushOptionsCap    = "push-options"
+	quietCap          = "quiet"
+	reportStatusCap   = "report-status"
+	reportStatusV2Cap = "report-status-v2"
 )
 
 // PushStream represents a git-receive-pack session.
@@ -38,10 +44,14 @@ type PushStream struct {
 	urlstr string
 	refs   map[githash.Ref]*Ref
 	caps   capabilityList
-	conn   receivePackConn
+	format githash.ObjectFormat
+	conn   serviceConn
 
 	wroteCommands bool
 	hasPack       bool
+	useCaps       capabilityList
+	progress      io.Writer
+	statuses      []*RefStatus
 }
 
 // StartPush starts a git-receive-pack session, reading the ref advertisements.
@@ -62,12 +72,19 @@ func (r *Remote) StartPush(ctx context.Context) (_ *PushStream, err error) {
 	if err != nil {
 		return nil, fmt.Errorf("push %s: %w", r.urlstr, err)
 	}
+	format, err := caps.objectFormat()
+	if err != nil {
+		return nil, fmt.Errorf("push %s: %w", r.urlstr, err)
+	}
 	var refs map[githash.Ref]*Ref
 	if ref0 != nil {
+		if ref0.ObjectID.ObjectFormat() != format {
+			return nil, fmt.Errorf("push %s: read refs: ref %s: object ID is not %v", r.urlstr, ref0.Name, format)
+		}
 		refs = map[githash.Ref]*Ref{
 			ref0.Name: ref0,
 		}
-		if err := readOtherRefsV1(refs, caps.symrefs(), connReader); err != nil {
+		if err := readOtherRefsV1(refs, caps.symrefs(), format, connReader); err != nil {
 			return nil, fmt.Errorf("push %s: %w", r.urlstr, err)
 		}
 	}
@@ -75,6 +92,7 @@ func (r *Remote) StartPush(ctx context.Context) (_ *PushStream, err error) {
 		urlstr: r.urlstr,
 		refs:   refs,
 		caps:   caps,
+		format: format,
 		conn:   conn,
 	}, nil
 }
@@ -85,51 +103,214 @@ func (p *PushStream) Refs() map[githash.Ref]*Ref {
 	return p.refs
 }
 
+// ObjectFormat returns the object format of the remote repository.
+// Commands and packfiles sent to the remote must use this format.
+func (p *PushStream) ObjectFormat() githash.ObjectFormat {
+	return p.format
+}
+
+// Capabilities returns the set of push request fields the remote supports.
+func (p *PushStream) Capabilities() PushCapabilities {
+	var caps PushCapabilities
+	if p.caps.supports(atomicCap) {
+		caps |= PushCapAtomic
+	}
+	if p.caps.supports(deleteRefsCap) {
+		caps |= PushCapDeleteRefs
+	}
+	if p.caps.supports(pushOptionsCap) {
+		caps |= PushCapOptions
+	}
+	if p.caps.supports(ofsDeltaCap) {
+		caps |= PushCapOfsDelta
+	}
+	if p.caps.supports(reportStatusV2Cap) {
+		caps |= PushCapReportStatusV2
+	}
+	if p.caps.supports(noThinCap) {
+		caps |= PushCapNoThin
+	}
+	return caps
+}
+
+// PushCapabilities is a bitset of capabilities that a remote supports for pushing.
+type PushCapabilities uint64
+
+// Push capabilities.
+// See https://git-scm.com/docs/protocol-capabilities for descriptions.
+const (
+	PushCapAtomic         PushCapabilities = 1 << iota // atomic
+	PushCapDeleteRefs                                  // delete-refs
+	PushCapOptions                                     // push-options
+	PushCapOfsDelta                                    // ofs-delta
+	PushCapReportStatusV2                              // report-status-v2
+	PushCapNoThin                                      // no-thin
+
+	maxPushCapBit
+)
+
+// Has reports whether caps includes all of the capabilities in mask.
+func (caps PushCapabilities) Has(mask PushCapabilities) bool {
+	return caps&mask == mask
+}
+
+// String returns a |-separated list of the capability constant names present
+// in caps.
+func (caps PushCapabilities) String() string {
+	sb := new(strings.Builder)
+	for bit := PushCapabilities(1); bit < maxPushCapBit; bit <<= 1 {
+		if !caps.Has(bit) {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("|")
+		}
+		switch bit {
+		case PushCapAtomic:
+			sb.WriteString("PushCapAtomic")
+		case PushCapDeleteRefs:
+			sb.WriteString("PushCapDeleteRefs")
+		case PushCapOptions:
+			sb.WriteString("PushCapOptions")
+		case PushCapOfsDelta:
+			sb.WriteString("PushCapOfsDelta")
+		case PushCapReportStatusV2:
+			sb.WriteString("PushCapReportStatusV2")
+		case PushCapNoThin:
+			sb.WriteString("PushCapNoThin")
+		}
+		caps &^= bit
+	}
+	if caps != 0 {
+		if sb.Len() > 0 {
+			sb.WriteString("|")
+		}
+		fmt.Fprintf(sb, "%#x", uint64(caps))
+	}
+	return sb.String()
+}
+
 // A PushCommand is an instruction to update a remote ref. At least one of Old
 // or New must be set.
 type PushCommand struct {
 	RefName githash.Ref
-	Old     githash.SHA1 // if not set, then create the ref
-	New     githash.SHA1 // if not set, then delete the ref
+	Old     githash.ObjectID // if not set, then create the ref
+	New     githash.ObjectID // if not set, then delete the ref
 }
 
 func (cmd *PushCommand) isZero() bool {
-	return cmd.New == githash.SHA1{} && cmd.Old == githash.SHA1{}
+	return cmd.New.IsZero() && cmd.Old.IsZero()
 }
 
 func (cmd *PushCommand) isDelete() bool {
-	return cmd.New == githash.SHA1{} && cmd.Old != githash.SHA1{}
+	return cmd.New.IsZero() && !cmd.Old.IsZero()
+}
+
+// objectFormat returns the object format of the command's non-zero object IDs.
+func (cmd *PushCommand) objectFormat() githash.ObjectFormat {
+	if !cmd.New.IsZero() {
+		return cmd.New.ObjectFormat()
+	}
+	return cmd.Old.ObjectFormat()
 }
 
 // String returns the wire representation of the push command.
+// An unset object ID is written as the null hash
+// in the object format of the other object ID.
 func (cmd *PushCommand) String() string {
-	return cmd.Old.String() + " " + cmd.New.String() + " " + cmd.RefName.String()
+	oldID, newID := cmd.Old, cmd.New
+	if oldID.IsZero() {
+		oldID = cmd.objectFormat().Null()
+	}
+	if newID.IsZero() {
+		newID = cmd.objectFormat().Null()
+	}
+	return oldID.String() + " " + newID.String() + " " + cmd.RefName.String()
+}
+
+// A PushRequest describes the ref changes to make on the remote
+// along with options for how to make them.
+type PushRequest struct {
+	// Commands is the list of ref changes to make.
+	Commands []*PushCommand
+
+	// If Atomic is true, then the remote either updates all of the refs
+	// or none of them. This is only supported by the remote if it has
+	// PushCapAtomic.
+	Atomic bool
+
+	// Options is a list of strings passed to the remote's receive hooks,
+	// like `git push --push-option`. This is only supported by the remote
+	// if it has PushCapOptions.
+	Options []string
+
+	// Progress will receive progress messages and hook output from the remote
+	// while the stream is closed. If Progress is nil, then the remote is asked
+	// not to send progress messages.
+	Progress io.Writer
 }
 
 // WriteCommands informs the remote what ref changes to make once the stream is
 // complete. This must be called at most once, and must be called before any
-// calls to Write.
+// calls to Write. It is equivalent to calling WriteRequest
+// with a PushRequest that only sets Commands.
 func (p *PushStream) WriteCommands(commands ...*PushCommand) error {
+	return p.WriteRequest(&PushRequest{Commands: commands})
+}
+
+// WriteRequest informs the remote what ref changes to make once the stream is
+// complete. At most one call to WriteCommands or WriteRequest may be made,
+// and it must be made before any calls to Write.
+func (p *PushStream) WriteRequest(req *PushRequest) error {
 	// Verify preconditions.
 	if p.wroteCommands {
 		return fmt.Errorf("push %s: WriteCommands called multiple times", p.urlstr)
 	}
-	if len(commands) == 0 {
+	if len(req.Commands) == 0 {
 		return nil
 	}
+	if req.Atomic && !p.caps.supports(atomicCap) {
+		return fmt.Errorf("push %s: remote does not support atomic pushes", p.urlstr)
+	}
+	if len(req.Options) > 0 && !p.caps.supports(pushOptionsCap) {
+		return fmt.Errorf("push %s: remote does not support push options", p.urlstr)
+	}
+	for _, opt := range req.Options {
+		if strings.ContainsAny(opt, "\x00\n") {
+			return fmt.Errorf("push %s: push option %q contains a newline or NUL", p.urlstr, opt)
+		}
+	}
 
 	// Determine which capabilities we can use.
 	useCaps := capabilityList{
-		reportStatusCap: "",
 		ofsDeltaCap:     "",
 		deleteRefsCap:   "",
+		objectFormatCap: p.format.String(),
+		sideBand64KCap:  "",
+	}
+	if p.caps.supports(reportStatusV2Cap) {
+		useCaps[reportStatusV2Cap] = ""
+	} else {
+		useCaps[reportStatusCap] = ""
+	}
+	if req.Atomic {
+		useCaps[atomicCap] = ""
+	}
+	if len(req.Options) > 0 {
+		useCaps[pushOptionsCap] = ""
+	}
+	if req.Progress == nil {
+		useCaps[quietCap] = ""
 	}
 	useCaps.intersect(p.caps)
 	hasNonDelete := false
-	for _, c := range commands {
+	for _, c := range req.Commands {
 		if c.isZero() {
 			return fmt.Errorf("push %s: empty command for %s", p.urlstr, c.RefName)
 		}
+		if (!c.Old.IsZero() && c.Old.ObjectFormat() != p.format) || (!c.New.IsZero() && c.New.ObjectFormat() != p.format) {
+			return fmt.Errorf("push %s: command for %s does not use %v object IDs", p.urlstr, c.RefName, p.format)
+		}
 		if c.isDelete() {
 			if !p.caps.supports(deleteRefsCap) {
 				return fmt.Errorf("push %s: remote does not support deleting refs", p.urlstr)
@@ -142,7 +323,7 @@ func (p *PushStream) WriteCommands(commands ...*PushCommand) error {
 	// Write commands.
 	p.wroteCommands = true
 	var buf []byte
-	for i, c := range commands {
+	for i, c := range req.Commands {
 		if i == 0 {
 			buf = pktline.AppendString(buf, c.String()+"\x00"+useCaps.String()+"\n")
 		} else {
@@ -150,10 +331,18 @@ func (p *PushStream) WriteCommands(commands ...*PushCommand) error {
 		}
 	}
 	buf = pktline.AppendFlush(buf)
+	if len(req.Options) > 0 {
+		for _, opt := range req.Options {
+			buf = pktline.AppendString(buf, opt+"\n")
+		}
+		buf = pktline.AppendFlush(buf)
+	}
 	if _, err := p.conn.Write(buf); err != nil {
 		return fmt.Errorf("push %s: write commands: %w", p.urlstr, err)
 	}
 	p.hasPack = hasNonDelete
+	p.useCaps = useCaps
+	p.progress = req.Progress
 	return nil
 }
 
@@ -167,10 +356,13 @@ func (p *PushStream) Write(data []byte) (int, error) {
 }
 
 // Close completes the stream and releases any resources associated with the
-// stream.
+// stream. If the remote rejected any of the commands, then Close returns an
+// error and RefStatuses reports which ones.
 func (p *PushStream) Close() error {
 	var err1 error
-	if p.wroteCommands && p.caps.supports(reportStatusCap) {
+	if p.wroteCommands && (p.useCaps.supports(reportStatusCap) ||
+		p.useCaps.supports(reportStatusV2Cap) ||
+		p.useCaps.supports(sideBand64KCap)) {
 		err1 = p.readStatus()
 	}
 	err2 := p.conn.Close()
@@ -180,17 +372,74 @@ func (p *PushStream) Close() error {
 	return err2
 }
 
+// RefStatuses returns the outcome of each command as reported by the remote,
+// in the order the remote reported them. It returns nil before Close is called
+// or if the remote does not support reporting status.
+func (p *PushStream) RefStatuses() []*RefStatus {
+	return p.statuses
+}
+
+// A RefStatus is the outcome of a PushCommand reported by the remote.
+type RefStatus struct {
+	// RefName is the name of the ref in the command.
+	RefName githash.Ref
+	// Error is the reason the remote gave for rejecting the command,
+	// or empty if the ref was updated.
+	Error string
+
+	// The remaining fields are only set by remotes that support
+	// PushCapReportStatusV2 when a hook (like proc-receive)
+	// changed how the command was applied.
+	// A remote may report more than one RefStatus for a single command.
+
+	// RewrittenRefName is the name of the ref that was actually updated.
+	RewrittenRefName githash.Ref
+	// OldID and NewID are the object IDs that the ref was actually updated
+	// from and to.
+	OldID githash.ObjectID
+	NewID githash.ObjectID
+	// ForcedUpdate is true if the update was not a fast-forward.
+	ForcedUpdate bool
+}
+
+// OK reports whether the remote applied the command.
+func (status *RefStatus) OK() bool {
+	return status.Error == ""
+}
+
 func (p *PushStream) readStatus() error {
 	// Indicate that we're done writing. This is required for the HTTP protocol
 	// to finish the request body.
 	if err := p.conn.CloseWrite(); err != nil {
 		return fmt.Errorf("push %s: %w", p.urlstr, err)
 	}
+	var r io.Reader = p.conn
+	if p.useCaps.supports(sideBand64KCap) {
+		r = &packfileReader{
+			errPrefix:  "read status report",
+			packReader: pktline.NewReader(p.conn),
+			progress:   p.progress,
+		}
+	}
+	if !p.useCaps.supports(reportStatusCap) && !p.useCaps.supports(reportStatusV2Cap) {
+		// Drain progress messages.
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return fmt.Errorf("push %s: %w", p.urlstr, err)
+		}
+		return nil
+	}
 	// Read status.
-	report, err := readStatusReport(pktline.NewReader(p.conn))
+	report, err := readStatusReport(pktline.NewReader(r), p.format)
 	if err != nil {
 		return fmt.Errorf("push %s: %w", p.urlstr, err)
 	}
+	p.statuses = report.commands
+	if r, ok := r.(*packfileReader); ok {
+		// Read any trailing progress messages.
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return fmt.Errorf("push %s: %w", p.urlstr, err)
+		}
+	}
 	if !report.isOK() {
 		return report
 	}
@@ -198,13 +447,13 @@ func (p *PushStream) readStatus() error {
 }
 
 type statusReport struct {
-	status   string            // blank if ok
-	commands map[string]string // ref name -> error mssage (blank if ok)
+	status   string // blank if ok
+	commands []*RefStatus
 }
 
-// readStatusReport reads a report-status from the wire.
+// readStatusReport reads a report-status or report-status-v2 from the wire.
 // https://git-scm.com/docs/pack-protocol#_report_status
-func readStatusReport(r *pktline.Reader) (*statusReport, error) {
+func readStatusReport(r *pktline.Reader, format githash.ObjectFormat) (*statusReport, error) {
 	// Read unpack-status
 	r.Next()
 	line, err := r.Text()
@@ -216,8 +465,7 @@ func readStatusReport(r *pktline.Reader) (*statusReport, error) {
 		return nil, fmt.Errorf("read status report: did not start with %q", unpackStatusPrefix)
 	}
 	report := &statusReport{
-		status:   string(line[len(unpackStatusPrefix):]),
-		commands: make(map[string]string),
+		status: string(line[len(unpackStatusPrefix):]),
 	}
 	if report.status == "" {
 		return nil, fmt.Errorf("read status report: unpack status blank")
@@ -230,6 +478,7 @@ func readStatusReport(r *pktline.Reader) (*statusReport, error) {
 	// Slightly more relaxed than spec.
 	successPrefix := []byte("ok ")
 	errorPrefix := []byte("ng ")
+	optionPrefix := []byte("option ")
 	for r.Next() && r.Type() != pktline.Flush {
 		line, err := r.Text()
 		if err != nil {
@@ -238,16 +487,26 @@ func readStatusReport(r *pktline.Reader) (*statusReport, error) {
 		switch {
 		case bytes.HasPrefix(line, successPrefix):
 			refName := string(line[len(successPrefix):])
-			report.commands[refName] = ""
+			report.commands = append(report.commands, &RefStatus{
+				RefName: githash.Ref(refName),
+			})
 		case bytes.HasPrefix(line, errorPrefix):
 			refNameAndError := string(line[len(errorPrefix):])
 			i := strings.IndexByte(refNameAndError, ' ')
 			if i == -1 || i == len(refNameAndError)-1 {
 				return nil, fmt.Errorf("read status report: commands: missing error message for %q", refNameAndError)
 			}
-			refName := refNameAndError[:i]
-			errorMsg := refNameAndError[i+1:]
-			report.commands[refName] = errorMsg
+			report.commands = append(report.commands, &RefStatus{
+				RefName: githash.Ref(refNameAndError[:i]),
+				Error:   refNameAndError[i+1:],
+			})
+		case bytes.HasPrefix(line, optionPrefix):
+			if len(report.commands) == 0 || !report.commands[len(report.commands)-1].OK() {
+				return nil, fmt.Errorf("read status report: commands: option line without preceding ok")
+			}
+			if err := report.commands[len(report.commands)-1].parseOption(string(line[len(optionPrefix):]), format); err != nil {
+				return nil, fmt.Errorf("read status report: commands: %w", err)
+			}
 		default:
 			return nil, fmt.Errorf("read status report: commands: unknown command status")
 		}
@@ -258,12 +517,43 @@ func readStatusReport(r *pktline.Reader) (*statusReport, error) {
 	return report, nil
 }
 
+// parseOption parses a report-status-v2 option line.
+func (status *RefStatus) parseOption(line string, format githash.ObjectFormat) error {
+	key, value := line, ""
+	if i := strings.IndexByte(line, ' '); i != -1 {
+		key, value = line[:i], line[i+1:]
+	}
+	parseID := func() (githash.ObjectID, error) {
+		id, err := githash.ParseObjectID(value)
+		if err != nil {
+			return githash.ObjectID{}, fmt.Errorf("option %s: %w", key, err)
+		}
+		if id.ObjectFormat() != format {
+			return githash.ObjectID{}, fmt.Errorf("option %s: object ID is not %v", key, format)
+		}
+		return id, nil
+	}
+	var err error
+	switch key {
+	case "refname":
+		status.RewrittenRefName = githash.Ref(value)
+	case "old-oid":
+		status.OldID, err = parseID()
+	case "new-oid":
+		status.NewID, err = parseID()
+	case "forced-update":
+		status.ForcedUpdate = true
+	}
+	// Unknown options are ignored for forward compatibility.
+	return err
+}
+
 func (report *statusReport) isOK() bool {
 	if report.status != "" {
 		return false
 	}
-	for _, msg := range report.commands {
-		if msg != "" {
+	for _, cmd := range report.commands {
+		if !cmd.OK() {
 			return false
 		}
 	}
@@ -271,8 +561,17 @@ func (report *statusReport) isOK() bool {
 }
 
 func (report *statusReport) Error() string {
-	if report.status == "" {
-		return "remote: could not update refs"
+	if report.status != "" {
+		return "remote: " + report.status
+	}
+	var failed []*RefStatus
+	for _, cmd := range report.commands {
+		if !cmd.OK() {
+			failed = append(failed, cmd)
+		}
+	}
+	if len(failed) == 1 {
+		return fmt.Sprintf("remote: %s: %s", failed[0].RefName, failed[0].Error)
 	}
-	return "remote: " + report.status
+	return "remote: could not update refs"
 }
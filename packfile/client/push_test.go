@@ -17,16 +17,22 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
 	"gg-scm.io/pkg/git"
 	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/internal/pktline"
 	"gg-scm.io/pkg/git/object"
 	"gg-scm.io/pkg/git/packfile"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestPush(t *testing.T) {
@@ -68,7 +74,7 @@ func TestPush(t *testing.T) {
 				Message:    commitMessage,
 			}
 
-			remote, err := NewRemote(transport.getURL(t, dir), nil)
+			remote, err := NewRemote(transport.getURL(t, dir))
 			if err != nil {
 				t.Fatal("NewRemote:", err)
 			}
@@ -134,3 +140,275 @@ func TestPush(t *testing.T) {
 		})
 	}
 }
+
+func TestPushRequest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Hooks are shell scripts")
+	}
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+
+	for _, transport := range allTransportVariants(localGit.Exe()) {
+		t.Run(transport.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			g := git.Custom(dir, localGit, localGit)
+			if err := g.InitBare(ctx, "."); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.Run(ctx, "config", "receive.advertisePushOptions", "true"); err != nil {
+				t.Fatal(err)
+			}
+			const hook = "#!/bin/sh\n" +
+				"i=0\n" +
+				"while [ \"$i\" -lt \"${GIT_PUSH_OPTION_COUNT:-0}\" ]; do\n" +
+				"  eval \"echo \\\"push option: \\$GIT_PUSH_OPTION_$i\\\"\" >&2\n" +
+				"  i=$((i + 1))\n" +
+				"done\n"
+			if err := os.WriteFile(filepath.Join(dir, "hooks", "pre-receive"), []byte(hook), 0o777); err != nil {
+				t.Fatal(err)
+			}
+			remote, err := NewRemote(transport.getURL(t, dir))
+			if err != nil {
+				t.Fatal("NewRemote:", err)
+			}
+			commitID, pack := newTestPushPack(t)
+			mainRef := githash.BranchRef("main")
+			otherRef := githash.BranchRef("other")
+
+			t.Run("Options", func(t *testing.T) {
+				stream, err := remote.StartPush(ctx)
+				if err != nil {
+					t.Fatal("remote.StartPush:", err)
+				}
+				wantCaps := PushCapAtomic | PushCapOptions | PushCapReportStatusV2
+				if got := stream.Capabilities(); !got.Has(wantCaps) {
+					t.Errorf("stream.Capabilities() = %v; want to include %v", got, wantCaps)
+				}
+				progress := new(bytes.Buffer)
+				err = stream.WriteRequest(&PushRequest{
+					Commands: []*PushCommand{{RefName: mainRef, New: commitID}},
+					Atomic:   true,
+					Options:  []string{"ci.skip", "reviewer=octocat"},
+					Progress: progress,
+				})
+				if err != nil {
+					t.Error("PushStream.WriteRequest:", err)
+				}
+				if _, err := stream.Write(pack); err != nil {
+					t.Error("PushStream.Write:", err)
+				}
+				if err := stream.Close(); err != nil {
+					t.Error("PushStream.Close:", err)
+				}
+				for _, want := range []string{"push option: ci.skip\n", "push option: reviewer=octocat\n"} {
+					if !strings.Contains(progress.String(), want) {
+						t.Errorf("progress = %q; want to contain %q", progress, want)
+					}
+				}
+				want := []*RefStatus{{RefName: mainRef}}
+				if diff := cmp.Diff(want, stream.RefStatuses()); diff != "" {
+					t.Errorf("RefStatuses() (-want +got):\n%s", diff)
+				}
+			})
+
+			t.Run("AtomicFailure", func(t *testing.T) {
+				stream, err := remote.StartPush(ctx)
+				if err != nil {
+					t.Fatal("remote.StartPush:", err)
+				}
+				err = stream.WriteRequest(&PushRequest{
+					Commands: []*PushCommand{
+						{RefName: mainRef, Old: commitID},
+						// Fails because otherRef does not exist.
+						{RefName: otherRef, Old: commitID, New: commitID},
+					},
+					Atomic: true,
+				})
+				if err != nil {
+					t.Error("PushStream.WriteRequest:", err)
+				}
+				// The remote already has commitID, so send an empty packfile.
+				if err := packfile.NewWriter(stream, 0).Close(); err != nil {
+					t.Error("packfile.Writer.Close:", err)
+				}
+				if err := stream.Close(); err == nil {
+					t.Error("PushStream.Close did not return an error")
+				}
+				statuses := stream.RefStatuses()
+				if len(statuses) != 2 {
+					t.Fatalf("len(RefStatuses()) = %d; want 2", len(statuses))
+				}
+				for _, status := range statuses {
+					if status.OK() {
+						t.Errorf("%s updated", status.RefName)
+					}
+				}
+				if _, err := g.ParseRev(ctx, mainRef.String()); err != nil {
+					t.Errorf("%v was deleted: %v", mainRef, err)
+				}
+			})
+		})
+	}
+}
+
+func TestReadStatusReport(t *testing.T) {
+	id1 := githash.SHA1{0x01}.ObjectID()
+	id2 := githash.SHA1{0x02}.ObjectID()
+	tests := []struct {
+		name       string
+		data       []byte
+		want       []*RefStatus
+		wantOK     bool
+		wantsError bool
+	}{
+		{
+			name: "V1",
+			data: appendPktLines(nil,
+				"unpack ok\n",
+				"ok refs/heads/main\n",
+				"ng refs/heads/other non-fast-forward\n",
+			),
+			want: []*RefStatus{
+				{RefName: "refs/heads/main"},
+				{RefName: "refs/heads/other", Error: "non-fast-forward"},
+			},
+		},
+		{
+			name: "V2Options",
+			data: appendPktLines(nil,
+				"unpack ok\n",
+				"ok refs/for/main/topic\n",
+				"option refname refs/changes/1/1\n",
+				"option old-oid "+id1.String()+"\n",
+				"option new-oid "+id2.String()+"\n",
+				"option forced-update\n",
+				"ok refs/for/main/topic\n",
+				"option refname refs/changes/2/1\n",
+				"option new-oid "+id2.String()+"\n",
+				"ok refs/heads/main\n",
+			),
+			want: []*RefStatus{
+				{
+					RefName:          "refs/for/main/topic",
+					RewrittenRefName: "refs/changes/1/1",
+					OldID:            id1,
+					NewID:            id2,
+					ForcedUpdate:     true,
+				},
+				{
+					RefName:          "refs/for/main/topic",
+					RewrittenRefName: "refs/changes/2/1",
+					NewID:            id2,
+				},
+				{RefName: "refs/heads/main"},
+			},
+			wantOK: true,
+		},
+		{
+			name: "OptionAfterError",
+			data: appendPktLines(nil,
+				"unpack ok\n",
+				"ng refs/heads/main failed\n",
+				"option refname refs/heads/other\n",
+			),
+			wantsError: true,
+		},
+		{
+			name: "BadObjectID",
+			data: appendPktLines(nil,
+				"unpack ok\n",
+				"ok refs/heads/main\n",
+				"option new-oid xyzzy\n",
+			),
+			wantsError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := pktline.AppendFlush(test.data)
+			report, err := readStatusReport(pktline.NewReader(bytes.NewReader(data)), githash.SHA1Format)
+			if err != nil {
+				if !test.wantsError {
+					t.Fatal("readStatusReport:", err)
+				}
+				return
+			}
+			if test.wantsError {
+				t.Fatal("readStatusReport did not return an error")
+			}
+			if diff := cmp.Diff(test.want, report.commands); diff != "" {
+				t.Errorf("commands (-want +got):\n%s", diff)
+			}
+			if got := report.isOK(); got != test.wantOK {
+				t.Errorf("isOK() = %t; want %t", got, test.wantOK)
+			}
+		})
+	}
+}
+
+func appendPktLines(dst []byte, lines ...string) []byte {
+	for _, line := range lines {
+		dst = pktline.AppendString(dst, line)
+	}
+	return dst
+}
+
+// newTestPushPack returns a packfile containing a single commit
+// and the commit's object ID.
+func newTestPushPack(tb testing.TB) (githash.ObjectID, []byte) {
+	tb.Helper()
+	const fname = "foo.txt"
+	const fileContent = "Hello, World!\n"
+	const commitMessage = "Initial import"
+	const author object.User = "Octocat <octocat@example.com>"
+	commitTime := time.Date(2020, time.January, 9, 14, 50, 0, 0, time.FixedZone("-0800", -8*60*60))
+	blobObjectID, err := object.BlobSum(strings.NewReader(fileContent), int64(len(fileContent)))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	treeObject := object.Tree{
+		{
+			Name:     fname,
+			Mode:     object.ModePlain,
+			ObjectID: blobObjectID,
+		},
+	}
+	commitObject := &object.Commit{
+		Tree:       treeObject.SHA1(),
+		Author:     author,
+		AuthorTime: commitTime,
+		Committer:  author,
+		CommitTime: commitTime,
+		Message:    commitMessage,
+	}
+
+	buf := new(bytes.Buffer)
+	pw := packfile.NewWriter(buf, 3)
+	objects := []struct {
+		typ  packfile.ObjectType
+		data []byte
+	}{
+		{packfile.Blob, []byte(fileContent)},
+		{packfile.Tree, mustMarshalBinary(tb, treeObject)},
+		{packfile.Commit, mustMarshalBinary(tb, commitObject)},
+	}
+	for _, obj := range objects {
+		_, err := pw.WriteHeader(&packfile.Header{
+			Type: obj.typ,
+			Size: int64(len(obj.data)),
+		})
+		if err != nil {
+			tb.Fatal("WriteHeader:", err)
+		}
+		if _, err := pw.Write(obj.data); err != nil {
+			tb.Fatal("packfile.Writer.Write:", err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		tb.Fatal("packfile.Writer.Close:", err)
+	}
+	return commitObject.SHA1(), buf.Bytes()
+}
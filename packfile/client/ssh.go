@@ -0,0 +1,276 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"gg-scm.io/pkg/git/internal/pktline"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshRemote starts Git services over SSH, either with an in-process SSH
+// client or by running an ssh(1)-compatible command.
+type sshRemote struct {
+	host string
+	port string // empty for default
+	user string // empty for default
+	path string
+
+	// config is used to connect with an in-process client if non-nil.
+	config *ssh.ClientConfig
+	// shellCommand is the shell command to run if config is nil.
+	// If shellCommand is empty, then program is run directly.
+	shellCommand string
+	program      string
+}
+
+func newSSHRemote(u *url.URL, opts *Options) (*sshRemote, error) {
+	r := &sshRemote{
+		host: u.Hostname(),
+		port: u.Port(),
+		user: u.User.Username(),
+		path: u.Path,
+	}
+	if r.host == "" {
+		return nil, errors.New("missing host")
+	}
+	// Arguments starting with a dash would be interpreted as flags by ssh(1).
+	if strings.HasPrefix(r.host, "-") {
+		return nil, fmt.Errorf("invalid host %q", r.host)
+	}
+	if strings.HasPrefix(r.user, "-") {
+		return nil, fmt.Errorf("invalid user %q", r.user)
+	}
+	// Like Git, treat ssh://example.com/~user/foo as relative to user's home.
+	if strings.HasPrefix(r.path, "/~") {
+		r.path = r.path[1:]
+	}
+	if r.path == "" {
+		return nil, errors.New("missing path")
+	}
+
+	if opts != nil && opts.SSHConfig != nil {
+		r.config = opts.SSHConfig
+		return r, nil
+	}
+	if opts != nil && opts.SSHCommand != "" {
+		r.shellCommand = opts.SSHCommand
+	} else if cmd := os.Getenv("GIT_SSH_COMMAND"); cmd != "" {
+		r.shellCommand = cmd
+	} else if prog := os.Getenv("GIT_SSH"); prog != "" {
+		r.program = prog
+	} else {
+		r.program = "ssh"
+	}
+	return r, nil
+}
+
+// remoteCommand returns the shell command that starts the given service.
+func (r *sshRemote) remoteCommand(service string) string {
+	return service + " " + shellQuote(r.path)
+}
+
+func (r *sshRemote) dial(ctx context.Context, service string, extraParams string) (serviceConn, error) {
+	if r.config != nil {
+		return r.dialClient(ctx, service, extraParams)
+	}
+	return r.runCommand(ctx, service, extraParams)
+}
+
+// runCommand starts the service using an ssh(1)-compatible command.
+func (r *sshRemote) runCommand(ctx context.Context, service string, extraParams string) (serviceConn, error) {
+	var args []string
+	if r.port != "" {
+		args = append(args, "-p", r.port)
+	}
+	if extraParams != "" {
+		args = append(args, "-o", "SendEnv=GIT_PROTOCOL")
+	}
+	if r.user != "" {
+		args = append(args, r.user+"@"+r.host)
+	} else {
+		args = append(args, r.host)
+	}
+	args = append(args, r.remoteCommand(service))
+
+	var c *exec.Cmd
+	if r.shellCommand != "" {
+		// Same as Git: pass arguments to the shell command as positional parameters.
+		c = exec.Command("sh", append([]string{"-c", r.shellCommand + ` "$@"`, r.shellCommand}, args...)...)
+	} else {
+		c = exec.Command(r.program, args...)
+	}
+	if extraParams != "" {
+		c.Env = append(os.Environ(), "GIT_PROTOCOL="+extraParams)
+	}
+	return startProcessConn(ctx, c, "ssh "+r.host+" "+service)
+}
+
+// dialClient starts the service using an in-process SSH client.
+func (r *sshRemote) dialClient(ctx context.Context, service string, extraParams string) (_ serviceConn, err error) {
+	errPrefix := "ssh " + r.host + " " + service
+	port := r.port
+	if port == "" {
+		port = "22"
+	}
+	addr := net.JoinHostPort(r.host, port)
+	config := *r.config
+	if r.user != "" {
+		config.User = r.user
+	}
+
+	netConn, err := new(net.Dialer).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	conn := &sshConn{
+		errPrefix: errPrefix,
+		done:      make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			netConn.Close()
+		case <-conn.done:
+		}
+	}()
+	defer func() {
+		if err != nil {
+			netConn.Close()
+			close(conn.done)
+		}
+	}()
+	clientConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, &config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	conn.client = ssh.NewClient(clientConn, chans, reqs)
+	conn.session, err = conn.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	if extraParams != "" {
+		// Servers may reject environment variables, in which case the
+		// service will fall back to protocol version 0.
+		conn.session.Setenv("GIT_PROTOCOL", extraParams)
+	}
+	conn.stdin, err = conn.session.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	conn.stdout, err = conn.session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	if err := conn.session.Start(r.remoteCommand(service)); err != nil {
+		return nil, fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	return conn, nil
+}
+
+// sshConn is a serviceConn to a command running in an SSH session.
+type sshConn struct {
+	errPrefix string
+	client    *ssh.Client
+	session   *ssh.Session
+	stdin     io.WriteCloser
+	stdout    io.Reader
+	wrote     bool
+
+	done      chan struct{} // closed when the connection is closed
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func (conn *sshConn) Read(p []byte) (int, error) {
+	n, err := conn.stdout.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("%s: %w", conn.errPrefix, err)
+	}
+	return n, err
+}
+
+func (conn *sshConn) Write(p []byte) (int, error) {
+	n, err := conn.stdin.Write(p)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", conn.errPrefix, err)
+	}
+	if n > 0 {
+		conn.wrote = true
+	}
+	return n, err
+}
+
+func (conn *sshConn) CloseWrite() error {
+	return conn.stdin.Close()
+}
+
+func (conn *sshConn) Close() error {
+	conn.closeOnce.Do(func() {
+		if !conn.wrote {
+			conn.stdin.Write(pktline.AppendFlush(nil))
+		}
+		conn.stdin.Close()
+		if err := conn.session.Wait(); err != nil {
+			conn.closeErr = fmt.Errorf("%s: %w", conn.errPrefix, err)
+		}
+		conn.client.Close()
+		close(conn.done)
+	})
+	return conn.closeErr
+}
+
+func (conn *sshConn) abort() {
+	conn.closeOnce.Do(func() {
+		if !conn.wrote {
+			conn.stdin.Write(pktline.AppendFlush(nil))
+		}
+		conn.stdin.Close()
+		conn.client.Close()
+		conn.session.Wait()
+		close(conn.done)
+	})
+}
+
+// shellQuote quotes s for use as a single argument in a POSIX shell command,
+// the same way Git does for the remote command it sends over SSH.
+func shellQuote(s string) string {
+	sb := new(strings.Builder)
+	sb.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'', '!':
+			sb.WriteString(`'\`)
+			sb.WriteByte(c)
+			sb.WriteByte('\'')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('\'')
+	return sb.String()
+}
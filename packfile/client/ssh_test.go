@@ -0,0 +1,311 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeSSHCommand is an ssh(1) replacement that ignores all arguments except
+// the last and runs it as a local shell command.
+const fakeSSHCommand = `sh -c 'while [ $# -gt 1 ]; do shift; done; exec sh -c "$1"' ssh`
+
+func TestNewSSHRemote(t *testing.T) {
+	tests := []struct {
+		url     string
+		host    string
+		port    string
+		user    string
+		path    string
+		wantErr bool
+	}{
+		{
+			url:  "ssh://example.com/foo.git",
+			host: "example.com",
+			path: "/foo.git",
+		},
+		{
+			url:  "ssh://git@example.com:2222/foo.git",
+			host: "example.com",
+			port: "2222",
+			user: "git",
+			path: "/foo.git",
+		},
+		{
+			url:  "git@example.com:foo/bar.git",
+			host: "example.com",
+			user: "git",
+			path: "/foo/bar.git",
+		},
+		{
+			url:  "ssh://example.com/~octocat/foo.git",
+			host: "example.com",
+			path: "~octocat/foo.git",
+		},
+		{
+			url:     "ssh://-oProxyCommand=evil/foo.git",
+			wantErr: true,
+		},
+		{
+			url:     "ssh://-oProxyCommand=evil@example.com/foo.git",
+			wantErr: true,
+		},
+		{
+			url:     "ssh://example.com",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		u, err := ParseURL(test.url)
+		if err != nil {
+			t.Errorf("ParseURL(%q): %v", test.url, err)
+			continue
+		}
+		r, err := newSSHRemote(u, nil)
+		if err != nil {
+			if !test.wantErr {
+				t.Errorf("newSSHRemote(%q, nil): %v", test.url, err)
+			}
+			continue
+		}
+		if test.wantErr {
+			t.Errorf("newSSHRemote(%q, nil) did not return an error", test.url)
+			continue
+		}
+		if r.host != test.host || r.port != test.port || r.user != test.user || r.path != test.path {
+			t.Errorf("newSSHRemote(%q, nil) = {host: %q, port: %q, user: %q, path: %q}; want {host: %q, port: %q, user: %q, path: %q}",
+				test.url, r.host, r.port, r.user, r.path, test.host, test.port, test.user, test.path)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found:", err)
+	}
+	tests := []string{
+		"",
+		"/foo/bar.git",
+		"foo bar",
+		"it's",
+		"'",
+		"hello!",
+		`back\slash`,
+		"$HOME",
+		"`ls`",
+	}
+	for _, s := range tests {
+		out, err := exec.Command("sh", "-c", "printf '%s' "+shellQuote(s)).Output()
+		if err != nil {
+			t.Errorf("shellQuote(%q) = %s; shell error: %v", s, shellQuote(s), err)
+			continue
+		}
+		if got := string(out); got != s {
+			t.Errorf("sh -c \"printf '%%s' %s\" = %q; want %q", shellQuote(s), got, s)
+		}
+	}
+}
+
+// serveSSH starts an in-process SSH server that runs the Git services on
+// the local filesystem. It returns the server's address and a client
+// configuration that trusts the server.
+func serveSSH(tb testing.TB, gitExe string) (addr string, config *ssh.ClientConfig) {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	_, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), clientSigner.PublicKey().Marshal()) {
+				return nil, errors.New("unknown public key")
+			}
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	srv := &sshTestServer{
+		gitExe: gitExe,
+		config: serverConfig,
+		conns:  make(map[net.Conn]struct{}),
+	}
+	srv.wg.Add(1)
+	go func() {
+		defer srv.wg.Done()
+		srv.serve(l)
+	}()
+	tb.Cleanup(func() {
+		l.Close()
+		srv.mu.Lock()
+		for c := range srv.conns {
+			c.Close()
+		}
+		srv.mu.Unlock()
+		srv.wg.Wait()
+	})
+	return l.Addr().String(), &ssh.ClientConfig{
+		User:            "git",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(clientSigner)},
+		HostKeyCallback: ssh.FixedHostKey(hostSigner.PublicKey()),
+	}
+}
+
+type sshTestServer struct {
+	gitExe string
+	config *ssh.ServerConfig
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func (srv *sshTestServer) serve(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		srv.mu.Lock()
+		srv.conns[c] = struct{}{}
+		srv.mu.Unlock()
+		srv.wg.Add(1)
+		go func() {
+			defer srv.wg.Done()
+			srv.handleConn(c)
+			srv.mu.Lock()
+			delete(srv.conns, c)
+			srv.mu.Unlock()
+			c.Close()
+		}()
+	}
+}
+
+func (srv *sshTestServer) handleConn(c net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(c, srv.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, chReqs, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.handleSession(ch, chReqs)
+		}()
+	}
+}
+
+func (srv *sshTestServer) handleSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+	var env []string
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for req := range reqs {
+		switch req.Type {
+		case "env":
+			var payload struct{ Name, Value string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			env = append(env, payload.Name+"="+payload.Value)
+			req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			c, err := srv.command(payload.Command)
+			if err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			c.Env = append(os.Environ(), env...)
+			c.Stdin = ch
+			c.Stdout = ch
+			c.Stderr = ch.Stderr()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var status uint32
+				if err := c.Run(); err != nil {
+					status = 1
+				}
+				ch.CloseWrite()
+				ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+				ch.Close()
+			}()
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// command parses a command sent by sshRemote.
+func (srv *sshTestServer) command(cmd string) (*exec.Cmd, error) {
+	service, arg, ok := strings.Cut(cmd, " ")
+	if !ok || (service != uploadPackService && service != receivePackService) {
+		return nil, fmt.Errorf("unknown command %q", cmd)
+	}
+	if len(arg) < 2 || arg[0] != '\'' || arg[len(arg)-1] != '\'' {
+		return nil, fmt.Errorf("unquoted argument in %q", cmd)
+	}
+	path := arg[1 : len(arg)-1]
+	path = strings.ReplaceAll(path, `'\''`, `'`)
+	path = strings.ReplaceAll(path, `'\!'`, `!`)
+	return exec.Command(srv.gitExe, strings.TrimPrefix(service, "git-"), path), nil
+}
@@ -260,6 +260,7 @@ type Undeltifier struct {
 type UndeltifyOptions struct {
 	// Index allows the undeltify operation to resolve delta object base ID
 	// references within the same packfile.
+	// The packfile is assumed to use the index's object format.
 	Index *Index
 }
 
@@ -322,8 +323,9 @@ func walkDeltaChain(f ByteReadSeeker, offset int64, opts *UndeltifyOptions) (*He
 	if _, err := f.Seek(offset, io.SeekStart); err != nil {
 		return nil, nil, fmt.Errorf("undeltify object at %d: %w", offset, err)
 	}
+	format := opts.Index.ObjectFormat()
 	brc := &byteReaderCounter{r: f}
-	hdr, err := readObjectHeader(offset, brc)
+	hdr, err := readObjectHeader(offset, brc, format)
 	if err != nil {
 		return nil, nil, fmt.Errorf("undeltify object at %d: %w", offset, err)
 	}
@@ -342,7 +344,7 @@ func walkDeltaChain(f ByteReadSeeker, offset int64, opts *UndeltifyOptions) (*He
 			return nil, nil, fmt.Errorf("undeltify object at %d: %w", hdr.Offset, err)
 		}
 		brc.n = 0
-		hdr, err = readObjectHeader(baseOffset, brc)
+		hdr, err = readObjectHeader(baseOffset, brc, format)
 		if err != nil {
 			return nil, nil, fmt.Errorf("undeltify object at %d: %w", hdr.Offset, err)
 		}
@@ -0,0 +1,247 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package packfile
+
+import "math/bits"
+
+// Delta encoder parameters.
+const (
+	// deltaBlockSize is the number of bytes in each indexed block of the base
+	// object and the size of the rolling hash window.
+	deltaBlockSize = 16
+	// maxDeltaChain is the maximum number of candidate blocks examined
+	// for each position in the target object.
+	maxDeltaChain = 64
+	// maxCopySize is the maximum number of bytes copied by a single
+	// copy instruction. Larger sizes are valid in the encoding, but Git
+	// limits copies to this size for compatibility with older readers.
+	maxCopySize = 0x10000
+	// maxInsertSize is the maximum number of bytes added by a single
+	// insert instruction.
+	maxInsertSize = 0x7f
+	// maxDeltaBaseSize is the largest base object that can be addressed
+	// by a copy instruction's 32-bit offset.
+	maxDeltaBaseSize = 1<<32 - 1
+)
+
+// deltaHashMultiplier is the multiplier of the polynomial rolling hash.
+const deltaHashMultiplier = 0x01000193
+
+// deltaHashOut is deltaHashMultiplier^(deltaBlockSize-1), the factor
+// used to remove the oldest byte from the rolling hash.
+var deltaHashOut = func() uint32 {
+	x := uint32(1)
+	for i := 0; i < deltaBlockSize-1; i++ {
+		x *= deltaHashMultiplier
+	}
+	return x
+}()
+
+// A DeltaIndex is an index of a base object's content used to compute deltas
+// from the base object to other objects. Computing the index is the most
+// expensive part of producing a delta, so a DeltaIndex should be reused when
+// computing deltas from the same base object to many target objects.
+// The index retains a reference to the base object's bytes, so the caller
+// must not modify them while the DeltaIndex is in use.
+//
+// The delta format is described in
+// https://git-scm.com/docs/pack-format#_deltified_representation
+type DeltaIndex struct {
+	base []byte
+	mask uint32
+	// heads maps a hash bucket to 1 + the index of the most recently
+	// indexed block whose hash falls into the bucket (0 if none).
+	heads []int32
+	// next maps a block index to 1 + the index of the previous block
+	// in the same hash bucket (0 if none).
+	next []int32
+}
+
+// NewDeltaIndex returns a new index of the given base object.
+func NewDeltaIndex(base []byte) *DeltaIndex {
+	idx := &DeltaIndex{base: base}
+	nblocks := len(base) / deltaBlockSize
+	if nblocks == 0 || int64(len(base)) > maxDeltaBaseSize {
+		// Either there's nothing to index or copy instructions can't address
+		// the base object. Deltas will consist solely of insert instructions.
+		return idx
+	}
+	nbuckets := 1 << bits.Len(uint(nblocks))
+	idx.mask = uint32(nbuckets - 1)
+	idx.heads = make([]int32, nbuckets)
+	idx.next = make([]int32, nblocks)
+	// Index blocks from back to front so that the chains visit earlier
+	// blocks first, which produces smaller copy offsets.
+	for i := nblocks - 1; i >= 0; i-- {
+		start := i * deltaBlockSize
+		bucket := deltaHash(base[start:start+deltaBlockSize]) & idx.mask
+		idx.next[i] = idx.heads[bucket]
+		idx.heads[bucket] = int32(i + 1)
+	}
+	return idx
+}
+
+// BaseSize returns the size of the indexed base object in bytes.
+func (idx *DeltaIndex) BaseSize() int {
+	return len(idx.base)
+}
+
+// AppendDelta appends the delta instructions that produce target from the
+// indexed base object to dst. If maxSize is positive and the delta would be
+// larger than maxSize bytes, AppendDelta stops early and returns dst unmodified
+// and false.
+func (idx *DeltaIndex) AppendDelta(dst []byte, target []byte, maxSize int) (_ []byte, ok bool) {
+	start := len(dst)
+	dst = appendVarint(dst, uint64(len(idx.base)))
+	dst = appendVarint(dst, uint64(len(target)))
+	tooBig := func() bool {
+		return maxSize > 0 && len(dst)-start > maxSize
+	}
+
+	insertStart := 0
+	pos := 0
+	var h uint32
+	if len(target) >= deltaBlockSize && len(idx.heads) > 0 {
+		h = deltaHash(target[:deltaBlockSize])
+	}
+	for len(idx.heads) > 0 && pos+deltaBlockSize <= len(target) {
+		matchStart, matchLen := idx.findMatch(h, target, pos)
+		if matchLen < deltaBlockSize {
+			// No match. Advance the rolling hash by one byte.
+			if pos+deltaBlockSize < len(target) {
+				h = (h-uint32(target[pos])*deltaHashOut)*deltaHashMultiplier + uint32(target[pos+deltaBlockSize])
+			}
+			pos++
+			continue
+		}
+
+		// Extend the match backward into bytes that would otherwise be inserted.
+		for matchStart > 0 && pos > insertStart && idx.base[matchStart-1] == target[pos-1] {
+			matchStart--
+			pos--
+			matchLen++
+		}
+		dst = appendInsertInstructions(dst, target[insertStart:pos])
+		dst = appendCopyInstructions(dst, matchStart, matchLen)
+		if tooBig() {
+			return dst[:start], false
+		}
+		pos += matchLen
+		insertStart = pos
+		if pos+deltaBlockSize <= len(target) {
+			h = deltaHash(target[pos : pos+deltaBlockSize])
+		}
+	}
+	dst = appendInsertInstructions(dst, target[insertStart:])
+	if tooBig() {
+		return dst[:start], false
+	}
+	return dst, true
+}
+
+// findMatch returns the longest match in the base object for the bytes of
+// target starting at pos, whose first deltaBlockSize bytes hash to h.
+func (idx *DeltaIndex) findMatch(h uint32, target []byte, pos int) (matchStart, matchLen int) {
+	chain := 0
+	for i := idx.heads[h&idx.mask]; i != 0 && chain < maxDeltaChain; i, chain = idx.next[i-1], chain+1 {
+		candidate := int(i-1) * deltaBlockSize
+		n := commonPrefixLen(idx.base[candidate:], target[pos:])
+		if n > matchLen {
+			matchStart, matchLen = candidate, n
+			if pos+n == len(target) {
+				break
+			}
+		}
+	}
+	return matchStart, matchLen
+}
+
+// AppendDelta appends the delta instructions that produce target from base
+// to dst. It is a shorthand for NewDeltaIndex(base).AppendDelta(dst, target, 0).
+func AppendDelta(dst []byte, base, target []byte) []byte {
+	dst, _ = NewDeltaIndex(base).AppendDelta(dst, target, 0)
+	return dst
+}
+
+func deltaHash(block []byte) uint32 {
+	var h uint32
+	for _, b := range block {
+		h = h*deltaHashMultiplier + uint32(b)
+	}
+	return h
+}
+
+func commonPrefixLen(a, b []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// appendInsertInstructions appends instructions to add data to the target.
+// See https://git-scm.com/docs/pack-format#_instruction_to_add_new_data
+func appendInsertInstructions(dst []byte, data []byte) []byte {
+	for len(data) > 0 {
+		n := len(data)
+		if n > maxInsertSize {
+			n = maxInsertSize
+		}
+		dst = append(dst, byte(n))
+		dst = append(dst, data[:n]...)
+		data = data[n:]
+	}
+	return dst
+}
+
+// appendCopyInstructions appends instructions to copy data from the base object.
+// See https://git-scm.com/docs/pack-format#_instruction_to_copy_from_base_object
+func appendCopyInstructions(dst []byte, offset, size int) []byte {
+	for size > 0 {
+		n := size
+		if n > maxCopySize {
+			n = maxCopySize
+		}
+		instructionIndex := len(dst)
+		instruction := byte(0x80)
+		dst = append(dst, 0)
+		for i := 0; i < 4; i++ {
+			if b := byte(offset >> (8 * i)); b != 0 {
+				instruction |= 1 << i
+				dst = append(dst, b)
+			}
+		}
+		if n != maxCopySize {
+			// A size of zero is interpreted as maxCopySize.
+			for i := 0; i < 3; i++ {
+				if b := byte(n >> (8 * i)); b != 0 {
+					instruction |= 1 << (4 + i)
+					dst = append(dst, b)
+				}
+			}
+		}
+		dst[instructionIndex] = instruction
+		offset += n
+		size -= n
+	}
+	return dst
+}
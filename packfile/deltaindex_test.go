@@ -0,0 +1,124 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package packfile
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestAppendDelta(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 100000)
+	rng.Read(random)
+	var lines strings.Builder
+	for i := 0; i < 1000; i++ {
+		lines.WriteString("The quick brown fox jumps over the lazy dog.\n")
+	}
+	edited := []byte(lines.String())
+	copy(edited[500:], "EDITED")
+	edited = append(edited[:30000:30000], append([]byte("Inserted text in the middle\n"), edited[30000:]...)...)
+
+	tests := []struct {
+		name   string
+		base   []byte
+		target []byte
+		// maxDeltaSize is an upper bound on the expected delta size
+		// or zero if the delta size should not be checked.
+		maxDeltaSize int
+	}{
+		{name: "Empty"},
+		{name: "EmptyBase", target: []byte("Hello, World!\n")},
+		{name: "EmptyTarget", base: []byte("Hello, World!\n")},
+		{name: "Short", base: []byte("Hello!"), target: []byte("Hello, delta\n")},
+		{name: "Identical", base: random, target: random, maxDeltaSize: 64},
+		{name: "Prefix", base: random, target: random[:50000], maxDeltaSize: 32},
+		{name: "Suffix", base: random, target: random[50000:], maxDeltaSize: 32},
+		{name: "Unrelated", base: random[:50000], target: random[50000:]},
+		{
+			name:         "Appended",
+			base:         random[:50000],
+			target:       append(random[:50000:50000], "trailer"...),
+			maxDeltaSize: 48,
+		},
+		{name: "Edited", base: []byte(lines.String()), target: edited, maxDeltaSize: 128},
+		{name: "Reversed", base: edited, target: []byte(lines.String()), maxDeltaSize: 128},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delta := AppendDelta(nil, test.base, test.target)
+			if test.maxDeltaSize > 0 && len(delta) > test.maxDeltaSize {
+				t.Errorf("len(delta) = %d; want <=%d", len(delta), test.maxDeltaSize)
+			}
+			got := new(bytes.Buffer)
+			d := NewDeltaReader(bytes.NewReader(test.base), bytes.NewReader(delta))
+			if _, err := io.Copy(got, d); err != nil {
+				t.Fatal("Apply delta:", err)
+			}
+			if !bytes.Equal(got.Bytes(), test.target) {
+				t.Errorf("applying delta produced %d bytes (want %d), content differs", got.Len(), len(test.target))
+			}
+			if n, err := DeltaObjectSize(bytes.NewReader(delta)); n != int64(len(test.target)) || err != nil {
+				t.Errorf("DeltaObjectSize(...) = %d, %v; want %d, <nil>", n, err, len(test.target))
+			}
+		})
+	}
+}
+
+func TestDeltaIndexMaxSize(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	base := make([]byte, 1000)
+	rng.Read(base)
+	target := make([]byte, 1000)
+	rng.Read(target)
+	idx := NewDeltaIndex(base)
+	dst := []byte("prefix")
+	got, ok := idx.AppendDelta(dst, target, 500)
+	if ok {
+		t.Errorf("AppendDelta(..., 500) for unrelated data succeeded with %d bytes", len(got)-len(dst))
+	}
+	if string(got) != "prefix" {
+		t.Errorf("AppendDelta(...) = %q; want %q", got, "prefix")
+	}
+	got, ok = idx.AppendDelta(dst, base, 500)
+	if !ok {
+		t.Error("AppendDelta(..., 500) for identical data failed")
+	}
+	if !bytes.HasPrefix(got, dst) {
+		t.Errorf("AppendDelta(...) = %q; want to start with %q", got, dst)
+	}
+}
+
+func BenchmarkAppendDelta(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	base := make([]byte, 1<<20)
+	rng.Read(base)
+	target := append([]byte(nil), base...)
+	for i := 0; i < 100; i++ {
+		target[rng.Intn(len(target))] ^= 0xff
+	}
+	b.SetBytes(int64(len(target)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		buf = AppendDelta(buf[:0], base, target)
+	}
+}
@@ -50,7 +50,7 @@ func Example() {
 	}
 
 	// Find the position of an object.
-	commitID, err := githash.ParseSHA1("45c3b785642598057cf65b79fd05586dae5cba10")
+	commitID, err := githash.ParseObjectID("45c3b785642598057cf65b79fd05586dae5cba10")
 	if err != nil {
 		// handle error
 	}
@@ -18,7 +18,6 @@ package packfile
 
 import (
 	"bytes"
-	"crypto/sha1"
 	"fmt"
 	"io"
 	"sort"
@@ -43,7 +42,8 @@ head room.
 // is treated the same as the Index for an empty packfile.
 type Index struct {
 	// ObjectIDs is a sorted list of object IDs in the packfile.
-	ObjectIDs []githash.SHA1
+	// All object IDs must use the same object format as PackfileSHA1.
+	ObjectIDs []githash.ObjectID
 	// Offsets holds the offsets from the start of the packfile that an object
 	// header starts at. The i'th element of Offsets corresponds with the
 	// i'th element of ObjectIDs.
@@ -53,8 +53,18 @@ type Index struct {
 	// corresponds with the i'th element of ObjectIDs. Version 1 index files do
 	// not have this information.
 	PackedChecksums []uint32
-	// PackfileSHA1 is a copy of the SHA-1 hash present at the end of the packfile.
-	PackfileSHA1 githash.SHA1
+	// PackfileSHA1 is a copy of the hash present at the end of the packfile.
+	// Despite its name, the hash uses the packfile's object format,
+	// which determines the object format of the index.
+	PackfileSHA1 githash.ObjectID
+}
+
+// ObjectFormat returns the object format of the index.
+func (idx *Index) ObjectFormat() githash.ObjectFormat {
+	if idx == nil {
+		return githash.SHA1Format
+	}
+	return idx.PackfileSHA1.ObjectFormat()
 }
 
 var indexV2Magic = [...]byte{
@@ -63,9 +73,16 @@ var indexV2Magic = [...]byte{
 }
 
 // ReadIndex parses a packfile index file from r. It performs no buffering and
-// will not read more bytes than necessary.
+// will not read more bytes than necessary. The index is assumed to use SHA-1
+// object IDs.
 func ReadIndex(r io.Reader) (*Index, error) {
-	h := sha1.New()
+	return ReadIndexFormat(r, githash.SHA1Format)
+}
+
+// ReadIndexFormat parses a packfile index file that uses the given object
+// format from r. It is otherwise identical to [ReadIndex].
+func ReadIndexFormat(r io.Reader, format githash.ObjectFormat) (*Index, error) {
+	h := format.New()
 	r = io.TeeReader(r, h)
 
 	first := make([]byte, len(indexV2Magic))
@@ -76,9 +93,9 @@ func ReadIndex(r io.Reader) (*Index, error) {
 	var idx *Index
 	var err error
 	if bytes.Equal(first, indexV2Magic[:]) {
-		idx, err = readIndexV2(r)
+		idx, err = readIndexV2(r, format)
 	} else {
-		idx, err = readIndexV1(io.MultiReader(bytes.NewReader(first), r))
+		idx, err = readIndexV1(io.MultiReader(bytes.NewReader(first), r), format)
 	}
 	if err != nil {
 		return nil, err
@@ -97,6 +114,7 @@ func ReadIndex(r io.Reader) (*Index, error) {
 }
 
 // UnmarshalBinary decodes Git's packfile index format into idx.
+// The index is assumed to use SHA-1 object IDs.
 func (idx *Index) UnmarshalBinary(data []byte) error {
 	newIndex, err := ReadIndex(bytes.NewReader(data))
 	if err != nil {
@@ -108,22 +126,23 @@ func (idx *Index) UnmarshalBinary(data []byte) error {
 
 const largeOffsetEntryMask = 1 << 31
 
-func readIndexV2(r io.Reader) (*Index, error) {
+func readIndexV2(r io.Reader, format githash.ObjectFormat) (*Index, error) {
 	nobjs, err := readIndexObjectCount(r)
 	if err != nil {
 		return nil, fmt.Errorf("read packfile index: %w", err)
 	}
 	idx := &Index{
-		ObjectIDs:       make([]githash.SHA1, 0, int(nobjs)),
+		ObjectIDs:       make([]githash.ObjectID, 0, int(nobjs)),
 		Offsets:         make([]int64, 0, int(nobjs)),
 		PackedChecksums: make([]uint32, 0, int(nobjs)),
 	}
 	for len(idx.ObjectIDs) < int(nobjs) {
 		i := len(idx.ObjectIDs)
-		idx.ObjectIDs = idx.ObjectIDs[:i+1]
-		if _, err := readFull(r, idx.ObjectIDs[i][:]); err != nil {
+		id, err := readObjectID(r, format)
+		if err != nil {
 			return nil, fmt.Errorf("read packfile index: object ids: %w", err)
 		}
+		idx.ObjectIDs = append(idx.ObjectIDs, id)
 		if i > 0 && !idx.Less(i-1, i) {
 			return nil, fmt.Errorf("read packfile index: object ids: not sorted")
 		}
@@ -172,19 +191,20 @@ func readIndexV2(r io.Reader) (*Index, error) {
 		}
 		idx.Offsets[i] = int64(off)
 	}
-	if _, err := readFull(r, idx.PackfileSHA1[:]); err != nil {
-		return nil, fmt.Errorf("read packfile index: packfile sha-1: %w", err)
+	idx.PackfileSHA1, err = readObjectID(r, format)
+	if err != nil {
+		return nil, fmt.Errorf("read packfile index: packfile hash: %w", err)
 	}
 	return idx, nil
 }
 
-func readIndexV1(r io.Reader) (*Index, error) {
+func readIndexV1(r io.Reader, format githash.ObjectFormat) (*Index, error) {
 	nobjs, err := readIndexObjectCount(r)
 	if err != nil {
 		return nil, fmt.Errorf("read packfile index: %w", err)
 	}
 	idx := &Index{
-		ObjectIDs: make([]githash.SHA1, 0, int(nobjs)),
+		ObjectIDs: make([]githash.ObjectID, 0, int(nobjs)),
 		Offsets:   make([]int64, 0, int(nobjs)),
 	}
 	var offBuf [4]byte
@@ -195,20 +215,35 @@ func readIndexV1(r io.Reader) (*Index, error) {
 		idx.Offsets = append(idx.Offsets, int64(ntohl(offBuf[:])))
 
 		i := len(idx.ObjectIDs)
-		idx.ObjectIDs = idx.ObjectIDs[:i+1]
-		if _, err := readFull(r, idx.ObjectIDs[i][:]); err != nil {
+		id, err := readObjectID(r, format)
+		if err != nil {
 			return nil, fmt.Errorf("read packfile index: entries: %w", err)
 		}
+		idx.ObjectIDs = append(idx.ObjectIDs, id)
 		if i > 0 && !idx.Less(i-1, i) {
 			return nil, fmt.Errorf("read packfile index: entries: not sorted")
 		}
 	}
-	if _, err := readFull(r, idx.PackfileSHA1[:]); err != nil {
-		return nil, fmt.Errorf("read packfile index: packfile sha-1: %w", err)
+	idx.PackfileSHA1, err = readObjectID(r, format)
+	if err != nil {
+		return nil, fmt.Errorf("read packfile index: packfile hash: %w", err)
 	}
 	return idx, nil
 }
 
+// readObjectID reads a binary object ID of the given format from r.
+func readObjectID(r io.Reader, format githash.ObjectFormat) (githash.ObjectID, error) {
+	var buf [githash.SHA256Size]byte
+	if _, err := readFull(r, buf[:format.Size()]); err != nil {
+		return githash.ObjectID{}, err
+	}
+	var id githash.ObjectID
+	if err := id.UnmarshalBinary(buf[:format.Size()]); err != nil {
+		return githash.ObjectID{}, err
+	}
+	return id, nil
+}
+
 const fanOutEntryCount = 256
 
 func readIndexObjectCount(r io.Reader) (uint32, error) {
@@ -235,7 +270,7 @@ func readFull(r io.Reader, buf []byte) (int, error) {
 // EncodeV2 writes idx in Git's packfile index version 2 format.
 func (idx *Index) EncodeV2(w io.Writer) error {
 	if idx == nil {
-		idx = &Index{PackfileSHA1: emptyPackfileSHA1()}
+		idx = &Index{PackfileSHA1: emptyPackfileHash(githash.SHA1Format)}
 	}
 	if err := idx.validate(); err != nil {
 		return fmt.Errorf("write packfile index: %w", err)
@@ -244,7 +279,7 @@ func (idx *Index) EncodeV2(w io.Writer) error {
 		return fmt.Errorf("number of checksums (%d) different than number of objects (%d)",
 			len(idx.PackedChecksums), len(idx.ObjectIDs))
 	}
-	h := sha1.New()
+	h := idx.ObjectFormat().New()
 	wh := io.MultiWriter(w, h)
 	if _, err := wh.Write(indexV2Magic[:]); err != nil {
 		return fmt.Errorf("write packfile index: %w", err)
@@ -252,12 +287,12 @@ func (idx *Index) EncodeV2(w io.Writer) error {
 	if err := idx.encodeFanOut(wh); err != nil {
 		return fmt.Errorf("write packfile index: %w", err)
 	}
-	for i := range idx.ObjectIDs {
-		if _, err := wh.Write(idx.ObjectIDs[i][:]); err != nil {
+	var buf [githash.SHA256Size]byte
+	for _, id := range idx.ObjectIDs {
+		if _, err := wh.Write(id.AppendBinary(buf[:0])); err != nil {
 			return fmt.Errorf("write packfile index: %w", err)
 		}
 	}
-	var buf [githash.SHA1Size]byte
 	for _, checksum := range idx.PackedChecksums {
 		htonl(buf[:], checksum)
 		if _, err := wh.Write(buf[:4]); err != nil {
@@ -289,7 +324,7 @@ func (idx *Index) EncodeV2(w io.Writer) error {
 			}
 		}
 	}
-	if _, err := wh.Write(idx.PackfileSHA1[:]); err != nil {
+	if _, err := wh.Write(idx.PackfileSHA1.AppendBinary(buf[:0])); err != nil {
 		return fmt.Errorf("write packfile index: %w", err)
 	}
 	if _, err := w.Write(h.Sum(buf[:0])); err != nil {
@@ -303,12 +338,12 @@ func (idx *Index) EncodeV2(w io.Writer) error {
 // store PackedChecksums and do not support packfiles larger than 4 GiB.
 func (idx *Index) EncodeV1(w io.Writer) error {
 	if idx == nil {
-		idx = &Index{PackfileSHA1: emptyPackfileSHA1()}
+		idx = &Index{PackfileSHA1: emptyPackfileHash(githash.SHA1Format)}
 	}
 	if err := idx.validate(); err != nil {
 		return fmt.Errorf("write packfile index: %w", err)
 	}
-	h := sha1.New()
+	h := idx.ObjectFormat().New()
 	wh := io.MultiWriter(w, h)
 	for _, off := range idx.Offsets {
 		if off >= 1<<33 {
@@ -318,15 +353,14 @@ func (idx *Index) EncodeV1(w io.Writer) error {
 	if err := idx.encodeFanOut(wh); err != nil {
 		return fmt.Errorf("write packfile index: %w", err)
 	}
-	var buf [4 + githash.SHA1Size]byte
+	var buf [4 + githash.SHA256Size]byte
 	for i, off := range idx.Offsets {
 		htonl(buf[:4], uint32(off))
-		copy(buf[4:], idx.ObjectIDs[i][:])
-		if _, err := wh.Write(buf[:]); err != nil {
+		if _, err := wh.Write(idx.ObjectIDs[i].AppendBinary(buf[:4])); err != nil {
 			return fmt.Errorf("write packfile index: %w", err)
 		}
 	}
-	if _, err := wh.Write(idx.PackfileSHA1[:]); err != nil {
+	if _, err := wh.Write(idx.PackfileSHA1.AppendBinary(buf[:0])); err != nil {
 		return fmt.Errorf("write packfile index: %w", err)
 	}
 	if _, err := w.Write(h.Sum(buf[:0])); err != nil {
@@ -340,10 +374,16 @@ func (idx *Index) validate() error {
 		return fmt.Errorf("number of object IDs (%d) different than number of offsets (%d)",
 			len(idx.ObjectIDs), len(idx.Offsets))
 	}
+	format := idx.ObjectFormat()
+	for _, id := range idx.ObjectIDs {
+		if id.ObjectFormat() != format {
+			return fmt.Errorf("object ID %v is not %v", id, format)
+		}
+	}
 	if len(idx.ObjectIDs) > 1 {
 		for prevIdx, curr := range idx.ObjectIDs[1:] {
 			prev := idx.ObjectIDs[prevIdx]
-			if result := bytes.Compare(prev[:], curr[:]); result > 0 {
+			if result := prev.Compare(curr); result > 0 {
 				return fmt.Errorf("not sorted by object ID")
 			} else if result == 0 {
 				return fmt.Errorf("object IDs duplicated")
@@ -357,11 +397,12 @@ func (idx *Index) encodeFanOut(w io.Writer) error {
 	bucket := int16(0)
 	var ent [4]byte
 	for i, id := range idx.ObjectIDs {
-		if bucket >= int16(id[0]) {
+		first := int16(id.AppendBinary(ent[:0])[0])
+		if bucket >= first {
 			continue
 		}
 		htonl(ent[:], uint32(i))
-		for ; bucket < int16(id[0]); bucket++ {
+		for ; bucket < first; bucket++ {
 			if _, err := w.Write(ent[:]); err != nil {
 				return err
 			}
@@ -376,14 +417,16 @@ func (idx *Index) encodeFanOut(w io.Writer) error {
 	return nil
 }
 
-func emptyPackfileSHA1() githash.SHA1 {
+func emptyPackfileHash(format githash.ObjectFormat) githash.ObjectID {
 	buf := new(bytes.Buffer)
-	w := NewWriter(buf, 0)
+	w := NewWriterFormat(buf, 0, format)
 	if err := w.Close(); err != nil {
 		panic(err)
 	}
-	var sum githash.SHA1
-	copy(sum[:], buf.Bytes()[buf.Len()-githash.SHA1Size:])
+	var sum githash.ObjectID
+	if err := sum.UnmarshalBinary(buf.Bytes()[buf.Len()-format.Size():]); err != nil {
+		panic(err)
+	}
 	return sum
 }
 
@@ -399,12 +442,12 @@ func (idx *Index) MarshalBinary() ([]byte, error) {
 // FindID finds the position of id in idx.ObjectIDs or -1 if the ID is not
 // present in the index. The result is undefined if idx.ObjectIDs is not sorted.
 // This search is O(log len(idx.ObjectIDs)).
-func (idx *Index) FindID(id githash.SHA1) int {
+func (idx *Index) FindID(id githash.ObjectID) int {
 	if idx == nil {
 		return -1
 	}
 	i := sort.Search(len(idx.ObjectIDs), func(i int) bool {
-		return bytes.Compare(idx.ObjectIDs[i][:], id[:]) >= 0
+		return idx.ObjectIDs[i].Compare(id) >= 0
 	})
 	if i >= len(idx.ObjectIDs) || idx.ObjectIDs[i] != id {
 		return -1
@@ -423,7 +466,7 @@ func (idx *Index) Len() int {
 // Less returns whether the i'th object ID is lexicographically less than the
 // j'th object ID.
 func (idx *Index) Less(i, j int) bool {
-	return bytes.Compare(idx.ObjectIDs[i][:], idx.ObjectIDs[j][:]) < 0
+	return idx.ObjectIDs[i].Compare(idx.ObjectIDs[j]) < 0
 }
 
 // Swap swaps the i'th and j'th rows of the index.
@@ -38,7 +38,7 @@ var bigOffsetIndex = &Index{
 		0x1_0000_0018,
 		0x1_0000_000c,
 	},
-	ObjectIDs: []githash.SHA1{
+	ObjectIDs: []githash.ObjectID{
 		hashLiteral("8ab686eafeb1f44702738c8b0f24f2567c36da6d"),
 		hashLiteral("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"),
 	},
@@ -0,0 +1,506 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package packfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/internal/chunkfile"
+)
+
+// MultiPackIndex is an in-memory mapping of object IDs to offsets within
+// a set of packfiles. This maps 1:1 with the multi-pack-index files produced by
+// git-multi-pack-index(1). (*MultiPackIndex)(nil) is treated the same as
+// a MultiPackIndex with no packfiles.
+//
+// The format is described in https://git-scm.com/docs/gitformat-pack.
+type MultiPackIndex struct {
+	// PackNames is the sorted list of pack index file names
+	// (like "pack-1234abcd.idx") covered by the multi-pack-index.
+	PackNames []string
+	// ObjectIDs is a sorted list of object IDs in the packfiles.
+	// An object ID appears at most once,
+	// even if the object is stored in more than one packfile.
+	ObjectIDs []githash.ObjectID
+	// PackIDs holds the position in PackNames of the packfile
+	// that each object is read from. The i'th element of PackIDs
+	// corresponds with the i'th element of ObjectIDs.
+	PackIDs []uint32
+	// Offsets holds the offsets from the start of the packfile that an object
+	// header starts at. The i'th element of Offsets corresponds with the
+	// i'th element of ObjectIDs.
+	Offsets []int64
+	// ReverseIndex is an optional list of positions in ObjectIDs
+	// in pseudo-pack order: the objects of the preferred pack first,
+	// then the objects of the other packs by position in PackNames,
+	// each sorted by offset. It is used by multi-pack reachability bitmaps.
+	ReverseIndex []uint32
+	// Format is the object format of the object IDs.
+	Format githash.ObjectFormat
+}
+
+// Multi-pack-index constants.
+const (
+	midxSignature  = "MIDX"
+	midxVersion    = 1
+	midxHeaderSize = 12
+
+	midxChunkPackNames    = "PNAM"
+	midxChunkOIDFanOut    = "OIDF"
+	midxChunkOIDLookup    = "OIDL"
+	midxChunkOffsets      = "OOFF"
+	midxChunkLargeOffsets = "LOFF"
+	midxChunkRevIndex     = "RIDX"
+
+	midxChunkAlignment = 4
+)
+
+// MultiPackIndexOptions specifies optional parameters to BuildMultiPackIndex.
+type MultiPackIndexOptions struct {
+	// PreferredPack is the name of the pack index file that objects are read
+	// from when they are stored in more than one packfile.
+	// Otherwise, the packfile with the newest modification time
+	// in ModTimes that contains the object is used, like Git.
+	// Remaining ties go to the first packfile in PackNames order.
+	PreferredPack string
+	// ModTimes maps pack index file names to the modification times
+	// of their packfiles. Packs not in the map are treated as older
+	// than any pack in the map.
+	ModTimes map[string]time.Time
+	// ReverseIndex causes the ReverseIndex field to be filled in.
+	ReverseIndex bool
+}
+
+// BuildMultiPackIndex builds a multi-pack-index for the given pack indices,
+// keyed by pack index file name (like "pack-1234abcd.idx").
+func BuildMultiPackIndex(packs map[string]*Index, opts *MultiPackIndexOptions) (*MultiPackIndex, error) {
+	if opts == nil {
+		opts = new(MultiPackIndexOptions)
+	}
+	m := &MultiPackIndex{
+		PackNames: make([]string, 0, len(packs)),
+	}
+	total := 0
+	for name, idx := range packs {
+		m.PackNames = append(m.PackNames, name)
+		total += idx.Len()
+	}
+	sort.Strings(m.PackNames)
+	preferred := -1
+	if opts.PreferredPack != "" {
+		preferred = sort.SearchStrings(m.PackNames, opts.PreferredPack)
+		if preferred >= len(m.PackNames) || m.PackNames[preferred] != opts.PreferredPack {
+			return nil, fmt.Errorf("build multi-pack-index: preferred pack %q not found", opts.PreferredPack)
+		}
+	}
+	if len(m.PackNames) > 0 {
+		m.Format = packs[m.PackNames[0]].ObjectFormat()
+	}
+
+	modTimes := make([]time.Time, len(m.PackNames))
+	for i, name := range m.PackNames {
+		modTimes[i] = opts.ModTimes[name]
+	}
+
+	type entry struct {
+		id     githash.ObjectID
+		pack   uint32
+		offset int64
+	}
+	entries := make([]entry, 0, total)
+	for i, name := range m.PackNames {
+		idx := packs[name]
+		if idx == nil {
+			continue
+		}
+		if idx.ObjectFormat() != m.Format {
+			return nil, fmt.Errorf("build multi-pack-index: %s uses %v instead of %v", name, idx.ObjectFormat(), m.Format)
+		}
+		for j, id := range idx.ObjectIDs {
+			entries = append(entries, entry{id: id, pack: uint32(i), offset: idx.Offsets[j]})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if cmp := a.id.Compare(b.id); cmp != 0 {
+			return cmp < 0
+		}
+		if aPreferred, bPreferred := int(a.pack) == preferred, int(b.pack) == preferred; aPreferred != bPreferred {
+			return aPreferred
+		}
+		if aTime, bTime := modTimes[a.pack], modTimes[b.pack]; !aTime.Equal(bTime) {
+			return aTime.After(bTime)
+		}
+		return a.pack < b.pack
+	})
+	for i, ent := range entries {
+		if i > 0 && entries[i-1].id == ent.id {
+			continue
+		}
+		m.ObjectIDs = append(m.ObjectIDs, ent.id)
+		m.PackIDs = append(m.PackIDs, ent.pack)
+		m.Offsets = append(m.Offsets, ent.offset)
+	}
+	if opts.ReverseIndex {
+		m.ReverseIndex = make([]uint32, len(m.ObjectIDs))
+		for i := range m.ReverseIndex {
+			m.ReverseIndex[i] = uint32(i)
+		}
+		sort.Slice(m.ReverseIndex, func(i, j int) bool {
+			a, b := m.ReverseIndex[i], m.ReverseIndex[j]
+			aPack, bPack := m.PackIDs[a], m.PackIDs[b]
+			if aPreferred, bPreferred := int(aPack) == preferred, int(bPack) == preferred; aPreferred != bPreferred {
+				return aPreferred
+			}
+			if aPack != bPack {
+				return aPack < bPack
+			}
+			return m.Offsets[a] < m.Offsets[b]
+		})
+	}
+	return m, nil
+}
+
+// ReadMultiPackIndex parses a multi-pack-index file from r.
+func ReadMultiPackIndex(r io.Reader) (*MultiPackIndex, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read multi-pack-index: %w", err)
+	}
+	m := new(MultiPackIndex)
+	if err := m.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UnmarshalBinary decodes Git's multi-pack-index format into m.
+func (m *MultiPackIndex) UnmarshalBinary(data []byte) error {
+	newIndex, err := parseMultiPackIndex(data)
+	if err != nil {
+		return fmt.Errorf("read multi-pack-index: %w", err)
+	}
+	*m = *newIndex
+	return nil
+}
+
+func parseMultiPackIndex(data []byte) (*MultiPackIndex, error) {
+	if len(data) < midxHeaderSize || string(data[:4]) != midxSignature {
+		return nil, errors.New("not a multi-pack-index file")
+	}
+	if data[4] != midxVersion {
+		return nil, fmt.Errorf("unsupported version %d", data[4])
+	}
+	m := new(MultiPackIndex)
+	switch data[5] {
+	case 1:
+		m.Format = githash.SHA1Format
+	case 2:
+		m.Format = githash.SHA256Format
+	default:
+		return nil, fmt.Errorf("unknown hash version %d", data[5])
+	}
+	numChunks := int(data[6])
+	if data[7] != 0 {
+		return nil, fmt.Errorf("incremental multi-pack-index chains not supported")
+	}
+	numPacks := ntohl(data[8:])
+
+	hashSize := m.Format.Size()
+	if len(data) < midxHeaderSize+hashSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	h := m.Format.New()
+	h.Write(data[:len(data)-hashSize])
+	if !bytes.Equal(h.Sum(nil), data[len(data)-hashSize:]) {
+		return nil, errors.New("checksum does not match")
+	}
+	chunks, err := chunkfile.Read(data[:len(data)-hashSize], midxHeaderSize, numChunks)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pack names.
+	pnam, ok := chunks[midxChunkPackNames]
+	if !ok {
+		return nil, fmt.Errorf("missing %s chunk", midxChunkPackNames)
+	}
+	for i := uint32(0); i < numPacks; i++ {
+		end := bytes.IndexByte(pnam, 0)
+		if end <= 0 {
+			return nil, fmt.Errorf("%s chunk: invalid name for pack %d", midxChunkPackNames, i)
+		}
+		name := string(pnam[:end])
+		if len(m.PackNames) > 0 && m.PackNames[len(m.PackNames)-1] >= name {
+			return nil, fmt.Errorf("%s chunk: pack names out of order", midxChunkPackNames)
+		}
+		m.PackNames = append(m.PackNames, name)
+		pnam = pnam[end+1:]
+	}
+
+	// Object IDs.
+	fanOut, ok := chunks[midxChunkOIDFanOut]
+	if !ok || len(fanOut) != fanOutEntryCount*4 {
+		return nil, fmt.Errorf("missing or invalid %s chunk", midxChunkOIDFanOut)
+	}
+	nobjs := ntohl(fanOut[len(fanOut)-4:])
+	oidl, ok := chunks[midxChunkOIDLookup]
+	if !ok || uint64(len(oidl)) != uint64(nobjs)*uint64(hashSize) {
+		return nil, fmt.Errorf("missing or invalid %s chunk", midxChunkOIDLookup)
+	}
+	m.ObjectIDs = make([]githash.ObjectID, nobjs)
+	for i := range m.ObjectIDs {
+		if err := m.ObjectIDs[i].UnmarshalBinary(oidl[i*hashSize : (i+1)*hashSize]); err != nil {
+			return nil, err
+		}
+		first := int(oidl[i*hashSize])
+		if uint32(i) >= ntohl(fanOut[first*4:]) || (first > 0 && uint32(i) < ntohl(fanOut[(first-1)*4:])) {
+			return nil, fmt.Errorf("%s chunk does not match %s chunk", midxChunkOIDFanOut, midxChunkOIDLookup)
+		}
+		if i > 0 && m.ObjectIDs[i-1].Compare(m.ObjectIDs[i]) >= 0 {
+			return nil, fmt.Errorf("%s chunk: object IDs not sorted", midxChunkOIDLookup)
+		}
+	}
+
+	// Offsets.
+	ooff, ok := chunks[midxChunkOffsets]
+	if !ok || uint64(len(ooff)) != uint64(nobjs)*8 {
+		return nil, fmt.Errorf("missing or invalid %s chunk", midxChunkOffsets)
+	}
+	loff, hasLargeOffsets := chunks[midxChunkLargeOffsets]
+	if len(loff)%8 != 0 {
+		return nil, fmt.Errorf("invalid %s chunk", midxChunkLargeOffsets)
+	}
+	m.PackIDs = make([]uint32, nobjs)
+	m.Offsets = make([]int64, nobjs)
+	for i := range m.Offsets {
+		m.PackIDs[i] = ntohl(ooff[i*8:])
+		if m.PackIDs[i] >= numPacks {
+			return nil, fmt.Errorf("object %v: pack %d out of range", m.ObjectIDs[i], m.PackIDs[i])
+		}
+		off := ntohl(ooff[i*8+4:])
+		if !hasLargeOffsets || off&largeOffsetEntryMask == 0 {
+			m.Offsets[i] = int64(off)
+			continue
+		}
+		j := int(off &^ largeOffsetEntryMask)
+		if (j+1)*8 > len(loff) {
+			return nil, fmt.Errorf("object %v: large offset %d out of range", m.ObjectIDs[i], j)
+		}
+		m.Offsets[i] = int64(ntohll(loff[j*8:]))
+	}
+
+	// Reverse index.
+	if ridx, ok := chunks[midxChunkRevIndex]; ok {
+		if uint64(len(ridx)) != uint64(nobjs)*4 {
+			return nil, fmt.Errorf("invalid %s chunk", midxChunkRevIndex)
+		}
+		m.ReverseIndex = make([]uint32, nobjs)
+		for i := range m.ReverseIndex {
+			m.ReverseIndex[i] = ntohl(ridx[i*4:])
+			if m.ReverseIndex[i] >= nobjs {
+				return nil, fmt.Errorf("%s chunk: position %d out of range", midxChunkRevIndex, m.ReverseIndex[i])
+			}
+		}
+	}
+	return m, nil
+}
+
+// Encode writes m in Git's multi-pack-index format.
+func (m *MultiPackIndex) Encode(w io.Writer) error {
+	if m == nil {
+		m = new(MultiPackIndex)
+	}
+	if err := m.validate(); err != nil {
+		return fmt.Errorf("write multi-pack-index: %w", err)
+	}
+	var hashVersion byte
+	switch m.Format {
+	case githash.SHA1Format:
+		hashVersion = 1
+	case githash.SHA256Format:
+		hashVersion = 2
+	default:
+		return fmt.Errorf("write multi-pack-index: unsupported object format %v", m.Format)
+	}
+
+	var pnam []byte
+	for _, name := range m.PackNames {
+		pnam = append(pnam, name...)
+		pnam = append(pnam, 0)
+	}
+	for len(pnam)%midxChunkAlignment != 0 {
+		pnam = append(pnam, 0)
+	}
+
+	fanOut := new(bytes.Buffer)
+	idx := &Index{ObjectIDs: m.ObjectIDs}
+	if err := idx.encodeFanOut(fanOut); err != nil {
+		return fmt.Errorf("write multi-pack-index: %w", err)
+	}
+	oidl := make([]byte, 0, len(m.ObjectIDs)*m.Format.Size())
+	for _, id := range m.ObjectIDs {
+		oidl = id.AppendBinary(oidl)
+	}
+
+	// Like Git, only use the large offset table
+	// if an offset does not fit in 32 bits.
+	largeOffsetsNeeded := false
+	for _, off := range m.Offsets {
+		if off > 0xffffffff {
+			largeOffsetsNeeded = true
+			break
+		}
+	}
+	ooff := make([]byte, len(m.ObjectIDs)*8)
+	var loff []byte
+	var buf [8]byte
+	for i, off := range m.Offsets {
+		htonl(ooff[i*8:], m.PackIDs[i])
+		if largeOffsetsNeeded && off >= largeOffsetEntryMask {
+			htonl(ooff[i*8+4:], largeOffsetEntryMask|uint32(len(loff)/8))
+			htonll(buf[:], uint64(off))
+			loff = append(loff, buf[:]...)
+		} else {
+			htonl(ooff[i*8+4:], uint32(off))
+		}
+	}
+
+	chunks := []chunkfile.Chunk{
+		{ID: midxChunkPackNames, Data: pnam},
+		{ID: midxChunkOIDFanOut, Data: fanOut.Bytes()},
+		{ID: midxChunkOIDLookup, Data: oidl},
+		{ID: midxChunkOffsets, Data: ooff},
+	}
+	if largeOffsetsNeeded {
+		chunks = append(chunks, chunkfile.Chunk{ID: midxChunkLargeOffsets, Data: loff})
+	}
+	if m.ReverseIndex != nil {
+		ridx := make([]byte, len(m.ReverseIndex)*4)
+		for i, pos := range m.ReverseIndex {
+			htonl(ridx[i*4:], pos)
+		}
+		chunks = append(chunks, chunkfile.Chunk{ID: midxChunkRevIndex, Data: ridx})
+	}
+
+	h := m.Format.New()
+	wh := io.MultiWriter(w, h)
+	header := make([]byte, midxHeaderSize)
+	copy(header, midxSignature)
+	header[4] = midxVersion
+	header[5] = hashVersion
+	header[6] = byte(len(chunks))
+	htonl(header[8:], uint32(len(m.PackNames)))
+	if _, err := wh.Write(header); err != nil {
+		return fmt.Errorf("write multi-pack-index: %w", err)
+	}
+	if err := chunkfile.Write(wh, midxHeaderSize, chunks); err != nil {
+		return fmt.Errorf("write multi-pack-index: %w", err)
+	}
+	if _, err := w.Write(h.Sum(nil)); err != nil {
+		return fmt.Errorf("write multi-pack-index: %w", err)
+	}
+	return nil
+}
+
+func (m *MultiPackIndex) validate() error {
+	if len(m.PackIDs) != len(m.ObjectIDs) || len(m.Offsets) != len(m.ObjectIDs) {
+		return fmt.Errorf("number of object IDs (%d), pack IDs (%d), and offsets (%d) differ",
+			len(m.ObjectIDs), len(m.PackIDs), len(m.Offsets))
+	}
+	if m.ReverseIndex != nil && len(m.ReverseIndex) != len(m.ObjectIDs) {
+		return fmt.Errorf("number of reverse index entries (%d) different than number of objects (%d)",
+			len(m.ReverseIndex), len(m.ObjectIDs))
+	}
+	for i, name := range m.PackNames {
+		if name == "" || bytes.IndexByte([]byte(name), 0) != -1 {
+			return fmt.Errorf("invalid pack name %q", name)
+		}
+		if i > 0 && m.PackNames[i-1] >= name {
+			return fmt.Errorf("pack names not sorted")
+		}
+	}
+	for i, id := range m.ObjectIDs {
+		if id.ObjectFormat() != m.Format {
+			return fmt.Errorf("object ID %v is not %v", id, m.Format)
+		}
+		if i > 0 {
+			if result := m.ObjectIDs[i-1].Compare(id); result > 0 {
+				return fmt.Errorf("not sorted by object ID")
+			} else if result == 0 {
+				return fmt.Errorf("object IDs duplicated")
+			}
+		}
+		if int(m.PackIDs[i]) >= len(m.PackNames) {
+			return fmt.Errorf("object %v: pack %d out of range", id, m.PackIDs[i])
+		}
+		if m.Offsets[i] < 0 {
+			return fmt.Errorf("object %v: negative offset", id)
+		}
+	}
+	return nil
+}
+
+// MarshalBinary encodes the index in Git's multi-pack-index format.
+func (m *MultiPackIndex) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.Encode(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FindID finds the position of id in m.ObjectIDs or -1 if the ID is not
+// present in the index. The result is undefined if m.ObjectIDs is not sorted.
+// This search is O(log len(m.ObjectIDs)).
+func (m *MultiPackIndex) FindID(id githash.ObjectID) int {
+	if m == nil {
+		return -1
+	}
+	i := sort.Search(len(m.ObjectIDs), func(i int) bool {
+		return m.ObjectIDs[i].Compare(id) >= 0
+	})
+	if i >= len(m.ObjectIDs) || m.ObjectIDs[i] != id {
+		return -1
+	}
+	return i
+}
+
+// Lookup returns the name of the pack index file for the packfile
+// that stores the object with the given ID and the object's offset
+// within the packfile. ok is false if the object is not in the index.
+func (m *MultiPackIndex) Lookup(id githash.ObjectID) (packName string, offset int64, ok bool) {
+	i := m.FindID(id)
+	if i == -1 {
+		return "", 0, false
+	}
+	return m.PackNames[m.PackIDs[i]], m.Offsets[i], true
+}
+
+// Len returns the number of objects in the index.
+func (m *MultiPackIndex) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.ObjectIDs)
+}
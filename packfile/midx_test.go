@@ -0,0 +1,318 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package packfile
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = new(MultiPackIndex)
+	_ encoding.BinaryUnmarshaler = new(MultiPackIndex)
+)
+
+func TestMultiPackIndexRoundTrip(t *testing.T) {
+	packs := map[string]*Index{
+		"pack-big.idx": bigOffsetIndex,
+	}
+	for _, name := range []string{"FirstCommit", "DeltaObject", "EmptyBlob"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name+".idx2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx, err := ReadIndex(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		packs["pack-"+name+".idx"] = idx
+	}
+
+	for _, preferred := range []string{"", "pack-EmptyBlob.idx", "pack-big.idx"} {
+		t.Run(fmt.Sprintf("Preferred=%q", preferred), func(t *testing.T) {
+			m, err := BuildMultiPackIndex(packs, &MultiPackIndexOptions{
+				PreferredPack: preferred,
+				ReverseIndex:  true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantNames := []string{"pack-DeltaObject.idx", "pack-EmptyBlob.idx", "pack-FirstCommit.idx", "pack-big.idx"}
+			if diff := cmp.Diff(wantNames, m.PackNames); diff != "" {
+				t.Errorf("PackNames (-want +got):\n%s", diff)
+			}
+
+			// Every object in every pack should be found in some pack
+			// at the right offset, and in the preferred pack if present.
+			for name, idx := range packs {
+				for i, id := range idx.ObjectIDs {
+					gotName, gotOffset, ok := m.Lookup(id)
+					if !ok {
+						t.Errorf("Lookup(%v) not found", id)
+						continue
+					}
+					if preferred != "" && packs[preferred].FindID(id) != -1 && gotName != preferred {
+						t.Errorf("Lookup(%v) = %q, _; want %q", id, gotName, preferred)
+					}
+					if gotName == name && gotOffset != idx.Offsets[i] {
+						t.Errorf("Lookup(%v) = %q, %d; want %q, %d", id, gotName, gotOffset, name, idx.Offsets[i])
+					}
+					if want := packs[gotName].Offsets[packs[gotName].FindID(id)]; gotOffset != want {
+						t.Errorf("Lookup(%v) = %q, %d; want %q, %d", id, gotName, gotOffset, gotName, want)
+					}
+				}
+			}
+			if _, _, ok := m.Lookup(hashLiteral("0123456789abcdef0123456789abcdef01234567")); ok {
+				t.Error("Lookup(missing) found an object")
+			}
+
+			// The reverse index should be sorted by pack and offset,
+			// with the preferred pack first.
+			for i := 1; i < len(m.ReverseIndex); i++ {
+				prev, curr := m.ReverseIndex[i-1], m.ReverseIndex[i]
+				prevPack, currPack := m.PackNames[m.PackIDs[prev]], m.PackNames[m.PackIDs[curr]]
+				switch {
+				case prevPack == currPack:
+					if m.Offsets[prev] >= m.Offsets[curr] {
+						t.Errorf("ReverseIndex[%d:%d] not sorted by offset", i-1, i+1)
+					}
+				case currPack == preferred:
+					t.Errorf("ReverseIndex[%d] is in preferred pack, but ReverseIndex[%d] is not", i, i-1)
+				case prevPack != preferred && m.PackIDs[prev] > m.PackIDs[curr]:
+					t.Errorf("ReverseIndex[%d:%d] not sorted by pack", i-1, i+1)
+				}
+			}
+
+			data, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadMultiPackIndex(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(m, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("decoded multi-pack-index (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMultiPackIndexEmpty(t *testing.T) {
+	data, err := (*MultiPackIndex)(nil).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(MultiPackIndex)
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got.Len() != 0 || len(got.PackNames) != 0 {
+		t.Errorf("decoded empty multi-pack-index = %+v", got)
+	}
+
+	data[len(data)-1] ^= 0xff
+	if err := got.UnmarshalBinary(data); err == nil {
+		t.Error("UnmarshalBinary with bad checksum did not return an error")
+	}
+}
+
+// TestMultiPackIndexGit verifies that multi-pack-index files written
+// by Git and BuildMultiPackIndex are the same.
+func TestMultiPackIndexGit(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{
+		Env: []string{"GIT_CONFIG_NOSYSTEM=1"},
+	})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	if err := g.Run(ctx, "init", "--quiet", "--bare", "."); err != nil {
+		t.Fatal(err)
+	}
+	packDir := filepath.Join(dir, "objects", "pack")
+	packs := make(map[string]*Index)
+	for i := 0; i < 3; i++ {
+		var blobs [][]byte
+		for j := 0; j < 20; j++ {
+			blobs = append(blobs, []byte(fmt.Sprintf("pack %d blob %d\n", i, j)))
+		}
+		name, idx := writeTestPack(t, packDir, blobs)
+		packs[name] = idx
+	}
+
+	if err := g.Run(ctx, "multi-pack-index", "write"); err != nil {
+		t.Fatal(err)
+	}
+	midxPath := filepath.Join(packDir, "multi-pack-index")
+	gitData, err := os.ReadFile(midxPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitIndex, err := ReadMultiPackIndex(bytes.NewReader(gitData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := BuildMultiPackIndex(packs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, gitIndex, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("multi-pack-index written by Git (-want +got):\n%s", diff)
+	}
+	if got, err := want.MarshalBinary(); err != nil {
+		t.Error(err)
+	} else if !bytes.Equal(got, gitData) {
+		t.Error("MarshalBinary() does not match file written by Git")
+	}
+
+	// Git should accept a file with a reverse index.
+	var preferred string
+	for name := range packs {
+		preferred = name
+		break
+	}
+	m, err := BuildMultiPackIndex(packs, &MultiPackIndexOptions{
+		PreferredPack: preferred,
+		ReverseIndex:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(midxPath, data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := g.Output(ctx, "multi-pack-index", "verify"); err != nil {
+		t.Errorf("git multi-pack-index verify: %v\n%s", err, out)
+	}
+}
+
+// TestMultiPackIndexGitModTimes verifies that BuildMultiPackIndex chooses
+// the same packfile as Git for objects stored in more than one packfile.
+func TestMultiPackIndexGitModTimes(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{
+		Env: []string{"GIT_CONFIG_NOSYSTEM=1"},
+	})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	if err := g.Run(ctx, "init", "--quiet", "--bare", "."); err != nil {
+		t.Fatal(err)
+	}
+	packDir := filepath.Join(dir, "objects", "pack")
+	packs := make(map[string]*Index)
+	var names []string
+	for i := 0; i < 3; i++ {
+		blobs := [][]byte{
+			[]byte("shared blob\n"),
+			[]byte(fmt.Sprintf("pack %d blob\n", i)),
+		}
+		name, idx := writeTestPack(t, packDir, blobs)
+		packs[name] = idx
+		names = append(names, name)
+	}
+	// Make the packs sorted first by name the oldest
+	// so that ordering by name and by time disagree.
+	sort.Strings(names)
+	modTimes := make(map[string]time.Time)
+	base := time.Date(2020, time.January, 9, 14, 50, 0, 0, time.UTC)
+	for i, name := range names {
+		modTime := base.Add(time.Duration(i) * time.Hour)
+		packPath := filepath.Join(packDir, strings.TrimSuffix(name, ".idx")+".pack")
+		if err := os.Chtimes(packPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		modTimes[name] = modTime
+	}
+
+	if err := g.Run(ctx, "multi-pack-index", "write"); err != nil {
+		t.Fatal(err)
+	}
+	gitData, err := os.ReadFile(filepath.Join(packDir, "multi-pack-index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadMultiPackIndex(bytes.NewReader(gitData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := BuildMultiPackIndex(packs, &MultiPackIndexOptions{
+		ModTimes: modTimes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("multi-pack-index written by Git (-want +got):\n%s", diff)
+	}
+}
+
+// writeTestPack writes a packfile of blobs and its index to dir.
+// It returns the index's file name.
+func writeTestPack(tb testing.TB, dir string, blobs [][]byte) (string, *Index) {
+	tb.Helper()
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf, uint32(len(blobs)))
+	for _, blob := range blobs {
+		if _, err := w.WriteHeader(&Header{Type: Blob, Size: int64(len(blob))}); err != nil {
+			tb.Fatal(err)
+		}
+		if _, err := w.Write(blob); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	idx, err := BuildIndex(bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	idxData, err := idx.MarshalBinary()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	base := "pack-" + idx.PackfileSHA1.String()
+	if err := os.WriteFile(filepath.Join(dir, base+".pack"), buf.Bytes(), 0o666); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, base+".idx"), idxData, 0o666); err != nil {
+		tb.Fatal(err)
+	}
+	return base + ".idx", idx
+}
@@ -37,16 +37,27 @@ type ByteReader interface {
 //
 // Use ReadHeader if you want random access in a packfile.
 type Reader struct {
-	r     byteReaderCounter
-	nobjs uint32
+	r      byteReaderCounter
+	format githash.ObjectFormat
+	nobjs  uint32
 
 	dataReader zlibReader
 	remaining  int64
 }
 
 // NewReader returns a Reader that reads from the given stream.
+// The packfile is assumed to use SHA-1 object IDs.
 func NewReader(r ByteReader) *Reader {
-	return &Reader{r: byteReaderCounter{r: r}}
+	return NewReaderFormat(r, githash.SHA1Format)
+}
+
+// NewReaderFormat returns a Reader that reads from the given stream
+// for a packfile that uses the given object format.
+func NewReaderFormat(r ByteReader, format githash.ObjectFormat) *Reader {
+	return &Reader{
+		r:      byteReaderCounter{r: r},
+		format: format,
+	}
 }
 
 func (r *Reader) init() error {
@@ -102,13 +113,13 @@ func (r *Reader) Next() (*Header, error) {
 	}
 	if r.nobjs == 0 {
 		// Consume trailing checksum.
-		// TODO(someday): Verify integrity. This is a SHA-1 hash.
-		if _, err := io.CopyN(io.Discard, &r.r, githash.SHA1Size); err != nil {
+		// TODO(someday): Verify integrity. This is a hash in the object format.
+		if _, err := io.CopyN(io.Discard, &r.r, int64(r.format.Size())); err != nil {
 			return nil, fmt.Errorf("packfile: read trailing checksum: %w", err)
 		}
 		return nil, io.EOF
 	}
-	hdr, err := readObjectHeader(r.r.n, &r.r)
+	hdr, err := readObjectHeader(r.r.n, &r.r, r.format)
 	if err != nil {
 		return nil, err
 	}
@@ -164,22 +175,29 @@ type Header struct {
 	// BaseOffset is the Offset of a previous Header for an OffsetDelta type object.
 	BaseOffset int64
 	// BaseObject is the hash of an object for a RefDelta type object.
-	BaseObject githash.SHA1
+	BaseObject githash.ObjectID
 }
 
 // ReadHeader reads a packfile object header from r. The returned Header's
 // Offset field will be set to the given offset. If ReadHeader does not return
 // an error, the data of the object will be available on r as a zlib-compressed
-// stream.
+// stream. The packfile is assumed to use SHA-1 object IDs.
 func ReadHeader(offset int64, r ByteReader) (*Header, error) {
-	hdr, err := readObjectHeader(offset, r)
+	return ReadHeaderFormat(offset, r, githash.SHA1Format)
+}
+
+// ReadHeaderFormat reads a packfile object header from r
+// for a packfile that uses the given object format.
+// It is otherwise identical to [ReadHeader].
+func ReadHeaderFormat(offset int64, r ByteReader, format githash.ObjectFormat) (*Header, error) {
+	hdr, err := readObjectHeader(offset, r, format)
 	if err != nil {
 		return nil, fmt.Errorf("packfile: %w", err)
 	}
 	return hdr, nil
 }
 
-func readObjectHeader(offset int64, r ByteReader) (*Header, error) {
+func readObjectHeader(offset int64, r ByteReader, format githash.ObjectFormat) (*Header, error) {
 	hdr := &Header{Offset: offset}
 	var err error
 	hdr.Type, hdr.Size, err = readLengthType(r)
@@ -194,7 +212,11 @@ func readObjectHeader(offset int64, r ByteReader) (*Header, error) {
 		}
 		hdr.BaseOffset = hdr.Offset + off
 	case RefDelta:
-		if _, err := io.ReadFull(r, hdr.BaseObject[:]); err != nil {
+		var buf [githash.SHA256Size]byte
+		if _, err := io.ReadFull(r, buf[:format.Size()]); err != nil {
+			return nil, fmt.Errorf("read ref-delta object: %w", err)
+		}
+		if err := hdr.BaseObject.UnmarshalBinary(buf[:format.Size()]); err != nil {
 			return nil, fmt.Errorf("read ref-delta object: %w", err)
 		}
 	}
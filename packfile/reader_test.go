@@ -82,7 +82,7 @@ var testFiles = []struct {
 		},
 		wantIndex: &Index{
 			Offsets: []int64{12, 91, 39},
-			ObjectIDs: []githash.SHA1{
+			ObjectIDs: []githash.ObjectID{
 				hashLiteral("8ab686eafeb1f44702738c8b0f24f2567c36da6d"),
 				hashLiteral("aef8a4c3fe8d296dec2d9b88d4654cd596927867"),
 				hashLiteral("bc225ea23f53f06c0c5bd3ba2be85c2120d68417"),
@@ -118,7 +118,7 @@ var testFiles = []struct {
 		},
 		wantIndex: &Index{
 			Offsets: []int64{12, 31},
-			ObjectIDs: []githash.SHA1{
+			ObjectIDs: []githash.ObjectID{
 				hashLiteral("05a682bd4e7c7117c5856be7142fea67465415e3"),
 				hashLiteral("45c3b785642598057cf65b79fd05586dae5cba10"),
 			},
@@ -152,7 +152,7 @@ var testFiles = []struct {
 		},
 		wantIndex: &Index{
 			Offsets: []int64{12, 31},
-			ObjectIDs: []githash.SHA1{
+			ObjectIDs: []githash.ObjectID{
 				hashLiteral("05a682bd4e7c7117c5856be7142fea67465415e3"),
 				hashLiteral("45c3b785642598057cf65b79fd05586dae5cba10"),
 			},
@@ -185,7 +185,7 @@ var testFiles = []struct {
 		},
 		wantIndex: &Index{
 			Offsets: []int64{24, 12},
-			ObjectIDs: []githash.SHA1{
+			ObjectIDs: []githash.ObjectID{
 				hashLiteral("8ab686eafeb1f44702738c8b0f24f2567c36da6d"),
 				hashLiteral("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"),
 			},
@@ -304,8 +304,8 @@ func TestReadOffset(t *testing.T) {
 	}
 }
 
-func hashLiteral(s string) githash.SHA1 {
-	var h githash.SHA1
+func hashLiteral(s string) githash.ObjectID {
+	var h githash.ObjectID
 	if err := h.UnmarshalText([]byte(s)); err != nil {
 		panic(err)
 	}
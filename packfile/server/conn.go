@@ -0,0 +1,162 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"gg-scm.io/pkg/git/internal/pktline"
+)
+
+// Service names.
+const (
+	uploadPackService  = "git-upload-pack"
+	receivePackService = "git-receive-pack"
+)
+
+// conn is the server side of a single service request or session.
+type conn struct {
+	br *bufio.Reader
+	r  *pktline.Reader
+	w  *bufio.Writer
+
+	// stateless is true if the client sends a single request
+	// and expects a single response, as in the smart HTTP protocol.
+	stateless bool
+}
+
+func newConn(r io.Reader, w io.Writer, stateless bool) *conn {
+	br := bufio.NewReader(r)
+	return &conn{
+		br:        br,
+		r:         pktline.NewReader(br),
+		w:         bufio.NewWriter(w),
+		stateless: stateless,
+	}
+}
+
+// atEOF reports whether the client has closed its end of the connection
+// without sending anything further. It blocks until data is available.
+func (c *conn) atEOF() bool {
+	_, err := c.br.Peek(1)
+	return errors.Is(err, io.EOF)
+}
+
+// next advances the reader to the next packet.
+func (c *conn) next() error {
+	if !c.r.Next() {
+		return c.r.Err()
+	}
+	return nil
+}
+
+func (c *conn) writeString(line string) {
+	c.w.Write(pktline.AppendString(nil, line))
+}
+
+func (c *conn) writeFlush() {
+	c.w.Write(pktline.AppendFlush(nil))
+}
+
+func (c *conn) writeDelim() {
+	c.w.Write(pktline.AppendDelim(nil))
+}
+
+// writeError sends an "ERR" packet to the client and flushes the connection.
+func (c *conn) writeError(err error) {
+	c.writeString("ERR " + strings.ReplaceAll(err.Error(), "\n", " ") + "\n")
+	c.w.Flush()
+}
+
+// protocolVersion returns the protocol version requested by the given
+// Git-Protocol header or GIT_PROTOCOL environment variable value.
+// See https://git-scm.com/docs/protocol-v2#_initial_client_request
+func protocolVersion(gitProtocol string) int {
+	version := 0
+	for _, param := range strings.Split(gitProtocol, ":") {
+		v, ok := cutPrefix(param, "version=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(v); err == nil && n > version && n <= 2 {
+			version = n
+		}
+	}
+	return version
+}
+
+// Sideband channels.
+// See https://git-scm.com/docs/protocol-capabilities#_side_band_side_band_64k
+const (
+	sidebandData     = 1
+	sidebandProgress = 2
+	sidebandError    = 3
+)
+
+// Maximum sizes of the data in a sideband packet, excluding the band number.
+const (
+	sideband64KMaxData = pktline.MaxSize - 1
+	sidebandMaxData    = 1000 - 4 - 1
+)
+
+// sidebandWriter writes data to a single sideband channel.
+type sidebandWriter struct {
+	w       io.Writer
+	band    byte
+	maxData int
+	buf     []byte
+}
+
+func (sw *sidebandWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > sw.maxData {
+			chunk = chunk[:sw.maxData]
+		}
+		sw.buf = append(sw.buf[:0], sw.band)
+		sw.buf = append(sw.buf, chunk...)
+		if _, err := sw.w.Write(pktline.Append(nil, sw.buf)); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+// writeSidebandError reports a fatal error to the client on the error channel.
+func writeSidebandError(w io.Writer, err error) {
+	msg := append([]byte{sidebandError}, err.Error()...)
+	msg = append(msg, '\n')
+	if len(msg) > sidebandMaxData+1 {
+		msg = msg[:sidebandMaxData+1]
+	}
+	w.Write(pktline.Append(nil, msg))
+}
+
+// cutPrefix is strings.CutPrefix, which is not available in Go 1.19.
+func cutPrefix(s, prefix string) (after string, found bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
@@ -0,0 +1,144 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	cacheControlHeader    = "Cache-Control"
+	contentEncodingHeader = "Content-Encoding"
+	contentTypeHeader     = "Content-Type"
+	gitProtocolHeader     = "Git-Protocol"
+)
+
+// ServeHTTP serves the smart HTTP protocol. It handles requests for paths that
+// end in "/info/refs", "/git-upload-pack", or "/git-receive-pack", so the
+// Server is usually registered at the repository's URL path. ServeHTTP does
+// not perform any authentication or authorization: callers should wrap the
+// Server in a handler that does.
+//
+// If the Server's Store does not implement WritableStore, then requests for
+// the git-receive-pack service receive a 403 Forbidden response.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch path := r.URL.Path; {
+	case strings.HasSuffix(path, "/info/refs"):
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		srv.serveInfoRefs(w, r)
+	case strings.HasSuffix(path, "/"+uploadPackService):
+		srv.serveRPC(w, r, uploadPackService)
+	case strings.HasSuffix(path, "/"+receivePackService):
+		srv.serveRPC(w, r, receivePackService)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveInfoRefs handles the initial reference discovery request.
+// See https://git-scm.com/docs/http-protocol#_smart_clients
+func (srv *Server) serveInfoRefs(w http.ResponseWriter, r *http.Request) {
+	service := r.URL.Query().Get("service")
+	switch service {
+	case uploadPackService:
+	case receivePackService:
+		if _, ok := srv.writableStore(); !ok {
+			http.Error(w, errReadOnly.Error(), http.StatusForbidden)
+			return
+		}
+	default:
+		// The dumb HTTP protocol requires a repository on disk.
+		http.Error(w, "only the smart HTTP protocol is supported", http.StatusForbidden)
+		return
+	}
+	version := protocolVersion(r.Header.Get(gitProtocolHeader))
+	w.Header().Set(contentTypeHeader, "application/x-"+service+"-advertisement")
+	w.Header().Set(cacheControlHeader, "no-cache")
+	if r.Method == http.MethodHead {
+		return
+	}
+	c := newConn(http.NoBody, w, true)
+	if service == receivePackService || version != 2 {
+		c.writeString("# service=" + service + "\n")
+		c.writeFlush()
+	}
+	var err error
+	if service == uploadPackService {
+		err = srv.advertiseUploadPack(c, version)
+	} else {
+		err = srv.advertiseReceivePack(c, version)
+	}
+	if err != nil {
+		srv.logf("git server: %s %s advertisement: %v", r.URL.Path, service, err)
+	}
+}
+
+// serveRPC handles a request to run a service.
+// See https://git-scm.com/docs/http-protocol#_smart_service_git_upload_pack
+func (srv *Server) serveRPC(w http.ResponseWriter, r *http.Request, service string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	store, writable := srv.writableStore()
+	if service == receivePackService && !writable {
+		http.Error(w, errReadOnly.Error(), http.StatusForbidden)
+		return
+	}
+	if got, want := r.Header.Get(contentTypeHeader), "application/x-"+service+"-request"; got != want {
+		http.Error(w, "Content-Type must be "+want, http.StatusUnsupportedMediaType)
+		return
+	}
+	var body io.Reader = r.Body
+	switch enc := r.Header.Get(contentEncodingHeader); enc {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, "bad gzip body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		http.Error(w, "unsupported Content-Encoding "+enc, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, "application/x-"+service+"-result")
+	w.Header().Set(cacheControlHeader, "no-cache")
+	c := newConn(body, w, true)
+	// Errors have already been reported to the client in-band where possible,
+	// but the response has started, so they can only be logged here.
+	var err error
+	if service == uploadPackService {
+		err = srv.uploadPack(r.Context(), c, protocolVersion(r.Header.Get(gitProtocolHeader)))
+	} else {
+		err = srv.receivePack(r.Context(), c, store)
+	}
+	if err != nil {
+		srv.logf("git server: %s %s: %v", r.URL.Path, service, err)
+	}
+}
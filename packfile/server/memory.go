@@ -0,0 +1,173 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/object"
+)
+
+// MemoryStore is a WritableStore that keeps objects and refs in memory.
+// It is safe to call MemoryStore's methods from multiple goroutines
+// simultaneously.
+type MemoryStore struct {
+	format githash.ObjectFormat
+
+	mu      sync.RWMutex
+	objects map[githash.ObjectID]memoryObject
+	refs    map[githash.Ref]githash.ObjectID
+	head    githash.Ref
+}
+
+type memoryObject struct {
+	typ  object.Type
+	data []byte
+}
+
+// NewMemoryStore returns a new empty MemoryStore that uses the given object
+// format. HEAD initially points to refs/heads/main.
+func NewMemoryStore(format githash.ObjectFormat) *MemoryStore {
+	return &MemoryStore{
+		format:  format,
+		objects: make(map[githash.ObjectID]memoryObject),
+		refs:    make(map[githash.Ref]githash.ObjectID),
+		head:    githash.BranchRef("main"),
+	}
+}
+
+// ObjectFormat returns the object format passed to NewMemoryStore.
+func (s *MemoryStore) ObjectFormat() githash.ObjectFormat {
+	return s.format
+}
+
+// SetHead changes the ref that HEAD points to. The ref does not need to exist.
+func (s *MemoryStore) SetHead(target githash.Ref) error {
+	if !target.IsValid() || !strings.HasPrefix(string(target), "refs/") {
+		return fmt.Errorf("set HEAD to %q: invalid ref", target)
+	}
+	s.mu.Lock()
+	s.head = target
+	s.mu.Unlock()
+	return nil
+}
+
+// ListRefs returns the store's refs, including HEAD.
+// If refPrefixes is given, then only refs that start with one of the given
+// strings are returned.
+func (s *MemoryStore) ListRefs(refPrefixes ...string) (map[githash.Ref]*gitrepo.Ref, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	refs := make(map[githash.Ref]*gitrepo.Ref, len(s.refs)+1)
+	if matchesRefPrefixes(githash.Head, refPrefixes) {
+		refs[githash.Head] = &gitrepo.Ref{
+			Name:         githash.Head,
+			ObjectID:     s.refs[s.head],
+			SymrefTarget: s.head,
+		}
+	}
+	for name, id := range s.refs {
+		if matchesRefPrefixes(name, refPrefixes) {
+			refs[name] = &gitrepo.Ref{
+				Name:     name,
+				ObjectID: id,
+			}
+		}
+	}
+	return refs, nil
+}
+
+// OpenObject returns a reader for the object with the given ID.
+func (s *MemoryStore) OpenObject(id githash.ObjectID) (object.Prefix, io.ReadCloser, error) {
+	s.mu.RLock()
+	obj, ok := s.objects[id]
+	s.mu.RUnlock()
+	if !ok {
+		return object.Prefix{}, nil, fmt.Errorf("open object %v: %w", id, fs.ErrNotExist)
+	}
+	prefix := object.Prefix{Type: obj.typ, Size: int64(len(obj.data))}
+	return prefix, io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+// HasObject reports whether the store contains an object with the given ID.
+func (s *MemoryStore) HasObject(id githash.ObjectID) (bool, error) {
+	s.mu.RLock()
+	_, ok := s.objects[id]
+	s.mu.RUnlock()
+	return ok, nil
+}
+
+// WriteObject stores an object and returns its ID.
+func (s *MemoryStore) WriteObject(prefix object.Prefix, r io.Reader) (githash.ObjectID, error) {
+	if !prefix.Type.IsValid() {
+		return githash.ObjectID{}, fmt.Errorf("write object: invalid type %q", prefix.Type)
+	}
+	if prefix.Size < 0 {
+		return githash.ObjectID{}, fmt.Errorf("write object: negative size")
+	}
+	data := make([]byte, int(prefix.Size))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return githash.ObjectID{}, fmt.Errorf("write object: %w", err)
+	}
+	h := s.format.New()
+	h.Write(object.AppendPrefix(nil, prefix.Type, prefix.Size))
+	h.Write(data)
+	id := s.format.Sum(h)
+	s.mu.Lock()
+	s.objects[id] = memoryObject{typ: prefix.Type, data: data}
+	s.mu.Unlock()
+	return id, nil
+}
+
+// UpdateRef changes the ref with the given name from oldID to newID.
+// If oldID is zero, then the ref must not exist. If newID is zero,
+// then the ref is deleted. UpdateRef returns an error if the ref's current
+// value is not oldID or newID does not refer to an object in the store.
+func (s *MemoryStore) UpdateRef(name githash.Ref, oldID, newID githash.ObjectID) error {
+	if !name.IsValid() || !strings.HasPrefix(string(name), "refs/") {
+		return fmt.Errorf("update %q: invalid ref", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !newID.IsZero() {
+		if _, ok := s.objects[newID]; !ok {
+			return fmt.Errorf("update %s: %v: %w", name, newID, fs.ErrNotExist)
+		}
+	}
+	curr, exists := s.refs[name]
+	switch {
+	case oldID.IsZero() && exists:
+		return fmt.Errorf("update %s: already exists", name)
+	case !oldID.IsZero() && !exists:
+		return fmt.Errorf("update %s: does not exist", name)
+	case !oldID.IsZero() && curr != oldID:
+		return fmt.Errorf("update %s: is at %v but expected %v", name, curr, oldID)
+	}
+	if newID.IsZero() {
+		delete(s.refs, name)
+	} else {
+		s.refs[name] = newID
+	}
+	return nil
+}
@@ -0,0 +1,516 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/internal/pktline"
+	"gg-scm.io/pkg/git/object"
+	"gg-scm.io/pkg/git/packfile"
+)
+
+// errReadOnly is returned when a client attempts to push to a Server
+// whose Store does not implement WritableStore.
+var errReadOnly = errors.New("repository is read-only")
+
+// ReceivePack serves a git-receive-pack session over a bidirectional stream,
+// like the standard input and output of a process started by ssh(1) or
+// git-daemon(1). gitProtocol is the value of the GIT_PROTOCOL environment
+// variable sent by the client, which may be empty. ReceivePack returns an error
+// if the Server's Store does not implement WritableStore.
+//
+// Like git-receive-pack(1), ReceivePack rejects ref updates to objects
+// whose ancestors or contents are missing from the Store. Objects from the
+// pushed packfile are only written to the Store if at least one ref update
+// passes this check.
+func (srv *Server) ReceivePack(ctx context.Context, r io.Reader, w io.Writer, gitProtocol string) error {
+	c := newConn(r, w, false)
+	store, ok := srv.writableStore()
+	if !ok {
+		c.writeError(errReadOnly)
+		return fmt.Errorf("receive-pack: %w", errReadOnly)
+	}
+	if err := srv.advertiseReceivePack(c, protocolVersion(gitProtocol)); err != nil {
+		return fmt.Errorf("receive-pack: %w", err)
+	}
+	if err := srv.receivePack(ctx, c, store); err != nil {
+		return fmt.Errorf("receive-pack: %w", err)
+	}
+	return nil
+}
+
+// advertiseReceivePack writes the initial response for git-receive-pack.
+// Protocol version 2 does not support pushes, so clients that request it
+// receive a version 1 advertisement.
+func (srv *Server) advertiseReceivePack(c *conn, version int) error {
+	if version > 1 {
+		version = 1
+	}
+	refs, err := srv.listRefs()
+	if err != nil {
+		c.writeError(err)
+		return err
+	}
+	// HEAD is not advertised for pushes.
+	n := 0
+	for _, ref := range refs {
+		if ref.Name != githash.Head {
+			refs[n] = ref
+			n++
+		}
+	}
+	refs = refs[:n]
+	caps := []string{
+		reportStatusCap,
+		deleteRefsCap,
+		ofsDeltaCap,
+		noThinCap,
+	}
+	return srv.advertiseRefsV1(c, version, refs, caps, false)
+}
+
+// receiveCommand is a ref update requested by the client.
+type receiveCommand struct {
+	name   githash.Ref
+	oldID  githash.ObjectID
+	newID  githash.ObjectID
+	status string // empty if successful
+}
+
+func (srv *Server) receivePack(ctx context.Context, c *conn, store WritableStore) error {
+	if !c.stateless && c.atEOF() {
+		return nil
+	}
+	commands, caps, err := srv.readReceiveCommands(c)
+	if err != nil {
+		c.writeError(err)
+		return err
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	needPack := false
+	for _, cmd := range commands {
+		if !cmd.newID.IsZero() {
+			needPack = true
+		}
+	}
+	var pack *receivedPack
+	var unpackErr error
+	if needPack {
+		pack, unpackErr = srv.unpack(ctx, c.br)
+		if pack != nil {
+			defer pack.Close()
+		}
+	}
+	if unpackErr == nil {
+		unpackErr = srv.checkCommandsConnected(ctx, commands, pack)
+	}
+	if unpackErr == nil && pack != nil && anyAccepted(commands) {
+		unpackErr = pack.migrate(ctx, store)
+	}
+	for _, cmd := range commands {
+		if unpackErr != nil {
+			cmd.status = "unpacker error"
+			continue
+		}
+		if cmd.status != "" {
+			continue
+		}
+		if err := srv.updateRef(store, cmd); err != nil {
+			cmd.status = strings.ReplaceAll(err.Error(), "\n", " ")
+		}
+	}
+
+	if _, ok := caps[reportStatusCap]; ok {
+		if unpackErr != nil {
+			c.writeString("unpack " + strings.ReplaceAll(unpackErr.Error(), "\n", " ") + "\n")
+		} else {
+			c.writeString("unpack ok\n")
+		}
+		for _, cmd := range commands {
+			if cmd.status == "" {
+				c.writeString("ok " + string(cmd.name) + "\n")
+			} else {
+				c.writeString("ng " + string(cmd.name) + " " + cmd.status + "\n")
+			}
+		}
+		c.writeFlush()
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	return unpackErr
+}
+
+// readReceiveCommands reads the ref update commands sent by the client.
+// See https://git-scm.com/docs/pack-protocol#_reference_update_request_and_packfile_transfer
+func (srv *Server) readReceiveCommands(c *conn) ([]*receiveCommand, map[string]string, error) {
+	format := srv.store.ObjectFormat()
+	var commands []*receiveCommand
+	caps := make(map[string]string)
+	for {
+		if err := c.next(); err != nil {
+			return nil, nil, fmt.Errorf("read commands: %w", err)
+		}
+		if c.r.Type() == pktline.Flush {
+			return commands, caps, nil
+		}
+		line, err := c.r.Text()
+		if err != nil {
+			return nil, nil, fmt.Errorf("read commands: %w", err)
+		}
+		if len(commands) == 0 {
+			var capString []byte
+			line, capString, _ = bytes.Cut(line, []byte{0})
+			for _, c := range strings.Fields(string(capString)) {
+				k, v, _ := strings.Cut(c, "=")
+				caps[k] = v
+			}
+			if f, ok := caps[objectFormatCap]; ok && f != format.String() {
+				return nil, nil, fmt.Errorf("read commands: mismatched object format %q", f)
+			}
+		}
+		parts := strings.SplitN(string(line), " ", 3)
+		if len(parts) != 3 {
+			return nil, nil, fmt.Errorf("read commands: invalid command %q", line)
+		}
+		cmd := &receiveCommand{name: githash.Ref(parts[2])}
+		cmd.oldID, err = githash.ParseObjectID(parts[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("read commands: %s: %w", cmd.name, err)
+		}
+		cmd.newID, err = githash.ParseObjectID(parts[1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("read commands: %s: %w", cmd.name, err)
+		}
+		if cmd.oldID.ObjectFormat() != format || cmd.newID.ObjectFormat() != format {
+			return nil, nil, fmt.Errorf("read commands: %s: object ID is not %v", cmd.name, format)
+		}
+		if cmd.oldID == format.Null() {
+			cmd.oldID = githash.ObjectID{}
+		}
+		if cmd.newID == format.Null() {
+			cmd.newID = githash.ObjectID{}
+		}
+		if cmd.oldID.IsZero() && cmd.newID.IsZero() {
+			return nil, nil, fmt.Errorf("read commands: %s: empty command", cmd.name)
+		}
+		commands = append(commands, cmd)
+	}
+}
+
+// receivedPack is a packfile received from a client. Its objects are kept
+// in a temporary file until the push's commands have been checked,
+// like the quarantine directory used by git-receive-pack(1).
+type receivedPack struct {
+	f   *os.File
+	rs  *packfile.BufferedReadSeeker
+	idx *packfile.Index
+	u   packfile.Undeltifier
+}
+
+// unpack reads a packfile from r into a temporary file.
+// The caller is responsible for calling Close on the returned receivedPack.
+func (srv *Server) unpack(ctx context.Context, r *bufio.Reader) (_ *receivedPack, err error) {
+	format := srv.store.ObjectFormat()
+	f, err := os.CreateTemp("", "gg-git-receive-*.pack")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	// Copy exactly the packfile's bytes from the stream into the file.
+	fw := bufio.NewWriter(f)
+	pr := packfile.NewReaderFormat(&teeByteReader{r: r, w: fw}, format)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := pr.Next(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := packfile.BuildIndex(f, size, &packfile.IndexOptions{ObjectFormat: format})
+	if err != nil {
+		return nil, err
+	}
+	return &receivedPack{
+		f:   f,
+		rs:  packfile.NewBufferedReadSeeker(f),
+		idx: idx,
+	}, nil
+}
+
+// has reports whether the packfile contains the object with the given ID.
+func (pack *receivedPack) has(id githash.ObjectID) bool {
+	return pack != nil && pack.idx.FindID(id) != -1
+}
+
+// read returns the type and contents of the object with the given ID,
+// which must be in the packfile.
+func (pack *receivedPack) read(id githash.ObjectID) (object.Type, []byte, error) {
+	i := pack.idx.FindID(id)
+	if i == -1 {
+		return "", nil, fmt.Errorf("read %v: not in pushed packfile", id)
+	}
+	prefix, r, err := pack.u.Undeltify(pack.rs, pack.idx.Offsets[i], &packfile.UndeltifyOptions{Index: pack.idx})
+	if err != nil {
+		return "", nil, fmt.Errorf("read %v: %w", id, err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", nil, fmt.Errorf("read %v: %w", id, err)
+	}
+	return prefix.Type, data, nil
+}
+
+// migrate writes the packfile's objects to the store.
+func (pack *receivedPack) migrate(ctx context.Context, store WritableStore) error {
+	opts := &packfile.UndeltifyOptions{Index: pack.idx}
+	for i, id := range pack.idx.ObjectIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		prefix, objReader, err := pack.u.Undeltify(pack.rs, pack.idx.Offsets[i], opts)
+		if err != nil {
+			return err
+		}
+		gotID, err := store.WriteObject(prefix, objReader)
+		if err != nil {
+			return fmt.Errorf("write %v: %w", id, err)
+		}
+		if gotID != id {
+			return fmt.Errorf("write %v: store returned %v", id, gotID)
+		}
+	}
+	return nil
+}
+
+// Close removes the packfile's temporary file.
+func (pack *receivedPack) Close() error {
+	err := pack.f.Close()
+	if rmErr := os.Remove(pack.f.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// checkCommandsConnected verifies that every object reachable from
+// the commands' new object IDs is either in the pushed packfile
+// or already present in the store, like Git's check_connected.
+// As in Git, objects that existed before the push are assumed to be complete,
+// so the walk only reads objects from the pushed packfile.
+// Commands that would leave the repository incomplete have their status set
+// to "missing necessary objects". pack may be nil if the client did not send
+// a packfile.
+func (srv *Server) checkCommandsConnected(ctx context.Context, commands []*receiveCommand, pack *receivedPack) error {
+	check := &connectivityCheck{
+		srv:       srv,
+		ctx:       ctx,
+		pack:      pack,
+		connected: make(map[githash.ObjectID]bool),
+	}
+	for _, cmd := range commands {
+		if cmd.newID.IsZero() {
+			continue
+		}
+		ok, err := check.isConnected(cmd.newID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			cmd.status = "missing necessary objects"
+		}
+	}
+	return nil
+}
+
+// connectivityCheck records which objects have been checked
+// by checkCommandsConnected, so that objects shared by several commands
+// are only walked once.
+type connectivityCheck struct {
+	srv  *Server
+	ctx  context.Context
+	pack *receivedPack
+
+	// connected maps objects that have been checked to whether
+	// all of the objects reachable from them are present.
+	connected map[githash.ObjectID]bool
+}
+
+// isConnected reports whether every object reachable from id is present
+// in the pushed packfile or the store.
+func (check *connectivityCheck) isConnected(id githash.ObjectID) (bool, error) {
+	// Objects are pushed onto the stack twice: once to read the object
+	// and push its references, then again (with expanded set) to record
+	// the result after its references have been checked.
+	type frame struct {
+		id       githash.ObjectID
+		expanded bool
+	}
+	refs := make(map[githash.ObjectID][]githash.ObjectID)
+	stack := []frame{{id: id}}
+	for len(stack) > 0 {
+		if err := check.ctx.Err(); err != nil {
+			return false, err
+		}
+		curr := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if curr.expanded {
+			ok := true
+			for _, ref := range refs[curr.id] {
+				if !check.connected[ref] {
+					ok = false
+					break
+				}
+			}
+			check.connected[curr.id] = ok
+			delete(refs, curr.id)
+			continue
+		}
+		if _, done := check.connected[curr.id]; done {
+			continue
+		}
+		if !check.pack.has(curr.id) {
+			has, err := check.srv.hasObject(curr.id)
+			if err != nil {
+				return false, err
+			}
+			check.connected[curr.id] = has
+			continue
+		}
+		objRefs, err := check.references(curr.id)
+		if err != nil {
+			return false, err
+		}
+		refs[curr.id] = objRefs
+		stack = append(stack, frame{id: curr.id, expanded: true})
+		for _, ref := range objRefs {
+			stack = append(stack, frame{id: ref})
+		}
+	}
+	return check.connected[id], nil
+}
+
+// references returns the IDs of the objects that the object
+// in the pushed packfile with the given ID refers to.
+func (check *connectivityCheck) references(id githash.ObjectID) ([]githash.ObjectID, error) {
+	typ, data, err := check.pack.read(id)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case object.TypeCommit:
+		c, err := object.ParseCommit(data)
+		if err != nil {
+			return nil, fmt.Errorf("commit %v: %w", id, err)
+		}
+		return append([]githash.ObjectID{c.Tree}, c.Parents...), nil
+	case object.TypeTag:
+		tag, err := object.ParseTag(data)
+		if err != nil {
+			return nil, fmt.Errorf("tag %v: %w", id, err)
+		}
+		return []githash.ObjectID{tag.ObjectID}, nil
+	case object.TypeTree:
+		tree, err := object.ParseTreeFormat(data, check.srv.store.ObjectFormat())
+		if err != nil {
+			return nil, fmt.Errorf("tree %v: %w", id, err)
+		}
+		var ids []githash.ObjectID
+		for _, ent := range tree {
+			// Submodule commits are not part of this repository.
+			if ent.Mode != object.ModeGitlink {
+				ids = append(ids, ent.ObjectID)
+			}
+		}
+		return ids, nil
+	default:
+		return nil, nil
+	}
+}
+
+// anyAccepted reports whether any of the commands have passed their checks.
+func anyAccepted(commands []*receiveCommand) bool {
+	for _, cmd := range commands {
+		if cmd.status == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// updateRef performs a single ref update.
+func (srv *Server) updateRef(store WritableStore, cmd *receiveCommand) error {
+	if !cmd.name.IsValid() || !strings.HasPrefix(string(cmd.name), "refs/") {
+		return fmt.Errorf("funny refname")
+	}
+	if !cmd.newID.IsZero() {
+		if has, err := srv.hasObject(cmd.newID); err != nil {
+			return err
+		} else if !has {
+			return fmt.Errorf("missing necessary objects")
+		}
+	}
+	return store.UpdateRef(cmd.name, cmd.oldID, cmd.newID)
+}
+
+// teeByteReader is a packfile.ByteReader that writes everything
+// it reads to w.
+type teeByteReader struct {
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (t *teeByteReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.w.Write(p[:n])
+	return n, err
+}
+
+func (t *teeByteReader) ReadByte() (byte, error) {
+	b, err := t.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	t.w.WriteByte(b)
+	return b, nil
+}
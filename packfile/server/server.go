@@ -0,0 +1,223 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package server provides a Git packfile protocol server: the counterpart to
+package client. It serves the git-upload-pack service (used by `git fetch`)
+and the git-receive-pack service (used by `git push`) either over the smart
+HTTP protocol or over a bidirectional stream like the standard input and
+output of a process started over SSH.
+
+A Server reads objects and refs from a Store. *gitrepo.Repository
+implements Store, so a Server can serve a repository on the local filesystem
+without running Git. MemoryStore is a Store that keeps its contents in memory,
+which is useful for testing clients.
+
+See https://git-scm.com/docs/pack-protocol,
+https://git-scm.com/docs/protocol-v2, and
+https://git-scm.com/docs/http-protocol for details on the protocols.
+*/
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"sort"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/object"
+)
+
+// A Store provides access to the objects and refs of a repository.
+// Its methods may be called from multiple goroutines simultaneously.
+type Store interface {
+	// ObjectFormat returns the object format of the repository.
+	ObjectFormat() githash.ObjectFormat
+
+	// ListRefs returns the repository's refs, including HEAD.
+	// If refPrefixes is given, then only refs that start with one of the given
+	// strings need to be returned. A symbolic ref that points to a ref that
+	// does not exist has a zero ObjectID.
+	ListRefs(refPrefixes ...string) (map[githash.Ref]*gitrepo.Ref, error)
+
+	// OpenObject returns the type and size of the object with the given ID
+	// and a reader for the object's contents. If the object does not exist,
+	// OpenObject must return an error for which errors.Is(err, fs.ErrNotExist)
+	// reports true.
+	OpenObject(id githash.ObjectID) (object.Prefix, io.ReadCloser, error)
+}
+
+// An ObjectChecker is a Store that can report whether it contains an object
+// more cheaply than opening the object. The Server uses HasObject instead of
+// OpenObject to check for objects if its Store implements ObjectChecker.
+type ObjectChecker interface {
+	Store
+
+	// HasObject reports whether the store contains an object with the given ID.
+	HasObject(id githash.ObjectID) (bool, error)
+}
+
+// A WritableStore is a Store that can accept pushes.
+type WritableStore interface {
+	Store
+
+	// WriteObject stores an object and returns its ID.
+	// r will contain exactly prefix.Size bytes.
+	WriteObject(prefix object.Prefix, r io.Reader) (githash.ObjectID, error)
+
+	// UpdateRef changes the ref with the given name from oldID to newID.
+	// If oldID is zero, then the ref must not exist. If newID is zero,
+	// then the ref is deleted. UpdateRef must return an error without
+	// modifying the ref if the ref's current value is not oldID.
+	UpdateRef(name githash.Ref, oldID, newID githash.ObjectID) error
+}
+
+// Options holds optional arguments for creating a Server.
+type Options struct {
+	// Agent is the agent string sent to clients. Defaults to "gg-git".
+	Agent string
+
+	// ErrorLog specifies an optional logger for errors that occur while
+	// serving HTTP requests after the response has started, like a client
+	// disconnecting or a Store returning an error. If nil, errors are logged
+	// using the log package's standard logger.
+	ErrorLog *log.Logger
+}
+
+// Server serves Git repository data from a Store.
+// It is safe to call Server's methods from multiple goroutines simultaneously.
+type Server struct {
+	store    Store
+	agent    string
+	errorLog *log.Logger
+}
+
+// New returns a new Server that serves the given store. If store implements
+// WritableStore, then the Server accepts pushes. Otherwise, the Server only
+// allows fetches.
+func New(store Store, opts *Options) *Server {
+	srv := &Server{
+		store: store,
+		agent: "gg-git",
+	}
+	if opts != nil && opts.Agent != "" {
+		srv.agent = opts.Agent
+	}
+	if opts != nil {
+		srv.errorLog = opts.ErrorLog
+	}
+	return srv
+}
+
+func (srv *Server) logf(format string, args ...interface{}) {
+	if srv.errorLog != nil {
+		srv.errorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+func (srv *Server) writableStore() (WritableStore, bool) {
+	ws, ok := srv.store.(WritableStore)
+	return ws, ok
+}
+
+// listRefs returns the store's refs sorted by name, with HEAD first.
+func (srv *Server) listRefs(refPrefixes ...string) ([]*gitrepo.Ref, error) {
+	refMap, err := srv.store.ListRefs(refPrefixes...)
+	if err != nil {
+		return nil, err
+	}
+	format := srv.store.ObjectFormat()
+	refs := make([]*gitrepo.Ref, 0, len(refMap))
+	for _, ref := range refMap {
+		if !ref.ObjectID.IsZero() && ref.ObjectID.ObjectFormat() != format {
+			return nil, fmt.Errorf("ref %s: object ID is not %v", ref.Name, format)
+		}
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Name == githash.Head || refs[j].Name == githash.Head {
+			return refs[i].Name == githash.Head
+		}
+		return refs[i].Name < refs[j].Name
+	})
+	return refs, nil
+}
+
+// readObject reads the entire content of an object.
+func (srv *Server) readObject(id githash.ObjectID) (object.Type, []byte, error) {
+	prefix, rc, err := srv.store.OpenObject(id)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rc.Close()
+	data := make([]byte, int(prefix.Size))
+	if _, err := io.ReadFull(rc, data); err != nil {
+		return "", nil, fmt.Errorf("read object %v: %w", id, err)
+	}
+	return prefix.Type, data, nil
+}
+
+// hasObject reports whether the store contains the given object.
+func (srv *Server) hasObject(id githash.ObjectID) (bool, error) {
+	if id.ObjectFormat() != srv.store.ObjectFormat() {
+		return false, nil
+	}
+	if oc, ok := srv.store.(ObjectChecker); ok {
+		return oc.HasObject(id)
+	}
+	_, rc, err := srv.store.OpenObject(id)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	rc.Close()
+	return true, nil
+}
+
+// peel follows the chain of annotated tags starting at id and returns
+// the first non-tag object. peel returns the zero ObjectID if id is not a tag.
+func (srv *Server) peel(id githash.ObjectID) (githash.ObjectID, error) {
+	peeled := githash.ObjectID{}
+	curr := id
+	for i := 0; i < maxTagDepth; i++ {
+		typ, data, err := srv.readObject(curr)
+		if err != nil {
+			return githash.ObjectID{}, fmt.Errorf("peel %v: %w", id, err)
+		}
+		if typ != object.TypeTag {
+			return peeled, nil
+		}
+		tag, err := object.ParseTag(data)
+		if err != nil {
+			return githash.ObjectID{}, fmt.Errorf("peel %v: %w", id, err)
+		}
+		curr = tag.ObjectID
+		peeled = curr
+	}
+	return githash.ObjectID{}, fmt.Errorf("peel %v: tags nested too deeply", id)
+}
+
+// maxTagDepth is the maximum number of tags that will be followed when
+// peeling a tag.
+const maxTagDepth = 32
@@ -0,0 +1,1032 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/internal/pktline"
+	"gg-scm.io/pkg/git/object"
+	"gg-scm.io/pkg/git/packfile"
+	"gg-scm.io/pkg/git/packfile/client"
+	"github.com/google/go-cmp/cmp"
+)
+
+var testFormats = []githash.ObjectFormat{githash.SHA1Format, githash.SHA256Format}
+
+func TestPull(t *testing.T) {
+	for _, format := range testFormats {
+		t.Run(format.String(), func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore(format)
+			objects := writeTestObjects(t, store)
+			httpServer := httptest.NewServer(New(store, nil))
+			t.Cleanup(httpServer.Close)
+			u, err := client.ParseURL(httpServer.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			remote, err := client.NewRemote(u, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream, err := remote.StartPull(ctx)
+			if err != nil {
+				t.Fatal("StartPull:", err)
+			}
+			defer func() {
+				if err := stream.Close(); err != nil {
+					t.Error("stream.Close():", err)
+				}
+			}()
+			if got := stream.ObjectFormat(); got != format {
+				t.Errorf("stream.ObjectFormat() = %v; want %v", got, format)
+			}
+
+			t.Run("ListRefs", func(t *testing.T) {
+				got, err := stream.ListRefs()
+				if err != nil {
+					t.Fatal("ListRefs:", err)
+				}
+				want := map[githash.Ref]*client.Ref{
+					githash.Head: {
+						Name:         githash.Head,
+						ObjectID:     objects.commit2,
+						SymrefTarget: githash.BranchRef("main"),
+					},
+					githash.BranchRef("main"): {
+						Name:     githash.BranchRef("main"),
+						ObjectID: objects.commit2,
+					},
+					githash.TagRef("v1"): {
+						Name:           githash.TagRef("v1"),
+						ObjectID:       objects.tag1,
+						PeeledObjectID: objects.commit1,
+					},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("ListRefs() (-want +got):\n%s", diff)
+				}
+			})
+
+			tests := []struct {
+				name string
+				req  *client.PullRequest
+				want []githash.ObjectID
+			}{
+				{
+					name: "All",
+					req: &client.PullRequest{
+						Want: []githash.ObjectID{objects.commit2},
+					},
+					want: []githash.ObjectID{
+						objects.blob1,
+						objects.tree1,
+						objects.commit1,
+						objects.blob2,
+						objects.subtree2,
+						objects.tree2,
+						objects.commit2,
+					},
+				},
+				{
+					name: "Incremental",
+					req: &client.PullRequest{
+						Want: []githash.ObjectID{objects.commit2},
+						Have: []githash.ObjectID{objects.commit1},
+					},
+					want: []githash.ObjectID{
+						objects.blob2,
+						objects.subtree2,
+						objects.tree2,
+						objects.commit2,
+					},
+				},
+				{
+					name: "IncludeTag",
+					req: &client.PullRequest{
+						Want:       []githash.ObjectID{objects.commit1},
+						IncludeTag: true,
+					},
+					want: []githash.ObjectID{
+						objects.blob1,
+						objects.tree1,
+						objects.commit1,
+						objects.tag1,
+					},
+				},
+			}
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					resp, err := stream.Negotiate(test.req)
+					if err != nil {
+						t.Fatal("Negotiate:", err)
+					}
+					if resp.Packfile == nil {
+						t.Fatal("Negotiate returned nil Packfile")
+					}
+					defer func() {
+						if err := resp.Packfile.Close(); err != nil {
+							t.Error("resp.Packfile.Close():", err)
+						}
+					}()
+					got, err := readPackfile(resp.Packfile, format)
+					if err != nil {
+						t.Error(err)
+					}
+					want := make(map[githash.ObjectID][]byte)
+					for _, id := range test.want {
+						want[id] = store.objects[id].data
+					}
+					if diff := cmp.Diff(want, got); diff != "" {
+						t.Errorf("objects (-want +got):\n%s", diff)
+					}
+				})
+			}
+
+			t.Run("HaveMore", func(t *testing.T) {
+				unknown := format.Sum(format.New())
+				resp, err := stream.Negotiate(&client.PullRequest{
+					Want:     []githash.ObjectID{objects.commit2},
+					Have:     []githash.ObjectID{unknown},
+					HaveMore: true,
+				})
+				if err != nil {
+					t.Fatal("Negotiate:", err)
+				}
+				if resp.Packfile != nil {
+					resp.Packfile.Close()
+					t.Error("Negotiate returned a Packfile")
+				}
+				if len(resp.Acks) > 0 {
+					t.Errorf("Acks = %v; want none", resp.Acks)
+				}
+			})
+
+			t.Run("Ready", func(t *testing.T) {
+				unknown := format.Sum(format.New())
+				resp, err := stream.Negotiate(&client.PullRequest{
+					Want:     []githash.ObjectID{objects.commit2},
+					Have:     []githash.ObjectID{objects.commit1, unknown},
+					HaveMore: true,
+				})
+				if err != nil {
+					t.Fatal("Negotiate:", err)
+				}
+				wantAcks := map[githash.ObjectID]struct{}{objects.commit1: {}}
+				if diff := cmp.Diff(wantAcks, resp.Acks); diff != "" {
+					t.Errorf("Acks (-want +got):\n%s", diff)
+				}
+				if resp.Packfile == nil {
+					t.Fatal("Negotiate did not return a Packfile after the remote found a common base")
+				}
+				defer func() {
+					if err := resp.Packfile.Close(); err != nil {
+						t.Error("resp.Packfile.Close():", err)
+					}
+				}()
+				got, err := readPackfile(resp.Packfile, format)
+				if err != nil {
+					t.Error(err)
+				}
+				want := make(map[githash.ObjectID][]byte)
+				for _, id := range []githash.ObjectID{objects.commit2, objects.tree2, objects.subtree2, objects.blob2} {
+					want[id] = store.objects[id].data
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("objects (-want +got):\n%s", diff)
+				}
+			})
+
+			t.Run("NotOurRef", func(t *testing.T) {
+				resp, err := stream.Negotiate(&client.PullRequest{
+					Want: []githash.ObjectID{objects.blob1},
+				})
+				if err == nil {
+					if resp.Packfile != nil {
+						resp.Packfile.Close()
+					}
+					t.Error("Negotiate did not return an error")
+				}
+			})
+		})
+	}
+}
+
+func TestPush(t *testing.T) {
+	for _, format := range testFormats {
+		t.Run(format.String(), func(t *testing.T) {
+			ctx := context.Background()
+			src := NewMemoryStore(format)
+			objects := writeTestObjects(t, src)
+			store := NewMemoryStore(format)
+			httpServer := httptest.NewServer(New(store, nil))
+			t.Cleanup(httpServer.Close)
+			u, err := client.ParseURL(httpServer.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			remote, err := client.NewRemote(u, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Push everything.
+			stream, err := remote.StartPush(ctx)
+			if err != nil {
+				t.Fatal("StartPush:", err)
+			}
+			err = stream.WriteCommands(
+				&client.PushCommand{RefName: githash.BranchRef("main"), New: objects.commit2},
+				&client.PushCommand{RefName: githash.TagRef("v1"), New: objects.tag1},
+			)
+			if err != nil {
+				t.Fatal("WriteCommands:", err)
+			}
+			var buildObjects []packfile.BuildObject
+			for _, obj := range src.objects {
+				buildObjects = append(buildObjects, packfile.BuildObject{
+					Type: packObjectType(obj.typ),
+					Data: obj.data,
+				})
+			}
+			if err := packfile.Build(stream, buildObjects, &packfile.BuildOptions{ObjectFormat: format}); err != nil {
+				t.Error("packfile.Build:", err)
+			}
+			if err := stream.Close(); err != nil {
+				t.Error("PushStream.Close:", err)
+			}
+			if diff := cmp.Diff(src.objects, store.objects, cmp.AllowUnexported(memoryObject{})); diff != "" {
+				t.Errorf("objects after push (-want +got):\n%s", diff)
+			}
+			wantRefs := map[githash.Ref]githash.ObjectID{
+				githash.BranchRef("main"): objects.commit2,
+				githash.TagRef("v1"):      objects.tag1,
+			}
+			if diff := cmp.Diff(wantRefs, store.refs); diff != "" {
+				t.Errorf("refs after push (-want +got):\n%s", diff)
+			}
+
+			// Stale update.
+			stream, err = remote.StartPush(ctx)
+			if err != nil {
+				t.Fatal("StartPush:", err)
+			}
+			err = stream.WriteCommands(&client.PushCommand{
+				RefName: githash.TagRef("v1"),
+				Old:     objects.commit1,
+			})
+			if err != nil {
+				t.Fatal("WriteCommands:", err)
+			}
+			if err := stream.Close(); err == nil {
+				t.Error("PushStream.Close did not return an error for stale delete")
+			}
+
+			// Delete.
+			stream, err = remote.StartPush(ctx)
+			if err != nil {
+				t.Fatal("StartPush:", err)
+			}
+			err = stream.WriteCommands(&client.PushCommand{
+				RefName: githash.TagRef("v1"),
+				Old:     objects.tag1,
+			})
+			if err != nil {
+				t.Fatal("WriteCommands:", err)
+			}
+			if err := stream.Close(); err != nil {
+				t.Error("PushStream.Close:", err)
+			}
+			delete(wantRefs, githash.TagRef("v1"))
+			if diff := cmp.Diff(wantRefs, store.refs); diff != "" {
+				t.Errorf("refs after delete (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPushMissingObjects(t *testing.T) {
+	ctx := context.Background()
+	format := githash.SHA1Format
+	src := NewMemoryStore(format)
+	objects := writeTestObjects(t, src)
+	store := NewMemoryStore(format)
+	httpServer := httptest.NewServer(New(store, nil))
+	t.Cleanup(httpServer.Close)
+	u, err := client.ParseURL(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := client.NewRemote(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := func(cmd *client.PushCommand, ids ...githash.ObjectID) error {
+		t.Helper()
+		stream, err := remote.StartPush(ctx)
+		if err != nil {
+			t.Fatal("StartPush:", err)
+		}
+		if err := stream.WriteCommands(cmd); err != nil {
+			stream.Close()
+			t.Fatal("WriteCommands:", err)
+		}
+		var buildObjects []packfile.BuildObject
+		for _, id := range ids {
+			obj := src.objects[id]
+			buildObjects = append(buildObjects, packfile.BuildObject{
+				Type: packObjectType(obj.typ),
+				Data: obj.data,
+			})
+		}
+		if err := packfile.Build(stream, buildObjects, &packfile.BuildOptions{ObjectFormat: format}); err != nil {
+			stream.Close()
+			t.Fatal("packfile.Build:", err)
+		}
+		return stream.Close()
+	}
+
+	// Commit without its tree.
+	err = push(&client.PushCommand{RefName: githash.BranchRef("bad"), New: objects.commit1}, objects.commit1)
+	if err == nil {
+		t.Error("Pushing commit without its tree did not return an error")
+	}
+	if len(store.refs) > 0 {
+		t.Errorf("refs after pushing commit without its tree = %v; want none", store.refs)
+	}
+	if _, ok := store.objects[objects.commit1]; ok {
+		t.Error("Commit from rejected push was written to store")
+	}
+
+	// Complete commit.
+	err = push(
+		&client.PushCommand{RefName: githash.BranchRef("main"), New: objects.commit1},
+		objects.commit1, objects.tree1, objects.blob1,
+	)
+	if err != nil {
+		t.Fatal("Pushing complete commit:", err)
+	}
+
+	// Child commit without the objects the remote already has.
+	err = push(
+		&client.PushCommand{RefName: githash.BranchRef("main"), Old: objects.commit1, New: objects.commit2},
+		objects.commit2, objects.tree2, objects.subtree2, objects.blob2,
+	)
+	if err != nil {
+		t.Fatal("Pushing child commit:", err)
+	}
+
+	wantRefs := map[githash.Ref]githash.ObjectID{
+		githash.BranchRef("main"): objects.commit2,
+	}
+	if diff := cmp.Diff(wantRefs, store.refs); diff != "" {
+		t.Errorf("refs after pushes (-want +got):\n%s", diff)
+	}
+}
+
+func TestPushReadOnly(t *testing.T) {
+	ctx := context.Background()
+	httpServer := httptest.NewServer(New(readOnlyStore{NewMemoryStore(githash.SHA1Format)}, nil))
+	t.Cleanup(httpServer.Close)
+	u, err := client.ParseURL(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := client.NewRemote(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream, err := remote.StartPush(ctx); err == nil {
+		stream.Close()
+		t.Error("StartPush did not return an error")
+	}
+}
+
+type readOnlyStore struct {
+	s *MemoryStore
+}
+
+func (s readOnlyStore) ObjectFormat() githash.ObjectFormat {
+	return s.s.ObjectFormat()
+}
+
+func (s readOnlyStore) ListRefs(refPrefixes ...string) (map[githash.Ref]*gitrepo.Ref, error) {
+	return s.s.ListRefs(refPrefixes...)
+}
+
+func (s readOnlyStore) OpenObject(id githash.ObjectID) (object.Prefix, io.ReadCloser, error) {
+	return s.s.OpenObject(id)
+}
+
+func TestHTTPErrorLog(t *testing.T) {
+	logBuf := new(bytes.Buffer)
+	srv := New(brokenStore{NewMemoryStore(githash.SHA1Format)}, &Options{
+		ErrorLog: log.New(logBuf, "", 0),
+	})
+	httpServer := httptest.NewServer(srv)
+	t.Cleanup(httpServer.Close)
+	resp, err := http.Get(httpServer.URL + "/info/refs?service=git-upload-pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if got := logBuf.String(); !strings.Contains(got, errBrokenStore.Error()) {
+		t.Errorf("log = %q; want to contain %q", got, errBrokenStore.Error())
+	}
+}
+
+var errBrokenStore = errors.New("store is broken")
+
+// brokenStore is a Store that fails to list refs.
+type brokenStore struct {
+	s *MemoryStore
+}
+
+func (s brokenStore) ObjectFormat() githash.ObjectFormat {
+	return s.s.ObjectFormat()
+}
+
+func (s brokenStore) ListRefs(refPrefixes ...string) (map[githash.Ref]*gitrepo.Ref, error) {
+	return nil, errBrokenStore
+}
+
+func (s brokenStore) OpenObject(id githash.ObjectID) (object.Prefix, io.ReadCloser, error) {
+	return s.s.OpenObject(id)
+}
+
+func TestUploadPackV1Stateless(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(githash.SHA1Format)
+	objects := writeTestObjects(t, store)
+	srv := New(store, nil)
+	unknown := githash.SHA1Format.Sum(githash.SHA1Format.New())
+
+	var req []byte
+	req = pktline.AppendString(req, "want "+objects.commit2.String()+" multi_ack_detailed side-band-64k ofs-delta\n")
+	req = pktline.AppendFlush(req)
+	req = pktline.AppendString(req, "have "+unknown.String()+"\n")
+	req = pktline.AppendString(req, "have "+objects.commit1.String()+"\n")
+	t.Run("Flush", func(t *testing.T) {
+		req := pktline.AppendFlush(req[:len(req):len(req)])
+		out := new(bytes.Buffer)
+		if err := srv.uploadPack(ctx, newConn(bytes.NewReader(req), out, true), 1); err != nil {
+			t.Error("uploadPack:", err)
+		}
+		// The response is not terminated by a flush.
+		r := pktline.NewReader(out)
+		var got []string
+		for out.Len() > 0 && r.Next() {
+			line, err := r.Text()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, string(line))
+		}
+		if err := r.Err(); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"ACK " + objects.commit1.String() + " common",
+			"NAK",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("response (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("Done", func(t *testing.T) {
+		req := pktline.AppendString(req[:len(req):len(req)], "done\n")
+		out := new(bytes.Buffer)
+		if err := srv.uploadPack(ctx, newConn(bytes.NewReader(req), out, true), 1); err != nil {
+			t.Error("uploadPack:", err)
+		}
+		r := pktline.NewReader(out)
+		got, n, err := readLines(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"ACK " + objects.commit1.String() + " common",
+			"ACK " + objects.commit1.String(),
+		}
+		if diff := cmp.Diff(want, got[:n]); diff != "" {
+			t.Errorf("response (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestUploadPackV1Ready(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(githash.SHA1Format)
+	objects := writeTestObjects(t, store)
+	srv := New(store, nil)
+	unknown := githash.SHA1Format.Sum(githash.SHA1Format.New())
+
+	tests := []struct {
+		name     string
+		multiAck string
+		want     githash.ObjectID
+		haves    []githash.ObjectID
+		response []string
+	}{
+		{
+			name:     "Flush",
+			multiAck: "multi_ack_detailed",
+			want:     objects.commit2,
+			haves:    []githash.ObjectID{objects.commit1},
+			response: []string{
+				"ACK " + objects.commit1.String() + " common",
+				"ACK " + objects.commit1.String() + " ready",
+				"NAK",
+			},
+		},
+		{
+			name:     "UnknownAfterCommon",
+			multiAck: "multi_ack_detailed",
+			want:     objects.commit2,
+			haves:    []githash.ObjectID{objects.commit1, unknown},
+			response: []string{
+				"ACK " + objects.commit1.String() + " common",
+				"ACK " + unknown.String() + " ready",
+				"NAK",
+			},
+		},
+		{
+			name:     "MultiAck",
+			multiAck: "multi_ack",
+			want:     objects.commit2,
+			haves:    []githash.ObjectID{objects.commit1, unknown},
+			response: []string{
+				"ACK " + objects.commit1.String() + " continue",
+				"ACK " + unknown.String() + " continue",
+				"NAK",
+			},
+		},
+		{
+			name:     "NoCommonBase",
+			multiAck: "multi_ack_detailed",
+			want:     objects.commit1,
+			haves:    []githash.ObjectID{objects.commit2},
+			response: []string{
+				"ACK " + objects.commit2.String() + " common",
+				"NAK",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var req []byte
+			req = pktline.AppendString(req, "want "+test.want.String()+" "+test.multiAck+" side-band-64k\n")
+			req = pktline.AppendFlush(req)
+			for _, have := range test.haves {
+				req = pktline.AppendString(req, "have "+have.String()+"\n")
+			}
+			req = pktline.AppendFlush(req)
+			out := new(bytes.Buffer)
+			if err := srv.uploadPack(ctx, newConn(bytes.NewReader(req), out, true), 1); err != nil {
+				t.Error("uploadPack:", err)
+			}
+			r := pktline.NewReader(out)
+			var got []string
+			for out.Len() > 0 && r.Next() {
+				line, err := r.Text()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, string(line))
+			}
+			if err := r.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.response, got); diff != "" {
+				t.Errorf("response (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUploadPackStream(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(githash.SHA1Format)
+	objects := writeTestObjects(t, store)
+	srv := New(store, nil)
+	unknown := githash.SHA1Format.Sum(githash.SHA1Format.New())
+
+	clientConn, serverConn := newPipe()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.UploadPack(ctx, serverConn, serverConn, "")
+		serverConn.Close()
+	}()
+	r := pktline.NewReader(clientConn)
+	adv, _, err := readLines(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adv) == 0 || !strings.HasPrefix(adv[0], objects.commit2.String()+" HEAD\x00") {
+		t.Errorf("advertisement = %q; want first line to start with %q", adv, objects.commit2.String()+" HEAD\x00")
+	}
+
+	var req []byte
+	req = pktline.AppendString(req, "want "+objects.commit2.String()+" multi_ack side-band\n")
+	req = pktline.AppendFlush(req)
+	req = pktline.AppendString(req, "have "+unknown.String()+"\n")
+	req = pktline.AppendFlush(req)
+	if _, err := clientConn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Next() {
+		t.Fatal(r.Err())
+	}
+	if line, err := r.Text(); err != nil || string(line) != "NAK" {
+		t.Errorf("first round response = %q, %v; want \"NAK\"", line, err)
+	}
+
+	req = req[:0]
+	req = pktline.AppendString(req, "have "+objects.commit1.String()+"\n")
+	req = pktline.AppendString(req, "done\n")
+	if _, err := clientConn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	got, n, err := readLines(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ACK " + objects.commit1.String() + " continue",
+		"ACK " + objects.commit1.String(),
+	}
+	if diff := cmp.Diff(want, got[:n]); diff != "" {
+		t.Errorf("response (-want +got):\n%s", diff)
+	}
+	clientConn.CloseWrite()
+	if err := <-errc; err != nil {
+		t.Error("UploadPack:", err)
+	}
+}
+
+func TestProtocolVersion(t *testing.T) {
+	tests := []struct {
+		gitProtocol string
+		want        int
+	}{
+		{"", 0},
+		{"version=1", 1},
+		{"version=2", 2},
+		{"object-format=sha256:version=2", 2},
+		{"version=1:version=2", 2},
+		{"version=3", 0},
+		{"version=x", 0},
+	}
+	for _, test := range tests {
+		if got := protocolVersion(test.gitProtocol); got != test.want {
+			t.Errorf("protocolVersion(%q) = %d; want %d", test.gitProtocol, got, test.want)
+		}
+	}
+}
+
+func TestGit(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	store := NewMemoryStore(githash.SHA1Format)
+	httpServer := httptest.NewServer(New(store, nil))
+	t.Cleanup(httpServer.Close)
+	repoURL := httpServer.URL + "/repo.git"
+
+	dir := t.TempDir()
+	if err := git.Custom(dir, localGit, localGit).Init(ctx, "src"); err != nil {
+		t.Fatal(err)
+	}
+	srcDir := filepath.Join(dir, "src")
+	g := git.Custom(srcDir, localGit, localGit)
+	commit := func(name, content string) githash.ObjectID {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(srcDir, name)), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Add(ctx, []git.Pathspec{git.LiteralPath(name)}, git.AddOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Commit(ctx, "Add "+name, testCommitOptions); err != nil {
+			t.Fatal(err)
+		}
+		rev, err := g.ParseRev(ctx, "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rev.Commit
+	}
+	tip := commit("foo.txt", "Hello, World!\n")
+	err = g.Run(ctx,
+		"-c", "user.name=Octocat", "-c", "user.email=octocat@example.com",
+		"tag", "-a", "-m", "Version 1", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Run(ctx, "push", repoURL, "HEAD:refs/heads/main", "refs/tags/v1"); err != nil {
+		t.Fatal("git push:", err)
+	}
+	if got := store.refs[githash.BranchRef("main")]; got != tip {
+		t.Errorf("after first push, main = %v; want %v", got, tip)
+	}
+
+	for version := 0; version <= 2; version++ {
+		t.Run(fmt.Sprintf("Version%d", version), func(t *testing.T) {
+			versionFlag := fmt.Sprintf("protocol.version=%d", version)
+			cloneDir := filepath.Join(t.TempDir(), "clone")
+			if err := g.Run(ctx, "-c", versionFlag, "clone", repoURL, cloneDir); err != nil {
+				t.Fatal("git clone:", err)
+			}
+			clone := g.WithDir(cloneDir)
+			if err := clone.Run(ctx, "fsck", "--strict"); err != nil {
+				t.Error("git fsck:", err)
+			}
+			if rev, err := clone.ParseRev(ctx, "HEAD"); err != nil {
+				t.Error(err)
+			} else if rev.Commit != tip {
+				t.Errorf("HEAD after clone = %v; want %v", rev.Commit, tip)
+			}
+
+			// Push a new commit from the source and fetch it into the clone.
+			tip = commit(fmt.Sprintf("v%d/bar.txt", version), "Goodbye\n")
+			if err := g.Run(ctx, "-c", versionFlag, "push", repoURL, "HEAD:refs/heads/main"); err != nil {
+				t.Fatal("git push:", err)
+			}
+			if err := clone.Run(ctx, "-c", versionFlag, "fetch", "origin"); err != nil {
+				t.Fatal("git fetch:", err)
+			}
+			if err := clone.Run(ctx, "fsck", "--strict"); err != nil {
+				t.Error("git fsck:", err)
+			}
+			if rev, err := clone.ParseRev(ctx, "refs/remotes/origin/main"); err != nil {
+				t.Error(err)
+			} else if rev.Commit != tip {
+				t.Errorf("origin/main after fetch = %v; want %v", rev.Commit, tip)
+			}
+		})
+	}
+}
+
+func TestGitRepositoryStore(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	dir := t.TempDir()
+	if err := git.Custom(dir, localGit, localGit).Init(ctx, "src"); err != nil {
+		t.Fatal(err)
+	}
+	srcDir := filepath.Join(dir, "src")
+	g := git.Custom(srcDir, localGit, localGit)
+	if err := os.WriteFile(filepath.Join(srcDir, "foo.txt"), []byte("Hello, World!\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Add(ctx, []git.Pathspec{git.LiteralPath("foo.txt")}, git.AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Commit(ctx, "Initial import", testCommitOptions); err != nil {
+		t.Fatal(err)
+	}
+	rev, err := g.ParseRev(ctx, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := gitrepo.Open(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	httpServer := httptest.NewServer(New(repo, nil))
+	t.Cleanup(httpServer.Close)
+
+	cloneDir := filepath.Join(dir, "clone")
+	if err := g.Run(ctx, "clone", httpServer.URL, cloneDir); err != nil {
+		t.Fatal("git clone:", err)
+	}
+	clone := g.WithDir(cloneDir)
+	if err := clone.Run(ctx, "fsck", "--strict"); err != nil {
+		t.Error("git fsck:", err)
+	}
+	if got, err := clone.ParseRev(ctx, "HEAD"); err != nil {
+		t.Error(err)
+	} else if got.Commit != rev.Commit {
+		t.Errorf("HEAD after clone = %v; want %v", got.Commit, rev.Commit)
+	}
+	if err := clone.Run(ctx, "push", "origin", "HEAD:refs/heads/other"); err == nil {
+		t.Error("git push to read-only store succeeded")
+	}
+}
+
+var testCommitOptions = git.CommitOptions{
+	Author:     "Octocat <octocat@example.com>",
+	AuthorTime: time.Date(2020, time.January, 9, 14, 50, 0, 0, time.UTC),
+	Committer:  "Octocat <octocat@example.com>",
+	CommitTime: time.Date(2020, time.January, 9, 14, 50, 0, 0, time.UTC),
+}
+
+// testObjects is the set of object IDs written by writeTestObjects.
+type testObjects struct {
+	blob1    githash.ObjectID
+	tree1    githash.ObjectID
+	commit1  githash.ObjectID
+	tag1     githash.ObjectID
+	blob2    githash.ObjectID
+	subtree2 githash.ObjectID
+	tree2    githash.ObjectID
+	commit2  githash.ObjectID
+}
+
+// writeTestObjects writes a repository with two commits and an annotated tag
+// to the store. refs/heads/main points to the second commit
+// and refs/tags/v1 points to the tag.
+func writeTestObjects(tb testing.TB, store *MemoryStore) *testObjects {
+	tb.Helper()
+	format := store.ObjectFormat()
+	objects := new(testObjects)
+	write := func(typ object.Type, data []byte) githash.ObjectID {
+		tb.Helper()
+		id, err := store.WriteObject(object.Prefix{Type: typ, Size: int64(len(data))}, bytes.NewReader(data))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return id
+	}
+	const author object.User = "Octocat <octocat@example.com>"
+	commitTime := time.Date(2020, time.January, 9, 14, 50, 0, 0, time.FixedZone("-0800", -8*60*60))
+
+	objects.blob1 = write(object.TypeBlob, []byte("Hello, World!\n"))
+	objects.tree1 = write(object.TypeTree, mustMarshalBinary(tb, object.Tree{
+		{Name: "foo.txt", Mode: object.ModePlain, ObjectID: objects.blob1},
+	}))
+	objects.commit1 = write(object.TypeCommit, mustMarshalBinary(tb, &object.Commit{
+		Tree:       objects.tree1,
+		Author:     author,
+		AuthorTime: commitTime,
+		Committer:  author,
+		CommitTime: commitTime,
+		Message:    "Initial import",
+	}))
+	objects.tag1 = write(object.TypeTag, mustMarshalBinary(tb, &object.Tag{
+		ObjectID:   objects.commit1,
+		ObjectType: object.TypeCommit,
+		Name:       "v1",
+		Tagger:     author,
+		Time:       commitTime,
+		Message:    "Version 1\n",
+	}))
+	objects.blob2 = write(object.TypeBlob, []byte("Goodbye\n"))
+	objects.subtree2 = write(object.TypeTree, mustMarshalBinary(tb, object.Tree{
+		{Name: "bar.txt", Mode: object.ModePlain, ObjectID: objects.blob2},
+	}))
+	tree2 := object.Tree{
+		{Name: "dir", Mode: object.ModeDir, ObjectID: objects.subtree2},
+		{Name: "foo.txt", Mode: object.ModePlain, ObjectID: objects.blob1},
+		{Name: "sub", Mode: object.ModeGitlink, ObjectID: format.Sum(format.New())},
+	}
+	if err := tree2.Sort(); err != nil {
+		tb.Fatal(err)
+	}
+	objects.tree2 = write(object.TypeTree, mustMarshalBinary(tb, tree2))
+	objects.commit2 = write(object.TypeCommit, mustMarshalBinary(tb, &object.Commit{
+		Tree:       objects.tree2,
+		Parents:    []githash.ObjectID{objects.commit1},
+		Author:     author,
+		AuthorTime: commitTime.Add(time.Hour),
+		Committer:  author,
+		CommitTime: commitTime.Add(time.Hour),
+		Message:    "Add bar.txt",
+	}))
+
+	if err := store.UpdateRef(githash.BranchRef("main"), githash.ObjectID{}, objects.commit2); err != nil {
+		tb.Fatal(err)
+	}
+	if err := store.UpdateRef(githash.TagRef("v1"), githash.ObjectID{}, objects.tag1); err != nil {
+		tb.Fatal(err)
+	}
+	return objects
+}
+
+// readLines reads data packets up to the next flush packet or,
+// if a sideband packet is encountered, until the end of the stream.
+// It returns the lines read and the number of lines before the
+// first sideband packet.
+func readLines(r *pktline.Reader) (lines []string, n int, err error) {
+	n = -1
+	for r.Next() && r.Type() != pktline.Flush {
+		line, err := r.Text()
+		if err != nil {
+			return lines, n, err
+		}
+		if n == -1 && len(line) > 0 && line[0] <= sidebandError {
+			n = len(lines)
+		}
+		lines = append(lines, string(line))
+	}
+	if err := r.Err(); err != nil {
+		return lines, n, err
+	}
+	if n == -1 {
+		n = len(lines)
+	}
+	return lines, n, nil
+}
+
+// readPackfile reads all the objects in a packfile, undeltifying them as needed.
+func readPackfile(r io.Reader, format githash.ObjectFormat) (map[githash.ObjectID][]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := packfile.BuildIndex(bytes.NewReader(data), int64(len(data)), &packfile.IndexOptions{
+		ObjectFormat: format,
+	})
+	if err != nil {
+		return nil, err
+	}
+	objects := make(map[githash.ObjectID][]byte)
+	u := new(packfile.Undeltifier)
+	rs := packfile.NewBufferedReadSeeker(bytes.NewReader(data))
+	for i, id := range idx.ObjectIDs {
+		_, objReader, err := u.Undeltify(rs, idx.Offsets[i], &packfile.UndeltifyOptions{Index: idx})
+		if err != nil {
+			return objects, err
+		}
+		objects[id], err = io.ReadAll(objReader)
+		if err != nil {
+			return objects, err
+		}
+	}
+	return objects, nil
+}
+
+func mustMarshalBinary(tb testing.TB, m encoding.BinaryMarshaler) []byte {
+	tb.Helper()
+	data, err := m.MarshalBinary()
+	if err != nil {
+		tb.Fatal("MarshalBinary:", err)
+	}
+	return data
+}
+
+// pipeConn is one end of an in-memory bidirectional stream.
+type pipeConn struct {
+	*io.PipeReader
+	w *io.PipeWriter
+}
+
+func newPipe() (*pipeConn, *pipeConn) {
+	r1, w1 := io.Pipe()
+	r2, w2 := io.Pipe()
+	return &pipeConn{r1, w2}, &pipeConn{r2, w1}
+}
+
+func (c *pipeConn) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+func (c *pipeConn) CloseWrite() error {
+	return c.w.Close()
+}
+
+func (c *pipeConn) Close() error {
+	c.PipeReader.Close()
+	return c.w.Close()
+}
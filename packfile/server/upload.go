@@ -0,0 +1,669 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/internal/pktline"
+)
+
+// Capability names. See https://git-scm.com/docs/protocol-capabilities
+const (
+	agentCap            = "agent"
+	deleteRefsCap       = "delete-refs"
+	includeTagCap       = "include-tag"
+	multiAckCap         = "multi_ack"
+	multiAckDetailedCap = "multi_ack_detailed"
+	noProgressCap       = "no-progress"
+	noThinCap           = "no-thin"
+	objectFormatCap     = "object-format"
+	ofsDeltaCap         = "ofs-delta"
+	reportStatusCap     = "report-status"
+	sideBand64KCap      = "side-band-64k"
+	sideBandCap         = "side-band"
+	symrefCap           = "symref"
+	thinPackCap         = "thin-pack"
+)
+
+// Version 2 commands. See https://git-scm.com/docs/protocol-v2
+const (
+	lsRefsCommand = "ls-refs"
+	fetchCommand  = "fetch"
+)
+
+// UploadPack serves a git-upload-pack session over a bidirectional stream,
+// like the standard input and output of a process started by ssh(1) or
+// git-daemon(1). gitProtocol is the value of the GIT_PROTOCOL environment
+// variable sent by the client, which may be empty. UploadPack returns after
+// the client closes its end of the stream or an error occurs.
+//
+// The context is checked between requests and while computing which objects
+// to send, but UploadPack does not interrupt blocked reads or writes.
+func (srv *Server) UploadPack(ctx context.Context, r io.Reader, w io.Writer, gitProtocol string) error {
+	c := newConn(r, w, false)
+	version := protocolVersion(gitProtocol)
+	if err := srv.advertiseUploadPack(c, version); err != nil {
+		return fmt.Errorf("upload-pack: %w", err)
+	}
+	if err := srv.uploadPack(ctx, c, version); err != nil {
+		return fmt.Errorf("upload-pack: %w", err)
+	}
+	return nil
+}
+
+func (srv *Server) uploadPack(ctx context.Context, c *conn, version int) error {
+	if version == 2 {
+		return srv.uploadPackV2(ctx, c)
+	}
+	return srv.uploadPackV1(ctx, c)
+}
+
+// advertiseUploadPack writes the initial response for git-upload-pack.
+func (srv *Server) advertiseUploadPack(c *conn, version int) error {
+	if version == 2 {
+		c.writeString("version 2\n")
+		c.writeString(agentCap + "=" + srv.agent + "\n")
+		c.writeString(lsRefsCommand + "=unborn\n")
+		c.writeString(fetchCommand + "\n")
+		c.writeString(objectFormatCap + "=" + srv.store.ObjectFormat().String() + "\n")
+		c.writeFlush()
+		return c.w.Flush()
+	}
+
+	refs, err := srv.listRefs()
+	if err != nil {
+		c.writeError(err)
+		return err
+	}
+	caps := []string{
+		multiAckCap,
+		multiAckDetailedCap,
+		sideBandCap,
+		sideBand64KCap,
+		thinPackCap,
+		ofsDeltaCap,
+		noProgressCap,
+		includeTagCap,
+	}
+	for _, ref := range refs {
+		if ref.Name == githash.Head && ref.SymrefTarget != "" && !ref.ObjectID.IsZero() {
+			caps = append(caps, symrefCap+"="+string(ref.Name)+":"+string(ref.SymrefTarget))
+		}
+	}
+	return srv.advertiseRefsV1(c, version, refs, caps, true)
+}
+
+// advertiseRefsV1 writes a version 0 or 1 reference advertisement.
+// If peel is true, then peeled values are advertised for annotated tags.
+// See https://git-scm.com/docs/pack-protocol#_reference_discovery
+func (srv *Server) advertiseRefsV1(c *conn, version int, refs []*gitrepo.Ref, caps []string, peel bool) error {
+	format := srv.store.ObjectFormat()
+	caps = append(caps,
+		objectFormatCap+"="+format.String(),
+		agentCap+"="+srv.agent,
+	)
+	capString := strings.Join(caps, " ")
+	if version == 1 {
+		c.writeString("version 1\n")
+	}
+	first := true
+	for _, ref := range refs {
+		if ref.ObjectID.IsZero() {
+			continue
+		}
+		if first {
+			c.writeString(ref.ObjectID.String() + " " + string(ref.Name) + "\x00" + capString + "\n")
+			first = false
+		} else {
+			c.writeString(ref.ObjectID.String() + " " + string(ref.Name) + "\n")
+		}
+		if !peel {
+			continue
+		}
+		peeled, err := srv.peel(ref.ObjectID)
+		if err != nil {
+			c.writeError(err)
+			return err
+		}
+		if !peeled.IsZero() {
+			c.writeString(peeled.String() + " " + string(ref.Name) + "^{}\n")
+		}
+	}
+	if first {
+		c.writeString(format.Null().String() + " capabilities^{}\x00" + capString + "\n")
+	}
+	c.writeFlush()
+	return c.w.Flush()
+}
+
+// uploadRequest is a parsed request to send a packfile.
+type uploadRequest struct {
+	wants      []githash.ObjectID
+	haves      []githash.ObjectID
+	caps       map[string]string
+	done       bool
+	ofsDelta   bool
+	includeTag bool
+}
+
+// uploadPackV1 handles a version 0 or 1 upload request.
+// See https://git-scm.com/docs/pack-protocol#_packfile_negotiation
+func (srv *Server) uploadPackV1(ctx context.Context, c *conn) error {
+	if !c.stateless && c.atEOF() {
+		return nil
+	}
+	req, err := readWantsV1(c)
+	if err != nil {
+		c.writeError(err)
+		return err
+	}
+	if req == nil {
+		// Client only wanted the advertisement.
+		return nil
+	}
+	if err := srv.checkWants(req.wants); err != nil {
+		c.writeError(err)
+		return err
+	}
+	multiAck := 0
+	if _, ok := req.caps[multiAckDetailedCap]; ok {
+		multiAck = 2
+	} else if _, ok := req.caps[multiAckCap]; ok {
+		multiAck = 1
+	}
+
+	// Same acknowledgement logic as git-upload-pack(1).
+	// gotCommon and gotOther record whether the current round
+	// had any haves that the server does or does not have, respectively.
+	var gotCommon, gotOther bool
+	ready := false
+	readyChecked := 0
+	checkReady := func() (bool, error) {
+		if ready || readyChecked == len(req.haves) {
+			return ready, nil
+		}
+		readyChecked = len(req.haves)
+		var err error
+		ready, err = srv.hasCommonBase(ctx, req.wants, req.haves)
+		return ready, err
+	}
+	for {
+		if err := c.next(); err != nil {
+			return fmt.Errorf("read haves: %w", err)
+		}
+		if c.r.Type() == pktline.Flush {
+			if multiAck == 2 && gotCommon && !gotOther {
+				if ok, err := checkReady(); err != nil {
+					c.writeError(err)
+					return err
+				} else if ok {
+					c.writeString("ACK " + req.haves[len(req.haves)-1].String() + " ready\n")
+				}
+			}
+			if len(req.haves) == 0 || multiAck > 0 {
+				c.writeString("NAK\n")
+			}
+			gotCommon, gotOther = false, false
+			if err := c.w.Flush(); err != nil {
+				return err
+			}
+			if c.stateless || c.atEOF() {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+		line, err := c.r.Text()
+		if err != nil {
+			return fmt.Errorf("read haves: %w", err)
+		}
+		if bytes.Equal(line, []byte("done")) {
+			break
+		}
+		idString, ok := cutPrefix(string(line), "have ")
+		if !ok {
+			err := fmt.Errorf("read haves: unexpected line %q", line)
+			c.writeError(err)
+			return err
+		}
+		id, err := githash.ParseObjectID(idString)
+		if err != nil {
+			err = fmt.Errorf("read haves: %w", err)
+			c.writeError(err)
+			return err
+		}
+		if has, err := srv.hasObject(id); err != nil {
+			c.writeError(err)
+			return err
+		} else if !has {
+			gotOther = true
+			if multiAck == 0 {
+				continue
+			}
+			if ok, err := checkReady(); err != nil {
+				c.writeError(err)
+				return err
+			} else if !ok {
+				continue
+			}
+			if multiAck == 2 {
+				c.writeString("ACK " + id.String() + " ready\n")
+			} else {
+				c.writeString("ACK " + id.String() + " continue\n")
+			}
+			continue
+		}
+		gotCommon = true
+		req.haves = append(req.haves, id)
+		switch multiAck {
+		case 2:
+			c.writeString("ACK " + id.String() + " common\n")
+		case 1:
+			c.writeString("ACK " + id.String() + " continue\n")
+		default:
+			if len(req.haves) == 1 {
+				c.writeString("ACK " + id.String() + "\n")
+			}
+		}
+	}
+	if len(req.haves) == 0 {
+		c.writeString("NAK\n")
+	} else if multiAck > 0 {
+		c.writeString("ACK " + req.haves[len(req.haves)-1].String() + "\n")
+	}
+
+	var pw io.Writer = c.w
+	sideband := true
+	if _, ok := req.caps[sideBand64KCap]; ok {
+		pw = &sidebandWriter{w: c.w, band: sidebandData, maxData: sideband64KMaxData}
+	} else if _, ok := req.caps[sideBandCap]; ok {
+		pw = &sidebandWriter{w: c.w, band: sidebandData, maxData: sidebandMaxData}
+	} else {
+		sideband = false
+	}
+	err = srv.sendPack(ctx, pw, req)
+	if sideband {
+		if err != nil {
+			writeSidebandError(c.w, err)
+		}
+		c.writeFlush()
+	}
+	if flushErr := c.w.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// readWantsV1 reads the list of wanted objects at the start of
+// a version 0 or 1 upload request. It returns nil if the client
+// does not want any objects.
+func readWantsV1(c *conn) (*uploadRequest, error) {
+	req := &uploadRequest{caps: make(map[string]string)}
+	for {
+		if err := c.next(); err != nil {
+			return nil, fmt.Errorf("read wants: %w", err)
+		}
+		if c.r.Type() == pktline.Flush {
+			break
+		}
+		line, err := c.r.Text()
+		if err != nil {
+			return nil, fmt.Errorf("read wants: %w", err)
+		}
+		rest, ok := cutPrefix(string(line), "want ")
+		if !ok {
+			// shallow, deepen, and filter are not advertised.
+			return nil, fmt.Errorf("read wants: unsupported request %q", line)
+		}
+		idString, capString, _ := strings.Cut(rest, " ")
+		id, err := githash.ParseObjectID(idString)
+		if err != nil {
+			return nil, fmt.Errorf("read wants: %w", err)
+		}
+		req.wants = append(req.wants, id)
+		if len(req.wants) == 1 {
+			for _, c := range strings.Fields(capString) {
+				k, v, _ := strings.Cut(c, "=")
+				req.caps[k] = v
+			}
+		}
+	}
+	if len(req.wants) == 0 {
+		return nil, nil
+	}
+	_, req.ofsDelta = req.caps[ofsDeltaCap]
+	_, req.includeTag = req.caps[includeTagCap]
+	return req, nil
+}
+
+// checkWants verifies that the client only asked for
+// objects at the tips of refs.
+func (srv *Server) checkWants(wants []githash.ObjectID) error {
+	refs, err := srv.listRefs()
+	if err != nil {
+		return err
+	}
+	tips := make(map[githash.ObjectID]struct{})
+	for _, ref := range refs {
+		if ref.ObjectID.IsZero() {
+			continue
+		}
+		tips[ref.ObjectID] = struct{}{}
+		peeled, err := srv.peel(ref.ObjectID)
+		if err != nil {
+			return err
+		}
+		if !peeled.IsZero() {
+			tips[peeled] = struct{}{}
+		}
+	}
+	for _, want := range wants {
+		if _, ok := tips[want]; !ok {
+			return fmt.Errorf("not our ref %v", want)
+		}
+	}
+	return nil
+}
+
+// sendPack writes the packfile for the request to w.
+func (srv *Server) sendPack(ctx context.Context, w io.Writer, req *uploadRequest) error {
+	objects, err := srv.objectsToSend(ctx, req.wants, req.haves, req.includeTag)
+	if err != nil {
+		return err
+	}
+	return srv.writePack(w, objects, req.ofsDelta)
+}
+
+// uploadPackV2 handles version 2 commands.
+// See https://git-scm.com/docs/protocol-v2#_command_request
+func (srv *Server) uploadPackV2(ctx context.Context, c *conn) error {
+	for {
+		if !c.stateless && c.atEOF() {
+			return nil
+		}
+		cmd, err := readCommandV2(c)
+		if err != nil {
+			c.writeError(err)
+			return err
+		}
+		if cmd == nil {
+			// A flush instead of a command ends the session.
+			return nil
+		}
+		if format, ok := cmd.caps[objectFormatCap]; ok && format != srv.store.ObjectFormat().String() {
+			err := fmt.Errorf("mismatched object format %q", format)
+			c.writeError(err)
+			return err
+		}
+		switch cmd.name {
+		case lsRefsCommand:
+			err = srv.lsRefsV2(c, cmd.args)
+		case fetchCommand:
+			err = srv.fetchV2(ctx, c, cmd.args)
+		default:
+			err = fmt.Errorf("unknown command %q", cmd.name)
+			c.writeError(err)
+		}
+		if err != nil {
+			return err
+		}
+		if c.stateless {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// commandV2 is a parsed version 2 command request.
+type commandV2 struct {
+	name string
+	caps map[string]string
+	args []string
+}
+
+// readCommandV2 reads a version 2 command request.
+// It returns nil if the client sent a flush packet instead.
+func readCommandV2(c *conn) (*commandV2, error) {
+	if err := c.next(); err != nil {
+		return nil, fmt.Errorf("read command: %w", err)
+	}
+	if c.r.Type() == pktline.Flush {
+		return nil, nil
+	}
+	line, err := c.r.Text()
+	if err != nil {
+		return nil, fmt.Errorf("read command: %w", err)
+	}
+	name, ok := cutPrefix(string(line), "command=")
+	if !ok {
+		return nil, fmt.Errorf("read command: expected command, got %q", line)
+	}
+	cmd := &commandV2{
+		name: name,
+		caps: make(map[string]string),
+	}
+	inArgs := false
+	for {
+		if err := c.next(); err != nil {
+			return nil, fmt.Errorf("read command %s: %w", name, err)
+		}
+		switch c.r.Type() {
+		case pktline.Flush:
+			return cmd, nil
+		case pktline.Delim:
+			if inArgs {
+				return nil, fmt.Errorf("read command %s: unexpected delimiter", name)
+			}
+			inArgs = true
+			continue
+		}
+		line, err := c.r.Text()
+		if err != nil {
+			return nil, fmt.Errorf("read command %s: %w", name, err)
+		}
+		if inArgs {
+			cmd.args = append(cmd.args, string(line))
+		} else {
+			k, v, _ := strings.Cut(string(line), "=")
+			cmd.caps[k] = v
+		}
+	}
+}
+
+// lsRefsV2 handles the ls-refs command.
+// See https://git-scm.com/docs/protocol-v2#_ls_refs
+func (srv *Server) lsRefsV2(c *conn, args []string) error {
+	var symrefs, peel, unborn bool
+	var prefixes []string
+	for _, arg := range args {
+		switch arg {
+		case "symrefs":
+			symrefs = true
+		case "peel":
+			peel = true
+		case "unborn":
+			unborn = true
+		default:
+			prefix, ok := cutPrefix(arg, "ref-prefix ")
+			if !ok {
+				err := fmt.Errorf("ls-refs: unexpected argument %q", arg)
+				c.writeError(err)
+				return err
+			}
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	refs, err := srv.listRefs(prefixes...)
+	if err != nil {
+		err = fmt.Errorf("ls-refs: %w", err)
+		c.writeError(err)
+		return err
+	}
+	for _, ref := range refs {
+		if !matchesRefPrefixes(ref.Name, prefixes) {
+			continue
+		}
+		var line string
+		switch {
+		case !ref.ObjectID.IsZero():
+			line = ref.ObjectID.String() + " " + string(ref.Name)
+		case unborn && ref.Name == githash.Head && ref.SymrefTarget != "":
+			line = "unborn " + string(ref.Name)
+		default:
+			continue
+		}
+		if symrefs && ref.SymrefTarget != "" {
+			line += " symref-target:" + string(ref.SymrefTarget)
+		}
+		if peel && !ref.ObjectID.IsZero() {
+			peeled, err := srv.peel(ref.ObjectID)
+			if err != nil {
+				err = fmt.Errorf("ls-refs: %w", err)
+				c.writeError(err)
+				return err
+			}
+			if !peeled.IsZero() {
+				line += " peeled:" + peeled.String()
+			}
+		}
+		c.writeString(line + "\n")
+	}
+	c.writeFlush()
+	return c.w.Flush()
+}
+
+func matchesRefPrefixes(name githash.Ref, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(string(name), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchV2 handles the fetch command.
+// See https://git-scm.com/docs/protocol-v2#_fetch
+func (srv *Server) fetchV2(ctx context.Context, c *conn, args []string) error {
+	req := new(uploadRequest)
+	for _, arg := range args {
+		switch arg {
+		case "done":
+			req.done = true
+		case "ofs-delta":
+			req.ofsDelta = true
+		case "include-tag":
+			req.includeTag = true
+		case "thin-pack", "no-progress":
+			// Packs are never thin and progress is never sent.
+		default:
+			var list *[]githash.ObjectID
+			idString, ok := cutPrefix(arg, "want ")
+			if ok {
+				list = &req.wants
+			} else if idString, ok = cutPrefix(arg, "have "); ok {
+				list = &req.haves
+			} else {
+				err := fmt.Errorf("fetch: unexpected argument %q", arg)
+				c.writeError(err)
+				return err
+			}
+			id, err := githash.ParseObjectID(idString)
+			if err != nil {
+				err = fmt.Errorf("fetch: %w", err)
+				c.writeError(err)
+				return err
+			}
+			*list = append(*list, id)
+		}
+	}
+	if len(req.wants) == 0 {
+		err := fmt.Errorf("fetch: no wants")
+		c.writeError(err)
+		return err
+	}
+	if err := srv.checkWants(req.wants); err != nil {
+		err = fmt.Errorf("fetch: %w", err)
+		c.writeError(err)
+		return err
+	}
+	common := req.haves[:0]
+	for _, have := range req.haves {
+		has, err := srv.hasObject(have)
+		if err != nil {
+			err = fmt.Errorf("fetch: %w", err)
+			c.writeError(err)
+			return err
+		}
+		if has {
+			common = append(common, have)
+		}
+	}
+	req.haves = common
+
+	if !req.done {
+		ready, err := srv.hasCommonBase(ctx, req.wants, req.haves)
+		if err != nil {
+			err = fmt.Errorf("fetch: %w", err)
+			c.writeError(err)
+			return err
+		}
+		c.writeString("acknowledgments\n")
+		if len(req.haves) == 0 {
+			c.writeString("NAK\n")
+		}
+		for _, have := range req.haves {
+			c.writeString("ACK " + have.String() + "\n")
+		}
+		if !ready {
+			c.writeFlush()
+			return c.w.Flush()
+		}
+		// The server found a common base, so it sends the packfile
+		// without waiting for the client to send "done".
+		c.writeString("ready\n")
+		c.writeDelim()
+	}
+
+	c.writeString("packfile\n")
+	pw := &sidebandWriter{w: c.w, band: sidebandData, maxData: sideband64KMaxData}
+	err := srv.sendPack(ctx, pw, req)
+	if err != nil {
+		writeSidebandError(c.w, err)
+	}
+	c.writeFlush()
+	if flushErr := c.w.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return fmt.Errorf("fetch: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,447 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+	"gg-scm.io/pkg/git/packfile"
+)
+
+// packObject is an object selected to be sent to the client.
+type packObject struct {
+	id   githash.ObjectID
+	name string // path hint for delta compression
+}
+
+// objectWalk computes the set of objects to send for a fetch.
+type objectWalk struct {
+	srv *Server
+	ctx context.Context
+
+	// have is the set of commits that the client has,
+	// including all of their ancestors.
+	have map[githash.ObjectID]struct{}
+	// seen is the set of non-commit objects that have either been selected
+	// or that the client is known to have.
+	seen map[githash.ObjectID]struct{}
+	// selected is the set of objects to send.
+	selected map[githash.ObjectID]struct{}
+	objects  []packObject
+}
+
+// objectsToSend returns the objects reachable from wants
+// that are not reachable from haves.
+// If includeTag is true, then annotated tags that point to
+// objects being sent are included as well.
+func (srv *Server) objectsToSend(ctx context.Context, wants, haves []githash.ObjectID, includeTag bool) ([]packObject, error) {
+	walk := &objectWalk{
+		srv:      srv,
+		ctx:      ctx,
+		have:     make(map[githash.ObjectID]struct{}),
+		seen:     make(map[githash.ObjectID]struct{}),
+		selected: make(map[githash.ObjectID]struct{}),
+	}
+	if err := walk.markHaves(haves); err != nil {
+		return nil, err
+	}
+
+	// Walk commits first to find the boundary between the commits being sent
+	// and the commits the client has. Objects in the boundary commits' trees
+	// don't need to be sent.
+	var trees []githash.ObjectID
+	var boundary []githash.ObjectID
+	stack := append([]githash.ObjectID(nil), wants...)
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := walk.have[id]; ok {
+			boundary = append(boundary, id)
+			continue
+		}
+		if _, ok := walk.selected[id]; ok {
+			continue
+		}
+		if _, ok := walk.seen[id]; ok {
+			continue
+		}
+		typ, data, err := srv.readObject(id)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case object.TypeCommit:
+			walk.add(id, "")
+			c, err := object.ParseCommit(data)
+			if err != nil {
+				return nil, fmt.Errorf("commit %v: %w", id, err)
+			}
+			trees = append(trees, c.Tree)
+			stack = append(stack, c.Parents...)
+		case object.TypeTag:
+			walk.add(id, "")
+			tag, err := object.ParseTag(data)
+			if err != nil {
+				return nil, fmt.Errorf("tag %v: %w", id, err)
+			}
+			stack = append(stack, tag.ObjectID)
+		case object.TypeTree:
+			trees = append(trees, id)
+		case object.TypeBlob:
+			walk.add(id, "")
+		default:
+			return nil, fmt.Errorf("object %v: unknown type %q", id, typ)
+		}
+	}
+	for _, id := range boundary {
+		typ, data, err := srv.readObject(id)
+		if err != nil {
+			return nil, err
+		}
+		if typ != object.TypeCommit {
+			continue
+		}
+		c, err := object.ParseCommit(data)
+		if err != nil {
+			return nil, fmt.Errorf("commit %v: %w", id, err)
+		}
+		if err := walk.markTree(c.Tree); err != nil {
+			return nil, err
+		}
+	}
+	for _, tree := range trees {
+		if err := walk.addTree(tree, ""); err != nil {
+			return nil, err
+		}
+	}
+	if includeTag {
+		if err := walk.addTags(); err != nil {
+			return nil, err
+		}
+	}
+	return walk.objects, nil
+}
+
+// hasCommonBase reports whether every wanted commit has one of the haves
+// as an ancestor, meaning that the client has sent enough haves to receive
+// a reasonably small packfile. This is the same condition as Git's
+// ok_to_give_up. Wanted objects that are not commits are ignored.
+func (srv *Server) hasCommonBase(ctx context.Context, wants, haves []githash.ObjectID) (bool, error) {
+	if len(haves) == 0 {
+		return false, nil
+	}
+	haveSet := make(map[githash.ObjectID]struct{}, len(haves))
+	for _, have := range haves {
+		haveSet[have] = struct{}{}
+	}
+	for _, want := range wants {
+		found, err := srv.reachesAny(ctx, want, haveSet)
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// reachesAny reports whether any of the commits in targets
+// are reachable from the given object. If id does not peel to a commit,
+// then reachesAny returns true.
+func (srv *Server) reachesAny(ctx context.Context, id githash.ObjectID, targets map[githash.ObjectID]struct{}) (bool, error) {
+	for depth := 0; ; depth++ {
+		if _, ok := targets[id]; ok {
+			return true, nil
+		}
+		typ, data, err := srv.readObject(id)
+		if err != nil {
+			return false, err
+		}
+		if typ == object.TypeCommit {
+			break
+		}
+		if typ != object.TypeTag || depth >= maxTagDepth {
+			return true, nil
+		}
+		tag, err := object.ParseTag(data)
+		if err != nil {
+			return false, fmt.Errorf("tag %v: %w", id, err)
+		}
+		id = tag.ObjectID
+	}
+
+	visited := make(map[githash.ObjectID]struct{})
+	stack := []githash.ObjectID{id}
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := targets[id]; ok {
+			return true, nil
+		}
+		if _, ok := visited[id]; ok {
+			continue
+		}
+		visited[id] = struct{}{}
+		typ, data, err := srv.readObject(id)
+		if err != nil {
+			return false, err
+		}
+		if typ != object.TypeCommit {
+			return false, fmt.Errorf("object %v: is a %v, not a commit", id, typ)
+		}
+		c, err := object.ParseCommit(data)
+		if err != nil {
+			return false, fmt.Errorf("commit %v: %w", id, err)
+		}
+		stack = append(stack, c.Parents...)
+	}
+	return false, nil
+}
+
+func (walk *objectWalk) add(id githash.ObjectID, name string) {
+	walk.selected[id] = struct{}{}
+	walk.objects = append(walk.objects, packObject{id: id, name: name})
+}
+
+// markHaves marks the given objects and all of their ancestors
+// as objects that the client has.
+func (walk *objectWalk) markHaves(haves []githash.ObjectID) error {
+	stack := append([]githash.ObjectID(nil), haves...)
+	for len(stack) > 0 {
+		if err := walk.ctx.Err(); err != nil {
+			return err
+		}
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := walk.have[id]; ok {
+			continue
+		}
+		if _, ok := walk.seen[id]; ok {
+			continue
+		}
+		typ, data, err := walk.srv.readObject(id)
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case object.TypeCommit:
+			walk.have[id] = struct{}{}
+			c, err := object.ParseCommit(data)
+			if err != nil {
+				return fmt.Errorf("commit %v: %w", id, err)
+			}
+			stack = append(stack, c.Parents...)
+		case object.TypeTag:
+			walk.seen[id] = struct{}{}
+			tag, err := object.ParseTag(data)
+			if err != nil {
+				return fmt.Errorf("tag %v: %w", id, err)
+			}
+			stack = append(stack, tag.ObjectID)
+		default:
+			walk.seen[id] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// markTree marks a tree and all of its contents as objects
+// that the client has.
+func (walk *objectWalk) markTree(id githash.ObjectID) error {
+	if _, ok := walk.seen[id]; ok {
+		return nil
+	}
+	walk.seen[id] = struct{}{}
+	tree, err := walk.readTree(id)
+	if err != nil {
+		return err
+	}
+	for _, ent := range tree {
+		switch {
+		case ent.Mode == object.ModeGitlink:
+		case ent.Mode.IsDir():
+			if err := walk.markTree(ent.ObjectID); err != nil {
+				return err
+			}
+		default:
+			walk.seen[ent.ObjectID] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// addTree selects a tree and any of its contents that the client
+// does not have.
+func (walk *objectWalk) addTree(id githash.ObjectID, name string) error {
+	if _, ok := walk.seen[id]; ok {
+		return nil
+	}
+	if _, ok := walk.selected[id]; ok {
+		return nil
+	}
+	if err := walk.ctx.Err(); err != nil {
+		return err
+	}
+	walk.add(id, name)
+	tree, err := walk.readTree(id)
+	if err != nil {
+		return err
+	}
+	for _, ent := range tree {
+		entName := ent.Name
+		if name != "" {
+			entName = name + "/" + ent.Name
+		}
+		switch {
+		case ent.Mode == object.ModeGitlink:
+			// Submodule commits are not part of this repository.
+		case ent.Mode.IsDir():
+			if err := walk.addTree(ent.ObjectID, entName); err != nil {
+				return err
+			}
+		default:
+			if _, ok := walk.seen[ent.ObjectID]; ok {
+				continue
+			}
+			if _, ok := walk.selected[ent.ObjectID]; ok {
+				continue
+			}
+			walk.add(ent.ObjectID, entName)
+		}
+	}
+	return nil
+}
+
+func (walk *objectWalk) readTree(id githash.ObjectID) (object.Tree, error) {
+	typ, data, err := walk.srv.readObject(id)
+	if err != nil {
+		return nil, err
+	}
+	if typ != object.TypeTree {
+		return nil, fmt.Errorf("object %v: is a %v, not a tree", id, typ)
+	}
+	tree, err := object.ParseTreeFormat(data, walk.srv.store.ObjectFormat())
+	if err != nil {
+		return nil, fmt.Errorf("tree %v: %w", id, err)
+	}
+	return tree, nil
+}
+
+// addTags selects any annotated tags in refs/tags/
+// that point to selected objects.
+func (walk *objectWalk) addTags() error {
+	refs, err := walk.srv.listRefs("refs/tags/")
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if !strings.HasPrefix(string(ref.Name), "refs/tags/") || ref.ObjectID.IsZero() {
+			continue
+		}
+		if _, ok := walk.selected[ref.ObjectID]; ok {
+			continue
+		}
+		// Follow the chain of tags, then select all of them
+		// if the final object is being sent.
+		var chain []githash.ObjectID
+		id := ref.ObjectID
+		for len(chain) < maxTagDepth {
+			typ, data, err := walk.srv.readObject(id)
+			if err != nil {
+				return err
+			}
+			if typ != object.TypeTag {
+				break
+			}
+			chain = append(chain, id)
+			tag, err := object.ParseTag(data)
+			if err != nil {
+				return fmt.Errorf("tag %v: %w", chain[len(chain)-1], err)
+			}
+			id = tag.ObjectID
+		}
+		if len(chain) == 0 {
+			continue
+		}
+		if _, ok := walk.selected[id]; !ok {
+			continue
+		}
+		for _, tagID := range chain {
+			if _, ok := walk.selected[tagID]; !ok {
+				walk.add(tagID, "")
+			}
+		}
+	}
+	return nil
+}
+
+// writePack writes a packfile containing the given objects to w.
+// Objects are read as the packfile is built, so only the objects
+// in the delta window are held in memory.
+func (srv *Server) writePack(w io.Writer, objects []packObject, ofsDelta bool) error {
+	buildObjects := make([]packfile.BuildObject, 0, len(objects))
+	for _, obj := range objects {
+		prefix, rc, err := srv.store.OpenObject(obj.id)
+		if err != nil {
+			return err
+		}
+		rc.Close()
+		buildObjects = append(buildObjects, packfile.BuildObject{
+			Type: packObjectType(prefix.Type),
+			Size: prefix.Size,
+			Name: obj.name,
+		})
+	}
+	opts := &packfile.BuildOptions{
+		ObjectFormat: srv.store.ObjectFormat(),
+		Load: func(i int) ([]byte, error) {
+			_, data, err := srv.readObject(objects[i].id)
+			return data, err
+		},
+	}
+	if !ofsDelta {
+		opts.Window = -1
+	}
+	return packfile.Build(w, buildObjects, opts)
+}
+
+func packObjectType(typ object.Type) packfile.ObjectType {
+	switch typ {
+	case object.TypeCommit:
+		return packfile.Commit
+	case object.TypeTree:
+		return packfile.Tree
+	case object.TypeBlob:
+		return packfile.Blob
+	case object.TypeTag:
+		return packfile.Tag
+	default:
+		return 0
+	}
+}
@@ -18,17 +18,19 @@ package packfile
 
 import (
 	"compress/zlib"
-	"crypto/sha1"
 	"fmt"
 	"hash"
 	"io"
+
+	"gg-scm.io/pkg/git/githash"
 )
 
 // Writer writes a packfile.
 type Writer struct {
-	wc    writerCounter
-	nobjs uint32
-	hash  hash.Hash
+	wc     writerCounter
+	nobjs  uint32
+	format githash.ObjectFormat
+	hash   hash.Hash
 
 	// Scratch buffer
 	buf []byte
@@ -40,13 +42,21 @@ type Writer struct {
 
 // NewWriter returns a Writer that writes to the given stream. It is the
 // caller's responsibility to call Close on the returned Writer after the last
-// object has been written.
+// object has been written. The packfile will use SHA-1 object IDs.
 func NewWriter(w io.Writer, objectCount uint32) *Writer {
-	h := sha1.New()
+	return NewWriterFormat(w, objectCount, githash.SHA1Format)
+}
+
+// NewWriterFormat returns a Writer that writes a packfile
+// using the given object format to the given stream.
+// It is otherwise identical to [NewWriter].
+func NewWriterFormat(w io.Writer, objectCount uint32, format githash.ObjectFormat) *Writer {
+	h := format.New()
 	return &Writer{
-		wc:    writerCounter{w: io.MultiWriter(h, w)},
-		nobjs: objectCount,
-		hash:  h,
+		wc:     writerCounter{w: io.MultiWriter(h, w)},
+		nobjs:  objectCount,
+		format: format,
+		hash:   h,
 	}
 }
 
@@ -80,6 +90,9 @@ func (w *Writer) WriteHeader(hdr *Header) (offset int64, err error) {
 	if hdr.BaseOffset < 0 {
 		return 0, fmt.Errorf("packfile: write object header: invalid base offset %d", hdr.BaseOffset)
 	}
+	if hdr.Type == RefDelta && hdr.BaseObject.ObjectFormat() != w.format {
+		return 0, fmt.Errorf("packfile: write object header: base object %v is not %v", hdr.BaseObject, w.format)
+	}
 	if w.dataRemaining > 0 {
 		return 0, fmt.Errorf("packfile: write object header: previous object incomplete (%d bytes remaining)", w.dataRemaining)
 	}
@@ -105,7 +118,7 @@ func (w *Writer) WriteHeader(hdr *Header) (offset int64, err error) {
 	case OffsetDelta:
 		w.buf = appendOffset(w.buf, hdr.BaseOffset-offset)
 	case RefDelta:
-		w.buf = append(w.buf, hdr.BaseObject[:]...)
+		w.buf = hdr.BaseObject.AppendBinary(w.buf)
 	}
 	if _, err := w.wc.Write(w.buf); err != nil {
 		return offset, fmt.Errorf("packfile: write object: %w", err)
@@ -26,13 +26,15 @@ import (
 	"gg-scm.io/pkg/git/githash"
 )
 
-// A Hash is the SHA-1 hash of a Git object.
-type Hash = githash.SHA1
+// A Hash is the hash of a Git object.
+// It may be either a SHA-1 or a SHA-256 hash,
+// depending on the repository's object format.
+type Hash = githash.ObjectID
 
 // ParseHash parses a hex-encoded hash. It is the same as calling UnmarshalText
 // on a new Hash.
 func ParseHash(s string) (Hash, error) {
-	return githash.ParseSHA1(s)
+	return githash.ParseObjectID(s)
 }
 
 // A Ref is a Git reference to a commit.
@@ -165,7 +167,7 @@ func parseRefs(iter *RefIterator) (map[Ref]Hash, error) {
 		} else if len(refs) >= maxCount {
 			return refs, fmt.Errorf("parse refs: too many refs")
 		}
-		refs[iter.Ref()] = iter.ObjectSHA1()
+		refs[iter.Ref()] = iter.ObjectID()
 	}
 	return refs, iter.Close()
 }
@@ -255,7 +257,7 @@ type RefIterator struct {
 	scanDone   bool
 	hasResults bool
 	ref        Ref
-	hash       githash.SHA1
+	hash       githash.ObjectID
 	deref      bool
 }
 
@@ -318,14 +320,25 @@ func (iter *RefIterator) Ref() Ref {
 	return iter.ref
 }
 
-// ObjectSHA1 returns the SHA-1 hash of the Git object
+// ObjectID returns the hash of the Git object
 // the current ref refers to.
-// [RefIterator.Next] must be called at least once before calling ObjectSHA1.
-func (iter *RefIterator) ObjectSHA1() githash.SHA1 {
+// [RefIterator.Next] must be called at least once before calling ObjectID.
+func (iter *RefIterator) ObjectID() githash.ObjectID {
 	return iter.hash
 }
 
-// IsDereference reports whether the value of [RefIterator.ObjectSHA1]
+// ObjectSHA1 returns the SHA-1 hash of the Git object
+// the current ref refers to. It returns the zero value
+// if the repository does not use SHA-1.
+//
+// Deprecated: Use [RefIterator.ObjectID],
+// which supports repositories that use SHA-256.
+func (iter *RefIterator) ObjectSHA1() githash.SHA1 {
+	h, _ := iter.hash.SHA1()
+	return h
+}
+
+// IsDereference reports whether the value of [RefIterator.ObjectID]
 // represents the target of a tag object.
 func (iter *RefIterator) IsDereference() bool {
 	return iter.deref
@@ -389,7 +402,7 @@ func SetRefIfMatches(oldvalue, newvalue string) RefMutation {
 	if newvalue == refZeroValue || oldvalue == refZeroValue {
 		return RefMutation{command: "updateerror"}
 	}
-	return RefMutation{command: "update", newvalue: newvalue}
+	return RefMutation{command: "update", newvalue: newvalue, oldvalue: oldvalue}
 }
 
 // CreateRef returns a RefMutation that creates a ref with the given value,
@@ -461,6 +461,33 @@ func TestMutateRefs(t *testing.T) {
 		}
 	})
 
+	t.Run("SetRefIfMatches/NoMatch", func(t *testing.T) {
+		env, err := newTestEnv(ctx, gitPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer env.cleanup()
+		if err := setupRepo(ctx, env); err != nil {
+			t.Fatal(err)
+		}
+		r, err := env.g.Head(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Attempt to update the branch with MutateRefs.
+		badCommitBytes := r.Commit.Bytes()
+		badCommitBytes[len(badCommitBytes)-1]++ // twiddle last byte
+		var badCommit Hash
+		if err := badCommit.UnmarshalBinary(badCommitBytes); err != nil {
+			t.Fatal(err)
+		}
+		muts := map[Ref]RefMutation{"refs/heads/foo": SetRefIfMatches(badCommit.String(), r.Commit.String())}
+		if err := env.g.MutateRefs(ctx, muts); err == nil {
+			t.Errorf("MutateRefs(ctx, %v) did not return error", muts)
+		}
+	})
+
 	t.Run("CreateRef/DoesNotExist", func(t *testing.T) {
 		env, err := newTestEnv(ctx, gitPath)
 		if err != nil {
@@ -595,8 +622,12 @@ func TestMutateRefs(t *testing.T) {
 		}
 
 		// Attempt to delete the branch with MutateRefs.
-		badCommit := r.Commit
-		badCommit[len(badCommit)-1]++ // twiddle last byte
+		badCommitBytes := r.Commit.Bytes()
+		badCommitBytes[len(badCommitBytes)-1]++ // twiddle last byte
+		var badCommit Hash
+		if err := badCommit.UnmarshalBinary(badCommitBytes); err != nil {
+			t.Fatal(err)
+		}
 		muts := map[Ref]RefMutation{"refs/heads/foo": DeleteRefIfMatches(badCommit.String())}
 		if err := env.g.MutateRefs(ctx, muts); err == nil {
 			t.Errorf("MutateRefs(ctx, %v) did not return error", muts)
@@ -0,0 +1,332 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package revwalk
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"gg-scm.io/pkg/git/githash"
+)
+
+// Flags used while painting the graph.
+const (
+	parent1 uint8 = 1 << iota
+	parent2
+	stale
+	result
+)
+
+// A paint holds the state of a single walk over a Graph.
+type paint struct {
+	flags    map[githash.ObjectID]uint8
+	queue    nodeQueue
+	nonStale int
+}
+
+func newPaint() *paint {
+	return &paint{flags: make(map[githash.ObjectID]uint8)}
+}
+
+func (p *paint) push(n *node, flags uint8) {
+	p.flags[n.id] |= flags
+	counted := p.flags[n.id]&stale == 0
+	if counted {
+		p.nonStale++
+	}
+	p.queue.push(n, counted)
+}
+
+// paintDownToCommon walks the history of one and twos, marking commits
+// reachable from one with parent1 and commits reachable from any of twos
+// with parent2. The walk stops once every commit left to visit is reachable
+// from both sides. Commits with a generation number less than minGen
+// are not walked past. It returns the commits that were found to be
+// reachable from both sides, in the order they were found.
+// This is the same algorithm Git uses.
+func (g *Graph) paintDownToCommon(p *paint, one *node, twos []*node, minGen uint64) ([]*node, error) {
+	p.push(one, parent1)
+	for _, two := range twos {
+		p.push(two, parent2)
+	}
+	var results []*node
+	for p.nonStale > 0 {
+		e := p.queue.pop()
+		if e.counted {
+			p.nonStale--
+		}
+		n := e.n
+		flags := p.flags[n.id] & (parent1 | parent2 | stale)
+		if flags == parent1|parent2 {
+			if p.flags[n.id]&result == 0 {
+				p.flags[n.id] |= result
+				results = append(results, n)
+			}
+			// Mark parents of a found merge base as stale.
+			flags |= stale
+		}
+		if n.gen < minGen {
+			continue
+		}
+		for _, parentID := range n.commit.Parents {
+			if p.flags[parentID]&flags == flags {
+				continue
+			}
+			parent, err := g.node(parentID)
+			if err != nil {
+				return nil, err
+			}
+			p.push(parent, flags)
+		}
+	}
+	return results, nil
+}
+
+// MergeBases returns the best common ancestors of the first commit
+// and the rest of the commits, like `git merge-base --all`.
+// A best common ancestor is a common ancestor that is not an ancestor
+// of another common ancestor. When more than two commits are given,
+// the result is the best common ancestors of the first commit
+// and a hypothetical merge of the rest of the commits.
+// The result is sorted from newest to oldest commit time
+// and is empty if the commits do not share any history.
+func (g *Graph) MergeBases(ids ...githash.ObjectID) ([]githash.ObjectID, error) {
+	if len(ids) < 2 {
+		return nil, errors.New("merge base: need at least two commits")
+	}
+	nodes, err := g.readNodes(ids)
+	if err != nil {
+		return nil, fmt.Errorf("merge base: %w", err)
+	}
+	bases, err := g.mergeBases(nodes[0], nodes[1:])
+	if err != nil {
+		return nil, fmt.Errorf("merge base: %w", err)
+	}
+	return nodeIDs(bases), nil
+}
+
+// MergeBase returns the best common ancestor of the first commit
+// and the rest of the commits, like `git merge-base`.
+// If there are multiple best common ancestors, MergeBase returns the newest.
+// MergeBase returns the zero ObjectID if the commits do not share any history.
+func (g *Graph) MergeBase(ids ...githash.ObjectID) (githash.ObjectID, error) {
+	bases, err := g.MergeBases(ids...)
+	if err != nil || len(bases) == 0 {
+		return githash.ObjectID{}, err
+	}
+	return bases[0], nil
+}
+
+func (g *Graph) mergeBases(one *node, twos []*node) ([]*node, error) {
+	for _, two := range twos {
+		if one == two {
+			return []*node{one}, nil
+		}
+	}
+	p := newPaint()
+	found, err := g.paintDownToCommon(p, one, twos, 0)
+	if err != nil {
+		return nil, err
+	}
+	var bases []*node
+	for _, n := range found {
+		if p.flags[n.id]&stale == 0 {
+			bases = append(bases, n)
+		}
+	}
+	sortByTime(bases)
+	if len(bases) > 1 {
+		bases, err = g.removeRedundant(bases)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bases, nil
+}
+
+// OctopusMergeBases returns the best common ancestors of all the commits,
+// like `git merge-base --octopus --all`.
+func (g *Graph) OctopusMergeBases(ids ...githash.ObjectID) ([]githash.ObjectID, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("octopus merge base: no commits given")
+	}
+	nodes, err := g.readNodes(ids)
+	if err != nil {
+		return nil, fmt.Errorf("octopus merge base: %w", err)
+	}
+	bases := nodes[:1]
+	for _, n := range nodes[1:] {
+		var next []*node
+		seen := make(map[githash.ObjectID]struct{})
+		for _, base := range bases {
+			b, err := g.mergeBases(base, []*node{n})
+			if err != nil {
+				return nil, fmt.Errorf("octopus merge base: %w", err)
+			}
+			for _, m := range b {
+				if _, dup := seen[m.id]; !dup {
+					seen[m.id] = struct{}{}
+					next = append(next, m)
+				}
+			}
+		}
+		bases = next
+	}
+	return nodeIDs(bases), nil
+}
+
+// Independent returns the minimal subset of the given commits
+// that cannot be reached from any other, like `git merge-base --independent`.
+// The result preserves the order of ids.
+func (g *Graph) Independent(ids ...githash.ObjectID) ([]githash.ObjectID, error) {
+	nodes, err := g.readNodes(ids)
+	if err != nil {
+		return nil, fmt.Errorf("independent commits: %w", err)
+	}
+	var unique []*node
+	seen := make(map[githash.ObjectID]struct{})
+	for _, n := range nodes {
+		if _, dup := seen[n.id]; !dup {
+			seen[n.id] = struct{}{}
+			unique = append(unique, n)
+		}
+	}
+	unique, err = g.removeRedundant(unique)
+	if err != nil {
+		return nil, fmt.Errorf("independent commits: %w", err)
+	}
+	return nodeIDs(unique), nil
+}
+
+// removeRedundant removes the commits from a list of distinct commits
+// that are ancestors of other commits in the list.
+func (g *Graph) removeRedundant(nodes []*node) ([]*node, error) {
+	redundant := make([]bool, len(nodes))
+	others := make([]*node, 0, len(nodes)-1)
+	for i, n := range nodes {
+		if redundant[i] {
+			continue
+		}
+		others = others[:0]
+		minGen := n.gen
+		for j, other := range nodes {
+			if i != j && !redundant[j] {
+				others = append(others, other)
+				if other.gen < minGen {
+					minGen = other.gen
+				}
+			}
+		}
+		if len(others) == 0 {
+			break
+		}
+		p := newPaint()
+		if _, err := g.paintDownToCommon(p, n, others, minGen); err != nil {
+			return nil, err
+		}
+		if p.flags[n.id]&parent2 != 0 {
+			redundant[i] = true
+		}
+		for j, other := range nodes {
+			if i != j && p.flags[other.id]&parent1 != 0 {
+				redundant[j] = true
+			}
+		}
+	}
+	var kept []*node
+	for i, n := range nodes {
+		if !redundant[i] {
+			kept = append(kept, n)
+		}
+	}
+	return kept, nil
+}
+
+// IsAncestor reports whether the commit a is an ancestor of the commit b,
+// like `git merge-base --is-ancestor`. A commit is its own ancestor.
+func (g *Graph) IsAncestor(a, b githash.ObjectID) (bool, error) {
+	if a == b {
+		return true, nil
+	}
+	na, err := g.node(a)
+	if err != nil {
+		return false, fmt.Errorf("is ancestor: %w", err)
+	}
+	nb, err := g.node(b)
+	if err != nil {
+		return false, fmt.Errorf("is ancestor: %w", err)
+	}
+	if na.gen != generationInfinity && nb.gen != generationInfinity && na.gen >= nb.gen {
+		return false, nil
+	}
+	minGen := uint64(0)
+	if na.gen != generationInfinity {
+		minGen = na.gen
+	}
+	p := newPaint()
+	if _, err := g.paintDownToCommon(p, na, []*node{nb}, minGen); err != nil {
+		return false, fmt.Errorf("is ancestor: %w", err)
+	}
+	return p.flags[a]&parent2 != 0, nil
+}
+
+// AheadBehind returns the number of commits reachable from a
+// that are not reachable from b (ahead) and the number of commits
+// reachable from b that are not reachable from a (behind),
+// like `git rev-list --left-right --count a...b`.
+func (g *Graph) AheadBehind(a, b githash.ObjectID) (ahead, behind int, err error) {
+	if a == b {
+		return 0, 0, nil
+	}
+	na, err := g.node(a)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ahead/behind: %w", err)
+	}
+	nb, err := g.node(b)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ahead/behind: %w", err)
+	}
+	p := newPaint()
+	if _, err := g.paintDownToCommon(p, na, []*node{nb}, 0); err != nil {
+		return 0, 0, fmt.Errorf("ahead/behind: %w", err)
+	}
+	for _, flags := range p.flags {
+		switch flags & (parent1 | parent2) {
+		case parent1:
+			ahead++
+		case parent2:
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// sortByTime sorts commits from newest to oldest commit time.
+func sortByTime(nodes []*node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].commit.CommitTime.After(nodes[j].commit.CommitTime)
+	})
+}
+
+func nodeIDs(nodes []*node) []githash.ObjectID {
+	ids := make([]githash.ObjectID, 0, len(nodes))
+	for _, n := range nodes {
+		ids = append(ids, n.id)
+	}
+	return ids
+}
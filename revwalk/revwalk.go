@@ -0,0 +1,196 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+Package revwalk walks the commit graph without running Git.
+It computes merge bases, ancestry, and ahead/behind counts
+like `git merge-base` and `git rev-list`,
+reading commits from any [CommitReader],
+like a [*gitrepo.Repository] or a [*git.ObjectReader].
+
+A Graph is safe to use from multiple goroutines simultaneously.
+
+[*gitrepo.Repository]: https://pkg.go.dev/gg-scm.io/pkg/git/gitrepo#Repository
+[*git.ObjectReader]: https://pkg.go.dev/gg-scm.io/pkg/git#ObjectReader
+*/
+package revwalk
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sync"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+)
+
+// A CommitReader reads commit objects.
+type CommitReader interface {
+	ReadCommit(id githash.ObjectID) (*object.Commit, error)
+}
+
+// A GenerationReader is an optional interface that a [CommitReader]
+// can implement to provide precomputed generation numbers,
+// like those stored in a commit-graph file.
+// Generation numbers let a Graph stop walking history early.
+type GenerationReader interface {
+	// CommitGeneration returns the generation number of the commit
+	// with the given ID, which must be greater than the generation numbers
+	// of all of the commit's parents.
+	// ok is false if the commit's generation number is not known.
+	CommitGeneration(id githash.ObjectID) (gen uint64, ok bool)
+}
+
+// generationInfinity is the generation number used for commits
+// whose generation number is not known.
+const generationInfinity = math.MaxUint64
+
+// A Graph reads and caches commits from a [CommitReader]
+// to answer questions about their history.
+type Graph struct {
+	r    CommitReader
+	gens GenerationReader
+
+	mu    sync.Mutex
+	nodes map[githash.ObjectID]*node
+}
+
+// node is a commit in a Graph.
+type node struct {
+	id     githash.ObjectID
+	commit *object.Commit
+	gen    uint64
+}
+
+// New returns a new Graph that reads commits from r.
+// If r implements [GenerationReader], its generation numbers are used.
+func New(r CommitReader) *Graph {
+	g := &Graph{
+		r:     r,
+		nodes: make(map[githash.ObjectID]*node),
+	}
+	g.gens, _ = r.(GenerationReader)
+	return g
+}
+
+// ReadCommit returns the commit with the given ID.
+// Commits are cached after they are first read.
+func (g *Graph) ReadCommit(id githash.ObjectID) (*object.Commit, error) {
+	n, err := g.node(id)
+	if err != nil {
+		return nil, err
+	}
+	return n.commit, nil
+}
+
+func (g *Graph) node(id githash.ObjectID) (*node, error) {
+	g.mu.Lock()
+	n := g.nodes[id]
+	g.mu.Unlock()
+	if n != nil {
+		return n, nil
+	}
+
+	c, err := g.r.ReadCommit(id)
+	if err != nil {
+		return nil, fmt.Errorf("read commit %v: %w", id, err)
+	}
+	n = &node{
+		id:     id,
+		commit: c,
+		gen:    generationInfinity,
+	}
+	if g.gens != nil {
+		if gen, ok := g.gens.CommitGeneration(id); ok {
+			n.gen = gen
+		}
+	}
+	g.mu.Lock()
+	if prev := g.nodes[id]; prev != nil {
+		n = prev
+	} else {
+		g.nodes[id] = n
+	}
+	g.mu.Unlock()
+	return n, nil
+}
+
+func (g *Graph) readNodes(ids []githash.ObjectID) ([]*node, error) {
+	nodes := make([]*node, 0, len(ids))
+	for _, id := range ids {
+		n, err := g.node(id)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// nodeQueue is a priority queue of commits. Commits with greater generation
+// numbers come first (unless byTime is true), then newer commits,
+// then commits that were pushed first.
+type nodeQueue struct {
+	entries []queueEntry
+	ctr     uint64
+	byTime  bool
+}
+
+type queueEntry struct {
+	n   *node
+	ctr uint64
+	// counted is true if the entry is included in a walk's count of
+	// entries that still need to be processed.
+	counted bool
+}
+
+func (q *nodeQueue) push(n *node, counted bool) {
+	heap.Push(q, queueEntry{n: n, ctr: q.ctr, counted: counted})
+	q.ctr++
+}
+
+func (q *nodeQueue) pop() queueEntry {
+	return heap.Pop(q).(queueEntry)
+}
+
+func (q *nodeQueue) Len() int { return len(q.entries) }
+
+func (q *nodeQueue) Less(i, j int) bool {
+	ei, ej := q.entries[i], q.entries[j]
+	if !q.byTime && ei.n.gen != ej.n.gen {
+		return ei.n.gen > ej.n.gen
+	}
+	if !ei.n.commit.CommitTime.Equal(ej.n.commit.CommitTime) {
+		return ei.n.commit.CommitTime.After(ej.n.commit.CommitTime)
+	}
+	return ei.ctr < ej.ctr
+}
+
+func (q *nodeQueue) Swap(i, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+}
+
+func (q *nodeQueue) Push(x interface{}) {
+	q.entries = append(q.entries, x.(queueEntry))
+}
+
+func (q *nodeQueue) Pop() interface{} {
+	e := q.entries[len(q.entries)-1]
+	q.entries[len(q.entries)-1] = queueEntry{}
+	q.entries = q.entries[:len(q.entries)-1]
+	return e
+}
@@ -0,0 +1,349 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package revwalk
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// memCommits is an in-memory CommitReader.
+type memCommits struct {
+	commits map[githash.ObjectID]*object.Commit
+	names   map[githash.ObjectID]string
+	ids     map[string]githash.ObjectID
+	time    time.Time
+}
+
+func newMemCommits() *memCommits {
+	return &memCommits{
+		commits: make(map[githash.ObjectID]*object.Commit),
+		names:   make(map[githash.ObjectID]string),
+		ids:     make(map[string]githash.ObjectID),
+		time:    time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// commit adds a commit with the given name and parent names.
+// Each commit is one minute newer than the last.
+func (m *memCommits) commit(name string, parents ...string) githash.ObjectID {
+	c := &object.Commit{
+		Tree:       object.Tree{}.SHA1(),
+		Author:     "Octocat <octocat@example.com>",
+		AuthorTime: m.time,
+		Committer:  "Octocat <octocat@example.com>",
+		CommitTime: m.time,
+		Message:    name + "\n",
+	}
+	for _, p := range parents {
+		c.Parents = append(c.Parents, m.ids[p])
+	}
+	m.time = m.time.Add(time.Minute)
+	id := c.SHA1()
+	m.commits[id] = c
+	m.names[id] = name
+	m.ids[name] = id
+	return id
+}
+
+func (m *memCommits) ReadCommit(id githash.ObjectID) (*object.Commit, error) {
+	c := m.commits[id]
+	if c == nil {
+		return nil, fmt.Errorf("commit %v: %w", id, fs.ErrNotExist)
+	}
+	return c, nil
+}
+
+func (m *memCommits) toIDs(names []string) []githash.ObjectID {
+	ids := make([]githash.ObjectID, 0, len(names))
+	for _, name := range names {
+		ids = append(ids, m.ids[name])
+	}
+	return ids
+}
+
+func (m *memCommits) toNames(ids []githash.ObjectID) []string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, m.names[id])
+	}
+	return names
+}
+
+// memGenerations is a memCommits that implements GenerationReader
+// with topological levels.
+type memGenerations struct {
+	*memCommits
+	gens map[githash.ObjectID]uint64
+}
+
+func withGenerations(m *memCommits) *memGenerations {
+	mg := &memGenerations{
+		memCommits: m,
+		gens:       make(map[githash.ObjectID]uint64),
+	}
+	var level func(id githash.ObjectID) uint64
+	level = func(id githash.ObjectID) uint64 {
+		if gen, ok := mg.gens[id]; ok {
+			return gen
+		}
+		gen := uint64(1)
+		for _, p := range m.commits[id].Parents {
+			if pgen := level(p) + 1; pgen > gen {
+				gen = pgen
+			}
+		}
+		mg.gens[id] = gen
+		return gen
+	}
+	for id := range m.commits {
+		level(id)
+	}
+	return mg
+}
+
+func (mg *memGenerations) CommitGeneration(id githash.ObjectID) (uint64, bool) {
+	gen, ok := mg.gens[id]
+	return gen, ok
+}
+
+// testGraphs calls f with Graphs that do and do not use generation numbers.
+func testGraphs(t *testing.T, m *memCommits, f func(t *testing.T, g *Graph)) {
+	t.Run("NoGeneration", func(t *testing.T) {
+		f(t, New(m))
+	})
+	t.Run("Generation", func(t *testing.T) {
+		f(t, New(withGenerations(m)))
+	})
+}
+
+// newTestHistory returns the following history,
+// where time flows from left to right:
+//
+//	    A---B---C---M1--F  main
+//	         \     /
+//	          D---E---G---H  topic
+//	               \
+//	                I  side
+//
+//	    X---Y  unrelated
+//
+//	    P---Q---R1  criss
+//	     \   \ /
+//	      \   X
+//	       \ / \
+//	        S---R2  cross
+func newTestHistory() *memCommits {
+	m := newMemCommits()
+	m.commit("A")
+	m.commit("B", "A")
+	m.commit("C", "B")
+	m.commit("D", "B")
+	m.commit("E", "D")
+	m.commit("M1", "C", "E")
+	m.commit("F", "M1")
+	m.commit("G", "E")
+	m.commit("H", "G")
+	m.commit("I", "E")
+	m.commit("X")
+	m.commit("Y", "X")
+	m.commit("P")
+	m.commit("Q", "P")
+	m.commit("S", "P")
+	m.commit("R1", "Q", "S")
+	m.commit("R2", "S", "Q")
+	return m
+}
+
+func TestMergeBases(t *testing.T) {
+	m := newTestHistory()
+	tests := []struct {
+		commits []string
+		want    []string
+	}{
+		{commits: []string{"A", "A"}, want: []string{"A"}},
+		{commits: []string{"A", "C"}, want: []string{"A"}},
+		{commits: []string{"C", "A"}, want: []string{"A"}},
+		{commits: []string{"C", "D"}, want: []string{"B"}},
+		{commits: []string{"F", "H"}, want: []string{"E"}},
+		{commits: []string{"H", "I"}, want: []string{"E"}},
+		{commits: []string{"C", "H", "I"}, want: []string{"B"}},
+		{commits: []string{"F", "Y"}, want: []string{}},
+		{commits: []string{"R1", "R2"}, want: []string{"S", "Q"}},
+	}
+	testGraphs(t, m, func(t *testing.T, g *Graph) {
+		for _, test := range tests {
+			got, err := g.MergeBases(m.toIDs(test.commits)...)
+			if err != nil {
+				t.Errorf("MergeBases(%q): %v", test.commits, err)
+				continue
+			}
+			if diff := cmp.Diff(test.want, m.toNames(got), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("MergeBases(%q) (-want +got):\n%s", test.commits, diff)
+			}
+
+			best, err := g.MergeBase(m.toIDs(test.commits)...)
+			if err != nil {
+				t.Errorf("MergeBase(%q): %v", test.commits, err)
+				continue
+			}
+			if len(test.want) == 0 {
+				if !best.IsZero() {
+					t.Errorf("MergeBase(%q) = %s; want zero", test.commits, m.names[best])
+				}
+			} else if best != m.ids[test.want[0]] {
+				t.Errorf("MergeBase(%q) = %s; want %s", test.commits, m.names[best], test.want[0])
+			}
+		}
+		if _, err := g.MergeBases(m.ids["A"]); err == nil {
+			t.Error("MergeBases with a single commit did not return an error")
+		}
+	})
+}
+
+func TestOctopusMergeBases(t *testing.T) {
+	m := newTestHistory()
+	tests := []struct {
+		commits []string
+		want    []string
+	}{
+		{commits: []string{"F"}, want: []string{"F"}},
+		{commits: []string{"F", "H", "I"}, want: []string{"E"}},
+		{commits: []string{"C", "H", "I"}, want: []string{"B"}},
+		{commits: []string{"F", "H", "Y"}, want: []string{}},
+	}
+	testGraphs(t, m, func(t *testing.T, g *Graph) {
+		for _, test := range tests {
+			got, err := g.OctopusMergeBases(m.toIDs(test.commits)...)
+			if err != nil {
+				t.Errorf("OctopusMergeBases(%q): %v", test.commits, err)
+				continue
+			}
+			if diff := cmp.Diff(test.want, m.toNames(got), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("OctopusMergeBases(%q) (-want +got):\n%s", test.commits, diff)
+			}
+		}
+	})
+}
+
+func TestIndependent(t *testing.T) {
+	m := newTestHistory()
+	tests := []struct {
+		commits []string
+		want    []string
+	}{
+		{commits: []string{"F"}, want: []string{"F"}},
+		{commits: []string{"A", "F", "E", "H"}, want: []string{"F", "H"}},
+		{commits: []string{"H", "H", "G"}, want: []string{"H"}},
+		{commits: []string{"I", "Y", "B", "X"}, want: []string{"I", "Y"}},
+		{commits: []string{"R1", "R2", "Q", "S"}, want: []string{"R1", "R2"}},
+	}
+	testGraphs(t, m, func(t *testing.T, g *Graph) {
+		for _, test := range tests {
+			got, err := g.Independent(m.toIDs(test.commits)...)
+			if err != nil {
+				t.Errorf("Independent(%q): %v", test.commits, err)
+				continue
+			}
+			if diff := cmp.Diff(test.want, m.toNames(got)); diff != "" {
+				t.Errorf("Independent(%q) (-want +got):\n%s", test.commits, diff)
+			}
+		}
+	})
+}
+
+func TestIsAncestor(t *testing.T) {
+	m := newTestHistory()
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"A", "A", true},
+		{"A", "F", true},
+		{"F", "A", false},
+		{"E", "F", true},
+		{"G", "F", false},
+		{"I", "H", false},
+		{"D", "I", true},
+		{"X", "F", false},
+		{"Q", "R2", true},
+	}
+	testGraphs(t, m, func(t *testing.T, g *Graph) {
+		for _, test := range tests {
+			got, err := g.IsAncestor(m.ids[test.a], m.ids[test.b])
+			if err != nil {
+				t.Errorf("IsAncestor(%s, %s): %v", test.a, test.b, err)
+				continue
+			}
+			if got != test.want {
+				t.Errorf("IsAncestor(%s, %s) = %t; want %t", test.a, test.b, got, test.want)
+			}
+		}
+	})
+}
+
+func TestAheadBehind(t *testing.T) {
+	m := newTestHistory()
+	tests := []struct {
+		a, b       string
+		ahead      int
+		behind     int
+		wantsError bool
+	}{
+		{a: "F", b: "F"},
+		{a: "F", b: "C", ahead: 4},
+		{a: "C", b: "F", behind: 4},
+		{a: "F", b: "H", ahead: 3, behind: 2},
+		{a: "H", b: "I", ahead: 2, behind: 1},
+		{a: "Y", b: "B", ahead: 2, behind: 2},
+		{a: "R1", b: "R2", ahead: 1, behind: 1},
+	}
+	testGraphs(t, m, func(t *testing.T, g *Graph) {
+		for _, test := range tests {
+			ahead, behind, err := g.AheadBehind(m.ids[test.a], m.ids[test.b])
+			if err != nil {
+				t.Errorf("AheadBehind(%s, %s): %v", test.a, test.b, err)
+				continue
+			}
+			if ahead != test.ahead || behind != test.behind {
+				t.Errorf("AheadBehind(%s, %s) = %d, %d; want %d, %d", test.a, test.b, ahead, behind, test.ahead, test.behind)
+			}
+		}
+	})
+}
+
+func TestMissingCommit(t *testing.T) {
+	m := newTestHistory()
+	missing := githash.SHA1{0xde, 0xad}.ObjectID()
+	g := New(m)
+	if _, err := g.MergeBases(m.ids["F"], missing); err == nil {
+		t.Error("MergeBases with missing commit did not return an error")
+	}
+	if _, err := g.IsAncestor(missing, m.ids["F"]); err == nil {
+		t.Error("IsAncestor with missing commit did not return an error")
+	}
+	if _, err := g.Walk(WalkOptions{Include: []githash.ObjectID{missing}}); err == nil {
+		t.Error("Walk with missing commit did not return an error")
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package revwalk
+
+import (
+	"fmt"
+
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+)
+
+// WalkOptions specifies the commits to list in a walk and their order.
+//
+// `git rev-list A..B` is equivalent to Include: {B}, Hide: {A}.
+// `git rev-list A...B` is equivalent to Include: {A, B}
+// and Hide set to the result of [Graph.MergeBases](A, B).
+type WalkOptions struct {
+	// Include is the set of commits to start walking from.
+	Include []githash.ObjectID
+	// Hide is the set of commits whose history is excluded from the walk.
+	Hide []githash.ObjectID
+	// Order specifies the order in which commits are listed.
+	Order Order
+}
+
+// Order specifies the order in which commits are listed in a walk.
+type Order int
+
+// Walk orders. See https://git-scm.com/docs/git-rev-list#_commit_ordering
+// for details.
+const (
+	// DefaultOrder lists commits in reverse chronological order
+	// by commit time. Parents may be listed before their children
+	// if commit times are skewed.
+	DefaultOrder Order = iota
+	// TopoOrder shows no parents before all of their children are shown
+	// and avoids interleaving multiple lines of history.
+	TopoOrder
+	// DateOrder shows no parents before all of their children are shown,
+	// but otherwise lists commits in commit time order.
+	DateOrder
+)
+
+// String returns the Go constant name of the order.
+func (order Order) String() string {
+	switch order {
+	case DefaultOrder:
+		return "DefaultOrder"
+	case TopoOrder:
+		return "TopoOrder"
+	case DateOrder:
+		return "DateOrder"
+	default:
+		return fmt.Sprintf("Order(%d)", int(order))
+	}
+}
+
+// Flags used while limiting a walk.
+const (
+	walkIncluded = parent1
+	walkHidden   = parent2
+)
+
+// limitSlop is the number of commits to keep walking after every
+// remaining commit is hidden, in case of clock skew.
+// It matches the value used by Git.
+const limitSlop = 5
+
+// A Walker is an iterator over the commits in a walk.
+type Walker struct {
+	g   *Graph
+	err error
+	cur *node
+
+	// Lazy walk state, used for DefaultOrder walks without hidden commits.
+	queue nodeQueue
+	seen  map[githash.ObjectID]struct{}
+
+	// Precomputed walk state, used for all other walks.
+	list []*node
+}
+
+// Walk starts a walk over the history of opts.Include,
+// excluding the history of opts.Hide.
+func (g *Graph) Walk(opts WalkOptions) (*Walker, error) {
+	switch opts.Order {
+	case DefaultOrder, TopoOrder, DateOrder:
+	default:
+		return nil, fmt.Errorf("walk: unknown order %v", opts.Order)
+	}
+	include, err := g.readNodes(opts.Include)
+	if err != nil {
+		return nil, fmt.Errorf("walk: %w", err)
+	}
+	hide, err := g.readNodes(opts.Hide)
+	if err != nil {
+		return nil, fmt.Errorf("walk: %w", err)
+	}
+	w := &Walker{g: g}
+	if len(hide) == 0 && opts.Order == DefaultOrder {
+		w.queue.byTime = true
+		w.seen = make(map[githash.ObjectID]struct{})
+		for _, n := range include {
+			if _, dup := w.seen[n.id]; !dup {
+				w.seen[n.id] = struct{}{}
+				w.queue.push(n, false)
+			}
+		}
+		return w, nil
+	}
+
+	list, err := g.limit(include, hide)
+	if err != nil {
+		return nil, fmt.Errorf("walk: %w", err)
+	}
+	switch opts.Order {
+	case DefaultOrder:
+		sortByTime(list)
+	case TopoOrder:
+		list = topoSort(list, true)
+	case DateOrder:
+		list = topoSort(list, false)
+	}
+	w.list = list
+	return w, nil
+}
+
+// limit returns the commits reachable from include
+// that are not reachable from hide, in the order they were found.
+func (g *Graph) limit(include, hide []*node) ([]*node, error) {
+	p := newPaint()
+	for _, n := range hide {
+		p.flags[n.id] |= walkHidden | stale
+	}
+	for _, n := range include {
+		p.push(n, walkIncluded)
+	}
+	for _, n := range hide {
+		p.push(n, walkHidden)
+	}
+	var found []*node
+	slop := limitSlop
+	for p.queue.Len() > 0 {
+		if p.nonStale == 0 {
+			// Everything left is hidden.
+			slop--
+			if slop <= 0 {
+				break
+			}
+		} else {
+			slop = limitSlop
+		}
+		e := p.queue.pop()
+		if e.counted {
+			p.nonStale--
+		}
+		n := e.n
+		flags := p.flags[n.id]
+		if flags&walkHidden == 0 && flags&result == 0 {
+			p.flags[n.id] |= result
+			found = append(found, n)
+		}
+		propagate := flags & (walkIncluded | walkHidden | stale)
+		for _, parentID := range n.commit.Parents {
+			if p.flags[parentID]&propagate == propagate {
+				continue
+			}
+			parent, err := g.node(parentID)
+			if err != nil {
+				return nil, err
+			}
+			p.push(parent, propagate)
+		}
+	}
+	var list []*node
+	for _, n := range found {
+		if p.flags[n.id]&walkHidden == 0 {
+			list = append(list, n)
+		}
+	}
+	return list, nil
+}
+
+// topoSort sorts commits so that no parent comes before all of its children.
+// If lifo is true, then each line of history is listed before moving on
+// to the next. Otherwise, commits are listed in commit time order
+// when possible.
+func topoSort(nodes []*node, lifo bool) []*node {
+	indegree := make(map[githash.ObjectID]int, len(nodes))
+	for _, n := range nodes {
+		indegree[n.id] = 1
+	}
+	for _, n := range nodes {
+		for _, parentID := range n.commit.Parents {
+			if d := indegree[parentID]; d > 0 {
+				indegree[parentID] = d + 1
+			}
+		}
+	}
+	byID := make(map[githash.ObjectID]*node, len(nodes))
+	for _, n := range nodes {
+		byID[n.id] = n
+	}
+
+	sortByTime(nodes)
+	var stack []*node
+	queue := nodeQueue{byTime: true}
+	put := func(n *node) {
+		if lifo {
+			stack = append(stack, n)
+		} else {
+			queue.push(n, false)
+		}
+	}
+	for _, n := range nodes {
+		if indegree[n.id] == 1 {
+			put(n)
+		}
+	}
+	if lifo {
+		// Start with the newest tip.
+		for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
+			stack[i], stack[j] = stack[j], stack[i]
+		}
+	}
+	sorted := make([]*node, 0, len(nodes))
+	for {
+		var n *node
+		if lifo {
+			if len(stack) == 0 {
+				break
+			}
+			n = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		} else {
+			if queue.Len() == 0 {
+				break
+			}
+			n = queue.pop().n
+		}
+		for _, parentID := range n.commit.Parents {
+			d := indegree[parentID]
+			if d == 0 {
+				// Not part of the walk.
+				continue
+			}
+			d--
+			indegree[parentID] = d
+			if d == 1 {
+				put(byID[parentID])
+			}
+		}
+		sorted = append(sorted, n)
+	}
+	return sorted
+}
+
+// Next advances the walker to the next commit
+// and reports whether there is one.
+func (w *Walker) Next() bool {
+	w.cur = nil
+	if w.err != nil {
+		return false
+	}
+	if w.seen == nil {
+		if len(w.list) == 0 {
+			return false
+		}
+		w.cur = w.list[0]
+		w.list[0] = nil
+		w.list = w.list[1:]
+		return true
+	}
+
+	if w.queue.Len() == 0 {
+		return false
+	}
+	n := w.queue.pop().n
+	for _, parentID := range n.commit.Parents {
+		if _, seen := w.seen[parentID]; seen {
+			continue
+		}
+		w.seen[parentID] = struct{}{}
+		parent, err := w.g.node(parentID)
+		if err != nil {
+			w.err = fmt.Errorf("walk: %w", err)
+			return false
+		}
+		w.queue.push(parent, false)
+	}
+	w.cur = n
+	return true
+}
+
+// ID returns the ID of the current commit.
+// Next must be called at least once before calling ID.
+func (w *Walker) ID() githash.ObjectID {
+	if w.cur == nil {
+		return githash.ObjectID{}
+	}
+	return w.cur.id
+}
+
+// Commit returns the current commit.
+// Next must be called at least once before calling Commit.
+func (w *Walker) Commit() *object.Commit {
+	if w.cur == nil {
+		return nil
+	}
+	return w.cur.commit
+}
+
+// Err returns the first error encountered during the walk.
+func (w *Walker) Err() error {
+	return w.err
+}
@@ -0,0 +1,327 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package revwalk
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/object"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestWalk(t *testing.T) {
+	m := newTestHistory()
+	tests := []struct {
+		name    string
+		include []string
+		hide    []string
+		order   Order
+		want    []string
+	}{
+		{
+			name:    "Default",
+			include: []string{"F"},
+			want:    []string{"F", "M1", "E", "D", "C", "B", "A"},
+		},
+		{
+			name:    "MultipleTips",
+			include: []string{"H", "C", "H"},
+			want:    []string{"H", "G", "E", "D", "C", "B", "A"},
+		},
+		{
+			name:    "Range",
+			include: []string{"H"},
+			hide:    []string{"F"},
+			want:    []string{"H", "G"},
+		},
+		{
+			name:    "SymmetricDifference",
+			include: []string{"F", "H"},
+			hide:    []string{"E"},
+			want:    []string{"H", "G", "F", "M1", "C"},
+		},
+		{
+			name:    "HideEverything",
+			include: []string{"E"},
+			hide:    []string{"F"},
+			want:    []string{},
+		},
+		{
+			name:    "TopoOrder",
+			include: []string{"F"},
+			order:   TopoOrder,
+			want:    []string{"F", "M1", "E", "D", "C", "B", "A"},
+		},
+		{
+			name:    "TopoOrderMultipleTips",
+			include: []string{"F", "H", "I"},
+			hide:    []string{"B"},
+			order:   TopoOrder,
+			want:    []string{"I", "H", "G", "F", "M1", "E", "D", "C"},
+		},
+		{
+			name:    "DateOrder",
+			include: []string{"F", "H", "I"},
+			hide:    []string{"B"},
+			order:   DateOrder,
+			want:    []string{"I", "H", "G", "F", "M1", "E", "D", "C"},
+		},
+		{
+			name:    "DateOrderRange",
+			include: []string{"F"},
+			hide:    []string{"D"},
+			order:   DateOrder,
+			want:    []string{"F", "M1", "E", "C"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testGraphs(t, m, func(t *testing.T, g *Graph) {
+				w, err := g.Walk(WalkOptions{
+					Include: m.toIDs(test.include),
+					Hide:    m.toIDs(test.hide),
+					Order:   test.order,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for w.Next() {
+					if w.Commit() != m.commits[w.ID()] {
+						t.Errorf("Commit() for %s does not match ID()", m.names[w.ID()])
+					}
+					got = append(got, m.names[w.ID()])
+				}
+				if err := w.Err(); err != nil {
+					t.Error(err)
+				}
+				if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("walk (-want +got):\n%s", diff)
+				}
+			})
+		})
+	}
+}
+
+// TestAgainstGit compares the results of a Graph
+// with Git on a randomly generated history.
+func TestAgainstGit(t *testing.T) {
+	ctx := context.Background()
+	localGit, err := git.NewLocal(git.Options{
+		Env: []string{"GIT_CONFIG_NOSYSTEM=1"},
+	})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	if err := g.Run(ctx, "init", "--quiet", "--bare", "."); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	// Build a random history where each commit has one to three parents
+	// chosen from recent commits, with occasional new roots.
+	rng := rand.New(rand.NewSource(1))
+	m := newMemCommits()
+	tree, err := repo.WriteTree(object.Tree{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commits []githash.ObjectID
+	for i := 0; i < 200; i++ {
+		var parents []string
+		if i > 0 && rng.Intn(20) != 0 {
+			nparents := 1
+			if rng.Intn(4) == 0 {
+				nparents += 1 + rng.Intn(2)
+			}
+			seen := make(map[int]bool)
+			for j := 0; j < nparents; j++ {
+				back := 1 + rng.Intn(min(i, 15))
+				if !seen[back] {
+					seen[back] = true
+					parents = append(parents, "c"+strconv.Itoa(i-back))
+				}
+			}
+		}
+		id := m.commit("c"+strconv.Itoa(i), parents...)
+		c := m.commits[id]
+		if c.Tree != tree {
+			t.Fatalf("empty tree = %v; want %v", tree, c.Tree)
+		}
+		if got, err := repo.WriteCommit(c); err != nil {
+			t.Fatal(err)
+		} else if got != id {
+			t.Fatalf("WriteCommit(...) = %v; want %v", got, id)
+		}
+		commits = append(commits, id)
+	}
+
+	graph := New(m)
+	genGraph := New(withGenerations(m))
+	for i := 0; i < 40; i++ {
+		a := commits[rng.Intn(len(commits))]
+		b := commits[rng.Intn(len(commits))]
+		label := m.names[a] + " " + m.names[b]
+
+		out, err := g.Output(ctx, "merge-base", "--all", a.String(), b.String())
+		if err != nil && out != "" {
+			t.Fatal(err)
+		}
+		wantBases := parseIDs(t, out)
+		for _, gr := range []*Graph{graph, genGraph} {
+			got, err := gr.MergeBases(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(sortIDs(wantBases), sortIDs(got), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("MergeBases(%s) (-want +got):\n%s", label, diff)
+			}
+		}
+
+		wantAncestor, err := g.IsAncestor(ctx, a.String(), b.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, gr := range []*Graph{graph, genGraph} {
+			if got, err := gr.IsAncestor(a, b); err != nil {
+				t.Fatal(err)
+			} else if got != wantAncestor {
+				t.Errorf("IsAncestor(%s) = %t; want %t", label, got, wantAncestor)
+			}
+		}
+
+		out, err = g.Output(ctx, "rev-list", "--left-right", "--count", a.String()+"..."+b.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wantAhead, wantBehind int
+		if fields := strings.Fields(out); len(fields) == 2 {
+			wantAhead, _ = strconv.Atoi(fields[0])
+			wantBehind, _ = strconv.Atoi(fields[1])
+		}
+		for _, gr := range []*Graph{graph, genGraph} {
+			ahead, behind, err := gr.AheadBehind(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ahead != wantAhead || behind != wantBehind {
+				t.Errorf("AheadBehind(%s) = %d, %d; want %d, %d", label, ahead, behind, wantAhead, wantBehind)
+			}
+		}
+
+		out, err = g.Output(ctx, "rev-list", "--topo-order", b.String(), "^"+a.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantWalk := parseIDs(t, out)
+		for _, gr := range []*Graph{graph, genGraph} {
+			w, err := gr.Walk(WalkOptions{
+				Include: []githash.ObjectID{b},
+				Hide:    []githash.ObjectID{a},
+				Order:   TopoOrder,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []githash.ObjectID
+			for w.Next() {
+				got = append(got, w.ID())
+			}
+			if diff := cmp.Diff(wantWalk, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Walk(%s..%s) (-want +got):\n%s", m.names[a], m.names[b], diff)
+			}
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		ids := make([]githash.ObjectID, 2+rng.Intn(4))
+		args := []string{"merge-base", "--independent"}
+		for j := range ids {
+			ids[j] = commits[rng.Intn(len(commits))]
+			args = append(args, ids[j].String())
+		}
+		out, err := g.Output(ctx, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := parseIDs(t, out)
+		got, err := graph.Independent(ids...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(sortIDs(want), sortIDs(got)); diff != "" {
+			t.Errorf("Independent(%q) (-want +got):\n%s", m.toNames(ids), diff)
+		}
+
+		args[1] = "--octopus"
+		out, err = g.Output(ctx, append(args, "--all")...)
+		if err != nil && out != "" {
+			t.Fatal(err)
+		}
+		want = parseIDs(t, out)
+		got, err = graph.OctopusMergeBases(ids...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(sortIDs(want), sortIDs(got), cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("OctopusMergeBases(%q) (-want +got):\n%s", m.toNames(ids), diff)
+		}
+	}
+}
+
+func parseIDs(tb testing.TB, out string) []githash.ObjectID {
+	tb.Helper()
+	var ids []githash.ObjectID
+	for _, line := range strings.Fields(out) {
+		id, err := githash.ParseObjectID(line)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func sortIDs(ids []githash.ObjectID) []githash.ObjectID {
+	ids = append([]githash.ObjectID(nil), ids...)
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+	return ids
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
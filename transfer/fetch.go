@@ -0,0 +1,512 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package transfer provides high-level operations that move objects
+// between a local Git repository and a remote. It combines the wire protocol
+// in [gg-scm.io/pkg/git/packfile/client] with a local repository accessed
+// through [git.Git].
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/packfile"
+	"gg-scm.io/pkg/git/packfile/client"
+)
+
+// FetchOptions specifies optional parameters to Fetch.
+type FetchOptions struct {
+	// Progress receives progress messages from the remote. It may be nil.
+	Progress io.Writer
+	// NegotiationAlgorithm selects how local commits are sent to the remote
+	// to find common history.
+	NegotiationAlgorithm client.NegotiationAlgorithm
+}
+
+// UpdateStatus describes the outcome of a single ref update.
+type UpdateStatus int
+
+// Update statuses. The names correspond to the flags
+// printed by `git fetch --porcelain` and `git push --porcelain`.
+const (
+	// UpToDate indicates that the local ref already had the remote's value.
+	UpToDate UpdateStatus = iota
+	// FastForward indicates that the local ref was advanced
+	// to a descendant of its previous value.
+	FastForward
+	// ForcedUpdate indicates that the local ref was set to a commit
+	// that is not a descendant of its previous value.
+	ForcedUpdate
+	// NewRef indicates that the local ref was created.
+	NewRef
+	// Rejected indicates that the local ref was not updated
+	// because the update was not a fast-forward or would move a tag,
+	// and the refspec did not permit forced updates.
+	Rejected
+	// Deleted indicates that the ref was deleted.
+	// Only Push deletes refs.
+	Deleted
+)
+
+// Flag returns the single-character flag that `git fetch --porcelain`
+// and `git push --porcelain` use for the status.
+func (status UpdateStatus) Flag() byte {
+	switch status {
+	case UpToDate:
+		return '='
+	case FastForward:
+		return ' '
+	case ForcedUpdate:
+		return '+'
+	case NewRef:
+		return '*'
+	case Rejected:
+		return '!'
+	case Deleted:
+		return '-'
+	default:
+		return '?'
+	}
+}
+
+// String returns a human-readable name of the status.
+func (status UpdateStatus) String() string {
+	switch status {
+	case UpToDate:
+		return "up to date"
+	case FastForward:
+		return "fast-forward"
+	case ForcedUpdate:
+		return "forced update"
+	case NewRef:
+		return "new"
+	case Rejected:
+		return "rejected"
+	case Deleted:
+		return "deleted"
+	default:
+		return "UpdateStatus(" + strconv.Itoa(int(status)) + ")"
+	}
+}
+
+// A RefUpdate describes the effect of a fetch on a single local ref.
+type RefUpdate struct {
+	Status UpdateStatus
+	// RemoteRef is the name of the ref on the remote.
+	RemoteRef githash.Ref
+	// LocalRef is the name of the ref in the local repository.
+	LocalRef githash.Ref
+	// OldObjectID is the value of LocalRef before the fetch.
+	// It is the zero value if the ref did not exist.
+	OldObjectID githash.ObjectID
+	// NewObjectID is the value of RemoteRef.
+	// If Status is Rejected, LocalRef still has OldObjectID.
+	NewObjectID githash.ObjectID
+}
+
+// String formats the update like a line of `git fetch --porcelain` output.
+func (u *RefUpdate) String() string {
+	format := u.NewObjectID.ObjectFormat()
+	old := u.OldObjectID
+	if old.IsZero() {
+		old = format.Null()
+	}
+	return string(u.Status.Flag()) + " " + old.String() + " " + u.NewObjectID.String() + " " + string(u.LocalRef)
+}
+
+// Fetch downloads the objects for the remote refs matched by refspecs
+// into the repository g operates on and updates the corresponding local refs.
+// The history of the local refs is used to negotiate a minimal packfile
+// with the remote over as many rounds as necessary.
+// The packfile is stored in the repository's objects/pack directory
+// along with a newly built index. Before updating any refs,
+// Fetch verifies that every object reachable from the new ref values
+// is present in the repository.
+//
+// Updates that are not fast-forwards are only made
+// if the matching refspec starts with a "+".
+// Existing tags are never moved unless forced.
+// All accepted ref updates are made in a single atomic transaction:
+// if any of them fails, none of the refs are changed.
+// Fetch returns the list of updates sorted by local ref name,
+// including rejected updates, which do not cause Fetch to return an error.
+func Fetch(ctx context.Context, g *git.Git, remote *client.Remote, refspecs []git.FetchRefspec, opts *FetchOptions) (_ []*RefUpdate, err error) {
+	if opts == nil {
+		opts = new(FetchOptions)
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("fetch: %w", err)
+		}
+	}()
+
+	format, err := g.ObjectFormat(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := remote.StartPull(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	if remoteFormat := stream.ObjectFormat(); remoteFormat != format {
+		return nil, fmt.Errorf("remote uses %v object format, but local repository uses %v", remoteFormat, format)
+	}
+	remoteRefs, err := stream.ListRefs()
+	if err != nil {
+		return nil, err
+	}
+	localRefs, err := listLocalRefs(ctx, g)
+	if err != nil {
+		return nil, err
+	}
+	updates, forced, err := mapRefs(remoteRefs, localRefs, refspecs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Request every object that a ref would change to.
+	var want []githash.ObjectID
+	wantSet := make(map[githash.ObjectID]struct{})
+	for _, u := range updates {
+		if u.OldObjectID == u.NewObjectID {
+			continue
+		}
+		if _, dup := wantSet[u.NewObjectID]; !dup {
+			wantSet[u.NewObjectID] = struct{}{}
+			want = append(want, u.NewObjectID)
+		}
+	}
+	if len(want) > 0 {
+		resp, err := negotiate(ctx, g, stream, format, &client.PullRequest{
+			Want:     want,
+			Progress: opts.Progress,
+		}, opts.NegotiationAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		commonDir, err := g.CommonDir(ctx)
+		if err != nil {
+			resp.Packfile.Close()
+			return nil, err
+		}
+		err = storePack(filepath.Join(commonDir, "objects", "pack"), resp.Packfile, format)
+		resp.Packfile.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	muts := make(map[githash.Ref]git.RefMutation)
+	for _, u := range updates {
+		if err := classifyUpdate(ctx, g, u, forced[u.LocalRef]); err != nil {
+			return nil, err
+		}
+		switch u.Status {
+		case NewRef:
+			muts[u.LocalRef] = git.CreateRef(u.NewObjectID.String())
+		case FastForward, ForcedUpdate:
+			muts[u.LocalRef] = git.SetRefIfMatches(u.OldObjectID.String(), u.NewObjectID.String())
+		}
+	}
+	newIDs := make([]githash.ObjectID, 0, len(muts))
+	for _, u := range updates {
+		if _, ok := muts[u.LocalRef]; ok {
+			newIDs = append(newIDs, u.NewObjectID)
+		}
+	}
+	if err := checkConnected(ctx, g, newIDs); err != nil {
+		return nil, err
+	}
+	if err := g.MutateRefs(ctx, muts); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// checkConnected verifies that every object reachable from ids
+// is present in the local repository, like Git's check_connected.
+// Objects reachable from the existing refs are assumed to be present.
+func checkConnected(ctx context.Context, g *git.Git, ids []githash.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []string{"rev-list", "--objects", "--quiet"}
+	for _, id := range ids {
+		args = append(args, id.String())
+	}
+	args = append(args, "--not", "--all")
+	if err := g.Run(ctx, args...); err != nil {
+		return fmt.Errorf("remote did not send all necessary objects: %w", err)
+	}
+	return nil
+}
+
+// listLocalRefs returns the values of all the refs in the local repository.
+func listLocalRefs(ctx context.Context, g *git.Git) (map[githash.Ref]githash.ObjectID, error) {
+	refs := make(map[githash.Ref]githash.ObjectID)
+	iter := g.IterateRefs(ctx, git.IterateRefsOptions{})
+	for iter.Next() {
+		refs[iter.Ref()] = iter.ObjectID()
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// mapRefs applies refspecs to the remote refs. It returns the updates
+// sorted by local ref name and the set of local refs that may be force-updated.
+func mapRefs(remoteRefs map[githash.Ref]*client.Ref, localRefs map[githash.Ref]githash.ObjectID, refspecs []git.FetchRefspec) ([]*RefUpdate, map[githash.Ref]bool, error) {
+	byLocal := make(map[githash.Ref]*RefUpdate)
+	forced := make(map[githash.Ref]bool)
+	for _, spec := range refspecs {
+		_, _, plus := spec.Parse()
+		for _, ref := range remoteRefs {
+			if ref.ObjectID.IsZero() {
+				// Unborn HEAD.
+				continue
+			}
+			local := spec.Map(ref.Name)
+			if local == "" {
+				continue
+			}
+			if !local.IsValid() {
+				return nil, nil, fmt.Errorf("refspec %q maps %s to invalid ref %q", spec, ref.Name, local)
+			}
+			if prev := byLocal[local]; prev != nil {
+				if prev.RemoteRef != ref.Name {
+					return nil, nil, fmt.Errorf("%s and %s both map to %s", prev.RemoteRef, ref.Name, local)
+				}
+			} else {
+				byLocal[local] = &RefUpdate{
+					RemoteRef:   ref.Name,
+					LocalRef:    local,
+					OldObjectID: localRefs[local],
+					NewObjectID: ref.ObjectID,
+				}
+			}
+			if plus {
+				forced[local] = true
+			}
+		}
+	}
+	updates := make([]*RefUpdate, 0, len(byLocal))
+	for _, u := range byLocal {
+		updates = append(updates, u)
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].LocalRef < updates[j].LocalRef
+	})
+	return updates, forced, nil
+}
+
+// negotiate requests a packfile for req, using the history of
+// the local refs to find commits in common with the remote.
+func negotiate(ctx context.Context, g *git.Git, stream *client.PullStream, format githash.ObjectFormat, req *client.PullRequest, algorithm client.NegotiationAlgorithm) (*client.PullResponse, error) {
+	tips, err := localTips(ctx, g)
+	if err != nil {
+		return nil, err
+	}
+	if len(tips) == 0 {
+		return stream.Negotiate(req)
+	}
+	negOpts := &client.NegotiatorOptions{Algorithm: algorithm}
+
+	// The negotiator reads every tip before walking their history,
+	// so prefer reading commits by ID. gitrepo reads the object database
+	// directly, so it works with any version of Git.
+	gitDir, err := g.GitDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if repo, err := gitrepo.Open(gitDir); err == nil {
+		defer repo.Close()
+		return client.NewNegotiator(repo, tips, negOpts).Negotiate(stream, req)
+	}
+
+	// Fall back to reading the history with git log.
+	revs := make([]string, 0, len(tips))
+	for _, id := range tips {
+		revs = append(revs, id.String())
+	}
+	log, err := g.Log(ctx, git.LogOptions{Revs: revs})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.NewNegotiator(NewLogCommitSource(log, format), tips, negOpts).Negotiate(stream, req)
+	closeErr := log.Close()
+	if err != nil {
+		return nil, err
+	}
+	if closeErr != nil {
+		resp.Packfile.Close()
+		return nil, closeErr
+	}
+	return resp, nil
+}
+
+// localTips returns the commits that the local refs point to,
+// peeling any annotated tags.
+func localTips(ctx context.Context, g *git.Git) ([]githash.ObjectID, error) {
+	values := make(map[githash.Ref]githash.ObjectID)
+	iter := g.IterateRefs(ctx, git.IterateRefsOptions{DereferenceTags: true})
+	for iter.Next() {
+		// Dereferenced tags are listed after the tag itself.
+		values[iter.Ref()] = iter.ObjectID()
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	var tips []githash.ObjectID
+	seen := make(map[githash.ObjectID]struct{})
+	for _, id := range values {
+		if _, dup := seen[id]; !dup {
+			seen[id] = struct{}{}
+			tips = append(tips, id)
+		}
+	}
+	sort.Slice(tips, func(i, j int) bool {
+		return tips[i].String() < tips[j].String()
+	})
+	return tips, nil
+}
+
+// classifyUpdate sets u.Status. It must be called after the objects
+// referenced by u are present in the local repository.
+func classifyUpdate(ctx context.Context, g *git.Git, u *RefUpdate, force bool) error {
+	switch {
+	case u.OldObjectID == u.NewObjectID:
+		u.Status = UpToDate
+	case u.OldObjectID.IsZero():
+		u.Status = NewRef
+	case u.LocalRef.IsTag() && force:
+		u.Status = ForcedUpdate
+	case u.LocalRef.IsTag():
+		u.Status = Rejected
+	default:
+		ff, err := g.IsAncestor(ctx, u.OldObjectID.String(), u.NewObjectID.String())
+		if err != nil {
+			return err
+		}
+		switch {
+		case ff:
+			u.Status = FastForward
+		case force:
+			u.Status = ForcedUpdate
+		default:
+			u.Status = Rejected
+		}
+	}
+	return nil
+}
+
+// storePack copies the packfile from r into packDir
+// and writes an index for it.
+// Packfiles without any objects are discarded.
+func storePack(packDir string, r io.Reader, format githash.ObjectFormat) (err error) {
+	if err := os.MkdirAll(packDir, 0o777); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(packDir, "tmp_pack_")
+	if err != nil {
+		return err
+	}
+	packTemp := f.Name()
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+		if err != nil {
+			os.Remove(packTemp)
+		}
+	}()
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return fmt.Errorf("store packfile: %w", err)
+	}
+	idx, err := packfile.BuildIndex(f, size, &packfile.IndexOptions{ObjectFormat: format})
+	if err != nil {
+		return fmt.Errorf("store packfile: %w", err)
+	}
+	if len(idx.ObjectIDs) == 0 {
+		f.Close()
+		f = nil
+		return os.Remove(packTemp)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		f = nil
+		return err
+	}
+	f = nil
+
+	idxFile, err := os.CreateTemp(packDir, "tmp_idx_")
+	if err != nil {
+		return err
+	}
+	idxTemp := idxFile.Name()
+	defer func() {
+		if err != nil {
+			os.Remove(idxTemp)
+		}
+	}()
+	if err := idx.EncodeV2(idxFile); err != nil {
+		idxFile.Close()
+		return fmt.Errorf("store packfile index: %w", err)
+	}
+	if err := idxFile.Sync(); err != nil {
+		idxFile.Close()
+		return err
+	}
+	if err := idxFile.Close(); err != nil {
+		return err
+	}
+
+	// Git only considers a packfile once its index is present,
+	// so move the index into place last.
+	base := filepath.Join(packDir, "pack-"+idx.PackfileSHA1.String())
+	for _, temp := range []string{packTemp, idxTemp} {
+		if err := os.Chmod(temp, 0o444); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(base + ".idx"); err == nil {
+		// The repository already has this packfile.
+		os.Remove(packTemp)
+		os.Remove(idxTemp)
+		return nil
+	}
+	if err := os.Rename(packTemp, base+".pack"); err != nil {
+		return err
+	}
+	if err := os.Rename(idxTemp, base+".idx"); err != nil {
+		os.Remove(base + ".pack")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/object"
+	"gg-scm.io/pkg/git/packfile/client"
+	"gg-scm.io/pkg/git/packfile/server"
+	"github.com/google/go-cmp/cmp"
+)
+
+var testFormats = []githash.ObjectFormat{githash.SHA1Format, githash.SHA256Format}
+
+func TestFetch(t *testing.T) {
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	for _, format := range testFormats {
+		t.Run(format.String(), func(t *testing.T) {
+			ctx := context.Background()
+			store := server.NewMemoryStore(format)
+			src := newTestHistory(t, store)
+			commit1 := src.commit("foo.txt", "Hello, World!\n")
+			tag1 := src.tag(commit1, "v1")
+			src.setRef(githash.BranchRef("main"), commit1)
+			src.setRef(githash.BranchRef("other"), commit1)
+			src.setRef(githash.TagRef("v1"), tag1)
+
+			httpServer := httptest.NewServer(server.New(store, nil))
+			t.Cleanup(httpServer.Close)
+			u, err := client.ParseURL(httpServer.URL + "/repo.git")
+			if err != nil {
+				t.Fatal(err)
+			}
+			remote, err := client.NewRemote(u, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dir := t.TempDir()
+			g := git.Custom(dir, localGit, localGit)
+			if err := g.Run(ctx, "init", "--quiet", "--bare", "--object-format="+format.String(), "."); err != nil {
+				t.Skip("Can't create repository with object format:", err)
+			}
+			fetch := func(t *testing.T, refspecs ...git.FetchRefspec) []*RefUpdate {
+				t.Helper()
+				updates, err := Fetch(ctx, g, remote, refspecs, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := g.Run(ctx, "fsck", "--no-dangling", "--no-progress"); err != nil {
+					t.Error(err)
+				}
+				return updates
+			}
+			checkRefs := func(t *testing.T, want map[githash.Ref]githash.ObjectID) {
+				t.Helper()
+				got, err := listLocalRefs(ctx, g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("refs (-want +got):\n%s", diff)
+				}
+			}
+			const (
+				branches = git.FetchRefspec("refs/heads/*:refs/remotes/origin/*")
+				tags     = git.FetchRefspec("refs/tags/*:refs/tags/*")
+			)
+			originMain := githash.Ref("refs/remotes/origin/main")
+			originOther := githash.Ref("refs/remotes/origin/other")
+
+			t.Run("Initial", func(t *testing.T) {
+				got := fetch(t, branches, tags)
+				want := []*RefUpdate{
+					{Status: NewRef, RemoteRef: githash.BranchRef("main"), LocalRef: originMain, NewObjectID: commit1},
+					{Status: NewRef, RemoteRef: githash.BranchRef("other"), LocalRef: originOther, NewObjectID: commit1},
+					{Status: NewRef, RemoteRef: githash.TagRef("v1"), LocalRef: githash.TagRef("v1"), NewObjectID: tag1},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+				checkRefs(t, map[githash.Ref]githash.ObjectID{
+					originMain:           commit1,
+					originOther:          commit1,
+					githash.TagRef("v1"): tag1,
+				})
+			})
+
+			commit2 := src.commit("bar.txt", "Goodbye\n", commit1)
+			root2 := src.commit("baz.txt", "Unrelated\n")
+			tag2 := src.tag(commit2, "v1")
+			src.setRef(githash.BranchRef("main"), commit2)
+			src.setRef(githash.BranchRef("other"), root2)
+			src.setRef(githash.TagRef("v1"), tag2)
+
+			t.Run("Rejected", func(t *testing.T) {
+				got := fetch(t, branches, tags)
+				want := []*RefUpdate{
+					{Status: FastForward, RemoteRef: githash.BranchRef("main"), LocalRef: originMain, OldObjectID: commit1, NewObjectID: commit2},
+					{Status: Rejected, RemoteRef: githash.BranchRef("other"), LocalRef: originOther, OldObjectID: commit1, NewObjectID: root2},
+					{Status: Rejected, RemoteRef: githash.TagRef("v1"), LocalRef: githash.TagRef("v1"), OldObjectID: tag1, NewObjectID: tag2},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+				checkRefs(t, map[githash.Ref]githash.ObjectID{
+					originMain:           commit2,
+					originOther:          commit1,
+					githash.TagRef("v1"): tag1,
+				})
+			})
+
+			t.Run("Forced", func(t *testing.T) {
+				got := fetch(t, "+"+branches, "+"+tags)
+				want := []*RefUpdate{
+					{Status: UpToDate, RemoteRef: githash.BranchRef("main"), LocalRef: originMain, OldObjectID: commit2, NewObjectID: commit2},
+					{Status: ForcedUpdate, RemoteRef: githash.BranchRef("other"), LocalRef: originOther, OldObjectID: commit1, NewObjectID: root2},
+					{Status: ForcedUpdate, RemoteRef: githash.TagRef("v1"), LocalRef: githash.TagRef("v1"), OldObjectID: tag1, NewObjectID: tag2},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+				checkRefs(t, map[githash.Ref]githash.ObjectID{
+					originMain:           commit2,
+					originOther:          root2,
+					githash.TagRef("v1"): tag2,
+				})
+			})
+
+			t.Run("Conflict", func(t *testing.T) {
+				_, err := Fetch(ctx, g, remote, []git.FetchRefspec{"refs/heads/*:refs/remotes/origin/x"}, nil)
+				if err == nil {
+					t.Error("Fetch did not return an error")
+				}
+			})
+		})
+	}
+}
+
+func TestRefUpdateString(t *testing.T) {
+	oldID, err := githash.ParseObjectID("8a3ca1e12ab3a9e1e6e0a7d4f1d4a6e2d0c3e6b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newID, err := githash.ParseObjectID("c4d39e3d1f2a0bb7d2f1b9a3bdc6a6c37b3d5e12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		u    *RefUpdate
+		want string
+	}{
+		{
+			u: &RefUpdate{
+				Status:      NewRef,
+				RemoteRef:   "refs/heads/main",
+				LocalRef:    "refs/remotes/origin/main",
+				NewObjectID: newID,
+			},
+			want: "* 0000000000000000000000000000000000000000 c4d39e3d1f2a0bb7d2f1b9a3bdc6a6c37b3d5e12 refs/remotes/origin/main",
+		},
+		{
+			u: &RefUpdate{
+				Status:      FastForward,
+				RemoteRef:   "refs/heads/main",
+				LocalRef:    "refs/remotes/origin/main",
+				OldObjectID: oldID,
+				NewObjectID: newID,
+			},
+			want: "  8a3ca1e12ab3a9e1e6e0a7d4f1d4a6e2d0c3e6b1 c4d39e3d1f2a0bb7d2f1b9a3bdc6a6c37b3d5e12 refs/remotes/origin/main",
+		},
+		{
+			u: &RefUpdate{
+				Status:      Rejected,
+				RemoteRef:   "refs/tags/v1",
+				LocalRef:    "refs/tags/v1",
+				OldObjectID: oldID,
+				NewObjectID: newID,
+			},
+			want: "! 8a3ca1e12ab3a9e1e6e0a7d4f1d4a6e2d0c3e6b1 c4d39e3d1f2a0bb7d2f1b9a3bdc6a6c37b3d5e12 refs/tags/v1",
+		},
+	}
+	for _, test := range tests {
+		if got := test.u.String(); got != test.want {
+			t.Errorf("(%+v).String() = %q; want %q", test.u, got, test.want)
+		}
+	}
+}
+
+// testHistory writes objects to a server.MemoryStore.
+type testHistory struct {
+	tb    testing.TB
+	store *server.MemoryStore
+	refs  map[githash.Ref]githash.ObjectID
+	time  time.Time
+}
+
+func newTestHistory(tb testing.TB, store *server.MemoryStore) *testHistory {
+	return &testHistory{
+		tb:    tb,
+		store: store,
+		refs:  make(map[githash.Ref]githash.ObjectID),
+		time:  time.Date(2020, time.January, 9, 14, 50, 0, 0, time.UTC),
+	}
+}
+
+const testAuthor object.User = "Octocat <octocat@example.com>"
+
+func (h *testHistory) write(typ object.Type, m encoding.BinaryMarshaler) githash.ObjectID {
+	h.tb.Helper()
+	data, err := m.MarshalBinary()
+	if err != nil {
+		h.tb.Fatal("MarshalBinary:", err)
+	}
+	id, err := h.store.WriteObject(object.Prefix{Type: typ, Size: int64(len(data))}, bytes.NewReader(data))
+	if err != nil {
+		h.tb.Fatal(err)
+	}
+	return id
+}
+
+// commit writes a commit with a single file.
+func (h *testHistory) commit(name, content string, parents ...githash.ObjectID) githash.ObjectID {
+	h.tb.Helper()
+	blob, err := h.store.WriteObject(object.Prefix{Type: object.TypeBlob, Size: int64(len(content))}, bytes.NewReader([]byte(content)))
+	if err != nil {
+		h.tb.Fatal(err)
+	}
+	tree := h.write(object.TypeTree, object.Tree{
+		{Name: name, Mode: object.ModePlain, ObjectID: blob},
+	})
+	h.time = h.time.Add(time.Hour)
+	return h.write(object.TypeCommit, &object.Commit{
+		Tree:       tree,
+		Parents:    parents,
+		Author:     testAuthor,
+		AuthorTime: h.time,
+		Committer:  testAuthor,
+		CommitTime: h.time,
+		Message:    "Add " + name,
+	})
+}
+
+func (h *testHistory) tag(target githash.ObjectID, name string) githash.ObjectID {
+	h.tb.Helper()
+	h.time = h.time.Add(time.Hour)
+	return h.write(object.TypeTag, &object.Tag{
+		ObjectID:   target,
+		ObjectType: object.TypeCommit,
+		Name:       name,
+		Tagger:     testAuthor,
+		Time:       h.time,
+		Message:    "Release " + name + "\n",
+	})
+}
+
+func (h *testHistory) setRef(name githash.Ref, id githash.ObjectID) {
+	h.tb.Helper()
+	if err := h.store.UpdateRef(name, h.refs[name], id); err != nil {
+		h.tb.Fatal(err)
+	}
+	h.refs[name] = id
+}
+
+func TestCheckConnected(t *testing.T) {
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	g := git.Custom(dir, localGit, localGit)
+	if err := g.Run(ctx, "init", "--quiet", "--bare", "."); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	// Write a commit without its blob.
+	const content = "Hello, World!\n"
+	blob, err := object.BlobSum(strings.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := repo.WriteTree(object.Tree{
+		{Name: "foo.txt", Mode: object.ModePlain, ObjectID: blob},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitTime := time.Date(2020, time.January, 9, 14, 50, 0, 0, time.UTC)
+	commit, err := repo.WriteCommit(&object.Commit{
+		Tree:       tree,
+		Author:     testAuthor,
+		AuthorTime: commitTime,
+		Committer:  testAuthor,
+		CommitTime: commitTime,
+		Message:    "Add foo.txt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkConnected(ctx, g, []githash.ObjectID{commit}); err == nil {
+		t.Error("checkConnected did not return an error for commit without its blob")
+	}
+
+	if _, err := repo.WriteObject(object.TypeBlob, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkConnected(ctx, g, []githash.ObjectID{commit}); err != nil {
+		t.Error("After writing blob:", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transfer
+
+import (
+	"fmt"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/object"
+)
+
+// LogCommitSource is a [client.CommitSource] that reads commits from a [git.Log].
+// Commits are read from the log on demand, so the log should list the history
+// of the commits being negotiated, newest first. This is the default order of
+// [git.Git.Log], and the order in which a [client.Negotiator] reads commits.
+// Reading a commit requires reading every commit listed before it,
+// so a source with random access to commits, like a [*gitrepo.Repository],
+// should be used instead if one is available.
+//
+// [*gitrepo.Repository]: https://pkg.go.dev/gg-scm.io/pkg/git/gitrepo#Repository
+type LogCommitSource struct {
+	log     *git.Log
+	format  githash.ObjectFormat
+	commits map[githash.ObjectID]*object.Commit
+	done    bool
+}
+
+// NewLogCommitSource returns a new LogCommitSource that reads from log,
+// which lists commits in the given object format.
+// The caller is responsible for closing log.
+func NewLogCommitSource(log *git.Log, format githash.ObjectFormat) *LogCommitSource {
+	return &LogCommitSource{
+		log:     log,
+		format:  format,
+		commits: make(map[githash.ObjectID]*object.Commit),
+	}
+}
+
+// ReadCommit returns the commit with the given ID,
+// reading further in the log if necessary.
+func (src *LogCommitSource) ReadCommit(id githash.ObjectID) (*object.Commit, error) {
+	if c := src.commits[id]; c != nil {
+		return c, nil
+	}
+	for !src.done && src.log.Next() {
+		c := src.log.CommitInfo()
+		cid := c.Sum(src.format)
+		src.commits[cid] = c
+		if cid == id {
+			return c, nil
+		}
+	}
+	// Any error from the log is reported by the caller's Close.
+	src.done = true
+	return nil, fmt.Errorf("read commit %v: not found in log", id)
+}
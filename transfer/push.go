@@ -0,0 +1,820 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/object"
+	"gg-scm.io/pkg/git/packfile"
+	"gg-scm.io/pkg/git/packfile/client"
+	"gg-scm.io/pkg/git/revwalk"
+)
+
+// PushOptions specifies optional parameters to Push.
+type PushOptions struct {
+	// Progress receives progress messages and hook output from the remote.
+	// It may be nil.
+	Progress io.Writer
+
+	// Leases maps remote ref names to the values the caller expects
+	// the remote refs to have, like `git push --force-with-lease=<ref>:<expect>`.
+	// A zero ObjectID expects the remote ref to not exist.
+	// An update to a leased ref is made even if it is not a fast-forward,
+	// but it is rejected if the remote ref does not have the expected value.
+	Leases map[githash.Ref]githash.ObjectID
+
+	// If Thin is true, then objects may be sent as deltas
+	// against objects that the remote already has,
+	// like `git push --thin`.
+	// Thin is ignored if the remote has [client.PushCapNoThin].
+	Thin bool
+
+	// If Atomic is true, then the remote either updates all of the refs
+	// or none of them. The remote must support [client.PushCapAtomic].
+	Atomic bool
+
+	// Options is a list of strings passed to the remote's receive hooks,
+	// like `git push --push-option`.
+	// The remote must support [client.PushCapOptions].
+	Options []string
+}
+
+// A PushUpdate describes the effect of a push on a single remote ref.
+type PushUpdate struct {
+	Status UpdateStatus
+	// LocalRef is the name of the ref in the local repository.
+	// It is empty if Status is Deleted.
+	LocalRef githash.Ref
+	// RemoteRef is the name of the ref on the remote.
+	RemoteRef githash.Ref
+	// OldObjectID is the value of RemoteRef before the push.
+	// It is the zero value if the ref did not exist.
+	OldObjectID githash.ObjectID
+	// NewObjectID is the value of LocalRef.
+	// It is the zero value if Status is Deleted.
+	// If Status is Rejected, RemoteRef still has OldObjectID.
+	NewObjectID githash.ObjectID
+	// Reason explains why the update was rejected, using the same
+	// phrases as git-push(1) (e.g. "non-fast-forward" or "stale info")
+	// or the error the remote reported.
+	Reason string
+}
+
+// String formats the update like a line of `git push --porcelain` output.
+func (u *PushUpdate) String() string {
+	s := string(u.Status.Flag()) + "\t" + string(u.LocalRef) + ":" + string(u.RemoteRef) + "\t"
+	switch u.Status {
+	case NewRef:
+		if u.RemoteRef.IsTag() {
+			s += "[new tag]"
+		} else {
+			s += "[new branch]"
+		}
+	case Deleted:
+		s += "[deleted]"
+	case UpToDate:
+		s += "[up to date]"
+	case Rejected:
+		s += "[rejected]"
+	case FastForward:
+		s += u.OldObjectID.String() + ".." + u.NewObjectID.String()
+	case ForcedUpdate:
+		s += u.OldObjectID.String() + "..." + u.NewObjectID.String()
+	}
+	if u.Reason != "" {
+		s += " (" + u.Reason + ")"
+	}
+	return s
+}
+
+// Push updates the remote refs matched by refspecs to the values of
+// the corresponding refs in repo and sends the remote any objects
+// reachable from the new values that are not reachable from
+// the remote's current refs.
+//
+// Updates that are not fast-forwards are only made if the matching refspec
+// starts with a "+" or the remote ref has a lease in opts.Leases.
+// Existing tags are never moved unless forced.
+// Push returns the list of updates sorted by remote ref name,
+// including updates rejected locally or by the remote,
+// which do not cause Push to return an error.
+func Push(ctx context.Context, repo Repository, remote *client.Remote, refspecs []git.PushRefspec, opts *PushOptions) (_ []*PushUpdate, err error) {
+	if opts == nil {
+		opts = new(PushOptions)
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("push: %w", err)
+		}
+	}()
+
+	format := repo.ObjectFormat()
+	localRefs, err := repo.ListRefs()
+	if err != nil {
+		return nil, err
+	}
+	updates, forced, err := mapPushRefs(localRefs, refspecs)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := remote.StartPush(ctx)
+	if err != nil {
+		return nil, err
+	}
+	streamClosed := false
+	defer func() {
+		if !streamClosed {
+			stream.Close()
+		}
+	}()
+	if remoteFormat := stream.ObjectFormat(); remoteFormat != format {
+		return nil, fmt.Errorf("remote uses %v object format, but local repository uses %v", remoteFormat, format)
+	}
+	remoteRefs := stream.Refs()
+
+	objects := &objectSource{repo: repo}
+	graph := revwalk.New(objects)
+	var commands []*client.PushCommand
+	for _, u := range updates {
+		if ref := remoteRefs[u.RemoteRef]; ref != nil {
+			u.OldObjectID = ref.ObjectID
+		}
+		if lease, leased := opts.Leases[u.RemoteRef]; leased && u.OldObjectID != lease {
+			u.Status = Rejected
+			u.Reason = "stale info"
+		} else if err := classifyPush(objects, graph, u, forced[u.RemoteRef] || leased); err != nil {
+			return nil, err
+		}
+		switch u.Status {
+		case NewRef, FastForward, ForcedUpdate, Deleted:
+			commands = append(commands, &client.PushCommand{
+				RefName: u.RemoteRef,
+				Old:     u.OldObjectID,
+				New:     u.NewObjectID,
+			})
+		}
+	}
+	if len(commands) == 0 {
+		streamClosed = true
+		if err := stream.Close(); err != nil {
+			return nil, err
+		}
+		return updates, nil
+	}
+
+	err = stream.WriteRequest(&client.PushRequest{
+		Commands: commands,
+		Atomic:   opts.Atomic,
+		Options:  opts.Options,
+		Progress: opts.Progress,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var wants []githash.ObjectID
+	for _, c := range commands {
+		if !c.New.IsZero() {
+			wants = append(wants, c.New)
+		}
+	}
+	if len(wants) > 0 {
+		var haves []githash.ObjectID
+		for _, ref := range remoteRefs {
+			if !ref.ObjectID.IsZero() {
+				haves = append(haves, ref.ObjectID)
+			}
+		}
+		walk, err := objects.objectsToPush(ctx, graph, wants, haves)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Thin && !stream.Capabilities().Has(client.PushCapNoThin) {
+			err = objects.writeThinPack(stream, walk)
+		} else {
+			err = objects.writePack(stream, walk.objects, stream.Capabilities().Has(client.PushCapOfsDelta))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	streamClosed = true
+	closeErr := stream.Close()
+	remoteRejected := false
+	for _, status := range stream.RefStatuses() {
+		if status.OK() {
+			continue
+		}
+		for _, u := range updates {
+			if u.RemoteRef == status.RefName && u.Status != Rejected && u.Status != UpToDate {
+				u.Status = Rejected
+				u.Reason = status.Error
+				remoteRejected = true
+			}
+		}
+	}
+	if closeErr != nil && !remoteRejected {
+		return nil, closeErr
+	}
+	return updates, nil
+}
+
+// mapPushRefs applies refspecs to the local refs. It returns the updates
+// sorted by remote ref name and the set of remote refs that may be
+// force-updated.
+func mapPushRefs(localRefs map[githash.Ref]*gitrepo.Ref, refspecs []git.PushRefspec) ([]*PushUpdate, map[githash.Ref]bool, error) {
+	byRemote := make(map[githash.Ref]*PushUpdate)
+	forced := make(map[githash.Ref]bool)
+	add := func(spec git.PushRefspec, u *PushUpdate) error {
+		if !u.RemoteRef.IsValid() {
+			return fmt.Errorf("refspec %q maps to invalid ref %q", spec, u.RemoteRef)
+		}
+		if prev := byRemote[u.RemoteRef]; prev != nil {
+			if prev.LocalRef != u.LocalRef {
+				return fmt.Errorf("%s and %s both map to %s", displayLocalRef(prev.LocalRef), displayLocalRef(u.LocalRef), u.RemoteRef)
+			}
+		} else {
+			byRemote[u.RemoteRef] = u
+		}
+		return nil
+	}
+	for _, spec := range refspecs {
+		src, dst, plus := spec.Parse()
+		switch {
+		case spec.IsDelete():
+			if _, isPattern := dst.Prefix(); isPattern {
+				return nil, nil, fmt.Errorf("refspec %q: cannot delete a pattern", spec)
+			}
+			remoteRef := githash.Ref(dst)
+			if !strings.HasPrefix(string(dst), "refs/") {
+				remoteRef = githash.BranchRef(string(dst))
+			}
+			if err := add(spec, &PushUpdate{RemoteRef: remoteRef}); err != nil {
+				return nil, nil, err
+			}
+			if plus {
+				forced[remoteRef] = true
+			}
+			continue
+		case src == "":
+			return nil, nil, fmt.Errorf("refspec %q: missing source", spec)
+		}
+
+		if _, isPattern := src.Prefix(); isPattern {
+			for _, ref := range localRefs {
+				if ref.SymrefTarget != "" || ref.ObjectID.IsZero() {
+					continue
+				}
+				remoteRef := spec.Map(ref.Name)
+				if remoteRef == "" {
+					continue
+				}
+				err := add(spec, &PushUpdate{
+					LocalRef:    ref.Name,
+					RemoteRef:   remoteRef,
+					NewObjectID: ref.ObjectID,
+				})
+				if err != nil {
+					return nil, nil, err
+				}
+				if plus {
+					forced[remoteRef] = true
+				}
+			}
+			continue
+		}
+
+		ref := resolveLocalRef(localRefs, src)
+		if ref == nil {
+			return nil, nil, fmt.Errorf("src refspec %s does not match any", src)
+		}
+		if ref.ObjectID.IsZero() {
+			return nil, nil, fmt.Errorf("src refspec %s points to an unborn branch", src)
+		}
+		remoteRef := spec.Map(ref.Name)
+		if dst == "" && ref.SymrefTarget != "" {
+			// Like Git, push a symbolic ref like HEAD to the ref it points to.
+			remoteRef = ref.SymrefTarget
+		}
+		if remoteRef == "" {
+			return nil, nil, fmt.Errorf("refspec %q: cannot infer full name of destination %s", spec, dst)
+		}
+		err := add(spec, &PushUpdate{
+			LocalRef:    ref.Name,
+			RemoteRef:   remoteRef,
+			NewObjectID: ref.ObjectID,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if plus {
+			forced[remoteRef] = true
+		}
+	}
+	updates := make([]*PushUpdate, 0, len(byRemote))
+	for _, u := range byRemote {
+		updates = append(updates, u)
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].RemoteRef < updates[j].RemoteRef
+	})
+	return updates, forced, nil
+}
+
+// resolveLocalRef finds the local ref for a non-wildcard refspec source,
+// using the same precedence as gitrevisions(7).
+func resolveLocalRef(localRefs map[githash.Ref]*gitrepo.Ref, src git.RefPattern) *gitrepo.Ref {
+	for _, format := range []string{"%s", "refs/%s", "refs/tags/%s", "refs/heads/%s", "refs/remotes/%s", "refs/remotes/%s/HEAD"} {
+		if ref := localRefs[githash.Ref(fmt.Sprintf(format, src))]; ref != nil {
+			return ref
+		}
+	}
+	return nil
+}
+
+func displayLocalRef(ref githash.Ref) string {
+	if ref == "" {
+		return "(delete)"
+	}
+	return ref.String()
+}
+
+// classifyPush sets u.Status and u.Reason.
+// u.OldObjectID must be set to the remote's current value of the ref.
+func classifyPush(objects *objectSource, graph *revwalk.Graph, u *PushUpdate, force bool) error {
+	switch {
+	case u.NewObjectID.IsZero() && u.OldObjectID.IsZero():
+		u.Status = Rejected
+		u.Reason = "remote ref does not exist"
+	case u.NewObjectID.IsZero():
+		u.Status = Deleted
+	case u.OldObjectID == u.NewObjectID:
+		u.Status = UpToDate
+	case u.OldObjectID.IsZero():
+		u.Status = NewRef
+	case u.RemoteRef.IsTag() && force:
+		u.Status = ForcedUpdate
+	case u.RemoteRef.IsTag():
+		u.Status = Rejected
+		u.Reason = "already exists"
+	default:
+		oldCommit, err := objects.peelToCommit(u.OldObjectID)
+		if errors.Is(err, fs.ErrNotExist) {
+			// We don't have the remote's history,
+			// so we can't tell whether this is a fast-forward.
+			if force {
+				u.Status = ForcedUpdate
+			} else {
+				u.Status = Rejected
+				u.Reason = "fetch first"
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		newCommit, err := objects.peelToCommit(u.NewObjectID)
+		if err != nil {
+			return err
+		}
+		ff := false
+		if !oldCommit.IsZero() && !newCommit.IsZero() {
+			ff, err = graph.IsAncestor(oldCommit, newCommit)
+			if err != nil {
+				return err
+			}
+		}
+		switch {
+		case ff:
+			u.Status = FastForward
+		case force:
+			u.Status = ForcedUpdate
+		default:
+			u.Status = Rejected
+			u.Reason = "non-fast-forward"
+		}
+	}
+	return nil
+}
+
+// objectSource reads objects from a Repository.
+// It implements [revwalk.CommitReader] and [client.CommitSource].
+type objectSource struct {
+	repo Repository
+}
+
+func (src *objectSource) readObject(id githash.ObjectID) (object.Type, []byte, error) {
+	prefix, rc, err := src.repo.OpenObject(id)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rc.Close()
+	data := make([]byte, int(prefix.Size))
+	if _, err := io.ReadFull(rc, data); err != nil {
+		return "", nil, fmt.Errorf("read object %v: %w", id, err)
+	}
+	return prefix.Type, data, nil
+}
+
+// ReadCommit reads and parses the commit with the given ID.
+func (src *objectSource) ReadCommit(id githash.ObjectID) (*object.Commit, error) {
+	typ, data, err := src.readObject(id)
+	if err != nil {
+		return nil, err
+	}
+	if typ != object.TypeCommit {
+		return nil, fmt.Errorf("object %v: is a %v, not a commit", id, typ)
+	}
+	c, err := object.ParseCommit(data)
+	if err != nil {
+		return nil, fmt.Errorf("commit %v: %w", id, err)
+	}
+	return c, nil
+}
+
+func (src *objectSource) readTree(id githash.ObjectID) (object.Tree, error) {
+	typ, data, err := src.readObject(id)
+	if err != nil {
+		return nil, err
+	}
+	if typ != object.TypeTree {
+		return nil, fmt.Errorf("object %v: is a %v, not a tree", id, typ)
+	}
+	tree, err := object.ParseTreeFormat(data, src.repo.ObjectFormat())
+	if err != nil {
+		return nil, fmt.Errorf("tree %v: %w", id, err)
+	}
+	return tree, nil
+}
+
+// peelToCommit follows the chain of annotated tags starting at id.
+// It returns the zero ObjectID if the chain does not end in a commit.
+func (src *objectSource) peelToCommit(id githash.ObjectID) (githash.ObjectID, error) {
+	for i := 0; i < maxTagDepth; i++ {
+		typ, data, err := src.readObject(id)
+		if err != nil {
+			return githash.ObjectID{}, err
+		}
+		switch typ {
+		case object.TypeCommit:
+			return id, nil
+		case object.TypeTag:
+			tag, err := object.ParseTag(data)
+			if err != nil {
+				return githash.ObjectID{}, fmt.Errorf("tag %v: %w", id, err)
+			}
+			id = tag.ObjectID
+		default:
+			return githash.ObjectID{}, nil
+		}
+	}
+	return githash.ObjectID{}, fmt.Errorf("peel %v: tags nested too deeply", id)
+}
+
+// maxTagDepth is the maximum number of tags that will be followed when
+// peeling a tag.
+const maxTagDepth = 32
+
+// pushObject is an object selected to be sent to the remote.
+type pushObject struct {
+	id   githash.ObjectID
+	name string // path hint for delta compression
+}
+
+// pushWalk is the result of objectsToPush.
+type pushWalk struct {
+	// objects is the list of objects to send.
+	objects []pushObject
+	// bases maps paths in the trees of the boundary commits to the objects
+	// at those paths. These objects are known to be on the remote,
+	// so they can be used as delta bases for a thin pack.
+	bases map[string]githash.ObjectID
+}
+
+// objectsToPush returns the objects reachable from wants
+// that are not reachable from haves.
+// Commits are listed with a walk over graph that hides the haves,
+// so only the history between the haves and the wants is read.
+// Haves that are not present in the repository are ignored.
+func (src *objectSource) objectsToPush(ctx context.Context, graph *revwalk.Graph, wants, haves []githash.ObjectID) (*pushWalk, error) {
+	// seen is the set of non-commit objects that have either been selected
+	// or that the remote is known to have.
+	seen := make(map[githash.ObjectID]struct{})
+	selected := make(map[githash.ObjectID]struct{})
+	walk := &pushWalk{bases: make(map[string]githash.ObjectID)}
+	add := func(id githash.ObjectID, name string) {
+		selected[id] = struct{}{}
+		walk.objects = append(walk.objects, pushObject{id: id, name: name})
+	}
+
+	var hide []githash.ObjectID
+	for _, id := range haves {
+		// Mark any tags the remote has, then hide the commit they point to.
+		for depth := 0; ; depth++ {
+			if depth >= maxTagDepth {
+				return nil, fmt.Errorf("peel %v: tags nested too deeply", id)
+			}
+			if _, ok := seen[id]; ok {
+				break
+			}
+			typ, data, err := src.readObject(id)
+			if errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if typ == object.TypeCommit {
+				hide = append(hide, id)
+				break
+			}
+			seen[id] = struct{}{}
+			if typ != object.TypeTag {
+				break
+			}
+			tag, err := object.ParseTag(data)
+			if err != nil {
+				return nil, fmt.Errorf("tag %v: %w", id, err)
+			}
+			id = tag.ObjectID
+		}
+	}
+
+	var include []githash.ObjectID
+	var trees []githash.ObjectID
+	for _, id := range wants {
+		for depth := 0; ; depth++ {
+			if depth >= maxTagDepth {
+				return nil, fmt.Errorf("peel %v: tags nested too deeply", id)
+			}
+			if _, ok := seen[id]; ok {
+				break
+			}
+			if _, ok := selected[id]; ok {
+				break
+			}
+			typ, data, err := src.readObject(id)
+			if err != nil {
+				return nil, err
+			}
+			if typ == object.TypeCommit {
+				include = append(include, id)
+				break
+			}
+			if typ == object.TypeTree {
+				trees = append(trees, id)
+				break
+			}
+			add(id, "")
+			if typ != object.TypeTag {
+				break
+			}
+			tag, err := object.ParseTag(data)
+			if err != nil {
+				return nil, fmt.Errorf("tag %v: %w", id, err)
+			}
+			id = tag.ObjectID
+		}
+	}
+
+	// Walk commits first to find the boundary between the commits being sent
+	// and the commits the remote has. Objects in the boundary commits' trees
+	// don't need to be sent.
+	var boundary []githash.ObjectID
+	if len(include) > 0 {
+		walker, err := graph.Walk(revwalk.WalkOptions{
+			Include: include,
+			Hide:    hide,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var parents []githash.ObjectID
+		for walker.Next() {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			c := walker.Commit()
+			add(walker.ID(), "")
+			trees = append(trees, c.Tree)
+			parents = append(parents, c.Parents...)
+		}
+		if err := walker.Err(); err != nil {
+			return nil, err
+		}
+		// Any included commit or parent that was not listed
+		// is reachable from the haves.
+		isBoundary := make(map[githash.ObjectID]struct{})
+		for _, id := range append(include, parents...) {
+			if _, ok := selected[id]; ok {
+				continue
+			}
+			if _, ok := isBoundary[id]; ok {
+				continue
+			}
+			isBoundary[id] = struct{}{}
+			boundary = append(boundary, id)
+		}
+	}
+
+	var markTree func(id githash.ObjectID, name string) error
+	markTree = func(id githash.ObjectID, name string) error {
+		if _, ok := walk.bases[name]; !ok {
+			walk.bases[name] = id
+		}
+		if _, ok := seen[id]; ok {
+			return nil
+		}
+		seen[id] = struct{}{}
+		tree, err := src.readTree(id)
+		if err != nil {
+			return err
+		}
+		for _, ent := range tree {
+			entName := joinPath(name, ent.Name)
+			switch {
+			case ent.Mode == object.ModeGitlink:
+			case ent.Mode.IsDir():
+				if err := markTree(ent.ObjectID, entName); err != nil {
+					return err
+				}
+			default:
+				seen[ent.ObjectID] = struct{}{}
+				if _, ok := walk.bases[entName]; !ok {
+					walk.bases[entName] = ent.ObjectID
+				}
+			}
+		}
+		return nil
+	}
+	for _, id := range boundary {
+		c, err := src.ReadCommit(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := markTree(c.Tree, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	var addTree func(id githash.ObjectID, name string) error
+	addTree = func(id githash.ObjectID, name string) error {
+		if _, ok := seen[id]; ok {
+			return nil
+		}
+		if _, ok := selected[id]; ok {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		add(id, name)
+		tree, err := src.readTree(id)
+		if err != nil {
+			return err
+		}
+		for _, ent := range tree {
+			entName := joinPath(name, ent.Name)
+			switch {
+			case ent.Mode == object.ModeGitlink:
+				// Submodule commits are not part of this repository.
+			case ent.Mode.IsDir():
+				if err := addTree(ent.ObjectID, entName); err != nil {
+					return err
+				}
+			default:
+				if _, ok := seen[ent.ObjectID]; ok {
+					continue
+				}
+				if _, ok := selected[ent.ObjectID]; ok {
+					continue
+				}
+				add(ent.ObjectID, entName)
+			}
+		}
+		return nil
+	}
+	for _, tree := range trees {
+		if err := addTree(tree, ""); err != nil {
+			return nil, err
+		}
+	}
+	return walk, nil
+}
+
+func joinPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// writePack writes a full packfile containing the given objects to w.
+// Objects are read as the packfile is built, so only the objects
+// in the delta window are held in memory.
+func (src *objectSource) writePack(w io.Writer, objects []pushObject, ofsDelta bool) error {
+	buildObjects := make([]packfile.BuildObject, 0, len(objects))
+	for _, obj := range objects {
+		prefix, rc, err := src.repo.OpenObject(obj.id)
+		if err != nil {
+			return err
+		}
+		rc.Close()
+		buildObjects = append(buildObjects, packfile.BuildObject{
+			Type: packObjectType(prefix.Type),
+			Size: prefix.Size,
+			Name: obj.name,
+		})
+	}
+	opts := &packfile.BuildOptions{
+		ObjectFormat: src.repo.ObjectFormat(),
+		Load: func(i int) ([]byte, error) {
+			_, data, err := src.readObject(objects[i].id)
+			return data, err
+		},
+	}
+	if !ofsDelta {
+		opts.Window = -1
+	}
+	return packfile.Build(w, buildObjects, opts)
+}
+
+// writeThinPack writes a packfile containing the objects in walk to w.
+// Trees and blobs are written as deltas against the object
+// at the same path in a boundary commit when doing so is smaller.
+// The packfile is "thin": the remote must already have the delta bases.
+func (src *objectSource) writeThinPack(w io.Writer, walk *pushWalk) error {
+	pw := packfile.NewWriterFormat(w, uint32(len(walk.objects)), src.repo.ObjectFormat())
+	for _, obj := range walk.objects {
+		typ, data, err := src.readObject(obj.id)
+		if err != nil {
+			return err
+		}
+		hdr := &packfile.Header{
+			Type: packObjectType(typ),
+			Size: int64(len(data)),
+		}
+		if baseID, ok := walk.bases[obj.name]; ok && (typ == object.TypeTree || typ == object.TypeBlob) {
+			baseType, baseData, err := src.readObject(baseID)
+			if err != nil {
+				return err
+			}
+			if baseType == typ {
+				if delta := packfile.AppendDelta(nil, baseData, data); len(delta) < len(data) {
+					hdr = &packfile.Header{
+						Type:       packfile.RefDelta,
+						Size:       int64(len(delta)),
+						BaseObject: baseID,
+					}
+					data = delta
+				}
+			}
+		}
+		if _, err := pw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := pw.Write(data); err != nil {
+			return err
+		}
+	}
+	return pw.Close()
+}
+
+func packObjectType(typ object.Type) packfile.ObjectType {
+	switch typ {
+	case object.TypeCommit:
+		return packfile.Commit
+	case object.TypeTree:
+		return packfile.Tree
+	case object.TypeBlob:
+		return packfile.Blob
+	case object.TypeTag:
+		return packfile.Tag
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,454 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transfer
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/object"
+	"gg-scm.io/pkg/git/packfile/client"
+	"gg-scm.io/pkg/git/packfile/server"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPush(t *testing.T) {
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	for _, format := range testFormats {
+		t.Run(format.String(), func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			remoteGit := git.Custom(dir, localGit, localGit)
+			if err := remoteGit.Run(ctx, "init", "--quiet", "--bare", "--object-format="+format.String(), "."); err != nil {
+				t.Skip("Can't create repository with object format:", err)
+			}
+			remote, err := client.NewRemote(client.URLFromPath(dir), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			store := server.NewMemoryStore(format)
+			src := newTestHistory(t, store)
+			commit1 := src.commit("foo.txt", strings.Repeat("Hello, World!\n", 20))
+			tag1 := src.tag(commit1, "v1")
+			main := githash.BranchRef("main")
+			other := githash.BranchRef("other")
+			src.setRef(main, commit1)
+			src.setRef(githash.TagRef("v1"), tag1)
+
+			push := func(t *testing.T, opts *PushOptions, refspecs ...git.PushRefspec) []*PushUpdate {
+				t.Helper()
+				updates, err := Push(ctx, store, remote, refspecs, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := remoteGit.Run(ctx, "fsck", "--no-dangling", "--no-progress"); err != nil {
+					t.Error(err)
+				}
+				return updates
+			}
+			checkRefs := func(t *testing.T, want map[githash.Ref]githash.ObjectID) {
+				t.Helper()
+				got, err := listLocalRefs(ctx, remoteGit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("remote refs (-want +got):\n%s", diff)
+				}
+			}
+
+			t.Run("Initial", func(t *testing.T) {
+				got := push(t, nil, "refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*")
+				want := []*PushUpdate{
+					{Status: NewRef, LocalRef: main, RemoteRef: main, NewObjectID: commit1},
+					{Status: NewRef, LocalRef: githash.TagRef("v1"), RemoteRef: githash.TagRef("v1"), NewObjectID: tag1},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+				checkRefs(t, map[githash.Ref]githash.ObjectID{
+					main:                 commit1,
+					githash.TagRef("v1"): tag1,
+				})
+			})
+
+			commit2 := src.commit("foo.txt", strings.Repeat("Hello, World!\n", 20)+"Goodbye\n", commit1)
+			src.setRef(main, commit2)
+
+			t.Run("Thin", func(t *testing.T) {
+				got := push(t, &PushOptions{Thin: true}, "main")
+				want := []*PushUpdate{
+					{Status: FastForward, LocalRef: main, RemoteRef: main, OldObjectID: commit1, NewObjectID: commit2},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+				checkRefs(t, map[githash.Ref]githash.ObjectID{
+					main:                 commit2,
+					githash.TagRef("v1"): tag1,
+				})
+			})
+
+			commit3 := src.commit("bar.txt", "Diverged\n", commit1)
+			tag2 := src.tag(commit3, "v1")
+			src.setRef(main, commit3)
+			src.setRef(githash.TagRef("v1"), tag2)
+
+			t.Run("Rejected", func(t *testing.T) {
+				got := push(t, nil, "main", "v1")
+				want := []*PushUpdate{
+					{Status: Rejected, LocalRef: main, RemoteRef: main, OldObjectID: commit2, NewObjectID: commit3, Reason: "non-fast-forward"},
+					{Status: Rejected, LocalRef: githash.TagRef("v1"), RemoteRef: githash.TagRef("v1"), OldObjectID: tag1, NewObjectID: tag2, Reason: "already exists"},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+				checkRefs(t, map[githash.Ref]githash.ObjectID{
+					main:                 commit2,
+					githash.TagRef("v1"): tag1,
+				})
+			})
+
+			t.Run("StaleLease", func(t *testing.T) {
+				got := push(t, &PushOptions{Leases: map[githash.Ref]githash.ObjectID{main: commit1}}, "main")
+				want := []*PushUpdate{
+					{Status: Rejected, LocalRef: main, RemoteRef: main, OldObjectID: commit2, NewObjectID: commit3, Reason: "stale info"},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+			})
+
+			t.Run("Lease", func(t *testing.T) {
+				got := push(t, &PushOptions{Leases: map[githash.Ref]githash.ObjectID{main: commit2}}, "main")
+				want := []*PushUpdate{
+					{Status: ForcedUpdate, LocalRef: main, RemoteRef: main, OldObjectID: commit2, NewObjectID: commit3},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+				checkRefs(t, map[githash.Ref]githash.ObjectID{
+					main:                 commit3,
+					githash.TagRef("v1"): tag1,
+				})
+			})
+
+			// Create a commit that only the remote has.
+			tree, err := remoteGit.MakeTree(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			remoteOnly, err := remoteGit.CommitTree(ctx, &object.Commit{
+				Tree:       tree,
+				Author:     testAuthor,
+				AuthorTime: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+				Committer:  testAuthor,
+				CommitTime: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+				Message:    "Remote only\n",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := remoteGit.Run(ctx, "update-ref", other.String(), remoteOnly.String()); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Run("FetchFirst", func(t *testing.T) {
+				got := push(t, nil, "main:other")
+				want := []*PushUpdate{
+					{Status: Rejected, LocalRef: main, RemoteRef: other, OldObjectID: remoteOnly, NewObjectID: commit3, Reason: "fetch first"},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+			})
+
+			t.Run("Forced", func(t *testing.T) {
+				got := push(t, nil, "+main:other", "+v1")
+				want := []*PushUpdate{
+					{Status: ForcedUpdate, LocalRef: main, RemoteRef: other, OldObjectID: remoteOnly, NewObjectID: commit3},
+					{Status: ForcedUpdate, LocalRef: githash.TagRef("v1"), RemoteRef: githash.TagRef("v1"), OldObjectID: tag1, NewObjectID: tag2},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+				checkRefs(t, map[githash.Ref]githash.ObjectID{
+					main:                 commit3,
+					other:                commit3,
+					githash.TagRef("v1"): tag2,
+				})
+			})
+
+			t.Run("Delete", func(t *testing.T) {
+				got := push(t, nil, ":other", ":refs/heads/missing")
+				want := []*PushUpdate{
+					{Status: Rejected, RemoteRef: githash.BranchRef("missing"), Reason: "remote ref does not exist"},
+					{Status: Deleted, RemoteRef: other, OldObjectID: commit3},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+				checkRefs(t, map[githash.Ref]githash.ObjectID{
+					main:                 commit3,
+					githash.TagRef("v1"): tag2,
+				})
+			})
+
+			t.Run("UpToDate", func(t *testing.T) {
+				got := push(t, nil, "main")
+				want := []*PushUpdate{
+					{Status: UpToDate, LocalRef: main, RemoteRef: main, OldObjectID: commit3, NewObjectID: commit3},
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updates (-want +got):\n%s", diff)
+				}
+			})
+
+			t.Run("NoMatch", func(t *testing.T) {
+				if _, err := Push(ctx, store, remote, []git.PushRefspec{"nonexistent"}, nil); err == nil {
+					t.Error("Push did not return an error")
+				}
+			})
+		})
+	}
+}
+
+// TestPushNoThin verifies that Push sends a full pack
+// to a remote that advertises no-thin, even if Thin is set.
+func TestPushNoThin(t *testing.T) {
+	ctx := context.Background()
+	remoteStore := server.NewMemoryStore(githash.SHA1Format)
+	httpServer := httptest.NewServer(server.New(remoteStore, nil))
+	t.Cleanup(httpServer.Close)
+	u, err := client.ParseURL(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := client.NewRemote(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := server.NewMemoryStore(githash.SHA1Format)
+	src := newTestHistory(t, store)
+	main := githash.BranchRef("main")
+	commit1 := src.commit("foo.txt", strings.Repeat("Hello, World!\n", 20))
+	src.setRef(main, commit1)
+	if _, err := Push(ctx, store, remote, []git.PushRefspec{"main"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	commit2 := src.commit("foo.txt", strings.Repeat("Hello, World!\n", 20)+"Goodbye\n", commit1)
+	src.setRef(main, commit2)
+	got, err := Push(ctx, store, remote, []git.PushRefspec{"main"}, &PushOptions{Thin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*PushUpdate{
+		{Status: FastForward, LocalRef: main, RemoteRef: main, OldObjectID: commit1, NewObjectID: commit2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("updates (-want +got):\n%s", diff)
+	}
+	refs, err := remoteStore.ListRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref := refs[main]; ref == nil || ref.ObjectID != commit2 {
+		t.Errorf("remote %v = %v; want %v", main, ref, commit2)
+	}
+}
+
+// TestPushRepository verifies that Push reads from repositories
+// on disk through either Git or gitrepo.
+func TestPushRepository(t *testing.T) {
+	localGit, err := git.NewLocal(git.Options{})
+	if err != nil {
+		t.Skip("Can't find Git, skipping:", err)
+	}
+	tests := []struct {
+		name string
+		open func(ctx context.Context, g *git.Git, dir string) (Repository, func() error, error)
+	}{
+		{
+			name: "Git",
+			open: func(ctx context.Context, g *git.Git, dir string) (Repository, func() error, error) {
+				r, err := OpenGitRepository(ctx, g)
+				if err != nil {
+					return nil, nil, err
+				}
+				return r, r.Close, nil
+			},
+		},
+		{
+			name: "GitRepo",
+			open: func(ctx context.Context, g *git.Git, dir string) (Repository, func() error, error) {
+				r, err := gitrepo.Open(dir)
+				if err != nil {
+					return nil, nil, err
+				}
+				return r, r.Close, nil
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			remoteDir := t.TempDir()
+			remoteGit := git.Custom(remoteDir, localGit, localGit)
+			if err := remoteGit.InitBare(ctx, "."); err != nil {
+				t.Fatal(err)
+			}
+			remote, err := client.NewRemote(client.URLFromPath(remoteDir), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dir := t.TempDir()
+			g := git.Custom(dir, localGit, localGit)
+			if err := g.InitBare(ctx, "."); err != nil {
+				t.Fatal(err)
+			}
+			blob, err := g.HashObject(ctx, object.TypeBlob, strings.NewReader("Hello, World!\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree, err := g.MakeTree(ctx, object.Tree{{Name: "foo.txt", Mode: object.ModePlain, ObjectID: blob}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			commitTime := time.Date(2020, time.January, 9, 14, 50, 0, 0, time.UTC)
+			commit, err := g.CommitTree(ctx, &object.Commit{
+				Tree:       tree,
+				Author:     testAuthor,
+				AuthorTime: commitTime,
+				Committer:  testAuthor,
+				CommitTime: commitTime,
+				Message:    "Initial import\n",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := g.Run(ctx, "update-ref", "refs/heads/main", commit.String()); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.Run(ctx, "symbolic-ref", "HEAD", "refs/heads/main"); err != nil {
+				t.Fatal(err)
+			}
+
+			repo, closeRepo, err := test.open(ctx, g, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if err := closeRepo(); err != nil {
+					t.Error("Close:", err)
+				}
+			}()
+			// HEAD is pushed to the branch it points to.
+			got, err := Push(ctx, repo, remote, []git.PushRefspec{"HEAD"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []*PushUpdate{
+				{Status: NewRef, LocalRef: githash.Head, RemoteRef: githash.BranchRef("main"), NewObjectID: commit},
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("updates (-want +got):\n%s", diff)
+			}
+			if err := remoteGit.Run(ctx, "fsck", "--no-dangling", "--no-progress"); err != nil {
+				t.Error(err)
+			}
+			rev, err := remoteGit.ParseRev(ctx, "refs/heads/main")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rev.Commit != commit {
+				t.Errorf("remote refs/heads/main = %v; want %v", rev.Commit, commit)
+			}
+		})
+	}
+}
+
+func TestPushUpdateString(t *testing.T) {
+	oldID, err := githash.ParseObjectID("8a3ca1e12ab3a9e1e6e0a7d4f1d4a6e2d0c3e6b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newID, err := githash.ParseObjectID("c4d39e3d1f2a0bb7d2f1b9a3bdc6a6c37b3d5e12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		u    *PushUpdate
+		want string
+	}{
+		{
+			u: &PushUpdate{
+				Status:      NewRef,
+				LocalRef:    "refs/heads/main",
+				RemoteRef:   "refs/heads/main",
+				NewObjectID: newID,
+			},
+			want: "*\trefs/heads/main:refs/heads/main\t[new branch]",
+		},
+		{
+			u: &PushUpdate{
+				Status:      FastForward,
+				LocalRef:    "refs/heads/main",
+				RemoteRef:   "refs/heads/main",
+				OldObjectID: oldID,
+				NewObjectID: newID,
+			},
+			want: " \trefs/heads/main:refs/heads/main\t8a3ca1e12ab3a9e1e6e0a7d4f1d4a6e2d0c3e6b1..c4d39e3d1f2a0bb7d2f1b9a3bdc6a6c37b3d5e12",
+		},
+		{
+			u: &PushUpdate{
+				Status:      Rejected,
+				LocalRef:    "refs/heads/main",
+				RemoteRef:   "refs/heads/main",
+				OldObjectID: oldID,
+				NewObjectID: newID,
+				Reason:      "non-fast-forward",
+			},
+			want: "!\trefs/heads/main:refs/heads/main\t[rejected] (non-fast-forward)",
+		},
+		{
+			u: &PushUpdate{
+				Status:      Deleted,
+				RemoteRef:   "refs/heads/old",
+				OldObjectID: oldID,
+			},
+			want: "-\t:refs/heads/old\t[deleted]",
+		},
+	}
+	for _, test := range tests {
+		if got := test.u.String(); got != test.want {
+			t.Errorf("(%+v).String() = %q; want %q", test.u, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 The gg Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"gg-scm.io/pkg/git"
+	"gg-scm.io/pkg/git/githash"
+	"gg-scm.io/pkg/git/gitrepo"
+	"gg-scm.io/pkg/git/object"
+)
+
+// A Repository is a local repository that Push reads refs and objects from.
+// [*gitrepo.Repository] and [*gg-scm.io/pkg/git/packfile/server.MemoryStore]
+// implement Repository, and [OpenGitRepository] adapts a repository
+// accessed through [git.Git].
+type Repository interface {
+	// ObjectFormat returns the object format of the repository.
+	ObjectFormat() githash.ObjectFormat
+	// ListRefs returns the repository's refs, including HEAD.
+	// If refPrefixes is given, then only refs that start with one of
+	// the given strings need to be returned.
+	ListRefs(refPrefixes ...string) (map[githash.Ref]*gitrepo.Ref, error)
+	// OpenObject returns the type and size of the object with the given ID
+	// and a reader for the object's contents. If the object does not exist,
+	// OpenObject returns an error for which errors.Is(err, fs.ErrNotExist)
+	// reports true.
+	OpenObject(id githash.ObjectID) (object.Prefix, io.ReadCloser, error)
+}
+
+// GitRepository is a [Repository] that reads from a repository
+// by running Git subprocesses.
+type GitRepository struct {
+	ctx     context.Context
+	g       *git.Git
+	format  githash.ObjectFormat
+	objects *git.ObjectReader
+}
+
+// OpenGitRepository returns a [Repository] for the repository g operates on.
+// The context's deadline and cancelation apply to the lifetime
+// of the GitRepository. The caller is responsible for calling Close
+// on the returned GitRepository.
+func OpenGitRepository(ctx context.Context, g *git.Git) (*GitRepository, error) {
+	format, err := g.ObjectFormat(ctx)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := g.NewObjectReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &GitRepository{
+		ctx:     ctx,
+		g:       g,
+		format:  format,
+		objects: objects,
+	}, nil
+}
+
+// ObjectFormat returns the object format of the repository.
+func (r *GitRepository) ObjectFormat() githash.ObjectFormat {
+	return r.format
+}
+
+// ListRefs returns the repository's refs, including HEAD.
+// If refPrefixes is given, then only refs that start with one of
+// the given strings are returned.
+func (r *GitRepository) ListRefs(refPrefixes ...string) (map[githash.Ref]*gitrepo.Ref, error) {
+	matches := func(name githash.Ref) bool {
+		if len(refPrefixes) == 0 {
+			return true
+		}
+		for _, prefix := range refPrefixes {
+			if strings.HasPrefix(string(name), prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	refs := make(map[githash.Ref]*gitrepo.Ref)
+	iter := r.g.IterateRefs(r.ctx, git.IterateRefsOptions{IncludeHead: true})
+	for iter.Next() {
+		if name := iter.Ref(); matches(name) {
+			refs[name] = &gitrepo.Ref{
+				Name:     name,
+				ObjectID: iter.ObjectID(),
+			}
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("list refs: %w", err)
+	}
+	if matches(githash.Head) {
+		target, err := r.g.HeadRef(r.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list refs: %w", err)
+		}
+		if head := refs[githash.Head]; head != nil {
+			head.SymrefTarget = target
+		} else if target != "" {
+			// Unborn branch.
+			refs[githash.Head] = &gitrepo.Ref{
+				Name:         githash.Head,
+				SymrefTarget: target,
+			}
+		}
+	}
+	return refs, nil
+}
+
+// OpenObject returns the type and size of the object with the given ID
+// and a reader for the object's contents. The caller must close the
+// returned reader before calling OpenObject again.
+// If the object does not exist, OpenObject returns an error for which
+// errors.Is(err, fs.ErrNotExist) reports true.
+func (r *GitRepository) OpenObject(id githash.ObjectID) (object.Prefix, io.ReadCloser, error) {
+	info, rc, err := r.objects.Open(id.String())
+	if err != nil {
+		return object.Prefix{}, nil, err
+	}
+	return object.Prefix{Type: info.Type, Size: info.Size}, rc, nil
+}
+
+// Close stops the Git subprocesses started by the GitRepository.
+func (r *GitRepository) Close() error {
+	return r.objects.Close()
+}